@@ -0,0 +1,197 @@
+// cmd/migrate/main.go
+// Управление миграциями базы данных:
+//
+//	go run ./cmd/migrate status
+//	go run ./cmd/migrate up [N]
+//	go run ./cmd/migrate down [N]
+//	go run ./cmd/migrate redo
+//	go run ./cmd/migrate create <name>
+//	go run ./cmd/migrate force <version>
+//	go run ./cmd/migrate accept <version>
+//
+// Флаг -dry-run печатает SQL, не выполняя его. Флаг -allow-drift разрешает
+// работать, если файлы примененных миграций изменились.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"strconv"
+	"text/tabwriter"
+
+	"vend_erp/config"
+	"vend_erp/migrations"
+)
+
+func usage() {
+	fmt.Fprintf(os.Stderr, `Usage: migrate [flags] <command> [args]
+
+Commands:
+  status            show applied and pending migrations
+  up [N]            apply all or N pending migrations
+  down [N]          revert the last N applied migrations (default 1)
+  redo              revert and re-apply the last migration
+  create <name>     create NNN_<name>.up.sql and NNN_<name>.down.sql
+  force <version>   mark migrations up to <version> as applied without running SQL
+  accept <version>  record the current checksum of a changed applied migration
+
+Flags:
+`)
+	flag.PrintDefaults()
+}
+
+func main() {
+	path := flag.String("path", "", "read migrations from this directory instead of the binary (create defaults to ./migrations)")
+	dryRun := flag.Bool("dry-run", false, "print SQL instead of executing it")
+	allowDrift := flag.Bool("allow-drift", false, "continue when applied migration files have changed")
+	flag.Usage = usage
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		usage()
+		os.Exit(2)
+	}
+	command, args := flag.Arg(0), flag.Args()[1:]
+
+	// create не требует подключения к базе
+	if command == "create" {
+		if len(args) != 1 {
+			log.Fatal("create requires a migration name")
+		}
+		dir := *path
+		if dir == "" {
+			dir = "./migrations"
+		}
+		up, down, err := migrations.Create(dir, args[0])
+		if err != nil {
+			log.Fatalf("Failed to create migration: %v", err)
+		}
+		fmt.Printf("Created %s\nCreated %s\n", up, down)
+		return
+	}
+
+	cfg := config.LoadConfig()
+	db, err := config.ConnectDB(cfg)
+	if err != nil {
+		log.Fatalf("Failed to connect to database: %v", err)
+	}
+	defer db.Close()
+
+	var files fs.FS = migrations.FS
+	if *path != "" {
+		files = os.DirFS(*path)
+	}
+	migrator := migrations.NewMigrator(db, files)
+	migrator.DryRun = *dryRun
+	migrator.AllowDrift = *allowDrift || cfg.MigrationsAllowDrift
+
+	switch command {
+	case "status":
+		err = printStatus(migrator)
+	case "up":
+		var applied int
+		applied, err = migrator.Up(countArg(args, 0))
+		report("Applied", applied, *dryRun)
+	case "down":
+		var reverted int
+		reverted, err = migrator.Down(countArg(args, 1))
+		report("Reverted", reverted, *dryRun)
+	case "redo":
+		err = migrator.Redo()
+	case "force":
+		if len(args) != 1 {
+			log.Fatal("force requires a version")
+		}
+		err = migrator.Force(args[0])
+		if err == nil && !*dryRun {
+			fmt.Printf("Forced version %s\n", args[0])
+		}
+	case "accept":
+		if len(args) != 1 {
+			log.Fatal("accept requires a version")
+		}
+		err = migrator.Accept(args[0])
+		if err == nil && !*dryRun {
+			fmt.Printf("Accepted checksum of %s\n", args[0])
+		}
+	default:
+		usage()
+		os.Exit(2)
+	}
+
+	if err != nil {
+		log.Fatalf("Migration %s failed: %v", command, err)
+	}
+}
+
+// countArg разбирает необязательный аргумент N.
+func countArg(args []string, defaultValue int) int {
+	if len(args) == 0 {
+		return defaultValue
+	}
+	n, err := strconv.Atoi(args[0])
+	if err != nil || n < 1 {
+		log.Fatalf("invalid count %q", args[0])
+	}
+	return n
+}
+
+func report(action string, count int, dryRun bool) {
+	if dryRun {
+		fmt.Printf("-- dry run: %d migration(s) would be affected\n", count)
+		return
+	}
+	fmt.Printf("%s %d migration(s)\n", action, count)
+}
+
+func printStatus(migrator *migrations.Migrator) error {
+	statuses, err := migrator.Status()
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "VERSION\tSTATUS\tAPPLIED AT\tDOWN\tCHECKSUM\tNAME")
+	pending, drifted := 0, 0
+	for _, status := range statuses {
+		state, appliedAt := "pending", "-"
+		switch {
+		case status.Missing:
+			state = "missing file"
+		case status.Applied:
+			state = "applied"
+		default:
+			pending++
+		}
+		if status.Applied && !status.AppliedAt.IsZero() {
+			appliedAt = status.AppliedAt.Format("2006-01-02 15:04:05")
+		}
+		down := "no"
+		if status.DownName != "" {
+			down = "yes"
+		}
+		sum := "-"
+		switch {
+		case status.Drift:
+			sum = "CHANGED"
+			drifted++
+		case status.Applied && !status.Missing && status.AppliedChecksum == "":
+			sum = "not recorded"
+		case status.Applied && !status.Missing:
+			sum = "ok"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", status.Version, state, appliedAt, down, sum, status.Name)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	fmt.Printf("\n%d migration(s), %d pending\n", len(statuses), pending)
+	if drifted > 0 {
+		fmt.Printf("%d applied migration(s) changed on disk: restore the files or run `migrate accept <version>`\n", drifted)
+	}
+	return nil
+}
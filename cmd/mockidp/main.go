@@ -0,0 +1,34 @@
+// cmd/mockidp/main.go
+package main
+
+import (
+	"flag"
+	"log"
+	"net/http"
+
+	"vend_erp/internal/oidc/mockidp"
+)
+
+// Локальный OIDC провайдер для разработки. Пример настройки сервера:
+//
+//	OIDC_ISSUER=http://localhost:9999
+//	OIDC_CLIENT_ID=venderp
+//	OIDC_ROLE_MAPPING=vend-admins=admin,vend-ops=operator
+func main() {
+	addr := flag.String("addr", "localhost:9999", "адрес для прослушивания")
+	issuer := flag.String("issuer", "http://localhost:9999", "внешний адрес провайдера (claim iss)")
+	clientID := flag.String("client-id", "venderp", "ожидаемый client_id")
+	flag.Parse()
+
+	idp, err := mockidp.New(*issuer, *clientID,
+		mockidp.User{Subject: "mock-admin", Email: "sso.admin@testsystem.ru", Name: "SSO Администратор", Username: "sso.admin", Groups: []string{"vend-admins"}},
+		mockidp.User{Subject: "mock-operator", Email: "sso.operator@testsystem.ru", Name: "SSO Оператор", Username: "sso.operator", Groups: []string{"vend-ops"}},
+		mockidp.User{Subject: "mock-guest", Email: "sso.guest@testsystem.ru", Name: "SSO Гость", Username: "sso.guest"},
+	)
+	if err != nil {
+		log.Fatalf("❌ Не удалось создать mock IdP: %v", err)
+	}
+
+	log.Printf("🔐 Mock IdP запущен на http://%s (issuer %s)", *addr, *issuer)
+	log.Fatal(http.ListenAndServe(*addr, idp))
+}
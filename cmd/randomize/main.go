@@ -199,6 +199,7 @@ func main() {
 		{"public.vending_machines", true},
 		{"public.vending_operations", true},
 		{"public.warehouse", true},
+		{"public.products", true},
 		{"public.warehouse_inventory", true},
 		{"public.warehouse_shipments", true},
 		{"public.warehouse_supplies", true},
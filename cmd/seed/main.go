@@ -0,0 +1,163 @@
+// cmd/seed/main.go
+// Загрузка тестовых и демонстрационных данных:
+//
+//	go run ./cmd/seed -list
+//	go run ./cmd/seed [-org slug] <profile>
+//	go run ./cmd/seed admin <username> <email>
+//	go run ./cmd/seed superadmin grant|revoke <username>
+//
+// Команда admin создает первого суперадминистратора в пустой базе
+// и печатает временный пароль, который нужно сменить при входе.
+// Команда superadmin выдает или снимает кросс-организационный доступ
+// существующему пользователю: других способов стать суперадмином нет.
+package main
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/base64"
+	"flag"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+
+	"vend_erp/config"
+	"vend_erp/internal/credentials"
+	"vend_erp/seeds"
+)
+
+func usage() {
+	fmt.Fprintf(os.Stderr, `Usage: seed [flags] <profile>
+       seed [flags] admin <username> <email>
+       seed superadmin grant|revoke <username>
+
+Profiles:
+`)
+	for _, profile := range seeds.Profiles() {
+		fmt.Fprintf(os.Stderr, "  %-8s %s\n", profile.Name, profile.Description)
+	}
+	fmt.Fprintf(os.Stderr, "\nFlags:\n")
+	flag.PrintDefaults()
+}
+
+func main() {
+	path := flag.String("path", "", "read seed files from this directory instead of the binary")
+	org := flag.String("org", "default", "slug of the organization to seed")
+	list := flag.Bool("list", false, "list profiles and exit")
+	flag.Usage = usage
+	flag.Parse()
+
+	if *list {
+		for _, profile := range seeds.Profiles() {
+			fmt.Printf("%-8s %s\n", profile.Name, profile.Description)
+		}
+		return
+	}
+	if flag.NArg() == 0 {
+		usage()
+		os.Exit(2)
+	}
+
+	cfg := config.LoadConfig()
+	db, err := config.ConnectDB(cfg)
+	if err != nil {
+		log.Fatalf("Failed to connect to database: %v", err)
+	}
+	defer db.Close()
+
+	if flag.Arg(0) == "admin" {
+		if flag.NArg() != 3 {
+			log.Fatal("admin requires a username and an email")
+		}
+		password, err := createAdmin(db, credentials.NewService(db, cfg.Password), *org, flag.Arg(1), flag.Arg(2))
+		if err != nil {
+			log.Fatalf("Failed to create admin: %v", err)
+		}
+		fmt.Printf("Created superadmin %s\nTemporary password: %s\n", flag.Arg(1), password)
+		return
+	}
+
+	if flag.Arg(0) == "superadmin" {
+		if flag.NArg() != 3 || flag.Arg(1) != "grant" && flag.Arg(1) != "revoke" {
+			log.Fatal("superadmin requires grant or revoke and a username")
+		}
+		grant := flag.Arg(1) == "grant"
+		if err := setSuperadmin(db, flag.Arg(2), grant); err != nil {
+			log.Fatalf("Failed to update superadmin: %v", err)
+		}
+		if grant {
+			fmt.Printf("%s is now a superadmin\n", flag.Arg(2))
+		} else {
+			fmt.Printf("%s is no longer a superadmin\n", flag.Arg(2))
+		}
+		return
+	}
+
+	if _, ok := seeds.Lookup(flag.Arg(0)); !ok {
+		usage()
+		os.Exit(2)
+	}
+	var files fs.FS = seeds.FS
+	if *path != "" {
+		files = os.DirFS(*path)
+	}
+	count, err := seeds.NewSeeder(db, files).Run(flag.Arg(0), *org)
+	if err != nil {
+		log.Fatalf("Seeding failed: %v", err)
+	}
+	fmt.Printf("Profile %s: %d step(s) run\n", flag.Arg(0), count)
+}
+
+// createAdmin создает суперадминистратора с временным паролем
+// и обязательной сменой пароля при первом входе.
+func createAdmin(db *sql.DB, creds *credentials.Service, orgSlug, username, email string) (string, error) {
+	buf := make([]byte, 12)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	password := base64.RawURLEncoding.EncodeToString(buf)
+
+	hash, err := creds.Hash(password)
+	if err != nil {
+		return "", err
+	}
+
+	var userID int64
+	err = db.QueryRow(`
+		INSERT INTO users (org_id, username, email, userrole, status, password, fullusername,
+		                   is_superadmin, must_change_password)
+		SELECT id, $2, $3, 'admin', 1, $4, $2, true, true FROM organizations WHERE slug = $1
+		RETURNING id
+	`, orgSlug, username, email, hash).Scan(&userID)
+	if err == sql.ErrNoRows {
+		return "", fmt.Errorf("organization %q not found", orgSlug)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	_, err = db.Exec(`
+		INSERT INTO user_organizations (user_id, org_id)
+		SELECT id, org_id FROM users WHERE id = $1
+		ON CONFLICT DO NOTHING
+	`, userID)
+	return password, err
+}
+
+// setSuperadmin выдает (grant) или снимает кросс-организационный доступ
+// пользователю username.
+func setSuperadmin(db *sql.DB, username string, grant bool) error {
+	res, err := db.Exec(`UPDATE users SET is_superadmin = $2 WHERE username = $1`, username, grant)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return fmt.Errorf("user %q not found", username)
+	}
+	return nil
+}
@@ -1,43 +1,170 @@
 package main
 
 import (
-    "log"
+    "context"
+    "errors"
+    "fmt"
+    "log/slog"
     "net/http"
-    
+    "os"
+    "os/signal"
+    "syscall"
+    "time"
+
     "vend_erp/config"
+    "vend_erp/internal/credentials"
+    "vend_erp/internal/logging"
+    "vend_erp/internal/replenishment"
+    "vend_erp/internal/repository/postgres"
+    "vend_erp/internal/tracing"
     "vend_erp/migrations"
+    "vend_erp/seeds"
     // Remove the duplicate import below
     // _ "github.com/jackc/pgx/v4/stdlib"
 )
 
 func main() {
     // Load configuration
-    cfg := config.LoadConfig()
-    
+    cfg, err := config.Load(os.Args[1:])
+    if errors.Is(err, config.ErrPrintConfig) {
+        return
+    }
+    if err != nil {
+        fmt.Fprintln(os.Stderr, err)
+        os.Exit(2)
+    }
+    logger := logging.Setup(os.Stderr, cfg.LogLevel)
+
+    // Tracing must be set up before the DB pool so that SQL spans are exported
+    shutdownTracing, err := tracing.Setup(context.Background(), cfg.Tracing)
+    if err != nil {
+        fatal("failed to set up tracing", "err", err)
+    }
+
     // Connect to database
     db, err := config.ConnectDB(cfg)
     if err != nil {
-        log.Fatalf("Failed to connect to database: %v", err)
+        fatal("failed to connect to database", "err", err)
     }
-    defer db.Close()
 
-    log.Printf("Database connected successfully!")
+    // Run migrations embedded into the binary (or from ASSETS_DIR in dev)
+    migrator := migrations.NewMigrator(db, cfg.Files(migrations.FS, "migrations"))
+    migrator.AllowDrift = cfg.MigrationsAllowDrift
+    if err := migrator.Run(); err != nil {
+        fatal("failed to run migrations", "err", err)
+    }
 
-    // Run migrations from migrations folder
-    migrationsPath := "./migrations"
-    if err := migrations.RunMigrations(db, migrationsPath); err != nil {
-        log.Fatalf("Failed to run migrations: %v", err)
+    // Load seed data only when a profile is requested explicitly
+    if cfg.SeedProfile != "" {
+        seeded, err := seeds.NewSeeder(db, cfg.Files(seeds.FS, "seeds")).Run(cfg.SeedProfile, cfg.SeedOrg)
+        if err != nil {
+            fatal("failed to seed", "profile", cfg.SeedProfile, "err", err)
+        }
+        slog.Info("seed profile loaded", "profile", cfg.SeedProfile, "steps", seeded)
     }
 
+    // Hash any passwords still stored in plain text
+    migrated, err := credentials.NewService(db, cfg.Password).MigratePlaintext()
+    if err != nil {
+        slog.Warn("plain-text password migration stopped", "err", err)
+    } else if migrated > 0 {
+        slog.Info("hashed plain-text passwords", "count", migrated)
+    }
+
+    // Расчет пополнения общий для расписания и ручного запуска со страницы поставок
+    replenisher := replenishment.New(postgres.New(db).Supplies, cfg.Replenishment)
+
     // Setup routes using handlers package
-    router := setupRoutes(db)
+    router := setupRoutes(db, cfg, replenisher)
+
+    server := &http.Server{
+        Addr:         cfg.Server.Addr,
+        Handler:      router,
+        ReadTimeout:  cfg.Server.ReadTimeout,
+        WriteTimeout: cfg.Server.WriteTimeout,
+        IdleTimeout:  cfg.Server.IdleTimeout,
+        ErrorLog:     slog.NewLogLogger(logger.Handler(), slog.LevelWarn),
+    }
 
     // Start server
-    port := ":8080"
-    log.Printf("🚀 Vend ERP Server starting on http://localhost%s", port)
-    log.Printf("📊 Database: %s@%s:%d/%s", cfg.DBUser, cfg.DBHost, cfg.DBPort, cfg.DBName)
-    log.Printf("🗃️  Migrations applied from: %s", migrationsPath)
-    if err := http.ListenAndServe(port, router); err != nil {
-        log.Fatalf("Server failed to start: %v", err)
+    scheme := "http"
+    if cfg.Server.TLSEnabled() {
+        scheme = "https"
+    }
+    slog.Info("Vend ERP server starting",
+        "url", scheme+"://"+cfg.Server.Addr,
+        "database", fmt.Sprintf("%s@%s:%d/%s", cfg.DBUser, cfg.DBHost, cfg.DBPort, cfg.DBName),
+        "signup_mode", cfg.Signup.Mode,
+        "log_level", cfg.LogLevel)
+    if cfg.AssetsDir != "" {
+        slog.Info("assets read from disk with live reload", "dir", cfg.AssetsDir)
+    }
+    if cfg.Tracing.Exporter != config.TraceNone {
+        slog.Info("tracing enabled", "exporter", cfg.Tracing.Exporter, "sample_ratio", cfg.Tracing.SampleRatio)
+    }
+    if cfg.OIDC.Enabled() {
+        slog.Info("SSO enabled", "provider", cfg.OIDC.ProviderName, "issuer", cfg.OIDC.Issuer)
+    }
+
+    // SIGINT/SIGTERM останавливают прием соединений; текущие запросы
+    // дорабатывают не дольше ShutdownTimeout, затем закрывается пул БД
+    ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+    defer stop()
+
+    // Расчет пополнения останавливается до закрытия пула БД: scheduled
+    // закрывается, когда Schedule вернулся
+    scheduleCtx, stopSchedule := context.WithCancel(ctx)
+    defer stopSchedule()
+    scheduled := make(chan struct{})
+    if cfg.Replenishment.Interval > 0 {
+        slog.Info("replenishment scheduled", "interval", cfg.Replenishment.Interval,
+            "window", cfg.Replenishment.Window, "lead_time", cfg.Replenishment.LeadTime)
+        go func() {
+            defer close(scheduled)
+            replenisher.Schedule(scheduleCtx)
+        }()
+    } else {
+        close(scheduled)
     }
+
+    serveErr := make(chan error, 1)
+    go func() {
+        if cfg.Server.TLSEnabled() {
+            serveErr <- server.ListenAndServeTLS(cfg.Server.TLSCert, cfg.Server.TLSKey)
+        } else {
+            serveErr <- server.ListenAndServe()
+        }
+    }()
+
+    select {
+    case err := <-serveErr:
+        if !errors.Is(err, http.ErrServerClosed) {
+            fatal("server failed to start", "err", err)
+        }
+    case <-ctx.Done():
+        stop()
+        slog.Info("shutting down, draining in-flight requests", "timeout", cfg.Server.ShutdownTimeout)
+        shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
+        defer cancel()
+        if err := server.Shutdown(shutdownCtx); err != nil {
+            slog.Warn("graceful shutdown incomplete", "err", err)
+        }
+    }
+
+    stopSchedule()
+    <-scheduled
+    if err := db.Close(); err != nil {
+        slog.Warn("closing database pool", "err", err)
+    }
+    flushCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+    defer cancel()
+    if err := shutdownTracing(flushCtx); err != nil {
+        slog.Warn("flushing traces", "err", err)
+    }
+    slog.Info("server stopped")
+}
+
+func fatal(msg string, args ...interface{}) {
+    slog.Error(msg, args...)
+    os.Exit(1)
 }
\ No newline at end of file
@@ -4,64 +4,99 @@ import (
 	"database/sql"
 	"net/http"
 
+	"vend_erp/config"
+	"vend_erp/internal/assets"
+	"vend_erp/internal/credentials"
 	"vend_erp/internal/handlers"
+	"vend_erp/internal/logging"
+	"vend_erp/internal/metrics"
+	"vend_erp/internal/oidc"
+	"vend_erp/internal/replenishment"
+	"vend_erp/internal/repository/postgres"
+	"vend_erp/internal/tracing"
+	"vend_erp/migrations"
+	"vend_erp/static"
+	"vend_erp/templates"
 )
 
-func setupRoutes(db *sql.DB) http.Handler {
+func setupRoutes(db *sql.DB, cfg *config.Config, replenisher *replenishment.Engine) http.Handler {
 	mux := http.NewServeMux()
-	renderer := handlers.NewTemplateRenderer()
+	live := cfg.AssetsDir != ""
+	staticFiles := assets.New(cfg.Files(static.FS, "static"), live)
+	renderer := handlers.NewTemplateRenderer(cfg.Files(templates.FS, "templates"), staticFiles, live)
+
+	// Хранилища
+	repos := postgres.New(db)
 
 	// Handlers
-	auth := handlers.NewAuthHandler(db, renderer)
-	users := handlers.NewUserHandler(db, renderer)
-	machines := handlers.NewMachineHandler(db, renderer)
-	locations := handlers.NewLocationHandler(db, renderer)
-	operations := handlers.NewOperationHandler(db, renderer)
+	creds := credentials.NewService(db, cfg.Password)
+	auth := handlers.NewAuthHandler(db, repos.Users, repos.Sessions, repos.Invites, renderer, creds)
+	auth.SetSignupMode(cfg.Signup.Mode)
+	auth.SetSessionLifetime(cfg.SessionLifetime)
+	if cfg.OIDC.Enabled() {
+		sso := handlers.NewOIDCHandler(repos.Users, repos.Organizations, auth, oidc.NewClient(cfg.OIDC))
+		auth.EnableSSO(cfg.OIDC.ProviderName)
+		mux.HandleFunc("/auth/oidc/login", sso.Login)
+		mux.HandleFunc("/auth/oidc/callback", sso.Callback)
+	}
+	users := handlers.NewUserHandler(repos.Users, repos.Organizations, renderer, creds)
+	invites := handlers.NewInviteHandler(repos.Invites, repos.Users, renderer, cfg.Signup.InviteTTL)
+	machines := handlers.NewMachineHandler(repos.Machines, repos.Locations, repos.Products, renderer)
+	locations := handlers.NewLocationHandler(repos.Locations, repos.Operations, renderer)
+	operations := handlers.NewOperationHandler(repos.Operations, repos.Machines, repos.Users, repos.Inventory, renderer)
 
 	// Chart handler - создаем первым
-	chartHandler := handlers.NewChartHandler(db)
+	chartHandler := handlers.NewChartHandler(repos.Reports)
 
 	// Dashboard handler - передаем chartHandler
-	dashboard := handlers.NewDashboardHandler(db, renderer, chartHandler)
-
-	warehouses := handlers.NewWarehouseHandler(db, renderer)
-
-	// Auth middleware closure
-	requireAuth := func(next http.HandlerFunc) http.HandlerFunc {
-		return func(w http.ResponseWriter, r *http.Request) {
-			user, err := auth.GetUserFromSession(r)
-			if err != nil || user == nil {
-				http.Redirect(w, r, "/auth/signin", http.StatusSeeOther)
-				return
-			}
-			next(w, r)
-		}
-	}
+	dashboard := handlers.NewDashboardHandler(repos.Reports, renderer, chartHandler)
+
+	warehouses := handlers.NewWarehouseHandler(repos.Inventory, repos.Machines, repos.Products, renderer)
+	products := handlers.NewProductHandler(repos.Products, repos.Inventory, renderer)
+	supplies := handlers.NewSupplyHandler(repos.Supplies, replenisher, renderer)
+	stocktakes := handlers.NewStocktakeHandler(repos.Stocktakes, repos.Inventory, renderer)
+	organizations := handlers.NewOrganizationHandler(repos.Organizations, repos.Sessions, renderer)
+	labels := handlers.NewLabelHandler(repos.Products, repos.Machines, repos.Locations, cfg.Labels, renderer)
+
+	// Auth middleware
+	requireAuth := auth.RequireAuth
 
 	// Routes
 	mux.HandleFunc("/auth/signin", auth.SignIn)
 	mux.HandleFunc("/auth/signup", auth.SignUp)
 	mux.HandleFunc("/auth/signout", auth.SignOut)
+	mux.HandleFunc("/auth/change-password", requireAuth(auth.ChangePassword))
+	mux.HandleFunc("/auth/password-strength", auth.PasswordStrength)
 	mux.HandleFunc("/dashboard", requireAuth(dashboard.ShowDashboard))
 
 	mux.HandleFunc("/accounts", requireAuth(users.ListUsers))
 	mux.HandleFunc("/accounts/form", requireAuth(users.GetUserForm))
 	mux.HandleFunc("/accounts/save", requireAuth(users.SaveUser))
 	mux.HandleFunc("/accounts/delete", requireAuth(users.DeleteUser))
+	mux.HandleFunc("/accounts/approve", requireAuth(users.ApproveUser))
+	mux.HandleFunc("/accounts/reject", requireAuth(users.RejectUser))
+	mux.HandleFunc("/accounts/invites", requireAuth(invites.ListInvites))
+	mux.HandleFunc("/accounts/invite-form", requireAuth(invites.GetInviteForm))
+	mux.HandleFunc("/accounts/invites/create", requireAuth(invites.CreateInvite))
+	mux.HandleFunc("/accounts/invites/revoke", requireAuth(invites.RevokeInvite))
 
 	mux.HandleFunc("/machines", requireAuth(machines.ListMachines))
 	mux.HandleFunc("/machines/form", requireAuth(machines.GetMachineForm))
 	mux.HandleFunc("/machines/save", requireAuth(machines.SaveMachine))
 	mux.HandleFunc("/machines/delete", requireAuth(machines.DeleteMachine))
+	mux.HandleFunc("/machines/planogram", requireAuth(machines.GetPlanogram))
+	mux.HandleFunc("/machines/planogram-save", requireAuth(machines.SavePlanogram))
 
 	mux.HandleFunc("/locations", requireAuth(locations.ListLocations))
 	mux.HandleFunc("/locations/form", requireAuth(locations.GetLocationForm))
 	mux.HandleFunc("/locations/save", requireAuth(locations.SaveLocation))
 	mux.HandleFunc("/locations/delete", requireAuth(locations.DeleteLocation))
+	mux.HandleFunc("/locations/sales", requireAuth(locations.Sales))
 
 	mux.HandleFunc("/operations", requireAuth(operations.ListOperations))
 	mux.HandleFunc("/operations/form", requireAuth(operations.GetOperationForm))
 	mux.HandleFunc("/operations/save", requireAuth(operations.SaveOperation))
+	mux.HandleFunc("/operations/restock-items", requireAuth(operations.RestockItems))
 	mux.HandleFunc("/operations/delete", requireAuth(operations.DeleteOperation))
 
 	mux.HandleFunc("/warehouses", requireAuth(warehouses.ListWarehouses))
@@ -73,17 +108,57 @@ func setupRoutes(db *sql.DB) http.Handler {
 	mux.HandleFunc("/warehouses/inventory-delete", requireAuth(warehouses.DeleteInventory))
 	mux.HandleFunc("/warehouses/quick-action", requireAuth(warehouses.GetQuickActionForm))
 	mux.HandleFunc("/warehouses/quick-action-execute", requireAuth(warehouses.ExecuteQuickAction))
+	mux.HandleFunc("/warehouses/reservation-release", requireAuth(warehouses.ReleaseReservation))
+	mux.HandleFunc("/warehouses/inventory-history", requireAuth(warehouses.InventoryHistory))
+	mux.HandleFunc("/warehouses/valuation", requireAuth(warehouses.Valuation))
+	mux.HandleFunc("/warehouses/utilization", requireAuth(warehouses.Utilization))
+	mux.HandleFunc("/warehouses/place", requireAuth(warehouses.PlaceItem))
+	mux.HandleFunc("/warehouses/zone-form", requireAuth(warehouses.GetZoneForm))
+	mux.HandleFunc("/warehouses/zone-save", requireAuth(warehouses.SaveZone))
+	mux.HandleFunc("/warehouses/zone-delete", requireAuth(warehouses.DeleteZone))
+
+	mux.HandleFunc("/products", requireAuth(products.ListProducts))
+	mux.HandleFunc("/products/form", requireAuth(products.GetProductForm))
+	mux.HandleFunc("/products/save", requireAuth(products.SaveProduct))
+	mux.HandleFunc("/products/delete", requireAuth(products.DeleteProduct))
+
+	mux.HandleFunc("/supplies", requireAuth(supplies.ListSupplies))
+	mux.HandleFunc("/supplies/replenish", requireAuth(supplies.Replenish))
+	mux.HandleFunc("/supplies/approve", requireAuth(supplies.ApproveSupply))
+	mux.HandleFunc("/supplies/reject", requireAuth(supplies.RejectSupply))
+	mux.HandleFunc("/supplies/receive-form", requireAuth(supplies.GetReceiveForm))
+	mux.HandleFunc("/supplies/receive", requireAuth(supplies.ReceiveSupply))
+
+	mux.HandleFunc("/stocktakes", requireAuth(stocktakes.ListStocktakes))
+	mux.HandleFunc("/stocktakes/form", requireAuth(stocktakes.GetStocktakeForm))
+	mux.HandleFunc("/stocktakes/start", requireAuth(stocktakes.StartStocktake))
+	mux.HandleFunc("/stocktakes/count", requireAuth(stocktakes.CountSheet))
+	mux.HandleFunc("/stocktakes/scan", requireAuth(stocktakes.Scan))
+	mux.HandleFunc("/stocktakes/variance", requireAuth(stocktakes.Variance))
+	mux.HandleFunc("/stocktakes/approve", requireAuth(stocktakes.ApproveStocktake))
+	mux.HandleFunc("/stocktakes/cancel", requireAuth(stocktakes.CancelStocktake))
+
+	mux.HandleFunc("/labels/form", requireAuth(labels.GetLabelForm))
+	mux.HandleFunc("/labels/print", requireAuth(labels.PrintLabels))
+	mux.HandleFunc("/scan/machine", requireAuth(labels.ScanMachine))
+	mux.HandleFunc("/scan/location", requireAuth(labels.ScanLocation))
+
+	mux.HandleFunc("/organizations", requireAuth(organizations.ListOrganizations))
+	mux.HandleFunc("/organizations/form", requireAuth(organizations.GetOrganizationForm))
+	mux.HandleFunc("/organizations/save", requireAuth(organizations.SaveOrganization))
+	mux.HandleFunc("/orgs/switcher", requireAuth(organizations.Switcher))
+	mux.HandleFunc("/orgs/switch", requireAuth(organizations.Switch))
 
 	// API routes for charts
 	mux.HandleFunc("/api/charts/machines", requireAuth(chartHandler.HandleMachinesChart))
-	mux.HandleFunc("/api/charts/machines/active", chartHandler.HandleActiveMachinesChart)
+	mux.HandleFunc("/api/charts/machines/active", requireAuth(chartHandler.HandleActiveMachinesChart))
 	mux.HandleFunc("/api/charts/operations", requireAuth(chartHandler.HandleOperationsChart))
-	mux.HandleFunc("/api/charts/cash", chartHandler.HandleCashChart)
+	mux.HandleFunc("/api/charts/cash", requireAuth(chartHandler.HandleCashChart))
 	mux.HandleFunc("/api/charts/revenue", requireAuth(chartHandler.HandleRevenueChart))
 	mux.HandleFunc("/api/charts/inventory", requireAuth(chartHandler.HandleInventoryChart))
-	mux.HandleFunc("/api/charts/toys", chartHandler.HandleToysChart)
+	mux.HandleFunc("/api/charts/toys", requireAuth(chartHandler.HandleToysChart))
 	// Static files
-	mux.Handle("/static/", http.StripPrefix("/static/", http.FileServer(http.Dir("static"))))
+	mux.Handle(assets.Prefix, staticFiles.Handler())
 
 	// Root
 	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
@@ -94,5 +169,15 @@ func setupRoutes(db *sql.DB) http.Handler {
 		http.Redirect(w, r, "/auth/signin", http.StatusSeeOther)
 	})
 
-	return mux
+	// Проверки и метрики не проходят через журнал доступа и счетчики
+	// запросов: их опрашивают каждые несколько секунд
+	stats := metrics.New(db, cfg.DBName)
+	health := handlers.NewHealthHandler(db, migrations.NewMigrator(db, cfg.Files(migrations.FS, "migrations")))
+
+	root := http.NewServeMux()
+	root.HandleFunc("/healthz", health.Live)
+	root.HandleFunc("/readyz", health.Ready)
+	root.Handle("/metrics", stats.Handler(cfg.Server.MetricsToken))
+	root.Handle("/", tracing.Middleware(logging.Middleware(stats.Middleware(tracing.Route(mux)))))
+	return root
 }
@@ -3,41 +3,402 @@ package config
 import (
     "database/sql"
     "fmt"
+    "io/fs"
     "log"
     "os"
+    "path/filepath"
     "strconv"
+    "strings"
+    "time"
 
-    "github.com/joho/godotenv"
-    _ "github.com/jackc/pgx/v4/stdlib" 
+    "github.com/XSAM/otelsql"
+    _ "github.com/jackc/pgx/v4/stdlib"
+    "go.opentelemetry.io/otel/attribute"
 )
 
+// Config собирается по слоям: значения по умолчанию, YAML-файл
+// (-config или CONFIG_FILE), переменные окружения, флаги командной строки.
+// Каждый следующий слой переопределяет предыдущий; см. Load.
 type Config struct {
-    DBHost     string
-    DBPort     int
-    DBUser     string
-    DBPassword string
-    DBName     string
-    SSLMode    string
+    DBHost     string `yaml:"db_host"`
+    DBPort     int    `yaml:"db_port"`
+    DBUser     string `yaml:"db_user"`
+    DBPassword string `yaml:"db_password"`
+    DBName     string `yaml:"db_name"`
+    SSLMode    string `yaml:"ssl_mode"`
+
+    // Пул соединений с базой
+    DBMaxOpenConns    int           `yaml:"db_max_open_conns"`
+    DBMaxIdleConns    int           `yaml:"db_max_idle_conns"`
+    DBConnMaxLifetime time.Duration `yaml:"db_conn_max_lifetime"`
+
+    Server ServerConfig `yaml:"server"`
+
+    // SessionLifetime — срок жизни сессии после входа
+    SessionLifetime time.Duration `yaml:"session_lifetime"`
+    // LogLevel — debug, info, warn или error
+    LogLevel string `yaml:"log_level"`
+
+    Tracing TracingConfig `yaml:"tracing"`
+
+    // MigrationsAllowDrift разрешает запуск, если файлы уже примененных
+    // миграций изменились
+    MigrationsAllowDrift bool `yaml:"migrations_allow_drift"`
+
+    // AssetsDir — корень репозитория, из которого в режиме разработки
+    // читаются templates/, static/, migrations/ и seeds/ вместо встроенных
+    // в бинарник копий; шаблоны при этом перечитываются на каждый запрос
+    AssetsDir string `yaml:"assets_dir"`
+
+    // SeedProfile — профиль сидов, загружаемый при запуске (пусто — не загружать)
+    SeedProfile string `yaml:"seed_profile"`
+    SeedOrg     string `yaml:"seed_org"`
+
+    OIDC     OIDCConfig     `yaml:"oidc"`
+    Signup   SignupConfig   `yaml:"signup"`
+    Password PasswordConfig `yaml:"password"`
+
+    Replenishment ReplenishmentConfig `yaml:"replenishment"`
+    Labels        LabelsConfig        `yaml:"labels"`
 }
 
-func LoadConfig() *Config {
-    // Load .env file
-    err := godotenv.Load()
-    if err != nil {
-        log.Printf("Warning: Error loading .env file: %v", err)
-        // Continue with environment variables or defaults
+// ServerConfig — параметры HTTP-сервера. TLS включается, если заданы
+// и сертификат, и ключ.
+type ServerConfig struct {
+    Addr    string `yaml:"addr"`
+    TLSCert string `yaml:"tls_cert"`
+    TLSKey  string `yaml:"tls_key"`
+
+    ReadTimeout  time.Duration `yaml:"read_timeout"`
+    WriteTimeout time.Duration `yaml:"write_timeout"`
+    IdleTimeout  time.Duration `yaml:"idle_timeout"`
+    // ShutdownTimeout — сколько ждать завершения текущих запросов при остановке
+    ShutdownTimeout time.Duration `yaml:"shutdown_timeout"`
+    // MetricsToken — токен Bearer для /metrics. Без него /metrics открыт,
+    // но не содержит показателей по организациям
+    MetricsToken string `yaml:"metrics_token"`
+}
+
+// TLSEnabled сообщает, нужно ли слушать HTTPS.
+func (c ServerConfig) TLSEnabled() bool {
+    return c.TLSCert != "" && c.TLSKey != ""
+}
+
+// Экспортеры трассировок
+const (
+    TraceNone   = "none"
+    TraceStdout = "stdout"
+    TraceFile   = "file"
+    TraceOTLP   = "otlp"
+)
+
+// TracingConfig настраивает OpenTelemetry. По умолчанию трассировка
+// выключена; stdout и file работают без сети, otlp отправляет спаны
+// коллектору по OTLP/HTTP.
+type TracingConfig struct {
+    Exporter    string `yaml:"exporter"`
+    ServiceName string `yaml:"service_name"`
+    // Endpoint — адрес коллектора: host:port или полный URL
+    Endpoint string `yaml:"endpoint"`
+    Insecure bool   `yaml:"insecure"`
+    // File — файл для экспортера file, спаны пишутся по одному JSON на строку
+    File string `yaml:"file"`
+    // SampleRatio — доля трассируемых запросов от 0 до 1
+    SampleRatio float64 `yaml:"sample_ratio"`
+}
+
+// PasswordConfig — политика паролей для регистрации, смены пароля
+// и учетных записей, создаваемых администратором.
+type PasswordConfig struct {
+    MinLength     int  `yaml:"min_length"`
+    RequireUpper  bool `yaml:"require_upper"`
+    RequireLower  bool `yaml:"require_lower"`
+    RequireDigit  bool `yaml:"require_digit"`
+    RequireSymbol bool `yaml:"require_symbol"`
+    // MinScore — минимальная оценка надежности от 0 (очень слабый) до 4 (надежный)
+    MinScore int `yaml:"min_score"`
+}
+
+// ReplenishmentConfig настраивает расчет пополнения складов, который
+// создает черновики заказов поставщикам. Расчет выполняется под
+// pg_try_advisory_lock, поэтому расписание можно включать на всех
+// экземплярах сервера: одновременный запуск пропускается.
+type ReplenishmentConfig struct {
+    // Interval — как часто пересчитывать пополнение; 0 выключает расчет
+    // по расписанию, запустить его можно вручную со страницы поставок
+    Interval time.Duration `yaml:"interval"`
+    // Window — за какой период оценивать расход по пополнениям автоматов
+    Window time.Duration `yaml:"window"`
+    // LeadTime — сколько обычно идет поставка от заказа до склада
+    LeadTime time.Duration `yaml:"lead_time"`
+}
+
+// Куда ведет QR-код автомата LabelsConfig.MachineTarget
+const (
+    // ScanOperation — форма операции с выбранным автоматом
+    ScanOperation = "operation"
+    // ScanMachine — карточка автомата
+    ScanMachine = "machine"
+)
+
+// LabelsConfig настраивает печать этикеток со штрихкодами и QR-кодами.
+type LabelsConfig struct {
+    // BaseURL — внешний адрес сервера для ссылок в QR-кодах, например
+    // https://erp.example.com; пусто — адрес, с которого открыта печать
+    BaseURL string `yaml:"base_url"`
+    // MachineTarget — что открывает QR-код автомата: operation или machine
+    MachineTarget string `yaml:"machine_target"`
+    // Templates — форматы листов этикеток; первый предлагается по умолчанию
+    Templates []LabelTemplate `yaml:"templates"`
+}
+
+// LabelTemplate — раскладка одинаковых этикеток на листе. Размеры
+// в миллиметрах, отступы считаются от левого верхнего угла листа.
+type LabelTemplate struct {
+    Name        string  `yaml:"name"`
+    Title       string  `yaml:"title"`
+    PageWidth   float64 `yaml:"page_width"`
+    PageHeight  float64 `yaml:"page_height"`
+    Columns     int     `yaml:"columns"`
+    Rows        int     `yaml:"rows"`
+    LabelWidth  float64 `yaml:"label_width"`
+    LabelHeight float64 `yaml:"label_height"`
+    MarginLeft  float64 `yaml:"margin_left"`
+    MarginTop   float64 `yaml:"margin_top"`
+    // GapX и GapY — промежутки между соседними этикетками
+    GapX float64 `yaml:"gap_x"`
+    GapY float64 `yaml:"gap_y"`
+    // FontSize — размер шрифта подписей в пунктах
+    FontSize float64 `yaml:"font_size"`
+}
+
+// PerSheet — сколько этикеток помещается на лист.
+func (t LabelTemplate) PerSheet() int {
+    return t.Columns * t.Rows
+}
+
+// LabelTemplate возвращает формат по имени.
+func (c LabelsConfig) LabelTemplate(name string) (LabelTemplate, bool) {
+    for _, t := range c.Templates {
+        if t.Name == name {
+            return t, true
+        }
+    }
+    return LabelTemplate{}, false
+}
+
+// Режимы самостоятельной регистрации
+const (
+    // SignupOpen — любой может зарегистрироваться и сразу получить доступ
+    SignupOpen = "open"
+    // SignupInvite — регистрация только по ссылке-приглашению
+    SignupInvite = "invite"
+    // SignupApproval — без приглашения аккаунт ждет подтверждения администратором
+    SignupApproval = "approval"
+)
+
+// SignupConfig управляет регистрацией новых пользователей.
+type SignupConfig struct {
+    Mode string `yaml:"mode"`
+    // InviteTTL — срок действия ссылки-приглашения
+    InviteTTL time.Duration `yaml:"invite_ttl"`
+}
+
+// OIDCConfig описывает подключение к внешнему провайдеру OpenID Connect.
+// Вход через OIDC включается, только если заданы Issuer и ClientID.
+type OIDCConfig struct {
+    ProviderName string   `yaml:"provider_name"`
+    Issuer       string   `yaml:"issuer"`
+    ClientID     string   `yaml:"client_id"`
+    ClientSecret string   `yaml:"client_secret"`
+    RedirectURL  string   `yaml:"redirect_url"`
+    Scopes       []string `yaml:"scopes"`
+    GroupsClaim  string   `yaml:"groups_claim"`
+    // RoleMapping сопоставляет группы IdP со значениями users.userrole.
+    // Порядок важен: побеждает первая совпавшая группа.
+    RoleMapping []RoleMapping `yaml:"role_mapping"`
+    DefaultRole string        `yaml:"default_role"`
+    // AutoProvision разрешает создавать пользователя при первом входе,
+    // иначе вход возможен только для уже связанных учетных записей.
+    AutoProvision bool `yaml:"auto_provision"`
+    // TrustEmail разрешает связывать вход с существующей учетной записью
+    // по подтвержденному email. Включайте только для IdP, который сам
+    // проверяет email: иначе владелец чужого адреса в IdP войдет в чужой аккаунт.
+    TrustEmail bool `yaml:"trust_email"`
+}
+
+type RoleMapping struct {
+    Group string `yaml:"group"`
+    Role  string `yaml:"role"`
+}
+
+// Enabled сообщает, настроен ли вход через OIDC.
+func (c OIDCConfig) Enabled() bool {
+    return c.Issuer != "" && c.ClientID != ""
+}
+
+// Defaults возвращает конфигурацию по умолчанию.
+func Defaults() *Config {
+    return &Config{
+        DBHost:     "localhost",
+        DBPort:     5432,
+        DBUser:     "postgres",
+        DBPassword: "postgres",
+        DBName:     "venderp",
+        SSLMode:    "disable",
+
+        DBMaxOpenConns:    25,
+        DBMaxIdleConns:    5,
+        DBConnMaxLifetime: 30 * time.Minute,
+
+        Server: ServerConfig{
+            Addr:            ":8080",
+            ReadTimeout:     15 * time.Second,
+            WriteTimeout:    30 * time.Second,
+            IdleTimeout:     2 * time.Minute,
+            ShutdownTimeout: 30 * time.Second,
+        },
+
+        SessionLifetime: 24 * time.Hour,
+        LogLevel:        "info",
+
+        Tracing: TracingConfig{
+            Exporter:    TraceNone,
+            ServiceName: "venderp",
+            Endpoint:    "localhost:4318",
+            File:        "traces.jsonl",
+            SampleRatio: 1,
+        },
+
+        SeedOrg: "default",
+        OIDC: OIDCConfig{
+            ProviderName:  "SSO",
+            RedirectURL:   "http://localhost:8080/auth/oidc/callback",
+            Scopes:        []string{"openid", "profile", "email", "groups"},
+            GroupsClaim:   "groups",
+            DefaultRole:   "user",
+            AutoProvision: true,
+        },
+        Signup: SignupConfig{
+            Mode:      SignupApproval,
+            InviteTTL: 72 * time.Hour,
+        },
+        Password: PasswordConfig{
+            MinLength:    8,
+            RequireDigit: true,
+            MinScore:     2,
+        },
+        Replenishment: ReplenishmentConfig{
+            Interval: 24 * time.Hour,
+            Window:   28 * 24 * time.Hour,
+            LeadTime: 7 * 24 * time.Hour,
+        },
+        Labels: LabelsConfig{
+            MachineTarget: ScanOperation,
+            Templates: []LabelTemplate{
+                {
+                    Name: "a4-24", Title: "A4, 24 этикетки 70×37 мм",
+                    PageWidth: 210, PageHeight: 297, Columns: 3, Rows: 8,
+                    LabelWidth: 70, LabelHeight: 37, MarginTop: 0.5, FontSize: 9,
+                },
+                {
+                    Name: "a4-65", Title: "A4, 65 этикеток 38×21 мм",
+                    PageWidth: 210, PageHeight: 297, Columns: 5, Rows: 13,
+                    LabelWidth: 38.1, LabelHeight: 21.2, MarginLeft: 4.75, MarginTop: 10.7, FontSize: 6,
+                },
+                {
+                    Name: "thermal-58x40", Title: "Термопринтер, 58×40 мм",
+                    PageWidth: 58, PageHeight: 40, Columns: 1, Rows: 1,
+                    LabelWidth: 58, LabelHeight: 40, FontSize: 9,
+                },
+            },
+        },
     }
+}
 
-    config := &Config{
-        DBHost:     getEnv("DB_HOST", "localhost"),
-        DBPort:     getEnvAsInt("DB_PORT", 5432),
-        DBUser:     getEnv("DB_USER", "postgres"),
-        DBPassword: getEnv("DB_PASSWORD", "postgres"),
-        DBName:     getEnv("DB_NAME", "venderp"),
-        SSLMode:    getEnv("SSL_MODE", "disable"),
+// applyEnv переопределяет значения переменными окружения (и .env).
+func applyEnv(c *Config) {
+    c.DBHost = getEnv("DB_HOST", c.DBHost)
+    c.DBPort = getEnvAsInt("DB_PORT", c.DBPort)
+    c.DBUser = getEnv("DB_USER", c.DBUser)
+    c.DBPassword = getEnv("DB_PASSWORD", c.DBPassword)
+    c.DBName = getEnv("DB_NAME", c.DBName)
+    c.SSLMode = getEnv("SSL_MODE", c.SSLMode)
+    c.DBMaxOpenConns = getEnvAsInt("DB_MAX_OPEN_CONNS", c.DBMaxOpenConns)
+    c.DBMaxIdleConns = getEnvAsInt("DB_MAX_IDLE_CONNS", c.DBMaxIdleConns)
+    c.DBConnMaxLifetime = getEnvAsDuration("DB_CONN_MAX_LIFETIME", c.DBConnMaxLifetime)
+
+    c.Server.Addr = getEnv("LISTEN_ADDR", c.Server.Addr)
+    c.Server.TLSCert = getEnv("TLS_CERT_FILE", c.Server.TLSCert)
+    c.Server.TLSKey = getEnv("TLS_KEY_FILE", c.Server.TLSKey)
+    c.Server.ReadTimeout = getEnvAsDuration("HTTP_READ_TIMEOUT", c.Server.ReadTimeout)
+    c.Server.WriteTimeout = getEnvAsDuration("HTTP_WRITE_TIMEOUT", c.Server.WriteTimeout)
+    c.Server.IdleTimeout = getEnvAsDuration("HTTP_IDLE_TIMEOUT", c.Server.IdleTimeout)
+    c.Server.ShutdownTimeout = getEnvAsDuration("SHUTDOWN_TIMEOUT", c.Server.ShutdownTimeout)
+    c.Server.MetricsToken = getEnv("METRICS_TOKEN", c.Server.MetricsToken)
+
+    c.SessionLifetime = getEnvAsDuration("SESSION_LIFETIME", c.SessionLifetime)
+    c.LogLevel = strings.ToLower(getEnv("LOG_LEVEL", c.LogLevel))
+
+    c.Tracing.Exporter = strings.ToLower(getEnv("OTEL_TRACES_EXPORTER", c.Tracing.Exporter))
+    c.Tracing.ServiceName = getEnv("OTEL_SERVICE_NAME", c.Tracing.ServiceName)
+    c.Tracing.Endpoint = getEnv("OTEL_EXPORTER_OTLP_ENDPOINT", c.Tracing.Endpoint)
+    c.Tracing.Insecure = getEnvAsBool("OTEL_EXPORTER_OTLP_INSECURE", c.Tracing.Insecure)
+    c.Tracing.File = getEnv("TRACE_FILE", c.Tracing.File)
+    if value, exists := os.LookupEnv("OTEL_TRACES_SAMPLER_ARG"); exists {
+        if ratio, err := strconv.ParseFloat(value, 64); err == nil {
+            c.Tracing.SampleRatio = ratio
+        }
     }
-    
-    return config
+
+    c.MigrationsAllowDrift = getEnvAsBool("MIGRATIONS_ALLOW_DRIFT", c.MigrationsAllowDrift)
+    c.AssetsDir = getEnv("ASSETS_DIR", c.AssetsDir)
+    c.SeedProfile = getEnv("SEED_PROFILE", c.SeedProfile)
+    c.SeedOrg = getEnv("SEED_ORG", c.SeedOrg)
+
+    c.OIDC.ProviderName = getEnv("OIDC_PROVIDER_NAME", c.OIDC.ProviderName)
+    c.OIDC.Issuer = getEnv("OIDC_ISSUER", c.OIDC.Issuer)
+    c.OIDC.ClientID = getEnv("OIDC_CLIENT_ID", c.OIDC.ClientID)
+    c.OIDC.ClientSecret = getEnv("OIDC_CLIENT_SECRET", c.OIDC.ClientSecret)
+    c.OIDC.RedirectURL = getEnv("OIDC_REDIRECT_URL", c.OIDC.RedirectURL)
+    c.OIDC.Scopes = getEnvAsList("OIDC_SCOPES", c.OIDC.Scopes)
+    c.OIDC.GroupsClaim = getEnv("OIDC_GROUPS_CLAIM", c.OIDC.GroupsClaim)
+    if value, exists := os.LookupEnv("OIDC_ROLE_MAPPING"); exists {
+        c.OIDC.RoleMapping = parseRoleMapping(value)
+    }
+    c.OIDC.DefaultRole = getEnv("OIDC_DEFAULT_ROLE", c.OIDC.DefaultRole)
+    c.OIDC.AutoProvision = getEnvAsBool("OIDC_AUTO_PROVISION", c.OIDC.AutoProvision)
+    c.OIDC.TrustEmail = getEnvAsBool("OIDC_TRUST_EMAIL", c.OIDC.TrustEmail)
+
+    c.Signup.Mode = strings.ToLower(strings.TrimSpace(getEnv("SIGNUP_MODE", c.Signup.Mode)))
+    if value, exists := os.LookupEnv("INVITE_TTL_HOURS"); exists {
+        if hours, err := strconv.Atoi(value); err == nil {
+            c.Signup.InviteTTL = time.Duration(hours) * time.Hour
+        }
+    }
+
+    c.Password.MinLength = getEnvAsInt("PASSWORD_MIN_LENGTH", c.Password.MinLength)
+    c.Password.RequireUpper = getEnvAsBool("PASSWORD_REQUIRE_UPPER", c.Password.RequireUpper)
+    c.Password.RequireLower = getEnvAsBool("PASSWORD_REQUIRE_LOWER", c.Password.RequireLower)
+    c.Password.RequireDigit = getEnvAsBool("PASSWORD_REQUIRE_DIGIT", c.Password.RequireDigit)
+    c.Password.RequireSymbol = getEnvAsBool("PASSWORD_REQUIRE_SYMBOL", c.Password.RequireSymbol)
+    c.Password.MinScore = getEnvAsInt("PASSWORD_MIN_SCORE", c.Password.MinScore)
+
+    c.Replenishment.Interval = getEnvAsDuration("REPLENISH_INTERVAL", c.Replenishment.Interval)
+    c.Replenishment.Window = getEnvAsDuration("REPLENISH_WINDOW", c.Replenishment.Window)
+    c.Replenishment.LeadTime = getEnvAsDuration("REPLENISH_LEAD_TIME", c.Replenishment.LeadTime)
+
+    c.Labels.BaseURL = strings.TrimRight(getEnv("LABELS_BASE_URL", c.Labels.BaseURL), "/")
+    c.Labels.MachineTarget = strings.ToLower(getEnv("LABELS_MACHINE_TARGET", c.Labels.MachineTarget))
+}
+
+// Files возвращает встроенные файлы или, если задан AssetsDir,
+// подкаталог dir на диске.
+func (c *Config) Files(embedded fs.FS, dir string) fs.FS {
+    if c.AssetsDir == "" {
+        return embedded
+    }
+    return os.DirFS(filepath.Join(c.AssetsDir, dir))
 }
 
 func getEnv(key, defaultValue string) string {
@@ -56,6 +417,52 @@ func getEnvAsInt(key string, defaultValue int) int {
     return defaultValue
 }
 
+func getEnvAsDuration(key string, defaultValue time.Duration) time.Duration {
+    if value, exists := os.LookupEnv(key); exists {
+        if duration, err := time.ParseDuration(value); err == nil {
+            return duration
+        }
+    }
+    return defaultValue
+}
+
+func getEnvAsBool(key string, defaultValue bool) bool {
+    if value, exists := os.LookupEnv(key); exists {
+        if boolValue, err := strconv.ParseBool(value); err == nil {
+            return boolValue
+        }
+    }
+    return defaultValue
+}
+
+func getEnvAsList(key string, defaultValue []string) []string {
+    value, exists := os.LookupEnv(key)
+    if !exists {
+        return defaultValue
+    }
+    var list []string
+    for _, item := range strings.Split(value, ",") {
+        if item = strings.TrimSpace(item); item != "" {
+            list = append(list, item)
+        }
+    }
+    return list
+}
+
+// parseRoleMapping разбирает строку вида "vend-admins=admin,vend-ops=operator".
+func parseRoleMapping(value string) []RoleMapping {
+    var mapping []RoleMapping
+    for _, pair := range strings.Split(value, ",") {
+        group, role, ok := strings.Cut(pair, "=")
+        group, role = strings.TrimSpace(group), strings.TrimSpace(role)
+        if !ok || group == "" || role == "" {
+            continue
+        }
+        mapping = append(mapping, RoleMapping{Group: group, Role: role})
+    }
+    return mapping
+}
+
 func (c *Config) GetConnectionString() string {
     return fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=%s",
         c.DBHost, c.DBPort, c.DBUser, c.DBPassword, c.DBName, c.SSLMode)
@@ -67,12 +474,20 @@ func ConnectDB(config *Config) (*sql.DB, error) {
     
     connStr := config.GetConnectionString()
     
-    // Use "pgx" as driver name instead of "postgres"
-    db, err := sql.Open("pgx", connStr)
+    // Use "pgx" as driver name instead of "postgres".
+    // otelsql оборачивает драйвер: каждый запрос становится спаном трассировки
+    db, err := otelsql.Open("pgx", connStr,
+        otelsql.WithAttributes(attribute.String("db.system", "postgresql")),
+        otelsql.WithSpanOptions(otelsql.SpanOptions{OmitConnResetSession: true, OmitRows: true}),
+    )
     if err != nil {
         return nil, fmt.Errorf("error opening database: %v", err)
     }
 
+    db.SetMaxOpenConns(config.DBMaxOpenConns)
+    db.SetMaxIdleConns(config.DBMaxIdleConns)
+    db.SetConnMaxLifetime(config.DBConnMaxLifetime)
+
     // Test the connection
     err = db.Ping()
     if err != nil {
@@ -0,0 +1,349 @@
+package config
+
+import (
+    "errors"
+    "flag"
+    "fmt"
+    "io"
+    "log"
+    "net"
+    "net/url"
+    "os"
+    "reflect"
+    "strings"
+    "time"
+
+    "github.com/joho/godotenv"
+    "gopkg.in/yaml.v3"
+)
+
+// ErrPrintConfig возвращается Load, если передан -print-config: конфигурация
+// уже напечатана, и программа должна завершиться.
+var ErrPrintConfig = errors.New("configuration printed")
+
+var logLevels = []string{"debug", "info", "warn", "error"}
+
+// LoadConfig читает конфигурацию без флагов командной строки — для
+// утилит со своими флагами (cmd/migrate, cmd/seed). Неверная конфигурация
+// завершает программу.
+func LoadConfig() *Config {
+    cfg, err := Load(nil)
+    if err != nil {
+        log.Fatalf("Invalid configuration: %v", err)
+    }
+    return cfg
+}
+
+// Load собирает конфигурацию: значения по умолчанию, YAML-файл, переменные
+// окружения и флаги из args. Файл задается флагом -config или переменной
+// CONFIG_FILE. Результат проверяется Validate.
+func Load(args []string) (*Config, error) {
+    // Load .env file
+    if err := godotenv.Load(); err != nil && !errors.Is(err, os.ErrNotExist) {
+        log.Printf("Warning: Error loading .env file: %v", err)
+    }
+
+    flags := flag.NewFlagSet("server", flag.ContinueOnError)
+    configFile := flags.String("config", os.Getenv("CONFIG_FILE"), "path to a YAML configuration file")
+    printConfig := flags.Bool("print-config", false, "print the effective configuration and exit")
+    // Флаги сохраняются как отложенные изменения и применяются последними,
+    // поверх файла и окружения
+    var overrides []func(*Config)
+    registerFlags(flags, &overrides)
+    if err := flags.Parse(args); err != nil {
+        return nil, err
+    }
+
+    cfg := Defaults()
+    if *configFile != "" {
+        if err := loadFile(cfg, *configFile); err != nil {
+            return nil, err
+        }
+    }
+    applyEnv(cfg)
+    for _, override := range overrides {
+        override(cfg)
+    }
+
+    if *printConfig {
+        if err := cfg.Print(os.Stdout); err != nil {
+            return nil, err
+        }
+        if err := cfg.Validate(); err != nil {
+            return nil, err
+        }
+        return nil, ErrPrintConfig
+    }
+    if err := cfg.Validate(); err != nil {
+        return nil, err
+    }
+    return cfg, nil
+}
+
+func loadFile(cfg *Config, path string) error {
+    content, err := os.ReadFile(path)
+    if err != nil {
+        return fmt.Errorf("reading config file: %w", err)
+    }
+    decoder := yaml.NewDecoder(strings.NewReader(string(content)))
+    decoder.KnownFields(true)
+    if err := decoder.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+        return fmt.Errorf("parsing %s: %w", path, err)
+    }
+    return nil
+}
+
+func registerFlags(flags *flag.FlagSet, overrides *[]func(*Config)) {
+    str := func(name, usage string, field func(*Config) *string) {
+        flags.Func(name, usage, func(value string) error {
+            *overrides = append(*overrides, func(c *Config) { *field(c) = value })
+            return nil
+        })
+    }
+    num := func(name, usage string, field func(*Config) *int) {
+        flags.Func(name, usage, func(value string) error {
+            var n int
+            if _, err := fmt.Sscan(value, &n); err != nil {
+                return fmt.Errorf("invalid number %q", value)
+            }
+            *overrides = append(*overrides, func(c *Config) { *field(c) = n })
+            return nil
+        })
+    }
+    duration := func(name, usage string, field func(*Config) *time.Duration) {
+        flags.Func(name, usage, func(value string) error {
+            d, err := time.ParseDuration(value)
+            if err != nil {
+                return err
+            }
+            *overrides = append(*overrides, func(c *Config) { *field(c) = d })
+            return nil
+        })
+    }
+
+    str("addr", "listen address, e.g. :8080", func(c *Config) *string { return &c.Server.Addr })
+    str("tls-cert", "TLS certificate file", func(c *Config) *string { return &c.Server.TLSCert })
+    str("tls-key", "TLS private key file", func(c *Config) *string { return &c.Server.TLSKey })
+    duration("read-timeout", "HTTP read timeout", func(c *Config) *time.Duration { return &c.Server.ReadTimeout })
+    duration("write-timeout", "HTTP write timeout", func(c *Config) *time.Duration { return &c.Server.WriteTimeout })
+    duration("idle-timeout", "HTTP keep-alive idle timeout", func(c *Config) *time.Duration { return &c.Server.IdleTimeout })
+    duration("shutdown-timeout", "time to drain in-flight requests on shutdown", func(c *Config) *time.Duration { return &c.Server.ShutdownTimeout })
+
+    str("db-host", "database host", func(c *Config) *string { return &c.DBHost })
+    num("db-port", "database port", func(c *Config) *int { return &c.DBPort })
+    str("db-name", "database name", func(c *Config) *string { return &c.DBName })
+    str("db-user", "database user", func(c *Config) *string { return &c.DBUser })
+    num("db-max-open-conns", "maximum open database connections", func(c *Config) *int { return &c.DBMaxOpenConns })
+    num("db-max-idle-conns", "maximum idle database connections", func(c *Config) *int { return &c.DBMaxIdleConns })
+    duration("db-conn-max-lifetime", "maximum lifetime of a database connection", func(c *Config) *time.Duration { return &c.DBConnMaxLifetime })
+
+    duration("session-lifetime", "how long a sign-in session lasts", func(c *Config) *time.Duration { return &c.SessionLifetime })
+    str("log-level", "debug, info, warn or error", func(c *Config) *string { return &c.LogLevel })
+    str("trace-exporter", "none, stdout, file or otlp", func(c *Config) *string { return &c.Tracing.Exporter })
+    str("trace-endpoint", "OTLP/HTTP collector address", func(c *Config) *string { return &c.Tracing.Endpoint })
+    str("trace-file", "file for the file trace exporter", func(c *Config) *string { return &c.Tracing.File })
+    duration("replenish-interval", "how often to create draft supplies for low stock, 0 to disable", func(c *Config) *time.Duration { return &c.Replenishment.Interval })
+    str("labels-base-url", "external server URL encoded in QR codes on labels", func(c *Config) *string { return &c.Labels.BaseURL })
+    str("assets", "read templates, static files and migrations from this directory instead of the binary", func(c *Config) *string { return &c.AssetsDir })
+}
+
+// Validate проверяет конфигурацию и перечисляет все ошибки сразу.
+func (c *Config) Validate() error {
+    var problems []string
+    add := func(format string, args ...interface{}) {
+        problems = append(problems, fmt.Sprintf(format, args...))
+    }
+
+    if c.DBHost == "" || c.DBName == "" || c.DBUser == "" {
+        add("db_host, db_name and db_user are required")
+    }
+    if c.DBPort < 1 || c.DBPort > 65535 {
+        add("db_port %d is out of range", c.DBPort)
+    }
+    if c.DBMaxOpenConns < 0 || c.DBMaxIdleConns < 0 {
+        add("db pool sizes must not be negative")
+    }
+    if c.DBMaxOpenConns > 0 && c.DBMaxIdleConns > c.DBMaxOpenConns {
+        add("db_max_idle_conns (%d) exceeds db_max_open_conns (%d)", c.DBMaxIdleConns, c.DBMaxOpenConns)
+    }
+    if c.DBConnMaxLifetime < 0 {
+        add("db_conn_max_lifetime must not be negative")
+    }
+
+    if _, _, err := net.SplitHostPort(c.Server.Addr); err != nil {
+        add("server.addr %q: %v", c.Server.Addr, err)
+    }
+    if (c.Server.TLSCert == "") != (c.Server.TLSKey == "") {
+        add("server.tls_cert and server.tls_key must be set together")
+    }
+    for _, file := range []string{c.Server.TLSCert, c.Server.TLSKey} {
+        if file == "" {
+            continue
+        }
+        if _, err := os.Stat(file); err != nil {
+            add("TLS file %s: %v", file, err)
+        }
+    }
+    for name, timeout := range map[string]time.Duration{
+        "read_timeout":     c.Server.ReadTimeout,
+        "write_timeout":    c.Server.WriteTimeout,
+        "idle_timeout":     c.Server.IdleTimeout,
+        "shutdown_timeout": c.Server.ShutdownTimeout,
+    } {
+        if timeout <= 0 {
+            add("server.%s must be positive", name)
+        }
+    }
+
+    if c.SessionLifetime < time.Minute {
+        add("session_lifetime must be at least 1m")
+    }
+    if !contains(logLevels, c.LogLevel) {
+        add("log_level %q must be one of %s", c.LogLevel, strings.Join(logLevels, ", "))
+    }
+
+    if !contains([]string{TraceNone, TraceStdout, TraceFile, TraceOTLP}, c.Tracing.Exporter) {
+        add("tracing.exporter %q must be one of %s, %s, %s, %s", c.Tracing.Exporter, TraceNone, TraceStdout, TraceFile, TraceOTLP)
+    }
+    if c.Tracing.Exporter == TraceFile && c.Tracing.File == "" {
+        add("tracing.file is required for the file exporter")
+    }
+    if c.Tracing.Exporter == TraceOTLP && c.Tracing.Endpoint == "" {
+        add("tracing.endpoint is required for the otlp exporter")
+    }
+    if c.Tracing.SampleRatio < 0 || c.Tracing.SampleRatio > 1 {
+        add("tracing.sample_ratio must be between 0 and 1")
+    }
+
+    if !contains([]string{SignupOpen, SignupInvite, SignupApproval}, c.Signup.Mode) {
+        add("signup.mode %q must be one of %s, %s, %s", c.Signup.Mode, SignupOpen, SignupInvite, SignupApproval)
+    }
+    if c.Signup.InviteTTL <= 0 {
+        add("signup.invite_ttl must be positive")
+    }
+    if c.Password.MinLength < 1 {
+        add("password.min_length must be at least 1")
+    }
+    if c.Password.MinScore < 0 || c.Password.MinScore > 4 {
+        add("password.min_score must be between 0 and 4")
+    }
+    if c.Replenishment.Interval < 0 || (c.Replenishment.Interval > 0 && c.Replenishment.Interval < time.Minute) {
+        add("replenishment.interval must be 0 (disabled) or at least 1m")
+    }
+    if c.Replenishment.Window < 24*time.Hour {
+        add("replenishment.window must be at least 24h")
+    }
+    if c.Replenishment.LeadTime < 0 {
+        add("replenishment.lead_time must not be negative")
+    }
+    if !contains([]string{ScanOperation, ScanMachine}, c.Labels.MachineTarget) {
+        add("labels.machine_target %q must be %s or %s", c.Labels.MachineTarget, ScanOperation, ScanMachine)
+    }
+    if c.Labels.BaseURL != "" {
+        if u, err := url.Parse(c.Labels.BaseURL); err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") {
+            add("labels.base_url %q must be an absolute http(s) URL", c.Labels.BaseURL)
+        }
+    }
+    if len(c.Labels.Templates) == 0 {
+        add("labels.templates must list at least one template")
+    }
+    templates := map[string]bool{}
+    for i, t := range c.Labels.Templates {
+        name := t.Name
+        if name == "" {
+            name = fmt.Sprintf("#%d", i+1)
+            add("labels.templates[%d].name is required", i)
+        } else if templates[name] {
+            add("labels template %s is listed twice", name)
+        }
+        templates[name] = true
+        if t.Columns < 1 || t.Rows < 1 || t.LabelWidth <= 0 || t.LabelHeight <= 0 || t.FontSize <= 0 {
+            add("labels template %s: columns, rows, label sizes and font_size must be positive", name)
+            continue
+        }
+        if t.MarginLeft < 0 || t.MarginTop < 0 || t.GapX < 0 || t.GapY < 0 {
+            add("labels template %s: margins and gaps must not be negative", name)
+        }
+        width := t.MarginLeft + float64(t.Columns)*t.LabelWidth + float64(t.Columns-1)*t.GapX
+        height := t.MarginTop + float64(t.Rows)*t.LabelHeight + float64(t.Rows-1)*t.GapY
+        // Допуск на округление размеров, взятых с упаковки этикеток
+        if width > t.PageWidth+0.01 || height > t.PageHeight+0.01 {
+            add("labels template %s: %d×%d labels do not fit a %g×%g mm page", name, t.Columns, t.Rows, t.PageWidth, t.PageHeight)
+        }
+    }
+    if (c.OIDC.Issuer == "") != (c.OIDC.ClientID == "") {
+        add("oidc.issuer and oidc.client_id must be set together")
+    }
+
+    if len(problems) > 0 {
+        return fmt.Errorf("invalid configuration:\n  - %s", strings.Join(problems, "\n  - "))
+    }
+    return nil
+}
+
+// Print печатает действующую конфигурацию в YAML без секретов.
+func (c *Config) Print(w io.Writer) error {
+    redacted := *c
+    if redacted.DBPassword != "" {
+        redacted.DBPassword = "********"
+    }
+    if redacted.OIDC.ClientSecret != "" {
+        redacted.OIDC.ClientSecret = "********"
+    }
+    if redacted.Server.MetricsToken != "" {
+        redacted.Server.MetricsToken = "********"
+    }
+    node, err := printable(reflect.ValueOf(redacted))
+    if err != nil {
+        return err
+    }
+    encoder := yaml.NewEncoder(w)
+    encoder.SetIndent(2)
+    defer encoder.Close()
+    return encoder.Encode(node)
+}
+
+// printable строит YAML-узел в порядке полей структуры. Интервалы
+// печатаются как "30s", а не в наносекундах, чтобы вывод можно было
+// использовать как файл конфигурации.
+func printable(v reflect.Value) (*yaml.Node, error) {
+    node := &yaml.Node{}
+    switch {
+    case v.Type() == reflect.TypeOf(time.Duration(0)):
+        return node, node.Encode(time.Duration(v.Int()).String())
+    case v.Kind() == reflect.Struct:
+        node.Kind = yaml.MappingNode
+        for i := 0; i < v.NumField(); i++ {
+            tag := strings.Split(v.Type().Field(i).Tag.Get("yaml"), ",")[0]
+            if tag == "" || tag == "-" {
+                continue
+            }
+            value, err := printable(v.Field(i))
+            if err != nil {
+                return nil, err
+            }
+            node.Content = append(node.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: tag}, value)
+        }
+        return node, nil
+    case v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.Struct:
+        node.Kind = yaml.SequenceNode
+        for i := 0; i < v.Len(); i++ {
+            item, err := printable(v.Index(i))
+            if err != nil {
+                return nil, err
+            }
+            node.Content = append(node.Content, item)
+        }
+        return node, nil
+    }
+    return node, node.Encode(v.Interface())
+}
+
+func contains(values []string, value string) bool {
+    for _, v := range values {
+        if v == value {
+            return true
+        }
+    }
+    return false
+}
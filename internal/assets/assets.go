@@ -0,0 +1,106 @@
+// Package assets раздает статические файлы по адресам с хешем содержимого
+// (/static/css/styles.3f9a1c2b7e.css). Такой адрес меняется вместе с файлом,
+// поэтому браузер может кэшировать его на год без риска получить старую
+// версию после обновления.
+package assets
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// Prefix — URL-префикс статических файлов
+const Prefix = "/static/"
+
+const hashLength = 10
+
+// hashedName выделяет хеш из имени вида name.<hash>.ext
+var hashedName = regexp.MustCompile(`^(.+)\.([0-9a-f]{10})(\.[^./]+)$`)
+
+type Assets struct {
+	files fs.FS
+	// live — файлы читаются с диска и могут меняться, хеши не кэшируются
+	live bool
+
+	mu     sync.RWMutex
+	hashes map[string]string
+}
+
+// New создает набор ассетов. live включается для каталога на диске
+// в режиме разработки.
+func New(files fs.FS, live bool) *Assets {
+	return &Assets{files: files, live: live, hashes: make(map[string]string)}
+}
+
+// URL возвращает адрес файла с хешем, например URL("css/styles.css").
+// Если файл не найден, возвращается адрес без хеша.
+func (a *Assets) URL(name string) string {
+	name = strings.TrimPrefix(name, "/")
+	hash := a.hash(name)
+	if hash == "" {
+		return Prefix + name
+	}
+	ext := path.Ext(name)
+	return Prefix + strings.TrimSuffix(name, ext) + "." + hash + ext
+}
+
+func (a *Assets) hash(name string) string {
+	if !a.live {
+		a.mu.RLock()
+		hash, ok := a.hashes[name]
+		a.mu.RUnlock()
+		if ok {
+			return hash
+		}
+	}
+
+	content, err := fs.ReadFile(a.files, name)
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(content)
+	hash := hex.EncodeToString(sum[:])[:hashLength]
+
+	if !a.live {
+		a.mu.Lock()
+		a.hashes[name] = hash
+		a.mu.Unlock()
+	}
+	return hash
+}
+
+// Handler раздает файлы под Prefix. Запросы с актуальным хешем кэшируются
+// на год; без хеша или с устаревшим — только с проверкой (no-cache).
+func (a *Assets) Handler() http.Handler {
+	files := http.FileServer(http.FS(a.files))
+
+	return http.StripPrefix(Prefix, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := r.URL.Path
+		cache := "no-cache"
+
+		if m := hashedName.FindStringSubmatch(name); m != nil {
+			original := m[1] + m[3]
+			if hash := a.hash(original); hash != "" {
+				if hash == m[2] && !a.live {
+					cache = "public, max-age=31536000, immutable"
+				}
+				name = original
+			}
+		}
+
+		w.Header().Set("Cache-Control", cache)
+		r2 := new(http.Request)
+		*r2 = *r
+		r2.URL = new(url.URL)
+		*r2.URL = *r.URL
+		r2.URL.Path = name
+		files.ServeHTTP(w, r2)
+	}))
+}
@@ -0,0 +1,187 @@
+// Package credentials — единая точка работы с паролями пользователей:
+// политика и оценка надежности, хеширование bcrypt, проверка при входе
+// и перевод старых паролей, хранившихся в открытом виде, в хеши.
+package credentials
+
+import (
+	"crypto/subtle"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+
+	"vend_erp/config"
+)
+
+// bcrypt учитывает только первые 72 байта пароля
+const maxPasswordBytes = 72
+
+// PolicyError перечисляет все нарушения политики, чтобы показать их разом.
+type PolicyError struct {
+	Problems []string
+}
+
+func (e *PolicyError) Error() string {
+	return "Пароль не соответствует требованиям: " + strings.Join(e.Problems, "; ")
+}
+
+type Service struct {
+	db     *sql.DB
+	policy config.PasswordConfig
+}
+
+func NewService(db *sql.DB, policy config.PasswordConfig) *Service {
+	return &Service{db: db, policy: policy}
+}
+
+func (s *Service) Policy() config.PasswordConfig {
+	return s.policy
+}
+
+// Validate проверяет пароль по политике. hints — имя пользователя, email
+// и другие данные, которые не должны входить в пароль.
+func (s *Service) Validate(password string, hints ...string) error {
+	var problems []string
+
+	if len([]rune(password)) < s.policy.MinLength {
+		problems = append(problems, fmt.Sprintf("не короче %d символов", s.policy.MinLength))
+	}
+	if len(password) > maxPasswordBytes {
+		problems = append(problems, fmt.Sprintf("не длиннее %d байт", maxPasswordBytes))
+	}
+
+	classes := characterClasses(password)
+	if s.policy.RequireUpper && !classes.upper {
+		problems = append(problems, "заглавная буква")
+	}
+	if s.policy.RequireLower && !classes.lower {
+		problems = append(problems, "строчная буква")
+	}
+	if s.policy.RequireDigit && !classes.digit {
+		problems = append(problems, "цифра")
+	}
+	if s.policy.RequireSymbol && !classes.symbol {
+		problems = append(problems, "спецсимвол")
+	}
+
+	if strength := Score(password, hints...); strength.Score < s.policy.MinScore {
+		problems = append(problems, fmt.Sprintf("надежность не ниже «%s» (сейчас «%s»)",
+			scoreLabels[s.policy.MinScore], strength.Label))
+	}
+
+	if len(problems) > 0 {
+		return &PolicyError{Problems: problems}
+	}
+	return nil
+}
+
+// Hash возвращает bcrypt-хеш для хранения в users.password.
+func (s *Service) Hash(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+// IsHash отличает bcrypt-хеш от пароля, сохраненного в открытом виде.
+func IsHash(stored string) bool {
+	_, err := bcrypt.Cost([]byte(stored))
+	return err == nil
+}
+
+// Verify сверяет введенный пароль с сохраненным значением. Пустое значение
+// означает вход только через SSO. Если пароль еще хранится в открытом виде
+// и совпал, он сразу заменяется хешем.
+func (s *Service) Verify(userID int64, stored, password string) bool {
+	if stored == "" || password == "" {
+		return false
+	}
+
+	if IsHash(stored) {
+		return bcrypt.CompareHashAndPassword([]byte(stored), []byte(password)) == nil
+	}
+
+	if subtle.ConstantTimeCompare([]byte(stored), []byte(password)) != 1 {
+		return false
+	}
+
+	if err := s.rehash(userID, stored); err != nil {
+		slog.Warn("failed to hash plain-text password", "user_id", userID, "err", err)
+	}
+	return true
+}
+
+// SetPassword проверяет пароль по политике и сохраняет его хеш.
+// mustChange требует сменить пароль при следующем входе.
+func (s *Service) SetPassword(userID int64, password string, mustChange bool, hints ...string) error {
+	if err := s.Validate(password, hints...); err != nil {
+		return err
+	}
+	hash, err := s.Hash(password)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.db.Exec(`
+		UPDATE users
+		SET password = $1, must_change_password = $2,
+		    password_changed_at = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $3
+	`, hash, mustChange, userID)
+	return err
+}
+
+// MigratePlaintext хеширует все пароли, сохраненные в открытом виде.
+// Вызывается при запуске сервера; повторный запуск ничего не меняет.
+func (s *Service) MigratePlaintext() (int, error) {
+	rows, err := s.db.Query(`SELECT id, password FROM users WHERE password <> ''`)
+	if err != nil {
+		return 0, err
+	}
+
+	type plainRow struct {
+		id       int64
+		password string
+	}
+	var pending []plainRow
+	for rows.Next() {
+		var row plainRow
+		if err := rows.Scan(&row.id, &row.password); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		if !IsHash(row.password) {
+			pending = append(pending, row)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	migrated := 0
+	for _, row := range pending {
+		if err := s.rehash(row.id, row.password); err != nil {
+			return migrated, fmt.Errorf("user %d: %w", row.id, err)
+		}
+		migrated++
+	}
+	return migrated, nil
+}
+
+// rehash заменяет открытый пароль хешем, только если значение в базе
+// не изменилось с момента чтения.
+func (s *Service) rehash(userID int64, plain string) error {
+	hash, err := s.Hash(plain)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.Exec(`
+		UPDATE users SET password = $1, password_changed_at = COALESCE(password_changed_at, CURRENT_TIMESTAMP)
+		WHERE id = $2 AND password = $3
+	`, hash, userID, plain)
+	return err
+}
@@ -0,0 +1,143 @@
+package credentials
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Strength — оценка надежности пароля от 0 до 4.
+type Strength struct {
+	Score int
+	Label string
+}
+
+var scoreLabels = []string{"очень слабый", "слабый", "средний", "хороший", "надежный"}
+
+// commonPasswords — самые частые пароли из публичных утечек, в том числе
+// встречавшиеся в демо-данных.
+var commonPasswords = map[string]bool{
+	"password": true, "password1": true, "123456": true, "12345678": true,
+	"123456789": true, "1234567890": true, "qwerty": true, "qwerty123": true,
+	"111111": true, "123123": true, "abc123": true, "admin": true,
+	"admin123": true, "letmein": true, "welcome": true, "iloveyou": true,
+	"monkey": true, "dragon": true, "secret": true, "passw0rd": true,
+	"qwertyuiop": true, "1q2w3e4r": true, "1qaz2wsx": true, "zaq12wsx": true,
+	"пароль": true, "йцукен": true,
+}
+
+var keyboardRows = []string{
+	"qwertyuiop", "asdfghjkl", "zxcvbnm", "1234567890", "йцукенгшщзхъ", "фывапролджэ", "ячсмитьбю",
+}
+
+type classes struct {
+	upper, lower, digit, symbol bool
+}
+
+func (c classes) count() int {
+	n := 0
+	for _, ok := range []bool{c.upper, c.lower, c.digit, c.symbol} {
+		if ok {
+			n++
+		}
+	}
+	return n
+}
+
+func characterClasses(password string) classes {
+	var c classes
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			c.upper = true
+		case unicode.IsLower(r):
+			c.lower = true
+		case unicode.IsDigit(r):
+			c.digit = true
+		default:
+			c.symbol = true
+		}
+	}
+	return c
+}
+
+// Score оценивает пароль по длине и разнообразию символов и штрафует
+// за частые пароли, клавиатурные последовательности, повторы и совпадение
+// с hints (имя пользователя, email).
+func Score(password string, hints ...string) Strength {
+	lower := strings.ToLower(password)
+	length := len([]rune(password))
+
+	score := 0
+	switch {
+	case length >= 16:
+		score = 3
+	case length >= 12:
+		score = 2
+	case length >= 8:
+		score = 1
+	}
+	if characterClasses(password).count() >= 3 {
+		score++
+	}
+
+	if containsSequence(lower) || repeatedRatio(lower) > 0.5 {
+		score--
+	}
+	for _, hint := range hints {
+		hint = strings.ToLower(strings.TrimSpace(hint))
+		if at := strings.Index(hint, "@"); at > 0 {
+			hint = hint[:at]
+		}
+		if len([]rune(hint)) >= 3 && strings.Contains(lower, hint) {
+			score--
+			break
+		}
+	}
+	if commonPasswords[lower] || length < 6 {
+		score = 0
+	}
+
+	if score < 0 {
+		score = 0
+	}
+	if score > 4 {
+		score = 4
+	}
+	return Strength{Score: score, Label: scoreLabels[score]}
+}
+
+// containsSequence ищет 4 и более подряд идущих символа клавиатурного ряда
+// или алфавита, в прямом или обратном порядке.
+func containsSequence(password string) bool {
+	runes := []rune(password)
+	for i := 0; i+4 <= len(runes); i++ {
+		chunk := string(runes[i : i+4])
+		reversed := string([]rune{runes[i+3], runes[i+2], runes[i+1], runes[i]})
+		for _, row := range keyboardRows {
+			if strings.Contains(row, chunk) || strings.Contains(row, reversed) {
+				return true
+			}
+		}
+		if runes[i+1]-runes[i] == 1 && runes[i+2]-runes[i+1] == 1 && runes[i+3]-runes[i+2] == 1 {
+			return true
+		}
+	}
+	return false
+}
+
+// repeatedRatio — доля символов, повторяющих самый частый символ.
+func repeatedRatio(password string) float64 {
+	runes := []rune(password)
+	if len(runes) == 0 {
+		return 0
+	}
+	counts := make(map[rune]int)
+	max := 0
+	for _, r := range runes {
+		counts[r]++
+		if counts[r] > max {
+			max = counts[r]
+		}
+	}
+	return float64(max) / float64(len(runes))
+}
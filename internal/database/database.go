@@ -1,6 +1,7 @@
 package database
 
 import (
+    "context"
     "database/sql"
     "fmt"
     "vend_erp/config" // Changed from internal/config to config
@@ -25,8 +26,9 @@ func Connect(cfg *config.Config) (*sql.DB, error) {
     return db, nil
 }
 
-func HealthCheck(db *sql.DB) error {
-    return db.Ping()
+// HealthCheck проверяет соединение с базой; ctx ограничивает время ожидания.
+func HealthCheck(ctx context.Context, db *sql.DB) error {
+    return db.PingContext(ctx)
 }
 
 func GetDBStats(db *sql.DB) sql.DBStats {
@@ -1,233 +1,231 @@
 package handlers
 
 import (
-    "database/sql"
-    "fmt"
+    "errors"
+    "log/slog"
     "net/http"
     "strconv"
+    "vend_erp/internal/credentials"
     "vend_erp/internal/models"
+    "vend_erp/internal/repository"
+    "vend_erp/internal/validate"
 )
 
 type UserHandler struct {
-    db       *sql.DB
+    users    repository.Users
+    orgs     repository.Organizations
     renderer *TemplateRenderer
+    creds    *credentials.Service
 }
 
-func NewUserHandler(db *sql.DB, renderer *TemplateRenderer) *UserHandler {
-    return &UserHandler{db: db, renderer: renderer}
+func NewUserHandler(users repository.Users, orgs repository.Organizations, renderer *TemplateRenderer, creds *credentials.Service) *UserHandler {
+    return &UserHandler{users: users, orgs: orgs, renderer: renderer, creds: creds}
+}
+
+// userRoles — допустимые значения users.userrole, в порядке формы
+var userRoles = []string{"user", "admin", "moderator", "agent", "support", "partner", "monitor"}
+
+// requireAdmin пропускает только администраторов организации и суперадминов.
+func requireAdmin(w http.ResponseWriter, r *http.Request) bool {
+    user := CurrentUser(r)
+    if user == nil || (user.UserRole != "admin" && !user.IsSuperAdmin) {
+        http.Error(w, "Доступ запрещен", http.StatusForbidden)
+        return false
+    }
+    return true
 }
 
 func (h *UserHandler) ListUsers(w http.ResponseWriter, r *http.Request) {
         w.Header().Set("Cache-Control", "no-cache, no-store, must-revalidate")
     w.Header().Set("Pragma", "no-cache")
     w.Header().Set("Expires", "0")
-    fmt.Printf("DEBUG: UserHandler.ListUsers called for URL: %s\n", r.URL.Path)
-    fmt.Printf("DEBUG: Method: %s, HTMX: %s\n", r.Method, r.Header.Get("HX-Request"))
     
-    rows, err := h.db.Query(`
-        SELECT 
-            id, username, email, userrole, status, lastipaddr,
-            fullusername, companyname, companyrole, phone, 
-            created_at, updated_at
-        FROM users 
-        ORDER BY created_at DESC
-    `)
+    scope := scopeFor(r)
+    accounts, err := h.users.List(r.Context(), scope)
     if err != nil {
-        fmt.Printf("DEBUG: User query error: %v\n", err)
-        http.Error(w, err.Error(), http.StatusInternalServerError)
+        serverError(w, r, err)
         return
     }
-    defer rows.Close()
-
-    var accounts []models.User
-    for rows.Next() {
-        var user models.User
-        var createdAt, updatedAt sql.NullTime
-        var lastIPAddr, fullUserName, companyName, companyRole, phone sql.NullString
-        
-        err := rows.Scan(
-            &user.ID, &user.Username, &user.Email, &user.UserRole, 
-            &user.Status, &lastIPAddr, &fullUserName, 
-            &companyName, &companyRole, &phone, &createdAt, &updatedAt,
-        )
-        if err != nil {
-            fmt.Printf("DEBUG: User scan error: %v\n", err)
-            continue
-        }
-        
-        // Handle nullable fields
-        if lastIPAddr.Valid {
-            user.LastIPAddr = lastIPAddr.String
-        }
-        if fullUserName.Valid {
-            user.FullUserName = fullUserName.String
-        }
-        if companyName.Valid {
-            user.CompanyName = companyName.String
-        }
-        if companyRole.Valid {
-            user.CompanyRole = companyRole.String
-        }
-        if phone.Valid {
-            user.Phone = phone.String
-        }
-        if createdAt.Valid {
-            user.CreatedAt = createdAt.Time
-        }
-        if updatedAt.Valid {
-            user.UpdatedAt = updatedAt.Time
+    
+    pendingCount := 0
+    for _, user := range accounts {
+        if user.Status == models.UserStatusPending {
+            pendingCount++
         }
-        
-        accounts = append(accounts, user)
     }
+    current := CurrentUser(r)
 
-    fmt.Printf("DEBUG: Loaded %d accounts\n", len(accounts))
+    slog.DebugContext(r.Context(), "loaded accounts", "count", len(accounts))
     
     data := map[string]interface{}{
-        "Users":  accounts,
-        "Active": "accounts",
-        "Title":  "Пользователи",
+        "Users":        accounts,
+        "PendingCount": pendingCount,
+        "CanManage":    current != nil && (current.UserRole == "admin" || current.IsSuperAdmin),
+        "SuperAdmin":   scope.SuperAdmin,
+        "AllOrgs":      scope.AllOrgs,
+        "Active":       "accounts",
+        "Title":        "Пользователи",
     }
     
     if r.Header.Get("HX-Request") == "true" {
-        fmt.Printf("DEBUG: Rendering acccounts_list.html for HTMX\n")
         h.renderer.Render(w, "accounts_list.html", data)
         return
     }
-    fmt.Printf("DEBUG: Rendering accounts_page.html for full page with %d accounts\n", len(accounts))
 
     h.renderer.Render(w, "accounts_page.html", data)
 }
 
 func (h *UserHandler) GetUserForm(w http.ResponseWriter, r *http.Request) {
-    fmt.Printf("DEBUG: UserHandler.GetUserForm called\n")
     idStr := r.URL.Query().Get("id")
     var user models.User
     
     if idStr != "" {
         id, _ := strconv.ParseInt(idStr, 10, 64)
-        var fullUserName, companyName, companyRole, phone sql.NullString
-        
-        err := h.db.QueryRow(`
-            SELECT id, username, email, userrole, status, 
-                   fullusername, companyname, companyrole, phone
-            FROM users WHERE id = $1
-        `, id).Scan(
-            &user.ID, &user.Username, &user.Email, &user.UserRole, 
-            &user.Status, &fullUserName, &companyName, &companyRole, &phone,
-        )
-        if err != nil && err != sql.ErrNoRows {
-            http.Error(w, err.Error(), http.StatusInternalServerError)
+        var err error
+        user, err = h.users.Get(r.Context(), scopeFor(r), id)
+        if err != nil && !errors.Is(err, repository.ErrNotFound) {
+            serverError(w, r, err)
             return
         }
-        
-        // Handle nullable fields
-        if fullUserName.Valid {
-            user.FullUserName = fullUserName.String
-        }
-        if companyName.Valid {
-            user.CompanyName = companyName.String
-        }
-        if companyRole.Valid {
-            user.CompanyRole = companyRole.String
-        }
-        if phone.Valid {
-            user.Phone = phone.String
+        if user.IsSuperAdmin && !scopeFor(r).SuperAdmin {
+            http.Error(w, superAdminOnly, http.StatusForbidden)
+            return
         }
+    } else {
+        // Пароль, заданный администратором, по умолчанию временный
+        user.Status = models.UserStatusActive
+        user.MustChangePassword = true
     }
     
+    h.renderForm(w, r, user, idStr != "", nil)
+}
+
+// renderForm показывает форму пользователя; непустая form — ответ на
+// неудачное сохранение с ошибками полей. Пароли в форму не возвращаются.
+func (h *UserHandler) renderForm(w http.ResponseWriter, r *http.Request, user models.User, edit bool, form *validate.Form) {
     data := map[string]interface{}{
-        "User": user,
-        "Edit": idStr != "",
+        "User":  user,
+        "Edit":  edit,
+        "Teams": listTeams(r, h.users),
+        "Policy": h.creds.Policy(),
+    }
+    
+    // Суперадмин может выбрать организацию пользователя
+    if current := CurrentUser(r); current != nil && current.IsSuperAdmin {
+        orgs, err := h.orgs.List(r.Context())
+        if err != nil {
+            serverError(w, r, err)
+            return
+        }
+        if user.OrgID == 0 {
+            user.OrgID = current.ActiveOrgID
+            data["User"] = user
+        }
+        data["Organizations"] = orgs
+    }
+    if form != nil {
+        h.renderer.RenderInvalid(w, modalBody, "account_form.html", data, form)
+        return
     }
     h.renderer.Render(w, "account_form.html", data)
 }
 
 func (h *UserHandler) SaveUser(w http.ResponseWriter, r *http.Request) {
-    fmt.Printf("DEBUG: UserHandler.SaveUser called\n")
+    if !requireAdmin(w, r) {
+        return
+    }
     if err := r.ParseForm(); err != nil {
         http.Error(w, err.Error(), http.StatusBadRequest)
         return
     }
     
-    idStr := r.FormValue("id")
-    status, _ := strconv.Atoi(r.FormValue("status"))
+    form := validate.New(r.PostForm)
+    form.Required("username", "email", "user_role", "status")
+    for _, field := range []string{"username", "email", "full_user_name", "company_name", "company_role", "team"} {
+        form.MaxLength(field, 255)
+    }
+    form.MaxLength("phone", 50)
+    form.Email("email")
+    form.Phone("phone")
+    form.OneOf("status", "0", "1", "2", "3")
+    scope := scopeFor(r)
     
     user := models.User{
-        Username:     r.FormValue("username"),
-        Email:        r.FormValue("email"),
-        UserRole:     r.FormValue("user_role"),
-        Status:       status,
-        FullUserName: r.FormValue("full_user_name"),
-        CompanyName:  r.FormValue("company_name"),
-        CompanyRole:  r.FormValue("company_role"),
-        Phone:        r.FormValue("phone"),
+        ID:           form.ID("id"),
+        Username:     form.Get("username"),
+        Email:        form.Get("email"),
+        UserRole:     form.OneOf("user_role", userRoles...),
+        Status:       form.Int("status"),
+        FullUserName: form.Get("full_user_name"),
+        CompanyName:  form.Get("company_name"),
+        CompanyRole:  form.Get("company_role"),
+        Phone:        form.Get("phone"),
+        Team:         form.Get("team"),
+        MustChangePassword: form.Get("must_change_password") == "true",
     }
     
-    var err error
-    if idStr == "" || idStr == "0" {
-        // Create new user
-        password := r.FormValue("password")
-        passwordConfirm := r.FormValue("password_confirm")
-        
-        if password != passwordConfirm {
-            http.Error(w, "Пароли не совпадают", http.StatusBadRequest)
-            return
+    // Организация по умолчанию — текущая; суперадмин может указать другую
+    orgID := scope.OrgID
+    var requestedOrg int64
+    if current := CurrentUser(r); current != nil && current.IsSuperAdmin {
+        if id := form.ID("org_id"); id > 0 {
+            orgID = id
+            requestedOrg = id
         }
-        
-        if password == "" {
-            http.Error(w, "Пароль обязателен", http.StatusBadRequest)
+    }
+    
+    // Пароль проверяется по политике и хешируется так же, как при регистрации
+    password := r.FormValue("password")
+    if password == "" {
+        form.Check(user.ID != 0, "password", "Пароль обязателен")
+    } else {
+        form.Check(password == r.FormValue("password_confirm"), "password_confirm", "Пароли не совпадают")
+        if err := h.creds.Validate(password, user.Username, user.Email); err != nil {
+            form.Fail("password", err.Error())
+        }
+    }
+    if !form.Valid() {
+        user.OrgID = orgID
+        h.renderForm(w, r, user, user.ID != 0, form)
+        return
+    }
+    
+    var passwordHash string
+    if password != "" {
+        hash, err := h.creds.Hash(password)
+        if err != nil {
+            serverError(w, r, err)
             return
         }
-        
-        // For now, store plain text password (NOT FOR PRODUCTION)
-        // In production, use proper hashing like bcrypt
-        _, err = h.db.Exec(`
-            INSERT INTO users (username, email, userrole, status, 
-                             fullusername, companyname, companyrole, phone, password)
-            VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
-        `, user.Username, user.Email, user.UserRole, user.Status,
-           nullIfEmpty(user.FullUserName), nullIfEmpty(user.CompanyName), 
-           nullIfEmpty(user.CompanyRole), nullIfEmpty(user.Phone), password)
+        passwordHash = hash
+    }
+    
+    if user.ID != 0 && !h.canManage(w, r, user.ID) {
+        return
+    }
+
+    var err error
+    if user.ID == 0 {
+        user.OrgID = orgID
+        err = h.users.Create(r.Context(), &user, passwordHash)
     } else {
-        // Update existing user
-        id, _ := strconv.ParseInt(idStr, 10, 64)
-        user.ID = id
-        
-        // Check if password is being updated
-        password := r.FormValue("password")
-        if password != "" {
-            passwordConfirm := r.FormValue("password_confirm")
-            if password != passwordConfirm {
-                http.Error(w, "Пароли не совпадают", http.StatusBadRequest)
-                return
-            }
-            
-            _, err = h.db.Exec(`
-                UPDATE users 
-                SET username=$1, email=$2, userrole=$3, status=$4, 
-                    fullusername=$5, companyname=$6, companyrole=$7, phone=$8,
-                    password=$9, updated_at=CURRENT_TIMESTAMP
-                WHERE id=$10
-            `, user.Username, user.Email, user.UserRole, user.Status,
-               nullIfEmpty(user.FullUserName), nullIfEmpty(user.CompanyName), 
-               nullIfEmpty(user.CompanyRole), nullIfEmpty(user.Phone), 
-               password, user.ID)
-        } else {
-            _, err = h.db.Exec(`
-                UPDATE users 
-                SET username=$1, email=$2, userrole=$3, status=$4, 
-                    fullusername=$5, companyname=$6, companyrole=$7, phone=$8,
-                    updated_at=CURRENT_TIMESTAMP
-                WHERE id=$9
-            `, user.Username, user.Email, user.UserRole, user.Status,
-               nullIfEmpty(user.FullUserName), nullIfEmpty(user.CompanyName), 
-               nullIfEmpty(user.CompanyRole), nullIfEmpty(user.Phone), user.ID)
-        }
+        // Организация меняется, только если ее выбрал суперадмин
+        user.OrgID = requestedOrg
+        err = h.users.Update(r.Context(), scope, user, passwordHash)
     }
     
+    if errors.Is(err, repository.ErrNotFound) {
+        http.Error(w, "Пользователь не найден", http.StatusNotFound)
+        return
+    }
+    if errors.Is(err, repository.ErrDuplicate) {
+        form.Fail("email", "Пользователь с таким email или именем уже существует")
+        user.OrgID = orgID
+        h.renderForm(w, r, user, user.ID != 0, form)
+        return
+    }
     if err != nil {
-        http.Error(w, "Database error: "+err.Error(), http.StatusInternalServerError)
+        serverError(w, r, err)
         return
     }
     
@@ -236,17 +234,21 @@ func (h *UserHandler) SaveUser(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *UserHandler) DeleteUser(w http.ResponseWriter, r *http.Request) {
-    fmt.Printf("DEBUG: UserHandler.DeleteUser called\n")
+    if !requireAdmin(w, r) {
+        return
+    }
     idStr := r.URL.Query().Get("id")
     id, err := strconv.ParseInt(idStr, 10, 64)
     if err != nil {
         http.Error(w, "Invalid ID", http.StatusBadRequest)
         return
     }
+    if !h.canManage(w, r, id) {
+        return
+    }
     
-    _, err = h.db.Exec("DELETE FROM users WHERE id = $1", id)
-    if err != nil {
-        http.Error(w, err.Error(), http.StatusInternalServerError)
+    if err := h.users.Delete(r.Context(), scopeFor(r), id); err != nil {
+        serverError(w, r, err)
         return
     }
     
@@ -255,6 +257,69 @@ func (h *UserHandler) DeleteUser(w http.ResponseWriter, r *http.Request) {
     h.ListUsers(w, r) 
 }
 
+// superAdminOnly — ответ администратору организации, который пытается
+// изменить учетную запись суперадмина.
+const superAdminOnly = "Учетную запись суперадмина может изменить только суперадмин"
+
+// canManage отказывает с 403, если пользователь id — суперадмин, а текущий
+// пользователь нет. Хранилище тоже не изменит такую запись, проверка здесь
+// нужна для понятного ответа.
+func (h *UserHandler) canManage(w http.ResponseWriter, r *http.Request, id int64) bool {
+    scope := scopeFor(r)
+    if scope.SuperAdmin {
+        return true
+    }
+    user, err := h.users.Get(r.Context(), scope, id)
+    if err != nil && !errors.Is(err, repository.ErrNotFound) {
+        serverError(w, r, err)
+        return false
+    }
+    if user.IsSuperAdmin {
+        http.Error(w, superAdminOnly, http.StatusForbidden)
+        return false
+    }
+    return true
+}
+
+// ApproveUser подтверждает ожидающую регистрацию.
+func (h *UserHandler) ApproveUser(w http.ResponseWriter, r *http.Request) {
+    h.reviewUser(w, r, models.UserStatusActive)
+}
+
+// RejectUser отклоняет ожидающую регистрацию. Запись сохраняется, чтобы
+// пользователь при входе увидел причину отказа, а email не занимали повторно.
+func (h *UserHandler) RejectUser(w http.ResponseWriter, r *http.Request) {
+    h.reviewUser(w, r, models.UserStatusRejected)
+}
+
+func (h *UserHandler) reviewUser(w http.ResponseWriter, r *http.Request, status int) {
+    if r.Method != http.MethodPost {
+        http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+        return
+    }
+    if !requireAdmin(w, r) {
+        return
+    }
+    
+    id, err := strconv.ParseInt(r.URL.Query().Get("id"), 10, 64)
+    if err != nil {
+        http.Error(w, "Invalid ID", http.StatusBadRequest)
+        return
+    }
+    
+    err = h.users.Review(r.Context(), scopeFor(r), id, status, CurrentUser(r).ID)
+    if errors.Is(err, repository.ErrNotFound) {
+        http.Error(w, "Заявка не найдена или уже рассмотрена", http.StatusConflict)
+        return
+    }
+    if err != nil {
+        serverError(w, r, err)
+        return
+    }
+    
+    h.ListUsers(w, r)
+}
+
 // Helper function for empty strings
 func nullIfEmpty(s string) interface{} {
     if s == "" {
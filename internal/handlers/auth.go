@@ -4,35 +4,90 @@ import (
     "database/sql"
     "crypto/rand"
     "encoding/hex"
+    "errors"
     "net/http"
+    "strings"
     "time"
-    "golang.org/x/crypto/bcrypt"
+    "vend_erp/config"
+    "vend_erp/internal/credentials"
+    "vend_erp/internal/logging"
+    "vend_erp/internal/models"
+    "vend_erp/internal/repository"
 )
 
 type AuthHandler struct {
     db       *sql.DB
+    users    repository.Users
+    sessions repository.Sessions
+    invites  repository.Invites
     renderer *TemplateRenderer
+    creds    *credentials.Service
+    ssoName  string
+    // signupMode — один из config.SignupOpen, SignupInvite, SignupApproval
+    signupMode string
+    // sessionLifetime — срок жизни сессии после входа
+    sessionLifetime time.Duration
 }
 
-func NewAuthHandler(db *sql.DB, renderer *TemplateRenderer) *AuthHandler {
-    return &AuthHandler{db: db, renderer: renderer}
+func NewAuthHandler(db *sql.DB, users repository.Users, sessions repository.Sessions, invites repository.Invites, renderer *TemplateRenderer, creds *credentials.Service) *AuthHandler {
+    return &AuthHandler{db: db, users: users, sessions: sessions, invites: invites, renderer: renderer, creds: creds, signupMode: config.SignupApproval, sessionLifetime: 24 * time.Hour}
 }
 
-// Session represents a user session
-type Session struct {
-    ID        string
-    UserID    int64
-    ExpiresAt time.Time
+// SetSessionLifetime задает срок жизни новых сессий.
+func (h *AuthHandler) SetSessionLifetime(lifetime time.Duration) {
+    h.sessionLifetime = lifetime
+}
+
+// SetSignupMode задает режим самостоятельной регистрации.
+func (h *AuthHandler) SetSignupMode(mode string) {
+    h.signupMode = mode
+}
+
+// EnableSSO показывает на странице входа кнопку входа через OIDC провайдер.
+func (h *AuthHandler) EnableSSO(providerName string) {
+    h.ssoName = providerName
 }
 
 // AuthData represents authentication form data
 type AuthData struct {
     SignUp   bool
+    // ChangePassword — форма смены пароля вместо входа/регистрации
+    ChangePassword bool
     Email    string
     Username string
     Error    string
     Title    string
     Active   string
+    SSOName  string
+    Message  string
+
+    // Регистрация по приглашению
+    InviteToken string
+    InviteOrg   string
+    // EmailLocked — приглашение выписано на конкретный email
+    EmailLocked bool
+    // InviteOnly — регистрация без приглашения выключена
+    InviteOnly bool
+    // SignupClosed скрывает форму регистрации
+    SignupClosed bool
+}
+
+// statusError объясняет, почему пользователь с таким статусом не может войти.
+func statusError(status int) string {
+    switch status {
+    case models.UserStatusPending:
+        return "Заявка на регистрацию ожидает подтверждения администратором"
+    case models.UserStatusRejected:
+        return "Заявка на регистрацию отклонена"
+    default:
+        return "Аккаунт неактивен"
+    }
+}
+
+// signInMessages — сообщения для параметра ?message= страницы входа
+var signInMessages = map[string]string{
+    "registered": "Аккаунт создан. Войдите, используя email и пароль",
+    "pending":    "Заявка отправлена. Вход станет доступен после подтверждения администратором",
 }
 
 // generateSessionID generates a random session ID
@@ -47,11 +102,12 @@ func generateSessionID() (string, error) {
 func (h *AuthHandler) SignIn(w http.ResponseWriter, r *http.Request) {
     if r.Method == http.MethodGet {
         data := AuthData{
-            SignUp: false,
-            Title:  "Вход в систему",
-            Active: "auth",
+            SignUp:  false,
+            Title:   "Вход в систему",
+            Active:  "auth",
+            Message: signInMessages[r.URL.Query().Get("message")],
         }
-        h.renderer.Render(w, "auth.html", data)
+        h.renderAuth(w, data)
         return
     }
     
@@ -63,14 +119,11 @@ func (h *AuthHandler) SignIn(w http.ResponseWriter, r *http.Request) {
     email := r.FormValue("email")
     password := r.FormValue("password")
     
-    var userID int64
-    var hashedPassword, username string
-    var status int
-    
-    err := h.db.QueryRow(`
-        SELECT id, username, password, status 
-        FROM users WHERE email = $1
-    `, email).Scan(&userID, &username, &hashedPassword, &status)
+    user, err := h.users.FindByEmail(r.Context(), email)
+    var storedPassword string
+    if err == nil {
+        storedPassword, err = h.users.PasswordHash(r.Context(), user.ID)
+    }
     
     if err != nil {
         data := AuthData{
@@ -80,57 +133,61 @@ func (h *AuthHandler) SignIn(w http.ResponseWriter, r *http.Request) {
             Title:  "Вход в систему",
             Active: "auth",
         }
-        h.renderer.Render(w, "auth.html", data)
+        h.renderAuth(w, data)
         return
     }
     
-    // Check if user is active
-    if status != 1 {
+    if !h.creds.Verify(user.ID, storedPassword, password) {
         data := AuthData{
             SignUp: false,
             Email:  email,
-            Error:  "Аккаунт неактивен",
+            Error:  "Неверный email или пароль",
             Title:  "Вход в систему",
             Active: "auth",
         }
-        h.renderer.Render(w, "auth.html", data)
+        h.renderAuth(w, data)
         return
     }
     
-    // Verify password using bcrypt
-    err = bcrypt.CompareHashAndPassword([]byte(hashedPassword), []byte(password))
-    if err != nil {
+    // Статус проверяем после пароля, чтобы не раскрывать его посторонним
+    if user.Status != models.UserStatusActive {
         data := AuthData{
             SignUp: false,
             Email:  email,
-            Error:  "Неверный email или пароль",
+            Error:  statusError(user.Status),
             Title:  "Вход в систему",
             Active: "auth",
         }
-        h.renderer.Render(w, "auth.html", data)
+        h.renderAuth(w, data)
         return
     }
     
-    // Create session
-    sessionID, err := generateSessionID()
-    if err != nil {
-        http.Error(w, "Ошибка создания сессии", http.StatusInternalServerError)
+    if err := h.startSession(w, r, user.ID); err != nil {
+        serverError(w, r, err)
         return
     }
     
-    expiresAt := time.Now().Add(24 * time.Hour)
+    if user.MustChangePassword {
+        http.Redirect(w, r, "/auth/change-password", http.StatusSeeOther)
+        return
+    }
+    http.Redirect(w, r, "/dashboard", http.StatusSeeOther)
+}
+
+// startSession создает сессию пользователя и выставляет cookie
+func (h *AuthHandler) startSession(w http.ResponseWriter, r *http.Request, userID int64) error {
+    sessionID, err := generateSessionID()
+    if err != nil {
+        return err
+    }
     
-    _, err = h.db.Exec(`
-        INSERT INTO sessions (id, user_id, expires_at) 
-        VALUES ($1, $2, $3)
-    `, sessionID, userID, expiresAt)
+    expiresAt := time.Now().Add(h.sessionLifetime)
     
+    err = h.sessions.Create(r.Context(), models.Session{ID: sessionID, UserID: userID, ExpiresAt: expiresAt})
     if err != nil {
-        http.Error(w, "Ошибка создания сессии", http.StatusInternalServerError)
-        return
+        return err
     }
     
-    // Set session cookie
     http.SetCookie(w, &http.Cookie{
         Name:     "session_id",
         Value:    sessionID,
@@ -139,8 +196,13 @@ func (h *AuthHandler) SignIn(w http.ResponseWriter, r *http.Request) {
         HttpOnly: true,
         Secure:   false,
     })
-    
-    http.Redirect(w, r, "/dashboard", http.StatusSeeOther)
+    return nil
+}
+
+func (h *AuthHandler) renderAuth(w http.ResponseWriter, data AuthData) {
+    data.SSOName = h.ssoName
+    data.InviteOnly = h.signupMode == config.SignupInvite
+    h.renderer.Render(w, "auth.html", data)
 }
 
 func (h *AuthHandler) SignUp(w http.ResponseWriter, r *http.Request) {
@@ -150,7 +212,22 @@ func (h *AuthHandler) SignUp(w http.ResponseWriter, r *http.Request) {
             Title:  "Регистрация",
             Active: "auth",
         }
-        h.renderer.Render(w, "auth.html", data)
+        if token := r.URL.Query().Get("invite"); token != "" {
+            invite, err := findInvite(r.Context(), h.invites, token)
+            if err != nil || !invite.Usable() {
+                data.Error = "Приглашение недействительно или истекло"
+                data.SignupClosed = h.signupMode == config.SignupInvite
+            } else {
+                data.InviteToken = token
+                data.InviteOrg = invite.OrgName
+                data.Email = invite.Email
+                data.EmailLocked = invite.Email != ""
+            }
+        } else if h.signupMode == config.SignupInvite {
+            data.Message = "Регистрация доступна только по приглашению администратора"
+            data.SignupClosed = true
+        }
+        h.renderAuth(w, data)
         return
     }
     
@@ -163,94 +240,235 @@ func (h *AuthHandler) SignUp(w http.ResponseWriter, r *http.Request) {
     username := r.FormValue("username")
     password := r.FormValue("password")
     passwordConfirm := r.FormValue("password_confirm")
+    token := r.FormValue("invite")
+    
+    data := AuthData{
+        SignUp:      true,
+        Email:       email,
+        Username:    username,
+        Title:       "Регистрация",
+        Active:      "auth",
+        InviteToken: token,
+    }
+    fail := func(message string) {
+        data.Error = message
+        h.renderAuth(w, data)
+    }
+    
+    // Без приглашения: роль user, организация по умолчанию
+    var invite *models.Invite
+    if token != "" {
+        var err error
+        invite, err = findInvite(r.Context(), h.invites, token)
+        if err != nil || !invite.Usable() {
+            data.InviteToken = ""
+            data.SignupClosed = h.signupMode == config.SignupInvite
+            fail("Приглашение недействительно или истекло")
+            return
+        }
+        data.InviteOrg = invite.OrgName
+        data.EmailLocked = invite.Email != ""
+        if invite.Email != "" && !strings.EqualFold(email, invite.Email) {
+            data.Email = invite.Email
+            fail("Приглашение выписано на другой email")
+            return
+        }
+    } else if h.signupMode == config.SignupInvite {
+        data.SignupClosed = true
+        fail("Регистрация доступна только по приглашению администратора")
+        return
+    }
     
     // Validate input
     if password != passwordConfirm {
-        data := AuthData{
-            SignUp:   true,
-            Email:    email,
-            Username: username,
-            Error:    "Пароли не совпадают",
-            Title:    "Регистрация",
-            Active:   "auth",
-        }
-        h.renderer.Render(w, "auth.html", data)
+        fail("Пароли не совпадают")
         return
     }
     
-    if len(password) < 6 {
-        data := AuthData{
-            SignUp:   true,
-            Email:    email,
-            Username: username,
-            Error:    "Пароль должен содержать минимум 6 символов",
-            Title:    "Регистрация",
-            Active:   "auth",
-        }
-        h.renderer.Render(w, "auth.html", data)
+    if err := h.creds.Validate(password, username, email); err != nil {
+        fail(err.Error())
         return
     }
     
     // Check if user already exists
-    var exists bool
-    h.db.QueryRow("SELECT EXISTS(SELECT 1 FROM users WHERE email = $1 OR username = $2)", 
-        email, username).Scan(&exists)
+    exists, err := h.users.Exists(r.Context(), email, username)
+    if err != nil {
+        serverError(w, r, err)
+        return
+    }
     
     if exists {
-        data := AuthData{
-            SignUp:   true,
-            Email:    email,
-            Username: username,
-            Error:    "Пользователь с таким email или именем уже существует",
-            Title:    "Регистрация",
-            Active:   "auth",
+        fail("Пользователь с таким email или именем уже существует")
+        return
+    }
+    
+    hashedPassword, err := h.creds.Hash(password)
+    if err != nil {
+        fail("Ошибка создания аккаунта")
+        return
+    }
+    
+    status := models.UserStatusActive
+    if invite == nil && h.signupMode == config.SignupApproval {
+        status = models.UserStatusPending
+    }
+    
+    if err := h.createSignedUpUser(username, email, hashedPassword, status, invite); err != nil {
+        if err == errInviteUsed {
+            data.InviteToken = ""
+            fail("Приглашение уже использовано")
+            return
         }
-        h.renderer.Render(w, "auth.html", data)
+        fail("Ошибка создания аккаунта")
         return
     }
     
-    // Hash password using bcrypt
-    hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+    if status == models.UserStatusPending {
+        http.Redirect(w, r, "/auth/signin?message=pending", http.StatusSeeOther)
+        return
+    }
+    http.Redirect(w, r, "/auth/signin?message=registered", http.StatusSeeOther)
+}
+
+var errInviteUsed = errors.New("invite already used")
+
+// createSignedUpUser создает пользователя и, если он пришел по приглашению,
+// в той же транзакции погашает приглашение: одно приглашение — один аккаунт.
+func (h *AuthHandler) createSignedUpUser(username, email, passwordHash string, status int, invite *models.Invite) error {
+    tx, err := h.db.Begin()
     if err != nil {
-        data := AuthData{
-            SignUp:   true,
-            Email:    email,
-            Username: username,
-            Error:    "Ошибка создания аккаунта",
-            Title:    "Регистрация",
-            Active:   "auth",
+        return err
+    }
+    defer tx.Rollback()
+    
+    role, team := "user", ""
+    var orgID interface{}
+    if invite != nil {
+        role, team, orgID = invite.UserRole, invite.Team, invite.OrgID
+    }
+    
+    var userID int64
+    err = tx.QueryRow(`
+        INSERT INTO users (username, email, password, userrole, team, status, org_id,
+                           password_changed_at, created_at, updated_at)
+        VALUES ($1, $2, $3, $4, $5, $6,
+                COALESCE($7::bigint, (SELECT id FROM organizations WHERE slug = $8)),
+                CURRENT_TIMESTAMP, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)
+        RETURNING id
+    `, username, email, passwordHash, role, nullIfEmpty(team), status, orgID, defaultOrgSlug).Scan(&userID)
+    if err != nil {
+        return err
+    }
+    
+    if invite != nil {
+        result, err := tx.Exec(`
+            UPDATE invites SET used_at = CURRENT_TIMESTAMP, used_by = $1
+            WHERE id = $2 AND used_at IS NULL AND revoked_at IS NULL
+              AND expires_at > CURRENT_TIMESTAMP
+        `, userID, invite.ID)
+        if err != nil {
+            return err
+        }
+        if n, _ := result.RowsAffected(); n == 0 {
+            return errInviteUsed
         }
-        h.renderer.Render(w, "auth.html", data)
+    }
+    
+    _, err = tx.Exec(`
+        INSERT INTO user_organizations (user_id, org_id)
+        SELECT id, org_id FROM users WHERE id = $1
+        ON CONFLICT DO NOTHING
+    `, userID)
+    if err != nil {
+        return err
+    }
+    
+    return tx.Commit()
+}
+
+// ChangePassword меняет пароль текущего пользователя. Сюда же перенаправляются
+// пользователи с флагом must_change_password, пока не сменят пароль.
+func (h *AuthHandler) ChangePassword(w http.ResponseWriter, r *http.Request) {
+    user := CurrentUser(r)
+    data := AuthData{
+        ChangePassword: true,
+        Email:          user.Email,
+        Title:          "Смена пароля",
+        Active:         "auth",
+    }
+    if user.MustChangePassword {
+        data.Message = "Администратор требует сменить пароль перед началом работы"
+    }
+    
+    if r.Method == http.MethodGet {
+        h.renderAuth(w, data)
+        return
+    }
+    
+    if err := r.ParseForm(); err != nil {
+        http.Error(w, err.Error(), http.StatusBadRequest)
         return
     }
     
-    // Create user
-    _, err = h.db.Exec(`
-        INSERT INTO users (username, email, password, userrole, status, created_at, updated_at)
-        VALUES ($1, $2, $3, $4, $5, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)
-    `, username, email, string(hashedPassword), "user", 1)
+    current := r.FormValue("current_password")
+    password := r.FormValue("password")
     
+    stored, err := h.users.PasswordHash(r.Context(), user.ID)
     if err != nil {
-        data := AuthData{
-            SignUp:   true,
-            Email:    email,
-            Username: username,
-            Error:    "Ошибка создания аккаунта",
-            Title:    "Регистрация",
-            Active:   "auth",
+        serverError(w, r, err)
+        return
+    }
+    
+    switch {
+    case !h.creds.Verify(user.ID, stored, current):
+        data.Error = "Текущий пароль указан неверно"
+    case password != r.FormValue("password_confirm"):
+        data.Error = "Пароли не совпадают"
+    case password == current:
+        data.Error = "Новый пароль должен отличаться от текущего"
+    default:
+        if err := h.creds.SetPassword(user.ID, password, false, user.Username, user.Email); err != nil {
+            data.Error = err.Error()
         }
-        h.renderer.Render(w, "auth.html", data)
+    }
+    if data.Error != "" {
+        h.renderAuth(w, data)
         return
     }
     
-    http.Redirect(w, r, "/auth/signin?message=registered", http.StatusSeeOther)
+    // Остальные сессии пользователя завершаются
+    if cookie, err := r.Cookie("session_id"); err == nil {
+        h.sessions.DeleteOthers(r.Context(), user.ID, cookie.Value)
+    }
+    
+    http.Redirect(w, r, "/dashboard", http.StatusSeeOther)
+}
+
+// PasswordStrength отдает индикатор надежности пароля для форм.
+func (h *AuthHandler) PasswordStrength(w http.ResponseWriter, r *http.Request) {
+    if err := r.ParseForm(); err != nil {
+        http.Error(w, err.Error(), http.StatusBadRequest)
+        return
+    }
+    
+    password := r.FormValue("password")
+    data := map[string]interface{}{
+        "Empty":    password == "",
+        "Strength": credentials.Score(password, r.FormValue("username"), r.FormValue("email")),
+    }
+    if password != "" {
+        if err := h.creds.Validate(password, r.FormValue("username"), r.FormValue("email")); err != nil {
+            data["Problems"] = err.(*credentials.PolicyError).Problems
+        }
+    }
+    h.renderer.Render(w, "password_strength.html", data)
 }
 
 func (h *AuthHandler) SignOut(w http.ResponseWriter, r *http.Request) {
     cookie, err := r.Cookie("session_id")
     if err == nil {
         // Delete session from database
-        h.db.Exec("DELETE FROM sessions WHERE id = $1", cookie.Value)
+        h.sessions.Delete(r.Context(), cookie.Value)
         
         // Clear cookie
         http.SetCookie(w, &http.Cookie{
@@ -271,49 +489,53 @@ func (h *AuthHandler) GetUserFromSession(r *http.Request) (*User, error) {
         return nil, err
     }
     
-    var userID int64
-    var expiresAt time.Time
-    
-    err = h.db.QueryRow(`
-        SELECT user_id, expires_at 
-        FROM sessions 
-        WHERE id = $1 AND expires_at > CURRENT_TIMESTAMP
-    `, cookie.Value).Scan(&userID, &expiresAt)
-    
+    session, err := h.sessions.Get(r.Context(), cookie.Value)
     if err != nil {
         return nil, err
     }
     
-    var user User
-    var fullUserName, companyName, companyRole, phone sql.NullString
-    
-    err = h.db.QueryRow(`
-        SELECT id, username, email, userrole, status, 
-               fullusername, companyname, companyrole, phone
-        FROM users 
-        WHERE id = $1 AND status = 1
-    `, userID).Scan(
-        &user.ID, &user.Username, &user.Email, &user.UserRole, 
-        &user.Status, &fullUserName, &companyName, &companyRole, &phone,
-    )
-    
+    account, err := h.users.Get(r.Context(), repository.Scope{AllOrgs: true}, session.UserID)
     if err != nil {
         return nil, err
     }
-    
-    // Handle nullable fields
-    if fullUserName.Valid {
-        user.FullUserName = fullUserName.String
-    }
-    if companyName.Valid {
-        user.CompanyName = companyName.String
+    if account.Status != models.UserStatusActive {
+        return nil, errors.New("user is not active")
     }
-    if companyRole.Valid {
-        user.CompanyRole = companyRole.String
+    
+    user := User{
+        ID:                 account.ID,
+        Username:           account.Username,
+        Email:              account.Email,
+        UserRole:           account.UserRole,
+        Status:             account.Status,
+        FullUserName:       account.FullUserName,
+        CompanyName:        account.CompanyName,
+        CompanyRole:        account.CompanyRole,
+        Phone:              account.Phone,
+        OrgID:              account.OrgID,
+        OrgName:            account.OrgName,
+        IsSuperAdmin:       account.IsSuperAdmin,
+        MustChangePassword: account.MustChangePassword,
     }
-    if phone.Valid {
-        user.Phone = phone.String
+    
+    // Организация, выбранная в сессии, действует только при наличии доступа к ней
+    user.ActiveOrgID = user.OrgID
+    user.ActiveOrgName = user.OrgName
+    if session.ActiveOrgID != 0 && session.ActiveOrgID != user.OrgID {
+        var orgName string
+        err := h.db.QueryRowContext(r.Context(), `
+            SELECT o.name FROM organizations o
+            WHERE o.id = $1 AND o.is_active = true
+              AND ($2 OR EXISTS (
+                  SELECT 1 FROM user_organizations uo
+                  WHERE uo.org_id = o.id AND uo.user_id = $3))
+        `, session.ActiveOrgID, user.IsSuperAdmin, user.ID).Scan(&orgName)
+        if err == nil {
+            user.ActiveOrgID = session.ActiveOrgID
+            user.ActiveOrgName = orgName
+        }
     }
+    user.AllOrgs = session.AllOrgs && user.IsSuperAdmin
     
     return &user, nil
 }
@@ -325,7 +547,17 @@ func (h *AuthHandler) RequireAuth(next http.HandlerFunc) http.HandlerFunc {
             http.Redirect(w, r, "/auth/signin", http.StatusSeeOther)
             return
         }
-        next(w, r)
+        // Пока пароль не сменен, доступна только страница смены пароля
+        if user.MustChangePassword && r.URL.Path != "/auth/change-password" {
+            if r.Header.Get("HX-Request") == "true" {
+                w.Header().Set("HX-Redirect", "/auth/change-password")
+                return
+            }
+            http.Redirect(w, r, "/auth/change-password", http.StatusSeeOther)
+            return
+        }
+        logging.SetUserID(r.Context(), user.ID)
+        next(w, r.WithContext(WithUser(r.Context(), user)))
     }
 }
 
@@ -340,4 +572,16 @@ type User struct {
     CompanyName  string
     CompanyRole  string
     Phone        string
+
+    // Домашняя организация пользователя
+    OrgID   int64
+    OrgName string
+    // Организация, в которой пользователь сейчас работает
+    ActiveOrgID   int64
+    ActiveOrgName string
+    IsSuperAdmin  bool
+    // AllOrgs — сводный режим суперадмина по всем организациям
+    AllOrgs bool
+    // MustChangePassword — пароль задан администратором и должен быть сменен
+    MustChangePassword bool
 }
\ No newline at end of file
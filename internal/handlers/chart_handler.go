@@ -1,31 +1,34 @@
 package handlers
 
 import (
-	"database/sql"
+	"context"
 	"encoding/json"
 	"net/http"
 	"time"
+
+	"vend_erp/internal/money"
+	"vend_erp/internal/repository"
 )
 
 // ChartHandler обрабатывает данные для графиков и диаграмм
 type ChartHandler struct {
-	db *sql.DB
+	reports repository.Reports
 }
 
 // NewChartHandler создает новый обработчик графиков
-func NewChartHandler(db *sql.DB) *ChartHandler {
-	return &ChartHandler{db: db}
+func NewChartHandler(reports repository.Reports) *ChartHandler {
+	return &ChartHandler{reports: reports}
 }
 
 // Структуры данных для графиков
 
 // ChartDataPoint представляет точку данных на графике
 type ChartDataPoint struct {
-	Date       string  `json:"date"`
-	Label      string  `json:"label"`
-	Count      int     `json:"count"`
-	Percentage float64 `json:"percentage"`
-	Value      float64 `json:"value,omitempty"`
+	Date       string       `json:"date"`
+	Label      string       `json:"label"`
+	Count      int          `json:"count"`
+	Percentage float64      `json:"percentage"`
+	Value      money.Amount `json:"value,omitempty"` // для денежных графиков
 }
 
 // ChartSeries представляет серию данных для графика
@@ -41,66 +44,55 @@ type ChartResponse struct {
 	Series        []ChartSeries `json:"series"`
 	Labels        []string     `json:"labels"`
 	Total         int          `json:"total"`
+	// Amount — точный итог денежного графика; Total для него содержит целые рубли
+	Amount        money.Amount `json:"amount,omitempty"`
 	Change        int          `json:"change"`
 	ChangePercent float64      `json:"change_percent"`
 	Trend         int          `json:"trend"` // -1 = down, 0 = stable, 1 = up
 	Period        string       `json:"period"`
 }
 
-// MachineChartData возвращает данные для графика автоматов за последние 30 дней
-func (h *ChartHandler) GetMachinesChartData() (*ChartResponse, error) {
-	endDate := time.Now()
-	startDate := endDate.AddDate(0, 0, -30)
-
-	// Получаем количество автоматов по дням
-	query := `
-		WITH date_series AS (
-			SELECT generate_series($1::date, $2::date, '1 day')::date as chart_date
-		),
-		daily_counts AS (
-			SELECT 
-				ds.chart_date,
-				COUNT(DISTINCT vm.id) as machine_count
-			FROM date_series ds
-			LEFT JOIN vending_machines vm ON date(vm.created_at) <= ds.chart_date
-			GROUP BY ds.chart_date
-			ORDER BY ds.chart_date
-		)
-		SELECT 
-			chart_date,
-			machine_count
-		FROM daily_counts
-		ORDER BY chart_date
-	`
-
-	rows, err := h.db.Query(query, startDate, endDate)
+// machineHistory возвращает показатели автоматов по дням за последние 30 дней
+// и их текущие значения
+func (h *ChartHandler) machineHistory(ctx context.Context, scope OrgScope) (history []repository.MachineDay, totals repository.MachineTotals, startDate, endDate time.Time, err error) {
+	endDate = time.Now()
+	startDate = endDate.AddDate(0, 0, -30)
+
+	history, err = h.reports.MachineHistory(ctx, scope, startDate, endDate)
 	if err != nil {
-		return nil, err
+		return nil, totals, startDate, endDate, err
 	}
-	defer rows.Close()
+	totals, err = h.reports.Machines(ctx, scope)
+	return history, totals, startDate, endDate, err
+}
 
+// countSeries строит точки графика целых значений
+func (h *ChartHandler) countSeries(dates []time.Time, counts []int, startDate, endDate time.Time) []ChartDataPoint {
 	var dataPoints []ChartDataPoint
-	var counts []int
-	var dates []time.Time
-
-	for rows.Next() {
-		var date time.Time
-		var count int
-		if err := rows.Scan(&date, &count); err != nil {
-			continue
-		}
-
-		counts = append(counts, count)
-		dates = append(dates, date)
-
-		label := h.formatDateLabel(date, startDate, endDate)
-
+	for i, date := range dates {
 		dataPoints = append(dataPoints, ChartDataPoint{
 			Date:  date.Format("2006-01-02"),
-			Label: label,
-			Count: count,
+			Label: h.formatDateLabel(date, startDate, endDate),
+			Count: counts[i],
 		})
 	}
+	return dataPoints
+}
+
+// MachineChartData возвращает данные для графика автоматов за последние 30 дней
+func (h *ChartHandler) GetMachinesChartData(ctx context.Context, scope OrgScope) (*ChartResponse, error) {
+	history, totals, startDate, endDate, err := h.machineHistory(ctx, scope)
+	if err != nil {
+		return nil, err
+	}
+
+	var counts []int
+	var dates []time.Time
+	for _, day := range history {
+		counts = append(counts, day.Machines)
+		dates = append(dates, day.Date)
+	}
+	dataPoints := h.countSeries(dates, counts, startDate, endDate)
 
 	// Вычисляем проценты для высоты столбцов
 	if len(counts) > 0 {
@@ -116,10 +108,6 @@ func (h *ChartHandler) GetMachinesChartData() (*ChartResponse, error) {
 		}
 	}
 
-	// Получаем текущее общее количество автоматов
-	var totalMachines int
-	h.db.QueryRow("SELECT COUNT(*) FROM vending_machines").Scan(&totalMachines)
-
 	// Рассчитываем изменения и тренд
 	change, changePercent, trend := h.calculateMetrics(counts)
 
@@ -136,7 +124,7 @@ func (h *ChartHandler) GetMachinesChartData() (*ChartResponse, error) {
 			},
 		},
 		Labels:        labels,
-		Total:         totalMachines,
+		Total:         totals.Count,
 		Change:        change,
 		ChangePercent: changePercent,
 		Trend:         trend,
@@ -147,51 +135,25 @@ func (h *ChartHandler) GetMachinesChartData() (*ChartResponse, error) {
 }
 
 // GetOperationsChartData возвращает данные для графика операций
-func (h *ChartHandler) GetOperationsChartData(days int) (*ChartResponse, error) {
+func (h *ChartHandler) GetOperationsChartData(ctx context.Context, scope OrgScope, days int) (*ChartResponse, error) {
 	endDate := time.Now()
 	startDate := endDate.AddDate(0, 0, -days)
 
-	query := `
-		SELECT 
-			date(created_at) as op_date,
-			operation_type,
-			COUNT(*) as operation_count
-		FROM vending_operations
-		WHERE created_at >= $1 AND created_at <= $2
-		GROUP BY date(created_at), operation_type
-		ORDER BY op_date, operation_type
-	`
-
-	rows, err := h.db.Query(query, startDate, endDate)
+	history, err := h.reports.OperationHistory(ctx, scope, startDate, endDate)
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
 
 	// Группируем данные по типам операций
 	operationsByType := make(map[string][]ChartDataPoint)
-	dateSet := make(map[string]bool)
-
-	for rows.Next() {
-		var opDate time.Time
-		var opType string
-		var count int
-		if err := rows.Scan(&opDate, &opType, &count); err != nil {
-			continue
-		}
-
-		dateStr := opDate.Format("2006-01-02")
-		dateSet[dateStr] = true
-
-		if _, exists := operationsByType[opType]; !exists {
-			operationsByType[opType] = []ChartDataPoint{}
-		}
-
-		label := h.formatDateLabel(opDate, startDate, endDate)
-		operationsByType[opType] = append(operationsByType[opType], ChartDataPoint{
-			Date:  dateStr,
-			Label: label,
-			Count: count,
+	totalOps := 0
+
+	for _, day := range history {
+		totalOps += day.Count
+		operationsByType[day.OperationType] = append(operationsByType[day.OperationType], ChartDataPoint{
+			Date:  day.Date.Format("2006-01-02"),
+			Label: h.formatDateLabel(day.Date, startDate, endDate),
+			Count: day.Count,
 		})
 	}
 
@@ -249,10 +211,6 @@ func (h *ChartHandler) GetOperationsChartData(days int) (*ChartResponse, error)
 		})
 	}
 
-	// Суммарная статистика операций
-	var totalOps int
-	h.db.QueryRow("SELECT COUNT(*) FROM vending_operations WHERE created_at >= $1", startDate).Scan(&totalOps)
-
 	// Создаем метки
 	labels := h.generateChartLabels(allDates)
 
@@ -268,61 +226,37 @@ func (h *ChartHandler) GetOperationsChartData(days int) (*ChartResponse, error)
 }
 
 // GetRevenueChartData возвращает данные для графика выручки
-func (h *ChartHandler) GetRevenueChartData(days int) (*ChartResponse, error) {
+func (h *ChartHandler) GetRevenueChartData(ctx context.Context, scope OrgScope, days int) (*ChartResponse, error) {
 	endDate := time.Now()
 	startDate := endDate.AddDate(0, 0, -days)
 
-	query := `
-		SELECT 
-			date(operation_date) as revenue_date,
-			SUM(cash_collected) as daily_revenue
-		FROM vending_operations
-		WHERE operation_type = 'collection' 
-		  AND operation_date >= $1 
-		  AND operation_date <= $2
-		GROUP BY date(operation_date)
-		ORDER BY revenue_date
-	`
-
-	rows, err := h.db.Query(query, startDate, endDate)
+	history, err := h.reports.Revenue(ctx, scope, startDate, endDate)
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
 
 	var dataPoints []ChartDataPoint
 	var dates []time.Time
-	var revenues []float64
+	var revenues []money.Amount
 
-	for rows.Next() {
-		var date time.Time
-		var revenue sql.NullFloat64
-		if err := rows.Scan(&date, &revenue); err != nil {
-			continue
-		}
-
-		rev := 0.0
-		if revenue.Valid {
-			rev = revenue.Float64
-		}
+	for _, day := range history {
+		dates = append(dates, day.Date)
+		revenues = append(revenues, day.Amount)
 
-		dates = append(dates, date)
-		revenues = append(revenues, rev)
-
-		label := h.formatDateLabel(date, startDate, endDate)
+		label := h.formatDateLabel(day.Date, startDate, endDate)
 		dataPoints = append(dataPoints, ChartDataPoint{
-			Date:  date.Format("2006-01-02"),
+			Date:  day.Date.Format("2006-01-02"),
 			Label: label,
-			Value: rev,
+			Value: day.Amount,
 		})
 	}
 
 	// Вычисляем проценты
 	if len(revenues) > 0 {
-		maxRev := h.getMaxFloat(revenues)
+		maxRev := h.getMaxAmount(revenues)
 		if maxRev > 0 {
 			for i := range dataPoints {
-				dataPoints[i].Percentage = revenues[i] / maxRev * 100
+				dataPoints[i].Percentage = float64(revenues[i]) / float64(maxRev) * 100
 				if dataPoints[i].Percentage < 5 {
 					dataPoints[i].Percentage = 5
 				}
@@ -331,11 +265,10 @@ func (h *ChartHandler) GetRevenueChartData(days int) (*ChartResponse, error) {
 	}
 
 	// Общая выручка за период
-	var totalRevenue float64
-	h.db.QueryRow("SELECT COALESCE(SUM(cash_collected), 0) FROM vending_operations WHERE operation_type = 'collection' AND operation_date >= $1", startDate).Scan(&totalRevenue)
+	totalRevenue := money.Sum(revenues...)
 
 	// Рассчитываем изменения
-	change, changePercent, trend := h.calculateFloatMetrics(revenues)
+	change, changePercent, trend := h.calculateAmountMetrics(revenues)
 
 	labels := h.generateChartLabels(dates)
 
@@ -349,8 +282,9 @@ func (h *ChartHandler) GetRevenueChartData(days int) (*ChartResponse, error) {
 			},
 		},
 		Labels:        labels,
-		Total:         int(totalRevenue),
-		Change:        int(change),
+		Total:         int(totalRevenue.Rubles()),
+		Amount:        totalRevenue,
+		Change:        int(change.Rubles()),
 		ChangePercent: changePercent,
 		Trend:         trend,
 		Period:        h.formatPeriod(days),
@@ -359,73 +293,52 @@ func (h *ChartHandler) GetRevenueChartData(days int) (*ChartResponse, error) {
 	return response, nil
 }
 
-// GetInventoryValueChartData возвращает данные для графика стоимости инвентаря
-func (h *ChartHandler) GetInventoryValueChartData() (*ChartResponse, error) {
-	query := `
-		SELECT 
-			date(wi.created_at) as inv_date,
-			SUM(wi.quantity * wi.unit_price) as daily_value
-		FROM warehouse_inventory wi
-		GROUP BY date(wi.created_at)
-		ORDER BY inv_date DESC
-		LIMIT 30
-	`
-
-	rows, err := h.db.Query(query)
+// GetInventoryValueChartData возвращает данные для графика стоимости инвентаря:
+// себестоимость запасов на конец каждого из последних 30 дней. Стоимость
+// остатка равна сумме стоимостей движений, поэтому на конец дня она —
+// сумма stock_movements.cost до его окончания.
+func (h *ChartHandler) GetInventoryValueChartData(ctx context.Context, scope OrgScope) (*ChartResponse, error) {
+	endDate := time.Now()
+	startDate := endDate.AddDate(0, 0, -29)
+
+	history, err := h.reports.StockValue(ctx, scope, startDate, endDate)
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
 
 	var dataPoints []ChartDataPoint
 	var dates []time.Time
-	var values []float64
+	var values []money.Amount
 
-	for rows.Next() {
-		var date time.Time
-		var value sql.NullFloat64
-		if err := rows.Scan(&date, &value); err != nil {
-			continue
-		}
-
-		val := 0.0
-		if value.Valid {
-			val = value.Float64
-		}
-
-		dates = append(dates, date)
-		values = append(values, val)
+	for _, day := range history {
+		dates = append(dates, day.Date)
+		values = append(values, day.Amount)
 
 		dataPoints = append(dataPoints, ChartDataPoint{
-			Date:  date.Format("2006-01-02"),
-			Label: date.Format("02"),
-			Value: val,
+			Date:  day.Date.Format("2006-01-02"),
+			Label: day.Date.Format("02"),
+			Value: day.Amount,
 		})
 	}
 
-	// Реверсируем данные для правильного порядка
-	for i, j := 0, len(dataPoints)-1; i < j; i, j = i+1, j-1 {
-		dataPoints[i], dataPoints[j] = dataPoints[j], dataPoints[i]
-		dates[i], dates[j] = dates[j], dates[i]
-		values[i], values[j] = values[j], values[i]
-	}
-
 	// Вычисляем проценты
 	if len(values) > 0 {
-		maxVal := h.getMaxFloat(values)
+		maxVal := h.getMaxAmount(values)
 		if maxVal > 0 {
 			for i := range dataPoints {
-				dataPoints[i].Percentage = values[i] / maxVal * 100
+				dataPoints[i].Percentage = float64(values[i]) / float64(maxVal) * 100
 			}
 		}
 	}
 
-	// Текущая стоимость инвентаря
-	var totalValue float64
-	h.db.QueryRow("SELECT COALESCE(SUM(quantity * unit_price), 0) FROM warehouse_inventory").Scan(&totalValue)
+	// Текущая стоимость инвентаря — значение на конец сегодняшнего дня
+	var totalValue money.Amount
+	if len(values) > 0 {
+		totalValue = values[len(values)-1]
+	}
 
 	// Рассчитываем изменения
-	change, changePercent, trend := h.calculateFloatMetrics(values)
+	change, changePercent, trend := h.calculateAmountMetrics(values)
 
 	labels := h.generateChartLabels(dates)
 
@@ -439,8 +352,9 @@ func (h *ChartHandler) GetInventoryValueChartData() (*ChartResponse, error) {
 			},
 		},
 		Labels:        labels,
-		Total:         int(totalValue),
-		Change:        int(change),
+		Total:         int(totalValue.Rubles()),
+		Amount:        totalValue,
+		Change:        int(change.Rubles()),
 		ChangePercent: changePercent,
 		Trend:         trend,
 		Period:        "30 дней",
@@ -564,8 +478,8 @@ func (h *ChartHandler) calculateMetrics(values []int) (change int, changePercent
 	return change, changePercent, trend
 }
 
-// calculateFloatMetrics рассчитывает изменения и тренд для дробных чисел
-func (h *ChartHandler) calculateFloatMetrics(values []float64) (change float64, changePercent float64, trend int) {
+// calculateAmountMetrics рассчитывает изменения и тренд для денежных сумм
+func (h *ChartHandler) calculateAmountMetrics(values []money.Amount) (change money.Amount, changePercent float64, trend int) {
 	if len(values) < 2 {
 		return 0, 0, 0
 	}
@@ -575,7 +489,7 @@ func (h *ChartHandler) calculateFloatMetrics(values []float64) (change float64,
 	change = endValue - startValue
 
 	if startValue != 0 {
-		changePercent = change / startValue * 100
+		changePercent = float64(change) / float64(startValue) * 100
 	}
 
 	// Определяем тренд
@@ -583,8 +497,8 @@ func (h *ChartHandler) calculateFloatMetrics(values []float64) (change float64,
 		last7 := values[len(values)-7:]
 		first7 := values[:7]
 
-		avgLast7 := h.averageFloat(last7)
-		avgFirst7 := h.averageFloat(first7)
+		avgLast7 := h.averageAmount(last7)
+		avgFirst7 := h.averageAmount(first7)
 
 		if avgLast7 > avgFirst7*1.05 {
 			trend = 1
@@ -610,7 +524,7 @@ func (h *ChartHandler) getMax(nums []int) int {
 	return max
 }
 
-func (h *ChartHandler) getMaxFloat(nums []float64) float64 {
+func (h *ChartHandler) getMaxAmount(nums []money.Amount) money.Amount {
 	if len(nums) == 0 {
 		return 0
 	}
@@ -644,15 +558,12 @@ func (h *ChartHandler) averageInt(nums []int) float64 {
 	return float64(sum) / float64(len(nums))
 }
 
-func (h *ChartHandler) averageFloat(nums []float64) float64 {
+// averageAmount возвращает среднее в копейках; нужно только для сравнения трендов
+func (h *ChartHandler) averageAmount(nums []money.Amount) float64 {
 	if len(nums) == 0 {
 		return 0
 	}
-	sum := 0.0
-	for _, num := range nums {
-		sum += num
-	}
-	return sum / float64(len(nums))
+	return float64(money.Sum(nums...)) / float64(len(nums))
 }
 
 // GetTrendInfo возвращает информацию о тренде для использования в шаблонах
@@ -668,8 +579,8 @@ func (h *ChartHandler) GetTrendInfo(trend int) (string, string, string) {
 }
 
 // GetMachinesChartJSON возвращает данные для графика автоматов в формате JSON
-func (h *ChartHandler) GetMachinesChartJSON() ([]byte, error) {
-	data, err := h.GetMachinesChartData()
+func (h *ChartHandler) GetMachinesChartJSON(ctx context.Context, scope OrgScope) ([]byte, error) {
+	data, err := h.GetMachinesChartData(ctx, scope)
 	if err != nil {
 		return nil, err
 	}
@@ -684,9 +595,9 @@ func (h *ChartHandler) HandleMachinesChart(w http.ResponseWriter, r *http.Reques
 		return
 	}
 	
-	data, err := h.GetMachinesChartJSON()
+	data, err := h.GetMachinesChartJSON(r.Context(), scopeFor(r))
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		serverError(w, r, err)
 		return
 	}
 	
@@ -707,9 +618,9 @@ func (h *ChartHandler) HandleOperationsChart(w http.ResponseWriter, r *http.Requ
 	// Здесь вы можете извлечь параметры из URL если нужно
 	// Например: /api/charts/operations?days=7
 	
-	data, err := h.GetOperationsChartData(days)
+	data, err := h.GetOperationsChartData(r.Context(), scopeFor(r), days)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		serverError(w, r, err)
 		return
 	}
 	
@@ -726,9 +637,9 @@ func (h *ChartHandler) HandleRevenueChart(w http.ResponseWriter, r *http.Request
 	
 	days := 30
 	
-	data, err := h.GetRevenueChartData(days)
+	data, err := h.GetRevenueChartData(r.Context(), scopeFor(r), days)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		serverError(w, r, err)
 		return
 	}
 	
@@ -743,9 +654,9 @@ func (h *ChartHandler) HandleInventoryChart(w http.ResponseWriter, r *http.Reque
 		return
 	}
 	
-	data, err := h.GetInventoryValueChartData()
+	data, err := h.GetInventoryValueChartData(r.Context(), scopeFor(r))
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		serverError(w, r, err)
 		return
 	}
 	
@@ -753,66 +664,34 @@ func (h *ChartHandler) HandleInventoryChart(w http.ResponseWriter, r *http.Reque
 	json.NewEncoder(w).Encode(data)
 }
 // GetCashChartData возвращает данные для графика денег в автоматах
-func (h *ChartHandler) GetCashChartData() (*ChartResponse, error) {
-	endDate := time.Now()
-	startDate := endDate.AddDate(0, 0, -30)
-
-	// Получаем сумму денег по дням
-	query := `
-		WITH date_series AS (
-			SELECT generate_series($1::date, $2::date, '1 day')::date as chart_date
-		),
-		daily_cash AS (
-			SELECT 
-				ds.chart_date,
-				COALESCE(SUM(vm.cash_amount), 0) as daily_cash_amount
-			FROM date_series ds
-			LEFT JOIN vending_machines vm ON date(vm.created_at) <= ds.chart_date
-			GROUP BY ds.chart_date
-			ORDER BY ds.chart_date
-		)
-		SELECT 
-			chart_date,
-			daily_cash_amount
-		FROM daily_cash
-		ORDER BY chart_date
-	`
-
-	rows, err := h.db.Query(query, startDate, endDate)
+func (h *ChartHandler) GetCashChartData(ctx context.Context, scope OrgScope) (*ChartResponse, error) {
+	history, totals, startDate, endDate, err := h.machineHistory(ctx, scope)
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
 
 	var dataPoints []ChartDataPoint
-	var amounts []float64
+	var amounts []money.Amount
 	var dates []time.Time
 
-	for rows.Next() {
-		var date time.Time
-		var amount float64
-		if err := rows.Scan(&date, &amount); err != nil {
-			continue
-		}
-
-		amounts = append(amounts, amount)
-		dates = append(dates, date)
+	for _, day := range history {
+		amounts = append(amounts, day.Cash)
+		dates = append(dates, day.Date)
 
-		label := h.formatDateLabel(date, startDate, endDate)
+		label := h.formatDateLabel(day.Date, startDate, endDate)
 
 		dataPoints = append(dataPoints, ChartDataPoint{
-			Date:  date.Format("2006-01-02"),
+			Date:  day.Date.Format("2006-01-02"),
 			Label: label,
-			Value: amount,
+			Value: day.Cash,
 		})
 	}
 
-	// Получаем текущую общую сумму денег
-	var totalCash float64
-	h.db.QueryRow("SELECT COALESCE(SUM(cash_amount), 0) FROM vending_machines").Scan(&totalCash)
+	// Текущая общая сумма денег
+	totalCash := totals.Cash
 
 	// Рассчитываем изменения и тренд
-	change, changePercent, trend := h.calculateFloatMetrics(amounts)
+	change, changePercent, trend := h.calculateAmountMetrics(amounts)
 
 	// Создаем метки для оси X
 	labels := h.generateChartLabels(dates)
@@ -827,8 +706,9 @@ func (h *ChartHandler) GetCashChartData() (*ChartResponse, error) {
 			},
 		},
 		Labels:        labels,
-		Total:         int(totalCash),
-		Change:        int(change),
+		Total:         int(totalCash.Rubles()),
+		Amount:        totalCash,
+		Change:        int(change.Rubles()),
 		ChangePercent: changePercent,
 		Trend:         trend,
 		Period:        "30 дней",
@@ -844,9 +724,9 @@ func (h *ChartHandler) HandleCashChart(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	data, err := h.GetCashChartData()
+	data, err := h.GetCashChartData(r.Context(), scopeFor(r))
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		serverError(w, r, err)
 		return
 	}
 
@@ -854,63 +734,22 @@ func (h *ChartHandler) HandleCashChart(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(data)
 }
 // GetToysChartData возвращает данные для графика игрушек в автоматах
-func (h *ChartHandler) GetToysChartData() (*ChartResponse, error) {
-	endDate := time.Now()
-	startDate := endDate.AddDate(0, 0, -30)
-
-	// Получаем количество игрушек по дням
-	query := `
-		WITH date_series AS (
-			SELECT generate_series($1::date, $2::date, '1 day')::date as chart_date
-		),
-		daily_toys AS (
-			SELECT 
-				ds.chart_date,
-				COALESCE(SUM(vm.current_toys_count), 0) as daily_toys_count
-			FROM date_series ds
-			LEFT JOIN vending_machines vm ON date(vm.created_at) <= ds.chart_date
-			GROUP BY ds.chart_date
-			ORDER BY ds.chart_date
-		)
-		SELECT 
-			chart_date,
-			daily_toys_count
-		FROM daily_toys
-		ORDER BY chart_date
-	`
-
-	rows, err := h.db.Query(query, startDate, endDate)
+func (h *ChartHandler) GetToysChartData(ctx context.Context, scope OrgScope) (*ChartResponse, error) {
+	history, totals, startDate, endDate, err := h.machineHistory(ctx, scope)
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
 
-	var dataPoints []ChartDataPoint
 	var counts []int
 	var dates []time.Time
-
-	for rows.Next() {
-		var date time.Time
-		var count int
-		if err := rows.Scan(&date, &count); err != nil {
-			continue
-		}
-
-		counts = append(counts, count)
-		dates = append(dates, date)
-
-		label := h.formatDateLabel(date, startDate, endDate)
-
-		dataPoints = append(dataPoints, ChartDataPoint{
-			Date:  date.Format("2006-01-02"),
-			Label: label,
-			Count: count,
-		})
+	for _, day := range history {
+		counts = append(counts, day.Toys)
+		dates = append(dates, day.Date)
 	}
+	dataPoints := h.countSeries(dates, counts, startDate, endDate)
 
-	// Получаем текущее общее количество игрушек
-	var totalToys int
-	h.db.QueryRow("SELECT COALESCE(SUM(current_toys_count), 0) FROM vending_machines").Scan(&totalToys)
+	// Текущее общее количество игрушек
+	totalToys := totals.Toys
 
 	// Рассчитываем изменения и тренд
 	change, changePercent, trend := h.calculateMetrics(counts)
@@ -945,9 +784,9 @@ func (h *ChartHandler) HandleToysChart(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	data, err := h.GetToysChartData()
+	data, err := h.GetToysChartData(r.Context(), scopeFor(r))
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		serverError(w, r, err)
 		return
 	}
 
@@ -955,64 +794,22 @@ func (h *ChartHandler) HandleToysChart(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(data)
 }
 // GetActiveMachinesChartData возвращает данные для графика активных автоматов
-func (h *ChartHandler) GetActiveMachinesChartData() (*ChartResponse, error) {
-	endDate := time.Now()
-	startDate := endDate.AddDate(0, 0, -30)
-
-	// Получаем количество активных автоматов по дням
-	query := `
-		WITH date_series AS (
-			SELECT generate_series($1::date, $2::date, '1 day')::date as chart_date
-		),
-		daily_active_counts AS (
-			SELECT 
-				ds.chart_date,
-				COUNT(DISTINCT vm.id) as active_machine_count
-			FROM date_series ds
-			LEFT JOIN vending_machines vm ON date(vm.created_at) <= ds.chart_date 
-				AND (vm.status = 'active' OR vm.status IS NULL)
-			GROUP BY ds.chart_date
-			ORDER BY ds.chart_date
-		)
-		SELECT 
-			chart_date,
-			active_machine_count
-		FROM daily_active_counts
-		ORDER BY chart_date
-	`
-
-	rows, err := h.db.Query(query, startDate, endDate)
+func (h *ChartHandler) GetActiveMachinesChartData(ctx context.Context, scope OrgScope) (*ChartResponse, error) {
+	history, totals, startDate, endDate, err := h.machineHistory(ctx, scope)
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
 
-	var dataPoints []ChartDataPoint
 	var counts []int
 	var dates []time.Time
-
-	for rows.Next() {
-		var date time.Time
-		var count int
-		if err := rows.Scan(&date, &count); err != nil {
-			continue
-		}
-
-		counts = append(counts, count)
-		dates = append(dates, date)
-
-		label := h.formatDateLabel(date, startDate, endDate)
-
-		dataPoints = append(dataPoints, ChartDataPoint{
-			Date:  date.Format("2006-01-02"),
-			Label: label,
-			Count: count,
-		})
+	for _, day := range history {
+		counts = append(counts, day.Active)
+		dates = append(dates, day.Date)
 	}
+	dataPoints := h.countSeries(dates, counts, startDate, endDate)
 
-	// Получаем текущее количество активных автоматов
-	var activeMachines int
-	h.db.QueryRow("SELECT COUNT(*) FROM vending_machines WHERE status = 'active'").Scan(&activeMachines)
+	// Текущее количество активных автоматов
+	activeMachines := totals.Active
 
 	// Рассчитываем изменения и тренд
 	change, changePercent, trend := h.calculateMetrics(counts)
@@ -1047,9 +844,9 @@ func (h *ChartHandler) HandleActiveMachinesChart(w http.ResponseWriter, r *http.
 		return
 	}
 
-	data, err := h.GetActiveMachinesChartData()
+	data, err := h.GetActiveMachinesChartData(r.Context(), scopeFor(r))
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		serverError(w, r, err)
 		return
 	}
 
@@ -0,0 +1,129 @@
+package handlers
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"vend_erp/internal/validate"
+)
+
+// Форма редактирования несет версию записи, и Update с устаревшей версией
+// возвращает repository.ErrConflict: запись успел сохранить кто-то другой.
+// Вместо того чтобы молча перезаписать его правки, обработчик показывает
+// окно сравнения. В нем поля, где значения разошлись, предлагают выбрать
+// свое или сохраненное значение; совпавшие поля и новая версия уходят
+// скрытыми полями, поэтому окно отправляется тем же обработчиком сохранения,
+// что и исходная форма.
+
+// formField описывает поле формы для окна сравнения.
+type formField struct {
+	Name  string
+	Label string
+	// Options подписывает значения списков и флажков: название локации
+	// вместо ID, "Да" вместо "true".
+	Options map[string]string
+}
+
+// conflictField — поле, в котором отправленное значение разошлось
+// с сохраненным.
+type conflictField struct {
+	Name, Label          string
+	Mine, Theirs         string
+	MineText, TheirsText string
+}
+
+// hiddenField — значение, которое окно сравнения отправляет без выбора.
+type hiddenField struct {
+	Name, Value string
+}
+
+type conflict struct {
+	Action string // URL сохранения исходной формы
+	Target string // hx-target исходной формы
+	Fields []conflictField
+	Hidden []hiddenField
+}
+
+// newConflict сравнивает отправленную запись mine с сохраненной theirs.
+// Обе передаются значениями формы в одном и том же виде (см. machineValues),
+// чтобы "1500" и "1500.00" не считались разными. id и version берутся
+// из сохраненной записи: выбранный вариант сохраняется поверх нее.
+func newConflict(action, target string, fields []formField, mine, theirs url.Values) conflict {
+	c := conflict{
+		Action: action,
+		Target: target,
+		Hidden: []hiddenField{
+			{"id", theirs.Get("id")},
+			{"version", theirs.Get("version")},
+		},
+	}
+	for _, f := range fields {
+		m, t := mine.Get(f.Name), theirs.Get(f.Name)
+		if m == t {
+			c.Hidden = append(c.Hidden, hiddenField{f.Name, m})
+			continue
+		}
+		c.Fields = append(c.Fields, conflictField{
+			Name: f.Name, Label: f.Label,
+			Mine: m, Theirs: t,
+			MineText: f.text(m), TheirsText: f.text(t),
+		})
+	}
+	return c
+}
+
+func (f formField) text(value string) string {
+	if label, ok := f.Options[value]; ok {
+		return label
+	}
+	if value == "" {
+		return "—"
+	}
+	return value
+}
+
+// yesNo подписывает флажки форм.
+var yesNo = map[string]string{"true": "Да", "": "Нет"}
+
+// flag, formID и formDate возвращают значения полей в том виде, в каком
+// их отправляет форма.
+func flag(on bool) string {
+	if on {
+		return "true"
+	}
+	return ""
+}
+
+func formID(id int64) string {
+	if id == 0 {
+		return ""
+	}
+	return strconv.FormatInt(id, 10)
+}
+
+func formDate(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(validate.DateLayout)
+}
+
+// idOptions подписывает ID записей списка их названиями.
+func idOptions[T any](list []T, id func(T) int64, name func(T) string) map[string]string {
+	options := make(map[string]string, len(list))
+	for _, item := range list {
+		options[formID(id(item))] = name(item)
+	}
+	return options
+}
+
+// RenderConflict показывает окно сравнения вместо формы. Как и форма
+// с ошибками, ответ (409) htmx вставляет в target по HX-Retarget.
+func (tr *TemplateRenderer) RenderConflict(w http.ResponseWriter, target string, c conflict) {
+	w.Header().Set("HX-Retarget", target)
+	w.Header().Set("HX-Reswap", "innerHTML")
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	tr.RenderStatus(w, "conflict_form.html", map[string]interface{}{"Conflict": c}, http.StatusConflict)
+}
@@ -1,111 +1,110 @@
 package handlers
 
 import (
-	"database/sql"
-	"fmt"
+	"context"
+	"log/slog"
 	"net/http"
-	"vend_erp/internal/models"
+	"vend_erp/internal/repository"
 )
 
 type DashboardHandler struct {
-	db           *sql.DB
+	reports      repository.Reports
 	renderer     *TemplateRenderer
 	chartHandler *ChartHandler
 }
 
-func NewDashboardHandler(db *sql.DB, renderer *TemplateRenderer, chartHandler *ChartHandler) *DashboardHandler {
+func NewDashboardHandler(reports repository.Reports, renderer *TemplateRenderer, chartHandler *ChartHandler) *DashboardHandler {
 	return &DashboardHandler{
-		db:           db,
+		reports:      reports,
 		renderer:     renderer,
 		chartHandler: chartHandler,
 	}
 }
 
 func (h *DashboardHandler) ShowDashboard(w http.ResponseWriter, r *http.Request) {
-	fmt.Printf("DEBUG: DashboardHandler.ShowDashboard called for URL: %s\n", r.URL.Path)
+
+	scope := scopeFor(r)
 
 	// Получаем статистику складов
-	stats, err := h.getWarehouseStats()
+	stats, err := h.reports.Warehouses(r.Context(), scope)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		serverError(w, r, err)
 		return
 	}
 
 	// Получаем распределение по типам
-	inventoryByType, err := h.getInventoryByType()
+	inventoryByType, err := h.getInventoryByType(r.Context(), scope)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		serverError(w, r, err)
 		return
 	}
 
 	// Получаем критические позиции
-	lowStockItems, err := h.getLowStockItems()
+	lowStockItems, err := h.reports.LowStock(r.Context(), scope, 10)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		serverError(w, r, err)
 		return
 	}
 
 	// Получаем данные для графика автоматов
-	machinesChart, err := h.chartHandler.GetMachinesChartData()
+	machinesChart, err := h.chartHandler.GetMachinesChartData(r.Context(), scope)
 	if err != nil {
-		fmt.Printf("DEBUG: Error getting machines chart data: %v\n", err)
+		slog.WarnContext(r.Context(), "machines chart data", "err", err)
 		machinesChart = &ChartResponse{Total: 0}
 	}
-	toysChart, err := h.chartHandler.GetToysChartData()
+	toysChart, err := h.chartHandler.GetToysChartData(r.Context(), scope)
 	if err != nil {
-		fmt.Printf("DEBUG: Error getting toys chart data: %v\n", err)
+		slog.WarnContext(r.Context(), "toys chart data", "err", err)
 		toysChart = &ChartResponse{Total: 0}
 	}
 	// Получаем данные для графика операций
-	operationsChart, err := h.chartHandler.GetOperationsChartData(30)
+	operationsChart, err := h.chartHandler.GetOperationsChartData(r.Context(), scope, 30)
 	if err != nil {
-		fmt.Printf("DEBUG: Error getting operations chart data: %v\n", err)
+		slog.WarnContext(r.Context(), "operations chart data", "err", err)
 		operationsChart = &ChartResponse{Total: 0}
 	}
 
 	// Получаем данные для графика денег
-	cashChart, err := h.chartHandler.GetCashChartData()
+	cashChart, err := h.chartHandler.GetCashChartData(r.Context(), scope)
 	if err != nil {
-		fmt.Printf("DEBUG: Error getting cash chart data: %v\n", err)
+		slog.WarnContext(r.Context(), "cash chart data", "err", err)
 		cashChart = &ChartResponse{Total: 0}
 	}
 
-	// Статистика автоматов
-	var totalMachines, activeMachines int
-	var totalCash float64
-	var totalToys int
-
-	h.db.QueryRow("SELECT COUNT(*) FROM vending_machines").Scan(&totalMachines)
-	h.db.QueryRow("SELECT COUNT(*) FROM vending_machines WHERE status = 'active'").Scan(&activeMachines)
-	h.db.QueryRow("SELECT COALESCE(SUM(cash_amount), 0) FROM vending_machines").Scan(&totalCash)
-	h.db.QueryRow("SELECT COALESCE(SUM(current_toys_count), 0) FROM vending_machines").Scan(&totalToys)
-
-	// Статистика операций
-	var totalOperations, restockOperations, collectionOperations, maintenanceOperations int
-
-	h.db.QueryRow("SELECT COUNT(*) FROM vending_operations").Scan(&totalOperations)
-	h.db.QueryRow("SELECT COUNT(*) FROM vending_operations WHERE operation_type = 'restock'").Scan(&restockOperations)
-	h.db.QueryRow("SELECT COUNT(*) FROM vending_operations WHERE operation_type = 'collection'").Scan(&collectionOperations)
-	h.db.QueryRow("SELECT COUNT(*) FROM vending_operations WHERE operation_type = 'maintenance'").Scan(&maintenanceOperations)
+	// Статистика автоматов и операций
+	machines, err := h.reports.Machines(r.Context(), scope)
+	if err != nil {
+		serverError(w, r, err)
+		return
+	}
+	operations, err := h.reports.OperationCounts(r.Context(), scope)
+	if err != nil {
+		serverError(w, r, err)
+		return
+	}
+	totalOperations := 0
+	for _, count := range operations {
+		totalOperations += count
+	}
 
 	// Получаем информацию о тренде
 	trendClass, trendText, trendIcon := h.chartHandler.GetTrendInfo(machinesChart.Trend)
 
 	data := map[string]interface{}{
-		"TotalValue":            fmt.Sprintf("%.2f ₽", stats.TotalValue),
-		"LowStockCount":         stats.LowStockCount,
-		"OutOfStockCount":       stats.OutOfStockCount,
-		"TotalWarehouses":       stats.TotalWarehouses,
+		"TotalValue":            stats.Value,
+		"LowStockCount":         stats.LowStock,
+		"OutOfStockCount":       stats.OutOfStock,
+		"TotalWarehouses":       stats.Warehouses,
 		"InventoryByType":       inventoryByType,
 		"LowStockItems":         lowStockItems,
-		"TotalMachines":         totalMachines,
-		"ActiveMachines":        activeMachines,
-		"TotalCash":             fmt.Sprintf("%.2f ₽", totalCash),
-		"TotalToys":             totalToys,
+		"TotalMachines":         machines.Count,
+		"ActiveMachines":        machines.Active,
+		"TotalCash":             machines.Cash,
+		"TotalToys":             machines.Toys,
 		"TotalOperations":       totalOperations,
-		"RestockOperations":     restockOperations,
-		"CollectionOperations":  collectionOperations,
-		"MaintenanceOperations": maintenanceOperations,
+		"RestockOperations":     operations["restock"],
+		"CollectionOperations":  operations["collection"],
+		"MaintenanceOperations": operations["maintenance"],
 		"TrendClass":            trendClass,
 		"TrendText":             trendText,
 		"TrendIcon":             trendIcon,
@@ -126,130 +125,32 @@ func (h *DashboardHandler) ShowDashboard(w http.ResponseWriter, r *http.Request)
 	h.renderer.Render(w, "dashboard_page.html", data)
 }
 
-type WarehouseStats struct {
-	TotalValue      float64
-	LowStockCount   int
-	OutOfStockCount int
-	TotalWarehouses int
-}
-
-func (h *DashboardHandler) getWarehouseStats() (WarehouseStats, error) {
-	var stats WarehouseStats
-
-	// Общая стоимость инвентаря
-	err := h.db.QueryRow(`
-        SELECT COALESCE(SUM(wi.quantity * wi.unit_price), 0) as total_value
-        FROM warehouse_inventory wi
-        JOIN warehouse w ON wi.warehouse_id = w.id
-        WHERE w.is_active = true
-    `).Scan(&stats.TotalValue)
-	if err != nil {
-		return stats, err
-	}
-
-	// Позиции с низким запасом
-	err = h.db.QueryRow(`
-        SELECT COUNT(*) as low_stock_count
-        FROM warehouse_inventory wi
-        JOIN warehouse w ON wi.warehouse_id = w.id
-        WHERE w.is_active = true AND wi.quantity < wi.min_stock_level AND wi.quantity > 0
-    `).Scan(&stats.LowStockCount)
-	if err != nil {
-		return stats, err
-	}
-
-	// Отсутствующие позиции
-	err = h.db.QueryRow(`
-        SELECT COUNT(*) as out_of_stock_count
-        FROM warehouse_inventory wi
-        JOIN warehouse w ON wi.warehouse_id = w.id
-        WHERE w.is_active = true AND wi.quantity = 0
-    `).Scan(&stats.OutOfStockCount)
-	if err != nil {
-		return stats, err
-	}
-
-	// Активные склады
-	err = h.db.QueryRow(`
-        SELECT COUNT(*) as total_warehouses
-        FROM warehouse 
-        WHERE is_active = true
-    `).Scan(&stats.TotalWarehouses)
-	if err != nil {
-		return stats, err
-	}
-
-	return stats, nil
-}
-
 type InventoryType struct {
 	TypeName string
 	Count    int
 }
 
-func (h *DashboardHandler) getInventoryByType() ([]InventoryType, error) {
-	rows, err := h.db.Query(`
-        SELECT 
-            CASE 
-                WHEN item_type = 'vending_machine' THEN 'Автоматы'
-                WHEN item_type = 'toy' THEN 'Игрушки' 
-                WHEN item_type = 'capsule' THEN 'Капсулы'
-                ELSE 'Другие'
-            END as type_name,
-            COUNT(*) as count
-        FROM warehouse_inventory wi
-        JOIN warehouse w ON wi.warehouse_id = w.id
-        WHERE w.is_active = true
-        GROUP BY item_type
-        ORDER BY count DESC
-    `)
+// inventoryTypeNames — подписи типов товара на дашборде
+var inventoryTypeNames = map[string]string{
+	"vending_machine": "Автоматы",
+	"toy":             "Игрушки",
+	"capsule":         "Капсулы",
+}
+
+func (h *DashboardHandler) getInventoryByType(ctx context.Context, scope OrgScope) ([]InventoryType, error) {
+	counts, err := h.reports.StockByType(ctx, scope)
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
 
 	var types []InventoryType
-	for rows.Next() {
-		var it InventoryType
-		err := rows.Scan(&it.TypeName, &it.Count)
-		if err != nil {
-			continue
+	for _, tc := range counts {
+		name, ok := inventoryTypeNames[tc.ItemType]
+		if !ok {
+			name = "Другие"
 		}
-		types = append(types, it)
+		types = append(types, InventoryType{TypeName: name, Count: tc.Count})
 	}
 
 	return types, nil
 }
-
-func (h *DashboardHandler) getLowStockItems() ([]models.WarehouseInventory, error) {
-	rows, err := h.db.Query(`
-        SELECT 
-            wi.item_name,
-            wi.quantity,
-            wi.min_stock_level,
-            w.name as warehouse_name
-        FROM warehouse_inventory wi
-        JOIN warehouse w ON wi.warehouse_id = w.id
-        WHERE w.is_active = true 
-          AND wi.quantity < wi.min_stock_level 
-          AND wi.quantity > 0
-        ORDER BY (wi.min_stock_level - wi.quantity) DESC
-        LIMIT 10
-    `)
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
-
-	var items []models.WarehouseInventory
-	for rows.Next() {
-		var item models.WarehouseInventory
-		err := rows.Scan(&item.ItemName, &item.Quantity, &item.MinStockLevel, &item.WarehouseName)
-		if err != nil {
-			continue
-		}
-		items = append(items, item)
-	}
-
-	return items, nil
-}
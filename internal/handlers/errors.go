@@ -0,0 +1,54 @@
+package handlers
+
+import (
+	"html/template"
+	"log/slog"
+	"net/http"
+
+	"vend_erp/internal/logging"
+)
+
+// errorFragment показывается вместо текста внутренней ошибки: подробности
+// остаются в журнале, а пользователь получает код запроса для поддержки.
+var errorFragment = template.Must(template.New("error").Parse(
+	`<div class="notification error" role="alert">` +
+		`Что-то пошло не так. Попробуйте еще раз; если ошибка повторится, сообщите в поддержку код запроса <code>{{.}}</code>.` +
+		`</div>`))
+
+// serverError пишет ошибку в журнал целиком и отвечает 500 с понятным
+// пользователю фрагментом.
+func serverError(w http.ResponseWriter, r *http.Request, err error) {
+	slog.ErrorContext(r.Context(), "request failed", "err", err)
+	writeErrorFragment(w, logging.RequestID(r.Context()))
+}
+
+// renderError — то же для TemplateRenderer, у которого нет запроса:
+// идентификатор берется из заголовка ответа, выставленного middleware.
+func renderError(w http.ResponseWriter, name string, err error) {
+	requestID := w.Header().Get(logging.Header)
+	slog.Error("rendering template", "template", name, "err", err, "request_id", requestID)
+	writeErrorFragment(w, requestID)
+}
+
+func writeErrorFragment(w http.ResponseWriter, requestID string) {
+	if requestID == "" {
+		requestID = "—"
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(http.StatusInternalServerError)
+	errorFragment.Execute(w, requestID)
+}
+
+var userErrorFragment = template.Must(template.New("user-error").Parse(
+	`<div class="notification error" role="alert">{{.}}</div>`))
+
+// userError отклоняет действие, которое нельзя выполнить, с понятным
+// пользователю сообщением: app.js показывает фрагмент уведомлением,
+// не трогая таблицу.
+func userError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	userErrorFragment.Execute(w, message)
+}
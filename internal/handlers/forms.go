@@ -0,0 +1,22 @@
+package handlers
+
+import (
+	"net/http"
+
+	"vend_erp/internal/validate"
+)
+
+// Формы открываются в модальных окнах, а отправляются в таблицу списка
+// (hx-target). Форма с ошибками возвращается в окно, из которого пришла.
+const modalBody = "#modal-body"
+
+// RenderInvalid перерисовывает форму name с ошибками полей и введенными
+// значениями. Ответ 422 с HX-Retarget htmx вставляет в target вместо
+// таблицы; app.js разрешает замену для этого кода.
+func (tr *TemplateRenderer) RenderInvalid(w http.ResponseWriter, target, name string, data map[string]interface{}, form *validate.Form) {
+	data["Form"] = form
+	w.Header().Set("HX-Retarget", target)
+	w.Header().Set("HX-Reswap", "innerHTML")
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	tr.RenderStatus(w, name, data, http.StatusUnprocessableEntity)
+}
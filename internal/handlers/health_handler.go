@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"vend_erp/internal/database"
+	"vend_erp/migrations"
+)
+
+// readyTimeout ограничивает проверки /readyz, чтобы зависшая база
+// не держала запрос балансировщика.
+const readyTimeout = 2 * time.Second
+
+// HealthHandler отвечает на проверки оркестратора: /healthz — процесс жив,
+// /readyz — можно принимать трафик (база доступна, миграции применены).
+type HealthHandler struct {
+	db       *sql.DB
+	migrator *migrations.Migrator
+}
+
+func NewHealthHandler(db *sql.DB, migrator *migrations.Migrator) *HealthHandler {
+	return &HealthHandler{db: db, migrator: migrator}
+}
+
+type readiness struct {
+	Status            string `json:"status"`
+	Database          string `json:"database"`
+	PendingMigrations int    `json:"pending_migrations"`
+	OpenConnections   int    `json:"open_connections"`
+	InUseConnections  int    `json:"in_use_connections"`
+}
+
+// Live не обращается к базе: перезапуск процесса не лечит недоступную БД.
+func (h *HealthHandler) Live(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Write([]byte("ok\n"))
+}
+
+func (h *HealthHandler) Ready(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), readyTimeout)
+	defer cancel()
+
+	result := readiness{Status: "ready", Database: "ok"}
+	status := http.StatusOK
+
+	if err := database.HealthCheck(ctx, h.db); err != nil {
+		slog.WarnContext(r.Context(), "readiness: database unavailable", "err", err)
+		result.Status, result.Database = "unavailable", "unreachable"
+		status = http.StatusServiceUnavailable
+	} else if pending, err := h.migrator.Pending(); err != nil {
+		slog.WarnContext(r.Context(), "readiness: checking migrations", "err", err)
+		result.Status = "unavailable"
+		status = http.StatusServiceUnavailable
+	} else if pending > 0 {
+		result.Status = "migrating"
+		result.PendingMigrations = pending
+		status = http.StatusServiceUnavailable
+	}
+
+	stats := database.GetDBStats(h.db)
+	result.OpenConnections = stats.OpenConnections
+	result.InUseConnections = stats.InUse
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(result)
+}
@@ -0,0 +1,177 @@
+package handlers
+
+import (
+    "context"
+    "crypto/sha256"
+    "encoding/hex"
+    "log/slog"
+    "net/http"
+    "strconv"
+    "time"
+    "vend_erp/internal/models"
+    "vend_erp/internal/repository"
+    "vend_erp/internal/validate"
+)
+
+type InviteHandler struct {
+    invites  repository.Invites
+    users    repository.Users
+    renderer *TemplateRenderer
+    ttl      time.Duration
+}
+
+func NewInviteHandler(invites repository.Invites, users repository.Users, renderer *TemplateRenderer, ttl time.Duration) *InviteHandler {
+    return &InviteHandler{invites: invites, users: users, renderer: renderer, ttl: ttl}
+}
+
+// hashInviteToken возвращает значение invites.token_hash для токена из ссылки.
+func hashInviteToken(token string) string {
+    sum := sha256.Sum256([]byte(token))
+    return hex.EncodeToString(sum[:])
+}
+
+// findInvite ищет приглашение по токену из ссылки. Использованные, отозванные
+// и просроченные приглашения тоже возвращаются — проверяет вызывающий.
+func findInvite(ctx context.Context, invites repository.Invites, token string) (*models.Invite, error) {
+    if token == "" {
+        return nil, repository.ErrNotFound
+    }
+    invite, err := invites.Find(ctx, hashInviteToken(token))
+    if err != nil {
+        return nil, err
+    }
+    return &invite, nil
+}
+
+// listTeams возвращает уже используемые в организации команды для подсказок
+// в формах. Без подсказок форма работает, поэтому ошибка только пишется в журнал.
+func listTeams(r *http.Request, users repository.Users) []string {
+    teams, err := users.Teams(r.Context(), scopeFor(r))
+    if err != nil {
+        slog.WarnContext(r.Context(), "listing teams", "err", err)
+    }
+    return teams
+}
+
+func (h *InviteHandler) ListInvites(w http.ResponseWriter, r *http.Request) {
+    if !requireAdmin(w, r) {
+        return
+    }
+
+    scope := scopeFor(r)
+    invites, err := h.invites.List(r.Context(), scope)
+    if err != nil {
+        serverError(w, r, err)
+        return
+    }
+
+    data := map[string]interface{}{
+        "Invites": invites,
+        "AllOrgs": scope.AllOrgs,
+    }
+    h.renderer.Render(w, "invites_list.html", data)
+}
+
+func (h *InviteHandler) GetInviteForm(w http.ResponseWriter, r *http.Request) {
+    if !requireAdmin(w, r) {
+        return
+    }
+
+    h.renderForm(w, r, nil)
+}
+
+// renderForm показывает форму приглашения; непустая form — ответ на
+// неудачное создание с ошибками полей.
+func (h *InviteHandler) renderForm(w http.ResponseWriter, r *http.Request, form *validate.Form) {
+    data := map[string]interface{}{
+        "Teams":    listTeams(r, h.users),
+        "TTLHours": int(h.ttl.Hours()),
+    }
+    if form != nil {
+        h.renderer.RenderInvalid(w, modalBody, "invite_form.html", data, form)
+        return
+    }
+    h.renderer.Render(w, "invite_form.html", data)
+}
+
+func (h *InviteHandler) CreateInvite(w http.ResponseWriter, r *http.Request) {
+    if !requireAdmin(w, r) {
+        return
+    }
+    if err := r.ParseForm(); err != nil {
+        http.Error(w, err.Error(), http.StatusBadRequest)
+        return
+    }
+
+    form := validate.New(r.PostForm)
+    form.Required("user_role")
+    form.MaxLength("email", 255)
+    form.MaxLength("team", 255)
+    form.Email("email")
+    email := form.Get("email")
+    role := form.OneOf("user_role", userRoles...)
+    team := form.Get("team")
+    if !form.Valid() {
+        h.renderForm(w, r, form)
+        return
+    }
+
+    // Приглашение создается в текущей организации; в сводном режиме —
+    // в домашней организации администратора
+    current := CurrentUser(r)
+    token, err := generateSessionID()
+    if err != nil {
+        serverError(w, r, err)
+        return
+    }
+    expiresAt := time.Now().Add(h.ttl)
+
+    invite := models.Invite{
+        Email:     email,
+        UserRole:  role,
+        Team:      team,
+        OrgID:     scopeFor(r).OrgID,
+        InvitedBy: current.ID,
+        ExpiresAt: expiresAt,
+    }
+    if err := h.invites.Create(r.Context(), &invite, hashInviteToken(token)); err != nil {
+        serverError(w, r, err)
+        return
+    }
+
+    data := map[string]interface{}{
+        "Link":      requestBaseURL(r) + "/auth/signup?invite=" + token,
+        "Email":     email,
+        "ExpiresAt": expiresAt,
+    }
+    w.Header().Set("HX-Trigger", "inviteCreated")
+    h.renderer.Render(w, "invite_created.html", data)
+}
+
+func (h *InviteHandler) RevokeInvite(w http.ResponseWriter, r *http.Request) {
+    if !requireAdmin(w, r) {
+        return
+    }
+
+    id, err := strconv.ParseInt(r.URL.Query().Get("id"), 10, 64)
+    if err != nil {
+        http.Error(w, "Invalid ID", http.StatusBadRequest)
+        return
+    }
+
+    if err := h.invites.Revoke(r.Context(), scopeFor(r), id); err != nil {
+        serverError(w, r, err)
+        return
+    }
+
+    h.ListInvites(w, r)
+}
+
+// requestBaseURL восстанавливает внешний адрес приложения для ссылок.
+func requestBaseURL(r *http.Request) string {
+    scheme := "http"
+    if r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https" {
+        scheme = "https"
+    }
+    return scheme + "://" + r.Host
+}
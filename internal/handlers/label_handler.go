@@ -0,0 +1,283 @@
+package handlers
+
+import (
+    "bytes"
+    "errors"
+    "fmt"
+    "net/http"
+    "net/url"
+    "strconv"
+    "vend_erp/config"
+    "vend_erp/internal/labels"
+    "vend_erp/internal/repository"
+    "vend_erp/internal/validate"
+)
+
+// LabelHandler печатает листы этикеток: штрихкоды товаров и QR-коды
+// автоматов и локаций. QR-коды ведут на /scan/..., а не прямо на форму,
+// поэтому напечатанная этикетка не устаревает при смене настроек.
+type LabelHandler struct {
+    products  repository.Products
+    machines  repository.Machines
+    locations repository.Locations
+    cfg       config.LabelsConfig
+    renderer  *TemplateRenderer
+}
+
+func NewLabelHandler(products repository.Products, machines repository.Machines, locations repository.Locations, cfg config.LabelsConfig, renderer *TemplateRenderer) *LabelHandler {
+    return &LabelHandler{products: products, machines: machines, locations: locations, cfg: cfg, renderer: renderer}
+}
+
+// Виды этикеток
+const (
+    labelProducts  = "products"
+    labelMachines  = "machines"
+    labelLocations = "locations"
+)
+
+var labelKinds = map[string]string{
+    labelProducts:  "Товары",
+    labelMachines:  "Автоматы",
+    labelLocations: "Локации",
+}
+
+// labelItem — позиция, для которой можно напечатать этикетку.
+type labelItem struct {
+    ID     int64
+    Name   string
+    Detail string
+    Label  labels.Label
+}
+
+// GetLabelForm показывает выбор позиций и формата листа.
+func (h *LabelHandler) GetLabelForm(w http.ResponseWriter, r *http.Request) {
+    kind := r.URL.Query().Get("kind")
+    if _, ok := labelKinds[kind]; !ok {
+        http.Error(w, "Unknown label kind", http.StatusBadRequest)
+        return
+    }
+    h.renderForm(w, r, kind, nil, nil)
+}
+
+// renderForm показывает форму печати; selected — отмеченные позиции
+// (nil — все), непустая form — ответ на неудачную попытку.
+func (h *LabelHandler) renderForm(w http.ResponseWriter, r *http.Request, kind string, selected map[int64]bool, form *validate.Form) {
+    items, err := h.items(r, kind)
+    if err != nil {
+        serverError(w, r, err)
+        return
+    }
+    if selected == nil {
+        selected = make(map[int64]bool, len(items))
+        for _, item := range items {
+            selected[item.ID] = true
+        }
+    }
+
+    data := map[string]interface{}{
+        "Kind":      kind,
+        "KindTitle": labelKinds[kind],
+        "Items":     items,
+        "Selected":  selected,
+        "Templates": h.cfg.Templates,
+        "Template":  h.cfg.Templates[0].Name,
+    }
+    if form != nil {
+        h.renderer.RenderInvalid(w, modalBody, "label_form.html", data, form)
+        return
+    }
+    h.renderer.Render(w, "label_form.html", data)
+}
+
+// PrintLabels проверяет форму печати (POST из модального окна) и
+// отправляет браузер за файлом (GET с теми же параметрами).
+func (h *LabelHandler) PrintLabels(w http.ResponseWriter, r *http.Request) {
+    if err := r.ParseForm(); err != nil {
+        http.Error(w, err.Error(), http.StatusBadRequest)
+        return
+    }
+
+    form := validate.New(r.Form)
+    kind := form.Get("kind")
+    if _, ok := labelKinds[kind]; !ok {
+        http.Error(w, "Unknown label kind", http.StatusBadRequest)
+        return
+    }
+    layout, ok := h.cfg.LabelTemplate(form.Get("template"))
+    form.Check(ok, "template", "Выберите формат листа")
+    format := form.OneOf("format", "pdf", "png")
+    copies := 1
+    if form.Get("copies") != "" {
+        copies = form.Int("copies")
+        form.Range("copies", copies, 1, 100)
+    }
+    skip := form.Int("skip")
+    if ok {
+        form.Range("skip", skip, 0, layout.PerSheet()-1)
+    }
+
+    items, err := h.items(r, kind)
+    if err != nil {
+        serverError(w, r, err)
+        return
+    }
+    selected := make(map[int64]bool)
+    for _, value := range r.Form["id"] {
+        id, err := strconv.ParseInt(value, 10, 64)
+        if err != nil {
+            http.Error(w, "Invalid ID", http.StatusBadRequest)
+            return
+        }
+        selected[id] = true
+    }
+    var list []labels.Label
+    for _, item := range items {
+        if !selected[item.ID] {
+            continue
+        }
+        for i := 0; i < copies; i++ {
+            list = append(list, item.Label)
+        }
+    }
+    form.Check(len(list) > 0, "id", "Отметьте хотя бы одну позицию")
+
+    var codeErr *labels.CodeError
+    if err := labels.Check(list); errors.As(err, &codeErr) {
+        form.Fail("id", fmt.Sprintf("Код «%s» нельзя напечатать штрихкодом: допустимы латиница, цифры и знаки препинания",
+            codeErr.Label.Code))
+    }
+    if !form.Valid() {
+        if r.Method != http.MethodPost {
+            http.Error(w, "Invalid label request", http.StatusBadRequest)
+            return
+        }
+        h.renderForm(w, r, kind, selected, form)
+        return
+    }
+    if r.Method == http.MethodPost {
+        w.Header().Set("HX-Redirect", "/labels/print?"+r.Form.Encode())
+        return
+    }
+
+    // Лист собирается в памяти, чтобы ошибка не оборвала уже начатый файл
+    var out bytes.Buffer
+    contentType, name := "application/pdf", "labels-"+kind+".pdf"
+    if format == "png" {
+        err = labels.PNG(&out, layout, list, skip)
+        contentType, name = "image/png", "labels-"+kind+".png"
+        if labels.Pages(layout, len(list), skip) > 1 {
+            contentType, name = "application/zip", "labels-"+kind+".zip"
+        }
+    } else {
+        err = labels.PDF(&out, layout, list, skip)
+    }
+    if err != nil {
+        serverError(w, r, err)
+        return
+    }
+    w.Header().Set("Content-Type", contentType)
+    w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", name))
+    w.Write(out.Bytes())
+}
+
+// items возвращает позиции области с готовыми этикетками.
+func (h *LabelHandler) items(r *http.Request, kind string) ([]labelItem, error) {
+    scope := scopeFor(r)
+    base := h.cfg.BaseURL
+    if base == "" {
+        base = requestBaseURL(r)
+    }
+
+    var items []labelItem
+    switch kind {
+    case labelProducts:
+        products, err := h.products.List(r.Context(), scope)
+        if err != nil {
+            return nil, err
+        }
+        for _, p := range products {
+            if !p.IsActive {
+                continue
+            }
+            symbology, code := labels.ProductCode(p.Barcode, p.SKU)
+            label := labels.Label{Symbology: symbology, Code: code, Title: p.Name}
+            if code != p.SKU {
+                label.Caption = "Арт. " + p.SKU
+            }
+            items = append(items, labelItem{ID: p.ID, Name: p.Name, Detail: p.SKU, Label: label})
+        }
+    case labelMachines:
+        machines, err := h.machines.List(r.Context(), scope)
+        if err != nil {
+            return nil, err
+        }
+        for _, m := range machines {
+            items = append(items, labelItem{ID: m.ID, Name: m.SerialNumber, Detail: m.LocationName, Label: labels.Label{
+                Symbology: labels.QR,
+                Code:      base + "/scan/machine?serial=" + url.QueryEscape(m.SerialNumber),
+                Title:     m.SerialNumber,
+                Caption:   m.Model + " · " + m.LocationName,
+            }})
+        }
+    case labelLocations:
+        locations, err := h.locations.List(r.Context(), scope)
+        if err != nil {
+            return nil, err
+        }
+        for _, l := range locations {
+            items = append(items, labelItem{ID: l.ID, Name: l.Name, Detail: l.Address, Label: labels.Label{
+                Symbology: labels.QR,
+                Code:      fmt.Sprintf("%s/scan/location?id=%d", base, l.ID),
+                Title:     l.Name,
+                Caption:   l.Address,
+            }})
+        }
+    }
+    return items, nil
+}
+
+// openForm — адрес формы, которую полная страница откроет сразу после
+// загрузки, если в ссылке есть идентификатор param; так срабатывают
+// переходы по QR-кодам.
+func openForm(r *http.Request, param, form string) string {
+    id, err := strconv.ParseInt(r.URL.Query().Get(param), 10, 64)
+    if err != nil || id <= 0 {
+        return ""
+    }
+    return fmt.Sprintf("%s?%s=%d", form, param, id)
+}
+
+// ScanMachine открывает автомат по QR-коду с этикетки: форму операции
+// с выбранным автоматом или карточку автомата, см. LabelsConfig.MachineTarget.
+func (h *LabelHandler) ScanMachine(w http.ResponseWriter, r *http.Request) {
+    machine, err := h.machines.FindBySerial(r.Context(), scopeFor(r), r.URL.Query().Get("serial"))
+    if errors.Is(err, repository.ErrNotFound) {
+        http.Error(w, "Автомат не найден. Возможно, он относится к другой организации — переключите организацию и отсканируйте код еще раз", http.StatusNotFound)
+        return
+    }
+    if err != nil {
+        serverError(w, r, err)
+        return
+    }
+
+    target := fmt.Sprintf("/operations?machine_id=%d", machine.ID)
+    if h.cfg.MachineTarget == config.ScanMachine {
+        target = fmt.Sprintf("/machines?id=%d", machine.ID)
+    }
+    http.Redirect(w, r, target, http.StatusSeeOther)
+}
+
+// ScanLocation открывает карточку локации по QR-коду с этикетки.
+func (h *LabelHandler) ScanLocation(w http.ResponseWriter, r *http.Request) {
+    id, _ := strconv.ParseInt(r.URL.Query().Get("id"), 10, 64)
+    location, err := h.locations.Get(r.Context(), scopeFor(r), id)
+    if errors.Is(err, repository.ErrNotFound) {
+        http.Error(w, "Локация не найдена. Возможно, она относится к другой организации — переключите организацию и отсканируйте код еще раз", http.StatusNotFound)
+        return
+    }
+    if err != nil {
+        serverError(w, r, err)
+        return
+    }
+    http.Redirect(w, r, fmt.Sprintf("/locations?id=%d", location.ID), http.StatusSeeOther)
+}
@@ -1,68 +1,50 @@
 package handlers
 
 import (
-    "database/sql"
-    "fmt"
+    "errors"
+    "log/slog"
     "net/http"
+    "net/url"
     "strconv"
+    "time"
     "vend_erp/internal/models"
+    "vend_erp/internal/money"
+    "vend_erp/internal/repository"
+    "vend_erp/internal/validate"
 )
 
 type LocationHandler struct {
-    db       *sql.DB
-    renderer *TemplateRenderer
+    locations  repository.Locations
+    operations repository.Operations
+    renderer   *TemplateRenderer
 }
 
-func NewLocationHandler(db *sql.DB, renderer *TemplateRenderer) *LocationHandler {
-    return &LocationHandler{db: db, renderer: renderer}
+func NewLocationHandler(locations repository.Locations, operations repository.Operations, renderer *TemplateRenderer) *LocationHandler {
+    return &LocationHandler{locations: locations, operations: operations, renderer: renderer}
 }
 
 func (h *LocationHandler) ListLocations(w http.ResponseWriter, r *http.Request) {
-    
-    fmt.Printf("DEBUG: LocationHandler.ListLocations called for URL: %s\n", r.URL.Path)
-    
-    rows, err := h.db.Query(`
-        SELECT id, name, address, contact_person, contact_phone, 
-               monthly_rent, rent_due_day, is_active
-        FROM locations ORDER BY created_at DESC
-    `)
+    scope := scopeFor(r)
+    locations, err := h.locations.List(r.Context(), scope)
     if err != nil {
-        fmt.Printf("DEBUG: Locations query error: %v\n", err)
-        http.Error(w, err.Error(), http.StatusInternalServerError)
+        serverError(w, r, err)
         return
     }
-    defer rows.Close()
 
-    var locations []models.Location
-    for rows.Next() {
-        var location models.Location
-        err := rows.Scan(
-            &location.ID, &location.Name, &location.Address,
-            &location.ContactPerson, &location.ContactPhone,
-            &location.MonthlyRent, &location.RentDueDay, &location.IsActive,
-        )
-        if err != nil {
-            fmt.Printf("DEBUG: Location scan error: %v\n", err)
-            continue
-        }
-        locations = append(locations, location)
-    }
-
-    fmt.Printf("DEBUG: Loaded %d locations\n", len(locations))
+    slog.DebugContext(r.Context(), "loaded locations", "count", len(locations))
 
     data := map[string]interface{}{
         "Locations": locations,
+        "AllOrgs":   scope.AllOrgs,
         "Active":    "locations",
         "Title":     "Локации",
+        "OpenForm":  openForm(r, "id", "/locations/form"),
     }
     
     if r.Header.Get("HX-Request") == "true" {
-        fmt.Printf("DEBUG: Rendering locations_list.html for HTMX\n")
         h.renderer.Render(w, "locations_list.html", data)
         return
     }
-    
-    fmt.Printf("DEBUG: Rendering locations.html for full page\n")
     h.renderer.Render(w, "locations_page.html", data)
 }
 
@@ -72,24 +54,27 @@ func (h *LocationHandler) GetLocationForm(w http.ResponseWriter, r *http.Request
     
     if idStr != "" {
         id, _ := strconv.ParseInt(idStr, 10, 64)
-        err := h.db.QueryRow(`
-            SELECT id, name, address, contact_person, contact_phone, 
-                   monthly_rent, rent_due_day, is_active
-            FROM locations WHERE id = $1
-        `, id).Scan(
-            &location.ID, &location.Name, &location.Address,
-            &location.ContactPerson, &location.ContactPhone,
-            &location.MonthlyRent, &location.RentDueDay, &location.IsActive,
-        )
-        if err != nil && err != sql.ErrNoRows {
-            http.Error(w, err.Error(), http.StatusInternalServerError)
+        var err error
+        location, err = h.locations.Get(r.Context(), scopeFor(r), id)
+        if err != nil && !errors.Is(err, repository.ErrNotFound) {
+            serverError(w, r, err)
             return
         }
     }
     
+    h.renderForm(w, location, idStr != "", nil)
+}
+
+// renderForm показывает форму локации; непустая form — ответ на неудачное
+// сохранение с ошибками полей.
+func (h *LocationHandler) renderForm(w http.ResponseWriter, location models.Location, edit bool, form *validate.Form) {
     data := map[string]interface{}{
         "Location": location,
-        "Edit":     idStr != "",
+        "Edit":     edit,
+    }
+    if form != nil {
+        h.renderer.RenderInvalid(w, modalBody, "location_form.html", data, form)
+        return
     }
     h.renderer.Render(w, "location_form.html", data)
 }
@@ -100,45 +85,52 @@ func (h *LocationHandler) SaveLocation(w http.ResponseWriter, r *http.Request) {
         return
     }
     
-    idStr := r.FormValue("id")
-    monthlyRent, _ := strconv.ParseFloat(r.FormValue("monthly_rent"), 64)
-    rentDueDay, _ := strconv.Atoi(r.FormValue("rent_due_day"))
-    isActive := r.FormValue("is_active") == "true"
+    form := validate.New(r.PostForm)
+    form.Required("name", "address", "contact_person", "contact_phone", "monthly_rent", "rent_due_day")
+    form.MaxLength("name", 255)
+    form.MaxLength("contact_person", 255)
+    form.MaxLength("contact_phone", 50)
+    form.Phone("contact_phone")
     
     location := models.Location{
-        Name:          r.FormValue("name"),
-        Address:       r.FormValue("address"),
-        ContactPerson: r.FormValue("contact_person"),
-        ContactPhone:  r.FormValue("contact_phone"),
-        MonthlyRent:   monthlyRent,
-        RentDueDay:    rentDueDay,
-        IsActive:      isActive,
+        ID:            form.ID("id"),
+        Version:       form.Int("version"),
+        Name:          form.Get("name"),
+        Address:       form.Get("address"),
+        ContactPerson: form.Get("contact_person"),
+        ContactPhone:  form.Get("contact_phone"),
+        MonthlyRent:   form.Money("monthly_rent"),
+        RentDueDay:    form.Int("rent_due_day"),
+        IsActive:      form.Get("is_active") == "true",
+    }
+    
+    form.NotNegative("monthly_rent", location.MonthlyRent)
+    form.Range("rent_due_day", location.RentDueDay, 1, 31)
+    if !form.Valid() {
+        h.renderForm(w, location, location.ID != 0, form)
+        return
     }
     
+    scope := scopeFor(r)
+    
     var err error
-    if idStr == "" || idStr == "0" {
-        _, err = h.db.Exec(`
-            INSERT INTO locations (name, address, contact_person, contact_phone, 
-                                 monthly_rent, rent_due_day, is_active)
-            VALUES ($1, $2, $3, $4, $5, $6, $7)
-        `, location.Name, location.Address, location.ContactPerson, 
-           location.ContactPhone, location.MonthlyRent, location.RentDueDay, location.IsActive)
+    if location.ID == 0 {
+        location.OrgID = scope.OrgID
+        err = h.locations.Create(r.Context(), &location)
     } else {
-        id, _ := strconv.ParseInt(idStr, 10, 64)
-        location.ID = id
-        
-        _, err = h.db.Exec(`
-            UPDATE locations 
-            SET name=$1, address=$2, contact_person=$3, contact_phone=$4,
-                monthly_rent=$5, rent_due_day=$6, is_active=$7
-            WHERE id=$8
-        `, location.Name, location.Address, location.ContactPerson,
-           location.ContactPhone, location.MonthlyRent, location.RentDueDay, 
-           location.IsActive, location.ID)
+        err = h.locations.Update(r.Context(), scope, location)
     }
     
+    if errors.Is(err, repository.ErrConflict) {
+        h.renderConflict(w, r, location)
+        return
+    }
+    if errors.Is(err, repository.ErrNotFound) {
+        http.Error(w, "Локация не найдена", http.StatusNotFound)
+        return
+    }
     if err != nil {
-        http.Error(w, "Database error: "+err.Error(), http.StatusInternalServerError)
+        serverError(w, r, err)
         return
     }
     
@@ -146,6 +138,47 @@ func (h *LocationHandler) SaveLocation(w http.ResponseWriter, r *http.Request) {
     h.ListLocations(w, r)
 }
 
+// renderConflict показывает, чем локация, сохраненная другим пользователем,
+// отличается от отправленной.
+func (h *LocationHandler) renderConflict(w http.ResponseWriter, r *http.Request, location models.Location) {
+    current, err := h.locations.Get(r.Context(), scopeFor(r), location.ID)
+    if errors.Is(err, repository.ErrNotFound) {
+        http.Error(w, "Локация не найдена", http.StatusNotFound)
+        return
+    }
+    if err != nil {
+        serverError(w, r, err)
+        return
+    }
+    
+    fields := []formField{
+        {Name: "name", Label: "Название локации"},
+        {Name: "address", Label: "Адрес"},
+        {Name: "contact_person", Label: "Контактное лицо"},
+        {Name: "contact_phone", Label: "Телефон"},
+        {Name: "monthly_rent", Label: "Ежемесячная аренда (₽)"},
+        {Name: "rent_due_day", Label: "День оплаты аренды"},
+        {Name: "is_active", Label: "Активная локация", Options: yesNo},
+    }
+    h.renderer.RenderConflict(w, modalBody, newConflict("/locations/save", "#locations-table",
+        fields, locationValues(location), locationValues(current)))
+}
+
+// locationValues переводит локацию в значения ее формы.
+func locationValues(l models.Location) url.Values {
+    return url.Values{
+        "id":             {formID(l.ID)},
+        "version":        {strconv.Itoa(l.Version)},
+        "name":           {l.Name},
+        "address":        {l.Address},
+        "contact_person": {l.ContactPerson},
+        "contact_phone":  {l.ContactPhone},
+        "monthly_rent":   {l.MonthlyRent.String()},
+        "rent_due_day":   {strconv.Itoa(l.RentDueDay)},
+        "is_active":      {flag(l.IsActive)},
+    }
+}
+
 func (h *LocationHandler) DeleteLocation(w http.ResponseWriter, r *http.Request) {
     idStr := r.URL.Query().Get("id")
     id, err := strconv.ParseInt(idStr, 10, 64)
@@ -154,9 +187,8 @@ func (h *LocationHandler) DeleteLocation(w http.ResponseWriter, r *http.Request)
         return
     }
     
-    _, err = h.db.Exec("DELETE FROM locations WHERE id = $1", id)
-    if err != nil {
-        http.Error(w, err.Error(), http.StatusInternalServerError)
+    if err := h.locations.Delete(r.Context(), scopeFor(r), id); err != nil {
+        serverError(w, r, err)
         return
     }
     
@@ -164,4 +196,65 @@ func (h *LocationHandler) DeleteLocation(w http.ResponseWriter, r *http.Request)
     w.WriteHeader(http.StatusOK)
     h.ListLocations(w, r) // ПРАВИЛЬНО: w, r
     
-}
\ No newline at end of file
+}
+// locationSales — продажи локации за период, лучшие товары первыми.
+type locationSales struct {
+    Name      string
+    OrgName   string
+    Sold      int
+    PlayValue money.Amount
+    Products  []models.ProductSales
+}
+
+// Sales показывает, какие игрушки лучше продаются на каждой локации,
+// чтобы менять ассортимент автоматов. Продажи считаются по пересчету
+// товаров при пополнениях.
+func (h *LocationHandler) Sales(w http.ResponseWriter, r *http.Request) {
+    form := validate.New(r.URL.Query())
+    today := time.Now()
+    from := form.Date("from")
+    if form.Get("from") == "" {
+        from = time.Date(today.Year(), today.Month(), 1, 0, 0, 0, 0, time.Local)
+    }
+    to := form.Date("to")
+    if form.Get("to") == "" {
+        to = today
+    }
+    form.Check(!to.Before(from), "to", "Конец периода раньше начала")
+    
+    scope := scopeFor(r)
+    data := map[string]interface{}{
+        "From":    from,
+        "To":      to,
+        "AllOrgs": scope.AllOrgs,
+    }
+    if !form.Valid() {
+        // Форма с ошибками показывается без отчета
+        data["Form"] = form
+        h.renderer.Render(w, "location_sales.html", data)
+        return
+    }
+    
+    // Границы — начало первого дня и конец последнего
+    start := time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, time.Local)
+    end := time.Date(to.Year(), to.Month(), to.Day()+1, 0, 0, 0, 0, time.Local)
+    sales, err := h.operations.Sales(r.Context(), scope, start, end)
+    if err != nil {
+        serverError(w, r, err)
+        return
+    }
+    
+    // Продажи приходят по локациям подряд
+    var locations []locationSales
+    for i, s := range sales {
+        if i == 0 || s.LocationID != sales[i-1].LocationID || s.OrgName != sales[i-1].OrgName {
+            locations = append(locations, locationSales{Name: s.LocationName, OrgName: s.OrgName})
+        }
+        l := &locations[len(locations)-1]
+        l.Sold += s.Sold
+        l.PlayValue += s.PlayValue
+        l.Products = append(l.Products, s)
+    }
+    data["Locations"] = locations
+    h.renderer.Render(w, "location_sales.html", data)
+}
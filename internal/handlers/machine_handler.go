@@ -1,162 +1,94 @@
 package handlers
 
 import (
-    "database/sql"
+    "errors"
     "fmt"
+    "log/slog"
     "net/http"
+    "net/url"
     "strconv"
-    "time"
     "vend_erp/internal/models"
+    "vend_erp/internal/repository"
+    "vend_erp/internal/validate"
 )
 
 type MachineHandler struct {
-    db       *sql.DB
-    renderer *TemplateRenderer
+    machines  repository.Machines
+    locations repository.Locations
+    products  repository.Products
+    renderer  *TemplateRenderer
 }
 
-func NewMachineHandler(db *sql.DB, renderer *TemplateRenderer) *MachineHandler {
-    return &MachineHandler{db: db, renderer: renderer}
+func NewMachineHandler(machines repository.Machines, locations repository.Locations, products repository.Products, renderer *TemplateRenderer) *MachineHandler {
+    return &MachineHandler{machines: machines, locations: locations, products: products, renderer: renderer}
 }
 
 func (h *MachineHandler) ListMachines(w http.ResponseWriter, r *http.Request) {
-    fmt.Printf("DEBUG: MachineHandler.ListMachines called for URL: %s\n", r.URL.Path)
-    
-    rows, err := h.db.Query(`
-        SELECT 
-            m.id, m.serial_number, m.model, m.status, 
-            m.current_toys_count, m.capacity_toys, m.cash_amount, 
-            m.last_maintenance_date, m.next_maintenance_date, m.installation_date,
-            m.created_at, m.updated_at,
-            m.location_id,
-            COALESCE(l.name, 'Не назначена') as location_name
-        FROM vending_machines m
-        LEFT JOIN locations l ON m.location_id = l.id
-        ORDER BY m.created_at DESC
-    `)
+    scope := scopeFor(r)
+    machines, err := h.machines.List(r.Context(), scope)
     if err != nil {
-        fmt.Printf("DEBUG: Machine query error: %v\n", err)
-        http.Error(w, err.Error(), http.StatusInternalServerError)
+        serverError(w, r, err)
         return
     }
-    defer rows.Close()
-
-    var machines []models.VendingMachine
-    for rows.Next() {
-        var machine models.VendingMachine
-        var lastMaintenanceDate, nextMaintenanceDate, installationDate, createdAt, updatedAt sql.NullTime
-        
-        err := rows.Scan(
-            &machine.ID, &machine.SerialNumber, &machine.Model, 
-            &machine.Status, &machine.CurrentToysCount, &machine.CapacityToys,
-            &machine.CashAmount, &lastMaintenanceDate, &nextMaintenanceDate, 
-            &installationDate, &createdAt, &updatedAt,
-            &machine.LocationID, &machine.LocationName,
-        )
-        if err != nil {
-            fmt.Printf("Error scanning machine: %v\n", err)
-            continue
-        }
-        
-        // Обработка nullable дат
-        if lastMaintenanceDate.Valid {
-            machine.LastMaintenanceDate = lastMaintenanceDate.Time
-        }
-        if nextMaintenanceDate.Valid {
-            machine.NextMaintenanceDate = nextMaintenanceDate.Time
-        }
-        if installationDate.Valid {
-            machine.InstallationDate = installationDate.Time
-        }
-        if createdAt.Valid {
-            machine.CreatedAt = createdAt.Time
-        }
-        if updatedAt.Valid {
-            machine.UpdatedAt = updatedAt.Time
-        }
-        
-        machines = append(machines, machine)
-    }
 
-    fmt.Printf("DEBUG: Loaded %d machines for template\n", len(machines))
+    slog.DebugContext(r.Context(), "loaded machines", "count", len(machines))
 
     data := map[string]interface{}{
         "Machines": machines,
+        "AllOrgs":  scope.AllOrgs,
         "Active":   "machines",
         "Title":    "Автоматы",
+        "OpenForm": openForm(r, "id", "/machines/form"),
     }
     
     if r.Header.Get("HX-Request") == "true" {
-        fmt.Printf("DEBUG: Rendering machines_list.html for HTMX\n")
         h.renderer.Render(w, "machines_list.html", data)
         return
     }
-    
-    fmt.Printf("DEBUG: Rendering machines.html for full page with %d machines\n", len(machines))
     h.renderer.Render(w, "machines_page.html", data)
 }
 
 func (h *MachineHandler) GetMachineForm(w http.ResponseWriter, r *http.Request) {
-    fmt.Printf("DEBUG: MachineHandler.GetMachineForm called\n")
     idStr := r.URL.Query().Get("id")
     var machine models.VendingMachine
     
     if idStr != "" {
         id, _ := strconv.ParseInt(idStr, 10, 64)
-        err := h.db.QueryRow(`
-            SELECT id, serial_number, model, status, location_id, 
-                   capacity_toys, current_toys_count, cash_amount,
-                   last_maintenance_date, next_maintenance_date, installation_date
-            FROM vending_machines WHERE id = $1
-        `, id).Scan(
-            &machine.ID, &machine.SerialNumber, &machine.Model, 
-            &machine.Status, &machine.LocationID, &machine.CapacityToys,
-            &machine.CurrentToysCount, &machine.CashAmount,
-            &machine.LastMaintenanceDate, &machine.NextMaintenanceDate, &machine.InstallationDate,
-        )
-        if err != nil && err != sql.ErrNoRows {
-            http.Error(w, err.Error(), http.StatusInternalServerError)
+        var err error
+        machine, err = h.machines.Get(r.Context(), scopeFor(r), id)
+        if err != nil && !errors.Is(err, repository.ErrNotFound) {
+            serverError(w, r, err)
             return
         }
     }
     
-    // Fetch all active locations for dropdown
-    locations, err := h.getActiveLocations()
+    h.renderForm(w, r, machine, idStr != "", nil)
+}
+
+// renderForm показывает форму автомата; непустая form — ответ на неудачное
+// сохранение с ошибками полей.
+func (h *MachineHandler) renderForm(w http.ResponseWriter, r *http.Request, machine models.VendingMachine, edit bool, form *validate.Form) {
+    // Fetch active locations of the machine's organization for dropdown
+    orgID := machine.OrgID
+    if orgID == 0 {
+        orgID = scopeFor(r).OrgID
+    }
+    locations, err := h.locations.ListActive(r.Context(), orgID)
     if err != nil {
-        http.Error(w, err.Error(), http.StatusInternalServerError)
+        serverError(w, r, err)
         return
     }
     
     data := map[string]interface{}{
         "Machine":   machine,
         "Locations": locations,
-        "Edit":      idStr != "",
-    }
-    h.renderer.Render(w, "machine_form.html", data)
-}
-
-// Helper function to get active locations
-func (h *MachineHandler) getActiveLocations() ([]models.Location, error) {
-    rows, err := h.db.Query(`
-        SELECT id, name, address 
-        FROM locations 
-        WHERE is_active = true 
-        ORDER BY name
-    `)
-    if err != nil {
-        return nil, err
+        "Edit":      edit,
     }
-    defer rows.Close()
-
-    var locations []models.Location
-    for rows.Next() {
-        var location models.Location
-        err := rows.Scan(&location.ID, &location.Name, &location.Address)
-        if err != nil {
-            continue
-        }
-        locations = append(locations, location)
+    if form != nil {
+        h.renderer.RenderInvalid(w, modalBody, "machine_form.html", data, form)
+        return
     }
-    return locations, nil
+    h.renderer.Render(w, "machine_form.html", data)
 }
 
 func (h *MachineHandler) SaveMachine(w http.ResponseWriter, r *http.Request) {
@@ -165,70 +97,83 @@ func (h *MachineHandler) SaveMachine(w http.ResponseWriter, r *http.Request) {
         return
     }
     
-    idStr := r.FormValue("id")
-    locationID, _ := strconv.ParseInt(r.FormValue("location_id"), 10, 64)
-    capacityToys, _ := strconv.Atoi(r.FormValue("capacity_toys"))
-    currentToysCount, _ := strconv.Atoi(r.FormValue("current_toys_count"))
-    cashAmount, _ := strconv.ParseFloat(r.FormValue("cash_amount"), 64)
+    form := validate.New(r.PostForm)
+    form.Required("serial_number", "model", "location_id")
+    form.MaxLength("serial_number", 100)
+    form.MaxLength("model", 255)
     
-    // Парсинг дат
-    var lastMaintenanceDate, nextMaintenanceDate, installationDate time.Time
-    if date := r.FormValue("last_maintenance_date"); date != "" {
-        lastMaintenanceDate, _ = time.Parse("2006-01-02", date)
-    }
-    if date := r.FormValue("next_maintenance_date"); date != "" {
-        nextMaintenanceDate, _ = time.Parse("2006-01-02", date)
+    machine := models.VendingMachine{
+        ID:                  form.ID("id"),
+        Version:             form.Int("version"),
+        SerialNumber:        form.Get("serial_number"),
+        Model:               form.Get("model"),
+        LocationID:          form.ID("location_id"),
+        CapacityToys:        form.Int("capacity_toys"),
+        CurrentToysCount:    form.Int("current_toys_count"),
+        CashAmount:          form.Money("cash_amount"),
+        Status:              form.OneOf("status", "active", "maintenance", "inactive"),
+        LastMaintenanceDate: form.Date("last_maintenance_date"),
+        NextMaintenanceDate: form.Date("next_maintenance_date"),
+        InstallationDate:    form.Date("installation_date"),
     }
-    if date := r.FormValue("installation_date"); date != "" {
-        installationDate, _ = time.Parse("2006-01-02", date)
+    
+    form.Min("capacity_toys", machine.CapacityToys, 0)
+    form.Min("current_toys_count", machine.CurrentToysCount, 0)
+    form.Check(machine.CurrentToysCount <= machine.CapacityToys, "current_toys_count",
+        "Не может превышать вместимость")
+    form.NotNegative("cash_amount", machine.CashAmount)
+    if !machine.LastMaintenanceDate.IsZero() && !machine.NextMaintenanceDate.IsZero() {
+        form.Check(!machine.NextMaintenanceDate.Before(machine.LastMaintenanceDate), "next_maintenance_date",
+            "Не раньше последнего обслуживания")
     }
     
-    machine := models.VendingMachine{
-        SerialNumber:        r.FormValue("serial_number"),
-        Model:               r.FormValue("model"),
-        LocationID:          locationID,
-        CapacityToys:        capacityToys,
-        CurrentToysCount:    currentToysCount,
-        CashAmount:          cashAmount,
-        Status:              r.FormValue("status"),
-        LastMaintenanceDate: lastMaintenanceDate,
-        NextMaintenanceDate: nextMaintenanceDate,
-        InstallationDate:    installationDate,
+    // Автомат и его локация должны принадлежать одной организации
+    scope := scopeFor(r)
+    machine.OrgID = scope.OrgID
+    if machine.ID != 0 {
+        current, err := h.machines.Get(r.Context(), scope, machine.ID)
+        if errors.Is(err, repository.ErrNotFound) {
+            http.Error(w, "Автомат не найден", http.StatusNotFound)
+            return
+        }
+        if err != nil {
+            serverError(w, r, err)
+            return
+        }
+        machine.OrgID = current.OrgID
+    }
+    if machine.LocationID != 0 {
+        _, err := h.locations.Get(r.Context(), repository.Scope{OrgID: machine.OrgID}, machine.LocationID)
+        if errors.Is(err, repository.ErrNotFound) {
+            form.Fail("location_id", "Локация не найдена")
+        } else if err != nil {
+            serverError(w, r, err)
+            return
+        }
+    }
+    if !form.Valid() {
+        h.renderForm(w, r, machine, machine.ID != 0, form)
+        return
     }
     
     var err error
-    if idStr == "" || idStr == "0" {
-        _, err = h.db.Exec(`
-            INSERT INTO vending_machines 
-            (serial_number, model, location_id, status, capacity_toys, 
-             current_toys_count, cash_amount, last_maintenance_date, 
-             next_maintenance_date, installation_date)
-            VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
-        `, machine.SerialNumber, machine.Model, machine.LocationID, machine.Status,
-           machine.CapacityToys, machine.CurrentToysCount, machine.CashAmount,
-           nullIfZeroTime(machine.LastMaintenanceDate),
-           nullIfZeroTime(machine.NextMaintenanceDate),
-           nullIfZeroTime(machine.InstallationDate))
+    if machine.ID == 0 {
+        err = h.machines.Create(r.Context(), &machine)
     } else {
-        id, _ := strconv.ParseInt(idStr, 10, 64)
-        machine.ID = id
-        
-        _, err = h.db.Exec(`
-            UPDATE vending_machines 
-            SET serial_number=$1, model=$2, location_id=$3, status=$4,
-                capacity_toys=$5, current_toys_count=$6, cash_amount=$7,
-                last_maintenance_date=$8, next_maintenance_date=$9, 
-                installation_date=$10, updated_at=CURRENT_TIMESTAMP
-            WHERE id=$11
-        `, machine.SerialNumber, machine.Model, machine.LocationID, machine.Status,
-           machine.CapacityToys, machine.CurrentToysCount, machine.CashAmount,
-           nullIfZeroTime(machine.LastMaintenanceDate),
-           nullIfZeroTime(machine.NextMaintenanceDate),
-           nullIfZeroTime(machine.InstallationDate), machine.ID)
+        err = h.machines.Update(r.Context(), scope, machine)
     }
     
+    if errors.Is(err, repository.ErrConflict) {
+        h.renderConflict(w, r, machine)
+        return
+    }
+    if errors.Is(err, repository.ErrDuplicate) {
+        form.Fail("serial_number", "Автомат с таким серийным номером уже есть")
+        h.renderForm(w, r, machine, machine.ID != 0, form)
+        return
+    }
     if err != nil {
-        http.Error(w, "Database error: "+err.Error(), http.StatusInternalServerError)
+        serverError(w, r, err)
         return
     }
     
@@ -236,6 +181,66 @@ func (h *MachineHandler) SaveMachine(w http.ResponseWriter, r *http.Request) {
     h.ListMachines(w, r)
 }
 
+// renderConflict показывает, чем автомат, сохраненный другим пользователем,
+// отличается от отправленного.
+func (h *MachineHandler) renderConflict(w http.ResponseWriter, r *http.Request, machine models.VendingMachine) {
+    current, err := h.machines.Get(r.Context(), scopeFor(r), machine.ID)
+    if errors.Is(err, repository.ErrNotFound) {
+        http.Error(w, "Автомат не найден", http.StatusNotFound)
+        return
+    }
+    if err != nil {
+        serverError(w, r, err)
+        return
+    }
+    locations, err := h.locations.ListActive(r.Context(), current.OrgID)
+    if err != nil {
+        serverError(w, r, err)
+        return
+    }
+    
+    fields := []formField{
+        {Name: "serial_number", Label: "Серийный номер"},
+        {Name: "model", Label: "Модель"},
+        {Name: "location_id", Label: "Локация", Options: idOptions(locations,
+            func(l models.Location) int64 { return l.ID },
+            func(l models.Location) string { return l.Name })},
+        {Name: "status", Label: "Статус", Options: machineStatuses},
+        {Name: "capacity_toys", Label: "Вместимость игрушек"},
+        {Name: "current_toys_count", Label: "Текущее кол-во игрушек"},
+        {Name: "cash_amount", Label: "Наличные (₽)"},
+        {Name: "installation_date", Label: "Дата установки"},
+        {Name: "last_maintenance_date", Label: "Дата последнего обслуживания"},
+        {Name: "next_maintenance_date", Label: "Дата следующего обслуживания"},
+    }
+    h.renderer.RenderConflict(w, modalBody, newConflict("/machines/save", "#machines-table",
+        fields, machineValues(machine), machineValues(current)))
+}
+
+var machineStatuses = map[string]string{
+    "active":      "Активен",
+    "maintenance": "Обслуживание",
+    "inactive":    "Неактивен",
+}
+
+// machineValues переводит автомат в значения его формы.
+func machineValues(m models.VendingMachine) url.Values {
+    return url.Values{
+        "id":                    {formID(m.ID)},
+        "version":               {strconv.Itoa(m.Version)},
+        "serial_number":         {m.SerialNumber},
+        "model":                 {m.Model},
+        "location_id":           {formID(m.LocationID)},
+        "status":                {m.Status},
+        "capacity_toys":         {strconv.Itoa(m.CapacityToys)},
+        "current_toys_count":    {strconv.Itoa(m.CurrentToysCount)},
+        "cash_amount":           {m.CashAmount.String()},
+        "installation_date":     {formDate(m.InstallationDate)},
+        "last_maintenance_date": {formDate(m.LastMaintenanceDate)},
+        "next_maintenance_date": {formDate(m.NextMaintenanceDate)},
+    }
+}
+
 func (h *MachineHandler) DeleteMachine(w http.ResponseWriter, r *http.Request) {
     idStr := r.URL.Query().Get("id")
     id, err := strconv.ParseInt(idStr, 10, 64)
@@ -244,9 +249,8 @@ func (h *MachineHandler) DeleteMachine(w http.ResponseWriter, r *http.Request) {
         return
     }
     
-    _, err = h.db.Exec("DELETE FROM vending_machines WHERE id = $1", id)
-    if err != nil {
-        http.Error(w, err.Error(), http.StatusInternalServerError)
+    if err := h.machines.Delete(r.Context(), scopeFor(r), id); err != nil {
+        serverError(w, r, err)
         return
     }
     
@@ -255,10 +259,163 @@ func (h *MachineHandler) DeleteMachine(w http.ResponseWriter, r *http.Request) {
     h.ListMachines(w, r)
 }
 
-// Вспомогательная функция для обработки нулевых дат
-func nullIfZeroTime(t time.Time) interface{} {
-    if t.IsZero() {
-        return nil
+// GetPlanogram показывает выкладку автомата: какие товары в него
+// загружаются, сколько и по какой цене игры.
+func (h *MachineHandler) GetPlanogram(w http.ResponseWriter, r *http.Request) {
+    id, _ := strconv.ParseInt(r.URL.Query().Get("id"), 10, 64)
+    machine, ok := h.getMachine(w, r, id)
+    if !ok {
+        return
+    }
+    items, err := h.machines.Planogram(r.Context(), scopeFor(r), machine.ID)
+    if err != nil {
+        serverError(w, r, err)
+        return
+    }
+    h.renderPlanogram(w, r, machine, items, nil)
+}
+
+// renderPlanogram показывает форму выкладки; непустая form — ответ
+// на неудачное сохранение с ошибками полей.
+func (h *MachineHandler) renderPlanogram(w http.ResponseWriter, r *http.Request, machine models.VendingMachine, items []models.PlanogramItem, form *validate.Form) {
+    products, err := h.products.ListActive(r.Context(), machine.OrgID)
+    if err != nil {
+        serverError(w, r, err)
+        return
+    }
+    
+    // Товар выкладки может быть уже неактивным; в список добавления
+    // попадают только товары, которых в выкладке нет
+    inPlanogram := make(map[int64]bool, len(items))
+    target := 0
+    for _, item := range items {
+        inPlanogram[item.ProductID] = true
+        target += item.TargetCount
+    }
+    var available []models.Product
+    for _, p := range products {
+        if !inPlanogram[p.ID] {
+            available = append(available, p)
+        }
+    }
+    
+    data := map[string]interface{}{
+        "Machine":  machine,
+        "Items":    items,
+        "Products": available,
+        "Target":   target,
+    }
+    if form != nil {
+        h.renderer.RenderInvalid(w, modalBody, "machine_planogram.html", data, form)
+        return
+    }
+    h.renderer.Render(w, "machine_planogram.html", data)
+}
+
+// SavePlanogram заменяет выкладку автомата: строки с отметкой «убрать»
+// удаляются, заполненная строка добавления становится новой позицией.
+func (h *MachineHandler) SavePlanogram(w http.ResponseWriter, r *http.Request) {
+    if r.Method != http.MethodPost {
+        http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+        return
+    }
+    if err := r.ParseForm(); err != nil {
+        http.Error(w, err.Error(), http.StatusBadRequest)
+        return
+    }
+    
+    form := validate.New(r.PostForm)
+    machine, ok := h.getMachine(w, r, form.ID("machine_id"))
+    if !ok {
+        return
+    }
+    current, err := h.machines.Planogram(r.Context(), scopeFor(r), machine.ID)
+    if err != nil {
+        serverError(w, r, err)
+        return
+    }
+    names := make(map[int64]models.PlanogramItem, len(current))
+    for _, item := range current {
+        names[item.ProductID] = item
+    }
+    
+    // rows — все строки формы для повторного показа, items — сохраняемые
+    var rows, items []models.PlanogramItem
+    for _, value := range r.PostForm["product_id"] {
+        productID, err := strconv.ParseInt(value, 10, 64)
+        if err != nil {
+            http.Error(w, "Invalid product ID", http.StatusBadRequest)
+            return
+        }
+        target, price := fmt.Sprintf("target_%d", productID), fmt.Sprintf("price_%d", productID)
+        item := names[productID]
+        item.ProductID = productID
+        item.TargetCount = form.Int(target)
+        item.PricePerPlay = form.Money(price)
+        rows = append(rows, item)
+        if form.Get(fmt.Sprintf("remove_%d", productID)) != "" {
+            continue
+        }
+        form.Min(target, item.TargetCount, 1)
+        form.NotNegative(price, item.PricePerPlay)
+        items = append(items, item)
+    }
+    if productID := form.ID("new_product_id"); productID != 0 {
+        form.Required("new_target")
+        item := models.PlanogramItem{
+            ProductID:    productID,
+            TargetCount:  form.Int("new_target"),
+            PricePerPlay: form.Money("new_price"),
+        }
+        form.Min("new_target", item.TargetCount, 1)
+        form.NotNegative("new_price", item.PricePerPlay)
+        _, dup := names[productID]
+        form.Check(!dup, "new_product_id", "Товар уже есть в выкладке")
+        items = append(items, item)
+    }
+    
+    // Целевые количества вместе должны помещаться в автомат
+    total := 0
+    for _, item := range items {
+        total += item.TargetCount
+    }
+    form.Check(total <= machine.CapacityToys, "planogram",
+        fmt.Sprintf("Выкладка на %d шт. не помещается в автомат вместимостью %d", total, machine.CapacityToys))
+    if !form.Valid() {
+        h.renderPlanogram(w, r, machine, rows, form)
+        return
     }
-    return t
-}
\ No newline at end of file
+    
+    err = h.machines.SetPlanogram(r.Context(), scopeFor(r), machine.ID, items)
+    if errors.Is(err, repository.ErrNotFound) || errors.Is(err, repository.ErrDuplicate) {
+        form.Fail("new_product_id", "Товар не найден")
+        h.renderPlanogram(w, r, machine, rows, form)
+        return
+    }
+    if err != nil {
+        serverError(w, r, err)
+        return
+    }
+    
+    saved, err := h.machines.Planogram(r.Context(), scopeFor(r), machine.ID)
+    if err != nil {
+        serverError(w, r, err)
+        return
+    }
+    w.Header().Set("HX-Trigger", "planogramSaved")
+    h.renderPlanogram(w, r, machine, saved, nil)
+}
+
+// getMachine читает автомат области; если его нет, отвечает 404.
+func (h *MachineHandler) getMachine(w http.ResponseWriter, r *http.Request, id int64) (models.VendingMachine, bool) {
+    machine, err := h.machines.Get(r.Context(), scopeFor(r), id)
+    if errors.Is(err, repository.ErrNotFound) {
+        http.Error(w, "Автомат не найден", http.StatusNotFound)
+        return machine, false
+    }
+    if err != nil {
+        serverError(w, r, err)
+        return machine, false
+    }
+    return machine, true
+}
@@ -0,0 +1,226 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"vend_erp/config"
+	"vend_erp/internal/models"
+	"vend_erp/internal/oidc"
+	"vend_erp/internal/repository"
+)
+
+const oidcFlowCookie = "oidc_flow"
+
+// OIDCHandler обрабатывает вход через внешний OpenID Connect провайдер.
+// Сессия после успешного входа создается так же, как при входе по паролю.
+type OIDCHandler struct {
+	users  repository.Users
+	orgs   repository.Organizations
+	auth   *AuthHandler
+	client *oidc.Client
+}
+
+func NewOIDCHandler(users repository.Users, orgs repository.Organizations, auth *AuthHandler, client *oidc.Client) *OIDCHandler {
+	return &OIDCHandler{users: users, orgs: orgs, auth: auth, client: client}
+}
+
+// Login перенаправляет пользователя на страницу входа IdP.
+func (h *OIDCHandler) Login(w http.ResponseWriter, r *http.Request) {
+	flow, err := oidc.NewFlow()
+	if err != nil {
+		serverError(w, r, err)
+		return
+	}
+
+	authURL, err := h.client.AuthCodeURL(r.Context(), flow)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "OIDC login", "err", err)
+		h.fail(w, "Провайдер входа недоступен, попробуйте позже")
+		return
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     oidcFlowCookie,
+		Value:    strings.Join([]string{flow.State, flow.Nonce, flow.Verifier}, "|"),
+		Expires:  time.Now().Add(10 * time.Minute),
+		Path:     "/auth/oidc",
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+	http.Redirect(w, r, authURL, http.StatusFound)
+}
+
+// Callback завершает authorization code flow и создает сессию.
+func (h *OIDCHandler) Callback(w http.ResponseWriter, r *http.Request) {
+	flow, ok := readFlowCookie(r)
+	// Cookie одноразовая: удаляем ее при любом исходе
+	http.SetCookie(w, &http.Cookie{
+		Name:     oidcFlowCookie,
+		Value:    "",
+		Expires:  time.Now().Add(-time.Hour),
+		Path:     "/auth/oidc",
+		HttpOnly: true,
+	})
+
+	query := r.URL.Query()
+	if idpError := query.Get("error"); idpError != "" {
+		slog.WarnContext(r.Context(), "OIDC provider returned error", "error", idpError, "description", query.Get("error_description"))
+		h.fail(w, "Вход через "+h.client.ProviderName()+" отменен")
+		return
+	}
+	if !ok || query.Get("state") == "" || query.Get("state") != flow.State {
+		h.fail(w, "Сессия входа устарела, попробуйте еще раз")
+		return
+	}
+
+	identity, err := h.client.Exchange(r.Context(), query.Get("code"), flow)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "OIDC callback", "err", err)
+		h.fail(w, "Не удалось подтвердить вход через "+h.client.ProviderName())
+		return
+	}
+	userID, err := h.resolveUser(r.Context(), identity)
+	if err != nil {
+		slog.WarnContext(r.Context(), "OIDC user rejected", "email", identity.Email, "err", err)
+		h.fail(w, err.Error())
+		return
+	}
+
+	if err := h.auth.startSession(w, r, userID); err != nil {
+		serverError(w, r, err)
+		return
+	}
+
+	http.Redirect(w, r, "/dashboard", http.StatusSeeOther)
+}
+
+// resolveUser находит пользователя по (issuer, subject), затем — только для
+// доверенного IdP (OIDC_TRUST_EMAIL) — по email, и при разрешенном
+// auto-provision создает нового. Уже связанный пользователь входит при любом
+// email_verified; связывание и создание требуют подтвержденного email. Роль
+// обновляется из групп IdP, только если одна из групп сопоставлена
+// в настройках.
+func (h *OIDCHandler) resolveUser(ctx context.Context, identity *oidc.Identity) (int64, error) {
+	role, mapped := h.client.MapRole(identity.Groups)
+
+	user, err := h.users.FindBySubject(ctx, identity.Issuer, identity.Subject)
+
+	if errors.Is(err, repository.ErrNotFound) {
+		if !identity.EmailVerified {
+			return 0, fmt.Errorf("Email в учетной записи %s не подтвержден", h.client.ProviderName())
+		}
+		taken, existsErr := h.users.Exists(ctx, identity.Email, "")
+		if existsErr != nil {
+			return 0, fmt.Errorf("Ошибка входа, попробуйте позже")
+		}
+		switch {
+		case taken && h.client.LinkByEmail(identity):
+			// Связываем существующую учетную запись по email, если она
+			// еще не связана с другим входом
+			user, err = h.users.LinkSubject(ctx, identity.Email, identity.Issuer, identity.Subject)
+			if errors.Is(err, repository.ErrNotFound) {
+				return 0, fmt.Errorf("Учетная запись %s уже связана с другим входом через %s", identity.Email, h.client.ProviderName())
+			}
+		case taken:
+			// Без доверия к IdP чужой email не дает доступа к учетной записи
+			return 0, fmt.Errorf("Учетная запись %s не связана с %s: войдите по паролю", identity.Email, h.client.ProviderName())
+		}
+	}
+
+	if errors.Is(err, repository.ErrNotFound) {
+		// Режим регистрации действует и для SSO: в режиме приглашений новые
+		// пользователи не создаются, в режиме подтверждения ждут администратора
+		if !h.client.AutoProvision() || h.auth.signupMode == config.SignupInvite {
+			return 0, fmt.Errorf("Учетная запись %s не зарегистрирована", identity.Email)
+		}
+		status := models.UserStatusActive
+		if h.auth.signupMode == config.SignupApproval {
+			status = models.UserStatusPending
+		}
+		userID, err := h.provisionUser(ctx, identity, role, status)
+		if err == nil && status != models.UserStatusActive {
+			return 0, errors.New(statusError(status))
+		}
+		return userID, err
+	}
+	if err != nil {
+		return 0, fmt.Errorf("Ошибка входа, попробуйте позже")
+	}
+
+	if user.Status != models.UserStatusActive {
+		return 0, errors.New(statusError(user.Status))
+	}
+
+	if mapped {
+		if err := h.users.SetRole(ctx, user.ID, role); err != nil {
+			slog.WarnContext(ctx, "OIDC role sync failed", "user_id", user.ID, "err", err)
+		}
+	}
+
+	return user.ID, nil
+}
+
+func (h *OIDCHandler) provisionUser(ctx context.Context, identity *oidc.Identity, role string, status int) (int64, error) {
+	username := identity.Username
+	if username == "" {
+		username, _, _ = strings.Cut(identity.Email, "@")
+	}
+
+	// Имя пользователя уникально: при совпадении добавляем суффикс
+	exists, err := h.users.Exists(ctx, "", username)
+	if err != nil {
+		return 0, fmt.Errorf("Ошибка создания аккаунта")
+	}
+	if exists {
+		username = username + "-" + identity.Subject
+	}
+
+	org, err := h.orgs.FindBySlug(ctx, defaultOrgSlug)
+	if err != nil {
+		slog.ErrorContext(ctx, "OIDC default organization", "err", err)
+		return 0, fmt.Errorf("Ошибка создания аккаунта")
+	}
+
+	// Пароль пустой: такой пользователь может входить только через SSO
+	user := models.User{
+		Username:     username,
+		Email:        identity.Email,
+		UserRole:     role,
+		Status:       status,
+		FullUserName: identity.Name,
+		OrgID:        org.ID,
+	}
+	if err := h.users.Provision(ctx, &user, identity.Issuer, identity.Subject); err != nil {
+		slog.ErrorContext(ctx, "OIDC provisioning", "email", identity.Email, "err", err)
+		return 0, fmt.Errorf("Ошибка создания аккаунта")
+	}
+
+	slog.InfoContext(ctx, "OIDC provisioned user", "email", identity.Email, "user_id", user.ID, "role", role)
+	return user.ID, nil
+}
+
+func (h *OIDCHandler) fail(w http.ResponseWriter, message string) {
+	h.auth.renderAuth(w, AuthData{
+		Error:  message,
+		Title:  "Вход в систему",
+		Active: "auth",
+	})
+}
+
+func readFlowCookie(r *http.Request) (oidc.Flow, bool) {
+	cookie, err := r.Cookie(oidcFlowCookie)
+	if err != nil {
+		return oidc.Flow{}, false
+	}
+	parts := strings.Split(cookie.Value, "|")
+	if len(parts) != 3 {
+		return oidc.Flow{}, false
+	}
+	return oidc.Flow{State: parts[0], Nonce: parts[1], Verifier: parts[2]}, true
+}
@@ -1,190 +1,320 @@
 package handlers
 
 import (
-    "database/sql"
+    "errors"
     "fmt"
+    "log/slog"
     "net/http"
+    "net/url"
+    "slices"
     "strconv"
+    "strings"
     "time"
     "vend_erp/internal/models"
+    "vend_erp/internal/repository"
+    "vend_erp/internal/validate"
 )
 
 type OperationHandler struct {
-    db       *sql.DB
-    renderer *TemplateRenderer
+    operations repository.Operations
+    machines   repository.Machines
+    users      repository.Users
+    inventory  repository.Inventory
+    renderer   *TemplateRenderer
 }
 
-func NewOperationHandler(db *sql.DB, renderer *TemplateRenderer) *OperationHandler {
-    return &OperationHandler{db: db, renderer: renderer}
+func NewOperationHandler(operations repository.Operations, machines repository.Machines, users repository.Users, inventory repository.Inventory, renderer *TemplateRenderer) *OperationHandler {
+    return &OperationHandler{operations: operations, machines: machines, users: users, inventory: inventory, renderer: renderer}
 }
 
 func (h *OperationHandler) ListOperations(w http.ResponseWriter, r *http.Request) {
-    fmt.Printf("DEBUG: OperationHandler.ListOperations called for URL: %s\n", r.URL.Path)
-    
-    rows, err := h.db.Query(`
-        SELECT 
-            o.id, o.vending_machine_id, o.operation_type, o.performed_by,
-            o.operation_date, o.toys_before, o.toys_after, o.toys_added,
-            o.cash_before, o.cash_after, o.cash_collected,
-            o.created_at, o.updated_at,
-            vm.serial_number as machine_serial,
-            u.username as performer_name
-        FROM vending_operations o
-        LEFT JOIN vending_machines vm ON o.vending_machine_id = vm.id
-        LEFT JOIN users u ON o.performed_by = u.id
-        ORDER BY o.operation_date DESC
-    `)
+    scope := scopeFor(r)
+    operations, err := h.operations.List(r.Context(), scope)
     if err != nil {
-        fmt.Printf("DEBUG: Operations query error: %v\n", err)
-        http.Error(w, err.Error(), http.StatusInternalServerError)
+        serverError(w, r, err)
         return
     }
-    defer rows.Close()
-
-    var operations []models.VendingOperation
-    for rows.Next() {
-        var operation models.VendingOperation
-        var operationDate, createdAt, updatedAt sql.NullTime
-        
-        err := rows.Scan(
-            &operation.ID, &operation.VendingMachineID, &operation.OperationType, 
-            &operation.PerformedBy, &operationDate, &operation.ToysBefore, 
-            &operation.ToysAfter, &operation.ToysAdded, &operation.CashBefore,
-            &operation.CashAfter, &operation.CashCollected, &createdAt, &updatedAt,
-            &operation.MachineSerial, &operation.PerformerName,
-        )
-        if err != nil {
-            fmt.Printf("Error scanning operation: %v\n", err)
-            continue
-        }
-        
-        // Handle nullable dates
-        if operationDate.Valid {
-            operation.OperationDate = operationDate.Time
-        }
-        if createdAt.Valid {
-            operation.CreatedAt = createdAt.Time
-        }
-        if updatedAt.Valid {
-            operation.UpdatedAt = updatedAt.Time
-        }
-        
-        operations = append(operations, operation)
-    }
 
-    fmt.Printf("DEBUG: Loaded %d operations for template\n", len(operations))
+    slog.DebugContext(r.Context(), "loaded operations", "count", len(operations))
 
     data := map[string]interface{}{
         "Operations": operations,
+        "AllOrgs":    scope.AllOrgs,
         "Active":     "operations",
         "Title":      "Операции",
+        "OpenForm":   openForm(r, "machine_id", "/operations/form"),
     }
     
     if r.Header.Get("HX-Request") == "true" {
-        fmt.Printf("DEBUG: Rendering operations_list.html for HTMX\n")
         h.renderer.Render(w, "operations_list.html", data)
         return
     }
-    
-    fmt.Printf("DEBUG: Rendering operations.html for full page with %d operations\n", len(operations))
     h.renderer.Render(w, "operations_page.html", data)
 }
 
 func (h *OperationHandler) GetOperationForm(w http.ResponseWriter, r *http.Request) {
-    fmt.Printf("DEBUG: OperationHandler.GetOperationForm called\n")
     idStr := r.URL.Query().Get("id")
     var operation models.VendingOperation
     
     if idStr != "" {
         id, _ := strconv.ParseInt(idStr, 10, 64)
-        err := h.db.QueryRow(`
-            SELECT id, vending_machine_id, operation_type, performed_by,
-                   operation_date, toys_before, toys_after, toys_added,
-                   cash_before, cash_after, cash_collected
-            FROM vending_operations WHERE id = $1
-        `, id).Scan(
-            &operation.ID, &operation.VendingMachineID, &operation.OperationType,
-            &operation.PerformedBy, &operation.OperationDate, &operation.ToysBefore,
-            &operation.ToysAfter, &operation.ToysAdded, &operation.CashBefore,
-            &operation.CashAfter, &operation.CashCollected,
-        )
-        if err != nil && err != sql.ErrNoRows {
-            http.Error(w, err.Error(), http.StatusInternalServerError)
+        var err error
+        operation, err = h.operations.Get(r.Context(), scopeFor(r), id)
+        if err != nil && !errors.Is(err, repository.ErrNotFound) {
+            serverError(w, r, err)
+            return
+        }
+    } else if machineID, _ := strconv.ParseInt(r.URL.Query().Get("machine_id"), 10, 64); machineID != 0 {
+        // Форма по QR-коду автомата: автомат, его игрушки и наличные
+        // уже известны, исполнитель — тот, кто сканировал
+        machine, err := h.machines.Get(r.Context(), scopeFor(r), machineID)
+        if err != nil && !errors.Is(err, repository.ErrNotFound) {
+            serverError(w, r, err)
             return
         }
+        if err == nil {
+            operation = models.VendingOperation{
+                OrgID:            machine.OrgID,
+                VendingMachineID: machine.ID,
+                PerformedBy:      CurrentUser(r).ID,
+                OperationDate:    time.Now(),
+                ToysBefore:       machine.CurrentToysCount,
+                CashBefore:       machine.CashAmount,
+            }
+        }
     }
     
-    // Fetch machines and users for dropdowns
-    machines, err := h.getActiveMachines()
+    h.renderForm(w, r, operation, idStr != "", nil)
+}
+
+// renderForm показывает форму операции; непустая form — ответ на неудачное
+// сохранение с ошибками полей.
+func (h *OperationHandler) renderForm(w http.ResponseWriter, r *http.Request, operation models.VendingOperation, edit bool, form *validate.Form) {
+    // Fetch machines and users of the operation's organization for dropdowns
+    orgID := operation.OrgID
+    if orgID == 0 {
+        orgID = scopeFor(r).OrgID
+    }
+    machines, err := h.machines.ListActive(r.Context(), orgID)
     if err != nil {
-        http.Error(w, err.Error(), http.StatusInternalServerError)
+        serverError(w, r, err)
         return
     }
     
-    users, err := h.getActiveUsers()
+    users, err := h.users.ListActive(r.Context(), orgID)
     if err != nil {
-        http.Error(w, err.Error(), http.StatusInternalServerError)
+        serverError(w, r, err)
+        return
+    }
+    rows, err := h.restockRows(r, orgID, operation)
+    if err != nil {
+        serverError(w, r, err)
+        return
+    }
+    warehouses, err := h.inventory.Warehouses(r.Context(), OrgScope{OrgID: orgID})
+    if err != nil {
+        serverError(w, r, err)
         return
     }
     
     data := map[string]interface{}{
-        "Operation": operation,
-        "Machines":  machines,
-        "Users":     users,
-        "Edit":      idStr != "",
+        "Operation":  operation,
+        "Machines":   machines,
+        "Users":      users,
+        "Warehouses": warehouses,
+        "Restock":    rows,
+        "Edit":       edit,
+    }
+    if form != nil {
+        h.renderer.RenderInvalid(w, modalBody, "operation_form.html", data, form)
+        return
     }
     h.renderer.Render(w, "operation_form.html", data)
 }
 
-// Helper function to get active machines
-func (h *OperationHandler) getActiveMachines() ([]models.VendingMachine, error) {
-    rows, err := h.db.Query(`
-        SELECT vm.id, vm.serial_number, COALESCE(l.name, 'Не назначена') as location_name 
-        FROM vending_machines vm
-        LEFT JOIN locations l ON vm.location_id = l.id
-        WHERE vm.status = 'active' 
-        ORDER BY vm.serial_number
-    `)
+// RestockItems перерисовывает форму операции для выбранных автомата
+// и типа; форма берет из ответа только строки пополнения по товарам.
+func (h *OperationHandler) RestockItems(w http.ResponseWriter, r *http.Request) {
+    form := validate.New(r.URL.Query())
+    operation := operationFromForm(form)
+    operation.OrgID = scopeFor(r).OrgID
+    if operation.ID != 0 {
+        current, err := h.operations.Get(r.Context(), scopeFor(r), operation.ID)
+        if errors.Is(err, repository.ErrNotFound) {
+            http.Error(w, "Операция не найдена", http.StatusNotFound)
+            return
+        }
+        if err != nil {
+            serverError(w, r, err)
+            return
+        }
+        operation.OrgID = current.OrgID
+        if current.VendingMachineID == operation.VendingMachineID {
+            operation.Items = current.Items
+        }
+    }
+    h.renderForm(w, r, operation, operation.ID != 0, nil)
+}
+
+// operationFromForm читает поля операции, кроме товаров пополнения.
+func operationFromForm(form *validate.Form) models.VendingOperation {
+    return models.VendingOperation{
+        ID:               form.ID("id"),
+        Version:          form.Int("version"),
+        VendingMachineID: form.ID("vending_machine_id"),
+        WarehouseID:      form.ID("warehouse_id"),
+        OperationType:    form.OneOf("operation_type", "restock", "collection", "maintenance"),
+        PerformedBy:      form.ID("performed_by"),
+        OperationDate:    form.DateTime("operation_date"),
+        ToysBefore:       form.Int("toys_before"),
+        ToysAfter:        form.Int("toys_after"),
+        ToysAdded:        form.Int("toys_added"),
+        CashBefore:       form.Money("cash_before"),
+        CashAfter:        form.Money("cash_after"),
+        CashCollected:    form.Money("cash_collected"),
+    }
+}
+
+// restockRow — строка пополнения по товару в форме операции.
+type restockRow struct {
+    models.OperationItem
+    // TargetCount — цель по выкладке; 0, если товара в выкладке уже нет
+    TargetCount int
+    // Stocked — товар есть на складе пополнения; Available — сколько его
+    // доступно там вместе с уже выданным этой операцией
+    Stocked   bool
+    Available int
+}
+
+// restockRows возвращает строки пополнения: товары выкладки автомата
+// и товары, уже записанные в операции. Для других типов операций
+// и без выбранного автомата строк нет.
+func (h *OperationHandler) restockRows(r *http.Request, orgID int64, operation models.VendingOperation) ([]restockRow, error) {
+    if operation.OperationType != "restock" || operation.VendingMachineID == 0 {
+        return nil, nil
+    }
+    planogram, err := h.machines.Planogram(r.Context(), repository.Scope{OrgID: orgID}, operation.VendingMachineID)
     if err != nil {
         return nil, err
     }
-    defer rows.Close()
-
-    var machines []models.VendingMachine
-    for rows.Next() {
-        var machine models.VendingMachine
-        err := rows.Scan(&machine.ID, &machine.SerialNumber, &machine.LocationName)
-        if err != nil {
+    
+    var rows []restockRow
+    index := make(map[int64]int)
+    for _, p := range planogram {
+        index[p.ProductID] = len(rows)
+        rows = append(rows, restockRow{
+            OperationItem: models.OperationItem{
+                ProductID:    p.ProductID,
+                PricePerPlay: p.PricePerPlay,
+                ProductName:  p.ProductName,
+                SKU:          p.SKU,
+            },
+            TargetCount: p.TargetCount,
+        })
+    }
+    for _, item := range operation.Items {
+        if i, ok := index[item.ProductID]; ok {
+            rows[i].OperationItem = item
             continue
         }
-        machines = append(machines, machine)
+        rows = append(rows, restockRow{OperationItem: item})
     }
-    return machines, nil
-}
-
-// Helper function to get active users
-func (h *OperationHandler) getActiveUsers() ([]models.User, error) {
-    rows, err := h.db.Query(`
-        SELECT id, username, fullusername 
-        FROM users 
-        WHERE status = 1 
-        ORDER BY username
-    `)
+    if operation.WarehouseID == 0 {
+        return rows, nil
+    }
+    
+    // Выданное этой операцией с того же склада вернется при правке
+    issued := make(map[int64]int)
+    if operation.ID != 0 {
+        saved, err := h.operations.Get(r.Context(), repository.Scope{OrgID: orgID}, operation.ID)
+        if err != nil && !errors.Is(err, repository.ErrNotFound) {
+            return nil, err
+        }
+        if saved.WarehouseID == operation.WarehouseID {
+            for _, item := range saved.Items {
+                issued[item.ProductID] = item.Added
+            }
+        }
+    }
+    stock, err := h.inventory.Items(r.Context(), repository.Scope{OrgID: orgID},
+        repository.InventoryFilter{WarehouseID: operation.WarehouseID})
     if err != nil {
         return nil, err
     }
-    defer rows.Close()
+    available := make(map[int64]int, len(stock))
+    for _, item := range stock {
+        available[item.ProductID] = item.Available()
+    }
+    for i := range rows {
+        if n, ok := available[rows[i].ProductID]; ok {
+            rows[i].Stocked = true
+            rows[i].Available = n + issued[rows[i].ProductID]
+        }
+    }
+    return rows, nil
+}
 
-    var users []models.User
-    for rows.Next() {
-        var user models.User
-        err := rows.Scan(&user.ID, &user.Username, &user.FullUserName)
-        if err != nil {
+// readRestock читает из формы пополнение по товарам и пересчитывает по
+// нему итоги операции. Незаполненное «добавлено» — догрузка до цели
+// выкладки. Цена игры берется из выкладки, а для товара, которого в ней
+// уже нет, — из сохраненной операции.
+func (h *OperationHandler) readRestock(r *http.Request, form *validate.Form, operation *models.VendingOperation) error {
+    saved := *operation
+    if operation.ID != 0 {
+        current, err := h.operations.Get(r.Context(), repository.Scope{OrgID: operation.OrgID}, operation.ID)
+        if err != nil && !errors.Is(err, repository.ErrNotFound) {
+            return err
+        }
+        saved.Items = current.Items
+    }
+    rows, err := h.restockRows(r, operation.OrgID, saved)
+    if err != nil {
+        return err
+    }
+    known := make(map[int64]restockRow, len(rows))
+    for _, row := range rows {
+        known[row.ProductID] = row
+    }
+    
+    // Строка товара в форме — его поле «было»
+    for name := range r.PostForm {
+        productID, err := strconv.ParseInt(strings.TrimPrefix(name, "before_"), 10, 64)
+        if _, ok := known[productID]; err == nil && !ok && strings.HasPrefix(name, "before_") {
+            form.Fail("items", "Товар пополнения не найден в выкладке автомата")
+        }
+    }
+    operation.Items = nil
+    for _, row := range rows {
+        before, added := fmt.Sprintf("before_%d", row.ProductID), fmt.Sprintf("added_%d", row.ProductID)
+        if _, ok := r.PostForm[before]; !ok {
             continue
         }
-        users = append(users, user)
+        item := row.OperationItem
+        item.ID = 0
+        item.CountBefore = form.Int(before)
+        form.Min(before, item.CountBefore, 0)
+        if form.Get(added) == "" {
+            item.Added = max(row.TargetCount-item.CountBefore, 0)
+        } else {
+            item.Added = form.Int(added)
+            form.Min(added, item.Added, 0)
+        }
+        if operation.WarehouseID != 0 && item.Added > 0 {
+            form.Check(row.Stocked, added, "Товара нет на складе пополнения")
+        }
+        operation.Items = append(operation.Items, item)
+    }
+    if len(operation.Items) == 0 {
+        return nil
     }
-    return users, nil
+    
+    operation.ToysBefore, operation.ToysAdded = 0, 0
+    for _, item := range operation.Items {
+        operation.ToysBefore += item.CountBefore
+        operation.ToysAdded += item.Added
+    }
+    operation.ToysAfter = operation.ToysBefore + operation.ToysAdded
+    return nil
 }
 
 func (h *OperationHandler) SaveOperation(w http.ResponseWriter, r *http.Request) {
@@ -193,67 +323,110 @@ func (h *OperationHandler) SaveOperation(w http.ResponseWriter, r *http.Request)
         return
     }
     
-    idStr := r.FormValue("id")
-    vendingMachineID, _ := strconv.ParseInt(r.FormValue("vending_machine_id"), 10, 64)
-    performedBy, _ := strconv.ParseInt(r.FormValue("performed_by"), 10, 64)
-    toysBefore, _ := strconv.Atoi(r.FormValue("toys_before"))
-    toysAfter, _ := strconv.Atoi(r.FormValue("toys_after"))
-    toysAdded, _ := strconv.Atoi(r.FormValue("toys_added"))
-    cashBefore, _ := strconv.ParseFloat(r.FormValue("cash_before"), 64)
-    cashAfter, _ := strconv.ParseFloat(r.FormValue("cash_after"), 64)
-    cashCollected, _ := strconv.ParseFloat(r.FormValue("cash_collected"), 64)
+    form := validate.New(r.PostForm)
+    form.Required("operation_type", "vending_machine_id", "performed_by")
+    operation := operationFromForm(form)
+    if form.Get("operation_date") == "" {
+        operation.OperationDate = time.Now()
+    }
     
-    // Parse operation date
-    var operationDate time.Time
-    if date := r.FormValue("operation_date"); date != "" {
-        operationDate, _ = time.Parse("2006-01-02T15:04", date) // For datetime-local
-    } else {
-        operationDate = time.Now()
+    form.Min("toys_before", operation.ToysBefore, 0)
+    form.Min("toys_after", operation.ToysAfter, 0)
+    form.Min("toys_added", operation.ToysAdded, 0)
+    form.NotNegative("cash_before", operation.CashBefore)
+    form.NotNegative("cash_after", operation.CashAfter)
+    form.NotNegative("cash_collected", operation.CashCollected)
+    form.Check(operation.CashCollected <= operation.CashBefore, "cash_collected",
+        "Не может превышать наличные до операции")
+    
+    // Со склада выдается только пополнение
+    if operation.OperationType != "restock" {
+        operation.WarehouseID = 0
     }
     
-    operation := models.VendingOperation{
-        VendingMachineID: vendingMachineID,
-        OperationType:    r.FormValue("operation_type"),
-        PerformedBy:      performedBy,
-        OperationDate:    operationDate,
-        ToysBefore:       toysBefore,
-        ToysAfter:        toysAfter,
-        ToysAdded:        toysAdded,
-        CashBefore:       cashBefore,
-        CashAfter:        cashAfter,
-        CashCollected:    cashCollected,
+    // Операция принадлежит организации автомата; исполнитель — ее участник
+    scope := scopeFor(r)
+    operation.OrgID = scope.OrgID
+    if operation.ID != 0 {
+        current, err := h.operations.Get(r.Context(), scope, operation.ID)
+        if errors.Is(err, repository.ErrNotFound) {
+            http.Error(w, "Операция не найдена", http.StatusNotFound)
+            return
+        }
+        if err != nil {
+            serverError(w, r, err)
+            return
+        }
+        operation.OrgID = current.OrgID
+    }
+    if operation.VendingMachineID != 0 {
+        machine, err := h.machines.Get(r.Context(), repository.Scope{OrgID: operation.OrgID}, operation.VendingMachineID)
+        switch {
+        case errors.Is(err, repository.ErrNotFound):
+            form.Fail("vending_machine_id", "Автомат не найден")
+        case err != nil:
+            serverError(w, r, err)
+            return
+        default:
+            if operation.OperationType == "restock" {
+                if err := h.readRestock(r, form, &operation); err != nil {
+                    serverError(w, r, err)
+                    return
+                }
+            }
+            if len(operation.Items) > 0 {
+                form.Check(operation.ToysAfter <= machine.CapacityToys, "items",
+                    fmt.Sprintf("После пополнения в автомате %d шт. — больше вместимости (%d)",
+                        operation.ToysAfter, machine.CapacityToys))
+            } else {
+                form.Check(operation.ToysAfter <= machine.CapacityToys, "toys_after",
+                    fmt.Sprintf("Не может превышать вместимость автомата (%d)", machine.CapacityToys))
+            }
+        }
+    }
+    if operation.WarehouseID != 0 {
+        _, err := h.inventory.Warehouse(r.Context(), OrgScope{OrgID: operation.OrgID}, operation.WarehouseID)
+        switch {
+        case errors.Is(err, repository.ErrNotFound):
+            form.Fail("warehouse_id", "Склад не найден")
+        case err != nil:
+            serverError(w, r, err)
+            return
+        }
+    }
+    if operation.PerformedBy != 0 {
+        isMember, err := h.users.IsMember(r.Context(), operation.PerformedBy, operation.OrgID)
+        if err != nil {
+            serverError(w, r, err)
+            return
+        }
+        form.Check(isMember, "performed_by", "Исполнитель не найден")
+    }
+    if !form.Valid() {
+        h.renderForm(w, r, operation, operation.ID != 0, form)
+        return
     }
     
     var err error
-    if idStr == "" || idStr == "0" {
-        _, err = h.db.Exec(`
-            INSERT INTO vending_operations 
-            (vending_machine_id, operation_type, performed_by, operation_date,
-             toys_before, toys_after, toys_added, cash_before, cash_after, cash_collected)
-            VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
-        `, operation.VendingMachineID, operation.OperationType, operation.PerformedBy,
-           operation.OperationDate, operation.ToysBefore, operation.ToysAfter,
-           operation.ToysAdded, operation.CashBefore, operation.CashAfter,
-           operation.CashCollected)
+    if operation.ID == 0 {
+        err = h.operations.Create(r.Context(), &operation)
     } else {
-        id, _ := strconv.ParseInt(idStr, 10, 64)
-        operation.ID = id
-        
-        _, err = h.db.Exec(`
-            UPDATE vending_operations 
-            SET vending_machine_id=$1, operation_type=$2, performed_by=$3, 
-                operation_date=$4, toys_before=$5, toys_after=$6, toys_added=$7,
-                cash_before=$8, cash_after=$9, cash_collected=$10, 
-                updated_at=CURRENT_TIMESTAMP
-            WHERE id=$11
-        `, operation.VendingMachineID, operation.OperationType, operation.PerformedBy,
-           operation.OperationDate, operation.ToysBefore, operation.ToysAfter,
-           operation.ToysAdded, operation.CashBefore, operation.CashAfter,
-           operation.CashCollected, operation.ID)
+        err = h.operations.Update(r.Context(), scope, operation)
     }
     
-    if err != nil {
-        http.Error(w, "Database error: "+err.Error(), http.StatusInternalServerError)
+    switch {
+    case errors.Is(err, repository.ErrConflict):
+        h.renderConflict(w, r, operation)
+        return
+    case errors.Is(err, repository.ErrInsufficientStock):
+        // Товар успели выдать или зарезервировать, пока форма была открыта
+        form.Fail("items", "На складе не хватает товара для этого пополнения")
+    case err != nil:
+        serverError(w, r, err)
+        return
+    }
+    if !form.Valid() {
+        h.renderForm(w, r, operation, operation.ID != 0, form)
         return
     }
     
@@ -261,6 +434,105 @@ func (h *OperationHandler) SaveOperation(w http.ResponseWriter, r *http.Request)
     h.ListOperations(w, r)
 }
 
+// renderConflict показывает, чем операция, сохраненная другим пользователем,
+// отличается от отправленной.
+func (h *OperationHandler) renderConflict(w http.ResponseWriter, r *http.Request, operation models.VendingOperation) {
+    current, err := h.operations.Get(r.Context(), scopeFor(r), operation.ID)
+    if errors.Is(err, repository.ErrNotFound) {
+        http.Error(w, "Операция не найдена", http.StatusNotFound)
+        return
+    }
+    if err != nil {
+        serverError(w, r, err)
+        return
+    }
+    machines, err := h.machines.ListActive(r.Context(), current.OrgID)
+    if err != nil {
+        serverError(w, r, err)
+        return
+    }
+    users, err := h.users.ListActive(r.Context(), current.OrgID)
+    if err != nil {
+        serverError(w, r, err)
+        return
+    }
+    warehouses, err := h.inventory.Warehouses(r.Context(), OrgScope{OrgID: current.OrgID})
+    if err != nil {
+        serverError(w, r, err)
+        return
+    }
+    
+    fields := []formField{
+        {Name: "operation_type", Label: "Тип операции", Options: operationTypes},
+        {Name: "vending_machine_id", Label: "Автомат", Options: idOptions(machines,
+            func(m models.VendingMachine) int64 { return m.ID },
+            func(m models.VendingMachine) string { return m.SerialNumber + " - " + m.LocationName })},
+        {Name: "performed_by", Label: "Исполнитель", Options: idOptions(users,
+            func(u models.User) int64 { return u.ID },
+            func(u models.User) string { return u.Username + " - " + u.FullUserName })},
+        {Name: "operation_date", Label: "Дата операции"},
+        {Name: "warehouse_id", Label: "Склад пополнения", Options: idOptions(warehouses,
+            func(w models.Warehouse) int64 { return w.ID },
+            func(w models.Warehouse) string { return w.Name })},
+        {Name: "toys_before", Label: "Игрушки до"},
+        {Name: "toys_after", Label: "Игрушки после"},
+        {Name: "toys_added", Label: "Добавлено игрушек"},
+        {Name: "cash_before", Label: "Наличные до (₽)"},
+        {Name: "cash_after", Label: "Наличные после (₽)"},
+        {Name: "cash_collected", Label: "Собрано наличных (₽)"},
+    }
+    fields = append(fields, restockFields(current.Items, operation.Items)...)
+    h.renderer.RenderConflict(w, modalBody, newConflict("/operations/save", "#operations-table",
+        fields, operationValues(operation), operationValues(current)))
+}
+
+var operationTypes = map[string]string{
+    "restock":     "Пополнение",
+    "collection":  "Инкассация",
+    "maintenance": "Обслуживание",
+}
+
+// restockFields — поля пополнения по товарам обеих версий операции
+// для окна сравнения.
+func restockFields(lists ...[]models.OperationItem) []formField {
+    var fields []formField
+    seen := make(map[int64]bool)
+    for _, item := range slices.Concat(lists...) {
+        if seen[item.ProductID] {
+            continue
+        }
+        seen[item.ProductID] = true
+        fields = append(fields,
+            formField{Name: fmt.Sprintf("before_%d", item.ProductID), Label: item.ProductName + ": было"},
+            formField{Name: fmt.Sprintf("added_%d", item.ProductID), Label: item.ProductName + ": добавлено"})
+    }
+    return fields
+}
+
+// operationValues переводит операцию в значения ее формы.
+func operationValues(op models.VendingOperation) url.Values {
+    values := url.Values{
+        "id":                 {formID(op.ID)},
+        "version":            {strconv.Itoa(op.Version)},
+        "operation_type":     {op.OperationType},
+        "vending_machine_id": {formID(op.VendingMachineID)},
+        "performed_by":       {formID(op.PerformedBy)},
+        "operation_date":     {op.OperationDate.Format(validate.DateTimeLayout)},
+        "warehouse_id":       {formID(op.WarehouseID)},
+        "toys_before":        {strconv.Itoa(op.ToysBefore)},
+        "toys_after":         {strconv.Itoa(op.ToysAfter)},
+        "toys_added":         {strconv.Itoa(op.ToysAdded)},
+        "cash_before":        {op.CashBefore.String()},
+        "cash_after":         {op.CashAfter.String()},
+        "cash_collected":     {op.CashCollected.String()},
+    }
+    for _, item := range op.Items {
+        values.Set(fmt.Sprintf("before_%d", item.ProductID), strconv.Itoa(item.CountBefore))
+        values.Set(fmt.Sprintf("added_%d", item.ProductID), strconv.Itoa(item.Added))
+    }
+    return values
+}
+
 func (h *OperationHandler) DeleteOperation(w http.ResponseWriter, r *http.Request) {
     idStr := r.URL.Query().Get("id")
     id, err := strconv.ParseInt(idStr, 10, 64)
@@ -269,9 +541,8 @@ func (h *OperationHandler) DeleteOperation(w http.ResponseWriter, r *http.Reques
         return
     }
     
-    _, err = h.db.Exec("DELETE FROM vending_operations WHERE id = $1", id)
-    if err != nil {
-        http.Error(w, err.Error(), http.StatusInternalServerError)
+    if err := h.operations.Delete(r.Context(), scopeFor(r), id); err != nil {
+        serverError(w, r, err)
         return
     }
     
@@ -0,0 +1,229 @@
+package handlers
+
+import (
+    "errors"
+    "net/http"
+    "regexp"
+    "strconv"
+    "strings"
+    "vend_erp/internal/models"
+    "vend_erp/internal/repository"
+    "vend_erp/internal/validate"
+)
+
+var slugPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{1,98}$`)
+
+type OrganizationHandler struct {
+    orgs     repository.Organizations
+    sessions repository.Sessions
+    renderer *TemplateRenderer
+}
+
+func NewOrganizationHandler(orgs repository.Organizations, sessions repository.Sessions, renderer *TemplateRenderer) *OrganizationHandler {
+    return &OrganizationHandler{orgs: orgs, sessions: sessions, renderer: renderer}
+}
+
+func (h *OrganizationHandler) requireSuperAdmin(w http.ResponseWriter, r *http.Request) bool {
+    user := CurrentUser(r)
+    if user == nil || !user.IsSuperAdmin {
+        http.Error(w, "Доступ запрещен", http.StatusForbidden)
+        return false
+    }
+    return true
+}
+
+func (h *OrganizationHandler) ListOrganizations(w http.ResponseWriter, r *http.Request) {
+    if !h.requireSuperAdmin(w, r) {
+        return
+    }
+
+    orgs, err := h.orgs.List(r.Context())
+    if err != nil {
+        serverError(w, r, err)
+        return
+    }
+
+    data := map[string]interface{}{
+        "Organizations": orgs,
+        "Active":        "organizations",
+        "Title":         "Организации",
+    }
+
+    if r.Header.Get("HX-Request") == "true" {
+        h.renderer.Render(w, "organizations_list.html", data)
+        return
+    }
+
+    h.renderer.Render(w, "organizations_page.html", data)
+}
+
+func (h *OrganizationHandler) GetOrganizationForm(w http.ResponseWriter, r *http.Request) {
+    if !h.requireSuperAdmin(w, r) {
+        return
+    }
+
+    idStr := r.URL.Query().Get("id")
+    org := models.Organization{IsActive: true, CostingMethod: repository.CostFIFO}
+
+    if idStr != "" {
+        id, _ := strconv.ParseInt(idStr, 10, 64)
+        found, err := h.orgs.Get(r.Context(), id)
+        if err == nil {
+            org = found
+        } else if !errors.Is(err, repository.ErrNotFound) {
+            serverError(w, r, err)
+            return
+        }
+    }
+
+    h.renderForm(w, org, idStr != "", nil)
+}
+
+// renderForm показывает форму организации; непустая form — ответ на
+// неудачное сохранение с ошибками полей.
+func (h *OrganizationHandler) renderForm(w http.ResponseWriter, org models.Organization, edit bool, form *validate.Form) {
+    data := map[string]interface{}{
+        "Organization": org,
+        "Edit":         edit,
+    }
+    if form != nil {
+        h.renderer.RenderInvalid(w, modalBody, "organization_form.html", data, form)
+        return
+    }
+    h.renderer.Render(w, "organization_form.html", data)
+}
+
+func (h *OrganizationHandler) SaveOrganization(w http.ResponseWriter, r *http.Request) {
+    if !h.requireSuperAdmin(w, r) {
+        return
+    }
+    if err := r.ParseForm(); err != nil {
+        http.Error(w, err.Error(), http.StatusBadRequest)
+        return
+    }
+
+    form := validate.New(r.PostForm)
+    form.Required("name", "slug")
+    form.MaxLength("name", 255)
+
+    org := models.Organization{
+        ID:       form.ID("id"),
+        Name:     form.Get("name"),
+        Slug:     strings.ToLower(form.Get("slug")),
+        IsActive: form.Get("is_active") == "true",
+        // Метод оценки применяется к движениям после смены; прошлые
+        // стоимости не пересчитываются
+        CostingMethod: form.OneOf("costing_method", repository.CostFIFO, repository.CostAverage),
+    }
+    if org.Slug != "" {
+        form.Check(slugPattern.MatchString(org.Slug), "slug", "Латиница, цифры и дефис, от 2 до 99 символов")
+    }
+    if !form.Valid() {
+        h.renderForm(w, org, org.ID != 0, form)
+        return
+    }
+
+    var err error
+    if org.ID == 0 {
+        err = h.orgs.Create(r.Context(), &org)
+    } else {
+        // Код и активность организации по умолчанию хранилище не меняет
+        err = h.orgs.Update(r.Context(), org)
+    }
+
+    switch {
+    case errors.Is(err, repository.ErrDuplicate):
+        form.Fail("slug", "Этот код уже занят")
+        h.renderForm(w, org, org.ID != 0, form)
+        return
+    case errors.Is(err, repository.ErrNotFound):
+        http.Error(w, "Организация не найдена", http.StatusNotFound)
+        return
+    case err != nil:
+        serverError(w, r, err)
+        return
+    }
+
+    h.ListOrganizations(w, r)
+}
+
+// Switcher отдает переключатель организаций для сайдбара.
+func (h *OrganizationHandler) Switcher(w http.ResponseWriter, r *http.Request) {
+    user := CurrentUser(r)
+    if user == nil {
+        http.Error(w, "Unauthorized", http.StatusUnauthorized)
+        return
+    }
+
+    var orgs []models.Organization
+    var err error
+    if user.IsSuperAdmin {
+        orgs, err = h.orgs.List(r.Context())
+    } else {
+        orgs, err = h.orgs.Member(r.Context(), user.ID)
+    }
+    if err != nil {
+        serverError(w, r, err)
+        return
+    }
+
+    data := map[string]interface{}{
+        "User":          user,
+        "Organizations": orgs,
+    }
+    h.renderer.Render(w, "org_switcher.html", data)
+}
+
+// Switch меняет активную организацию текущей сессии.
+// Значение "all" включает сводный режим и доступно только суперадмину.
+func (h *OrganizationHandler) Switch(w http.ResponseWriter, r *http.Request) {
+    if r.Method != http.MethodPost {
+        http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+        return
+    }
+    user := CurrentUser(r)
+    cookie, err := r.Cookie("session_id")
+    if user == nil || err != nil {
+        http.Error(w, "Unauthorized", http.StatusUnauthorized)
+        return
+    }
+
+    value := r.FormValue("org_id")
+    if value == "all" {
+        if !user.IsSuperAdmin {
+            http.Error(w, "Доступ запрещен", http.StatusForbidden)
+            return
+        }
+        err = h.sessions.SetAllOrgs(r.Context(), cookie.Value, user.ID)
+    } else {
+        orgID, parseErr := strconv.ParseInt(value, 10, 64)
+        if parseErr != nil {
+            http.Error(w, "Неверная организация", http.StatusBadRequest)
+            return
+        }
+
+        allowed, checkErr := h.orgs.CanSwitch(r.Context(), user.ID, orgID, user.IsSuperAdmin)
+        if checkErr != nil {
+            serverError(w, r, checkErr)
+            return
+        }
+        if !allowed {
+            http.Error(w, "Доступ запрещен", http.StatusForbidden)
+            return
+        }
+
+        err = h.sessions.SetActiveOrg(r.Context(), cookie.Value, user.ID, orgID)
+    }
+    if errors.Is(err, repository.ErrNotFound) {
+        http.Error(w, "Unauthorized", http.StatusUnauthorized)
+        return
+    }
+    if err != nil {
+        serverError(w, r, err)
+        return
+    }
+
+    // Все данные на странице зависят от организации — перезагружаем ее целиком
+    w.Header().Set("HX-Refresh", "true")
+    w.WriteHeader(http.StatusNoContent)
+}
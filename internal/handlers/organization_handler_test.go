@@ -0,0 +1,190 @@
+package handlers_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"vend_erp/internal/assets"
+	"vend_erp/internal/handlers"
+	"vend_erp/internal/models"
+	"vend_erp/internal/repository"
+	"vend_erp/internal/repository/memory"
+	"vend_erp/static"
+	"vend_erp/templates"
+)
+
+// orgEnv — обработчик организаций поверх memory.Store с пользователем,
+// который состоит только в своей организации.
+type orgEnv struct {
+	repos   repository.Repos
+	handler *handlers.OrganizationHandler
+	home    int64
+	foreign int64
+	user    *handlers.User
+	session string
+}
+
+func newOrgEnv(t *testing.T) orgEnv {
+	t.Helper()
+	ctx := context.Background()
+	store := memory.New()
+	env := orgEnv{
+		repos:   store.Repos(),
+		home:    store.AddOrganization("Север"),
+		foreign: store.AddOrganization("Юг"),
+		session: "session-1",
+	}
+	renderer := handlers.NewTemplateRenderer(templates.FS, assets.New(static.FS, false), false)
+	env.handler = handlers.NewOrganizationHandler(env.repos.Organizations, env.repos.Sessions, renderer)
+
+	account := models.User{Username: "ivan", Email: "ivan@example.com", UserRole: "admin", Status: models.UserStatusActive, OrgID: env.home}
+	if err := env.repos.Users.Create(ctx, &account, "hash"); err != nil {
+		t.Fatal(err)
+	}
+	err := env.repos.Sessions.Create(ctx, models.Session{ID: env.session, UserID: account.ID, ExpiresAt: time.Now().Add(time.Hour)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	env.user = &handlers.User{ID: account.ID, Username: account.Username, OrgID: env.home, ActiveOrgID: env.home}
+	return env
+}
+
+// post выполняет POST с формой от имени пользователя env.user.
+func (env orgEnv) post(handler http.HandlerFunc, path string, form url.Values) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodPost, path, strings.NewReader(form.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	r.AddCookie(&http.Cookie{Name: "session_id", Value: env.session})
+	r = r.WithContext(handlers.WithUser(r.Context(), env.user))
+	w := httptest.NewRecorder()
+	handler(w, r)
+	return w
+}
+
+func TestOrganizationSwitch(t *testing.T) {
+	tests := []struct {
+		name       string
+		superAdmin bool
+		org        func(env orgEnv) string
+		wantStatus int
+		wantActive func(env orgEnv) int64
+		wantAll    bool
+	}{
+		{
+			name:       "member",
+			org:        func(env orgEnv) string { return itoa(env.home) },
+			wantStatus: http.StatusNoContent,
+			wantActive: func(env orgEnv) int64 { return env.home },
+		},
+		{
+			name:       "not a member",
+			org:        func(env orgEnv) string { return itoa(env.foreign) },
+			wantStatus: http.StatusForbidden,
+			wantActive: func(orgEnv) int64 { return 0 },
+		},
+		{
+			name:       "superadmin",
+			superAdmin: true,
+			org:        func(env orgEnv) string { return itoa(env.foreign) },
+			wantStatus: http.StatusNoContent,
+			wantActive: func(env orgEnv) int64 { return env.foreign },
+		},
+		{
+			name:       "all orgs without superadmin",
+			org:        func(orgEnv) string { return "all" },
+			wantStatus: http.StatusForbidden,
+			wantActive: func(orgEnv) int64 { return 0 },
+		},
+		{
+			name:       "all orgs",
+			superAdmin: true,
+			org:        func(orgEnv) string { return "all" },
+			wantStatus: http.StatusNoContent,
+			wantActive: func(orgEnv) int64 { return 0 },
+			wantAll:    true,
+		},
+		{
+			name:       "missing organization",
+			superAdmin: true,
+			org:        func(orgEnv) string { return "-1" },
+			wantStatus: http.StatusForbidden,
+			wantActive: func(orgEnv) int64 { return 0 },
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newOrgEnv(t)
+			env.user.IsSuperAdmin = tt.superAdmin
+
+			w := env.post(env.handler.Switch, "/organizations/switch", url.Values{"org_id": {tt.org(env)}})
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body)
+			}
+			if tt.wantStatus == http.StatusNoContent && w.Header().Get("HX-Refresh") != "true" {
+				t.Error("HX-Refresh not set")
+			}
+
+			session, err := env.repos.Sessions.Get(context.Background(), env.session)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := tt.wantActive(env); session.ActiveOrgID != want {
+				t.Errorf("ActiveOrgID = %d, want %d", session.ActiveOrgID, want)
+			}
+			if session.AllOrgs != tt.wantAll {
+				t.Errorf("AllOrgs = %v, want %v", session.AllOrgs, tt.wantAll)
+			}
+		})
+	}
+}
+
+func TestSaveOrganization(t *testing.T) {
+	env := newOrgEnv(t)
+	save := func(form url.Values) *httptest.ResponseRecorder {
+		return env.post(env.handler.SaveOrganization, "/organizations/save", form)
+	}
+
+	env.user.IsSuperAdmin = false
+	if w := save(url.Values{"name": {"Запад"}, "slug": {"west"}}); w.Code != http.StatusForbidden {
+		t.Errorf("without superadmin: status = %d, want %d", w.Code, http.StatusForbidden)
+	}
+
+	env.user.IsSuperAdmin = true
+	w := save(url.Values{"name": {"Запад"}, "slug": {"West"}, "is_active": {"true"}, "costing_method": {repository.CostAverage}})
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "Запад") {
+		t.Fatalf("create: status = %d, want list with the new organization: %s", w.Code, w.Body)
+	}
+	created, err := env.repos.Organizations.FindBySlug(context.Background(), "west")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !created.IsActive || created.CostingMethod != repository.CostAverage {
+		t.Errorf("created = %+v", created)
+	}
+
+	w = save(url.Values{"name": {"Запад-2"}, "slug": {"west"}, "costing_method": {repository.CostFIFO}})
+	if w.Code != http.StatusUnprocessableEntity || !strings.Contains(w.Body.String(), "Этот код уже занят") {
+		t.Errorf("duplicate slug: status = %d, want %d with error: %s", w.Code, http.StatusUnprocessableEntity, w.Body)
+	}
+
+	w = save(url.Values{"id": {itoa(created.ID)}, "name": {"Запад"}, "slug": {"west"}, "costing_method": {repository.CostFIFO}})
+	if w.Code != http.StatusOK {
+		t.Fatalf("update: status = %d: %s", w.Code, w.Body)
+	}
+	updated, err := env.repos.Organizations.Get(context.Background(), created.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if updated.IsActive || updated.CostingMethod != repository.CostFIFO {
+		t.Errorf("updated = %+v, want inactive with FIFO", updated)
+	}
+}
+
+func itoa(id int64) string {
+	return strconv.FormatInt(id, 10)
+}
@@ -0,0 +1,249 @@
+package handlers
+
+import (
+    "errors"
+    "net/http"
+    "net/url"
+    "strconv"
+    "vend_erp/internal/models"
+    "vend_erp/internal/repository"
+    "vend_erp/internal/validate"
+)
+
+// ProductHandler ведет справочник товаров. Остатки товаров на складах
+// редактирует WarehouseHandler.
+type ProductHandler struct {
+    products  repository.Products
+    inventory repository.Inventory
+    renderer  *TemplateRenderer
+}
+
+func NewProductHandler(products repository.Products, inventory repository.Inventory, renderer *TemplateRenderer) *ProductHandler {
+    return &ProductHandler{products: products, inventory: inventory, renderer: renderer}
+}
+
+func (h *ProductHandler) ListProducts(w http.ResponseWriter, r *http.Request) {
+    scope := scopeFor(r)
+    products, err := h.products.List(r.Context(), scope)
+    if err != nil {
+        serverError(w, r, err)
+        return
+    }
+
+    data := map[string]interface{}{
+        "Products": products,
+        "AllOrgs":  scope.AllOrgs,
+        "Active":   "products",
+        "Title":    "Товары",
+    }
+
+    if r.Header.Get("HX-Request") == "true" {
+        h.renderer.Render(w, "products_list.html", data)
+        return
+    }
+    h.renderer.Render(w, "products_page.html", data)
+}
+
+func (h *ProductHandler) GetProductForm(w http.ResponseWriter, r *http.Request) {
+    idStr := r.URL.Query().Get("id")
+    product := models.Product{IsActive: true, UnitVolume: 1, PackSize: 1}
+
+    if idStr != "" {
+        id, _ := strconv.ParseInt(idStr, 10, 64)
+        var err error
+        product, err = h.products.Get(r.Context(), scopeFor(r), id)
+        if err != nil && !errors.Is(err, repository.ErrNotFound) {
+            serverError(w, r, err)
+            return
+        }
+    }
+
+    h.renderForm(w, r, product, idStr != "", nil)
+}
+
+// renderForm показывает форму товара; непустая form — ответ на неудачное
+// сохранение с ошибками полей.
+func (h *ProductHandler) renderForm(w http.ResponseWriter, r *http.Request, product models.Product, edit bool, form *validate.Form) {
+    categories, _ := h.inventory.Categories(r.Context())
+
+    data := map[string]interface{}{
+        "Product":    product,
+        "Categories": categories,
+        "Edit":       edit,
+    }
+    if form != nil {
+        h.renderer.RenderInvalid(w, modalBody, "product_form.html", data, form)
+        return
+    }
+    h.renderer.Render(w, "product_form.html", data)
+}
+
+func (h *ProductHandler) SaveProduct(w http.ResponseWriter, r *http.Request) {
+    if err := r.ParseForm(); err != nil {
+        http.Error(w, err.Error(), http.StatusBadRequest)
+        return
+    }
+
+    form := validate.New(r.PostForm)
+    form.Required("sku", "name", "item_type", "category_id", "unit_volume", "pack_size")
+    form.MaxLength("sku", 100)
+    form.MaxLength("name", 255)
+    form.MaxLength("barcode", 64)
+    form.MaxLength("supplier_name", 255)
+    form.URL("photo_url")
+
+    product := models.Product{
+        ID:               form.ID("id"),
+        Version:          form.Int("version"),
+        SKU:              form.Get("sku"),
+        Name:             form.Get("name"),
+        ItemType:         form.OneOf("item_type", "vending_machine", "toy", "capsule"),
+        CategoryID:       form.ID("category_id"),
+        Description:      form.Get("description"),
+        Barcode:          form.Get("barcode"),
+        PhotoURL:         form.Get("photo_url"),
+        DefaultCost:      form.Money("default_cost"),
+        DefaultPrice:     form.Money("default_price"),
+        SupplierName:     form.Get("supplier_name"),
+        UnitVolume:       form.Int("unit_volume"),
+        MinOrderQuantity: form.Int("min_order_quantity"),
+        PackSize:         form.Int("pack_size"),
+        IsActive:         form.Get("is_active") == "true",
+    }
+
+    form.NotNegative("default_cost", product.DefaultCost)
+    form.NotNegative("default_price", product.DefaultPrice)
+    form.Min("unit_volume", product.UnitVolume, 1)
+    form.Min("min_order_quantity", product.MinOrderQuantity, 0)
+    form.Min("pack_size", product.PackSize, 1)
+
+    categories, err := h.inventory.Categories(r.Context())
+    if err != nil {
+        serverError(w, r, err)
+        return
+    }
+    if product.CategoryID != 0 {
+        known := false
+        for _, category := range categories {
+            known = known || category.ID == product.CategoryID
+        }
+        form.Check(known, "category_id", "Категория не найдена")
+    }
+    if !form.Valid() {
+        h.renderForm(w, r, product, product.ID != 0, form)
+        return
+    }
+
+    scope := scopeFor(r)
+    if product.ID == 0 {
+        product.OrgID = scope.OrgID
+        err = h.products.Create(r.Context(), &product)
+    } else {
+        err = h.products.Update(r.Context(), scope, product)
+    }
+
+    if errors.Is(err, repository.ErrConflict) {
+        h.renderConflict(w, r, product, categories)
+        return
+    }
+    if errors.Is(err, repository.ErrNotFound) {
+        http.Error(w, "Товар не найден", http.StatusNotFound)
+        return
+    }
+    if errors.Is(err, repository.ErrDuplicate) {
+        // Ограничение не сообщает, какое из полей занято
+        form.Fail("sku", "Артикул или штрихкод уже используется")
+        if product.Barcode != "" {
+            form.Fail("barcode", "Артикул или штрихкод уже используется")
+        }
+        h.renderForm(w, r, product, product.ID != 0, form)
+        return
+    }
+    if err != nil {
+        serverError(w, r, err)
+        return
+    }
+
+    w.Header().Set("HX-Trigger", "productSaved")
+    h.ListProducts(w, r)
+}
+
+// renderConflict показывает, чем товар, сохраненный другим пользователем,
+// отличается от отправленного.
+func (h *ProductHandler) renderConflict(w http.ResponseWriter, r *http.Request, product models.Product, categories []models.WarehouseCategory) {
+    current, err := h.products.Get(r.Context(), scopeFor(r), product.ID)
+    if errors.Is(err, repository.ErrNotFound) {
+        http.Error(w, "Товар не найден", http.StatusNotFound)
+        return
+    }
+    if err != nil {
+        serverError(w, r, err)
+        return
+    }
+
+    fields := []formField{
+        {Name: "sku", Label: "Артикул (SKU)"},
+        {Name: "name", Label: "Наименование"},
+        {Name: "item_type", Label: "Тип товара", Options: itemTypes},
+        {Name: "category_id", Label: "Категория", Options: idOptions(categories,
+            func(c models.WarehouseCategory) int64 { return c.ID },
+            func(c models.WarehouseCategory) string { return c.Name })},
+        {Name: "description", Label: "Описание"},
+        {Name: "barcode", Label: "Штрихкод"},
+        {Name: "photo_url", Label: "Ссылка на фото"},
+        {Name: "default_cost", Label: "Закупочная цена (₽)"},
+        {Name: "default_price", Label: "Цена продажи (₽)"},
+        {Name: "supplier_name", Label: "Поставщик"},
+        {Name: "unit_volume", Label: "Объем единицы"},
+        {Name: "min_order_quantity", Label: "Минимальная партия"},
+        {Name: "pack_size", Label: "Штук в упаковке"},
+        {Name: "is_active", Label: "Активный товар", Options: yesNo},
+    }
+    h.renderer.RenderConflict(w, modalBody, newConflict("/products/save", "#products-table",
+        fields, productValues(product), productValues(current)))
+}
+
+// productValues переводит товар в значения его формы.
+func productValues(p models.Product) url.Values {
+    return url.Values{
+        "id":                 {formID(p.ID)},
+        "version":            {strconv.Itoa(p.Version)},
+        "sku":                {p.SKU},
+        "name":               {p.Name},
+        "item_type":          {p.ItemType},
+        "category_id":        {formID(p.CategoryID)},
+        "description":        {p.Description},
+        "barcode":            {p.Barcode},
+        "photo_url":          {p.PhotoURL},
+        "default_cost":       {p.DefaultCost.String()},
+        "default_price":      {p.DefaultPrice.String()},
+        "supplier_name":      {p.SupplierName},
+        "unit_volume":        {strconv.Itoa(p.UnitVolume)},
+        "min_order_quantity": {strconv.Itoa(p.MinOrderQuantity)},
+        "pack_size":          {strconv.Itoa(p.PackSize)},
+        "is_active":          {flag(p.IsActive)},
+    }
+}
+
+func (h *ProductHandler) DeleteProduct(w http.ResponseWriter, r *http.Request) {
+    idStr := r.URL.Query().Get("id")
+    id, err := strconv.ParseInt(idStr, 10, 64)
+    if err != nil {
+        http.Error(w, "Invalid ID", http.StatusBadRequest)
+        return
+    }
+
+    err = h.products.Delete(r.Context(), scopeFor(r), id)
+    if errors.Is(err, repository.ErrInUse) {
+        userError(w, http.StatusBadRequest,
+            "Товар есть на складах, в выкладке автоматов или в записанных пополнениях. Сделайте товар неактивным или сначала удалите его позиции на складах и уберите из выкладки.")
+        return
+    }
+    if err != nil {
+        serverError(w, r, err)
+        return
+    }
+
+    w.Header().Set("HX-Trigger", "productDeleted")
+    h.ListProducts(w, r)
+}
@@ -0,0 +1,345 @@
+package handlers
+
+import (
+    "errors"
+    "fmt"
+    "net/http"
+    "strconv"
+    "vend_erp/internal/models"
+    "vend_erp/internal/money"
+    "vend_erp/internal/repository"
+    "vend_erp/internal/validate"
+)
+
+// StocktakeHandler ведет инвентаризации складов. Подсчет слепой: лист
+// подсчета не показывает ожидаемые остатки, их видят только
+// администраторы в сверке расхождений перед проведением.
+type StocktakeHandler struct {
+    stocktakes repository.Stocktakes
+    inventory  repository.Inventory
+    renderer   *TemplateRenderer
+}
+
+func NewStocktakeHandler(stocktakes repository.Stocktakes, inventory repository.Inventory, renderer *TemplateRenderer) *StocktakeHandler {
+    return &StocktakeHandler{stocktakes: stocktakes, inventory: inventory, renderer: renderer}
+}
+
+func (h *StocktakeHandler) ListStocktakes(w http.ResponseWriter, r *http.Request) {
+    scope := scopeFor(r)
+    stocktakes, err := h.stocktakes.List(r.Context(), scope)
+    if err != nil {
+        serverError(w, r, err)
+        return
+    }
+
+    data := map[string]interface{}{
+        "Stocktakes": stocktakes,
+        "AllOrgs":    scope.AllOrgs,
+        "Active":     "stocktakes",
+        "Title":      "Инвентаризация",
+    }
+
+    if r.Header.Get("HX-Request") == "true" {
+        h.renderer.Render(w, "stocktakes_list.html", data)
+        return
+    }
+    h.renderer.Render(w, "stocktakes_page.html", data)
+}
+
+func (h *StocktakeHandler) GetStocktakeForm(w http.ResponseWriter, r *http.Request) {
+    h.renderForm(w, r, models.Stocktake{}, nil)
+}
+
+// renderForm показывает форму начала инвентаризации; непустая form —
+// ответ на неудачную попытку с ошибками полей.
+func (h *StocktakeHandler) renderForm(w http.ResponseWriter, r *http.Request, stocktake models.Stocktake, form *validate.Form) {
+    warehouses, err := h.inventory.Warehouses(r.Context(), scopeFor(r))
+    if err != nil {
+        serverError(w, r, err)
+        return
+    }
+
+    data := map[string]interface{}{
+        "Stocktake":  stocktake,
+        "Warehouses": warehouses,
+    }
+    if form != nil {
+        h.renderer.RenderInvalid(w, modalBody, "stocktake_form.html", data, form)
+        return
+    }
+    h.renderer.Render(w, "stocktake_form.html", data)
+}
+
+// StartStocktake открывает инвентаризацию склада и переводит на лист подсчета.
+func (h *StocktakeHandler) StartStocktake(w http.ResponseWriter, r *http.Request) {
+    if r.Method != http.MethodPost {
+        http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+        return
+    }
+    if err := r.ParseForm(); err != nil {
+        http.Error(w, err.Error(), http.StatusBadRequest)
+        return
+    }
+
+    form := validate.New(r.PostForm)
+    form.Required("warehouse_id")
+    form.MaxLength("notes", 1000)
+    stocktake := models.Stocktake{
+        WarehouseID: form.ID("warehouse_id"),
+        Notes:       form.Get("notes"),
+        CreatedBy:   CurrentUser(r).ID,
+    }
+    if !form.Valid() {
+        h.renderForm(w, r, stocktake, form)
+        return
+    }
+
+    err := h.stocktakes.Start(r.Context(), scopeFor(r), &stocktake)
+    switch {
+    case errors.Is(err, repository.ErrNotFound):
+        form.Fail("warehouse_id", "Склад не найден")
+    case errors.Is(err, repository.ErrDuplicate):
+        form.Fail("warehouse_id", "На складе уже идет инвентаризация")
+    case err != nil:
+        serverError(w, r, err)
+        return
+    }
+    if !form.Valid() {
+        h.renderForm(w, r, stocktake, form)
+        return
+    }
+
+    w.Header().Set("HX-Redirect", fmt.Sprintf("/stocktakes/count?id=%d", stocktake.ID))
+}
+
+// CountSheet показывает лист подсчета (GET) или записывает посчитанное
+// количество позиции (POST).
+func (h *StocktakeHandler) CountSheet(w http.ResponseWriter, r *http.Request) {
+    if r.Method == http.MethodPost {
+        h.count(w, r)
+        return
+    }
+
+    id, _ := strconv.ParseInt(r.URL.Query().Get("id"), 10, 64)
+    stocktake, ok := h.get(w, r, id)
+    if !ok {
+        return
+    }
+
+    data := h.sheetData(r, stocktake)
+    if r.Header.Get("HX-Request") == "true" {
+        h.renderer.Render(w, "stocktake_sheet.html", data)
+        return
+    }
+    data["Active"] = "stocktakes"
+    data["Title"] = fmt.Sprintf("Инвентаризация №%d", stocktake.ID)
+    h.renderer.Render(w, "stocktake_page.html", data)
+}
+
+func (h *StocktakeHandler) count(w http.ResponseWriter, r *http.Request) {
+    if err := r.ParseForm(); err != nil {
+        http.Error(w, err.Error(), http.StatusBadRequest)
+        return
+    }
+
+    form := validate.New(r.PostForm)
+    id := form.ID("id")
+    itemID := form.ID("item_id")
+    form.Required("quantity")
+    quantity := form.Int("quantity")
+    form.Min("quantity", quantity, 0)
+    if !form.Valid() {
+        userError(w, http.StatusBadRequest, "Введите количество: целое число не меньше нуля")
+        return
+    }
+
+    err := h.stocktakes.Count(r.Context(), scopeFor(r), id, itemID, quantity, CurrentUser(r).ID)
+    if errors.Is(err, repository.ErrNotFound) {
+        userError(w, http.StatusBadRequest, "Инвентаризация не найдена или уже закрыта")
+        return
+    }
+    if err != nil {
+        serverError(w, r, err)
+        return
+    }
+    h.renderSheet(w, r, id, nil, nil)
+}
+
+// Scan прибавляет отсканированный товар к посчитанному количеству.
+func (h *StocktakeHandler) Scan(w http.ResponseWriter, r *http.Request) {
+    if r.Method != http.MethodPost {
+        http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+        return
+    }
+    if err := r.ParseForm(); err != nil {
+        http.Error(w, err.Error(), http.StatusBadRequest)
+        return
+    }
+
+    form := validate.New(r.PostForm)
+    id := form.ID("id")
+    form.Required("code")
+    quantity := 1
+    if form.Get("quantity") != "" {
+        quantity = form.Int("quantity")
+        form.Min("quantity", quantity, 1)
+    }
+    if !form.Valid() {
+        h.renderSheet(w, r, id, nil, form)
+        return
+    }
+
+    line, err := h.stocktakes.Scan(r.Context(), scopeFor(r), id, form.Get("code"), quantity, CurrentUser(r).ID)
+    if errors.Is(err, repository.ErrNotFound) {
+        // Различаем закрытую инвентаризацию и неизвестный код
+        stocktake, ok := h.get(w, r, id)
+        if !ok {
+            return
+        }
+        if stocktake.Status != repository.StocktakeCounting {
+            userError(w, http.StatusBadRequest, "Инвентаризация не найдена или уже закрыта")
+            return
+        }
+        form.Fail("code", "Товара с таким штрихкодом или артикулом нет на складе")
+        h.renderSheet(w, r, id, nil, form)
+        return
+    }
+    if err != nil {
+        serverError(w, r, err)
+        return
+    }
+    h.renderSheet(w, r, id, &line, nil)
+}
+
+// renderSheet перерисовывает лист подсчета после ввода; scanned —
+// позиция, найденная сканером, form — ошибки формы сканирования.
+func (h *StocktakeHandler) renderSheet(w http.ResponseWriter, r *http.Request, id int64, scanned *models.StocktakeLine, form *validate.Form) {
+    stocktake, ok := h.get(w, r, id)
+    if !ok {
+        return
+    }
+    data := h.sheetData(r, stocktake)
+    if scanned != nil {
+        data["Scanned"] = *scanned
+    }
+    if form != nil {
+        h.renderer.RenderInvalid(w, "#stocktake-sheet", "stocktake_sheet.html", data, form)
+        return
+    }
+    h.renderer.Render(w, "stocktake_sheet.html", data)
+}
+
+func (h *StocktakeHandler) sheetData(r *http.Request, stocktake models.Stocktake) map[string]interface{} {
+    current := CurrentUser(r)
+    return map[string]interface{}{
+        "Stocktake": stocktake,
+        "Open":      stocktake.Status == repository.StocktakeCounting,
+        "CanManage": current != nil && (current.UserRole == "admin" || current.IsSuperAdmin),
+    }
+}
+
+// Variance показывает администратору расхождения факта со снимком
+// и их стоимость.
+func (h *StocktakeHandler) Variance(w http.ResponseWriter, r *http.Request) {
+    if !requireAdmin(w, r) {
+        return
+    }
+    id, _ := strconv.ParseInt(r.URL.Query().Get("id"), 10, 64)
+    h.renderVariance(w, r, id)
+}
+
+func (h *StocktakeHandler) renderVariance(w http.ResponseWriter, r *http.Request, id int64) {
+    stocktake, ok := h.get(w, r, id)
+    if !ok {
+        return
+    }
+
+    // Итоги считаются по посчитанным позициям; непосчитанные
+    // при проведении не меняются
+    var shortage, surplus money.Amount
+    var lines []models.StocktakeLine
+    uncounted := 0
+    for _, line := range stocktake.Lines {
+        switch {
+        case !line.Counted:
+            uncounted++
+        case line.Variance() < 0:
+            shortage -= line.VarianceValue()
+        case line.Variance() > 0:
+            surplus += line.VarianceValue()
+        }
+        if line.Variance() != 0 {
+            lines = append(lines, line)
+        }
+    }
+
+    data := h.sheetData(r, stocktake)
+    data["Differences"] = lines
+    data["Uncounted"] = uncounted
+    data["Shortage"] = shortage
+    data["Surplus"] = surplus
+    data["Net"] = surplus - shortage
+    h.renderer.Render(w, "stocktake_variance.html", data)
+}
+
+// ApproveStocktake проводит расхождения корректировками остатков.
+func (h *StocktakeHandler) ApproveStocktake(w http.ResponseWriter, r *http.Request) {
+    h.closeStocktake(w, r, true)
+}
+
+// CancelStocktake закрывает инвентаризацию без изменения остатков.
+func (h *StocktakeHandler) CancelStocktake(w http.ResponseWriter, r *http.Request) {
+    h.closeStocktake(w, r, false)
+}
+
+func (h *StocktakeHandler) closeStocktake(w http.ResponseWriter, r *http.Request, approve bool) {
+    if r.Method != http.MethodPost {
+        http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+        return
+    }
+    if !requireAdmin(w, r) {
+        return
+    }
+
+    id, err := strconv.ParseInt(r.URL.Query().Get("id"), 10, 64)
+    if err != nil {
+        http.Error(w, "Invalid ID", http.StatusBadRequest)
+        return
+    }
+
+    scope := scopeFor(r)
+    if approve {
+        err = h.stocktakes.Approve(r.Context(), scope, id, CurrentUser(r).ID)
+    } else {
+        err = h.stocktakes.Cancel(r.Context(), scope, id)
+    }
+    if errors.Is(err, repository.ErrNotFound) {
+        userError(w, http.StatusBadRequest, "Инвентаризация не найдена или уже закрыта")
+        return
+    }
+    if errors.Is(err, repository.ErrInsufficientStock) {
+        userError(w, http.StatusConflict,
+            "После подсчета товар успели отгрузить: недостачу по снимку списать нельзя. Пересчитайте позицию или отмените инвентаризацию")
+        return
+    }
+    if err != nil {
+        serverError(w, r, err)
+        return
+    }
+
+    h.renderVariance(w, r, id)
+}
+
+// get читает инвентаризацию области; если ее нет, отвечает 404.
+func (h *StocktakeHandler) get(w http.ResponseWriter, r *http.Request, id int64) (models.Stocktake, bool) {
+    stocktake, err := h.stocktakes.Get(r.Context(), scopeFor(r), id)
+    if errors.Is(err, repository.ErrNotFound) {
+        http.Error(w, "Инвентаризация не найдена", http.StatusNotFound)
+        return stocktake, false
+    }
+    if err != nil {
+        serverError(w, r, err)
+        return stocktake, false
+    }
+    return stocktake, true
+}
@@ -0,0 +1,233 @@
+package handlers
+
+import (
+    "errors"
+    "fmt"
+    "net/http"
+    "strconv"
+    "vend_erp/internal/models"
+    "vend_erp/internal/replenishment"
+    "vend_erp/internal/repository"
+    "vend_erp/internal/validate"
+)
+
+// SupplyHandler показывает заказы поставщикам. Черновики создает расчет
+// пополнения, утверждают и отклоняют их администраторы организации,
+// а утвержденный заказ принимают на склад, когда он пришел.
+type SupplyHandler struct {
+    supplies  repository.Supplies
+    replenish *replenishment.Engine
+    renderer  *TemplateRenderer
+}
+
+func NewSupplyHandler(supplies repository.Supplies, replenish *replenishment.Engine, renderer *TemplateRenderer) *SupplyHandler {
+    return &SupplyHandler{supplies: supplies, replenish: replenish, renderer: renderer}
+}
+
+func (h *SupplyHandler) ListSupplies(w http.ResponseWriter, r *http.Request) {
+    h.renderList(w, r, nil)
+}
+
+// replenishResult — результат ручного расчета пополнения: сколько создано
+// черновиков или что расчет уже выполняется.
+type replenishResult struct {
+    Created int
+    Busy    bool
+}
+
+// renderList показывает заказы; replenished — результат ручного расчета
+// пополнения, nil — расчет не запускали.
+func (h *SupplyHandler) renderList(w http.ResponseWriter, r *http.Request, replenished *replenishResult) {
+    scope := scopeFor(r)
+    supplies, err := h.supplies.List(r.Context(), scope)
+    if err != nil {
+        serverError(w, r, err)
+        return
+    }
+
+    drafts := 0
+    for _, supply := range supplies {
+        if supply.Status == repository.SupplyDraft {
+            drafts++
+        }
+    }
+    current := CurrentUser(r)
+
+    data := map[string]interface{}{
+        "Supplies":   supplies,
+        "DraftCount": drafts,
+        "CanManage":  current != nil && (current.UserRole == "admin" || current.IsSuperAdmin),
+        "AllOrgs":    scope.AllOrgs,
+        "Active":     "supplies",
+        "Title":      "Поставки",
+    }
+    if replenished != nil {
+        data["Replenished"] = replenished
+    }
+
+    if r.Header.Get("HX-Request") == "true" {
+        h.renderer.Render(w, "supplies_list.html", data)
+        return
+    }
+    h.renderer.Render(w, "supplies_page.html", data)
+}
+
+// Replenish запускает расчет пополнения для складов текущей области,
+// не дожидаясь расписания.
+func (h *SupplyHandler) Replenish(w http.ResponseWriter, r *http.Request) {
+    if r.Method != http.MethodPost {
+        http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+        return
+    }
+
+    drafts, err := h.replenish.Run(r.Context(), scopeFor(r))
+    if errors.Is(err, repository.ErrLocked) {
+        h.renderList(w, r, &replenishResult{Busy: true})
+        return
+    }
+    if err != nil {
+        serverError(w, r, err)
+        return
+    }
+    h.renderList(w, r, &replenishResult{Created: len(drafts)})
+}
+
+// ApproveSupply утверждает черновик: заказ получает статус ordered.
+func (h *SupplyHandler) ApproveSupply(w http.ResponseWriter, r *http.Request) {
+    h.reviewSupply(w, r, true)
+}
+
+// RejectSupply отклоняет черновик. Заказ остается в списке отмененным,
+// а его позиции снова попадут в следующий расчет пополнения.
+func (h *SupplyHandler) RejectSupply(w http.ResponseWriter, r *http.Request) {
+    h.reviewSupply(w, r, false)
+}
+
+func (h *SupplyHandler) reviewSupply(w http.ResponseWriter, r *http.Request, approve bool) {
+    if r.Method != http.MethodPost {
+        http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+        return
+    }
+    if !requireAdmin(w, r) {
+        return
+    }
+
+    id, err := strconv.ParseInt(r.URL.Query().Get("id"), 10, 64)
+    if err != nil {
+        http.Error(w, "Invalid ID", http.StatusBadRequest)
+        return
+    }
+
+    scope := scopeFor(r)
+    if approve {
+        err = h.supplies.Approve(r.Context(), scope, id, CurrentUser(r).ID)
+    } else {
+        err = h.supplies.Reject(r.Context(), scope, id)
+    }
+    if errors.Is(err, repository.ErrNotFound) {
+        userError(w, http.StatusBadRequest, "Черновик не найден или уже рассмотрен")
+        return
+    }
+    if err != nil {
+        serverError(w, r, err)
+        return
+    }
+
+    h.renderList(w, r, nil)
+}
+
+// GetReceiveForm показывает форму приемки заказа: сколько пришло по
+// каждой позиции, по умолчанию — все, что еще не получено.
+func (h *SupplyHandler) GetReceiveForm(w http.ResponseWriter, r *http.Request) {
+    id, err := strconv.ParseInt(r.URL.Query().Get("id"), 10, 64)
+    if err != nil {
+        http.Error(w, "Invalid ID", http.StatusBadRequest)
+        return
+    }
+    supply, ok := h.receivable(w, r, id)
+    if !ok {
+        return
+    }
+    h.renderReceiveForm(w, r, supply, nil)
+}
+
+// renderReceiveForm показывает форму приемки; непустая form — ответ на
+// неудачную попытку с ошибками полей.
+func (h *SupplyHandler) renderReceiveForm(w http.ResponseWriter, r *http.Request, supply models.WarehouseSupply, form *validate.Form) {
+    data := map[string]interface{}{
+        "Supply": supply,
+    }
+    if form != nil {
+        h.renderer.RenderInvalid(w, modalBody, "supply_receive_form.html", data, form)
+        return
+    }
+    h.renderer.Render(w, "supply_receive_form.html", data)
+}
+
+// ReceiveSupply приходует пришедшее по заказу на склад и закрывает заказ:
+// недопоставленное больше не считается заказанным, и следующий расчет
+// пополнения закажет его заново, если оно нужно.
+func (h *SupplyHandler) ReceiveSupply(w http.ResponseWriter, r *http.Request) {
+    if r.Method != http.MethodPost {
+        http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+        return
+    }
+    if err := r.ParseForm(); err != nil {
+        http.Error(w, err.Error(), http.StatusBadRequest)
+        return
+    }
+    
+    form := validate.New(r.PostForm)
+    supply, ok := h.receivable(w, r, form.ID("supply_id"))
+    if !ok {
+        return
+    }
+    received := make(map[int64]int, len(supply.Items))
+    for _, item := range supply.Items {
+        field := fmt.Sprintf("received_%d", item.ID)
+        form.Required(field)
+        received[item.ID] = form.Int(field)
+        form.Min(field, received[item.ID], 0)
+    }
+    if !form.Valid() {
+        h.renderReceiveForm(w, r, supply, form)
+        return
+    }
+    
+    err := h.supplies.Receive(r.Context(), scopeFor(r), supply.ID, received)
+    switch {
+    case errors.Is(err, repository.ErrNotFound):
+        // Заказ успели принять или отменить, пока форма была открыта
+        userError(w, http.StatusBadRequest, "Заказ не найден или уже принят")
+        return
+    case errors.Is(err, repository.ErrOverCapacity):
+        form.Fail("supply", "Не хватает места на складе или в зоне")
+        h.renderReceiveForm(w, r, supply, form)
+        return
+    case err != nil:
+        serverError(w, r, err)
+        return
+    }
+    
+    w.Header().Set("HX-Trigger", "supplyReceived")
+    h.renderList(w, r, nil)
+}
+
+// receivable читает заказ области, который можно принять; иначе отвечает
+// ошибкой.
+func (h *SupplyHandler) receivable(w http.ResponseWriter, r *http.Request, id int64) (models.WarehouseSupply, bool) {
+    supply, err := h.supplies.Get(r.Context(), scopeFor(r), id)
+    if errors.Is(err, repository.ErrNotFound) {
+        http.Error(w, "Заказ не найден", http.StatusNotFound)
+        return supply, false
+    }
+    if err != nil {
+        serverError(w, r, err)
+        return supply, false
+    }
+    if supply.Status != repository.SupplyOrdered && supply.Status != repository.SupplyInTransit {
+        userError(w, http.StatusBadRequest, "Принять можно только утвержденный заказ, который еще не получен")
+        return supply, false
+    }
+    return supply, true
+}
@@ -1,38 +1,121 @@
 package handlers
 
 import (
+	"context"
 	"fmt"
 	"html/template"
+	"io/fs"
+	"log/slog"
 	"net/http"
+	"path"
 	"path/filepath"
 	"strings"
+	"sync"
+	"time"
+
+	"vend_erp/internal/assets"
+	"vend_erp/internal/money"
+	"vend_erp/internal/validate"
 )
 
+// TemplateRenderer читает шаблоны из files — встроенного templates.FS или,
+// в режиме разработки, каталога на диске. В режиме live шаблоны
+// перечитываются при каждом запросе, поэтому правки видны без перезапуска.
 type TemplateRenderer struct {
+	files  fs.FS
+	static *assets.Assets
+	live   bool
+
+	mu        sync.RWMutex
 	templates map[string]*template.Template
 	funcMap   template.FuncMap
+	verbose   bool
 }
 
-func NewTemplateRenderer() *TemplateRenderer {
+func NewTemplateRenderer(files fs.FS, static *assets.Assets, live bool) *TemplateRenderer {
 	renderer := &TemplateRenderer{
+		files:     files,
+		static:    static,
+		live:      live,
 		templates: make(map[string]*template.Template),
+		verbose:   true,
 	}
 
 	renderer.addCustomFuncs()
-	renderer.loadTemplates()
-
-	fmt.Println("DEBUG: Loaded templates:")
-	for name := range renderer.templates {
-		fmt.Printf("  - %s\n", name)
+	if err := renderer.loadTemplates(); err != nil {
+		panic(err)
 	}
+	renderer.verbose = false
+
+	slog.Info("templates loaded", "count", len(renderer.templates), "live", live)
 
 	return renderer
 }
 
+// log пишет подробности загрузки шаблонов только при первой загрузке,
+// чтобы перезагрузка в режиме live не засоряла журнал.
+func (tr *TemplateRenderer) log(level slog.Level, msg string, args ...interface{}) {
+	if tr.verbose {
+		slog.Log(context.Background(), level, msg, args...)
+	}
+}
+
+// parseFiles разбирает файлы из tr.files; имена шаблонов — базовые имена
+// файлов, как у template.ParseFiles.
+func (tr *TemplateRenderer) parseFiles(t *template.Template, filenames ...string) (*template.Template, error) {
+	for _, filename := range filenames {
+		content, err := fs.ReadFile(tr.files, filename)
+		if err != nil {
+			return nil, err
+		}
+		name := path.Base(filename)
+		var tmpl *template.Template
+		if t.Name() == name {
+			tmpl = t
+		} else {
+			tmpl = t.New(name)
+		}
+		if _, err := tmpl.Parse(string(content)); err != nil {
+			return nil, err
+		}
+	}
+	return t, nil
+}
+
 func (tr *TemplateRenderer) addCustomFuncs() {
 	tr.funcMap = template.FuncMap{
-		"mult": func(a int, b float64) float64 {
-			return float64(a) * b
+		// mult — стоимость a единиц по цене b
+		"mult": func(a int, b money.Amount) money.Amount {
+			return b.Mul(a)
+		},
+		// money форматирует сумму в рублях: 1 234,50 ₽
+		"money": func(a money.Amount) string {
+			return a.Format()
+		},
+		// moneyIn форматирует сумму в валюте с кодом ISO 4217: {{moneyIn "USD" .Price}}
+		"moneyIn": func(code string, a money.Amount) string {
+			return money.Lookup(code).Format(a)
+		},
+		// field — значение поля формы: введенное пользователем, если форма
+		// вернулась с ошибками, иначе value из модели
+		"field": func(form *validate.Form, name string, value interface{}) string {
+			if form != nil {
+				return form.Value(name)
+			}
+			if t, ok := value.(time.Time); ok {
+				if t.IsZero() {
+					return ""
+				}
+				return t.Format(validate.DateLayout)
+			}
+			return fmt.Sprint(value)
+		},
+		// fieldError — сообщение об ошибке поля формы или пустая строка
+		"fieldError": func(form *validate.Form, name string) string {
+			if form == nil {
+				return ""
+			}
+			return form.Error(name)
 		},
 		"percent": func(a, b int) int {
 			if b == 0 {
@@ -43,60 +126,78 @@ func (tr *TemplateRenderer) addCustomFuncs() {
 		"subtract": func(a, b int) int {
 			return a - b
 		},
+		// asset возвращает адрес статического файла с хешем содержимого
+		"asset": tr.static.URL,
 	}
 }
 
-func (tr *TemplateRenderer) loadTemplates() {
+func (tr *TemplateRenderer) loadTemplates() error {
+	templates := make(map[string]*template.Template)
+
 	// Загружаем все шаблоны из templates/ папки
 	templatePatterns := []string{
-		"templates/*.html",
-		"templates/layouts/*.html",
-		"templates/partials/*.html",
-		"templates/components/*.html",
+		"*.html",
+		"layouts/*.html",
+		"partials/*.html",
+		"components/*.html",
 	}
 
 	// Собираем все файлы шаблонов
 	var templateFiles []string
 	for _, pattern := range templatePatterns {
-		files, err := filepath.Glob(pattern)
+		files, err := fs.Glob(tr.files, pattern)
 		if err != nil {
-			fmt.Printf("WARN: Error globbing pattern %s: %v\n", pattern, err)
+			tr.log(slog.LevelWarn, "globbing templates", "pattern", pattern, "err", err)
 			continue
 		}
 		templateFiles = append(templateFiles, files...)
 	}
 
 	if len(templateFiles) == 0 {
-		panic("No template files found")
+		return fmt.Errorf("no template files found")
 	}
 
-	fmt.Printf("DEBUG: Found %d template files:\n", len(templateFiles))
-	for _, file := range templateFiles {
-		fmt.Printf("  - %s\n", file)
-	}
+	tr.log(slog.LevelDebug, "found template files", "count", len(templateFiles), "files", templateFiles)
 
 	// Собираем ВСЕ файлы, которые нужны для базового шаблона
 	baseFiles := []string{
-		"templates/layouts/base.html",
-		"templates/partials/sidebar.html",
+		"layouts/base.html",
+		"partials/sidebar.html",
 		// Добавляем ВСЕ partials списков
-		"templates/partials/accounts_list.html",
-		"templates/partials/locations_list.html",
-		"templates/partials/machines_list.html",
-		"templates/partials/operations_list.html",
-		"templates/partials/warehouses_list.html",
+		"partials/accounts_list.html",
+		"partials/locations_list.html",
+		"partials/machines_list.html",
+		"partials/operations_list.html",
+		"partials/warehouses_list.html",
+		"partials/products_list.html",
+		"partials/supplies_list.html",
+		"partials/stocktakes_list.html",
+		"partials/stocktake_sheet.html",
+		"partials/stocktake_variance.html",
+		"partials/organizations_list.html",
 		// Добавляем ВСЕ формы
-		"templates/partials/account_form.html",
-		"templates/partials/location_form.html",
-		"templates/partials/machine_form.html",
-		"templates/partials/operation_form.html",
-		"templates/partials/warehouse_form.html",
-		"templates/partials/inventory_form.html",
-		"templates/partials/quick_action_form.html",
-		"templates/components/machines_chart.html",
-		"templates/components/operations_chart.html",
-		"templates/components/cash_chart.html",
-		"templates/components/toy_chart.html",
+		"partials/account_form.html",
+		"partials/location_form.html",
+		"partials/location_sales.html",
+		"partials/machine_form.html",
+		"partials/machine_planogram.html",
+		"partials/operation_form.html",
+		"partials/warehouse_form.html",
+		"partials/product_form.html",
+		"partials/inventory_form.html",
+		"partials/quick_action_form.html",
+		"partials/inventory_history.html",
+		"partials/inventory_valuation.html",
+		"partials/warehouse_utilization.html",
+		"partials/warehouse_zone_form.html",
+		"partials/stocktake_form.html",
+		"partials/supply_receive_form.html",
+		"partials/organization_form.html",
+		"partials/label_form.html",
+		"components/machines_chart.html",
+		"components/operations_chart.html",
+		"components/cash_chart.html",
+		"components/toy_chart.html",
 	}
 
 	// Проверяем существование файлов перед добавлением
@@ -104,101 +205,157 @@ func (tr *TemplateRenderer) loadTemplates() {
 	for _, file := range baseFiles {
 		if tr.fileExists(file) {
 			existingBaseFiles = append(existingBaseFiles, file)
-			fmt.Printf("DEBUG: Adding to base template: %s\n", file)
+			tr.log(slog.LevelDebug, "adding to base template", "file", file)
 		} else {
-			fmt.Printf("WARN: Base template file not found: %s\n", file)
+			tr.log(slog.LevelWarn, "base template file not found", "file", file)
 		}
 	}
 
 	// Создаем базовый шаблон с функциями и ВСЕМИ partials
 	baseTmpl := template.New("").Funcs(tr.funcMap)
-	baseTmpl = template.Must(baseTmpl.ParseFiles(existingBaseFiles...))
+	baseTmpl, err := tr.parseFiles(baseTmpl, existingBaseFiles...)
+	if err != nil {
+		return err
+	}
 
 	// Парсим все основные страницы
 	mainPages := []string{
-		"templates/accounts_page.html",
-		"templates/locations_page.html",
-		"templates/machines_page.html",
-		"templates/operations_page.html",
-		"templates/warehouses_page.html",
-		"templates/dashboard_page.html",
-		"templates/auth.html",
+		"accounts_page.html",
+		"locations_page.html",
+		"machines_page.html",
+		"operations_page.html",
+		"warehouses_page.html",
+		"products_page.html",
+		"supplies_page.html",
+		"stocktakes_page.html",
+		"stocktake_page.html",
+		"dashboard_page.html",
+		"organizations_page.html",
+		"auth.html",
 	}
 
 	for _, pagePath := range mainPages {
 		if !tr.fileExists(pagePath) {
-			fmt.Printf("WARN: Main page not found: %s\n", pagePath)
+			tr.log(slog.LevelWarn, "page template not found", "file", pagePath)
 			continue
 		}
 
 		// Создаем клон базового шаблона для каждой страницы
-		pageTmpl := template.Must(baseTmpl.Clone())
-		pageTmpl = template.Must(pageTmpl.ParseFiles(pagePath))
+		pageTmpl, err := baseTmpl.Clone()
+		if err != nil {
+			return err
+		}
+		if _, err := tr.parseFiles(pageTmpl, pagePath); err != nil {
+			return err
+		}
 
 		// Извлекаем имя файла без пути
 		name := filepath.Base(pagePath)
-		tr.templates[name] = pageTmpl
-		fmt.Printf("DEBUG: Loaded main page: %s\n", name)
+		templates[name] = pageTmpl
+		tr.log(slog.LevelDebug, "loaded page", "name", name)
 	}
 
 	// Также загружаем формы отдельно для HTMX запросов
 	forms := []string{
-		"templates/partials/account_form.html",
-		"templates/partials/location_form.html",
-		"templates/partials/machine_form.html",
-		"templates/partials/operation_form.html",
-		"templates/partials/warehouse_form.html",
-		"templates/partials/inventory_form.html",
-		"templates/partials/quick_action_form.html",
+		"partials/account_form.html",
+		"partials/location_form.html",
+		"partials/location_sales.html",
+		"partials/machine_form.html",
+		"partials/machine_planogram.html",
+		"partials/operation_form.html",
+		"partials/warehouse_form.html",
+		"partials/product_form.html",
+		"partials/inventory_form.html",
+		"partials/quick_action_form.html",
+		"partials/inventory_history.html",
+		"partials/inventory_valuation.html",
+		"partials/warehouse_utilization.html",
+		"partials/warehouse_zone_form.html",
+		"partials/stocktake_form.html",
+		"partials/supply_receive_form.html",
+		"partials/organization_form.html",
+		"partials/label_form.html",
+		"partials/invite_form.html",
+		"partials/invite_created.html",
+		"partials/conflict_form.html",
 	}
 
 	for _, formPath := range forms {
 		if !tr.fileExists(formPath) {
-			fmt.Printf("WARN: Form not found: %s\n", formPath)
+			tr.log(slog.LevelWarn, "form template not found", "file", formPath)
 			continue
 		}
 
-		formTmpl := template.New("").Funcs(tr.funcMap)
-		formTmpl = template.Must(formTmpl.ParseFiles(formPath))
-		
+		formTmpl, err := tr.parseFiles(template.New("").Funcs(tr.funcMap), formPath)
+		if err != nil {
+			return err
+		}
+
 		name := filepath.Base(formPath)
-		tr.templates[name] = formTmpl
-		fmt.Printf("DEBUG: Loaded form separately: %s\n", name)
+		templates[name] = formTmpl
+		tr.log(slog.LevelDebug, "loaded form", "name", name)
 	}
 
 	// Также загружаем partials списков отдельно для HTMX
 	partials := []string{
-		"templates/partials/accounts_list.html",
-		"templates/partials/locations_list.html",
-		"templates/partials/machines_list.html",
-		"templates/partials/operations_list.html",
-		"templates/partials/warehouses_list.html",
+		"partials/accounts_list.html",
+		"partials/locations_list.html",
+		"partials/machines_list.html",
+		"partials/operations_list.html",
+		"partials/warehouses_list.html",
+		"partials/products_list.html",
+		"partials/supplies_list.html",
+		"partials/stocktakes_list.html",
+		"partials/stocktake_sheet.html",
+		"partials/stocktake_variance.html",
+		"partials/organizations_list.html",
+		"partials/org_switcher.html",
+		"partials/invites_list.html",
+		"partials/password_strength.html",
 	}
 
 	for _, partialPath := range partials {
 		if !tr.fileExists(partialPath) {
-			fmt.Printf("WARN: Partial not found: %s\n", partialPath)
+			tr.log(slog.LevelWarn, "partial template not found", "file", partialPath)
 			continue
 		}
 
-		partialTmpl := template.New("").Funcs(tr.funcMap)
-		partialTmpl = template.Must(partialTmpl.ParseFiles(partialPath))
-		
+		partialTmpl, err := tr.parseFiles(template.New("").Funcs(tr.funcMap), partialPath)
+		if err != nil {
+			return err
+		}
+
 		name := filepath.Base(partialPath)
-		tr.templates[name] = partialTmpl
-		fmt.Printf("DEBUG: Loaded partial separately: %s\n", name)
+		templates[name] = partialTmpl
+		tr.log(slog.LevelDebug, "loaded partial", "name", name)
 	}
+
+	tr.mu.Lock()
+	tr.templates = templates
+	tr.mu.Unlock()
+	return nil
 }
 
-func (tr *TemplateRenderer) fileExists(path string) bool {
-	files, err := filepath.Glob(path)
-	return err == nil && len(files) > 0
+func (tr *TemplateRenderer) fileExists(name string) bool {
+	_, err := fs.Stat(tr.files, name)
+	return err == nil
 }
 
 func (tr *TemplateRenderer) Render(w http.ResponseWriter, name string, data interface{}) {
-	fmt.Printf("DEBUG: Attempting to render template: %s\n", name)
+	tr.RenderStatus(w, name, data, http.StatusOK)
+}
+
+// RenderStatus — Render с кодом ответа, отличным от 200.
+func (tr *TemplateRenderer) RenderStatus(w http.ResponseWriter, name string, data interface{}, status int) {
+	if tr.live {
+		if err := tr.loadTemplates(); err != nil {
+			renderError(w, name, fmt.Errorf("reloading templates: %w", err))
+			return
+		}
+	}
 
 	// Проверяем, есть ли шаблон
+	tr.mu.RLock()
 	tmpl, exists := tr.templates[name]
 	if !exists {
 		// Пробуем найти с расширением .html
@@ -206,16 +363,13 @@ func (tr *TemplateRenderer) Render(w http.ResponseWriter, name string, data inte
 			nameWithExt := name + ".html"
 			tmpl, exists = tr.templates[nameWithExt]
 		}
-		
-		if !exists {
-			fmt.Printf("ERROR: Template %s not found in registry\n", name)
-			fmt.Printf("DEBUG: Available templates:\n")
-			for tname := range tr.templates {
-				fmt.Printf("  - %s\n", tname)
-			}
-			http.Error(w, "Template not found: "+name, http.StatusInternalServerError)
-			return
-		}
+	}
+	tr.mu.RUnlock()
+
+	if !exists {
+		slog.Debug("available templates", "names", tr.getTemplateNames())
+		renderError(w, name, fmt.Errorf("template not found in registry"))
+		return
 	}
 
 	// Заголовки против кэширования
@@ -242,27 +396,30 @@ func (tr *TemplateRenderer) Render(w http.ResponseWriter, name string, data inte
 			tmplName = baseName
 		} else {
 			// Исполняем первый найденный шаблон
-			fmt.Printf("WARN: Template %s not found, using default execution\n", tmplName)
-			fmt.Printf("DEBUG: Available templates in this tmpl: %s\n", tmpl.DefinedTemplates())
+			slog.Warn("template not defined, using default execution", "name", tmplName, "defined", tmpl.DefinedTemplates())
+			if status != http.StatusOK {
+				w.WriteHeader(status)
+			}
 			if err := tmpl.Execute(w, data); err != nil {
-				fmt.Printf("ERROR: Template %s execution failed: %v\n", name, err)
-				http.Error(w, err.Error(), http.StatusInternalServerError)
+				renderError(w, name, err)
 			}
 			return
 		}
 	}
 
+	if status != http.StatusOK {
+		w.WriteHeader(status)
+	}
 	if err := tmpl.ExecuteTemplate(w, tmplName, data); err != nil {
-		fmt.Printf("ERROR: Template %s execution failed: %v\n", name, err)
-		fmt.Printf("DEBUG: Available templates in this tmpl: %s\n", tmpl.DefinedTemplates())
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		slog.Debug("defined templates", "name", name, "defined", tmpl.DefinedTemplates())
+		renderError(w, name, err)
 		return
 	}
-
-	fmt.Printf("DEBUG: Successfully rendered template: %s as %s\n", name, tmplName)
 }
 
 func (tr *TemplateRenderer) getTemplateNames() []string {
+	tr.mu.RLock()
+	defer tr.mu.RUnlock()
 	names := make([]string, 0, len(tr.templates))
 	for name := range tr.templates {
 		names = append(names, name)
@@ -0,0 +1,40 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+
+	"vend_erp/internal/repository"
+)
+
+// defaultOrgSlug — организация для самостоятельно зарегистрированных пользователей.
+const defaultOrgSlug = repository.DefaultOrganization
+
+type contextKey string
+
+const userContextKey contextKey = "user"
+
+// WithUser сохраняет аутентифицированного пользователя в контексте запроса.
+func WithUser(ctx context.Context, user *User) context.Context {
+	return context.WithValue(ctx, userContextKey, user)
+}
+
+// CurrentUser возвращает пользователя, сохраненного middleware авторизации.
+func CurrentUser(r *http.Request) *User {
+	user, _ := r.Context().Value(userContextKey).(*User)
+	return user
+}
+
+// OrgScope определяет, данные какой организации видит запрос.
+// AllOrgs включается только суперадмином в сводном режиме.
+type OrgScope = repository.Scope
+
+// scopeFor вычисляет область видимости для текущего пользователя.
+// Без пользователя в контексте возвращается область, не совпадающая ни с чем.
+func scopeFor(r *http.Request) OrgScope {
+	user := CurrentUser(r)
+	if user == nil {
+		return OrgScope{OrgID: -1}
+	}
+	return OrgScope{OrgID: user.ActiveOrgID, AllOrgs: user.AllOrgs, SuperAdmin: user.IsSuperAdmin}
+}
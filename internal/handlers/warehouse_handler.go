@@ -1,36 +1,47 @@
 package handlers
 
 import (
-    "database/sql"
+    "errors"
     "fmt"
     "net/http"
+    "net/url"
     "strconv"
+    "time"
     "vend_erp/internal/models"
+    "vend_erp/internal/money"
+    "vend_erp/internal/repository"
+    "vend_erp/internal/validate"
 )
 
+// reservationDays — срок резерва по умолчанию, дней.
+const reservationDays = 3
+
 type WarehouseHandler struct {
-    db       *sql.DB
-    renderer *TemplateRenderer
+    inventory repository.Inventory
+    machines  repository.Machines
+    products  repository.Products
+    renderer  *TemplateRenderer
 }
 
-func NewWarehouseHandler(db *sql.DB, renderer *TemplateRenderer) *WarehouseHandler {
-    return &WarehouseHandler{db: db, renderer: renderer}
+func NewWarehouseHandler(inventory repository.Inventory, machines repository.Machines, products repository.Products, renderer *TemplateRenderer) *WarehouseHandler {
+    return &WarehouseHandler{inventory: inventory, machines: machines, products: products, renderer: renderer}
 }
 
 func (h *WarehouseHandler) ListWarehouses(w http.ResponseWriter, r *http.Request) {
-    fmt.Printf("DEBUG: WarehouseHandler.ListWarehouses called for URL: %s\n", r.URL.Path)
+    
+    scope := scopeFor(r)
     
     // Получаем все склады
-    warehouses, err := h.getActiveWarehouses()
+    warehouses, err := h.inventory.Warehouses(r.Context(), scope)
     if err != nil {
-        http.Error(w, err.Error(), http.StatusInternalServerError)
+        serverError(w, r, err)
         return
     }
     
     // Получаем инвентарь со всеми складами
     inventory, err := h.getInventoryWithFilters(r)
     if err != nil {
-        http.Error(w, err.Error(), http.StatusInternalServerError)
+        serverError(w, r, err)
         return
     }
     
@@ -42,7 +53,8 @@ func (h *WarehouseHandler) ListWarehouses(w http.ResponseWriter, r *http.Request
         "Inventory":         inventory,
         "TotalItems":        len(inventory),
         "TotalWarehouses":   len(warehouses),
-        "TotalValue":        fmt.Sprintf("%.2f ₽", stats.TotalValue),
+        "AllOrgs":           scope.AllOrgs,
+        "TotalValue":        stats.TotalValue,
         "LowStockCount":     stats.LowStockCount,
         "OutOfStockCount":   stats.OutOfStockCount,
         "Active":            "warehouses",
@@ -58,118 +70,17 @@ func (h *WarehouseHandler) ListWarehouses(w http.ResponseWriter, r *http.Request
 }
 
 func (h *WarehouseHandler) getInventoryWithFilters(r *http.Request) ([]models.WarehouseInventory, error) {
-    warehouseID := r.URL.Query().Get("warehouse_id")
-    category := r.URL.Query().Get("category")
-    stockFilter := r.URL.Query().Get("stock")
-    
-    query := `
-        SELECT 
-            wi.id, wi.warehouse_id, wi.category_id, wi.item_type, 
-            wi.item_name, wi.description, wi.quantity, wi.min_stock_level,
-            wi.max_stock_level, wi.unit_price, wi.sku, wi.created_at, wi.updated_at,
-            w.name as warehouse_name, w.address as warehouse_address,
-            c.name as category_name
-        FROM warehouse_inventory wi
-        LEFT JOIN warehouse w ON wi.warehouse_id = w.id
-        LEFT JOIN warehouse_categories c ON wi.category_id = c.id
-        WHERE w.is_active = true
-    `
-    
-    args := []interface{}{}
-    argCount := 0
-    
-    if warehouseID != "" {
-        argCount++
-        query += fmt.Sprintf(" AND wi.warehouse_id = $%d", argCount)
-        args = append(args, warehouseID)
-    }
-    
-    if category != "" {
-        argCount++
-        query += fmt.Sprintf(" AND wi.item_type = $%d", argCount)
-        args = append(args, category)
-    }
-    
-    query += " ORDER BY w.name, wi.item_type, wi.item_name"
-    
-    rows, err := h.db.Query(query, args...)
-    if err != nil {
-        return nil, err
-    }
-    defer rows.Close()
-    
-    var inventory []models.WarehouseInventory
-    for rows.Next() {
-        var item models.WarehouseInventory
-        var createdAt, updatedAt sql.NullTime
-        
-        err := rows.Scan(
-            &item.ID, &item.WarehouseID, &item.CategoryID, &item.ItemType,
-            &item.ItemName, &item.Description, &item.Quantity, &item.MinStockLevel,
-            &item.MaxStockLevel, &item.UnitPrice, &item.SKU, &createdAt, &updatedAt,
-            &item.WarehouseName, &item.WarehouseAddress, &item.CategoryName,
-        )
-        if err != nil {
-            continue
-        }
-        
-        // Применяем фильтр по запасу
-        if stockFilter != "" {
-            if stockFilter == "low" && item.Quantity >= item.MinStockLevel {
-                continue
-            }
-            if stockFilter == "out" && item.Quantity > 0 {
-                continue
-            }
-            if stockFilter == "normal" && (item.Quantity < item.MinStockLevel || item.Quantity == 0) {
-                continue
-            }
-        }
-        
-        if createdAt.Valid {
-            item.CreatedAt = createdAt.Time
-        }
-        if updatedAt.Valid {
-            item.UpdatedAt = updatedAt.Time
-        }
-        
-        inventory = append(inventory, item)
-    }
-    
-    return inventory, nil
-}
-
-func (h *WarehouseHandler) getActiveWarehouses() ([]models.Warehouse, error) {
-    rows, err := h.db.Query(`
-        SELECT id, name, address, contact_person, contact_phone,
-               total_capacity, current_usage, is_active
-        FROM warehouse 
-        WHERE is_active = true 
-        ORDER BY name
-    `)
-    if err != nil {
-        return nil, err
-    }
-    defer rows.Close()
-    
-    var warehouses []models.Warehouse
-    for rows.Next() {
-        var warehouse models.Warehouse
-        err := rows.Scan(
-            &warehouse.ID, &warehouse.Name, &warehouse.Address,
-            &warehouse.ContactPerson, &warehouse.ContactPhone,
-            &warehouse.TotalCapacity, &warehouse.CurrentUsage, &warehouse.IsActive,
-        )
-        if err != nil {
-            continue
-        }
-        warehouses = append(warehouses, warehouse)
+    warehouseID, _ := strconv.ParseInt(r.URL.Query().Get("warehouse_id"), 10, 64)
+    filter := repository.InventoryFilter{
+        WarehouseID: warehouseID,
+        ItemType:    r.URL.Query().Get("category"),
+        Stock:       r.URL.Query().Get("stock"),
     }
-    return warehouses, nil
+    return h.inventory.Items(r.Context(), scopeFor(r), filter)
 }
 
 type InventoryStats struct {
-    TotalValue      float64
+    TotalValue      money.Amount
     LowStockCount   int
     OutOfStockCount int
 }
@@ -178,7 +89,7 @@ func (h *WarehouseHandler) calculateInventoryStats(inventory []models.WarehouseI
     var stats InventoryStats
     
     for _, item := range inventory {
-        stats.TotalValue += float64(item.Quantity) * item.UnitPrice
+        stats.TotalValue += item.StockValue
         
         if item.Quantity == 0 {
             stats.OutOfStockCount++
@@ -196,24 +107,27 @@ func (h *WarehouseHandler) GetWarehouseForm(w http.ResponseWriter, r *http.Reque
     
     if idStr != "" {
         id, _ := strconv.ParseInt(idStr, 10, 64)
-        err := h.db.QueryRow(`
-            SELECT id, name, address, contact_person, contact_phone,
-                   total_capacity, current_usage, is_active
-            FROM warehouse WHERE id = $1
-        `, id).Scan(
-            &warehouse.ID, &warehouse.Name, &warehouse.Address,
-            &warehouse.ContactPerson, &warehouse.ContactPhone,
-            &warehouse.TotalCapacity, &warehouse.CurrentUsage, &warehouse.IsActive,
-        )
-        if err != nil && err != sql.ErrNoRows {
-            http.Error(w, err.Error(), http.StatusInternalServerError)
+        var err error
+        warehouse, err = h.inventory.Warehouse(r.Context(), scopeFor(r), id)
+        if err != nil && !errors.Is(err, repository.ErrNotFound) {
+            serverError(w, r, err)
             return
         }
     }
     
+    h.renderWarehouseForm(w, warehouse, idStr != "", nil)
+}
+
+// renderWarehouseForm показывает форму склада; непустая form — ответ на
+// неудачное сохранение с ошибками полей.
+func (h *WarehouseHandler) renderWarehouseForm(w http.ResponseWriter, warehouse models.Warehouse, edit bool, form *validate.Form) {
     data := map[string]interface{}{
         "Warehouse": warehouse,
-        "Edit":      idStr != "",
+        "Edit":      edit,
+    }
+    if form != nil {
+        h.renderer.RenderInvalid(w, modalBody, "warehouse_form.html", data, form)
+        return
     }
     h.renderer.Render(w, "warehouse_form.html", data)
 }
@@ -224,42 +138,79 @@ func (h *WarehouseHandler) SaveWarehouse(w http.ResponseWriter, r *http.Request)
         return
     }
     
-    idStr := r.FormValue("id")
-    totalCapacity, _ := strconv.Atoi(r.FormValue("total_capacity"))
-    isActive := r.FormValue("is_active") == "true"
+    form := validate.New(r.PostForm)
+    form.Required("name", "address", "total_capacity")
+    form.MaxLength("name", 255)
+    form.MaxLength("contact_person", 255)
+    form.MaxLength("contact_phone", 50)
+    form.Phone("contact_phone")
     
     warehouse := models.Warehouse{
-        Name:          r.FormValue("name"),
-        Address:       r.FormValue("address"),
-        ContactPerson: r.FormValue("contact_person"),
-        ContactPhone:  r.FormValue("contact_phone"),
-        TotalCapacity: totalCapacity,
-        IsActive:      isActive,
+        ID:             form.ID("id"),
+        Version:        form.Int("version"),
+        Name:           form.Get("name"),
+        Address:        form.Get("address"),
+        ContactPerson:  form.Get("contact_person"),
+        ContactPhone:   form.Get("contact_phone"),
+        TotalCapacity:  form.Int("total_capacity"),
+        CapacityPolicy: form.OneOf("capacity_policy", repository.CapacityWarn, repository.CapacityBlock),
+        IsActive:       form.Get("is_active") == "true",
+    }
+    form.Min("total_capacity", warehouse.TotalCapacity, 1)
+    
+    scope := scopeFor(r)
+    
+    // Вместимость нельзя уменьшить ниже текущей загрузки
+    if warehouse.ID != 0 {
+        current, err := h.inventory.Warehouse(r.Context(), scope, warehouse.ID)
+        if errors.Is(err, repository.ErrNotFound) {
+            http.Error(w, "Склад не найден", http.StatusNotFound)
+            return
+        }
+        if err != nil {
+            serverError(w, r, err)
+            return
+        }
+        warehouse.CurrentUsage = current.CurrentUsage
+        form.Check(warehouse.TotalCapacity >= current.CurrentUsage, "total_capacity",
+            fmt.Sprintf("Не меньше текущей загрузки (%d)", current.CurrentUsage))
+        
+        // Зоны делят вместимость склада и не могут превышать ее в сумме
+        zones, err := h.inventory.Zones(r.Context(), scope, warehouse.ID)
+        if err != nil {
+            serverError(w, r, err)
+            return
+        }
+        zoned := 0
+        for _, zone := range zones {
+            zoned += zone.Capacity
+        }
+        form.Check(warehouse.TotalCapacity >= zoned, "total_capacity",
+            fmt.Sprintf("Не меньше суммы вместимостей зон (%d)", zoned))
+    }
+    if !form.Valid() {
+        h.renderWarehouseForm(w, warehouse, warehouse.ID != 0, form)
+        return
     }
     
     var err error
-    if idStr == "" || idStr == "0" {
-        _, err = h.db.Exec(`
-            INSERT INTO warehouse (name, address, contact_person, contact_phone, 
-                                 total_capacity, is_active)
-            VALUES ($1, $2, $3, $4, $5, $6)
-        `, warehouse.Name, warehouse.Address, warehouse.ContactPerson,
-           warehouse.ContactPhone, warehouse.TotalCapacity, warehouse.IsActive)
+    if warehouse.ID == 0 {
+        warehouse.OrgID = scope.OrgID
+        err = h.inventory.CreateWarehouse(r.Context(), &warehouse)
     } else {
-        id, _ := strconv.ParseInt(idStr, 10, 64)
-        warehouse.ID = id
-        
-        _, err = h.db.Exec(`
-            UPDATE warehouse 
-            SET name=$1, address=$2, contact_person=$3, contact_phone=$4,
-                total_capacity=$5, is_active=$6, updated_at=CURRENT_TIMESTAMP
-            WHERE id=$7
-        `, warehouse.Name, warehouse.Address, warehouse.ContactPerson,
-           warehouse.ContactPhone, warehouse.TotalCapacity, warehouse.IsActive, warehouse.ID)
+        err = h.inventory.UpdateWarehouse(r.Context(), scope, warehouse)
     }
     
+    if errors.Is(err, repository.ErrConflict) {
+        h.renderWarehouseConflict(w, r, warehouse)
+        return
+    }
+    if errors.Is(err, repository.ErrNotFound) {
+        http.Error(w, "Склад не найден", http.StatusNotFound)
+        return
+    }
     if err != nil {
-        http.Error(w, "Database error: "+err.Error(), http.StatusInternalServerError)
+        serverError(w, r, err)
         return
     }
     
@@ -267,37 +218,102 @@ func (h *WarehouseHandler) SaveWarehouse(w http.ResponseWriter, r *http.Request)
     h.ListWarehouses(w, r)
 }
 
+// renderWarehouseConflict показывает, чем склад, сохраненный другим
+// пользователем, отличается от отправленного.
+func (h *WarehouseHandler) renderWarehouseConflict(w http.ResponseWriter, r *http.Request, warehouse models.Warehouse) {
+    current, err := h.inventory.Warehouse(r.Context(), scopeFor(r), warehouse.ID)
+    if errors.Is(err, repository.ErrNotFound) {
+        http.Error(w, "Склад не найден", http.StatusNotFound)
+        return
+    }
+    if err != nil {
+        serverError(w, r, err)
+        return
+    }
+    
+    fields := []formField{
+        {Name: "name", Label: "Название склада"},
+        {Name: "address", Label: "Адрес склада"},
+        {Name: "contact_person", Label: "Контактное лицо"},
+        {Name: "contact_phone", Label: "Телефон"},
+        {Name: "total_capacity", Label: "Общая вместимость (ед.)"},
+        {Name: "capacity_policy", Label: "При нехватке места", Options: capacityPolicies},
+        {Name: "is_active", Label: "Активный склад", Options: yesNo},
+    }
+    h.renderer.RenderConflict(w, modalBody, newConflict("/warehouses/save", "#warehouses-table",
+        fields, warehouseValues(warehouse), warehouseValues(current)))
+}
+
+// warehouseValues переводит склад в значения его формы.
+func warehouseValues(wh models.Warehouse) url.Values {
+    return url.Values{
+        "id":              {formID(wh.ID)},
+        "version":         {strconv.Itoa(wh.Version)},
+        "name":            {wh.Name},
+        "address":         {wh.Address},
+        "contact_person":  {wh.ContactPerson},
+        "contact_phone":   {wh.ContactPhone},
+        "total_capacity":  {strconv.Itoa(wh.TotalCapacity)},
+        "capacity_policy": {wh.CapacityPolicy},
+        "is_active":       {flag(wh.IsActive)},
+    }
+}
+
+var capacityPolicies = map[string]string{
+    repository.CapacityWarn:  "Предупреждать",
+    repository.CapacityBlock: "Запрещать",
+}
+
 func (h *WarehouseHandler) GetInventoryForm(w http.ResponseWriter, r *http.Request) {
     idStr := r.URL.Query().Get("id")
     var inventoryItem models.WarehouseInventory
     
     if idStr != "" {
         id, _ := strconv.ParseInt(idStr, 10, 64)
-        err := h.db.QueryRow(`
-            SELECT id, warehouse_id, category_id, item_type, item_name,
-                   description, quantity, min_stock_level, max_stock_level,
-                   unit_price, sku
-            FROM warehouse_inventory WHERE id = $1
-        `, id).Scan(
-            &inventoryItem.ID, &inventoryItem.WarehouseID, &inventoryItem.CategoryID,
-            &inventoryItem.ItemType, &inventoryItem.ItemName, &inventoryItem.Description,
-            &inventoryItem.Quantity, &inventoryItem.MinStockLevel, &inventoryItem.MaxStockLevel,
-            &inventoryItem.UnitPrice, &inventoryItem.SKU,
-        )
-        if err != nil && err != sql.ErrNoRows {
-            http.Error(w, err.Error(), http.StatusInternalServerError)
+        var err error
+        inventoryItem, err = h.inventory.Item(r.Context(), scopeFor(r), id)
+        if err != nil && !errors.Is(err, repository.ErrNotFound) {
+            serverError(w, r, err)
             return
         }
     }
     
-    warehouses, _ := h.getActiveWarehouses()
-    categories, _ := h.getCategories()
+    h.renderInventoryForm(w, r, inventoryItem, idStr != "", nil)
+}
+
+// renderInventoryForm показывает форму позиции склада; непустая form —
+// ответ на неудачное сохранение с ошибками полей.
+func (h *WarehouseHandler) renderInventoryForm(w http.ResponseWriter, r *http.Request, inventoryItem models.WarehouseInventory, edit bool, form *validate.Form) {
+    warehouses, _ := h.inventory.Warehouses(r.Context(), scopeFor(r))
+    
+    // Товар позиции не меняется: при редактировании он только подписан,
+    // новая позиция выбирает из активных товаров организации
+    var products []models.Product
+    if edit {
+        if product, err := h.products.Get(r.Context(), scopeFor(r), inventoryItem.ProductID); err == nil {
+            inventoryItem.SKU = product.SKU
+            inventoryItem.ItemName = product.Name
+        }
+    } else {
+        products, _ = h.products.ListActive(r.Context(), scopeFor(r).OrgID)
+    }
+    
+    zones, err := h.warehouseZones(r, warehouses)
+    if err != nil {
+        serverError(w, r, err)
+        return
+    }
     
     data := map[string]interface{}{
         "InventoryItem": inventoryItem,
         "Warehouses":    warehouses,
-        "Categories":    categories,
-        "Edit":          idStr != "",
+        "Zones":         zones,
+        "Products":      products,
+        "Edit":          edit,
+    }
+    if form != nil {
+        h.renderer.RenderInvalid(w, modalBody, "inventory_form.html", data, form)
+        return
     }
     h.renderer.Render(w, "inventory_form.html", data)
 }
@@ -308,156 +324,289 @@ func (h *WarehouseHandler) SaveInventory(w http.ResponseWriter, r *http.Request)
         return
     }
     
-    idStr := r.FormValue("id")
-    warehouseID, _ := strconv.ParseInt(r.FormValue("warehouse_id"), 10, 64)
-    categoryID, _ := strconv.ParseInt(r.FormValue("category_id"), 10, 64)
-    quantity, _ := strconv.Atoi(r.FormValue("quantity"))
-    minStockLevel, _ := strconv.Atoi(r.FormValue("min_stock_level"))
-    maxStockLevel, _ := strconv.Atoi(r.FormValue("max_stock_level"))
-    unitPrice, _ := strconv.ParseFloat(r.FormValue("unit_price"), 64)
+    form := validate.New(r.PostForm)
+    form.Required("warehouse_id", "product_id", "quantity", "min_stock_level", "max_stock_level")
     
     inventoryItem := models.WarehouseInventory{
-        WarehouseID:   warehouseID,
-        CategoryID:    categoryID,
-        ItemType:      r.FormValue("item_type"),
-        ItemName:      r.FormValue("item_name"),
-        Description:   r.FormValue("description"),
-        Quantity:      quantity,
-        MinStockLevel: minStockLevel,
-        MaxStockLevel: maxStockLevel,
-        UnitPrice:     unitPrice,
-        SKU:           r.FormValue("sku"),
+        ID:            form.ID("id"),
+        Version:       form.Int("version"),
+        WarehouseID:   form.ID("warehouse_id"),
+        ZoneID:        form.ID("zone_id"),
+        ProductID:     form.ID("product_id"),
+        Quantity:      form.Int("quantity"),
+        MinStockLevel: form.Int("min_stock_level"),
+        MaxStockLevel: form.Int("max_stock_level"),
     }
     
+    form.Min("quantity", inventoryItem.Quantity, 0)
+    form.Min("min_stock_level", inventoryItem.MinStockLevel, 0)
+    form.Min("max_stock_level", inventoryItem.MaxStockLevel, 1)
+    form.Check(inventoryItem.MinStockLevel <= inventoryItem.MaxStockLevel, "min_stock_level",
+        "Не может превышать максимальный запас")
+    
+    // Склад должен быть виден в текущей области; при редактировании
+    // позиция не может уйти в склад другой организации
+    scope := scopeFor(r)
+    var warehouse models.Warehouse
     var err error
-    if idStr == "" || idStr == "0" {
-        _, err = h.db.Exec(`
-            INSERT INTO warehouse_inventory 
-            (warehouse_id, category_id, item_type, item_name, description,
-             quantity, min_stock_level, max_stock_level, unit_price, sku)
-            VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
-        `, inventoryItem.WarehouseID, inventoryItem.CategoryID, inventoryItem.ItemType,
-           inventoryItem.ItemName, inventoryItem.Description, inventoryItem.Quantity,
-           inventoryItem.MinStockLevel, inventoryItem.MaxStockLevel, inventoryItem.UnitPrice,
-           inventoryItem.SKU)
+    if inventoryItem.WarehouseID != 0 {
+        warehouse, err = h.inventory.Warehouse(r.Context(), scope, inventoryItem.WarehouseID)
+        if errors.Is(err, repository.ErrNotFound) {
+            form.Fail("warehouse_id", "Склад не найден")
+        } else if err != nil {
+            serverError(w, r, err)
+            return
+        }
+    }
+    if inventoryItem.ZoneID != 0 && warehouse.ID != 0 {
+        zones, err := h.inventory.Zones(r.Context(), scope, warehouse.ID)
+        if err != nil {
+            serverError(w, r, err)
+            return
+        }
+        _, ok := findZone(zones, inventoryItem.ZoneID)
+        form.Check(ok, "zone_id", "Зона не относится к выбранному складу")
+    }
+    
+    // Начальный остаток новой позиции — тот же приход
+    if form.Valid() && inventoryItem.ID == 0 && inventoryItem.Quantity > 0 {
+        product, err := h.products.Get(r.Context(), OrgScope{OrgID: warehouse.OrgID}, inventoryItem.ProductID)
+        if err == nil {
+            err = h.checkCapacity(r, form, warehouse, inventoryItem.ZoneID, inventoryItem.Quantity*product.UnitVolume, "quantity")
+        }
+        if err != nil && !errors.Is(err, repository.ErrNotFound) {
+            serverError(w, r, err)
+            return
+        }
+    }
+    if !form.Valid() {
+        h.renderInventoryForm(w, r, inventoryItem, inventoryItem.ID != 0, form)
+        return
+    }
+    
+    if inventoryItem.ID == 0 {
+        err = h.inventory.CreateItem(r.Context(), &inventoryItem)
     } else {
-        id, _ := strconv.ParseInt(idStr, 10, 64)
-        inventoryItem.ID = id
-        
-        _, err = h.db.Exec(`
-            UPDATE warehouse_inventory 
-            SET warehouse_id=$1, category_id=$2, item_type=$3, item_name=$4,
-                description=$5, quantity=$6, min_stock_level=$7, max_stock_level=$8,
-                unit_price=$9, sku=$10, updated_at=CURRENT_TIMESTAMP
-            WHERE id=$11
-        `, inventoryItem.WarehouseID, inventoryItem.CategoryID, inventoryItem.ItemType,
-           inventoryItem.ItemName, inventoryItem.Description, inventoryItem.Quantity,
-           inventoryItem.MinStockLevel, inventoryItem.MaxStockLevel, inventoryItem.UnitPrice,
-           inventoryItem.SKU, inventoryItem.ID)
+        err = h.inventory.UpdateItem(r.Context(), OrgScope{OrgID: warehouse.OrgID}, inventoryItem)
     }
     
+    if errors.Is(err, repository.ErrNotFound) && inventoryItem.ID == 0 {
+        // Товар не из справочника организации склада
+        form.Fail("product_id", "Товар не найден в организации склада")
+        h.renderInventoryForm(w, r, inventoryItem, false, form)
+        return
+    }
+    if errors.Is(err, repository.ErrNotFound) {
+        http.Error(w, "Позиция не найдена", http.StatusNotFound)
+        return
+    }
+    if errors.Is(err, repository.ErrConflict) {
+        h.renderInventoryConflict(w, r, inventoryItem)
+        return
+    }
+    if errors.Is(err, repository.ErrDuplicate) {
+        form.Fail("product_id", "Товар уже есть на этом складе")
+        h.renderInventoryForm(w, r, inventoryItem, inventoryItem.ID != 0, form)
+        return
+    }
+    if errors.Is(err, repository.ErrOverCapacity) {
+        form.Fail("quantity", "Не хватает места на складе или в зоне")
+        h.renderInventoryForm(w, r, inventoryItem, inventoryItem.ID != 0, form)
+        return
+    }
+    if errors.Is(err, repository.ErrInsufficientStock) {
+        form.Fail("quantity", "Нельзя списать зарезервированный товар: сначала снимите резервы")
+        h.renderInventoryForm(w, r, inventoryItem, true, form)
+        return
+    }
+    if errors.Is(err, repository.ErrInUse) {
+        form.Fail("warehouse_id", "У позиции есть действующие резервы: снимите их перед переносом на другой склад")
+        h.renderInventoryForm(w, r, inventoryItem, true, form)
+        return
+    }
     if err != nil {
-        http.Error(w, "Database error: "+err.Error(), http.StatusInternalServerError)
+        serverError(w, r, err)
         return
     }
     
-    // Обновляем использование склада
-    h.updateWarehouseUsage(warehouseID)
-    
     w.Header().Set("HX-Trigger", "inventorySaved")
     h.ListWarehouses(w, r)
 }
 
-func (h *WarehouseHandler) DeleteInventory(w http.ResponseWriter, r *http.Request) {
-    idStr := r.URL.Query().Get("id")
-    id, err := strconv.ParseInt(idStr, 10, 64)
+// renderInventoryConflict показывает, чем позиция склада, измененная другим
+// пользователем или корректировкой остатка, отличается от отправленной.
+func (h *WarehouseHandler) renderInventoryConflict(w http.ResponseWriter, r *http.Request, item models.WarehouseInventory) {
+    current, err := h.inventory.Item(r.Context(), scopeFor(r), item.ID)
+    if errors.Is(err, repository.ErrNotFound) {
+        http.Error(w, "Позиция не найдена", http.StatusNotFound)
+        return
+    }
     if err != nil {
-        http.Error(w, "Invalid ID", http.StatusBadRequest)
+        serverError(w, r, err)
         return
     }
-    
-    // Получаем warehouse_id перед удалением для обновления использования
-    var warehouseID int64
-    err = h.db.QueryRow("SELECT warehouse_id FROM warehouse_inventory WHERE id = $1", id).Scan(&warehouseID)
+    warehouses, err := h.inventory.Warehouses(r.Context(), scopeFor(r))
     if err != nil {
-        http.Error(w, err.Error(), http.StatusInternalServerError)
+        serverError(w, r, err)
         return
     }
     
-    _, err = h.db.Exec("DELETE FROM warehouse_inventory WHERE id = $1", id)
+    zones, err := h.warehouseZones(r, warehouses)
     if err != nil {
-        http.Error(w, err.Error(), http.StatusInternalServerError)
+        serverError(w, r, err)
         return
     }
+    zoneNames := map[string]string{"": "Не размещена"}
+    for _, group := range zones {
+        for _, zone := range group.Zones {
+            zoneNames[formID(zone.ID)] = group.Warehouse.Name + " / " + zone.Name
+        }
+    }
     
-    // Обновляем использование склада
-    h.updateWarehouseUsage(warehouseID)
-    
-    w.Header().Set("HX-Trigger", "inventoryDeleted")
-    h.ListWarehouses(w, r)
+    fields := []formField{
+        {Name: "warehouse_id", Label: "Склад", Options: idOptions(warehouses,
+            func(wh models.Warehouse) int64 { return wh.ID },
+            func(wh models.Warehouse) string { return wh.Name })},
+        {Name: "zone_id", Label: "Зона", Options: zoneNames},
+        // Товар позиции не меняется и всегда уходит скрытым полем
+        {Name: "product_id", Label: "Товар"},
+        {Name: "quantity", Label: "Текущее количество"},
+        {Name: "min_stock_level", Label: "Минимальный запас"},
+        {Name: "max_stock_level", Label: "Максимальный запас"},
+    }
+    h.renderer.RenderConflict(w, modalBody, newConflict("/warehouses/inventory-save", "#warehouses-table",
+        fields, inventoryValues(item), inventoryValues(current)))
+}
+
+var itemTypes = map[string]string{
+    "vending_machine": "Вендинговый автомат",
+    "toy":             "Игрушка",
+    "capsule":         "Капсула",
 }
 
-func (h *WarehouseHandler) updateWarehouseUsage(warehouseID int64) {
-    h.db.Exec(`
-        UPDATE warehouse 
-        SET current_usage = (
-            SELECT COALESCE(SUM(quantity), 0) 
-            FROM warehouse_inventory 
-            WHERE warehouse_id = $1
-        )
-        WHERE id = $1
-    `, warehouseID)
+// inventoryValues переводит позицию склада в значения ее формы.
+func inventoryValues(item models.WarehouseInventory) url.Values {
+    return url.Values{
+        "id":              {formID(item.ID)},
+        "version":         {strconv.Itoa(item.Version)},
+        "warehouse_id":    {formID(item.WarehouseID)},
+        "zone_id":         {formID(item.ZoneID)},
+        "product_id":      {formID(item.ProductID)},
+        "quantity":        {strconv.Itoa(item.Quantity)},
+        "min_stock_level": {strconv.Itoa(item.MinStockLevel)},
+        "max_stock_level": {strconv.Itoa(item.MaxStockLevel)},
+    }
 }
 
-func (h *WarehouseHandler) getCategories() ([]models.WarehouseCategory, error) {
-    rows, err := h.db.Query("SELECT id, name FROM warehouse_categories ORDER BY name")
+func (h *WarehouseHandler) DeleteInventory(w http.ResponseWriter, r *http.Request) {
+    idStr := r.URL.Query().Get("id")
+    id, err := strconv.ParseInt(idStr, 10, 64)
     if err != nil {
-        return nil, err
+        http.Error(w, "Invalid ID", http.StatusBadRequest)
+        return
     }
-    defer rows.Close()
     
-    var categories []models.WarehouseCategory
-    for rows.Next() {
-        var category models.WarehouseCategory
-        err := rows.Scan(&category.ID, &category.Name)
-        if err != nil {
-            continue
-        }
-        categories = append(categories, category)
+    err = h.inventory.DeleteItem(r.Context(), scopeFor(r), id)
+    if errors.Is(err, repository.ErrInUse) {
+        userError(w, http.StatusBadRequest,
+            "У позиции есть остаток или история движений, ее удалить нельзя: история нужна для остатков и оценки запасов на прошлые даты. Спишите остаток корректировкой, если товара больше нет.")
+        return
     }
-    return categories, nil
+    if err != nil {
+        serverError(w, r, err)
+        return
+    }
+    
+    w.Header().Set("HX-Trigger", "inventoryDeleted")
+    h.ListWarehouses(w, r)
 }
 
 func (h *WarehouseHandler) GetQuickActionForm(w http.ResponseWriter, r *http.Request) {
     itemID := r.URL.Query().Get("item_id")
     actionType := r.URL.Query().Get("action")
     
-    var item models.WarehouseInventory
-    err := h.db.QueryRow(`
-        SELECT wi.id, wi.quantity, wi.item_name, w.name as warehouse_name, w.id as warehouse_id
-        FROM warehouse_inventory wi
-        LEFT JOIN warehouse w ON wi.warehouse_id = w.id
-        WHERE wi.id = $1
-    `, itemID).Scan(&item.ID, &item.Quantity, &item.ItemName, &item.WarehouseName, &item.WarehouseID)
-    
+    id, _ := strconv.ParseInt(itemID, 10, 64)
+    item, err := h.inventory.Item(r.Context(), scopeFor(r), id)
+    if errors.Is(err, repository.ErrNotFound) {
+        http.Error(w, "Позиция не найдена", http.StatusNotFound)
+        return
+    }
     if err != nil {
-        http.Error(w, err.Error(), http.StatusInternalServerError)
+        serverError(w, r, err)
         return
     }
     
-    warehouses, _ := h.getActiveWarehouses()
+    h.renderQuickActionForm(w, r, item, actionType, nil)
+}
+
+// renderQuickActionForm показывает форму быстрого действия с позицией;
+// непустая form — ответ на неудачное выполнение с ошибками полей.
+func (h *WarehouseHandler) renderQuickActionForm(w http.ResponseWriter, r *http.Request, item models.WarehouseInventory, actionType string, form *validate.Form) {
+    // Перемещение возможно только между складами одной организации,
+    // резерв под пополнение — только для автоматов организации позиции
+    warehouses, _ := h.inventory.Warehouses(r.Context(), OrgScope{OrgID: item.OrgID})
+    var machines []models.VendingMachine
+    if actionType == "reserve" {
+        machines, _ = h.machines.ListActive(r.Context(), item.OrgID)
+    }
     
     data := map[string]interface{}{
         "ItemID":           item.ID,
         "ActionType":       actionType,
         "CurrentQuantity":  item.Quantity,
+        "Reserved":         item.Reserved,
+        "Available":        item.Available(),
         "ItemName":         item.ItemName,
+        "UnitPrice":        item.UnitPrice,
         "SourceWarehouse":  item.WarehouseName,
         "SourceWarehouseID": item.WarehouseID,
         "Warehouses":       warehouses,
+        "Machines":         machines,
         "Title":            getActionTitle(actionType),
     }
     
+    // Отгрузке предлагаем резервы под отгрузку, форме резерва — все
+    // действующие резервы позиции
+    if actionType == repository.MovementShipment || actionType == "reserve" {
+        reservations, err := h.inventory.Reservations(r.Context(), OrgScope{OrgID: item.OrgID}, item.ID)
+        if err != nil {
+            serverError(w, r, err)
+            return
+        }
+        if actionType != "reserve" {
+            reservations = reservationsOf(reservations, actionType)
+        }
+        data["Reservations"] = reservations
+    }
+    if actionType == "reserve" {
+        data["ExpiresAt"] = time.Now().AddDate(0, 0, reservationDays).Format(validate.DateLayout)
+    }
+    
+    // Приходу подсказываем место: зону позиции или зону, куда поместится
+    // пополнение до максимального запаса
+    if actionType == repository.MovementReceipt {
+        zones, err := h.inventory.Zones(r.Context(), OrgScope{OrgID: item.OrgID}, item.WarehouseID)
+        if err != nil {
+            serverError(w, r, err)
+            return
+        }
+        refill := max(item.MaxStockLevel-item.Quantity, 1)
+        data["Zones"] = zones
+        data["Refill"] = refill
+        if zone, ok := findZone(zones, item.ZoneID); ok {
+            data["Zone"] = zone
+            data["ZoneFits"] = zone.Free() / max(item.UnitVolume, 1)
+        }
+        zone, ok := repository.Putaway(zones, item.ZoneID, refill*item.UnitVolume)
+        if ok && zone.ID != item.ZoneID {
+            data["Suggested"] = zone
+        }
+        data["NoRoom"] = len(zones) > 0 && !ok
+    }
+    if form != nil {
+        h.renderer.RenderInvalid(w, modalBody, "quick_action_form.html", data, form)
+        return
+    }
     h.renderer.Render(w, "quick_action_form.html", data)
 }
 
@@ -468,154 +617,421 @@ func (h *WarehouseHandler) ExecuteQuickAction(w http.ResponseWriter, r *http.Req
     }
     
     itemID, _ := strconv.ParseInt(r.FormValue("item_id"), 10, 64)
+    item, err := h.inventory.Item(r.Context(), scopeFor(r), itemID)
+    if errors.Is(err, repository.ErrNotFound) {
+        http.Error(w, "Позиция не найдена", http.StatusNotFound)
+        return
+    }
+    if err != nil {
+        serverError(w, r, err)
+        return
+    }
+    
+    form := validate.New(r.PostForm)
     actionType := r.FormValue("action_type")
     
     switch actionType {
     case "adjust":
-        h.handleQuantityAdjustment(w, r, itemID)
+        h.handleQuantityAdjustment(w, r, item, form)
     case "transfer":
-        h.handleInventoryTransfer(w, r, itemID)
+        h.handleInventoryTransfer(w, r, item, form)
+    case "reserve":
+        h.handleReservation(w, r, item, form)
+    case repository.MovementReceipt, repository.MovementShipment:
+        h.handleStockMovement(w, r, item, actionType, form)
     default:
         http.Error(w, "Unknown action type", http.StatusBadRequest)
     }
 }
 
-func (h *WarehouseHandler) handleQuantityAdjustment(w http.ResponseWriter, r *http.Request, itemID int64) {
-    adjustmentType := r.FormValue("adjustment_type")
-    quantity, _ := strconv.Atoi(r.FormValue("quantity"))
-    reason := r.FormValue("reason")
+func (h *WarehouseHandler) handleQuantityAdjustment(w http.ResponseWriter, r *http.Request, item models.WarehouseInventory, form *validate.Form) {
+    form.Required("quantity")
+    adjustmentType := form.OneOf("adjustment_type", repository.AdjustAdd, repository.AdjustSubtract, repository.AdjustSet)
+    quantity := form.Int("quantity")
+    reason := form.Get("reason")
     
-    var currentQuantity int
-    var warehouseID int64
-    err := h.db.QueryRow("SELECT quantity, warehouse_id FROM warehouse_inventory WHERE id = $1", itemID).
-        Scan(&currentQuantity, &warehouseID)
-    if err != nil {
-        http.Error(w, err.Error(), http.StatusInternalServerError)
+    form.Min("quantity", quantity, 0)
+    if adjustmentType == repository.AdjustSubtract {
+        form.Check(quantity <= item.Available(), "quantity",
+            fmt.Sprintf("Нельзя убрать больше доступного (%d), отложенное сначала снимите с резерва", item.Available()))
+    }
+    if adjustmentType == repository.AdjustSet && quantity < item.Quantity {
+        form.Check(quantity >= item.Reserved, "quantity",
+            fmt.Sprintf("Остаток не может быть меньше зарезервированного (%d)", item.Reserved))
+    }
+    if !form.Valid() {
+        h.renderQuickActionForm(w, r, item, "adjust", form)
         return
     }
     
-    var newQuantity int
-    switch adjustmentType {
-    case "add":
-        newQuantity = currentQuantity + quantity
-    case "subtract":
-        newQuantity = currentQuantity - quantity
-        if newQuantity < 0 {
-            newQuantity = 0
-        }
-    case "set":
-        newQuantity = quantity
+    _, err := h.inventory.Adjust(r.Context(), scopeFor(r), item.ID, adjustmentType, quantity, reason)
+    switch {
+    case errors.Is(err, repository.ErrInsufficientStock):
+        // Остаток успели уменьшить или зарезервировать, пока форма была открыта
+        form.Fail("quantity", "Недостаточно доступного товара на складе")
+        h.renderQuickActionForm(w, r, item, "adjust", form)
+        return
+    case errors.Is(err, repository.ErrNotFound):
+        http.Error(w, "Позиция не найдена", http.StatusNotFound)
+        return
+    case err != nil:
+        serverError(w, r, err)
+        return
     }
     
-    _, err = h.db.Exec(`
-        UPDATE warehouse_inventory 
-        SET quantity = $1, updated_at = CURRENT_TIMESTAMP 
-        WHERE id = $2
-    `, newQuantity, itemID)
+    w.Header().Set("HX-Trigger", "inventoryAdjusted")
+    h.ListWarehouses(w, r)
+}
+
+// handleStockMovement проводит приход или отгрузку. Пополнение автомата
+// со склада записывается операцией пополнения.
+func (h *WarehouseHandler) handleStockMovement(w http.ResponseWriter, r *http.Request, item models.WarehouseInventory, actionType string, form *validate.Form) {
+    form.Required("quantity")
+    change := repository.StockChange{
+        Type:     actionType,
+        Quantity: form.Int("quantity"),
+        Reason:   form.Get("reason"),
+    }
+    if actionType == repository.MovementReceipt {
+        form.Min("quantity", change.Quantity, 1)
+        // Цена партии; без нее приход оценивается по закупочной цене товара
+        change.UnitCost = form.Money("unit_cost")
+        form.NotNegative("unit_cost", change.UnitCost)
+    } else {
+        // Расход берет доступный товар и отложенное под выбранный резерв
+        limit := item.Available()
+        change.ReservationID = form.ID("reservation_id")
+        if change.ReservationID != 0 {
+            reservations, err := h.inventory.Reservations(r.Context(), scopeFor(r), item.ID)
+            if err != nil {
+                serverError(w, r, err)
+                return
+            }
+            reservation, ok := findReservation(reservationsOf(reservations, actionType), change.ReservationID)
+            form.Check(ok, "reservation_id", "Резерв израсходован, снят или истек")
+            limit += reservation.Quantity
+        }
+        form.Min("quantity", change.Quantity, 1)
+        form.Check(change.Quantity <= limit, "quantity", fmt.Sprintf("Доступно не больше %d ед.", max(limit, 0)))
+    }
+    if form.Valid() && actionType == repository.MovementReceipt {
+        warehouse, err := h.inventory.Warehouse(r.Context(), scopeFor(r), item.WarehouseID)
+        if err == nil {
+            err = h.checkCapacity(r, form, warehouse, item.ZoneID, change.Quantity*item.UnitVolume, "quantity")
+        }
+        if err != nil {
+            serverError(w, r, err)
+            return
+        }
+    }
+    if !form.Valid() {
+        h.renderQuickActionForm(w, r, item, actionType, form)
+        return
+    }
     
-    if err != nil {
-        http.Error(w, err.Error(), http.StatusInternalServerError)
+    _, err := h.inventory.Move(r.Context(), scopeFor(r), item.ID, change)
+    switch {
+    case errors.Is(err, repository.ErrInsufficientStock):
+        // Товар успели отгрузить или зарезервировать, пока форма была открыта
+        form.Fail("quantity", "Недостаточно доступного товара на складе")
+    case errors.Is(err, repository.ErrOverCapacity):
+        form.Fail("quantity", "Не хватает места на складе или в зоне")
+    case errors.Is(err, repository.ErrNotFound) && change.ReservationID != 0:
+        form.Fail("reservation_id", "Резерв израсходован, снят или истек")
+    case errors.Is(err, repository.ErrNotFound):
+        http.Error(w, "Позиция не найдена", http.StatusNotFound)
+        return
+    case err != nil:
+        serverError(w, r, err)
+        return
+    }
+    if !form.Valid() {
+        h.renderQuickActionForm(w, r, item, actionType, form)
         return
     }
     
-    // Логируем операцию
-    h.db.Exec(`
-        INSERT INTO inventory_adjustments 
-        (inventory_item_id, adjustment_type, quantity, new_quantity, reason)
-        VALUES ($1, $2, $3, $4, $5)
-    `, itemID, adjustmentType, quantity, newQuantity, reason)
+    w.Header().Set("HX-Trigger", "inventoryMoved")
+    h.ListWarehouses(w, r)
+}
+
+// handleReservation откладывает товар позиции под запланированную
+// отгрузку или рейс пополнения до конца выбранного дня.
+func (h *WarehouseHandler) handleReservation(w http.ResponseWriter, r *http.Request, item models.WarehouseInventory, form *validate.Form) {
+    form.Required("quantity", "expires_at")
+    reservation := models.StockReservation{
+        ItemID:    item.ID,
+        Type:      form.OneOf("reservation_type", repository.MovementShipment, repository.MovementRestock),
+        Quantity:  form.Int("quantity"),
+        Reason:    form.Get("reason"),
+        CreatedBy: CurrentUser(r).ID,
+    }
+    if reservation.Type == repository.MovementRestock {
+        reservation.VendingMachineID = form.ID("vending_machine_id")
+    }
+    form.Min("quantity", reservation.Quantity, 1)
+    form.Check(reservation.Quantity <= item.Available(), "quantity",
+        fmt.Sprintf("Доступно не больше %d ед.", max(item.Available(), 0)))
+    day := form.Date("expires_at")
+    if !day.IsZero() {
+        reservation.ExpiresAt = time.Date(day.Year(), day.Month(), day.Day()+1, 0, 0, 0, 0, time.Local)
+        form.Check(reservation.ExpiresAt.After(time.Now()), "expires_at", "Срок резерва уже прошел")
+    }
+    form.MaxLength("reason", 500)
+    if !form.Valid() {
+        h.renderQuickActionForm(w, r, item, "reserve", form)
+        return
+    }
     
-    // Обновляем использование склада
-    h.updateWarehouseUsage(warehouseID)
+    err := h.inventory.Reserve(r.Context(), scopeFor(r), &reservation)
+    switch {
+    case errors.Is(err, repository.ErrInsufficientStock):
+        // Товар успели отгрузить или зарезервировать, пока форма была открыта
+        form.Fail("quantity", "Недостаточно доступного товара на складе")
+    case errors.Is(err, repository.ErrNotFound) && reservation.VendingMachineID != 0:
+        form.Fail("vending_machine_id", "Автомат не найден")
+    case errors.Is(err, repository.ErrNotFound):
+        http.Error(w, "Позиция не найдена", http.StatusNotFound)
+        return
+    case err != nil:
+        serverError(w, r, err)
+        return
+    }
+    if !form.Valid() {
+        h.renderQuickActionForm(w, r, item, "reserve", form)
+        return
+    }
     
-    w.Header().Set("HX-Trigger", "inventoryAdjusted")
+    w.Header().Set("HX-Trigger", "inventoryReserved")
     h.ListWarehouses(w, r)
 }
 
-func (h *WarehouseHandler) handleInventoryTransfer(w http.ResponseWriter, r *http.Request, itemID int64) {
-    quantity, _ := strconv.Atoi(r.FormValue("quantity"))
-    targetWarehouseID, _ := strconv.ParseInt(r.FormValue("target_warehouse_id"), 10, 64)
-    notes := r.FormValue("notes")
-    
-    // Получаем информацию об исходном товаре
-    var sourceItem models.WarehouseInventory
-    err := h.db.QueryRow(`
-        SELECT wi.*, w.name as warehouse_name 
-        FROM warehouse_inventory wi
-        LEFT JOIN warehouse w ON wi.warehouse_id = w.id
-        WHERE wi.id = $1
-    `, itemID).Scan(
-        &sourceItem.ID, &sourceItem.WarehouseID, &sourceItem.CategoryID, &sourceItem.ItemType,
-        &sourceItem.ItemName, &sourceItem.Description, &sourceItem.Quantity, &sourceItem.MinStockLevel,
-        &sourceItem.MaxStockLevel, &sourceItem.UnitPrice, &sourceItem.SKU, &sourceItem.CreatedAt, &sourceItem.UpdatedAt,
-        &sourceItem.WarehouseName,
-    )
+// ReleaseReservation снимает резерв и показывает резервы позиции заново.
+func (h *WarehouseHandler) ReleaseReservation(w http.ResponseWriter, r *http.Request) {
+    if r.Method != http.MethodPost {
+        http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+        return
+    }
+    if err := r.ParseForm(); err != nil {
+        http.Error(w, err.Error(), http.StatusBadRequest)
+        return
+    }
     
+    form := validate.New(r.PostForm)
+    scope := scopeFor(r)
+    err := h.inventory.Release(r.Context(), scope, form.ID("id"))
     if err != nil {
-        http.Error(w, err.Error(), http.StatusInternalServerError)
+        serverError(w, r, err)
         return
     }
-    
-    if sourceItem.Quantity < quantity {
-        http.Error(w, "Недостаточно товара для перемещения", http.StatusBadRequest)
+    item, err := h.inventory.Item(r.Context(), scope, form.ID("item_id"))
+    if errors.Is(err, repository.ErrNotFound) {
+        http.Error(w, "Позиция не найдена", http.StatusNotFound)
+        return
+    }
+    if err != nil {
+        serverError(w, r, err)
         return
     }
     
-    // Находим или создаем запись в целевом складе
-    var targetItemID int64
-    err = h.db.QueryRow(`
-        SELECT id FROM warehouse_inventory 
-        WHERE warehouse_id = $1 AND sku = $2
-    `, targetWarehouseID, sourceItem.SKU).Scan(&targetItemID)
-    
-    if err == sql.ErrNoRows {
-        // Создаем новую запись в целевом складе
-        err = h.db.QueryRow(`
-            INSERT INTO warehouse_inventory 
-            (warehouse_id, category_id, item_type, item_name, description,
-             quantity, min_stock_level, max_stock_level, unit_price, sku)
-            VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
-            RETURNING id
-        `, targetWarehouseID, sourceItem.CategoryID, sourceItem.ItemType,
-           sourceItem.ItemName, sourceItem.Description, quantity,
-           sourceItem.MinStockLevel, sourceItem.MaxStockLevel, sourceItem.UnitPrice,
-           sourceItem.SKU).Scan(&targetItemID)
-    } else if err == nil {
-        // Обновляем существующую запись
-        _, err = h.db.Exec(`
-            UPDATE warehouse_inventory 
-            SET quantity = quantity + $1, updated_at = CURRENT_TIMESTAMP
-            WHERE id = $2
-        `, quantity, targetItemID)
+    w.Header().Set("HX-Trigger", "reservationReleased")
+    h.renderQuickActionForm(w, r, item, "reserve", nil)
+}
+
+// reservationsOf оставляет резервы под отгрузку или под пополнение.
+func reservationsOf(reservations []models.StockReservation, kind string) []models.StockReservation {
+    var list []models.StockReservation
+    for _, reservation := range reservations {
+        if reservation.Type == kind {
+            list = append(list, reservation)
+        }
+    }
+    return list
+}
+
+func findReservation(reservations []models.StockReservation, id int64) (models.StockReservation, bool) {
+    for _, reservation := range reservations {
+        if reservation.ID == id {
+            return reservation, true
+        }
+    }
+    return models.StockReservation{}, false
+}
+
+// InventoryHistory показывает журнал движений позиции со стоимостью,
+// непустые партии и остаток на выбранную дату.
+func (h *WarehouseHandler) InventoryHistory(w http.ResponseWriter, r *http.Request) {
+    id, _ := strconv.ParseInt(r.URL.Query().Get("id"), 10, 64)
+    scope := scopeFor(r)
+    item, err := h.inventory.Item(r.Context(), scope, id)
+    if errors.Is(err, repository.ErrNotFound) {
+        http.Error(w, "Позиция не найдена", http.StatusNotFound)
+        return
+    }
+    if err != nil {
+        serverError(w, r, err)
+        return
     }
     
+    movements, err := h.inventory.Movements(r.Context(), scope, id)
     if err != nil {
-        http.Error(w, err.Error(), http.StatusInternalServerError)
+        serverError(w, r, err)
         return
     }
+    lots, err := h.inventory.Lots(r.Context(), scope, id)
+    if err != nil {
+        serverError(w, r, err)
+        return
+    }
+    
+    data := map[string]interface{}{
+        "Item":      item,
+        "Movements": movements,
+        "Lots":      lots,
+    }
     
-    // Уменьшаем количество в исходном складе
-    _, err = h.db.Exec(`
-        UPDATE warehouse_inventory 
-        SET quantity = quantity - $1, updated_at = CURRENT_TIMESTAMP
-        WHERE id = $2
-    `, quantity, itemID)
+    // Остаток на конец выбранного дня
+    form := validate.New(r.URL.Query())
+    if day := form.Date("at"); !day.IsZero() {
+        at := time.Date(day.Year(), day.Month(), day.Day()+1, 0, 0, 0, 0, time.Local).Add(-time.Microsecond)
+        balance, err := h.inventory.BalanceAt(r.Context(), scope, id, at)
+        if err != nil {
+            serverError(w, r, err)
+            return
+        }
+        data["At"] = day
+        data["BalanceAt"] = balance
+    }
+    data["Form"] = form
     
+    h.renderer.Render(w, "inventory_history.html", data)
+}
+
+// Valuation показывает себестоимость запасов по складам на начало и конец
+// периода и себестоимость товара, выданного в автоматы за период, по
+// операциям пополнения.
+func (h *WarehouseHandler) Valuation(w http.ResponseWriter, r *http.Request) {
+    scope := scopeFor(r)
+    form := validate.New(r.URL.Query())
+    today := time.Now()
+    from := form.Date("from")
+    if form.Get("from") == "" {
+        from = time.Date(today.Year(), today.Month(), 1, 0, 0, 0, 0, time.Local)
+    }
+    to := form.Date("to")
+    if form.Get("to") == "" {
+        to = today
+    }
+    form.Check(!to.Before(from), "to", "Конец периода раньше начала")
+    
+    data := map[string]interface{}{
+        "From": from,
+        "To":   to,
+    }
+    if !form.Valid() {
+        // Форма с ошибками показывается без отчета
+        data["Form"] = form
+        h.renderer.Render(w, "inventory_valuation.html", data)
+        return
+    }
+    
+    // Границы — начало первого дня и конец последнего
+    start := time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, time.Local)
+    end := time.Date(to.Year(), to.Month(), to.Day()+1, 0, 0, 0, 0, time.Local)
+    opening, err := h.inventory.Valuation(r.Context(), scope, start.Add(-time.Microsecond))
+    if err != nil {
+        serverError(w, r, err)
+        return
+    }
+    closing, err := h.inventory.Valuation(r.Context(), scope, end.Add(-time.Microsecond))
     if err != nil {
-        http.Error(w, err.Error(), http.StatusInternalServerError)
+        serverError(w, r, err)
+        return
+    }
+    restocks, err := h.inventory.CostOfSales(r.Context(), scope, start, end)
+    if err != nil {
+        serverError(w, r, err)
         return
     }
     
-    // Логируем перемещение
-    h.db.Exec(`
-        INSERT INTO inventory_transfers 
-        (source_item_id, target_item_id, quantity, notes)
-        VALUES ($1, $2, $3, $4)
-    `, itemID, targetItemID, quantity, notes)
+    // Склады — по остатку на конец периода с началом рядом
+    type warehouseRow struct {
+        Name            string
+        OpeningQuantity int
+        OpeningValue    money.Amount
+        Quantity        int
+        Value           money.Amount
+    }
+    var warehouses []warehouseRow
+    var openingTotal, closingTotal money.Amount
+    rowIndex := make(map[int64]int)
+    for _, v := range closing {
+        rowIndex[v.WarehouseID] = len(warehouses)
+        warehouses = append(warehouses, warehouseRow{Name: v.WarehouseName, Quantity: v.Quantity, Value: v.Value})
+        closingTotal += v.Value
+    }
+    for _, v := range opening {
+        i, ok := rowIndex[v.WarehouseID]
+        if !ok {
+            i = len(warehouses)
+            warehouses = append(warehouses, warehouseRow{Name: v.WarehouseName})
+        }
+        warehouses[i].OpeningQuantity = v.Quantity
+        warehouses[i].OpeningValue = v.Value
+        openingTotal += v.Value
+    }
+    
+    var cogs money.Amount
+    for _, c := range restocks {
+        cogs += c.Cost
+    }
     
-    // Обновляем использование обоих складов
-    h.updateWarehouseUsage(sourceItem.WarehouseID)
-    h.updateWarehouseUsage(targetWarehouseID)
+    data["Warehouses"] = warehouses
+    data["OpeningTotal"] = openingTotal
+    data["ClosingTotal"] = closingTotal
+    data["CostOfSales"] = restocks
+    data["CostOfSalesTotal"] = cogs
+    h.renderer.Render(w, "inventory_valuation.html", data)
+}
+
+func (h *WarehouseHandler) handleInventoryTransfer(w http.ResponseWriter, r *http.Request, item models.WarehouseInventory, form *validate.Form) {
+    form.Required("quantity", "target_warehouse_id")
+    quantity := form.Int("quantity")
+    targetWarehouseID := form.ID("target_warehouse_id")
+    notes := form.Get("notes")
+    
+    form.Min("quantity", quantity, 1)
+    form.Check(quantity <= item.Available(), "quantity",
+        fmt.Sprintf("Доступно не больше %d ед.: остальное в резерве", max(item.Available(), 0)))
+    form.Check(targetWarehouseID != item.WarehouseID, "target_warehouse_id", "Выберите другой склад")
+    if form.Valid() {
+        if err := h.checkTransferCapacity(r, form, item, targetWarehouseID, quantity); err != nil {
+            serverError(w, r, err)
+            return
+        }
+    }
+    if !form.Valid() {
+        h.renderQuickActionForm(w, r, item, "transfer", form)
+        return
+    }
+    
+    err := h.inventory.Transfer(r.Context(), scopeFor(r), item.ID, targetWarehouseID, quantity, notes)
+    switch {
+    case errors.Is(err, repository.ErrInsufficientStock):
+        form.Fail("quantity", "Недостаточно доступного товара для перемещения")
+    case errors.Is(err, repository.ErrOverCapacity):
+        form.Fail("quantity", "Не хватает места на целевом складе или в зоне")
+    case errors.Is(err, repository.ErrNotFound):
+        form.Fail("target_warehouse_id", "Целевой склад не найден")
+    case errors.Is(err, repository.ErrDuplicate):
+        form.Fail("target_warehouse_id", "Артикул уже используется")
+    case err != nil:
+        serverError(w, r, err)
+        return
+    }
+    if !form.Valid() {
+        h.renderQuickActionForm(w, r, item, "transfer", form)
+        return
+    }
     
     w.Header().Set("HX-Trigger", "inventoryTransferred")
     h.ListWarehouses(w, r)
@@ -627,7 +1043,348 @@ func getActionTitle(actionType string) string {
         return "Корректировка количества"
     case "transfer":
         return "Перемещение между складами"
+    case repository.MovementReceipt:
+        return "Приход на склад"
+    case repository.MovementShipment:
+        return "Отгрузка со склада"
+    case "reserve":
+        return "Резерв товара"
     default:
         return "Действие"
     }
-}
\ No newline at end of file
+}
+// zoneGroup — зоны одного склада для выбора места хранения.
+type zoneGroup struct {
+    Warehouse models.Warehouse
+    Zones     []models.WarehouseZone
+}
+
+// warehouseZones возвращает зоны складов; склады без зон пропускаются.
+func (h *WarehouseHandler) warehouseZones(r *http.Request, warehouses []models.Warehouse) ([]zoneGroup, error) {
+    var groups []zoneGroup
+    for _, wh := range warehouses {
+        zones, err := h.inventory.Zones(r.Context(), OrgScope{OrgID: wh.OrgID}, wh.ID)
+        if err != nil {
+            return nil, err
+        }
+        if len(zones) > 0 {
+            groups = append(groups, zoneGroup{Warehouse: wh, Zones: zones})
+        }
+    }
+    return groups, nil
+}
+
+func findZone(zones []models.WarehouseZone, id int64) (models.WarehouseZone, bool) {
+    for _, zone := range zones {
+        if zone.ID == id {
+            return zone, true
+        }
+    }
+    return models.WarehouseZone{}, false
+}
+
+// checkCapacity проверяет, поместятся ли volume единиц вместимости на склад
+// и в его зону zoneID. Если склад запрещает переполнение, это ошибка поля
+// field; если предупреждает — переполнение нужно подтвердить флажком
+// over_capacity. Ошибка возвращается, только если зоны не прочитать.
+func (h *WarehouseHandler) checkCapacity(r *http.Request, form *validate.Form, warehouse models.Warehouse, zoneID int64, volume int, field string) error {
+    var zone models.WarehouseZone
+    if zoneID != 0 {
+        zones, err := h.inventory.Zones(r.Context(), OrgScope{OrgID: warehouse.OrgID}, warehouse.ID)
+        if err != nil {
+            return err
+        }
+        zone, _ = findZone(zones, zoneID)
+    }
+    if !repository.OverCapacity(warehouse, zone, volume) {
+        return nil
+    }
+    
+    message := fmt.Sprintf("На складе «%s» свободно %d ед. вместимости, а нужно %d",
+        warehouse.Name, max(warehouse.Free(), 0), volume)
+    if zone.ID != 0 && zone.Free() < volume {
+        message = fmt.Sprintf("В зоне «%s» свободно %d ед. вместимости, а нужно %d",
+            zone.Name, max(zone.Free(), 0), volume)
+    }
+    if warehouse.CapacityPolicy == repository.CapacityBlock {
+        form.Fail(field, message)
+    } else if form.Get("over_capacity") != "true" {
+        form.Fail("over_capacity", message)
+    }
+    return nil
+}
+
+// checkTransferCapacity проверяет место на целевом складе; товар ляжет
+// в зону, где он на этом складе уже хранится.
+func (h *WarehouseHandler) checkTransferCapacity(r *http.Request, form *validate.Form, item models.WarehouseInventory, targetWarehouseID int64, quantity int) error {
+    scope := OrgScope{OrgID: item.OrgID}
+    target, err := h.inventory.Warehouse(r.Context(), scope, targetWarehouseID)
+    if errors.Is(err, repository.ErrNotFound) {
+        form.Fail("target_warehouse_id", "Целевой склад не найден")
+        return nil
+    }
+    if err != nil {
+        return err
+    }
+    
+    items, err := h.inventory.Items(r.Context(), scope, repository.InventoryFilter{WarehouseID: target.ID})
+    if err != nil {
+        return err
+    }
+    var zoneID int64
+    for _, other := range items {
+        if other.ProductID == item.ProductID {
+            zoneID = other.ZoneID
+        }
+    }
+    return h.checkCapacity(r, form, target, zoneID, quantity*item.UnitVolume, "quantity")
+}
+
+// placement — неразмещенная позиция и зона, куда ее стоит положить.
+type placement struct {
+    Item models.WarehouseInventory
+    Zone models.WarehouseZone
+    Fits bool
+}
+
+// Utilization показывает загрузку склада по зонам и неразмещенные
+// позиции с подсказкой, в какую зону их положить.
+func (h *WarehouseHandler) Utilization(w http.ResponseWriter, r *http.Request) {
+    id, _ := strconv.ParseInt(r.URL.Query().Get("id"), 10, 64)
+    h.renderUtilization(w, r, id)
+}
+
+// renderUtilization показывает загрузку склада id; без id — первого
+// склада области.
+func (h *WarehouseHandler) renderUtilization(w http.ResponseWriter, r *http.Request, id int64) {
+    scope := scopeFor(r)
+    warehouses, err := h.inventory.Warehouses(r.Context(), scope)
+    if err != nil {
+        serverError(w, r, err)
+        return
+    }
+    data := map[string]interface{}{
+        "Warehouses": warehouses,
+    }
+    if len(warehouses) == 0 {
+        h.renderer.Render(w, "warehouse_utilization.html", data)
+        return
+    }
+    
+    warehouse := warehouses[0]
+    if id != 0 {
+        found := false
+        for _, wh := range warehouses {
+            if wh.ID == id {
+                warehouse, found = wh, true
+            }
+        }
+        if !found {
+            http.Error(w, "Склад не найден", http.StatusNotFound)
+            return
+        }
+    }
+    
+    zones, err := h.inventory.Zones(r.Context(), scope, warehouse.ID)
+    if err != nil {
+        serverError(w, r, err)
+        return
+    }
+    items, err := h.inventory.Items(r.Context(), scope, repository.InventoryFilter{WarehouseID: warehouse.ID})
+    if err != nil {
+        serverError(w, r, err)
+        return
+    }
+    
+    zoned := 0
+    for _, zone := range zones {
+        zoned += zone.Capacity
+    }
+    var unplaced []placement
+    unplacedUsage := 0
+    for _, item := range items {
+        if item.ZoneID != 0 || item.Quantity == 0 {
+            continue
+        }
+        zone, ok := repository.Putaway(zones, 0, item.Volume())
+        unplaced = append(unplaced, placement{Item: item, Zone: zone, Fits: ok})
+        unplacedUsage += item.Volume()
+    }
+    
+    data["Warehouse"] = warehouse
+    data["Zones"] = zones
+    data["Zoned"] = zoned
+    data["Unplaced"] = unplaced
+    data["UnplacedUsage"] = unplacedUsage
+    h.renderer.Render(w, "warehouse_utilization.html", data)
+}
+
+// PlaceItem кладет позицию в зону, обычно по подсказке из загрузки склада.
+func (h *WarehouseHandler) PlaceItem(w http.ResponseWriter, r *http.Request) {
+    if r.Method != http.MethodPost {
+        http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+        return
+    }
+    if err := r.ParseForm(); err != nil {
+        http.Error(w, err.Error(), http.StatusBadRequest)
+        return
+    }
+    
+    form := validate.New(r.PostForm)
+    itemID := form.ID("item_id")
+    zoneID := form.ID("zone_id")
+    scope := scopeFor(r)
+    item, err := h.inventory.Item(r.Context(), scope, itemID)
+    if err == nil {
+        err = h.inventory.Place(r.Context(), scope, itemID, zoneID)
+    }
+    switch {
+    case errors.Is(err, repository.ErrNotFound):
+        userError(w, http.StatusBadRequest, "Позиция или зона не найдена")
+        return
+    case errors.Is(err, repository.ErrOverCapacity):
+        userError(w, http.StatusBadRequest, "В зоне не хватает места: выберите другую или расширьте ее")
+        return
+    case err != nil:
+        serverError(w, r, err)
+        return
+    }
+    
+    w.Header().Set("HX-Trigger", "inventoryPlaced")
+    h.renderUtilization(w, r, item.WarehouseID)
+}
+
+func (h *WarehouseHandler) GetZoneForm(w http.ResponseWriter, r *http.Request) {
+    warehouseID, _ := strconv.ParseInt(r.URL.Query().Get("warehouse_id"), 10, 64)
+    zone := models.WarehouseZone{WarehouseID: warehouseID}
+    if id, _ := strconv.ParseInt(r.URL.Query().Get("id"), 10, 64); id != 0 {
+        zones, err := h.inventory.Zones(r.Context(), scopeFor(r), warehouseID)
+        if err != nil && !errors.Is(err, repository.ErrNotFound) {
+            serverError(w, r, err)
+            return
+        }
+        var ok bool
+        if zone, ok = findZone(zones, id); !ok {
+            http.Error(w, "Зона не найдена", http.StatusNotFound)
+            return
+        }
+    }
+    h.renderZoneForm(w, r, zone, nil)
+}
+
+// renderZoneForm показывает форму зоны склада; непустая form — ответ на
+// неудачное сохранение с ошибками полей.
+func (h *WarehouseHandler) renderZoneForm(w http.ResponseWriter, r *http.Request, zone models.WarehouseZone, form *validate.Form) {
+    warehouse, err := h.inventory.Warehouse(r.Context(), scopeFor(r), zone.WarehouseID)
+    if errors.Is(err, repository.ErrNotFound) {
+        http.Error(w, "Склад не найден", http.StatusNotFound)
+        return
+    }
+    if err != nil {
+        serverError(w, r, err)
+        return
+    }
+    
+    data := map[string]interface{}{
+        "Zone":      zone,
+        "Warehouse": warehouse,
+    }
+    if form != nil {
+        h.renderer.RenderInvalid(w, modalBody, "warehouse_zone_form.html", data, form)
+        return
+    }
+    h.renderer.Render(w, "warehouse_zone_form.html", data)
+}
+
+func (h *WarehouseHandler) SaveZone(w http.ResponseWriter, r *http.Request) {
+    if err := r.ParseForm(); err != nil {
+        http.Error(w, err.Error(), http.StatusBadRequest)
+        return
+    }
+    
+    form := validate.New(r.PostForm)
+    form.Required("name", "capacity")
+    form.MaxLength("name", 100)
+    zone := models.WarehouseZone{
+        ID:          form.ID("id"),
+        WarehouseID: form.ID("warehouse_id"),
+        Name:        form.Get("name"),
+        Capacity:    form.Int("capacity"),
+    }
+    form.Min("capacity", zone.Capacity, 1)
+    
+    scope := scopeFor(r)
+    warehouse, err := h.inventory.Warehouse(r.Context(), scope, zone.WarehouseID)
+    if errors.Is(err, repository.ErrNotFound) {
+        http.Error(w, "Склад не найден", http.StatusNotFound)
+        return
+    }
+    if err != nil {
+        serverError(w, r, err)
+        return
+    }
+    zones, err := h.inventory.Zones(r.Context(), scope, warehouse.ID)
+    if err != nil {
+        serverError(w, r, err)
+        return
+    }
+    
+    // Зоны делят вместимость склада; зону нельзя сделать меньше того,
+    // что в ней уже лежит
+    zoned := zone.Capacity
+    for _, other := range zones {
+        if other.ID == zone.ID {
+            form.Check(zone.Capacity >= other.Usage, "capacity",
+                fmt.Sprintf("Не меньше текущей загрузки зоны (%d)", other.Usage))
+            continue
+        }
+        zoned += other.Capacity
+    }
+    form.Check(zoned <= warehouse.TotalCapacity, "capacity",
+        fmt.Sprintf("Зоны вместе не могут быть больше склада: свободно для зоны %d из %d",
+            max(warehouse.TotalCapacity-zoned+zone.Capacity, 0), warehouse.TotalCapacity))
+    if !form.Valid() {
+        h.renderZoneForm(w, r, zone, form)
+        return
+    }
+    
+    if zone.ID == 0 {
+        err = h.inventory.CreateZone(r.Context(), scope, &zone)
+    } else {
+        err = h.inventory.UpdateZone(r.Context(), scope, zone)
+    }
+    switch {
+    case errors.Is(err, repository.ErrDuplicate):
+        form.Fail("name", "На складе уже есть зона с таким названием")
+        h.renderZoneForm(w, r, zone, form)
+        return
+    case errors.Is(err, repository.ErrNotFound):
+        http.Error(w, "Зона не найдена", http.StatusNotFound)
+        return
+    case err != nil:
+        serverError(w, r, err)
+        return
+    }
+    
+    h.renderUtilization(w, r, warehouse.ID)
+}
+
+// DeleteZone удаляет зону; ее позиции становятся неразмещенными.
+func (h *WarehouseHandler) DeleteZone(w http.ResponseWriter, r *http.Request) {
+    if r.Method != http.MethodPost {
+        http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+        return
+    }
+    id, err := strconv.ParseInt(r.URL.Query().Get("id"), 10, 64)
+    if err != nil {
+        http.Error(w, "Invalid ID", http.StatusBadRequest)
+        return
+    }
+    warehouseID, _ := strconv.ParseInt(r.URL.Query().Get("warehouse_id"), 10, 64)
+    
+    if err := h.inventory.DeleteZone(r.Context(), scopeFor(r), id); err != nil {
+        serverError(w, r, err)
+        return
+    }
+    h.renderUtilization(w, r, warehouseID)
+}
@@ -0,0 +1,300 @@
+// Package labels печатает листы этикеток: штрихкоды товаров (EAN-13,
+// EAN-8 или Code 128) и QR-коды автоматов и локаций.
+//
+// Этикетки раскладываются по формату config.LabelTemplate слева направо
+// и сверху вниз, лист за листом. Коды рисуются прямоугольниками, а не
+// картинками: в PDF они остаются векторными, а в PNG ширина модуля
+// округляется до целого числа точек, чтобы штрихи не получались разной
+// толщины.
+package labels
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/boombuler/barcode"
+	"github.com/boombuler/barcode/code128"
+	"github.com/boombuler/barcode/ean"
+	"github.com/boombuler/barcode/qr"
+
+	"vend_erp/config"
+)
+
+// Символики кодов Label.Symbology
+const (
+	Code128 = "code128"
+	EAN     = "ean"
+	QR      = "qr"
+)
+
+// Label — содержимое одной этикетки.
+type Label struct {
+	Symbology string
+	// Code — что закодировать: штрихкод, артикул или ссылка
+	Code string
+	// Title печатается жирным, Caption — обычным шрифтом под ним
+	Title   string
+	Caption string
+}
+
+// CodeError сообщает, что код этикетки нельзя закодировать выбранной
+// символикой, например артикул с кириллицей в Code 128.
+type CodeError struct {
+	Label Label
+	Err   error
+}
+
+func (e *CodeError) Error() string {
+	return fmt.Sprintf("encoding %q as %s: %v", e.Label.Code, e.Label.Symbology, e.Err)
+}
+
+func (e *CodeError) Unwrap() error { return e.Err }
+
+// ProductCode выбирает код товара: штрихкод EAN-13 или EAN-8 с верной
+// контрольной цифрой печатается как EAN, любой другой штрихкод или,
+// если его нет, артикул — как Code 128.
+func ProductCode(barcode, sku string) (symbology, code string) {
+	if barcode == "" {
+		return Code128, sku
+	}
+	if len(barcode) == 8 || len(barcode) == 13 {
+		if _, err := ean.Encode(barcode); err == nil {
+			return EAN, barcode
+		}
+	}
+	return Code128, barcode
+}
+
+// Pages возвращает, сколько листов займут count этикеток, если первые
+// skip мест на листе уже использованы.
+func Pages(t config.LabelTemplate, count, skip int) int {
+	if count == 0 {
+		return 0
+	}
+	return (skip+count-1)/t.PerSheet() + 1
+}
+
+// Check проверяет, что коды всех этикеток кодируются, не рисуя листов.
+func Check(list []Label) error {
+	for _, l := range list {
+		if _, err := encode(l); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func encode(l Label) (barcode.Barcode, error) {
+	var code barcode.Barcode
+	var err error
+	switch l.Symbology {
+	case EAN:
+		code, err = ean.Encode(l.Code)
+	case Code128:
+		code, err = code128.Encode(l.Code)
+	case QR:
+		code, err = qr.Encode(l.Code, qr.M, qr.Auto)
+	default:
+		err = fmt.Errorf("unknown symbology")
+	}
+	if err != nil {
+		return nil, &CodeError{Label: l, Err: err}
+	}
+	return code, nil
+}
+
+// canvas — поверхность листа, координаты в миллиметрах от левого
+// верхнего угла.
+type canvas interface {
+	// fill закрашивает прямоугольник черным
+	fill(x, y, w, h float64)
+	// text печатает строку; y — верх строки, size — кегль в пунктах
+	text(x, y float64, s string, size float64, bold bool)
+	width(s string, size float64, bold bool) float64
+	// dot — размер точки устройства; 0 — векторный вывод
+	dot() float64
+}
+
+// lineHeight — высота строки кегля size в миллиметрах.
+func lineHeight(size float64) float64 {
+	return size * 25.4 / 72 * 1.2
+}
+
+// sheet — этикетки, разложенные по листам.
+type sheet struct {
+	t     config.LabelTemplate
+	codes []barcode.Barcode
+	list  []Label
+	skip  int
+}
+
+func newSheet(t config.LabelTemplate, list []Label, skip int) (*sheet, error) {
+	s := &sheet{t: t, list: list, skip: skip % t.PerSheet()}
+	for _, l := range list {
+		code, err := encode(l)
+		if err != nil {
+			return nil, err
+		}
+		s.codes = append(s.codes, code)
+	}
+	return s, nil
+}
+
+func (s *sheet) pages() int {
+	return Pages(s.t, len(s.list), s.skip)
+}
+
+// draw рисует на c этикетки листа page (с нуля).
+func (s *sheet) draw(c canvas, page int) {
+	per := s.t.PerSheet()
+	for i := range s.list {
+		place := s.skip + i
+		if place/per != page {
+			continue
+		}
+		col, row := place%per%s.t.Columns, place%per/s.t.Columns
+		x := s.t.MarginLeft + float64(col)*(s.t.LabelWidth+s.t.GapX)
+		y := s.t.MarginTop + float64(row)*(s.t.LabelHeight+s.t.GapY)
+		if s.list[i].Symbology == QR {
+			s.drawQR(c, x, y, s.list[i], s.codes[i])
+		} else {
+			s.drawLinear(c, x, y, s.list[i], s.codes[i])
+		}
+	}
+}
+
+// padding — поле внутри этикетки.
+func (s *sheet) padding() float64 {
+	return min(2, s.t.LabelHeight*0.08)
+}
+
+// drawQR рисует QR-код слева и подписи справа от него.
+func (s *sheet) drawQR(c canvas, x, y float64, l Label, code barcode.Barcode) {
+	pad := s.padding()
+	w, h := s.t.LabelWidth, s.t.LabelHeight
+	modules := code.Bounds().Dx()
+	module := snap(c, min(h-2*pad, w/2)/float64(modules))
+	side := module * float64(modules)
+	top := y + (h-side)/2
+	for my := 0; my < modules; my++ {
+		for mx := 0; mx < modules; mx++ {
+			if dark(code, mx, my) {
+				c.fill(x+pad+float64(mx)*module, top+float64(my)*module, module, module)
+			}
+		}
+	}
+
+	// Между кодом и текстом — поле не уже четырех модулей
+	tx := x + pad + side + max(pad, 4*module)
+	tw := x + w - pad - tx
+	size := s.t.FontSize
+	line := lineHeight(size)
+	lines := int((h - 2*pad) / line)
+	title := wrap(c, l.Title, tw, size, true, min(lines, 3))
+	caption := wrap(c, l.Caption, tw, size, false, lines-len(title))
+	ty := y + (h-line*float64(len(title)+len(caption)))/2
+	for _, text := range title {
+		c.text(tx, ty, text, size, true)
+		ty += line
+	}
+	for _, text := range caption {
+		c.text(tx, ty, text, size, false)
+		ty += line
+	}
+}
+
+// drawLinear рисует название сверху, штрихи и под ними код и подпись.
+func (s *sheet) drawLinear(c canvas, x, y float64, l Label, code barcode.Barcode) {
+	pad := s.padding()
+	w, h := s.t.LabelWidth, s.t.LabelHeight
+	size := s.t.FontSize
+	line := lineHeight(size)
+
+	title := fit(c, l.Title, w-2*pad, size, true)
+	c.text(x+(w-c.width(title, size, true))/2, y+pad, title, size, true)
+	var below []string
+	below = append(below, l.Code)
+	if l.Caption != "" && l.Caption != l.Code {
+		below = append(below, l.Caption)
+	}
+
+	// Справа и слева от штрихов нужна свободная зона в десяток модулей
+	modules := code.Bounds().Dx()
+	module := snap(c, (w-2*pad)/float64(modules+20))
+	bars := module * float64(modules)
+	left := x + (w-bars)/2
+	top := y + pad + line
+	height := h - 2*pad - line*float64(1+len(below))
+	for mx := 0; mx < modules; {
+		if !dark(code, mx, 0) {
+			mx++
+			continue
+		}
+		run := mx
+		for run < modules && dark(code, run, 0) {
+			run++
+		}
+		c.fill(left+float64(mx)*module, top, float64(run-mx)*module, height)
+		mx = run
+	}
+
+	ty := top + height
+	for _, text := range below {
+		text = fit(c, text, w-2*pad, size, false)
+		c.text(x+(w-c.width(text, size, false))/2, ty, text, size, false)
+		ty += line
+	}
+}
+
+// snap округляет ширину модуля вниз до целого числа точек устройства.
+func snap(c canvas, module float64) float64 {
+	dot := c.dot()
+	if dot == 0 || module < dot {
+		return module
+	}
+	return float64(int(module/dot)) * dot
+}
+
+func dark(code barcode.Barcode, x, y int) bool {
+	r, _, _, _ := code.At(code.Bounds().Min.X+x, code.Bounds().Min.Y+y).RGBA()
+	return r < 0x8000
+}
+
+// fit обрезает строку по ширине, заменяя хвост многоточием.
+func fit(c canvas, s string, width, size float64, bold bool) string {
+	if c.width(s, size, bold) <= width {
+		return s
+	}
+	runes := []rune(s)
+	for len(runes) > 0 {
+		runes = runes[:len(runes)-1]
+		if cut := strings.TrimSpace(string(runes)) + "…"; c.width(cut, size, bold) <= width {
+			return cut
+		}
+	}
+	return ""
+}
+
+// wrap переносит текст по словам не более чем в lines строк; не
+// поместившийся остаток обрезается многоточием.
+func wrap(c canvas, s string, width, size float64, bold bool, lines int) []string {
+	words := strings.Fields(s)
+	if lines <= 0 || len(words) == 0 {
+		return nil
+	}
+	var out []string
+	current := words[0]
+	for i, word := range words[1:] {
+		if next := current + " " + word; c.width(next, size, bold) <= width {
+			current = next
+			continue
+		}
+		if len(out) == lines-1 {
+			current = strings.Join(append([]string{current}, words[i+1:]...), " ")
+			break
+		}
+		out = append(out, fit(c, current, width, size, bold))
+		current = word
+	}
+	return append(out, fit(c, current, width, size, bold))
+}
@@ -0,0 +1,140 @@
+package labels_test
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"vend_erp/config"
+	"vend_erp/internal/labels"
+)
+
+func TestProductCode(t *testing.T) {
+	tests := []struct {
+		name          string
+		barcode, sku  string
+		wantSymbology string
+		wantCode      string
+	}{
+		{"EAN-13", "4006381333931", "SKU-1", labels.EAN, "4006381333931"},
+		{"EAN-8", "96385074", "SKU-1", labels.EAN, "96385074"},
+		{"EAN-13 with wrong check digit", "4006381333932", "SKU-1", labels.Code128, "4006381333932"},
+		{"EAN-8 with wrong check digit", "96385075", "SKU-1", labels.Code128, "96385075"},
+		{"EAN length with letters", "40063813339AB", "SKU-1", labels.Code128, "40063813339AB"},
+		{"other length", "123456789012", "SKU-1", labels.Code128, "123456789012"},
+		{"no barcode", "", "SKU-1", labels.Code128, "SKU-1"},
+		{"neither", "", "", labels.Code128, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			symbology, code := labels.ProductCode(tt.barcode, tt.sku)
+			if symbology != tt.wantSymbology || code != tt.wantCode {
+				t.Errorf("ProductCode(%q, %q) = %s %q, want %s %q",
+					tt.barcode, tt.sku, symbology, code, tt.wantSymbology, tt.wantCode)
+			}
+		})
+	}
+}
+
+func TestPages(t *testing.T) {
+	// 3×8 = 24 этикетки на листе
+	sheet := config.LabelTemplate{Columns: 3, Rows: 8}
+	tests := []struct {
+		count, skip int
+		want        int
+	}{
+		{0, 0, 0},
+		{0, 5, 0},
+		{1, 0, 1},
+		{24, 0, 1},
+		{25, 0, 2},
+		{48, 0, 2},
+		{49, 0, 3},
+		{1, 23, 1},
+		{2, 23, 2},
+		{24, 1, 2},
+		{23, 1, 1},
+	}
+	for _, tt := range tests {
+		if got := labels.Pages(sheet, tt.count, tt.skip); got != tt.want {
+			t.Errorf("Pages(%d, skip %d) = %d, want %d", tt.count, tt.skip, got, tt.want)
+		}
+	}
+}
+
+func TestCheck(t *testing.T) {
+	tests := []struct {
+		name    string
+		list    []labels.Label
+		wantBad string
+	}{
+		{name: "empty"},
+		{name: "valid", list: []labels.Label{
+			{Symbology: labels.EAN, Code: "4006381333931"},
+			{Symbology: labels.EAN, Code: "96385074"},
+			{Symbology: labels.Code128, Code: "SKU-001"},
+			{Symbology: labels.QR, Code: "https://erp.example.com/machines/7"},
+			{Symbology: labels.QR, Code: "Автомат №7"},
+		}},
+		{name: "cyrillic in Code 128", wantBad: "МИШКА-1", list: []labels.Label{
+			{Symbology: labels.Code128, Code: "SKU-001"},
+			{Symbology: labels.Code128, Code: "МИШКА-1"},
+		}},
+		{name: "EAN with wrong check digit", wantBad: "4006381333932", list: []labels.Label{
+			{Symbology: labels.EAN, Code: "4006381333932"},
+		}},
+		{name: "EAN with letters", wantBad: "ABCDEFGH", list: []labels.Label{
+			{Symbology: labels.EAN, Code: "ABCDEFGH"},
+		}},
+		{name: "unknown symbology", wantBad: "123", list: []labels.Label{
+			{Symbology: "pdf417", Code: "123"},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := labels.Check(tt.list)
+			if tt.wantBad == "" {
+				if err != nil {
+					t.Errorf("Check = %v, want nil", err)
+				}
+				return
+			}
+			var codeErr *labels.CodeError
+			if !errors.As(err, &codeErr) {
+				t.Fatalf("Check = %v, want *CodeError", err)
+			}
+			if codeErr.Label.Code != tt.wantBad {
+				t.Errorf("CodeError.Label.Code = %q, want %q", codeErr.Label.Code, tt.wantBad)
+			}
+		})
+	}
+}
+
+func TestPDF(t *testing.T) {
+	sheet := config.LabelTemplate{
+		PageWidth: 210, PageHeight: 297, Columns: 3, Rows: 8,
+		LabelWidth: 70, LabelHeight: 37, FontSize: 9,
+	}
+	list := []labels.Label{
+		{Symbology: labels.EAN, Code: "4006381333931", Title: "Мишка", Caption: "Игрушки"},
+		{Symbology: labels.Code128, Code: "SKU-001", Title: "Зайка"},
+		{Symbology: labels.QR, Code: "https://erp.example.com/machines/7", Title: "Автомат №7"},
+	}
+	var out bytes.Buffer
+	if err := labels.PDF(&out, sheet, list, 22); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(out.Bytes(), []byte("%PDF-")) {
+		t.Fatalf("PDF output starts with %q", out.Bytes()[:min(out.Len(), 8)])
+	}
+	// 22 пропущенных места: третья этикетка уходит на второй лист
+	if got := bytes.Count(out.Bytes(), []byte("/Type /Page\n")); got != 2 {
+		t.Errorf("pages = %d, want 2", got)
+	}
+
+	bad := []labels.Label{{Symbology: labels.Code128, Code: "МИШКА"}}
+	var codeErr *labels.CodeError
+	if err := labels.PDF(&out, sheet, bad, 0); !errors.As(err, &codeErr) {
+		t.Errorf("PDF with bad code = %v, want *CodeError", err)
+	}
+}
@@ -0,0 +1,65 @@
+package labels
+
+import (
+	"io"
+
+	"codeberg.org/go-pdf/fpdf"
+	"golang.org/x/image/font/gofont/gobold"
+	"golang.org/x/image/font/gofont/goregular"
+
+	"vend_erp/config"
+)
+
+// PDF печатает этикетки листами формата t; первые skip мест первого
+// листа остаются пустыми.
+func PDF(w io.Writer, t config.LabelTemplate, list []Label, skip int) error {
+	s, err := newSheet(t, list, skip)
+	if err != nil {
+		return err
+	}
+
+	doc := fpdf.NewCustom(&fpdf.InitType{
+		UnitStr: "mm",
+		Size:    fpdf.SizeType{Wd: t.PageWidth, Ht: t.PageHeight},
+	})
+	doc.SetMargins(0, 0, 0)
+	doc.SetAutoPageBreak(false, 0)
+	// Встроенные шрифты PDF не знают кириллицы
+	doc.AddUTF8FontFromBytes("go", "", goregular.TTF)
+	doc.AddUTF8FontFromBytes("go", "B", gobold.TTF)
+	doc.SetFillColor(0, 0, 0)
+	for page := 0; page < s.pages(); page++ {
+		doc.AddPage()
+		s.draw(pdfCanvas{doc}, page)
+	}
+	return doc.Output(w)
+}
+
+type pdfCanvas struct {
+	doc *fpdf.Fpdf
+}
+
+func (c pdfCanvas) fill(x, y, w, h float64) {
+	c.doc.Rect(x, y, w, h, "F")
+}
+
+func (c pdfCanvas) text(x, y float64, s string, size float64, bold bool) {
+	c.font(size, bold)
+	// Text ставит строку на базовую линию, а y — верх строки
+	c.doc.Text(x, y+size*25.4/72, s)
+}
+
+func (c pdfCanvas) width(s string, size float64, bold bool) float64 {
+	c.font(size, bold)
+	return c.doc.GetStringWidth(s)
+}
+
+func (c pdfCanvas) font(size float64, bold bool) {
+	style := ""
+	if bold {
+		style = "B"
+	}
+	c.doc.SetFont("go", style, size)
+}
+
+func (c pdfCanvas) dot() float64 { return 0 }
@@ -0,0 +1,123 @@
+package labels
+
+import (
+	"archive/zip"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"io"
+	"math"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/gobold"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/math/fixed"
+
+	"vend_erp/config"
+)
+
+// DPI — разрешение листов PNG.
+const DPI = 300
+
+var (
+	regular = mustParse(goregular.TTF)
+	bold    = mustParse(gobold.TTF)
+)
+
+func mustParse(ttf []byte) *opentype.Font {
+	f, err := opentype.Parse(ttf)
+	if err != nil {
+		panic(err)
+	}
+	return f
+}
+
+// PNG печатает этикетки картинкой с разрешением DPI. Если этикетки
+// не помещаются на один лист, пишется ZIP-архив с листами sheet-1.png,
+// sheet-2.png и так далее; см. Pages.
+func PNG(w io.Writer, t config.LabelTemplate, list []Label, skip int) error {
+	s, err := newSheet(t, list, skip)
+	if err != nil {
+		return err
+	}
+	if s.pages() <= 1 {
+		return png.Encode(w, s.image(0))
+	}
+
+	archive := zip.NewWriter(w)
+	for page := 0; page < s.pages(); page++ {
+		file, err := archive.Create(fmt.Sprintf("sheet-%d.png", page+1))
+		if err != nil {
+			return err
+		}
+		if err := png.Encode(file, s.image(page)); err != nil {
+			return err
+		}
+	}
+	return archive.Close()
+}
+
+func (s *sheet) image(page int) *image.Gray {
+	img := image.NewGray(image.Rect(0, 0, px(s.t.PageWidth), px(s.t.PageHeight)))
+	draw.Draw(img, img.Bounds(), image.White, image.Point{}, draw.Src)
+	s.draw(&pngCanvas{img: img, faces: map[faceKey]font.Face{}}, page)
+	return img
+}
+
+// px переводит миллиметры в точки PNG.
+func px(mm float64) int {
+	return int(math.Round(mm * DPI / 25.4))
+}
+
+type faceKey struct {
+	size float64
+	bold bool
+}
+
+type pngCanvas struct {
+	img   *image.Gray
+	faces map[faceKey]font.Face
+}
+
+func (c *pngCanvas) fill(x, y, w, h float64) {
+	rect := image.Rect(px(x), px(y), px(x+w), px(y+h))
+	draw.Draw(c.img, rect, image.Black, image.Point{}, draw.Src)
+}
+
+func (c *pngCanvas) text(x, y float64, s string, size float64, bold bool) {
+	face := c.face(size, bold)
+	d := font.Drawer{
+		Dst:  c.img,
+		Src:  image.NewUniform(color.Black),
+		Face: face,
+		Dot:  fixed.P(px(x), px(y)+face.Metrics().Ascent.Ceil()),
+	}
+	d.DrawString(s)
+}
+
+func (c *pngCanvas) width(s string, size float64, bold bool) float64 {
+	return float64(font.MeasureString(c.face(size, bold), s)) / 64 * 25.4 / DPI
+}
+
+func (c *pngCanvas) face(size float64, isBold bool) font.Face {
+	key := faceKey{size, isBold}
+	if face, ok := c.faces[key]; ok {
+		return face
+	}
+	f := regular
+	if isBold {
+		f = bold
+	}
+	face, err := opentype.NewFace(f, &opentype.FaceOptions{Size: size, DPI: DPI, Hinting: font.HintingFull})
+	if err != nil {
+		// Ошибку дает только неверный размер, а он проверяется в конфигурации
+		panic(err)
+	}
+	c.faces[key] = face
+	return face
+}
+
+func (c *pngCanvas) dot() float64 { return 25.4 / DPI }
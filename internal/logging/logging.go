@@ -0,0 +1,186 @@
+// Package logging настраивает log/slog и связывает записи с HTTP-запросом.
+// Middleware присваивает каждому запросу идентификатор (заголовок
+// X-Request-ID), пишет строку журнала доступа, а обработчик slog добавляет
+// request_id, user_id и trace_id ко всем записям, сделанным с контекстом
+// запроса:
+//
+//	slog.ErrorContext(r.Context(), "saving machine", "err", err)
+package logging
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"io"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Header — заголовок с идентификатором запроса. Входящее значение
+// (например, от балансировщика) сохраняется, если оно похоже на идентификатор.
+const Header = "X-Request-ID"
+
+var validID = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+// ParseLevel переводит debug, info, warn или error в уровень slog.
+func ParseLevel(level string) slog.Level {
+	switch strings.ToLower(level) {
+	case "debug":
+		return slog.LevelDebug
+	case "warn":
+		return slog.LevelWarn
+	case "error":
+		return slog.LevelError
+	}
+	return slog.LevelInfo
+}
+
+// Setup делает логгер по умолчанию для slog и стандартного пакета log.
+func Setup(w io.Writer, level string) *slog.Logger {
+	handler := slog.NewTextHandler(w, &slog.HandlerOptions{Level: ParseLevel(level)})
+	logger := slog.New(contextHandler{handler})
+	slog.SetDefault(logger)
+	return logger
+}
+
+type requestKey struct{}
+
+// requestInfo создается middleware; user_id заполняется позже, когда
+// проверка сессии узнает пользователя.
+type requestInfo struct {
+	id string
+
+	mu     sync.Mutex
+	userID int64
+}
+
+func infoFrom(ctx context.Context) *requestInfo {
+	info, _ := ctx.Value(requestKey{}).(*requestInfo)
+	return info
+}
+
+// RequestID возвращает идентификатор текущего запроса или пустую строку.
+func RequestID(ctx context.Context) string {
+	if info := infoFrom(ctx); info != nil {
+		return info.id
+	}
+	return ""
+}
+
+// SetUserID запоминает пользователя запроса для журнала доступа и записей slog.
+func SetUserID(ctx context.Context, userID int64) {
+	if info := infoFrom(ctx); info != nil {
+		info.mu.Lock()
+		info.userID = userID
+		info.mu.Unlock()
+	}
+}
+
+func (info *requestInfo) user() int64 {
+	info.mu.Lock()
+	defer info.mu.Unlock()
+	return info.userID
+}
+
+// contextHandler добавляет к записи поля запроса из контекста.
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h contextHandler) Handle(ctx context.Context, record slog.Record) error {
+	if info := infoFrom(ctx); info != nil {
+		record.AddAttrs(slog.String("request_id", info.id))
+		if userID := info.user(); userID != 0 {
+			record.AddAttrs(slog.Int64("user_id", userID))
+		}
+	}
+	// Идентификаторы трассы связывают запись журнала со спанами OpenTelemetry
+	if span := trace.SpanContextFromContext(ctx); span.IsValid() {
+		record.AddAttrs(
+			slog.String("trace_id", span.TraceID().String()),
+			slog.String("span_id", span.SpanID().String()),
+		)
+	}
+	return h.Handler.Handle(ctx, record)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
+
+// Middleware присваивает запросу идентификатор, возвращает его в заголовке
+// ответа и после обработки пишет строку журнала доступа.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+
+		id := r.Header.Get(Header)
+		if !validID.MatchString(id) {
+			id = newID()
+		}
+		info := &requestInfo{id: id}
+		ctx := context.WithValue(r.Context(), requestKey{}, info)
+		w.Header().Set(Header, id)
+
+		recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(recorder, r.WithContext(ctx))
+
+		level := slog.LevelInfo
+		if recorder.status >= http.StatusInternalServerError {
+			level = slog.LevelError
+		}
+		slog.LogAttrs(ctx, level, "request",
+			slog.String("method", r.Method),
+			slog.String("path", r.URL.Path),
+			slog.Int("status", recorder.status),
+			slog.Int64("bytes", recorder.bytes),
+			slog.Duration("latency", time.Since(start)),
+			slog.String("remote", r.RemoteAddr),
+		)
+	})
+}
+
+func newID() string {
+	buf := make([]byte, 8)
+	if _, err := rand.Read(buf); err != nil {
+		return "unknown"
+	}
+	return hex.EncodeToString(buf)
+}
+
+type statusRecorder struct {
+	http.ResponseWriter
+	status      int
+	bytes       int64
+	wroteHeader bool
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	if !r.wroteHeader {
+		r.status = status
+		r.wroteHeader = true
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *statusRecorder) Write(p []byte) (int, error) {
+	r.wroteHeader = true
+	n, err := r.ResponseWriter.Write(p)
+	r.bytes += int64(n)
+	return n, err
+}
+
+// Unwrap дает http.ResponseController доступ к исходному ResponseWriter.
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
@@ -0,0 +1,237 @@
+// Package metrics собирает метрики Prometheus: HTTP-запросы по маршрутам,
+// пул соединений с базой и бизнес-показатели (автоматы, наличные в них,
+// позиции с низким остатком). Бизнес-показатели считаются запросами к базе
+// в момент опроса /metrics, поэтому всегда актуальны. Они раскрывают данные
+// всех организаций, поэтому отдаются только по токену; без токена /metrics
+// содержит лишь метрики процесса, пула и HTTP.
+package metrics
+
+import (
+	"context"
+	"crypto/subtle"
+	"database/sql"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	"vend_erp/internal/money"
+)
+
+const namespace = "venderp"
+
+// scrapeTimeout ограничивает запросы бизнес-показателей при опросе.
+const scrapeTimeout = 5 * time.Second
+
+type Metrics struct {
+	registry *prometheus.Registry
+	// business — показатели по организациям, см. Handler
+	business *prometheus.Registry
+
+	requests *prometheus.CounterVec
+	duration *prometheus.HistogramVec
+}
+
+// New регистрирует стандартные метрики процесса и Go, пул соединений
+// и бизнес-показатели из db.
+func New(db *sql.DB, dbName string) *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		business: prometheus.NewRegistry(),
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "http_requests_total",
+			Help:      "HTTP requests by route pattern, method and status code.",
+		}, []string{"route", "method", "code"}),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "http_request_duration_seconds",
+			Help:      "HTTP request latency by route pattern and method.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"route", "method"}),
+	}
+
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		collectors.NewDBStatsCollector(db, dbName),
+		m.requests,
+		m.duration,
+	)
+	m.business.MustRegister(newBusinessCollector(db))
+	return m
+}
+
+// Handler отдает метрики в текстовом формате Prometheus. Пустой token
+// оставляет адрес открытым, но без бизнес-показателей. Иначе адрес требует
+// заголовок "Authorization: Bearer <token>" и отдает все метрики.
+func (m *Metrics) Handler(token string) http.Handler {
+	opts := promhttp.HandlerOpts{
+		ErrorLog: slog.NewLogLogger(slog.Default().Handler(), slog.LevelWarn),
+	}
+	if token == "" {
+		return promhttp.HandlerFor(m.registry, opts)
+	}
+
+	all := promhttp.HandlerFor(prometheus.Gatherers{m.registry, m.business}, opts)
+	want := []byte("Bearer " + token)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), want) != 1 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="metrics"`)
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		all.ServeHTTP(w, r)
+	})
+}
+
+// Middleware считает запросы и время ответа. Маршрут берется из шаблона
+// ServeMux (r.Pattern, например "/machines/save"), а не из пути, чтобы число
+// рядов не росло от идентификаторов и опечаток в адресах. Middleware должен
+// оборачивать непосредственно ServeMux: шаблон появляется в запросе после
+// того, как mux выбрал обработчик.
+func (m *Metrics) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(recorder, r)
+
+		route := r.Pattern
+		if route == "" {
+			route = "unmatched"
+		}
+		m.requests.WithLabelValues(route, r.Method, strconv.Itoa(recorder.status)).Inc()
+		m.duration.WithLabelValues(route, r.Method).Observe(time.Since(start).Seconds())
+	})
+}
+
+type statusRecorder struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	if !r.wroteHeader {
+		r.status = status
+		r.wroteHeader = true
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *statusRecorder) Write(p []byte) (int, error) {
+	r.wroteHeader = true
+	return r.ResponseWriter.Write(p)
+}
+
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+// businessCollector считает показатели по организациям при каждом опросе.
+type businessCollector struct {
+	db *sql.DB
+
+	machines   *prometheus.Desc
+	cash       *prometheus.Desc
+	lowStock   *prometheus.Desc
+	outOfStock *prometheus.Desc
+	up         *prometheus.Desc
+}
+
+func newBusinessCollector(db *sql.DB) *businessCollector {
+	return &businessCollector{
+		db: db,
+		machines: prometheus.NewDesc(namespace+"_machines",
+			"Vending machines by organization and status.", []string{"org", "status"}, nil),
+		cash: prometheus.NewDesc(namespace+"_machines_cash_rubles",
+			"Cash currently held in vending machines.", []string{"org"}, nil),
+		lowStock: prometheus.NewDesc(namespace+"_low_stock_items",
+			"Warehouse items below their minimum stock level but not empty.", []string{"org"}, nil),
+		outOfStock: prometheus.NewDesc(namespace+"_out_of_stock_items",
+			"Warehouse items with zero quantity.", []string{"org"}, nil),
+		up: prometheus.NewDesc(namespace+"_business_metrics_up",
+			"Whether the last business metrics query succeeded.", nil, nil),
+	}
+}
+
+func (c *businessCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.machines
+	ch <- c.cash
+	ch <- c.lowStock
+	ch <- c.outOfStock
+	ch <- c.up
+}
+
+func (c *businessCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), scrapeTimeout)
+	defer cancel()
+
+	up := 1.0
+	if err := c.collect(ctx, ch); err != nil {
+		slog.Warn("collecting business metrics", "err", err)
+		up = 0
+	}
+	ch <- prometheus.MustNewConstMetric(c.up, prometheus.GaugeValue, up)
+}
+
+func (c *businessCollector) collect(ctx context.Context, ch chan<- prometheus.Metric) error {
+	rows, err := c.db.QueryContext(ctx, `
+		SELECT o.slug, COALESCE(vm.status, 'unknown'), COUNT(*), COALESCE(SUM(vm.cash_amount), 0)
+		FROM vending_machines vm
+		JOIN organizations o ON o.id = vm.org_id
+		GROUP BY o.slug, vm.status
+	`)
+	if err != nil {
+		return err
+	}
+	cash := make(map[string]money.Amount)
+	for rows.Next() {
+		var org, status string
+		var count int
+		var amount money.Amount
+		if err := rows.Scan(&org, &status, &count, &amount); err != nil {
+			rows.Close()
+			return err
+		}
+		ch <- prometheus.MustNewConstMetric(c.machines, prometheus.GaugeValue, float64(count), org, status)
+		cash[org] += amount
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	for org, amount := range cash {
+		ch <- prometheus.MustNewConstMetric(c.cash, prometheus.GaugeValue, amount.Float64(), org)
+	}
+
+	// Те же условия, что и в карточках склада на дашборде
+	rows, err = c.db.QueryContext(ctx, `
+		SELECT o.slug,
+		       COUNT(*) FILTER (WHERE wi.quantity < wi.min_stock_level AND wi.quantity > 0),
+		       COUNT(*) FILTER (WHERE wi.quantity = 0)
+		FROM warehouse_inventory wi
+		JOIN warehouse w ON w.id = wi.warehouse_id
+		JOIN organizations o ON o.id = w.org_id
+		WHERE w.is_active = true
+		GROUP BY o.slug
+	`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var org string
+		var low, empty int
+		if err := rows.Scan(&org, &low, &empty); err != nil {
+			return err
+		}
+		ch <- prometheus.MustNewConstMetric(c.lowStock, prometheus.GaugeValue, float64(low), org)
+		ch <- prometheus.MustNewConstMetric(c.outOfStock, prometheus.GaugeValue, float64(empty), org)
+	}
+	return rows.Err()
+}
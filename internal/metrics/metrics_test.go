@@ -0,0 +1,62 @@
+package metrics_test
+
+import (
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	_ "github.com/jackc/pgx/v4/stdlib"
+
+	"vend_erp/internal/metrics"
+)
+
+func TestHandlerToken(t *testing.T) {
+	// База недоступна: бизнес-показатели отдаются с venderp_business_metrics_up 0
+	db, err := sql.Open("pgx", "postgres://metrics@127.0.0.1:1/metrics?connect_timeout=1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	stats := metrics.New(db, "metrics")
+
+	tests := []struct {
+		name         string
+		token        string
+		header       string
+		wantStatus   int
+		wantBusiness bool
+	}{
+		{name: "no token", wantStatus: http.StatusOK},
+		{name: "no token ignores header", header: "Bearer secret", wantStatus: http.StatusOK},
+		{name: "missing header", token: "secret", wantStatus: http.StatusUnauthorized},
+		{name: "wrong token", token: "secret", header: "Bearer other", wantStatus: http.StatusUnauthorized},
+		{name: "without scheme", token: "secret", header: "secret", wantStatus: http.StatusUnauthorized},
+		{name: "valid token", token: "secret", header: "Bearer secret", wantStatus: http.StatusOK, wantBusiness: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+			if tt.header != "" {
+				r.Header.Set("Authorization", tt.header)
+			}
+			w := httptest.NewRecorder()
+			stats.Handler(tt.token).ServeHTTP(w, r)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+			if w.Code != http.StatusOK {
+				return
+			}
+			body := w.Body.String()
+			if !strings.Contains(body, "go_sql_open_connections") {
+				t.Error("pool metrics missing")
+			}
+			if got := strings.Contains(body, "venderp_business_metrics_up"); got != tt.wantBusiness {
+				t.Errorf("business metrics present = %v, want %v", got, tt.wantBusiness)
+			}
+		})
+	}
+}
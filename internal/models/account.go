@@ -3,6 +3,15 @@ package models
 
 import "time"
 
+// Значения users.status
+const (
+    UserStatusInactive = 0
+    UserStatusActive   = 1
+    // UserStatusPending — регистрация ждет подтверждения администратором
+    UserStatusPending  = 2
+    UserStatusRejected = 3
+)
+
 type User struct {
     ID           int64     `json:"id"`
     Username     string    `json:"username"`
@@ -14,7 +23,21 @@ type User struct {
     CompanyName  string    `json:"companyname"`
     CompanyRole  string    `json:"companyrole"`
     Phone        string    `json:"phone"`
+    Team         string    `json:"team"`
+    MustChangePassword bool `json:"must_change_password"`
+    IsSuperAdmin bool      `json:"is_superadmin"`
+    OrgID        int64     `json:"org_id"`
+    OrgName      string    `json:"org_name"`
     CreatedAt    time.Time `json:"created_at"`
     UpdatedAt    time.Time `json:"updated_at"`
 }
 
+// Session — сессия входа. ActiveOrgID равен нулю, пока пользователь
+// не переключался из домашней организации.
+type Session struct {
+    ID          string    `json:"id"`
+    UserID      int64     `json:"user_id"`
+    ExpiresAt   time.Time `json:"expires_at"`
+    ActiveOrgID int64     `json:"active_org_id"`
+    AllOrgs     bool      `json:"all_orgs"`
+}
@@ -0,0 +1,24 @@
+package models
+
+import "time"
+
+// Invite — ссылка-приглашение с заранее назначенными ролью и командой.
+type Invite struct {
+    ID            int64      `json:"id" db:"id"`
+    Email         string     `json:"email" db:"email"`
+    UserRole      string     `json:"userrole" db:"userrole"`
+    Team          string     `json:"team" db:"team"`
+    OrgID         int64      `json:"org_id" db:"org_id"`
+    OrgName       string     `json:"org_name"`
+    InvitedBy     int64      `json:"invited_by" db:"invited_by"`
+    InvitedByName string     `json:"invited_by_name"`
+    ExpiresAt     time.Time  `json:"expires_at" db:"expires_at"`
+    UsedAt        *time.Time `json:"used_at" db:"used_at"`
+    RevokedAt     *time.Time `json:"revoked_at" db:"revoked_at"`
+    CreatedAt     time.Time  `json:"created_at" db:"created_at"`
+}
+
+// Usable сообщает, можно ли еще зарегистрироваться по приглашению.
+func (i Invite) Usable() bool {
+    return i.UsedAt == nil && i.RevokedAt == nil && time.Now().Before(i.ExpiresAt)
+}
@@ -2,22 +2,65 @@ package models
 
 import (
     "time"
+    "vend_erp/internal/money"
 )
 
 type VendingOperation struct {
-    ID               int64     `json:"id" db:"id"`
-    VendingMachineID int64     `json:"vending_machine_id" db:"vending_machine_id"`
-    MachineSerial    string    `json:"machine_serial" db:"machine_serial"` // Added for display
-    OperationType    string    `json:"operation_type" db:"operation_type"`
-    PerformedBy      int64     `json:"performed_by" db:"performed_by"`
-    PerformerName    string    `json:"performer_name" db:"performer_name"` // Added for display
-    OperationDate    time.Time `json:"operation_date" db:"operation_date"`
-    ToysBefore       int       `json:"toys_before" db:"toys_before"`
-    ToysAfter        int       `json:"toys_after" db:"toys_after"`
-    ToysAdded        int       `json:"toys_added" db:"toys_added"`
-    CashBefore       float64   `json:"cash_before" db:"cash_before"`
-    CashAfter        float64   `json:"cash_after" db:"cash_after"`
-    CashCollected    float64   `json:"cash_collected" db:"cash_collected"`
-    CreatedAt        time.Time `json:"created_at" db:"created_at"`
-    UpdatedAt        time.Time `json:"updated_at" db:"updated_at"`
+    ID               int64        `json:"id" db:"id"`
+    VendingMachineID int64        `json:"vending_machine_id" db:"vending_machine_id"`
+    MachineSerial    string       `json:"machine_serial" db:"machine_serial"` // Added for display
+    OperationType    string       `json:"operation_type" db:"operation_type"`
+    PerformedBy      int64        `json:"performed_by" db:"performed_by"`
+    PerformerName    string       `json:"performer_name" db:"performer_name"` // Added for display
+    OperationDate    time.Time    `json:"operation_date" db:"operation_date"`
+    ToysBefore       int          `json:"toys_before" db:"toys_before"`
+    ToysAfter        int          `json:"toys_after" db:"toys_after"`
+    ToysAdded        int          `json:"toys_added" db:"toys_added"`
+    CashBefore       money.Amount `json:"cash_before" db:"cash_before"`
+    CashAfter        money.Amount `json:"cash_after" db:"cash_after"`
+    CashCollected    money.Amount `json:"cash_collected" db:"cash_collected"`
+    // WarehouseID — склад, с которого выдано пополнение; 0 — пополнение
+    // не списывается со склада
+    WarehouseID      int64        `json:"warehouse_id" db:"warehouse_id"`
+    WarehouseName    string       `json:"warehouse_name" db:"warehouse_name"`
+    OrgID            int64        `json:"org_id" db:"org_id"`
+    OrgName          string       `json:"org_name" db:"org_name"`
+    CreatedAt        time.Time    `json:"created_at" db:"created_at"`
+    UpdatedAt        time.Time    `json:"updated_at" db:"updated_at"`
+    Version          int          `json:"version" db:"version"`
+    // Items — пополнение по товарам; List их не заполняет
+    Items            []OperationItem `json:"items,omitempty" db:"-"`
+}
+
+// OperationItem — товар в пополнении автомата.
+type OperationItem struct {
+    ID           int64        `json:"id"`
+    OperationID  int64        `json:"operation_id"`
+    ProductID    int64        `json:"product_id"`
+    CountBefore  int          `json:"count_before"` // было в автомате до пополнения
+    Added        int          `json:"added"`
+    PricePerPlay money.Amount `json:"price_per_play"` // цена игры на момент пополнения
+    
+    // Joined fields
+    ProductName  string       `json:"product_name"`
+    SKU          string       `json:"sku"`
+}
+
+// CountAfter — сколько товара в автомате после пополнения.
+func (i OperationItem) CountAfter() int {
+    return i.CountBefore + i.Added
+}
+
+// ProductSales — продажи товара на локации за период.
+type ProductSales struct {
+    LocationID   int64        `json:"location_id"`
+    LocationName string       `json:"location_name"`
+    OrgName      string       `json:"org_name"`
+    ProductID    int64        `json:"product_id"`
+    ProductName  string       `json:"product_name"`
+    SKU          string       `json:"sku"`
+    Sold         int          `json:"sold"`
+    // PlayValue — проданное по цене игры из выкладки: сколько стоят игры,
+    // в которых выдали игрушки. Это не выручка — игр без выигрыша в ней нет.
+    PlayValue    money.Amount `json:"play_value"`
 }
\ No newline at end of file
@@ -0,0 +1,13 @@
+package models
+
+import "time"
+
+type Organization struct {
+    ID            int64     `json:"id" db:"id"`
+    Name          string    `json:"name" db:"name"`
+    Slug          string    `json:"slug" db:"slug"`
+    IsActive      bool      `json:"is_active" db:"is_active"`
+    CostingMethod string    `json:"costing_method" db:"costing_method"` // fifo или average
+    CreatedAt     time.Time `json:"created_at" db:"created_at"`
+    UpdatedAt     time.Time `json:"updated_at" db:"updated_at"`
+}
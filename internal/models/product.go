@@ -0,0 +1,38 @@
+package models
+
+import (
+    "time"
+    "vend_erp/internal/money"
+)
+
+// Product — товар из справочника организации. Остатки товара на складах
+// хранятся в WarehouseInventory.
+type Product struct {
+    ID               int64        `json:"id"`
+    OrgID            int64        `json:"org_id"`
+    SKU              string       `json:"sku"`
+    Name             string       `json:"name"`
+    ItemType         string       `json:"item_type"` // vending_machine, toy, capsule
+    CategoryID       int64        `json:"category_id"`
+    Description      string       `json:"description"`
+    Barcode          string       `json:"barcode"`
+    PhotoURL         string       `json:"photo_url"`
+    DefaultCost      money.Amount `json:"default_cost"`  // закупочная цена
+    DefaultPrice     money.Amount `json:"default_price"` // цена продажи
+    SupplierName     string       `json:"supplier_name"`
+    // UnitVolume — сколько единиц вместимости склада занимает единица товара
+    UnitVolume       int          `json:"unit_volume"`
+    // MinOrderQuantity — минимальная партия заказа у поставщика (0 — без
+    // ограничения), PackSize — штук в упаковке: заказ кратен ей
+    MinOrderQuantity int          `json:"min_order_quantity"`
+    PackSize         int          `json:"pack_size"`
+    IsActive         bool         `json:"is_active"`
+    CreatedAt        time.Time    `json:"created_at"`
+    UpdatedAt        time.Time    `json:"updated_at"`
+    Version          int          `json:"version"`
+    
+    // Joined fields
+    CategoryName     string       `json:"category_name"`
+    OrgName          string       `json:"org_name"`
+    Stock            int          `json:"stock"` // остаток на всех складах
+}
@@ -0,0 +1,60 @@
+package models
+
+import (
+    "time"
+    "vend_erp/internal/money"
+)
+
+// Stocktake — инвентаризация склада: снимок остатков на момент открытия
+// и фактические количества, введенные счетчиками.
+type Stocktake struct {
+    ID          int64     `json:"id"`
+    WarehouseID int64     `json:"warehouse_id"`
+    Status      string    `json:"status"` // counting, approved, cancelled
+    Notes       string    `json:"notes"`
+    CreatedBy   int64     `json:"created_by"`
+    ApprovedBy  int64     `json:"approved_by"`
+    CreatedAt   time.Time `json:"created_at"`
+    ClosedAt    time.Time `json:"closed_at"` // проведена или отменена
+
+    // Joined fields
+    WarehouseName  string          `json:"warehouse_name"`
+    OrgID          int64           `json:"org_id"`
+    OrgName        string          `json:"org_name"`
+    CreatedByName  string          `json:"created_by_name"`
+    ApprovedByName string          `json:"approved_by_name"`
+    LineCount      int             `json:"line_count"`
+    CountedCount   int             `json:"counted_count"`
+    Lines          []StocktakeLine `json:"lines"`
+}
+
+// StocktakeLine — позиция склада в инвентаризации.
+type StocktakeLine struct {
+    ID               int64        `json:"id"`
+    StocktakeID      int64        `json:"stocktake_id"`
+    ItemID           int64        `json:"item_id"`
+    ExpectedQuantity int          `json:"expected_quantity"` // остаток в снимке
+    UnitCost         money.Amount `json:"unit_cost"`         // закупочная цена в снимке
+    Counted          bool         `json:"counted"`
+    CountedQuantity  int          `json:"counted_quantity"`
+    CountedBy        int64        `json:"counted_by"`
+    CountedAt        time.Time    `json:"counted_at"`
+
+    // Joined fields
+    ItemName         string       `json:"item_name"`
+    SKU              string       `json:"sku"`
+    Barcode          string       `json:"barcode"`
+}
+
+// Variance — расхождение факта со снимком: плюс — излишек, минус — недостача.
+func (l StocktakeLine) Variance() int {
+    if !l.Counted {
+        return 0
+    }
+    return l.CountedQuantity - l.ExpectedQuantity
+}
+
+// VarianceValue — стоимость расхождения по закупочной цене из снимка.
+func (l StocktakeLine) VarianceValue() money.Amount {
+    return l.UnitCost.Mul(l.Variance())
+}
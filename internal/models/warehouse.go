@@ -1,18 +1,49 @@
 package models
 
-import "time"
+import (
+    "time"
+    "vend_erp/internal/money"
+)
 
 type Warehouse struct {
-    ID            int64     `json:"id"`
-    Name          string    `json:"name"`
-    Address       string    `json:"address"`
-    ContactPerson string    `json:"contact_person"`
-    ContactPhone  string    `json:"contact_phone"`
-    TotalCapacity int       `json:"total_capacity"`
-    CurrentUsage  int       `json:"current_usage"`
-    IsActive      bool      `json:"is_active"`
-    CreatedAt     time.Time `json:"created_at"`
-    UpdatedAt     time.Time `json:"updated_at"`
+    ID             int64     `json:"id"`
+    Name           string    `json:"name"`
+    Address        string    `json:"address"`
+    ContactPerson  string    `json:"contact_person"`
+    ContactPhone   string    `json:"contact_phone"`
+    TotalCapacity  int       `json:"total_capacity"`
+    CurrentUsage   int       `json:"current_usage"`
+    // CapacityPolicy — что делать с переполнением: block или warn
+    CapacityPolicy string    `json:"capacity_policy"`
+    IsActive       bool      `json:"is_active"`
+    OrgID          int64     `json:"org_id"`
+    CreatedAt      time.Time `json:"created_at"`
+    UpdatedAt      time.Time `json:"updated_at"`
+    Version        int       `json:"version"`
+}
+
+// Free — свободная вместимость склада; у переполненного склада отрицательна.
+func (w Warehouse) Free() int {
+    return w.TotalCapacity - w.CurrentUsage
+}
+
+// WarehouseZone — зона хранения склада: стеллаж, ячейка или паллетное
+// место. Вместимость и загрузка — в единицах вместимости, как у склада.
+type WarehouseZone struct {
+    ID          int64     `json:"id"`
+    WarehouseID int64     `json:"warehouse_id"`
+    Name        string    `json:"name"`
+    Capacity    int       `json:"capacity"`
+    CreatedAt   time.Time `json:"created_at"`
+    
+    // Usage — сумма объемов позиций зоны, ItemCount — число позиций
+    Usage       int       `json:"usage"`
+    ItemCount   int       `json:"item_count"`
+}
+
+// Free — свободная вместимость зоны; у переполненной зоны отрицательна.
+func (z WarehouseZone) Free() int {
+    return z.Capacity - z.Usage
 }
 
 type WarehouseCategory struct {
@@ -22,49 +53,160 @@ type WarehouseCategory struct {
     CreatedAt   time.Time `json:"created_at"`
 }
 
+// WarehouseInventory — остаток товара на складе: одна позиция на пару
+// (склад, товар).
 type WarehouseInventory struct {
-    ID            int64     `json:"id"`
-    WarehouseID   int64     `json:"warehouse_id"`
-    CategoryID    int64     `json:"category_id"`
-    ItemType      string    `json:"item_type"` // vending_machine, toy, capsule
-    ItemName      string    `json:"item_name"`
-    Description   string    `json:"description"`
-    Quantity      int       `json:"quantity"`
-    MinStockLevel int       `json:"min_stock_level"`
-    MaxStockLevel int       `json:"max_stock_level"`
-    UnitPrice     float64   `json:"unit_price"`
-    SKU           string    `json:"sku"`
-    CreatedAt     time.Time `json:"created_at"`
-    UpdatedAt     time.Time `json:"updated_at"`
+    ID               int64        `json:"id"`
+    WarehouseID      int64        `json:"warehouse_id"`
+    ProductID        int64        `json:"product_id"`
+    Quantity         int          `json:"quantity"`
+    // Reserved — сколько из остатка отложено действующими резервами
+    Reserved         int          `json:"reserved"`
+    MinStockLevel    int          `json:"min_stock_level"`
+    MaxStockLevel    int          `json:"max_stock_level"`
+    ZoneID           int64        `json:"zone_id"` // 0 — позиция не размещена
+    CreatedAt        time.Time    `json:"created_at"`
+    UpdatedAt        time.Time    `json:"updated_at"`
+    Version          int          `json:"version"`
+    
+    // Поля товара из справочника
+    CategoryID       int64        `json:"category_id"`
+    ItemType         string       `json:"item_type"` // vending_machine, toy, capsule
+    ItemName         string       `json:"item_name"`
+    Description      string       `json:"description"`
+    UnitPrice        money.Amount `json:"unit_price"` // закупочная цена товара
+    SKU              string       `json:"sku"`
+    UnitVolume       int          `json:"unit_volume"`
+    
+    // StockValue — себестоимость остатка по партиям
+    StockValue       money.Amount `json:"stock_value"`
     
     // Joined fields
-    WarehouseName    string `json:"warehouse_name"`
-    WarehouseAddress string `json:"warehouse_address"`
-    CategoryName     string `json:"category_name"`
+    WarehouseName    string       `json:"warehouse_name"`
+    WarehouseAddress string       `json:"warehouse_address"`
+    CategoryName     string       `json:"category_name"`
+    ZoneName         string       `json:"zone_name"`
+    OrgID            int64        `json:"org_id"`
+    OrgName          string       `json:"org_name"`
+}
+
+// Volume — сколько единиц вместимости занимает остаток позиции.
+func (i WarehouseInventory) Volume() int {
+    return i.Quantity * i.UnitVolume
+}
+
+// Available — сколько товара можно отгрузить, переместить или
+// зарезервировать: остаток за вычетом резервов. Отрицателен, если
+// остаток уменьшили корректировкой ниже зарезервированного.
+func (i WarehouseInventory) Available() int {
+    return i.Quantity - i.Reserved
+}
+
+// AverageCost — средняя себестоимость единицы остатка; для пустого
+// остатка — закупочная цена товара.
+func (i WarehouseInventory) AverageCost() money.Amount {
+    if i.Quantity <= 0 {
+        return i.UnitPrice
+    }
+    return i.StockValue.Share(1, i.Quantity)
 }
 
+// StockMovement — строка журнала движений товара: изменение остатка
+// позиции и остаток после него.
+type StockMovement struct {
+    ID               int64        `json:"id"`
+    ItemID           int64        `json:"item_id"`
+    WarehouseID      int64        `json:"warehouse_id"`
+    Type             string       `json:"movement_type"` // opening, receipt, adjustment, transfer_out, transfer_in, shipment, restock
+    Quantity         int          `json:"quantity"`      // со знаком: приход положительный
+    BalanceAfter     int          `json:"balance_after"`
+    // Cost — стоимость движения со знаком: приход увеличивает стоимость
+    // остатка, расход уменьшает; для пополнения автомата -Cost —
+    // себестоимость выданного товара
+    Cost             money.Amount `json:"cost"`
+    TransferItemID   int64        `json:"transfer_item_id"`   // позиция на другом складе при перемещении
+    VendingMachineID int64        `json:"vending_machine_id"` // пополненный автомат
+    OperationID      int64        `json:"operation_id"`       // операция пополнения
+    Reason           string       `json:"reason"`
+    CreatedAt        time.Time    `json:"created_at"`
+    
+    // Joined fields
+    WarehouseName    string       `json:"warehouse_name"`
+    MachineSerial    string       `json:"machine_serial"`
+    ItemName         string       `json:"item_name"`
+    SKU              string       `json:"sku"`
+}
+
+// StockLot — партия товара на складе: приход по одной цене. Расход
+// списывает партии от старых к новым.
+type StockLot struct {
+    ID         int64        `json:"id"`
+    ItemID     int64        `json:"item_id"`
+    MovementID int64        `json:"movement_id"` // движение, которым пришла партия
+    UnitCost   money.Amount `json:"unit_cost"`
+    Quantity   int          `json:"quantity"`  // пришло
+    Remaining  int          `json:"remaining"` // осталось
+    ReceivedAt time.Time    `json:"received_at"`
+}
+
+// StockReservation — резерв товара позиции под запланированную отгрузку
+// или рейс пополнения автоматов. Резерв действует до ExpiresAt, пока его
+// не израсходует отгрузка или пополнение либо не снимут вручную.
+type StockReservation struct {
+    ID               int64     `json:"id"`
+    ItemID           int64     `json:"item_id"`
+    Type             string    `json:"reservation_type"` // shipment, restock
+    Quantity         int       `json:"quantity"`
+    VendingMachineID int64     `json:"vending_machine_id"` // 0 — любой автомат рейса
+    Reason           string    `json:"reason"`
+    Status           string    `json:"status"`      // active, consumed, released
+    MovementID       int64     `json:"movement_id"` // движение, израсходовавшее резерв
+    ExpiresAt        time.Time `json:"expires_at"`
+    CreatedBy        int64     `json:"created_by"`
+    CreatedAt        time.Time `json:"created_at"`
+    
+    // Joined fields
+    MachineSerial    string    `json:"machine_serial"`
+    CreatedByName    string    `json:"created_by_name"`
+}
+
+// WarehouseSupply — заказ поставщику. Черновики (draft) создает расчет
+// пополнения; после утверждения заказ получает статус ordered.
 type WarehouseSupply struct {
-    ID           int64     `json:"id"`
-    WarehouseID  int64     `json:"warehouse_id"`
-    SupplierName string    `json:"supplier_name"`
-    SupplyDate   time.Time `json:"supply_date"`
-    ExpectedDate time.Time `json:"expected_date"`
-    Status       string    `json:"status"` // ordered, in_transit, delivered, cancelled
-    TotalAmount  float64   `json:"total_amount"`
-    Notes        string    `json:"notes"`
-    CreatedAt    time.Time `json:"created_at"`
-    UpdatedAt    time.Time `json:"updated_at"`
+    ID           int64        `json:"id"`
+    WarehouseID  int64        `json:"warehouse_id"`
+    SupplierName string       `json:"supplier_name"`
+    SupplyDate   time.Time    `json:"supply_date"`
+    ExpectedDate time.Time    `json:"expected_date"`
+    Status       string       `json:"status"` // draft, ordered, in_transit, delivered, cancelled
+    TotalAmount  money.Amount `json:"total_amount"`
+    Notes        string       `json:"notes"`
+    ApprovedBy   int64        `json:"approved_by"`
+    ApprovedAt   time.Time    `json:"approved_at"`
+    CreatedAt    time.Time    `json:"created_at"`
+    UpdatedAt    time.Time    `json:"updated_at"`
+    
+    // Joined fields
+    WarehouseName  string       `json:"warehouse_name"`
+    OrgID          int64        `json:"org_id"`
+    OrgName        string       `json:"org_name"`
+    ApprovedByName string       `json:"approved_by_name"`
+    Items          []SupplyItem `json:"items"`
 }
 
 type SupplyItem struct {
-    ID               int64   `json:"id"`
-    SupplyID         int64   `json:"supply_id"`
-    InventoryItemID  int64   `json:"inventory_item_id"`
-    QuantityOrdered  int     `json:"quantity_ordered"`
-    QuantityReceived int     `json:"quantity_received"`
-    UnitPrice        float64 `json:"unit_price"`
-    TotalPrice       float64 `json:"total_price"`
-    CreatedAt        time.Time `json:"created_at"`
+    ID               int64        `json:"id"`
+    SupplyID         int64        `json:"supply_id"`
+    InventoryItemID  int64        `json:"inventory_item_id"`
+    QuantityOrdered  int          `json:"quantity_ordered"`
+    QuantityReceived int          `json:"quantity_received"`
+    UnitPrice        money.Amount `json:"unit_price"`
+    TotalPrice       money.Amount `json:"total_price"`
+    CreatedAt        time.Time    `json:"created_at"`
+    
+    // Joined fields
+    ItemName         string       `json:"item_name"`
+    SKU              string       `json:"sku"`
 }
 
 type WarehouseShipment struct {
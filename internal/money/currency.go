@@ -0,0 +1,70 @@
+package money
+
+import "strings"
+
+// Currency описывает, как показывать суммы в валюте.
+type Currency struct {
+	// Code — код ISO 4217
+	Code   string
+	Symbol string
+	// Разделители дробной части и групп разрядов
+	Decimal string
+	Group   string
+	// SymbolFirst ставит символ перед суммой: $1,234.50
+	SymbolFirst bool
+}
+
+var (
+	// RUB — валюта учета VendERP.
+	RUB = Currency{Code: "RUB", Symbol: "₽", Decimal: ",", Group: "\u00a0"}
+	USD = Currency{Code: "USD", Symbol: "$", Decimal: ".", Group: ",", SymbolFirst: true}
+	EUR = Currency{Code: "EUR", Symbol: "€", Decimal: ",", Group: "\u00a0"}
+)
+
+var currencies = map[string]Currency{
+	RUB.Code: RUB,
+	USD.Code: USD,
+	EUR.Code: EUR,
+}
+
+// Lookup возвращает валюту по коду ISO 4217; неизвестный код — RUB.
+func Lookup(code string) Currency {
+	if c, ok := currencies[strings.ToUpper(code)]; ok {
+		return c
+	}
+	return RUB
+}
+
+// Format возвращает сумму с символом валюты: "1 234,50 ₽".
+func (c Currency) Format(a Amount) string {
+	number := c.FormatNumber(a)
+	if c.SymbolFirst {
+		if strings.HasPrefix(number, "-") {
+			return "-" + c.Symbol + number[1:]
+		}
+		return c.Symbol + number
+	}
+	return number + "\u00a0" + c.Symbol
+}
+
+// FormatNumber возвращает сумму без символа валюты: "1 234,50".
+func (c Currency) FormatNumber(a Amount) string {
+	s := a.String()
+	sign := ""
+	if strings.HasPrefix(s, "-") {
+		sign, s = "-", s[1:]
+	}
+	whole, frac, _ := strings.Cut(s, ".")
+
+	var b strings.Builder
+	b.WriteString(sign)
+	for i, r := range whole {
+		if i > 0 && (len(whole)-i)%3 == 0 {
+			b.WriteString(c.Group)
+		}
+		b.WriteRune(r)
+	}
+	b.WriteString(c.Decimal)
+	b.WriteString(frac)
+	return b.String()
+}
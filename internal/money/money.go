@@ -0,0 +1,198 @@
+// Package money хранит денежные суммы в целых копейках. В базе суммы лежат
+// в DECIMAL(10,2), поэтому Amount читается из базы и записывается в нее
+// строкой с двумя знаками после точки без промежуточного float64: сложение
+// и умножение на количество остаются точными.
+package money
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Amount — сумма в копейках.
+type Amount int64
+
+// ErrInvalid — строка не является денежной суммой.
+var ErrInvalid = errors.New("money: invalid amount")
+
+// FromKopecks возвращает сумму из целого числа копеек.
+func FromKopecks(kopecks int64) Amount {
+	return Amount(kopecks)
+}
+
+// FromRubles возвращает сумму из целого числа рублей.
+func FromRubles(rubles int64) Amount {
+	return Amount(rubles * 100)
+}
+
+// Parse разбирает сумму из формы или из базы: "1234.5", "1 234,50", "-10".
+// Пустая строка — ноль. Знаки после второго округляются, как при записи
+// в DECIMAL(10,2).
+func Parse(s string) (Amount, error) {
+	s = strings.Map(func(r rune) rune {
+		switch r {
+		case ' ', '\u00a0', '\u202f':
+			return -1
+		case ',':
+			return '.'
+		}
+		return r
+	}, s)
+	if s == "" {
+		return 0, nil
+	}
+
+	negative := false
+	switch s[0] {
+	case '-':
+		negative = true
+		s = s[1:]
+	case '+':
+		s = s[1:]
+	}
+
+	whole, frac, _ := strings.Cut(s, ".")
+	if whole == "" && frac == "" || !digits(whole) || !digits(frac) || len(whole) > 15 {
+		return 0, fmt.Errorf("%w: %q", ErrInvalid, s)
+	}
+
+	var kopecks int64
+	if whole != "" {
+		kopecks, _ = strconv.ParseInt(whole, 10, 64)
+	}
+	kopecks *= 100
+	for i, weight := range []int64{10, 1} {
+		if i < len(frac) {
+			kopecks += int64(frac[i]-'0') * weight
+		}
+	}
+	if len(frac) > 2 && frac[2] >= '5' {
+		kopecks++
+	}
+
+	if negative {
+		kopecks = -kopecks
+	}
+	return Amount(kopecks), nil
+}
+
+func digits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// Kopecks возвращает сумму в копейках.
+func (a Amount) Kopecks() int64 {
+	return int64(a)
+}
+
+// Rubles возвращает целые рубли; копейки отбрасываются.
+func (a Amount) Rubles() int64 {
+	return int64(a) / 100
+}
+
+// Float64 нужен только для процентов на графиках и метрик — не для расчетов.
+func (a Amount) Float64() float64 {
+	return float64(a) / 100
+}
+
+// Mul возвращает стоимость n единиц по цене a.
+func (a Amount) Mul(n int) Amount {
+	return a * Amount(n)
+}
+
+// Share возвращает долю part/whole суммы a, округленную до копейки
+// (половина копейки — от нуля): стоимость part единиц из whole.
+func (a Amount) Share(part, whole int) Amount {
+	if whole == 0 {
+		return 0
+	}
+	n := int64(a) * int64(part)
+	d := int64(whole)
+	if (n < 0) != (d < 0) {
+		return Amount((n - d/2) / d)
+	}
+	return Amount((n + d/2) / d)
+}
+
+// Sum складывает суммы.
+func Sum(amounts ...Amount) Amount {
+	var total Amount
+	for _, a := range amounts {
+		total += a
+	}
+	return total
+}
+
+// String возвращает сумму в виде "1234.50": так она попадает в поля форм,
+// JSON и запросы к базе.
+func (a Amount) String() string {
+	sign := ""
+	kopecks := int64(a)
+	if kopecks < 0 {
+		sign = "-"
+		kopecks = -kopecks
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, kopecks/100, kopecks%100)
+}
+
+// Format форматирует сумму в рублях для отображения: "1 234,50 ₽".
+func (a Amount) Format() string {
+	return RUB.Format(a)
+}
+
+// Scan читает DECIMAL из базы. NULL считается нулем.
+func (a *Amount) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case nil:
+		*a = 0
+		return nil
+	case int64:
+		*a = FromRubles(v)
+		return nil
+	case float64:
+		*a = Amount(math.Round(v * 100))
+		return nil
+	case []byte:
+		return a.parse(string(v))
+	case string:
+		return a.parse(v)
+	}
+	return fmt.Errorf("money: cannot scan %T", src)
+}
+
+func (a *Amount) parse(s string) error {
+	parsed, err := Parse(s)
+	if err != nil {
+		return err
+	}
+	*a = parsed
+	return nil
+}
+
+// Value записывает сумму в базу строкой, чтобы DECIMAL получил ее без потерь.
+func (a Amount) Value() (driver.Value, error) {
+	return a.String(), nil
+}
+
+// MarshalJSON кодирует сумму числом с двумя знаками: 1234.50.
+func (a Amount) MarshalJSON() ([]byte, error) {
+	return []byte(a.String()), nil
+}
+
+// UnmarshalJSON принимает число или строку.
+func (a *Amount) UnmarshalJSON(data []byte) error {
+	s := strings.Trim(string(data), `"`)
+	if s == "null" {
+		return nil
+	}
+	return a.parse(s)
+}
@@ -0,0 +1,240 @@
+package money_test
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"vend_erp/internal/money"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    money.Amount
+		wantErr bool
+	}{
+		{in: "", want: 0},
+		{in: "0", want: 0},
+		{in: "10", want: 1000},
+		{in: "1234.5", want: 123450},
+		{in: "1234.50", want: 123450},
+		{in: ".5", want: 50},
+		{in: "5.", want: 500},
+		{in: "+7.25", want: 725},
+
+		// Запятая и разделители разрядов из форм
+		{in: "1234,5", want: 123450},
+		{in: "1 234,50", want: 123450},
+		{in: "1 234,50", want: 123450},
+		{in: "1 234,50", want: 123450},
+
+		{in: "-10", want: -1000},
+		{in: "-0,01", want: -1},
+		{in: "-1 234.56", want: -123456},
+
+		// Больше двух знаков округляется, половина копейки — от нуля
+		{in: "0.004", want: 0},
+		{in: "0.005", want: 1},
+		{in: "1.239", want: 124},
+		{in: "1.2345", want: 123},
+		{in: "9.999", want: 1000},
+		{in: "-0.005", want: -1},
+		{in: "-1.234", want: -123},
+
+		{in: "abc", wantErr: true},
+		{in: "-", wantErr: true},
+		{in: ".", wantErr: true},
+		{in: "1.2.3", wantErr: true},
+		{in: "1,2,3", wantErr: true},
+		{in: "--1", wantErr: true},
+		{in: "1e3", wantErr: true},
+		{in: "12a", wantErr: true},
+		{in: "1234567890123456", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := money.Parse(tt.in)
+		if tt.wantErr {
+			if !errors.Is(err, money.ErrInvalid) {
+				t.Errorf("Parse(%q) error = %v, want ErrInvalid", tt.in, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Parse(%q) error = %v", tt.in, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("Parse(%q) = %d, want %d", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestScan(t *testing.T) {
+	tests := []struct {
+		name    string
+		src     interface{}
+		want    money.Amount
+		wantErr bool
+	}{
+		{name: "nil", src: nil, want: 0},
+		{name: "int64", src: int64(15), want: 1500},
+		{name: "negative int64", src: int64(-3), want: -300},
+		{name: "float64", src: 12.34, want: 1234},
+		{name: "inexact float64", src: 0.1 + 0.2, want: 30},
+		{name: "negative float64", src: -2.5, want: -250},
+		{name: "bytes", src: []byte("1234.56"), want: 123456},
+		{name: "string", src: "99.90", want: 9990},
+		{name: "negative string", src: "-0.50", want: -50},
+		{name: "invalid bytes", src: []byte("n/a"), wantErr: true},
+		{name: "invalid string", src: "12,3.4", wantErr: true},
+		{name: "unsupported type", src: true, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Непустое начальное значение: NULL должен сбросить его в ноль
+			got := money.Amount(777)
+			err := got.Scan(tt.src)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("Scan(%v) = %d, want error", tt.src, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Scan(%v) error = %v", tt.src, err)
+			}
+			if got != tt.want {
+				t.Errorf("Scan(%v) = %d, want %d", tt.src, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	tests := []struct {
+		amount money.Amount
+		want   string
+	}{
+		{0, "0.00"},
+		{1, "0.01"},
+		{50, "0.50"},
+		{123456, "1234.56"},
+		{-1, "-0.01"},
+		{-123450, "-1234.50"},
+		{money.FromRubles(1_000_000_000), "1000000000.00"},
+	}
+	for _, tt := range tests {
+		value, err := tt.amount.Value()
+		if err != nil {
+			t.Fatalf("Value(%d) error = %v", tt.amount, err)
+		}
+		if value != tt.want {
+			t.Errorf("Value(%d) = %v, want %q", tt.amount, value, tt.want)
+		}
+		var scanned money.Amount
+		if err := scanned.Scan(value); err != nil || scanned != tt.amount {
+			t.Errorf("Scan(Value(%d)) = %d, %v", tt.amount, scanned, err)
+		}
+
+		data, err := json.Marshal(tt.amount)
+		if err != nil {
+			t.Fatalf("Marshal(%d) error = %v", tt.amount, err)
+		}
+		if string(data) != tt.want {
+			t.Errorf("Marshal(%d) = %s, want %s", tt.amount, data, tt.want)
+		}
+		var decoded money.Amount
+		if err := json.Unmarshal(data, &decoded); err != nil || decoded != tt.amount {
+			t.Errorf("Unmarshal(%s) = %d, %v", data, decoded, err)
+		}
+	}
+
+	// В структуре JSON сумма — число; строка и null тоже принимаются
+	var doc struct {
+		Price money.Amount  `json:"price"`
+		Cost  money.Amount  `json:"cost"`
+		Fee   *money.Amount `json:"fee"`
+	}
+	if err := json.Unmarshal([]byte(`{"price": 10.5, "cost": "7,25", "fee": null}`), &doc); err != nil {
+		t.Fatal(err)
+	}
+	if doc.Price != 1050 || doc.Cost != 725 || doc.Fee != nil {
+		t.Errorf("Unmarshal = %+v", doc)
+	}
+	data, err := json.Marshal(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"price":10.50,"cost":7.25,"fee":null}`; string(data) != want {
+		t.Errorf("Marshal = %s, want %s", data, want)
+	}
+}
+
+func TestShare(t *testing.T) {
+	tests := []struct {
+		amount      money.Amount
+		part, whole int
+		want        money.Amount
+	}{
+		{1000, 1, 4, 250},
+		{1000, 3, 3, 1000},
+		{1000, 0, 3, 0},
+		{1000, 5, 0, 0},
+		{100, 1, 3, 33},
+		{100, 2, 3, 67},
+		// Половина копейки округляется от нуля
+		{1, 1, 2, 1},
+		{-1, 1, 2, -1},
+		{-100, 2, 3, -67},
+		{100, -1, 3, -33},
+	}
+	for _, tt := range tests {
+		if got := tt.amount.Share(tt.part, tt.whole); got != tt.want {
+			t.Errorf("%d.Share(%d, %d) = %d, want %d", tt.amount, tt.part, tt.whole, got, tt.want)
+		}
+	}
+}
+
+// Списание по средней себестоимости: каждая партия уносит долю остатка,
+// и копейки округления остаются в последней, так что сумма списаний
+// равна стоимости запаса.
+func TestShareRemainder(t *testing.T) {
+	tests := []struct {
+		value  money.Amount
+		issues []int
+		want   []money.Amount
+	}{
+		{100, []int{1, 1, 1}, []money.Amount{33, 34, 33}},
+		{1000, []int{1, 2, 4}, []money.Amount{143, 286, 571}},
+		{1, []int{1, 1, 1}, []money.Amount{0, 1, 0}},
+		{99999, []int{6, 1}, []money.Amount{85713, 14286}},
+		{-100, []int{1, 2}, []money.Amount{-33, -67}},
+	}
+	for _, tt := range tests {
+		quantity := 0
+		for _, n := range tt.issues {
+			quantity += n
+		}
+		value := tt.value
+		var got []money.Amount
+		for _, n := range tt.issues {
+			cost := value.Share(n, quantity)
+			got = append(got, cost)
+			value -= cost
+			quantity -= n
+		}
+		if value != 0 {
+			t.Errorf("%d issued as %v: remaining value %d, want 0", tt.value, tt.issues, value)
+		}
+		if money.Sum(got...) != tt.value {
+			t.Errorf("%d issued as %v: total %d", tt.value, tt.issues, money.Sum(got...))
+		}
+		for i := range tt.want {
+			if i >= len(got) || got[i] != tt.want[i] {
+				t.Errorf("%d issued as %v = %v, want %v", tt.value, tt.issues, got, tt.want)
+				break
+			}
+		}
+	}
+}
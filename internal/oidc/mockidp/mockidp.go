@@ -0,0 +1,298 @@
+// Package mockidp — крошечный OpenID Connect провайдер для локальной
+// разработки и тестов. Он поддерживает discovery, authorization code flow
+// с PKCE (S256) и подписывает ID token ключом RSA, созданным при запуске.
+// Аутентификации нет: пользователь выбирается на странице /authorize.
+package mockidp
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const keyID = "mockidp-key"
+
+// User — учётная запись, от имени которой mock IdP выпускает токены.
+type User struct {
+	Subject  string
+	Email    string
+	Name     string
+	Username string
+	Groups   []string
+	// OmitEmailVerified убирает claim email_verified из ID token,
+	// как у провайдеров, которые не проверяют email.
+	OmitEmailVerified bool
+}
+
+type authCode struct {
+	user          User
+	clientID      string
+	redirectURI   string
+	nonce         string
+	challenge     string
+	challengeType string
+	expiresAt     time.Time
+}
+
+// Server реализует http.Handler. Issuer должен совпадать с внешним адресом
+// сервера, так как он попадает в discovery и в claim iss.
+type Server struct {
+	Issuer   string
+	ClientID string
+	Users    []User
+
+	key   *rsa.PrivateKey
+	mu    sync.Mutex
+	codes map[string]authCode
+	mux   *http.ServeMux
+}
+
+func New(issuer, clientID string, users ...User) (*Server, error) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, err
+	}
+	s := &Server{
+		Issuer:   strings.TrimSuffix(issuer, "/"),
+		ClientID: clientID,
+		Users:    users,
+		key:      key,
+		codes:    make(map[string]authCode),
+		mux:      http.NewServeMux(),
+	}
+	s.mux.HandleFunc("/.well-known/openid-configuration", s.discovery)
+	s.mux.HandleFunc("/authorize", s.authorize)
+	s.mux.HandleFunc("/token", s.token)
+	s.mux.HandleFunc("/jwks", s.jwks)
+	return s, nil
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+func (s *Server) discovery(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"issuer":                                s.Issuer,
+		"authorization_endpoint":                s.Issuer + "/authorize",
+		"token_endpoint":                        s.Issuer + "/token",
+		"jwks_uri":                              s.Issuer + "/jwks",
+		"response_types_supported":              []string{"code"},
+		"subject_types_supported":               []string{"public"},
+		"id_token_signing_alg_values_supported": []string{"RS256"},
+		"code_challenge_methods_supported":      []string{"S256", "plain"},
+		"scopes_supported":                      []string{"openid", "email", "profile", "groups"},
+	})
+}
+
+var chooserTmpl = template.Must(template.New("chooser").Parse(`<!DOCTYPE html>
+<html><head><meta charset="UTF-8"><title>Mock IdP</title></head>
+<body style="font-family: sans-serif; max-width: 480px; margin: 3rem auto;">
+<h2>Mock IdP: выберите пользователя</h2>
+<ul>{{range .Users}}
+<li><a href="{{$.Base}}&login_hint={{.Email}}">{{.Email}}</a> {{.Groups}}</li>
+{{end}}</ul>
+</body></html>`))
+
+func (s *Server) authorize(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	if q.Get("response_type") != "code" {
+		http.Error(w, "unsupported response_type", http.StatusBadRequest)
+		return
+	}
+	if s.ClientID != "" && q.Get("client_id") != s.ClientID {
+		http.Error(w, "unknown client_id", http.StatusBadRequest)
+		return
+	}
+	redirectURI, err := url.Parse(q.Get("redirect_uri"))
+	if err != nil || redirectURI.String() == "" {
+		http.Error(w, "invalid redirect_uri", http.StatusBadRequest)
+		return
+	}
+
+	user, ok := s.findUser(q.Get("login_hint"))
+	if !ok {
+		if len(s.Users) == 1 {
+			user = s.Users[0]
+		} else {
+			base := *r.URL
+			values := base.Query()
+			values.Del("login_hint")
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			chooserTmpl.Execute(w, map[string]interface{}{
+				"Users": s.Users,
+				"Base":  template.URL("/authorize?" + values.Encode()),
+			})
+			return
+		}
+	}
+
+	code := randomString()
+	s.mu.Lock()
+	s.codes[code] = authCode{
+		user:          user,
+		clientID:      q.Get("client_id"),
+		redirectURI:   redirectURI.String(),
+		nonce:         q.Get("nonce"),
+		challenge:     q.Get("code_challenge"),
+		challengeType: q.Get("code_challenge_method"),
+		expiresAt:     time.Now().Add(time.Minute),
+	}
+	s.mu.Unlock()
+
+	values := redirectURI.Query()
+	values.Set("code", code)
+	values.Set("state", q.Get("state"))
+	redirectURI.RawQuery = values.Encode()
+	http.Redirect(w, r, redirectURI.String(), http.StatusFound)
+}
+
+func (s *Server) token(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		tokenError(w, "invalid_request")
+		return
+	}
+	if r.FormValue("grant_type") != "authorization_code" {
+		tokenError(w, "unsupported_grant_type")
+		return
+	}
+
+	s.mu.Lock()
+	code, ok := s.codes[r.FormValue("code")]
+	delete(s.codes, r.FormValue("code"))
+	s.mu.Unlock()
+
+	if !ok || time.Now().After(code.expiresAt) || code.redirectURI != r.FormValue("redirect_uri") {
+		tokenError(w, "invalid_grant")
+		return
+	}
+
+	clientID := r.FormValue("client_id")
+	if basicID, _, hasBasic := r.BasicAuth(); hasBasic {
+		clientID = basicID
+	}
+	if clientID != code.clientID {
+		tokenError(w, "invalid_client")
+		return
+	}
+
+	if !verifyPKCE(code.challenge, code.challengeType, r.FormValue("code_verifier")) {
+		tokenError(w, "invalid_grant")
+		return
+	}
+
+	idToken, err := s.signIDToken(code)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"access_token": randomString(),
+		"token_type":   "Bearer",
+		"expires_in":   3600,
+		"id_token":     idToken,
+	})
+}
+
+func (s *Server) jwks(w http.ResponseWriter, r *http.Request) {
+	pub := s.key.PublicKey
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"keys": []map[string]string{{
+			"kty": "RSA",
+			"use": "sig",
+			"alg": "RS256",
+			"kid": keyID,
+			"n":   base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+		}},
+	})
+}
+
+func (s *Server) signIDToken(code authCode) (string, error) {
+	now := time.Now()
+	claims := map[string]interface{}{
+		"iss":                s.Issuer,
+		"sub":                code.user.Subject,
+		"aud":                code.clientID,
+		"iat":                now.Unix(),
+		"exp":                now.Add(time.Hour).Unix(),
+		"email":              code.user.Email,
+		"email_verified":     true,
+		"name":               code.user.Name,
+		"preferred_username": code.user.Username,
+		"groups":             code.user.Groups,
+	}
+	if code.nonce != "" {
+		claims["nonce"] = code.nonce
+	}
+	if code.user.OmitEmailVerified {
+		delete(claims, "email_verified")
+	}
+
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT", "kid": keyID})
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+
+	signingInput := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(signingInput))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, s.key, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", err
+	}
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+func (s *Server) findUser(email string) (User, bool) {
+	for _, user := range s.Users {
+		if email != "" && strings.EqualFold(user.Email, email) {
+			return user, true
+		}
+	}
+	return User{}, false
+}
+
+func verifyPKCE(challenge, method, verifier string) bool {
+	if challenge == "" {
+		return true
+	}
+	switch method {
+	case "S256":
+		sum := sha256.Sum256([]byte(verifier))
+		return base64.RawURLEncoding.EncodeToString(sum[:]) == challenge
+	case "", "plain":
+		return verifier == challenge
+	}
+	return false
+}
+
+func tokenError(w http.ResponseWriter, code string) {
+	writeJSON(w, http.StatusBadRequest, map[string]string{"error": code})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func randomString() string {
+	bytes := make([]byte, 24)
+	if _, err := rand.Read(bytes); err != nil {
+		panic(fmt.Sprintf("mockidp: %v", err))
+	}
+	return base64.RawURLEncoding.EncodeToString(bytes)
+}
@@ -0,0 +1,211 @@
+// Package oidc реализует вход через внешний провайдер OpenID Connect
+// по схеме authorization code flow с PKCE.
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	gooidc "github.com/coreos/go-oidc/v3/oidc"
+	"golang.org/x/oauth2"
+
+	"vend_erp/config"
+)
+
+// Identity — проверенные данные пользователя из ID token.
+type Identity struct {
+	Issuer        string
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+	Username      string
+	Groups        []string
+}
+
+// Flow хранит одноразовые значения между редиректом на IdP и callback.
+type Flow struct {
+	State    string
+	Nonce    string
+	Verifier string
+}
+
+// Client оборачивает discovery, обмен кода и проверку ID token.
+// Discovery выполняется лениво при первом входе, чтобы недоступный IdP
+// не мешал запуску сервера.
+type Client struct {
+	cfg config.OIDCConfig
+
+	mu       sync.Mutex
+	provider *gooidc.Provider
+	verifier *gooidc.IDTokenVerifier
+	oauth    *oauth2.Config
+}
+
+func NewClient(cfg config.OIDCConfig) *Client {
+	return &Client{cfg: cfg}
+}
+
+func (c *Client) ProviderName() string {
+	return c.cfg.ProviderName
+}
+
+func (c *Client) init(ctx context.Context) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.provider != nil {
+		return nil
+	}
+
+	provider, err := gooidc.NewProvider(ctx, c.cfg.Issuer)
+	if err != nil {
+		return fmt.Errorf("oidc discovery failed: %w", err)
+	}
+
+	c.provider = provider
+	c.verifier = provider.Verifier(&gooidc.Config{ClientID: c.cfg.ClientID})
+	c.oauth = &oauth2.Config{
+		ClientID:     c.cfg.ClientID,
+		ClientSecret: c.cfg.ClientSecret,
+		RedirectURL:  c.cfg.RedirectURL,
+		Endpoint:     provider.Endpoint(),
+		Scopes:       c.cfg.Scopes,
+	}
+	return nil
+}
+
+// NewFlow генерирует state, nonce и PKCE verifier для нового входа.
+func NewFlow() (Flow, error) {
+	state, err := randomToken()
+	if err != nil {
+		return Flow{}, err
+	}
+	nonce, err := randomToken()
+	if err != nil {
+		return Flow{}, err
+	}
+	return Flow{State: state, Nonce: nonce, Verifier: oauth2.GenerateVerifier()}, nil
+}
+
+// AuthCodeURL возвращает адрес страницы входа IdP для данного flow.
+func (c *Client) AuthCodeURL(ctx context.Context, flow Flow) (string, error) {
+	if err := c.init(ctx); err != nil {
+		return "", err
+	}
+	return c.oauth.AuthCodeURL(flow.State,
+		gooidc.Nonce(flow.Nonce),
+		oauth2.S256ChallengeOption(flow.Verifier),
+	), nil
+}
+
+// Exchange обменивает код авторизации на токены и проверяет ID token.
+func (c *Client) Exchange(ctx context.Context, code string, flow Flow) (*Identity, error) {
+	if err := c.init(ctx); err != nil {
+		return nil, err
+	}
+
+	token, err := c.oauth.Exchange(ctx, code, oauth2.VerifierOption(flow.Verifier))
+	if err != nil {
+		return nil, fmt.Errorf("code exchange failed: %w", err)
+	}
+
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok || rawIDToken == "" {
+		return nil, errors.New("token response has no id_token")
+	}
+
+	idToken, err := c.verifier.Verify(ctx, rawIDToken)
+	if err != nil {
+		return nil, fmt.Errorf("id_token verification failed: %w", err)
+	}
+	if idToken.Nonce != flow.Nonce {
+		return nil, errors.New("id_token nonce mismatch")
+	}
+
+	var claims map[string]interface{}
+	if err := idToken.Claims(&claims); err != nil {
+		return nil, fmt.Errorf("cannot decode id_token claims: %w", err)
+	}
+
+	// Без claim email_verified адрес считается неподтвержденным
+	identity := &Identity{
+		Issuer:        idToken.Issuer,
+		Subject:       idToken.Subject,
+		Email:         strings.ToLower(stringClaim(claims, "email")),
+		EmailVerified: boolClaim(claims, "email_verified"),
+		Name:          stringClaim(claims, "name"),
+		Username:      stringClaim(claims, "preferred_username"),
+		Groups:        listClaim(claims, c.cfg.GroupsClaim),
+	}
+	if identity.Email == "" {
+		return nil, errors.New("id_token has no email claim")
+	}
+
+	return identity, nil
+}
+
+// MapRole выбирает роль по группам IdP согласно настройкам RoleMapping.
+// Второе значение false, если ни одна группа не сопоставлена.
+func (c *Client) MapRole(groups []string) (string, bool) {
+	member := make(map[string]bool, len(groups))
+	for _, group := range groups {
+		member[group] = true
+	}
+	for _, mapping := range c.cfg.RoleMapping {
+		if member[mapping.Group] {
+			return mapping.Role, true
+		}
+	}
+	return c.cfg.DefaultRole, false
+}
+
+func (c *Client) AutoProvision() bool {
+	return c.cfg.AutoProvision
+}
+
+// LinkByEmail сообщает, можно ли связать вход с существующей учетной
+// записью по email: только для доверенного IdP и подтвержденного адреса.
+func (c *Client) LinkByEmail(identity *Identity) bool {
+	return c.cfg.TrustEmail && identity.EmailVerified
+}
+
+func stringClaim(claims map[string]interface{}, name string) string {
+	value, _ := claims[name].(string)
+	return value
+}
+
+func boolClaim(claims map[string]interface{}, name string) bool {
+	value, _ := claims[name].(bool)
+	return value
+}
+
+// listClaim принимает как массив строк, так и одну строку через пробел или запятую.
+func listClaim(claims map[string]interface{}, name string) []string {
+	switch value := claims[name].(type) {
+	case []interface{}:
+		var list []string
+		for _, item := range value {
+			if s, ok := item.(string); ok {
+				list = append(list, s)
+			}
+		}
+		return list
+	case string:
+		return strings.FieldsFunc(value, func(r rune) bool { return r == ',' || r == ' ' })
+	}
+	return nil
+}
+
+func randomToken() (string, error) {
+	bytes := make([]byte, 32)
+	if _, err := rand.Read(bytes); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(bytes), nil
+}
@@ -0,0 +1,162 @@
+package oidc_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"vend_erp/config"
+	"vend_erp/internal/oidc"
+	"vend_erp/internal/oidc/mockidp"
+)
+
+const redirectURL = "http://localhost:8080/auth/oidc/callback"
+
+// newIdP запускает mock IdP и возвращает клиента, настроенного на него.
+func newIdP(t *testing.T, trustEmail bool, users ...mockidp.User) *oidc.Client {
+	t.Helper()
+	var idp *mockidp.Server
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		idp.ServeHTTP(w, r)
+	}))
+	t.Cleanup(srv.Close)
+
+	var err error
+	idp, err = mockidp.New(srv.URL, "venderp", users...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return oidc.NewClient(config.OIDCConfig{
+		Issuer:      srv.URL,
+		ClientID:    "venderp",
+		RedirectURL: redirectURL,
+		Scopes:      []string{"openid", "profile", "email", "groups"},
+		GroupsClaim: "groups",
+		TrustEmail:  trustEmail,
+	})
+}
+
+// login проходит страницу входа IdP за пользователя email и возвращает
+// код из редиректа на callback.
+func login(t *testing.T, client *oidc.Client, flow oidc.Flow, email string) string {
+	t.Helper()
+	authURL, err := client.AuthCodeURL(context.Background(), flow)
+	if err != nil {
+		t.Fatal(err)
+	}
+	browser := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+	resp, err := browser.Get(authURL + "&login_hint=" + url.QueryEscape(email))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusFound {
+		t.Fatalf("authorize status = %d, want %d", resp.StatusCode, http.StatusFound)
+	}
+	location, err := url.Parse(resp.Header.Get("Location"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := location.Query().Get("state"); got != flow.State {
+		t.Fatalf("state = %q, want %q", got, flow.State)
+	}
+	return location.Query().Get("code")
+}
+
+func newFlow(t *testing.T) oidc.Flow {
+	t.Helper()
+	flow, err := oidc.NewFlow()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return flow
+}
+
+func TestExchange(t *testing.T) {
+	ctx := context.Background()
+	verified := mockidp.User{
+		Subject: "sub-1", Email: "SSO.Admin@TestSystem.ru", Name: "SSO Администратор",
+		Username: "sso.admin", Groups: []string{"vend-admins"},
+	}
+	unverified := mockidp.User{Subject: "sub-2", Email: "sso.guest@testsystem.ru", OmitEmailVerified: true}
+	client := newIdP(t, true, verified, unverified)
+
+	t.Run("Verified", func(t *testing.T) {
+		flow := newFlow(t)
+		identity, err := client.Exchange(ctx, login(t, client, flow, verified.Email), flow)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if identity.Subject != "sub-1" || identity.Email != "sso.admin@testsystem.ru" || identity.Username != "sso.admin" {
+			t.Errorf("identity = %+v", identity)
+		}
+		if !identity.EmailVerified {
+			t.Error("EmailVerified = false, want true")
+		}
+		if len(identity.Groups) != 1 || identity.Groups[0] != "vend-admins" {
+			t.Errorf("Groups = %v, want [vend-admins]", identity.Groups)
+		}
+		if !client.LinkByEmail(identity) {
+			t.Error("LinkByEmail = false for a trusted IdP and a verified email")
+		}
+	})
+
+	t.Run("MissingEmailVerified", func(t *testing.T) {
+		flow := newFlow(t)
+		identity, err := client.Exchange(ctx, login(t, client, flow, unverified.Email), flow)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if identity.EmailVerified {
+			t.Error("EmailVerified = true without the email_verified claim")
+		}
+		if client.LinkByEmail(identity) {
+			t.Error("LinkByEmail = true for an unverified email")
+		}
+	})
+
+	t.Run("Untrusted", func(t *testing.T) {
+		untrusted := newIdP(t, false, verified)
+		flow := newFlow(t)
+		identity, err := untrusted.Exchange(ctx, login(t, untrusted, flow, verified.Email), flow)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if untrusted.LinkByEmail(identity) {
+			t.Error("LinkByEmail = true for an untrusted IdP")
+		}
+	})
+
+	t.Run("Nonce", func(t *testing.T) {
+		flow := newFlow(t)
+		code := login(t, client, flow, verified.Email)
+		flow.Nonce = "forged"
+		if _, err := client.Exchange(ctx, code, flow); err == nil {
+			t.Error("Exchange accepted an id_token with a foreign nonce")
+		}
+	})
+
+	t.Run("Verifier", func(t *testing.T) {
+		flow := newFlow(t)
+		code := login(t, client, flow, verified.Email)
+		flow.Verifier = newFlow(t).Verifier
+		if _, err := client.Exchange(ctx, code, flow); err == nil {
+			t.Error("Exchange accepted a code without the matching PKCE verifier")
+		}
+	})
+
+	t.Run("CodeReuse", func(t *testing.T) {
+		flow := newFlow(t)
+		code := login(t, client, flow, verified.Email)
+		if _, err := client.Exchange(ctx, code, flow); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := client.Exchange(ctx, code, flow); err == nil {
+			t.Error("Exchange accepted a used code")
+		}
+	})
+}
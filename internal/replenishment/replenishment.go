@@ -0,0 +1,182 @@
+// Package replenishment рассчитывает пополнение складов и готовит
+// черновики заказов поставщикам.
+//
+// Для каждой позиции склада расход за день оценивается по пополнениям
+// автоматов за последние Window. Позиция заказывается, когда остаток
+// вместе с уже заказанным (черновики и незакрытые заказы) за вычетом
+// расхода на время поставки LeadTime опускается до минимального уровня.
+// Заказ доводит этот прогноз до максимального уровня:
+//
+//	прогноз = остаток + заказано − ⌈расход за Window × LeadTime / Window⌉
+//	если прогноз <= минимум: заказать максимум − прогноз
+//
+// Заказ не меньше минимальной партии товара и округляется вверх до целых
+// упаковок, поэтому может превысить максимум.
+//
+// Позиции группируются по складу и поставщику товара: один черновик
+// на пару (склад, поставщик). Созданные черновики сами считаются
+// заказанным количеством, поэтому повторный расчет их не дублирует.
+//
+// Расчет выполняется под блокировкой Supplies.LockReplenishment, общей
+// для всех экземпляров приложения: расписание может работать на каждом
+// из них, а одновременный запуск пропускается.
+package replenishment
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"sort"
+	"time"
+
+	"vend_erp/config"
+	"vend_erp/internal/models"
+	"vend_erp/internal/repository"
+)
+
+// NoSupplier — поставщик черновика для товаров, у которых он не указан.
+const NoSupplier = "Поставщик не указан"
+
+// Engine создает черновики заказов поставщикам.
+type Engine struct {
+	supplies repository.Supplies
+	cfg      config.ReplenishmentConfig
+	now      func() time.Time
+}
+
+func New(supplies repository.Supplies, cfg config.ReplenishmentConfig) *Engine {
+	return &Engine{supplies: supplies, cfg: cfg, now: time.Now}
+}
+
+// Run рассчитывает пополнение складов области и сохраняет черновики.
+// Возвращает созданные черновики. Если расчет уже выполняется здесь или
+// на другом экземпляре, возвращает ошибку с repository.ErrLocked: иначе
+// оба создали бы черновики на одни и те же позиции.
+func (e *Engine) Run(ctx context.Context, scope repository.Scope) ([]models.WarehouseSupply, error) {
+	unlock, err := e.supplies.LockReplenishment(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("locking replenishment: %w", err)
+	}
+	defer unlock()
+
+	now := e.now()
+	lines, err := e.supplies.ReorderLines(ctx, scope, now.Add(-e.cfg.Window))
+	if err != nil {
+		return nil, fmt.Errorf("reading stock for replenishment: %w", err)
+	}
+	drafts := Plan(lines, e.cfg, now)
+	for i := range drafts {
+		if err := e.supplies.Create(ctx, &drafts[i]); err != nil {
+			return drafts[:i], fmt.Errorf("creating draft supply for warehouse %d: %w", drafts[i].WarehouseID, err)
+		}
+	}
+	return drafts, nil
+}
+
+// Schedule запускает Run по всем организациям каждые cfg.Interval,
+// пока не отменен ctx. Первый расчет выполняется сразу; если в это время
+// расчет выполняет другой экземпляр, запуск пропускается.
+func (e *Engine) Schedule(ctx context.Context) {
+	ticker := time.NewTicker(e.cfg.Interval)
+	defer ticker.Stop()
+	for {
+		drafts, err := e.Run(ctx, repository.Scope{AllOrgs: true})
+		switch {
+		case errors.Is(err, repository.ErrLocked):
+			slog.Info("replenishment skipped: already running")
+		case err != nil && ctx.Err() == nil:
+			slog.Error("replenishment failed", "err", err, "created", len(drafts))
+		case len(drafts) > 0:
+			slog.Info("replenishment drafts created", "count", len(drafts))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Plan рассчитывает черновики заказов по позициям lines на момент now.
+// Черновики упорядочены по складу и поставщику, позиции — как в lines.
+func Plan(lines []repository.ReorderLine, cfg config.ReplenishmentConfig, now time.Time) []models.WarehouseSupply {
+	type key struct {
+		warehouseID int64
+		supplier    string
+	}
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	expected := today.Add(cfg.LeadTime)
+	notes := fmt.Sprintf("Сформирован автоматически: расход за %d дн., поставка за %d дн.",
+		days(cfg.Window), days(cfg.LeadTime))
+
+	drafts := make(map[key]*models.WarehouseSupply)
+	for _, line := range lines {
+		quantity := Reorder(line, cfg)
+		if quantity <= 0 {
+			continue
+		}
+		supplier := line.SupplierName
+		if supplier == "" {
+			supplier = NoSupplier
+		}
+		k := key{line.WarehouseID, supplier}
+		draft, ok := drafts[k]
+		if !ok {
+			draft = &models.WarehouseSupply{
+				WarehouseID:  line.WarehouseID,
+				SupplierName: supplier,
+				SupplyDate:   today,
+				ExpectedDate: expected,
+				Status:       repository.SupplyDraft,
+				Notes:        notes,
+			}
+			drafts[k] = draft
+		}
+		draft.Items = append(draft.Items, models.SupplyItem{
+			InventoryItemID: line.ItemID,
+			QuantityOrdered: quantity,
+			UnitPrice:       line.UnitCost,
+		})
+		draft.TotalAmount += line.UnitCost.Mul(quantity)
+	}
+
+	list := make([]models.WarehouseSupply, 0, len(drafts))
+	for _, draft := range drafts {
+		list = append(list, *draft)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].WarehouseID != list[j].WarehouseID {
+			return list[i].WarehouseID < list[j].WarehouseID
+		}
+		return list[i].SupplierName < list[j].SupplierName
+	})
+	return list
+}
+
+// Reorder возвращает, сколько единиц позиции заказать; ноль — заказ не нужен.
+func Reorder(line repository.ReorderLine, cfg config.ReplenishmentConfig) int {
+	if line.MaxStock <= 0 {
+		return 0
+	}
+	var demand int
+	if line.Restocked > 0 && cfg.Window > 0 {
+		demand = int(math.Ceil(float64(line.Restocked) * float64(cfg.LeadTime) / float64(cfg.Window)))
+	}
+	projected := line.Quantity + line.OnOrder - demand
+	if projected > line.MinStock || projected >= line.MaxStock {
+		return 0
+	}
+	quantity := max(line.MaxStock-projected, line.MinOrder)
+	if line.PackSize > 1 {
+		quantity = (quantity + line.PackSize - 1) / line.PackSize * line.PackSize
+	}
+	return quantity
+}
+
+// days округляет интервал до целых суток.
+func days(d time.Duration) int {
+	return int(math.Round(d.Hours() / 24))
+}
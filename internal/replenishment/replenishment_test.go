@@ -0,0 +1,125 @@
+package replenishment_test
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"vend_erp/config"
+	"vend_erp/internal/money"
+	"vend_erp/internal/replenishment"
+	"vend_erp/internal/repository"
+)
+
+const day = 24 * time.Hour
+
+var cfg = config.ReplenishmentConfig{Window: 28 * day, LeadTime: 7 * day}
+
+func TestReorder(t *testing.T) {
+	tests := []struct {
+		name string
+		line repository.ReorderLine
+		cfg  config.ReplenishmentConfig
+		want int
+	}{
+		{"no max level", repository.ReorderLine{Quantity: 0, MinStock: 5}, cfg, 0},
+		{"above reorder point", repository.ReorderLine{Quantity: 6, MinStock: 5, MaxStock: 20}, cfg, 0},
+		{"zero velocity at reorder point", repository.ReorderLine{Quantity: 5, MinStock: 5, MaxStock: 20}, cfg, 15},
+		// 28 за 28 дней — 7 за неделю поставки: прогноз 10 − 7 = 3
+		{"demand lowers projection", repository.ReorderLine{Quantity: 10, MinStock: 5, MaxStock: 20, Restocked: 28}, cfg, 17},
+		// ⌈1 × 7 / 28⌉ = 1
+		{"demand rounds up", repository.ReorderLine{Quantity: 6, MinStock: 5, MaxStock: 20, Restocked: 1}, cfg, 15},
+		{"no window", repository.ReorderLine{Quantity: 6, MinStock: 5, MaxStock: 20, Restocked: 28}, config.ReplenishmentConfig{LeadTime: 7 * day}, 0},
+		{"covered by open orders", repository.ReorderLine{Quantity: 2, OnOrder: 10, MinStock: 5, MaxStock: 20}, cfg, 0},
+		{"projection below zero", repository.ReorderLine{Quantity: 0, MinStock: 5, MaxStock: 20, Restocked: 56}, cfg, 34},
+		{"min level at max", repository.ReorderLine{Quantity: 20, MinStock: 20, MaxStock: 20}, cfg, 0},
+		{"min order quantity", repository.ReorderLine{Quantity: 5, MinStock: 5, MaxStock: 8, MinOrder: 10}, cfg, 10},
+		{"min order below need", repository.ReorderLine{Quantity: 5, MinStock: 5, MaxStock: 20, MinOrder: 10}, cfg, 15},
+		{"pack rounding", repository.ReorderLine{Quantity: 5, MinStock: 5, MaxStock: 20, PackSize: 12}, cfg, 24},
+		{"whole packs", repository.ReorderLine{Quantity: 8, MinStock: 8, MaxStock: 20, PackSize: 6}, cfg, 12},
+		{"min order then pack", repository.ReorderLine{Quantity: 5, MinStock: 5, MaxStock: 8, MinOrder: 10, PackSize: 4}, cfg, 12},
+		{"single pack", repository.ReorderLine{Quantity: 5, MinStock: 5, MaxStock: 8, PackSize: 1}, cfg, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := replenishment.Reorder(tt.line, tt.cfg); got != tt.want {
+				t.Errorf("Reorder = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+// wantDraft — склад, поставщик, позиции и сумма ожидаемого черновика.
+type wantDraft struct {
+	warehouseID int64
+	supplier    string
+	items       []int64
+	total       money.Amount
+}
+
+func TestPlan(t *testing.T) {
+	now := time.Date(2026, 3, 10, 15, 30, 0, 0, time.UTC)
+	today := time.Date(2026, 3, 10, 0, 0, 0, 0, time.UTC)
+	rub := money.FromRubles
+	low := func(itemID, warehouseID int64, supplier string, cost money.Amount) repository.ReorderLine {
+		return repository.ReorderLine{
+			ItemID: itemID, WarehouseID: warehouseID, SupplierName: supplier, UnitCost: cost,
+			Quantity: 2, MinStock: 5, MaxStock: 10,
+		}
+	}
+
+	tests := []struct {
+		name  string
+		lines []repository.ReorderLine
+		want  []wantDraft
+	}{
+		{name: "nothing to order", lines: []repository.ReorderLine{
+			{ItemID: 1, WarehouseID: 1, Quantity: 10, MinStock: 5, MaxStock: 10},
+		}},
+		{name: "grouped by warehouse and supplier", lines: []repository.ReorderLine{
+			low(1, 2, "Игрушки", rub(10)),
+			low(2, 1, "Капсулы", rub(5)),
+			low(3, 1, "", rub(1)),
+			low(4, 1, "Капсулы", rub(20)),
+			{ItemID: 5, WarehouseID: 1, SupplierName: "Капсулы", Quantity: 9, MinStock: 5, MaxStock: 10},
+		}, want: []wantDraft{
+			{1, "Капсулы", []int64{2, 4}, rub(5).Mul(8) + rub(20).Mul(8)},
+			{1, replenishment.NoSupplier, []int64{3}, rub(1).Mul(8)},
+			{2, "Игрушки", []int64{1}, rub(10).Mul(8)},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			drafts := replenishment.Plan(tt.lines, cfg, now)
+			if len(drafts) != len(tt.want) {
+				t.Fatalf("got %d drafts, want %d: %+v", len(drafts), len(tt.want), drafts)
+			}
+			for i, want := range tt.want {
+				draft := drafts[i]
+				if draft.WarehouseID != want.warehouseID || draft.SupplierName != want.supplier {
+					t.Errorf("draft %d = warehouse %d %q, want %d %q", i,
+						draft.WarehouseID, draft.SupplierName, want.warehouseID, want.supplier)
+				}
+				if draft.Status != repository.SupplyDraft {
+					t.Errorf("draft %d status = %q, want %q", i, draft.Status, repository.SupplyDraft)
+				}
+				if !draft.SupplyDate.Equal(today) || !draft.ExpectedDate.Equal(today.Add(cfg.LeadTime)) {
+					t.Errorf("draft %d dates = %v, %v", i, draft.SupplyDate, draft.ExpectedDate)
+				}
+				if draft.TotalAmount != want.total {
+					t.Errorf("draft %d total = %v, want %v", i, draft.TotalAmount, want.total)
+				}
+				var items []int64
+				for _, item := range draft.Items {
+					items = append(items, item.InventoryItemID)
+					if item.QuantityOrdered != 8 {
+						t.Errorf("draft %d item %d ordered %d, want 8", i, item.InventoryItemID, item.QuantityOrdered)
+					}
+				}
+				if !slices.Equal(items, want.items) {
+					t.Errorf("draft %d items = %v, want %v", i, items, want.items)
+				}
+			}
+		})
+	}
+}
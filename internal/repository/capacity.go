@@ -0,0 +1,44 @@
+package repository
+
+import "vend_erp/internal/models"
+
+// Политики вместимости склада (warehouse.capacity_policy)
+const (
+	// CapacityBlock — приход сверх вместимости склада или зоны отклоняется
+	CapacityBlock = "block"
+	// CapacityWarn — приход сверх вместимости проводится после
+	// предупреждения
+	CapacityWarn = "warn"
+)
+
+// Putaway подбирает зону для volume единиц вместимости. Зона zoneID,
+// где товар уже лежит, предпочтительна, если в ней хватает места:
+// товар не разносится по складу. Иначе выбирается самая заполненная
+// из зон, где место есть, — просторные зоны остаются для крупных
+// приходов. ok=false — места не хватает ни в одной зоне.
+func Putaway(zones []models.WarehouseZone, zoneID int64, volume int) (zone models.WarehouseZone, ok bool) {
+	for _, z := range zones {
+		if z.ID == zoneID && z.Free() >= volume {
+			return z, true
+		}
+	}
+	for _, z := range zones {
+		if z.Free() < volume {
+			continue
+		}
+		if !ok || z.Free() < zone.Free() || (z.Free() == zone.Free() && z.Name < zone.Name) {
+			zone, ok = z, true
+		}
+	}
+	return zone, ok
+}
+
+// OverCapacity сообщает, переполнит ли добавление volume единиц
+// вместимости склад w или его зону zone (нулевая зона — позиция
+// не размещена).
+func OverCapacity(w models.Warehouse, zone models.WarehouseZone, volume int) bool {
+	if volume <= 0 {
+		return false
+	}
+	return w.Free() < volume || (zone.ID != 0 && zone.Free() < volume)
+}
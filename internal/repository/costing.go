@@ -0,0 +1,55 @@
+package repository
+
+import (
+	"vend_erp/internal/models"
+	"vend_erp/internal/money"
+)
+
+// Методы оценки запасов организации (organizations.costing_method)
+const (
+	// CostFIFO — расход оценивается по ценам партий, от старых к новым
+	CostFIFO = "fifo"
+	// CostAverage — расход оценивается по средней себестоимости остатка
+	CostAverage = "average"
+)
+
+// Issue списывает issued единиц из непустых партий lots, упорядоченных
+// от старых к новым, и возвращает себестоимость списания по методу method
+// и списанные части партий (Quantity — сколько взято из партии).
+//
+// quantity и value — остаток позиции и его стоимость до списания.
+// Партии списываются по порядку при любом методе; от метода зависит
+// только себестоимость. Списание всего остатка стоит ровно value, чтобы
+// округления не оставляли стоимость у пустой позиции. Если партий
+// не хватает, недостающее оценивается по средней себестоимости.
+func Issue(method string, lots []models.StockLot, value money.Amount, quantity, issued int) (money.Amount, []models.StockLot) {
+	var taken []models.StockLot
+	var fifo money.Amount
+	need := issued
+	for _, lot := range lots {
+		if need == 0 {
+			break
+		}
+		n := min(lot.Remaining, need)
+		if n <= 0 {
+			continue
+		}
+		part := lot
+		part.Quantity = n
+		part.Remaining = lot.Remaining - n
+		taken = append(taken, part)
+		fifo += lot.UnitCost.Mul(n)
+		need -= n
+	}
+
+	switch {
+	case issued >= quantity:
+		return value, taken
+	case method == CostAverage:
+		return value.Share(issued, quantity), taken
+	}
+	if need > 0 {
+		fifo += value.Share(need, quantity)
+	}
+	return fifo, taken
+}
@@ -0,0 +1,916 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"vend_erp/internal/models"
+	"vend_erp/internal/money"
+	"vend_erp/internal/repository"
+)
+
+type inventory struct {
+	s *Store
+}
+
+func (r inventory) Warehouses(ctx context.Context, scope repository.Scope) ([]models.Warehouse, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	var list []models.Warehouse
+	for _, w := range r.s.warehouses {
+		if w.IsActive && scope.Includes(w.OrgID) {
+			list = append(list, w)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Name != list[j].Name {
+			return list[i].Name < list[j].Name
+		}
+		return list[i].ID < list[j].ID
+	})
+	return list, nil
+}
+
+func (r inventory) Warehouse(ctx context.Context, scope repository.Scope, id int64) (models.Warehouse, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	w, ok := r.s.warehouses[id]
+	if !ok || !scope.Includes(w.OrgID) {
+		return models.Warehouse{}, repository.ErrNotFound
+	}
+	return w, nil
+}
+
+func (r inventory) CreateWarehouse(ctx context.Context, warehouse *models.Warehouse) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	if warehouse.CapacityPolicy == "" {
+		warehouse.CapacityPolicy = repository.CapacityWarn
+	}
+	warehouse.ID = r.s.id()
+	warehouse.Version = 1
+	warehouse.CurrentUsage = 0
+	warehouse.CreatedAt = time.Now()
+	warehouse.UpdatedAt = warehouse.CreatedAt
+	r.s.warehouses[warehouse.ID] = *warehouse
+	return nil
+}
+
+func (r inventory) UpdateWarehouse(ctx context.Context, scope repository.Scope, warehouse models.Warehouse) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	current, ok := r.s.warehouses[warehouse.ID]
+	if !ok || !scope.Includes(current.OrgID) {
+		return repository.ErrNotFound
+	}
+	if current.Version != warehouse.Version {
+		return repository.ErrConflict
+	}
+	if warehouse.CapacityPolicy == "" {
+		warehouse.CapacityPolicy = repository.CapacityWarn
+	}
+	warehouse.OrgID = current.OrgID
+	warehouse.CurrentUsage = current.CurrentUsage
+	warehouse.CreatedAt = current.CreatedAt
+	warehouse.Version = current.Version + 1
+	warehouse.UpdatedAt = time.Now()
+	r.s.warehouses[warehouse.ID] = warehouse
+	return nil
+}
+
+func (r inventory) Zones(ctx context.Context, scope repository.Scope, warehouseID int64) ([]models.WarehouseZone, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	if w, ok := r.s.warehouses[warehouseID]; !ok || !scope.Includes(w.OrgID) {
+		return nil, repository.ErrNotFound
+	}
+	var list []models.WarehouseZone
+	for _, z := range r.s.zones {
+		if z.WarehouseID == warehouseID {
+			list = append(list, r.s.zone(z))
+		}
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Name != list[j].Name {
+			return list[i].Name < list[j].Name
+		}
+		return list[i].ID < list[j].ID
+	})
+	return list, nil
+}
+
+// zone дополняет зону загрузкой и числом позиций.
+func (s *Store) zone(z models.WarehouseZone) models.WarehouseZone {
+	z.Usage, z.ItemCount = 0, 0
+	for _, item := range s.items {
+		if item.ZoneID == z.ID {
+			z.Usage += item.Quantity * s.products[item.ProductID].UnitVolume
+			z.ItemCount++
+		}
+	}
+	return z
+}
+
+// zoneTaken повторяет UNIQUE(warehouse_id, name).
+func (s *Store) zoneTaken(z models.WarehouseZone) bool {
+	for id, other := range s.zones {
+		if id != z.ID && other.WarehouseID == z.WarehouseID && other.Name == z.Name {
+			return true
+		}
+	}
+	return false
+}
+
+// visibleZone возвращает зону склада области.
+func (s *Store) visibleZone(scope repository.Scope, id int64) (models.WarehouseZone, bool) {
+	z, ok := s.zones[id]
+	if !ok || !scope.Includes(s.warehouses[z.WarehouseID].OrgID) {
+		return models.WarehouseZone{}, false
+	}
+	return z, true
+}
+
+func (r inventory) CreateZone(ctx context.Context, scope repository.Scope, zone *models.WarehouseZone) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	if w, ok := r.s.warehouses[zone.WarehouseID]; !ok || !scope.Includes(w.OrgID) {
+		return repository.ErrNotFound
+	}
+	zone.ID = 0
+	if r.s.zoneTaken(*zone) {
+		return repository.ErrDuplicate
+	}
+	zone.ID = r.s.id()
+	zone.CreatedAt = time.Now()
+	zone.Usage, zone.ItemCount = 0, 0
+	r.s.zones[zone.ID] = *zone
+	return nil
+}
+
+func (r inventory) UpdateZone(ctx context.Context, scope repository.Scope, zone models.WarehouseZone) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	current, ok := r.s.visibleZone(scope, zone.ID)
+	if !ok {
+		return repository.ErrNotFound
+	}
+	current.Name = zone.Name
+	current.Capacity = zone.Capacity
+	if r.s.zoneTaken(current) {
+		return repository.ErrDuplicate
+	}
+	r.s.zones[zone.ID] = current
+	return nil
+}
+
+func (r inventory) DeleteZone(ctx context.Context, scope repository.Scope, id int64) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	if _, ok := r.s.visibleZone(scope, id); !ok {
+		return nil
+	}
+	delete(r.s.zones, id)
+	// Позиции зоны остаются неразмещенными (ON DELETE SET NULL)
+	for itemID, item := range r.s.items {
+		if item.ZoneID == id {
+			item.ZoneID = 0
+			r.s.items[itemID] = item
+		}
+	}
+	return nil
+}
+
+func (r inventory) Place(ctx context.Context, scope repository.Scope, itemID, zoneID int64) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	item, err := r.s.visibleItem(scope, itemID)
+	if err != nil {
+		return err
+	}
+	if !r.s.zoneOf(item.WarehouseID, zoneID) {
+		return repository.ErrNotFound
+	}
+	if zoneID != item.ZoneID && r.s.overZone(zoneID, item.Volume()) {
+		return repository.ErrOverCapacity
+	}
+	stored := r.s.items[itemID]
+	stored.ZoneID = zoneID
+	stored.Version++
+	stored.UpdatedAt = time.Now()
+	r.s.items[itemID] = stored
+	return nil
+}
+
+func (r inventory) Categories(ctx context.Context) ([]models.WarehouseCategory, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	var list []models.WarehouseCategory
+	for _, c := range r.s.categories {
+		list = append(list, c)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list, nil
+}
+
+// item дополняет позицию полями товара, склада, категории и организации.
+func (s *Store) item(item models.WarehouseInventory) models.WarehouseInventory {
+	p := s.products[item.ProductID]
+	item.CategoryID = p.CategoryID
+	item.ItemType = p.ItemType
+	item.ItemName = p.Name
+	item.Description = p.Description
+	item.UnitPrice = p.DefaultCost
+	item.SKU = p.SKU
+	item.UnitVolume = p.UnitVolume
+	w := s.warehouses[item.WarehouseID]
+	item.WarehouseName = w.Name
+	item.WarehouseAddress = w.Address
+	item.CategoryName = s.categories[item.CategoryID].Name
+	item.ZoneName = s.zones[item.ZoneID].Name
+	item.OrgID = w.OrgID
+	item.OrgName = s.orgs[w.OrgID].Name
+	item.Reserved = s.reserved(item.ID, 0)
+	return item
+}
+
+func matchesStock(item models.WarehouseInventory, stock string) bool {
+	switch stock {
+	case repository.StockLow:
+		return item.Quantity < item.MinStockLevel
+	case repository.StockOut:
+		return item.Quantity <= 0
+	case repository.StockNormal:
+		return item.Quantity >= item.MinStockLevel && item.Quantity != 0
+	}
+	return true
+}
+
+func (r inventory) Items(ctx context.Context, scope repository.Scope, filter repository.InventoryFilter) ([]models.WarehouseInventory, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	var list []models.WarehouseInventory
+	for _, stored := range r.s.items {
+		item := r.s.item(stored)
+		w := r.s.warehouses[item.WarehouseID]
+		switch {
+		case !w.IsActive || !scope.Includes(w.OrgID):
+		case filter.WarehouseID != 0 && item.WarehouseID != filter.WarehouseID:
+		case filter.ItemType != "" && item.ItemType != filter.ItemType:
+		case !matchesStock(item, filter.Stock):
+		default:
+			list = append(list, item)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool {
+		a, b := list[i], list[j]
+		switch {
+		case a.WarehouseName != b.WarehouseName:
+			return a.WarehouseName < b.WarehouseName
+		case a.ItemType != b.ItemType:
+			return a.ItemType < b.ItemType
+		case a.ItemName != b.ItemName:
+			return a.ItemName < b.ItemName
+		}
+		return a.ID < b.ID
+	})
+	return list, nil
+}
+
+func (r inventory) Item(ctx context.Context, scope repository.Scope, id int64) (models.WarehouseInventory, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	return r.s.visibleItem(scope, id)
+}
+
+func (s *Store) visibleItem(scope repository.Scope, id int64) (models.WarehouseInventory, error) {
+	item, ok := s.items[id]
+	if !ok || !scope.Includes(s.warehouses[item.WarehouseID].OrgID) {
+		return models.WarehouseInventory{}, repository.ErrNotFound
+	}
+	return s.item(item), nil
+}
+
+// stocked повторяет UNIQUE(warehouse_id, product_id): у товара одна
+// позиция на складе.
+func (s *Store) stocked(warehouseID, productID, exceptID int64) bool {
+	for id, item := range s.items {
+		if id != exceptID && item.WarehouseID == warehouseID && item.ProductID == productID {
+			return true
+		}
+	}
+	return false
+}
+
+// stockFields оставляет в позиции только хранимые в ней поля: остальные
+// берутся из справочника товаров и склада.
+func stockFields(item models.WarehouseInventory) models.WarehouseInventory {
+	return models.WarehouseInventory{
+		ID: item.ID, WarehouseID: item.WarehouseID, ProductID: item.ProductID,
+		Quantity: item.Quantity, MinStockLevel: item.MinStockLevel, MaxStockLevel: item.MaxStockLevel,
+		ZoneID: item.ZoneID, StockValue: item.StockValue, CreatedAt: item.CreatedAt, UpdatedAt: item.UpdatedAt, Version: item.Version,
+	}
+}
+
+func (s *Store) createItem(item *models.WarehouseInventory) error {
+	w, ok := s.warehouses[item.WarehouseID]
+	if p, found := s.products[item.ProductID]; !ok || !found || p.OrgID != w.OrgID {
+		return repository.ErrNotFound
+	}
+	if !s.zoneOf(item.WarehouseID, item.ZoneID) {
+		return repository.ErrNotFound
+	}
+	if s.stocked(item.WarehouseID, item.ProductID, 0) {
+		return repository.ErrDuplicate
+	}
+	if s.overCapacity(item.WarehouseID, item.ZoneID, item.Quantity*s.products[item.ProductID].UnitVolume) {
+		return repository.ErrOverCapacity
+	}
+	item.ID = s.id()
+	item.Version = 1
+	item.CreatedAt = time.Now()
+	item.UpdatedAt = item.CreatedAt
+	// Начальный остаток оценивается по закупочной цене товара
+	unitCost := s.products[item.ProductID].DefaultCost
+	item.StockValue = 0
+	if item.Quantity > 0 {
+		item.StockValue = unitCost.Mul(item.Quantity)
+	}
+	s.items[item.ID] = stockFields(*item)
+	if item.Quantity > 0 {
+		m := s.record(*item, models.StockMovement{
+			Type: repository.MovementOpening, Quantity: item.Quantity, Cost: item.StockValue,
+			Reason: "Начальный остаток",
+		})
+		s.addLot(item.ID, m.ID, models.StockLot{UnitCost: unitCost, Quantity: item.Quantity})
+	}
+	s.updateUsage(item.WarehouseID)
+	return nil
+}
+
+func (r inventory) CreateItem(ctx context.Context, item *models.WarehouseInventory) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	return r.s.createItem(item)
+}
+
+func (r inventory) UpdateItem(ctx context.Context, scope repository.Scope, item models.WarehouseInventory) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	current, err := r.s.visibleItem(scope, item.ID)
+	if err != nil {
+		return err
+	}
+	if current.Version != item.Version {
+		return repository.ErrConflict
+	}
+	item.ProductID = current.ProductID
+	if r.s.stocked(item.WarehouseID, item.ProductID, item.ID) {
+		return repository.ErrDuplicate
+	}
+	if item.Quantity < 0 {
+		return repository.ErrInsufficientStock
+	}
+	// Отложенное остается на складе позиции: его нельзя ни списать
+	// правкой количества, ни увезти вместе с позицией
+	if current.Reserved > 0 && current.WarehouseID != item.WarehouseID {
+		return repository.ErrInUse
+	}
+	if item.Quantity < current.Quantity && item.Quantity < current.Reserved {
+		return repository.ErrInsufficientStock
+	}
+	if !r.s.zoneOf(item.WarehouseID, item.ZoneID) {
+		return repository.ErrNotFound
+	}
+	// Перенос на другой склад проверяется как приход, новая зона — как
+	// размещение; правка количества — корректировка и не проверяется
+	volume := item.Quantity * current.UnitVolume
+	var over bool
+	switch {
+	case current.WarehouseID != item.WarehouseID && item.Quantity > 0:
+		over = r.s.overCapacity(item.WarehouseID, item.ZoneID, volume)
+	case current.ZoneID != item.ZoneID:
+		over = r.s.overZone(item.ZoneID, volume)
+	}
+	if over {
+		return repository.ErrOverCapacity
+	}
+
+	// Перенос позиции на другой склад — перемещение всего остатка
+	// вместе с партиями
+	if current.WarehouseID != item.WarehouseID && current.Quantity > 0 {
+		moved := current.Quantity
+		out, lots, _ := r.s.move(item.ID, models.StockMovement{
+			Type: repository.MovementTransferOut, Quantity: -moved, Reason: "Позиция перенесена на другой склад",
+		})
+		stored := r.s.items[item.ID]
+		stored.WarehouseID = item.WarehouseID
+		r.s.items[item.ID] = stored
+		r.s.move(item.ID, models.StockMovement{
+			Type: repository.MovementTransferIn, Quantity: moved, Cost: -out.Cost,
+			Reason: "Позиция перенесена на другой склад",
+		}, lots...)
+	}
+	stored := r.s.items[item.ID]
+	stored.WarehouseID = item.WarehouseID
+	r.s.items[item.ID] = stored
+	if delta := item.Quantity - stored.Quantity; delta != 0 {
+		r.s.move(item.ID, models.StockMovement{
+			Type: repository.MovementAdjustment, Quantity: delta, Reason: "Изменено в карточке позиции",
+		})
+	}
+
+	item.StockValue = r.s.items[item.ID].StockValue
+	item.CreatedAt = current.CreatedAt
+	item.Version = current.Version + 1
+	item.UpdatedAt = time.Now()
+	r.s.items[item.ID] = stockFields(item)
+	r.s.updateUsage(current.WarehouseID)
+	r.s.updateUsage(item.WarehouseID)
+	return nil
+}
+
+func (r inventory) DeleteItem(ctx context.Context, scope repository.Scope, id int64) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	item, err := r.s.visibleItem(scope, id)
+	if err != nil {
+		return nil
+	}
+	// Журнал позиции не удаляется: позицию с историей удалить нельзя.
+	// Партии и резервы бывают только у позиций с движениями
+	if item.Quantity != 0 {
+		return repository.ErrInUse
+	}
+	for _, m := range r.s.movements {
+		if m.ItemID == id {
+			return repository.ErrInUse
+		}
+	}
+	delete(r.s.items, id)
+	// Позиции заказов поставщикам удаляются вместе с ней (ON DELETE CASCADE)
+	for supplyID, supply := range r.s.supplies {
+		items := supply.Items[:0]
+		for _, si := range supply.Items {
+			if si.InventoryItemID != id {
+				items = append(items, si)
+			}
+		}
+		supply.Items = items
+		r.s.supplies[supplyID] = supply
+	}
+	// и позиции инвентаризаций
+	for stID, st := range r.s.stocktakes {
+		lines := st.Lines[:0]
+		for _, line := range st.Lines {
+			if line.ItemID != id {
+				lines = append(lines, line)
+			}
+		}
+		st.Lines = lines
+		r.s.stocktakes[stID] = st
+	}
+	r.s.updateUsage(item.WarehouseID)
+	return nil
+}
+
+func (r inventory) Adjust(ctx context.Context, scope repository.Scope, itemID int64, kind string, quantity int, reason string) (int, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	item, err := r.s.visibleItem(scope, itemID)
+	if err != nil {
+		return 0, err
+	}
+
+	var delta int
+	switch kind {
+	case repository.AdjustAdd:
+		delta = quantity
+	case repository.AdjustSubtract:
+		delta = -quantity
+	case repository.AdjustSet:
+		delta = quantity - item.Quantity
+	default:
+		return 0, fmt.Errorf("unknown adjustment type %q", kind)
+	}
+	if delta < 0 && item.Quantity+delta < item.Reserved {
+		return 0, repository.ErrInsufficientStock
+	}
+
+	m, _, err := r.s.move(itemID, models.StockMovement{
+		Type: repository.MovementAdjustment, Quantity: delta, Reason: reason,
+	})
+	if err != nil {
+		return 0, err
+	}
+	r.s.updateUsage(item.WarehouseID)
+	return m.BalanceAfter, nil
+}
+
+func (r inventory) Transfer(ctx context.Context, scope repository.Scope, itemID, targetWarehouseID int64, quantity int, notes string) error {
+	if quantity <= 0 {
+		return fmt.Errorf("transfer quantity must be positive, got %d", quantity)
+	}
+
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	source, err := r.s.visibleItem(scope, itemID)
+	if err != nil {
+		return err
+	}
+	if source.WarehouseID == targetWarehouseID {
+		return fmt.Errorf("transfer of item %d to its own warehouse", itemID)
+	}
+	if w, ok := r.s.warehouses[targetWarehouseID]; !ok || w.OrgID != source.OrgID {
+		return repository.ErrNotFound
+	}
+	if source.Available() < quantity {
+		return repository.ErrInsufficientStock
+	}
+	var targetZoneID int64
+	for _, item := range r.s.items {
+		if item.WarehouseID == targetWarehouseID && item.ProductID == source.ProductID {
+			targetZoneID = item.ZoneID
+		}
+	}
+	if r.s.overCapacity(targetWarehouseID, targetZoneID, quantity*source.UnitVolume) {
+		return repository.ErrOverCapacity
+	}
+
+	// Остаток того же товара на целевом складе; если его нет, он заводится
+	// с пороговыми уровнями исходной позиции
+	var targetID int64
+	for id, item := range r.s.items {
+		if item.WarehouseID == targetWarehouseID && item.ProductID == source.ProductID {
+			targetID = id
+			break
+		}
+	}
+	if targetID == 0 {
+		target := r.s.items[itemID]
+		target.WarehouseID = targetWarehouseID
+		target.ZoneID = 0
+		target.Quantity = 0
+		if err := r.s.createItem(&target); err != nil {
+			return err
+		}
+		targetID = target.ID
+	}
+
+	// Партии переходят на целевой склад со своими ценами и датами прихода,
+	// стоимость — с той, по которой товар списан с исходного
+	out, lots, _ := r.s.move(itemID, models.StockMovement{
+		Type: repository.MovementTransferOut, Quantity: -quantity, TransferItemID: targetID, Reason: notes,
+	})
+	r.s.move(targetID, models.StockMovement{
+		Type: repository.MovementTransferIn, Quantity: quantity, Cost: -out.Cost,
+		TransferItemID: itemID, Reason: notes,
+	}, lots...)
+	r.s.updateUsage(source.WarehouseID)
+	r.s.updateUsage(targetWarehouseID)
+	return nil
+}
+
+func (r inventory) Move(ctx context.Context, scope repository.Scope, itemID int64, change repository.StockChange) (int, error) {
+	var sign int
+	switch change.Type {
+	case repository.MovementReceipt:
+		sign = 1
+	case repository.MovementShipment:
+		sign = -1
+	default:
+		return 0, fmt.Errorf("unknown stock change type %q", change.Type)
+	}
+	if change.Quantity <= 0 {
+		return 0, fmt.Errorf("stock change quantity must be positive, got %d", change.Quantity)
+	}
+
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	item, err := r.s.visibleItem(scope, itemID)
+	if err != nil {
+		return 0, err
+	}
+	// Расход берет доступный товар и отложенный под его резерв
+	if change.ReservationID != 0 {
+		res, ok := r.s.reserves[change.ReservationID]
+		if !ok || res.ItemID != itemID || !active(res, time.Now()) || res.Type != change.Type {
+			return 0, repository.ErrNotFound
+		}
+	}
+	if sign < 0 && item.Quantity-r.s.reserved(itemID, change.ReservationID) < change.Quantity {
+		return 0, repository.ErrInsufficientStock
+	}
+	if change.Type == repository.MovementReceipt && r.s.overCapacity(item.WarehouseID, item.ZoneID, change.Quantity*item.UnitVolume) {
+		return 0, repository.ErrOverCapacity
+	}
+
+	var lots []models.StockLot
+	if change.Type == repository.MovementReceipt {
+		unitCost := change.UnitCost
+		if unitCost == 0 {
+			unitCost = item.UnitPrice
+		}
+		lots = append(lots, models.StockLot{UnitCost: unitCost, Quantity: change.Quantity})
+	}
+	m, _, err := r.s.move(itemID, models.StockMovement{
+		Type: change.Type, Quantity: sign * change.Quantity, Reason: change.Reason,
+	}, lots...)
+	if err != nil {
+		return 0, err
+	}
+	if change.ReservationID != 0 {
+		res := r.s.reserves[change.ReservationID]
+		res.Status = repository.ReservationConsumed
+		res.MovementID = m.ID
+		r.s.reserves[res.ID] = res
+	}
+	r.s.updateUsage(item.WarehouseID)
+	return m.BalanceAfter, nil
+}
+
+func (r inventory) Movements(ctx context.Context, scope repository.Scope, itemID int64) ([]models.StockMovement, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	if _, err := r.s.visibleItem(scope, itemID); err != nil {
+		return nil, nil
+	}
+	var list []models.StockMovement
+	for _, m := range r.s.movements {
+		if m.ItemID != itemID {
+			continue
+		}
+		m.WarehouseName = r.s.warehouses[m.WarehouseID].Name
+		m.MachineSerial = r.s.machines[m.VendingMachineID].SerialNumber
+		list = append(list, m)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return newestFirst(list[i].CreatedAt, list[j].CreatedAt, list[i].ID, list[j].ID)
+	})
+	return list, nil
+}
+
+func (r inventory) BalanceAt(ctx context.Context, scope repository.Scope, itemID int64, at time.Time) (int, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	if _, err := r.s.visibleItem(scope, itemID); err != nil {
+		return 0, err
+	}
+	// Журнал хранится в порядке записи: последнее подходящее движение
+	// и есть остаток на момент at
+	balance := 0
+	for _, m := range r.s.movements {
+		if m.ItemID == itemID && !m.CreatedAt.After(at) {
+			balance = m.BalanceAfter
+		}
+	}
+	return balance, nil
+}
+
+func (r inventory) Lots(ctx context.Context, scope repository.Scope, itemID int64) ([]models.StockLot, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	if _, err := r.s.visibleItem(scope, itemID); err != nil {
+		return nil, err
+	}
+	return r.s.openLots(itemID), nil
+}
+
+func (r inventory) Valuation(ctx context.Context, scope repository.Scope, at time.Time) ([]repository.WarehouseValue, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	// Стоимость остатка позиции равна сумме стоимостей ее движений
+	byWarehouse := make(map[int64]*repository.WarehouseValue)
+	for _, m := range r.s.movements {
+		w := r.s.warehouses[m.WarehouseID]
+		if m.CreatedAt.After(at) || !scope.Includes(w.OrgID) {
+			continue
+		}
+		v, ok := byWarehouse[w.ID]
+		if !ok {
+			v = &repository.WarehouseValue{WarehouseID: w.ID, WarehouseName: w.Name}
+			byWarehouse[w.ID] = v
+		}
+		v.Quantity += m.Quantity
+		v.Value += m.Cost
+	}
+	var list []repository.WarehouseValue
+	for _, v := range byWarehouse {
+		if v.Quantity != 0 || v.Value != 0 {
+			list = append(list, *v)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].WarehouseName != list[j].WarehouseName {
+			return list[i].WarehouseName < list[j].WarehouseName
+		}
+		return list[i].WarehouseID < list[j].WarehouseID
+	})
+	return list, nil
+}
+
+func (r inventory) CostOfSales(ctx context.Context, scope repository.Scope, from, to time.Time) ([]repository.OperationCost, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	// Строки операций по номеру; движения без операции — каждое отдельно.
+	// first — первое движение строки, для порядка как в postgres
+	type row struct {
+		repository.OperationCost
+		first int64
+	}
+	var rows []*row
+	index := make(map[int64]*row)
+	for _, m := range r.s.movements {
+		w := r.s.warehouses[m.WarehouseID]
+		if m.Type != repository.MovementRestock || m.CreatedAt.Before(from) || !m.CreatedAt.Before(to) || !scope.Includes(w.OrgID) {
+			continue
+		}
+		c := index[m.OperationID]
+		if c == nil || m.OperationID == 0 {
+			c = &row{OperationCost: repository.OperationCost{OperationID: m.OperationID, Date: m.CreatedAt}, first: m.ID}
+			if m.OperationID != 0 {
+				index[m.OperationID] = c
+				c.Date = r.s.operations[m.OperationID].OperationDate
+			} else {
+				c.Reason = m.Reason
+			}
+			rows = append(rows, c)
+		}
+		c.MachineSerial = max(c.MachineSerial, r.s.machines[m.VendingMachineID].SerialNumber)
+		c.Quantity -= m.Quantity
+		c.Cost -= m.Cost
+		c.first = min(c.first, m.ID)
+	}
+	sort.Slice(rows, func(i, j int) bool {
+		a, b := rows[i], rows[j]
+		if a.Date.Equal(b.Date) && a.OperationID == b.OperationID {
+			return a.first > b.first
+		}
+		return newestFirst(a.Date, b.Date, a.OperationID, b.OperationID)
+	})
+	list := make([]repository.OperationCost, len(rows))
+	for i, c := range rows {
+		list[i] = c.OperationCost
+	}
+	return list, nil
+}
+
+// move меняет остаток позиции на m.Quantity и записывает движение в журнал.
+// Отрицательный остаток не допускается. Партии и стоимость — как
+// в postgres: приход заводит партии lots (без них — одну по средней
+// себестоимости), расход списывает партии от старых к новым.
+// Возвращает записанное движение и списанные части партий.
+func (s *Store) move(itemID int64, m models.StockMovement, lots ...models.StockLot) (models.StockMovement, []models.StockLot, error) {
+	item := s.items[itemID]
+	balance := item.Quantity + m.Quantity
+	if balance < 0 {
+		return m, nil, repository.ErrInsufficientStock
+	}
+
+	var taken []models.StockLot
+	switch {
+	case m.Quantity > 0:
+		if len(lots) == 0 {
+			unitCost := s.products[item.ProductID].DefaultCost
+			if item.Quantity > 0 {
+				unitCost = item.StockValue.Share(1, item.Quantity)
+			}
+			lots = []models.StockLot{{UnitCost: unitCost, Quantity: m.Quantity}}
+		}
+		if m.Cost == 0 {
+			for _, lot := range lots {
+				m.Cost += lot.UnitCost.Mul(lot.Quantity)
+			}
+		}
+	case m.Quantity < 0:
+		method := s.orgs[s.warehouses[item.WarehouseID].OrgID].CostingMethod
+		var cost money.Amount
+		cost, taken = repository.Issue(method, s.openLots(itemID), item.StockValue, item.Quantity, -m.Quantity)
+		m.Cost = -cost
+		for _, part := range taken {
+			for i := range s.lots {
+				if s.lots[i].ID == part.ID {
+					s.lots[i].Remaining = part.Remaining
+				}
+			}
+		}
+	}
+
+	item.Quantity = balance
+	item.StockValue += m.Cost
+	item.Version++
+	item.UpdatedAt = time.Now()
+	s.items[itemID] = item
+	m = s.record(item, m)
+	if m.Quantity > 0 {
+		for _, lot := range lots {
+			s.addLot(itemID, m.ID, lot)
+		}
+	}
+	return m, taken, nil
+}
+
+// record добавляет движение m в журнал позиции с ее текущим остатком
+// и возвращает записанное движение.
+func (s *Store) record(item models.WarehouseInventory, m models.StockMovement) models.StockMovement {
+	m.ID = s.id()
+	m.ItemID = item.ID
+	m.WarehouseID = item.WarehouseID
+	m.BalanceAfter = item.Quantity
+	m.CreatedAt = time.Now()
+	s.movements = append(s.movements, m)
+	return m
+}
+
+// openLots возвращает непустые партии позиции, старые первыми.
+func (s *Store) openLots(itemID int64) []models.StockLot {
+	var lots []models.StockLot
+	for _, lot := range s.lots {
+		if lot.ItemID == itemID && lot.Remaining > 0 {
+			lots = append(lots, lot)
+		}
+	}
+	sort.SliceStable(lots, func(i, j int) bool {
+		if !lots[i].ReceivedAt.Equal(lots[j].ReceivedAt) {
+			return lots[i].ReceivedAt.Before(lots[j].ReceivedAt)
+		}
+		return lots[i].ID < lots[j].ID
+	})
+	return lots
+}
+
+// addLot заводит партию позиции, пришедшую движением movementID. Партия,
+// перемещенная с другого склада, сохраняет дату прихода.
+func (s *Store) addLot(itemID, movementID int64, lot models.StockLot) {
+	lot.ID = s.id()
+	lot.ItemID = itemID
+	lot.MovementID = movementID
+	lot.Remaining = lot.Quantity
+	if lot.ReceivedAt.IsZero() {
+		lot.ReceivedAt = time.Now()
+	}
+	s.lots = append(s.lots, lot)
+}
+
+// updateUsage пересчитывает загрузку склада по объемам остатков.
+func (s *Store) updateUsage(warehouseID int64) {
+	w, ok := s.warehouses[warehouseID]
+	if !ok {
+		return
+	}
+	w.CurrentUsage = 0
+	for _, item := range s.items {
+		if item.WarehouseID == warehouseID {
+			w.CurrentUsage += item.Quantity * s.products[item.ProductID].UnitVolume
+		}
+	}
+	s.warehouses[warehouseID] = w
+}
+
+// zoneOf сообщает, что zoneID — 0 или зона склада.
+func (s *Store) zoneOf(warehouseID, zoneID int64) bool {
+	z, ok := s.zones[zoneID]
+	return zoneID == 0 || (ok && z.WarehouseID == warehouseID)
+}
+
+// overCapacity повторяет проверку вместимости postgres до изменения:
+// переполнят ли volume новых единиц вместимости склад или зону zoneID
+// (0 — без зоны) с политикой CapacityBlock.
+func (s *Store) overCapacity(warehouseID, zoneID int64, volume int) bool {
+	w := s.warehouses[warehouseID]
+	if volume <= 0 || w.CapacityPolicy != repository.CapacityBlock {
+		return false
+	}
+	return w.CurrentUsage+volume > w.TotalCapacity || s.overZone(zoneID, volume)
+}
+
+// overZone — то же только для зоны.
+func (s *Store) overZone(zoneID int64, volume int) bool {
+	z, ok := s.zones[zoneID]
+	if !ok || s.warehouses[z.WarehouseID].CapacityPolicy != repository.CapacityBlock {
+		return false
+	}
+	return s.zone(z).Usage+volume > z.Capacity
+}
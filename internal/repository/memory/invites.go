@@ -0,0 +1,93 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"vend_erp/internal/models"
+	"vend_erp/internal/repository"
+)
+
+type invites struct {
+	s *Store
+}
+
+func (s *Store) invite(i invite) models.Invite {
+	result := i.Invite
+	result.OrgName = s.orgs[i.OrgID].Name
+	result.InvitedByName = ""
+	if u, ok := s.users[i.InvitedBy]; ok {
+		result.InvitedByName = u.Username
+	}
+	return result
+}
+
+func (r invites) Find(ctx context.Context, tokenHash string) (models.Invite, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	for _, i := range r.s.invites {
+		if i.tokenHash == tokenHash && r.s.orgs[i.OrgID].IsActive {
+			return r.s.invite(i), nil
+		}
+	}
+	return models.Invite{}, repository.ErrNotFound
+}
+
+func (r invites) List(ctx context.Context, scope repository.Scope) ([]models.Invite, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	var list []models.Invite
+	for _, i := range r.s.invites {
+		if scope.Includes(i.OrgID) {
+			list = append(list, r.s.invite(i))
+		}
+	}
+	sort.Slice(list, func(i, j int) bool {
+		a, b := list[i], list[j]
+		if a.Usable() != b.Usable() {
+			return a.Usable()
+		}
+		return newestFirst(a.CreatedAt, b.CreatedAt, a.ID, b.ID)
+	})
+	if len(list) > 50 {
+		list = list[:50]
+	}
+	return list, nil
+}
+
+func (r invites) Create(ctx context.Context, i *models.Invite, tokenHash string) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	if _, ok := r.s.orgs[i.OrgID]; !ok {
+		return repository.ErrNotFound
+	}
+	for _, existing := range r.s.invites {
+		if existing.tokenHash == tokenHash {
+			return repository.ErrDuplicate
+		}
+	}
+	i.ID = r.s.id()
+	i.UsedAt = nil
+	i.RevokedAt = nil
+	i.CreatedAt = time.Now()
+	r.s.invites[i.ID] = invite{Invite: *i, tokenHash: tokenHash}
+	return nil
+}
+
+func (r invites) Revoke(ctx context.Context, scope repository.Scope, id int64) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	i, ok := r.s.invites[id]
+	if !ok || !scope.Includes(i.OrgID) || i.UsedAt != nil || i.RevokedAt != nil {
+		return nil
+	}
+	now := time.Now()
+	i.RevokedAt = &now
+	r.s.invites[id] = i
+	return nil
+}
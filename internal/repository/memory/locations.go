@@ -0,0 +1,110 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"vend_erp/internal/models"
+	"vend_erp/internal/repository"
+)
+
+type locations struct {
+	s *Store
+}
+
+func (s *Store) location(l models.Location) models.Location {
+	l.OrgName = s.orgs[l.OrgID].Name
+	return l
+}
+
+func (r locations) List(ctx context.Context, scope repository.Scope) ([]models.Location, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	var list []models.Location
+	for _, l := range r.s.locations {
+		if scope.Includes(l.OrgID) {
+			list = append(list, r.s.location(l))
+		}
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return newestFirst(list[i].CreatedAt, list[j].CreatedAt, list[i].ID, list[j].ID)
+	})
+	return list, nil
+}
+
+func (r locations) Get(ctx context.Context, scope repository.Scope, id int64) (models.Location, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	l, ok := r.s.locations[id]
+	if !ok || !scope.Includes(l.OrgID) {
+		return models.Location{}, repository.ErrNotFound
+	}
+	return r.s.location(l), nil
+}
+
+func (r locations) ListActive(ctx context.Context, orgID int64) ([]models.Location, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	var list []models.Location
+	for _, l := range r.s.locations {
+		if l.OrgID == orgID && l.IsActive {
+			list = append(list, r.s.location(l))
+		}
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list, nil
+}
+
+func (r locations) Create(ctx context.Context, location *models.Location) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	location.ID = r.s.id()
+	location.Version = 1
+	location.CreatedAt = time.Now()
+	location.UpdatedAt = location.CreatedAt
+	r.s.locations[location.ID] = *location
+	return nil
+}
+
+func (r locations) Update(ctx context.Context, scope repository.Scope, location models.Location) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	current, ok := r.s.locations[location.ID]
+	if !ok || !scope.Includes(current.OrgID) {
+		return repository.ErrNotFound
+	}
+	if current.Version != location.Version {
+		return repository.ErrConflict
+	}
+	location.OrgID = current.OrgID
+	location.CreatedAt = current.CreatedAt
+	location.Version = current.Version + 1
+	location.UpdatedAt = time.Now()
+	r.s.locations[location.ID] = location
+	return nil
+}
+
+func (r locations) Delete(ctx context.Context, scope repository.Scope, id int64) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	l, ok := r.s.locations[id]
+	if !ok || !scope.Includes(l.OrgID) {
+		return nil
+	}
+	delete(r.s.locations, id)
+	// Автоматы остаются без локации (ON DELETE SET NULL)
+	for machineID, m := range r.s.machines {
+		if m.LocationID == id {
+			m.LocationID = 0
+			r.s.machines[machineID] = m
+		}
+	}
+	return nil
+}
@@ -0,0 +1,218 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"vend_erp/internal/models"
+	"vend_erp/internal/repository"
+)
+
+type machines struct {
+	s *Store
+}
+
+// machine дополняет запись полями, которые PostgreSQL получает JOIN-ом.
+func (s *Store) machine(m models.VendingMachine) models.VendingMachine {
+	m.LocationName = "Не назначена"
+	if location, ok := s.locations[m.LocationID]; ok {
+		m.LocationName = location.Name
+	}
+	m.OrgName = s.orgs[m.OrgID].Name
+	return m
+}
+
+func (r machines) List(ctx context.Context, scope repository.Scope) ([]models.VendingMachine, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	var list []models.VendingMachine
+	for _, m := range r.s.machines {
+		if scope.Includes(m.OrgID) {
+			list = append(list, r.s.machine(m))
+		}
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return newestFirst(list[i].CreatedAt, list[j].CreatedAt, list[i].ID, list[j].ID)
+	})
+	return list, nil
+}
+
+func (r machines) Get(ctx context.Context, scope repository.Scope, id int64) (models.VendingMachine, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	m, ok := r.s.machines[id]
+	if !ok || !scope.Includes(m.OrgID) {
+		return models.VendingMachine{}, repository.ErrNotFound
+	}
+	return r.s.machine(m), nil
+}
+
+func (r machines) FindBySerial(ctx context.Context, scope repository.Scope, serial string) (models.VendingMachine, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	for _, m := range r.s.machines {
+		if m.SerialNumber == serial && scope.Includes(m.OrgID) {
+			return r.s.machine(m), nil
+		}
+	}
+	return models.VendingMachine{}, repository.ErrNotFound
+}
+
+func (r machines) ListActive(ctx context.Context, orgID int64) ([]models.VendingMachine, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	var list []models.VendingMachine
+	for _, m := range r.s.machines {
+		if m.OrgID == orgID && m.Status == "active" {
+			list = append(list, r.s.machine(m))
+		}
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].SerialNumber < list[j].SerialNumber })
+	return list, nil
+}
+
+func (r machines) Create(ctx context.Context, machine *models.VendingMachine) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	for _, m := range r.s.machines {
+		if m.SerialNumber == machine.SerialNumber {
+			return repository.ErrDuplicate
+		}
+	}
+	machine.ID = r.s.id()
+	machine.Version = 1
+	machine.CreatedAt = time.Now()
+	machine.UpdatedAt = machine.CreatedAt
+	r.s.machines[machine.ID] = *machine
+	return nil
+}
+
+func (r machines) Update(ctx context.Context, scope repository.Scope, machine models.VendingMachine) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	current, ok := r.s.machines[machine.ID]
+	if !ok || !scope.Includes(current.OrgID) {
+		return repository.ErrNotFound
+	}
+	if current.Version != machine.Version {
+		return repository.ErrConflict
+	}
+	for _, m := range r.s.machines {
+		if m.ID != machine.ID && m.SerialNumber == machine.SerialNumber {
+			return repository.ErrDuplicate
+		}
+	}
+	machine.OrgID = current.OrgID
+	machine.CreatedAt = current.CreatedAt
+	machine.Version = current.Version + 1
+	machine.UpdatedAt = time.Now()
+	r.s.machines[machine.ID] = machine
+	return nil
+}
+
+func (r machines) Delete(ctx context.Context, scope repository.Scope, id int64) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	if m, ok := r.s.machines[id]; ok && scope.Includes(m.OrgID) {
+		r.s.deleteMachine(id)
+	}
+	return nil
+}
+
+// deleteMachine удаляет автомат вместе с его операциями и выкладкой
+// (ON DELETE CASCADE); движения склада остаются без ссылки на автомат
+// и операцию (ON DELETE SET NULL).
+func (s *Store) deleteMachine(id int64) {
+	delete(s.machines, id)
+	for opID, op := range s.operations {
+		if op.VendingMachineID == id {
+			delete(s.operations, opID)
+			for i := range s.movements {
+				if s.movements[i].OperationID == opID {
+					s.movements[i].OperationID = 0
+				}
+			}
+		}
+	}
+	for itemID, item := range s.planogram {
+		if item.MachineID == id {
+			delete(s.planogram, itemID)
+		}
+	}
+	for resID, res := range s.reserves {
+		if res.VendingMachineID == id {
+			delete(s.reserves, resID)
+		}
+	}
+	for i := range s.movements {
+		if s.movements[i].VendingMachineID == id {
+			s.movements[i].VendingMachineID = 0
+		}
+	}
+}
+
+func (r machines) Planogram(ctx context.Context, scope repository.Scope, machineID int64) ([]models.PlanogramItem, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	var items []models.PlanogramItem
+	if m, ok := r.s.machines[machineID]; !ok || !scope.Includes(m.OrgID) {
+		return items, nil
+	}
+	for _, item := range r.s.planogram {
+		if item.MachineID == machineID {
+			p := r.s.products[item.ProductID]
+			item.ProductName = p.Name
+			item.SKU = p.SKU
+			items = append(items, item)
+		}
+	}
+	sort.Slice(items, func(i, j int) bool {
+		if items[i].ProductName != items[j].ProductName {
+			return items[i].ProductName < items[j].ProductName
+		}
+		return items[i].ID < items[j].ID
+	})
+	return items, nil
+}
+
+func (r machines) SetPlanogram(ctx context.Context, scope repository.Scope, machineID int64, items []models.PlanogramItem) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	m, ok := r.s.machines[machineID]
+	if !ok || !scope.Includes(m.OrgID) {
+		return repository.ErrNotFound
+	}
+	seen := make(map[int64]bool, len(items))
+	for _, item := range items {
+		if p, ok := r.s.products[item.ProductID]; !ok || p.OrgID != m.OrgID {
+			return repository.ErrNotFound
+		}
+		if seen[item.ProductID] {
+			return repository.ErrDuplicate
+		}
+		seen[item.ProductID] = true
+	}
+
+	for id, item := range r.s.planogram {
+		if item.MachineID == machineID {
+			delete(r.s.planogram, id)
+		}
+	}
+	for _, item := range items {
+		item.ID = r.s.id()
+		item.MachineID = machineID
+		item.ProductName, item.SKU = "", ""
+		r.s.planogram[item.ID] = item
+	}
+	return nil
+}
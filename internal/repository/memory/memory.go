@@ -0,0 +1,168 @@
+// Package memory реализует хранилища repository в памяти процесса.
+// Она нужна тестам обработчиков и повторяет поведение PostgreSQL,
+// которое видно через интерфейсы: области видимости организаций,
+// уникальность email, артикулов и штрихкодов, каскадное удаление и порядок списков.
+//
+//	store := memory.New()
+//	org := store.AddOrganization("Тест")
+//	repos := store.Repos()
+package memory
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"vend_erp/internal/models"
+	"vend_erp/internal/repository"
+)
+
+// Store — общее состояние всех хранилищ; безопасен для конкурентного доступа.
+type Store struct {
+	mu     sync.Mutex
+	nextID int64
+	// replenishing — блокировка расчета пополнения
+	replenishing sync.Mutex
+
+	orgs       map[int64]models.Organization
+	categories map[int64]models.WarehouseCategory
+	machines   map[int64]models.VendingMachine
+	locations  map[int64]models.Location
+	operations map[int64]models.VendingOperation
+	planogram  map[int64]models.PlanogramItem
+	products   map[int64]models.Product
+	warehouses map[int64]models.Warehouse
+	zones      map[int64]models.WarehouseZone
+	items      map[int64]models.WarehouseInventory
+	supplies   map[int64]models.WarehouseSupply
+	stocktakes map[int64]models.Stocktake
+	reserves   map[int64]models.StockReservation
+	users      map[int64]*user
+	members    map[membership]bool
+	sessions   map[string]models.Session
+	invites    map[int64]invite
+
+	// Журнал движений товара в порядке записи
+	movements []models.StockMovement
+	// Партии товара в порядке прихода на склад
+	lots []models.StockLot
+}
+
+type user struct {
+	models.User
+	passwordHash string
+	approvedBy   int64
+	// Связанная учетная запись OpenID Connect
+	oidcIssuer, oidcSubject string
+}
+
+type invite struct {
+	models.Invite
+	tokenHash string
+}
+
+type membership struct {
+	userID, orgID int64
+}
+
+// New возвращает пустое хранилище.
+func New() *Store {
+	return &Store{
+		orgs:       make(map[int64]models.Organization),
+		categories: make(map[int64]models.WarehouseCategory),
+		machines:   make(map[int64]models.VendingMachine),
+		locations:  make(map[int64]models.Location),
+		operations: make(map[int64]models.VendingOperation),
+		planogram:  make(map[int64]models.PlanogramItem),
+		products:   make(map[int64]models.Product),
+		warehouses: make(map[int64]models.Warehouse),
+		zones:      make(map[int64]models.WarehouseZone),
+		items:      make(map[int64]models.WarehouseInventory),
+		supplies:   make(map[int64]models.WarehouseSupply),
+		stocktakes: make(map[int64]models.Stocktake),
+		reserves:   make(map[int64]models.StockReservation),
+		users:      make(map[int64]*user),
+		members:    make(map[membership]bool),
+		sessions:   make(map[string]models.Session),
+		invites:    make(map[int64]invite),
+	}
+}
+
+// Repos возвращает хранилища всех агрегатов поверх этого состояния.
+func (s *Store) Repos() repository.Repos {
+	return repository.Repos{
+		Machines:      machines{s},
+		Locations:     locations{s},
+		Operations:    operations{s},
+		Products:      products{s},
+		Inventory:     inventory{s},
+		Supplies:      supplies{s},
+		Stocktakes:    stocktakes{s},
+		Users:         users{s},
+		Sessions:      sessions{s},
+		Organizations: organizations{s},
+		Invites:       invites{s},
+		Reports:       reports{s},
+	}
+}
+
+// AddOrganization регистрирует активную организацию с кодом org-<ID>
+// и оценкой запасов по FIFO — короткая форма Organizations.Create для
+// подготовки тестов.
+func (s *Store) AddOrganization(name string) int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	id := s.id()
+	now := time.Now()
+	s.orgs[id] = models.Organization{
+		ID: id, Name: name, Slug: fmt.Sprintf("org-%d", id), IsActive: true,
+		CostingMethod: repository.CostFIFO, CreatedAt: now, UpdatedAt: now,
+	}
+	return id
+}
+
+// SetCostingMethod меняет метод оценки запасов организации
+// (repository.CostFIFO, repository.CostAverage) без остальных полей,
+// которые перезаписал бы Organizations.Update.
+func (s *Store) SetCostingMethod(orgID int64, method string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if org, ok := s.orgs[orgID]; ok {
+		org.CostingMethod = method
+		s.orgs[orgID] = org
+	}
+}
+
+// SetSuperAdmin выдает или снимает права суперадмина; в PostgreSQL
+// это делает команда seed superadmin.
+func (s *Store) SetSuperAdmin(userID int64, grant bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if u, ok := s.users[userID]; ok {
+		u.IsSuperAdmin = grant
+	}
+}
+
+// AddCategory добавляет категорию склада; в PostgreSQL их создают миграции.
+func (s *Store) AddCategory(name string) int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	id := s.id()
+	s.categories[id] = models.WarehouseCategory{ID: id, Name: name, CreatedAt: time.Now()}
+	return id
+}
+
+// id выдает следующий идентификатор; вызывается под s.mu. Последовательность
+// общая для всех таблиц, поэтому перепутанные ID разных сущностей не совпадают.
+func (s *Store) id() int64 {
+	s.nextID++
+	return s.nextID
+}
+
+// newestFirst упорядочивает по убыванию времени создания, затем ID.
+func newestFirst(a, b time.Time, idA, idB int64) bool {
+	if !a.Equal(b) {
+		return a.After(b)
+	}
+	return idA > idB
+}
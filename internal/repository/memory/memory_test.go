@@ -0,0 +1,11 @@
+package memory_test
+
+import (
+	"testing"
+
+	"vend_erp/internal/repository/repotest"
+)
+
+func TestMemoryRepos(t *testing.T) {
+	repotest.Run(t, repotest.Memory)
+}
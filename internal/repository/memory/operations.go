@@ -0,0 +1,353 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"time"
+
+	"vend_erp/internal/models"
+	"vend_erp/internal/money"
+	"vend_erp/internal/repository"
+)
+
+type operations struct {
+	s *Store
+}
+
+func (s *Store) operation(op models.VendingOperation) models.VendingOperation {
+	op.MachineSerial = s.machines[op.VendingMachineID].SerialNumber
+	if u, ok := s.users[op.PerformedBy]; ok {
+		op.PerformerName = u.Username
+	}
+	op.WarehouseName = s.warehouses[op.WarehouseID].Name
+	op.OrgName = s.orgs[op.OrgID].Name
+	op.Items = nil
+	return op
+}
+
+func (r operations) List(ctx context.Context, scope repository.Scope) ([]models.VendingOperation, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	var list []models.VendingOperation
+	for _, op := range r.s.operations {
+		if scope.Includes(op.OrgID) {
+			list = append(list, r.s.operation(op))
+		}
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return newestFirst(list[i].OperationDate, list[j].OperationDate, list[i].ID, list[j].ID)
+	})
+	return list, nil
+}
+
+func (r operations) Get(ctx context.Context, scope repository.Scope, id int64) (models.VendingOperation, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	op, ok := r.s.operations[id]
+	if !ok || !scope.Includes(op.OrgID) {
+		return models.VendingOperation{}, repository.ErrNotFound
+	}
+	items := make([]models.OperationItem, 0, len(op.Items))
+	for _, item := range op.Items {
+		p := r.s.products[item.ProductID]
+		item.ProductName = p.Name
+		item.SKU = p.SKU
+		items = append(items, item)
+	}
+	sort.Slice(items, func(i, j int) bool {
+		if items[i].ProductName != items[j].ProductName {
+			return items[i].ProductName < items[j].ProductName
+		}
+		return items[i].ID < items[j].ID
+	})
+	op = r.s.operation(op)
+	if len(items) > 0 {
+		op.Items = items
+	}
+	return op, nil
+}
+
+// operationItems проверяет товары пополнения и копирует их с новыми ID,
+// как их записывает saveItems в postgres.
+func (s *Store) operationItems(operationID, orgID int64, items []models.OperationItem) ([]models.OperationItem, error) {
+	var saved []models.OperationItem
+	seen := make(map[int64]bool, len(items))
+	for _, item := range items {
+		if p, ok := s.products[item.ProductID]; !ok || p.OrgID != orgID {
+			return nil, repository.ErrNotFound
+		}
+		if seen[item.ProductID] {
+			return nil, repository.ErrDuplicate
+		}
+		seen[item.ProductID] = true
+		item.ID = s.id()
+		item.OperationID = operationID
+		item.ProductName, item.SKU = "", ""
+		saved = append(saved, item)
+	}
+	return saved, nil
+}
+
+func (r operations) Create(ctx context.Context, operation *models.VendingOperation) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	operation.ID = r.s.id()
+	items, err := r.s.operationItems(operation.ID, operation.OrgID, operation.Items)
+	if err != nil {
+		operation.ID = 0
+		return err
+	}
+	operation.Version = 1
+	operation.CreatedAt = time.Now()
+	operation.UpdatedAt = operation.CreatedAt
+	saved := *operation
+	saved.Items = items
+	saved.MachineSerial, saved.PerformerName, saved.WarehouseName, saved.OrgName = "", "", "", ""
+	if err := r.s.issueRestock(saved, false); err != nil {
+		operation.ID = 0
+		return err
+	}
+	r.s.operations[operation.ID] = saved
+	return nil
+}
+
+func (r operations) Update(ctx context.Context, scope repository.Scope, operation models.VendingOperation) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	current, ok := r.s.operations[operation.ID]
+	if !ok || !scope.Includes(current.OrgID) {
+		return repository.ErrNotFound
+	}
+	if current.Version != operation.Version {
+		return repository.ErrConflict
+	}
+	items, err := r.s.operationItems(operation.ID, current.OrgID, operation.Items)
+	if err != nil {
+		return err
+	}
+	operation.Items = items
+	operation.OrgID = current.OrgID
+	operation.CreatedAt = current.CreatedAt
+	operation.Version = current.Version + 1
+	operation.UpdatedAt = time.Now()
+	operation.MachineSerial, operation.PerformerName, operation.WarehouseName, operation.OrgName = "", "", "", ""
+	if err := r.s.issueRestock(operation, false); err != nil {
+		return err
+	}
+	r.s.operations[operation.ID] = operation
+	return nil
+}
+
+func (r operations) Delete(ctx context.Context, scope repository.Scope, id int64) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	op, ok := r.s.operations[id]
+	if !ok || !scope.Includes(op.OrgID) {
+		return nil
+	}
+	if err := r.s.issueRestock(op, true); err != nil {
+		return err
+	}
+	delete(r.s.operations, id)
+	for i := range r.s.movements {
+		if r.s.movements[i].OperationID == id {
+			r.s.movements[i].OperationID = 0
+		}
+	}
+	return nil
+}
+
+// issuedStock — выданное операцией с позиции склада.
+type issuedStock struct {
+	quantity int
+	cost     money.Amount
+}
+
+// issueRestock доводит выданное со склада по операции op до ее
+// пополнения, как issueRestock в postgres. Сначала проверяется, что
+// товара хватит, и только затем меняются остатки: отката, как
+// у транзакции, здесь нет.
+func (s *Store) issueRestock(op models.VendingOperation, remove bool) error {
+	issued := make(map[int64]issuedStock)
+	for _, m := range s.movements {
+		if m.OperationID == op.ID {
+			stock := issued[m.ItemID]
+			stock.quantity -= m.Quantity
+			stock.cost -= m.Cost
+			issued[m.ItemID] = stock
+		}
+	}
+
+	target := make(map[int64]int)
+	if !remove && op.WarehouseID != 0 {
+		if w, ok := s.warehouses[op.WarehouseID]; !ok || w.OrgID != op.OrgID {
+			return repository.ErrNotFound
+		}
+	}
+	if !remove && op.OperationType == "restock" && op.WarehouseID != 0 {
+		for _, oi := range op.Items {
+			if oi.Added == 0 {
+				continue
+			}
+			var itemID int64
+			for id, item := range s.items {
+				if item.WarehouseID == op.WarehouseID && item.ProductID == oi.ProductID {
+					itemID = id
+				}
+			}
+			if itemID == 0 {
+				return repository.ErrInsufficientStock
+			}
+			target[itemID] += oi.Added
+		}
+	}
+
+	var ids []int64
+	for itemID := range issued {
+		ids = append(ids, itemID)
+	}
+	for itemID := range target {
+		if _, ok := issued[itemID]; !ok {
+			ids = append(ids, itemID)
+		}
+	}
+	slices.Sort(ids)
+	for _, itemID := range ids {
+		diff := target[itemID] - issued[itemID].quantity
+		if diff <= 0 {
+			continue
+		}
+		held := 0
+		for _, res := range s.restockReserves(itemID, op.VendingMachineID) {
+			held += res.Quantity
+		}
+		if diff > max(s.items[itemID].Quantity-s.reserved(itemID, 0), 0)+held {
+			return repository.ErrInsufficientStock
+		}
+	}
+
+	reason := fmt.Sprintf("Операция №%d", op.ID)
+	for _, itemID := range ids {
+		back := issued[itemID]
+		diff := target[itemID] - back.quantity
+		switch {
+		case diff < 0:
+			s.move(itemID, models.StockMovement{
+				Type: repository.MovementRestock, Quantity: -diff, Cost: back.cost.Share(-diff, back.quantity),
+				VendingMachineID: op.VendingMachineID, OperationID: op.ID, Reason: reason + ": возврат на склад",
+			}, models.StockLot{UnitCost: back.cost.Share(1, back.quantity), Quantity: -diff})
+		case diff > 0:
+			reserves := s.restockReserves(itemID, op.VendingMachineID)
+			available := max(s.items[itemID].Quantity-s.reserved(itemID, 0), 0)
+			m, _, err := s.move(itemID, models.StockMovement{
+				Type: repository.MovementRestock, Quantity: -diff,
+				VendingMachineID: op.VendingMachineID, OperationID: op.ID, Reason: reason,
+			})
+			if err != nil {
+				return err
+			}
+			s.takeReserves(reserves, diff, available, m.ID)
+		default:
+			continue
+		}
+		s.updateUsage(s.items[itemID].WarehouseID)
+	}
+	return nil
+}
+
+func (r operations) Sales(ctx context.Context, scope repository.Scope, from, to time.Time) ([]models.ProductSales, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	// Пополнения каждого автомата по порядку, по всем датам
+	restocks := make(map[int64][]models.VendingOperation)
+	for _, op := range r.s.operations {
+		if op.OperationType == "restock" && scope.Includes(op.OrgID) {
+			restocks[op.VendingMachineID] = append(restocks[op.VendingMachineID], op)
+		}
+	}
+
+	type key struct{ machineID, productID int64 }
+	totals := make(map[key]*models.ProductSales)
+	for machineID, ops := range restocks {
+		sort.Slice(ops, func(i, j int) bool {
+			return newestFirst(ops[j].OperationDate, ops[i].OperationDate, ops[j].ID, ops[i].ID)
+		})
+		for i := 1; i < len(ops); i++ {
+			cur := ops[i]
+			if cur.OperationDate.Before(from) || !cur.OperationDate.Before(to) {
+				continue
+			}
+			prev := make(map[int64]models.OperationItem)
+			for _, item := range ops[i-1].Items {
+				prev[item.ProductID] = item
+			}
+			for _, item := range cur.Items {
+				before, ok := prev[item.ProductID]
+				if !ok {
+					continue
+				}
+				sold := max(before.CountAfter()-item.CountBefore, 0)
+				k := key{machineID, item.ProductID}
+				if totals[k] == nil {
+					totals[k] = &models.ProductSales{ProductID: item.ProductID}
+				}
+				totals[k].Sold += sold
+				totals[k].PlayValue += before.PricePerPlay.Mul(sold)
+			}
+		}
+	}
+
+	// Автоматы одной локации складываются
+	byLocation := make(map[key]*models.ProductSales)
+	var list []*models.ProductSales
+	for k, sales := range totals {
+		m := r.s.machines[k.machineID]
+		lk := key{m.LocationID, k.productID}
+		if m.LocationID == 0 {
+			// Автоматы без локации разных организаций не смешиваются
+			lk = key{-m.OrgID, k.productID}
+		}
+		total, ok := byLocation[lk]
+		if !ok {
+			p := r.s.products[k.productID]
+			location := r.s.machine(m)
+			total = &models.ProductSales{
+				LocationID: m.LocationID, LocationName: location.LocationName, OrgName: location.OrgName,
+				ProductID: p.ID, ProductName: p.Name, SKU: p.SKU,
+			}
+			byLocation[lk] = total
+			list = append(list, total)
+		}
+		total.Sold += sales.Sold
+		total.PlayValue += sales.PlayValue
+	}
+
+	var result []models.ProductSales
+	for _, sales := range list {
+		if sales.Sold > 0 {
+			result = append(result, *sales)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		a, b := result[i], result[j]
+		if a.LocationName != b.LocationName {
+			return a.LocationName < b.LocationName
+		}
+		if a.LocationID != b.LocationID {
+			return a.LocationID < b.LocationID
+		}
+		if a.Sold != b.Sold {
+			return a.Sold > b.Sold
+		}
+		return a.ProductName < b.ProductName
+	})
+	return result, nil
+}
@@ -0,0 +1,126 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"vend_erp/internal/models"
+	"vend_erp/internal/repository"
+)
+
+type organizations struct {
+	s *Store
+}
+
+// byName упорядочивает организации по названию, затем ID.
+func byName(list []models.Organization) {
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Name != list[j].Name {
+			return list[i].Name < list[j].Name
+		}
+		return list[i].ID < list[j].ID
+	})
+}
+
+// slugTaken повторяет UNIQUE(slug).
+func (s *Store) slugTaken(slug string, exceptID int64) bool {
+	for id, org := range s.orgs {
+		if id != exceptID && org.Slug == slug {
+			return true
+		}
+	}
+	return false
+}
+
+func (r organizations) List(ctx context.Context) ([]models.Organization, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	var list []models.Organization
+	for _, org := range r.s.orgs {
+		list = append(list, org)
+	}
+	byName(list)
+	return list, nil
+}
+
+func (r organizations) Member(ctx context.Context, userID int64) ([]models.Organization, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	var list []models.Organization
+	for _, org := range r.s.orgs {
+		if org.IsActive && r.s.members[membership{userID, org.ID}] {
+			list = append(list, org)
+		}
+	}
+	byName(list)
+	return list, nil
+}
+
+func (r organizations) Get(ctx context.Context, id int64) (models.Organization, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	org, ok := r.s.orgs[id]
+	if !ok {
+		return models.Organization{}, repository.ErrNotFound
+	}
+	return org, nil
+}
+
+func (r organizations) FindBySlug(ctx context.Context, slug string) (models.Organization, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	for _, org := range r.s.orgs {
+		if org.Slug == slug {
+			return org, nil
+		}
+	}
+	return models.Organization{}, repository.ErrNotFound
+}
+
+func (r organizations) Create(ctx context.Context, org *models.Organization) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	if r.s.slugTaken(org.Slug, 0) {
+		return repository.ErrDuplicate
+	}
+	org.ID = r.s.id()
+	org.CreatedAt = time.Now()
+	org.UpdatedAt = org.CreatedAt
+	r.s.orgs[org.ID] = *org
+	return nil
+}
+
+func (r organizations) Update(ctx context.Context, org models.Organization) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	current, ok := r.s.orgs[org.ID]
+	if !ok {
+		return repository.ErrNotFound
+	}
+	if current.Slug == repository.DefaultOrganization {
+		org.Slug = current.Slug
+		org.IsActive = true
+	}
+	if r.s.slugTaken(org.Slug, org.ID) {
+		return repository.ErrDuplicate
+	}
+	org.CreatedAt = current.CreatedAt
+	org.UpdatedAt = time.Now()
+	r.s.orgs[org.ID] = org
+	return nil
+}
+
+func (r organizations) CanSwitch(ctx context.Context, userID, orgID int64, superAdmin bool) (bool, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	org, ok := r.s.orgs[orgID]
+	return ok && org.IsActive && (superAdmin || r.s.members[membership{userID, orgID}]), nil
+}
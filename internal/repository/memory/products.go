@@ -0,0 +1,176 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"vend_erp/internal/models"
+	"vend_erp/internal/repository"
+)
+
+type products struct {
+	s *Store
+}
+
+// product дополняет товар названиями категории и организации и остатком
+// на всех складах.
+func (s *Store) product(p models.Product) models.Product {
+	p.CategoryName = s.categories[p.CategoryID].Name
+	p.OrgName = s.orgs[p.OrgID].Name
+	p.Stock = 0
+	for _, item := range s.items {
+		if item.ProductID == p.ID {
+			p.Stock += item.Quantity
+		}
+	}
+	return p
+}
+
+func sortProducts(list []models.Product) {
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Name != list[j].Name {
+			return list[i].Name < list[j].Name
+		}
+		return list[i].ID < list[j].ID
+	})
+}
+
+func (r products) List(ctx context.Context, scope repository.Scope) ([]models.Product, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	var list []models.Product
+	for _, p := range r.s.products {
+		if scope.Includes(p.OrgID) {
+			list = append(list, r.s.product(p))
+		}
+	}
+	sortProducts(list)
+	return list, nil
+}
+
+func (r products) Get(ctx context.Context, scope repository.Scope, id int64) (models.Product, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	p, ok := r.s.products[id]
+	if !ok || !scope.Includes(p.OrgID) {
+		return models.Product{}, repository.ErrNotFound
+	}
+	return r.s.product(p), nil
+}
+
+func (r products) ListActive(ctx context.Context, orgID int64) ([]models.Product, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	var list []models.Product
+	for _, p := range r.s.products {
+		if p.OrgID == orgID && p.IsActive {
+			list = append(list, r.s.product(p))
+		}
+	}
+	sortProducts(list)
+	return list, nil
+}
+
+// productTaken повторяет UNIQUE(org_id, sku) и уникальный индекс штрихкодов
+// организации: пустой штрихкод хранится как NULL и не занят.
+func (s *Store) productTaken(p models.Product) bool {
+	for id, other := range s.products {
+		if id == p.ID || other.OrgID != p.OrgID {
+			continue
+		}
+		if other.SKU == p.SKU || (p.Barcode != "" && other.Barcode == p.Barcode) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r products) Create(ctx context.Context, product *models.Product) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	product.ID = 0
+	if r.s.productTaken(*product) {
+		return repository.ErrDuplicate
+	}
+	if product.UnitVolume <= 0 {
+		product.UnitVolume = 1
+	}
+	if product.PackSize <= 0 {
+		product.PackSize = 1
+	}
+	product.ID = r.s.id()
+	product.Version = 1
+	product.CreatedAt = time.Now()
+	product.UpdatedAt = product.CreatedAt
+	r.s.products[product.ID] = *product
+	return nil
+}
+
+func (r products) Update(ctx context.Context, scope repository.Scope, product models.Product) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	current, ok := r.s.products[product.ID]
+	if !ok || !scope.Includes(current.OrgID) {
+		return repository.ErrNotFound
+	}
+	if current.Version != product.Version {
+		return repository.ErrConflict
+	}
+	product.OrgID = current.OrgID
+	if r.s.productTaken(product) {
+		return repository.ErrDuplicate
+	}
+	if product.UnitVolume <= 0 {
+		product.UnitVolume = 1
+	}
+	if product.PackSize <= 0 {
+		product.PackSize = 1
+	}
+	product.CreatedAt = current.CreatedAt
+	product.Version = current.Version + 1
+	product.UpdatedAt = time.Now()
+	r.s.products[product.ID] = product
+	// Объем единицы меняет загрузку складов, где лежит товар
+	for _, item := range r.s.items {
+		if item.ProductID == product.ID {
+			r.s.updateUsage(item.WarehouseID)
+		}
+	}
+	return nil
+}
+
+func (r products) Delete(ctx context.Context, scope repository.Scope, id int64) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	p, ok := r.s.products[id]
+	if !ok || !scope.Includes(p.OrgID) {
+		return nil
+	}
+	// Позиции складов, выкладки и пополнения ссылаются на товар без каскада
+	for _, item := range r.s.items {
+		if item.ProductID == id {
+			return repository.ErrInUse
+		}
+	}
+	for _, item := range r.s.planogram {
+		if item.ProductID == id {
+			return repository.ErrInUse
+		}
+	}
+	for _, op := range r.s.operations {
+		for _, item := range op.Items {
+			if item.ProductID == id {
+				return repository.ErrInUse
+			}
+		}
+	}
+	delete(r.s.products, id)
+	return nil
+}
@@ -0,0 +1,229 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"vend_erp/internal/models"
+	"vend_erp/internal/money"
+	"vend_erp/internal/repository"
+)
+
+type reports struct {
+	s *Store
+}
+
+// day возвращает дату t без времени, как ее хранит столбец DATE.
+func day(t time.Time) time.Time {
+	y, m, d := t.Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
+
+// days возвращает даты от from до to включительно.
+func days(from, to time.Time) []time.Time {
+	var list []time.Time
+	for d := day(from); !d.After(day(to)); d = d.AddDate(0, 0, 1) {
+		list = append(list, d)
+	}
+	return list
+}
+
+func (r reports) Machines(ctx context.Context, scope repository.Scope) (repository.MachineTotals, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	var totals repository.MachineTotals
+	for _, m := range r.s.machines {
+		if !scope.Includes(m.OrgID) {
+			continue
+		}
+		totals.Count++
+		if m.Status == "active" {
+			totals.Active++
+		}
+		totals.Cash += m.CashAmount
+		totals.Toys += m.CurrentToysCount
+	}
+	return totals, nil
+}
+
+func (r reports) OperationCounts(ctx context.Context, scope repository.Scope) (map[string]int, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	counts := make(map[string]int)
+	for _, op := range r.s.operations {
+		if scope.Includes(op.OrgID) {
+			counts[op.OperationType]++
+		}
+	}
+	return counts, nil
+}
+
+// activeItems возвращает позиции активных складов области.
+func (s *Store) activeItems(scope repository.Scope) []models.WarehouseInventory {
+	var list []models.WarehouseInventory
+	for _, item := range s.items {
+		if w := s.warehouses[item.WarehouseID]; w.IsActive && scope.Includes(w.OrgID) {
+			list = append(list, s.item(item))
+		}
+	}
+	return list
+}
+
+func (r reports) Warehouses(ctx context.Context, scope repository.Scope) (repository.WarehouseTotals, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	var totals repository.WarehouseTotals
+	for _, item := range r.s.activeItems(scope) {
+		totals.Value += item.StockValue
+		switch {
+		case item.Quantity == 0:
+			totals.OutOfStock++
+		case item.Quantity < item.MinStockLevel:
+			totals.LowStock++
+		}
+	}
+	for _, w := range r.s.warehouses {
+		if w.IsActive && scope.Includes(w.OrgID) {
+			totals.Warehouses++
+		}
+	}
+	return totals, nil
+}
+
+func (r reports) StockByType(ctx context.Context, scope repository.Scope) ([]repository.TypeCount, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	counts := make(map[string]int)
+	for _, item := range r.s.activeItems(scope) {
+		counts[item.ItemType]++
+	}
+	var types []repository.TypeCount
+	for itemType, count := range counts {
+		types = append(types, repository.TypeCount{ItemType: itemType, Count: count})
+	}
+	sort.Slice(types, func(i, j int) bool {
+		if types[i].Count != types[j].Count {
+			return types[i].Count > types[j].Count
+		}
+		return types[i].ItemType < types[j].ItemType
+	})
+	return types, nil
+}
+
+func (r reports) LowStock(ctx context.Context, scope repository.Scope, limit int) ([]models.WarehouseInventory, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	var list []models.WarehouseInventory
+	for _, item := range r.s.activeItems(scope) {
+		if item.Quantity > 0 && item.Quantity < item.MinStockLevel {
+			list = append(list, item)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool {
+		a, b := list[i], list[j]
+		if shortA, shortB := a.MinStockLevel-a.Quantity, b.MinStockLevel-b.Quantity; shortA != shortB {
+			return shortA > shortB
+		}
+		return a.ID < b.ID
+	})
+	if len(list) > limit {
+		list = list[:limit]
+	}
+	return list, nil
+}
+
+func (r reports) MachineHistory(ctx context.Context, scope repository.Scope, from, to time.Time) ([]repository.MachineDay, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	var history []repository.MachineDay
+	for _, d := range days(from, to) {
+		point := repository.MachineDay{Date: d}
+		for _, m := range r.s.machines {
+			if !scope.Includes(m.OrgID) || day(m.CreatedAt).After(d) {
+				continue
+			}
+			point.Machines++
+			// Автомат без статуса считается активным, как status IS NULL
+			if m.Status == "active" || m.Status == "" {
+				point.Active++
+			}
+			point.Cash += m.CashAmount
+			point.Toys += m.CurrentToysCount
+		}
+		history = append(history, point)
+	}
+	return history, nil
+}
+
+func (r reports) OperationHistory(ctx context.Context, scope repository.Scope, from, to time.Time) ([]repository.OperationDay, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	type key struct {
+		date   time.Time
+		opType string
+	}
+	counts := make(map[key]int)
+	for _, op := range r.s.operations {
+		d := day(op.CreatedAt)
+		if scope.Includes(op.OrgID) && !d.Before(day(from)) && !d.After(day(to)) {
+			counts[key{d, op.OperationType}]++
+		}
+	}
+	var history []repository.OperationDay
+	for k, count := range counts {
+		history = append(history, repository.OperationDay{Date: k.date, OperationType: k.opType, Count: count})
+	}
+	sort.Slice(history, func(i, j int) bool {
+		a, b := history[i], history[j]
+		if !a.Date.Equal(b.Date) {
+			return a.Date.Before(b.Date)
+		}
+		return a.OperationType < b.OperationType
+	})
+	return history, nil
+}
+
+func (r reports) Revenue(ctx context.Context, scope repository.Scope, from, to time.Time) ([]repository.AmountDay, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	revenue := make(map[time.Time]money.Amount)
+	for _, op := range r.s.operations {
+		d := day(op.OperationDate)
+		if op.OperationType == "collection" && scope.Includes(op.OrgID) && !d.Before(day(from)) && !d.After(day(to)) {
+			revenue[d] += op.CashCollected
+		}
+	}
+	var history []repository.AmountDay
+	for d, amount := range revenue {
+		history = append(history, repository.AmountDay{Date: d, Amount: amount})
+	}
+	sort.Slice(history, func(i, j int) bool { return history[i].Date.Before(history[j].Date) })
+	return history, nil
+}
+
+func (r reports) StockValue(ctx context.Context, scope repository.Scope, from, to time.Time) ([]repository.AmountDay, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	var history []repository.AmountDay
+	for _, d := range days(from, to) {
+		point := repository.AmountDay{Date: d}
+		end := d.AddDate(0, 0, 1)
+		for _, m := range r.s.movements {
+			if scope.Includes(r.s.warehouses[m.WarehouseID].OrgID) && day(m.CreatedAt).Before(end) {
+				point.Amount += m.Cost
+			}
+		}
+		history = append(history, point)
+	}
+	return history, nil
+}
@@ -0,0 +1,163 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"vend_erp/internal/models"
+	"vend_erp/internal/repository"
+)
+
+// active сообщает, что резерв действует на момент now: не израсходован,
+// не снят и не истек.
+func active(res models.StockReservation, now time.Time) bool {
+	return res.Status == repository.ReservationActive && res.ExpiresAt.After(now)
+}
+
+// reserved возвращает, сколько товара позиции отложено действующими
+// резервами, кроме резерва exceptID.
+func (s *Store) reserved(itemID, exceptID int64) int {
+	now := time.Now()
+	var total int
+	for _, res := range s.reserves {
+		if res.ItemID == itemID && res.ID != exceptID && active(res, now) {
+			total += res.Quantity
+		}
+	}
+	return total
+}
+
+func (r inventory) Reservations(ctx context.Context, scope repository.Scope, itemID int64) ([]models.StockReservation, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	var list []models.StockReservation
+	if _, err := r.s.visibleItem(scope, itemID); err != nil {
+		return list, nil
+	}
+	now := time.Now()
+	for _, res := range r.s.reserves {
+		if res.ItemID != itemID || !active(res, now) {
+			continue
+		}
+		res.MachineSerial = r.s.machines[res.VendingMachineID].SerialNumber
+		if u, ok := r.s.users[res.CreatedBy]; ok {
+			res.CreatedByName = u.Username
+		}
+		list = append(list, res)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if !list[i].ExpiresAt.Equal(list[j].ExpiresAt) {
+			return list[i].ExpiresAt.Before(list[j].ExpiresAt)
+		}
+		return list[i].ID < list[j].ID
+	})
+	return list, nil
+}
+
+func (r inventory) Reserve(ctx context.Context, scope repository.Scope, reservation *models.StockReservation) error {
+	if reservation.Type != repository.MovementShipment && reservation.Type != repository.MovementRestock {
+		return fmt.Errorf("unknown reservation type %q", reservation.Type)
+	}
+	if reservation.Quantity <= 0 {
+		return fmt.Errorf("reservation quantity must be positive, got %d", reservation.Quantity)
+	}
+
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	item, err := r.s.visibleItem(scope, reservation.ItemID)
+	if err != nil {
+		return err
+	}
+	if reservation.VendingMachineID != 0 {
+		if m, ok := r.s.machines[reservation.VendingMachineID]; !ok || m.OrgID != item.OrgID {
+			return repository.ErrNotFound
+		}
+	}
+	if item.Available() < reservation.Quantity {
+		return repository.ErrInsufficientStock
+	}
+
+	reservation.ID = r.s.id()
+	reservation.Status = repository.ReservationActive
+	reservation.MovementID = 0
+	reservation.CreatedAt = time.Now()
+	stored := *reservation
+	stored.MachineSerial, stored.CreatedByName = "", ""
+	r.s.reserves[reservation.ID] = stored
+	return nil
+}
+
+func (r inventory) Release(ctx context.Context, scope repository.Scope, id int64) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	res, ok := r.s.reserves[id]
+	if !ok || !active(res, time.Now()) {
+		return nil
+	}
+	if _, err := r.s.visibleItem(scope, res.ItemID); err != nil {
+		return nil
+	}
+	res.Status = repository.ReservationReleased
+	r.s.reserves[id] = res
+	return nil
+}
+
+// restockReserves возвращает резервы позиции, из которых может взять
+// пополнение автомата machineID: сначала резервы автомата, затем рейсов
+// без автомата, ближайшие к сроку первыми.
+func (s *Store) restockReserves(itemID, machineID int64) []models.StockReservation {
+	now := time.Now()
+	var list []models.StockReservation
+	for _, res := range s.reserves {
+		if res.ItemID == itemID && res.Type == repository.MovementRestock && active(res, now) &&
+			(res.VendingMachineID == machineID || res.VendingMachineID == 0) {
+			list = append(list, res)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool {
+		a, b := list[i], list[j]
+		if (a.VendingMachineID == 0) != (b.VendingMachineID == 0) {
+			return a.VendingMachineID != 0
+		}
+		if !a.ExpiresAt.Equal(b.ExpiresAt) {
+			return a.ExpiresAt.Before(b.ExpiresAt)
+		}
+		return a.ID < b.ID
+	})
+	return list
+}
+
+// takeReserves расходует резервы пополнения, как takeReserves в postgres.
+func (s *Store) takeReserves(reserves []models.StockReservation, quantity, available int, movementID int64) {
+	consume := func(res models.StockReservation) {
+		res.Status = repository.ReservationConsumed
+		res.MovementID = movementID
+		s.reserves[res.ID] = res
+	}
+	rest := quantity
+	for _, res := range reserves {
+		if res.VendingMachineID != 0 {
+			rest -= res.Quantity
+			consume(res)
+		}
+	}
+	rest -= available
+	for _, res := range reserves {
+		if res.VendingMachineID != 0 || rest <= 0 {
+			continue
+		}
+		taken := min(res.Quantity, rest)
+		rest -= taken
+		if taken == res.Quantity {
+			consume(res)
+			continue
+		}
+		res.Quantity -= taken
+		s.reserves[res.ID] = res
+	}
+}
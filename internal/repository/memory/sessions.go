@@ -0,0 +1,80 @@
+package memory
+
+import (
+	"context"
+	"time"
+
+	"vend_erp/internal/models"
+	"vend_erp/internal/repository"
+)
+
+type sessions struct {
+	s *Store
+}
+
+func (r sessions) Create(ctx context.Context, session models.Session) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	if _, ok := r.s.sessions[session.ID]; ok {
+		return repository.ErrDuplicate
+	}
+	r.s.sessions[session.ID] = session
+	return nil
+}
+
+func (r sessions) Get(ctx context.Context, id string) (models.Session, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	session, ok := r.s.sessions[id]
+	if !ok || !session.ExpiresAt.After(time.Now()) {
+		return models.Session{}, repository.ErrNotFound
+	}
+	return session, nil
+}
+
+func (r sessions) update(id string, userID int64, change func(*models.Session)) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	session, ok := r.s.sessions[id]
+	if !ok || session.UserID != userID {
+		return repository.ErrNotFound
+	}
+	change(&session)
+	r.s.sessions[id] = session
+	return nil
+}
+
+func (r sessions) SetActiveOrg(ctx context.Context, id string, userID, orgID int64) error {
+	return r.update(id, userID, func(session *models.Session) {
+		session.ActiveOrgID = orgID
+		session.AllOrgs = false
+	})
+}
+
+func (r sessions) SetAllOrgs(ctx context.Context, id string, userID int64) error {
+	return r.update(id, userID, func(session *models.Session) {
+		session.AllOrgs = true
+	})
+}
+
+func (r sessions) Delete(ctx context.Context, id string) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	delete(r.s.sessions, id)
+	return nil
+}
+
+func (r sessions) DeleteOthers(ctx context.Context, userID int64, keepID string) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	for id, session := range r.s.sessions {
+		if session.UserID == userID && id != keepID {
+			delete(r.s.sessions, id)
+		}
+	}
+	return nil
+}
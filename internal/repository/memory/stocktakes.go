@@ -0,0 +1,243 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"vend_erp/internal/models"
+	"vend_erp/internal/repository"
+)
+
+type stocktakes struct {
+	s *Store
+}
+
+// stocktake дополняет инвентаризацию полями склада, организации
+// и пользователей, счетчиками позиций и данными товаров в позициях.
+func (s *Store) stocktake(st models.Stocktake) models.Stocktake {
+	w := s.warehouses[st.WarehouseID]
+	st.WarehouseName = w.Name
+	st.OrgID = w.OrgID
+	st.OrgName = s.orgs[w.OrgID].Name
+	st.CreatedByName = ""
+	if u, ok := s.users[st.CreatedBy]; ok {
+		st.CreatedByName = u.Username
+	}
+	st.ApprovedByName = ""
+	if u, ok := s.users[st.ApprovedBy]; ok {
+		st.ApprovedByName = u.Username
+	}
+	lines := make([]models.StocktakeLine, len(st.Lines))
+	st.LineCount, st.CountedCount = len(st.Lines), 0
+	for i, line := range st.Lines {
+		lines[i] = s.stocktakeLine(line)
+		if line.Counted {
+			st.CountedCount++
+		}
+	}
+	sort.Slice(lines, func(i, j int) bool {
+		if lines[i].ItemName != lines[j].ItemName {
+			return lines[i].ItemName < lines[j].ItemName
+		}
+		return lines[i].ID < lines[j].ID
+	})
+	st.Lines = lines
+	return st
+}
+
+func (s *Store) stocktakeLine(line models.StocktakeLine) models.StocktakeLine {
+	p := s.products[s.items[line.ItemID].ProductID]
+	line.ItemName = p.Name
+	line.SKU = p.SKU
+	line.Barcode = p.Barcode
+	return line
+}
+
+func (r stocktakes) List(ctx context.Context, scope repository.Scope) ([]models.Stocktake, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	var list []models.Stocktake
+	for _, st := range r.s.stocktakes {
+		if scope.Includes(r.s.warehouses[st.WarehouseID].OrgID) {
+			st = r.s.stocktake(st)
+			st.Lines = nil
+			list = append(list, st)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool {
+		a, b := list[i], list[j]
+		if openA, openB := a.Status == repository.StocktakeCounting, b.Status == repository.StocktakeCounting; openA != openB {
+			return openA
+		}
+		return newestFirst(a.CreatedAt, b.CreatedAt, a.ID, b.ID)
+	})
+	return list, nil
+}
+
+func (r stocktakes) Get(ctx context.Context, scope repository.Scope, id int64) (models.Stocktake, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	st, ok := r.s.stocktakes[id]
+	if !ok || !scope.Includes(r.s.warehouses[st.WarehouseID].OrgID) {
+		return models.Stocktake{}, repository.ErrNotFound
+	}
+	return r.s.stocktake(st), nil
+}
+
+func (r stocktakes) Start(ctx context.Context, scope repository.Scope, stocktake *models.Stocktake) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	w, ok := r.s.warehouses[stocktake.WarehouseID]
+	if !ok || !scope.Includes(w.OrgID) {
+		return repository.ErrNotFound
+	}
+	for _, st := range r.s.stocktakes {
+		if st.WarehouseID == w.ID && st.Status == repository.StocktakeCounting {
+			return repository.ErrDuplicate
+		}
+	}
+
+	stocktake.ID = r.s.id()
+	stocktake.Status = repository.StocktakeCounting
+	stocktake.CreatedAt = time.Now()
+	stored := *stocktake
+	stored.Lines = nil
+	for _, item := range r.s.items {
+		if item.WarehouseID != w.ID {
+			continue
+		}
+		stored.Lines = append(stored.Lines, models.StocktakeLine{
+			ID: r.s.id(), StocktakeID: stocktake.ID, ItemID: item.ID,
+			ExpectedQuantity: item.Quantity, UnitCost: r.s.item(item).AverageCost(),
+		})
+	}
+	r.s.stocktakes[stocktake.ID] = stored
+	return nil
+}
+
+// open возвращает открытую инвентаризацию области.
+func (s *Store) open(scope repository.Scope, id int64) (models.Stocktake, error) {
+	st, ok := s.stocktakes[id]
+	if !ok || st.Status != repository.StocktakeCounting || !scope.Includes(s.warehouses[st.WarehouseID].OrgID) {
+		return models.Stocktake{}, repository.ErrNotFound
+	}
+	return st, nil
+}
+
+func (r stocktakes) Count(ctx context.Context, scope repository.Scope, id, itemID int64, quantity int, userID int64) error {
+	if quantity < 0 {
+		return fmt.Errorf("counted quantity must not be negative, got %d", quantity)
+	}
+
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	st, err := r.s.open(scope, id)
+	if err != nil {
+		return err
+	}
+	// st.Lines разделяет массив с хранилищем, поэтому позиция меняется на месте
+	for i := range st.Lines {
+		line := &st.Lines[i]
+		if line.ItemID == itemID {
+			line.Counted = true
+			line.CountedQuantity = quantity
+			line.CountedBy = userID
+			line.CountedAt = time.Now()
+			return nil
+		}
+	}
+	return repository.ErrNotFound
+}
+
+func (r stocktakes) Scan(ctx context.Context, scope repository.Scope, id int64, code string, quantity int, userID int64) (models.StocktakeLine, error) {
+	if quantity <= 0 {
+		return models.StocktakeLine{}, fmt.Errorf("scanned quantity must be positive, got %d", quantity)
+	}
+
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	st, err := r.s.open(scope, id)
+	if err != nil {
+		return models.StocktakeLine{}, err
+	}
+	// Штрихкод одного товара может совпасть с артикулом другого;
+	// совпадение по штрихкоду важнее
+	match := -1
+	for i, line := range st.Lines {
+		p := r.s.products[r.s.items[line.ItemID].ProductID]
+		if p.Barcode == code && code != "" {
+			match = i
+			break
+		}
+		if p.SKU == code && match < 0 {
+			match = i
+		}
+	}
+	if match < 0 {
+		return models.StocktakeLine{}, repository.ErrNotFound
+	}
+
+	line := &st.Lines[match]
+	if !line.Counted {
+		line.Counted = true
+		line.CountedQuantity = 0
+	}
+	line.CountedQuantity += quantity
+	line.CountedBy = userID
+	line.CountedAt = time.Now()
+	return r.s.stocktakeLine(*line), nil
+}
+
+func (r stocktakes) Approve(ctx context.Context, scope repository.Scope, id, userID int64) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	st, err := r.s.open(scope, id)
+	if err != nil {
+		return err
+	}
+	// Сначала проверяются все позиции: проведение либо проходит целиком,
+	// либо не меняет ничего
+	for _, line := range st.Lines {
+		if r.s.items[line.ItemID].Quantity+line.Variance() < 0 {
+			return repository.ErrInsufficientStock
+		}
+	}
+
+	reason := fmt.Sprintf("Инвентаризация №%d", id)
+	for _, line := range st.Lines {
+		if delta := line.Variance(); delta != 0 {
+			r.s.move(line.ItemID, models.StockMovement{
+				Type: repository.MovementAdjustment, Quantity: delta, Reason: reason,
+			})
+		}
+	}
+	r.s.updateUsage(st.WarehouseID)
+
+	st.Status = repository.StocktakeApproved
+	st.ApprovedBy = userID
+	st.ClosedAt = time.Now()
+	r.s.stocktakes[id] = st
+	return nil
+}
+
+func (r stocktakes) Cancel(ctx context.Context, scope repository.Scope, id int64) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	st, err := r.s.open(scope, id)
+	if err != nil {
+		return err
+	}
+	st.Status = repository.StocktakeCancelled
+	st.ClosedAt = time.Now()
+	r.s.stocktakes[id] = st
+	return nil
+}
@@ -0,0 +1,257 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"vend_erp/internal/models"
+	"vend_erp/internal/repository"
+)
+
+type supplies struct {
+	s *Store
+}
+
+// supply дополняет заказ полями склада, организации и утвердившего
+// и названиями товаров в позициях.
+func (s *Store) supply(supply models.WarehouseSupply) models.WarehouseSupply {
+	w := s.warehouses[supply.WarehouseID]
+	supply.WarehouseName = w.Name
+	supply.OrgID = w.OrgID
+	supply.OrgName = s.orgs[w.OrgID].Name
+	supply.ApprovedByName = ""
+	if u, ok := s.users[supply.ApprovedBy]; ok {
+		supply.ApprovedByName = u.Username
+	}
+	items := make([]models.SupplyItem, len(supply.Items))
+	for i, item := range supply.Items {
+		p := s.products[s.items[item.InventoryItemID].ProductID]
+		item.ItemName = p.Name
+		item.SKU = p.SKU
+		items[i] = item
+	}
+	sort.Slice(items, func(i, j int) bool {
+		if items[i].ItemName != items[j].ItemName {
+			return items[i].ItemName < items[j].ItemName
+		}
+		return items[i].ID < items[j].ID
+	})
+	supply.Items = items
+	return supply
+}
+
+func (r supplies) List(ctx context.Context, scope repository.Scope) ([]models.WarehouseSupply, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	var list []models.WarehouseSupply
+	for _, supply := range r.s.supplies {
+		if scope.Includes(r.s.warehouses[supply.WarehouseID].OrgID) {
+			list = append(list, r.s.supply(supply))
+		}
+	}
+	sort.Slice(list, func(i, j int) bool {
+		a, b := list[i], list[j]
+		if draftA, draftB := a.Status == repository.SupplyDraft, b.Status == repository.SupplyDraft; draftA != draftB {
+			return draftA
+		}
+		return newestFirst(a.CreatedAt, b.CreatedAt, a.ID, b.ID)
+	})
+	return list, nil
+}
+
+func (r supplies) Get(ctx context.Context, scope repository.Scope, id int64) (models.WarehouseSupply, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	supply, ok := r.s.supplies[id]
+	if !ok || !scope.Includes(r.s.warehouses[supply.WarehouseID].OrgID) {
+		return models.WarehouseSupply{}, repository.ErrNotFound
+	}
+	return r.s.supply(supply), nil
+}
+
+func (r supplies) Create(ctx context.Context, supply *models.WarehouseSupply) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	if _, ok := r.s.warehouses[supply.WarehouseID]; !ok {
+		return repository.ErrNotFound
+	}
+	for _, item := range supply.Items {
+		if stock, ok := r.s.items[item.InventoryItemID]; !ok || stock.WarehouseID != supply.WarehouseID {
+			return repository.ErrNotFound
+		}
+	}
+
+	supply.ID = r.s.id()
+	supply.TotalAmount = 0
+	supply.CreatedAt = time.Now()
+	supply.UpdatedAt = supply.CreatedAt
+	// Позиции хранятся копией, чтобы вызывающий не менял их в хранилище
+	items := make([]models.SupplyItem, len(supply.Items))
+	for i := range supply.Items {
+		item := &supply.Items[i]
+		item.ID = r.s.id()
+		item.SupplyID = supply.ID
+		item.TotalPrice = item.UnitPrice.Mul(item.QuantityOrdered)
+		item.CreatedAt = supply.CreatedAt
+		supply.TotalAmount += item.TotalPrice
+		items[i] = models.SupplyItem{
+			ID: item.ID, SupplyID: item.SupplyID, InventoryItemID: item.InventoryItemID,
+			QuantityOrdered: item.QuantityOrdered, QuantityReceived: item.QuantityReceived,
+			UnitPrice: item.UnitPrice, TotalPrice: item.TotalPrice, CreatedAt: item.CreatedAt,
+		}
+	}
+	stored := *supply
+	stored.Items = items
+	r.s.supplies[supply.ID] = stored
+	return nil
+}
+
+// review переводит черновик области в status.
+func (s *Store) review(scope repository.Scope, id int64, status string, userID int64) error {
+	supply, ok := s.supplies[id]
+	if !ok || supply.Status != repository.SupplyDraft || !scope.Includes(s.warehouses[supply.WarehouseID].OrgID) {
+		return repository.ErrNotFound
+	}
+	supply.Status = status
+	supply.UpdatedAt = time.Now()
+	if userID != 0 {
+		supply.ApprovedBy = userID
+		supply.ApprovedAt = supply.UpdatedAt
+	}
+	s.supplies[id] = supply
+	return nil
+}
+
+func (r supplies) Approve(ctx context.Context, scope repository.Scope, id, userID int64) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	return r.s.review(scope, id, repository.SupplyOrdered, userID)
+}
+
+func (r supplies) Reject(ctx context.Context, scope repository.Scope, id int64) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	return r.s.review(scope, id, repository.SupplyCancelled, 0)
+}
+
+func (r supplies) Receive(ctx context.Context, scope repository.Scope, id int64, received map[int64]int) error {
+	for lineID, quantity := range received {
+		if quantity < 0 {
+			return fmt.Errorf("received quantity of supply item %d must not be negative, got %d", lineID, quantity)
+		}
+	}
+
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	supply, ok := r.s.supplies[id]
+	if !ok || !scope.Includes(r.s.warehouses[supply.WarehouseID].OrgID) {
+		return repository.ErrNotFound
+	}
+	if supply.Status != repository.SupplyOrdered && supply.Status != repository.SupplyInTransit {
+		return repository.ErrNotFound
+	}
+
+	// Вместимость проверяется до первого движения: в базе приход
+	// откатывается целиком
+	var volume int
+	zones := make(map[int64]int)
+	for _, line := range supply.Items {
+		item := r.s.item(r.s.items[line.InventoryItemID])
+		volume += received[line.ID] * item.UnitVolume
+		zones[item.ZoneID] += received[line.ID] * item.UnitVolume
+	}
+	if r.s.overCapacity(supply.WarehouseID, 0, volume) {
+		return repository.ErrOverCapacity
+	}
+	for zoneID, v := range zones {
+		if r.s.overZone(zoneID, v) {
+			return repository.ErrOverCapacity
+		}
+	}
+
+	reason := fmt.Sprintf("Поставка №%d", id)
+	items := make([]models.SupplyItem, len(supply.Items))
+	for i, line := range supply.Items {
+		if quantity := received[line.ID]; quantity > 0 {
+			unitCost := line.UnitPrice
+			if unitCost == 0 {
+				unitCost = r.s.item(r.s.items[line.InventoryItemID]).UnitPrice
+			}
+			_, _, err := r.s.move(line.InventoryItemID, models.StockMovement{
+				Type: repository.MovementReceipt, Quantity: quantity, Reason: reason,
+			}, models.StockLot{UnitCost: unitCost, Quantity: quantity})
+			if err != nil {
+				return err
+			}
+			line.QuantityReceived += quantity
+		}
+		items[i] = line
+	}
+	supply.Items = items
+	supply.Status = repository.SupplyDelivered
+	supply.UpdatedAt = time.Now()
+	r.s.supplies[id] = supply
+	r.s.updateUsage(supply.WarehouseID)
+	return nil
+}
+
+func (r supplies) LockReplenishment(ctx context.Context) (func(), error) {
+	if !r.s.replenishing.TryLock() {
+		return nil, repository.ErrLocked
+	}
+	return r.s.replenishing.Unlock, nil
+}
+
+func (r supplies) ReorderLines(ctx context.Context, scope repository.Scope, since time.Time) ([]repository.ReorderLine, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	onOrder := make(map[int64]int)
+	for _, supply := range r.s.supplies {
+		switch supply.Status {
+		case repository.SupplyDraft, repository.SupplyOrdered, repository.SupplyInTransit:
+		default:
+			continue
+		}
+		for _, item := range supply.Items {
+			if open := item.QuantityOrdered - item.QuantityReceived; open > 0 {
+				onOrder[item.InventoryItemID] += open
+			}
+		}
+	}
+	restocked := make(map[int64]int)
+	for _, m := range r.s.movements {
+		if m.Type == repository.MovementRestock && !m.CreatedAt.Before(since) {
+			restocked[m.ItemID] -= m.Quantity
+		}
+	}
+
+	var lines []repository.ReorderLine
+	for _, item := range r.s.items {
+		w := r.s.warehouses[item.WarehouseID]
+		p := r.s.products[item.ProductID]
+		if !w.IsActive || !p.IsActive || !scope.Includes(w.OrgID) {
+			continue
+		}
+		lines = append(lines, repository.ReorderLine{
+			ItemID: item.ID, WarehouseID: item.WarehouseID, OrgID: w.OrgID,
+			SupplierName: p.SupplierName, UnitCost: p.DefaultCost,
+			MinOrder: p.MinOrderQuantity, PackSize: p.PackSize,
+			Quantity: item.Quantity, MinStock: item.MinStockLevel, MaxStock: item.MaxStockLevel,
+			OnOrder: onOrder[item.ID], Restocked: restocked[item.ID],
+		})
+	}
+	sort.Slice(lines, func(i, j int) bool {
+		if lines[i].WarehouseID != lines[j].WarehouseID {
+			return lines[i].WarehouseID < lines[j].WarehouseID
+		}
+		return lines[i].ItemID < lines[j].ItemID
+	})
+	return lines, nil
+}
@@ -0,0 +1,325 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+
+	"vend_erp/internal/models"
+	"vend_erp/internal/repository"
+)
+
+type users struct {
+	s *Store
+}
+
+func (s *Store) user(u *user) models.User {
+	result := u.User
+	result.OrgName = s.orgs[u.OrgID].Name
+	return result
+}
+
+// taken повторяет UNIQUE(username) и UNIQUE(email).
+func (s *Store) taken(email, username string, exceptID int64) bool {
+	for id, u := range s.users {
+		if id != exceptID && (u.Email == email || u.Username == username) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r users) List(ctx context.Context, scope repository.Scope) ([]models.User, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	var list []models.User
+	for _, u := range r.s.users {
+		if scope.Includes(u.OrgID) {
+			list = append(list, r.s.user(u))
+		}
+	}
+	sort.Slice(list, func(i, j int) bool {
+		a, b := list[i], list[j]
+		pendingA, pendingB := a.Status == models.UserStatusPending, b.Status == models.UserStatusPending
+		if pendingA != pendingB {
+			return pendingA
+		}
+		return newestFirst(a.CreatedAt, b.CreatedAt, a.ID, b.ID)
+	})
+	return list, nil
+}
+
+func (r users) Get(ctx context.Context, scope repository.Scope, id int64) (models.User, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	u, ok := r.s.users[id]
+	if !ok || !scope.Includes(u.OrgID) {
+		return models.User{}, repository.ErrNotFound
+	}
+	return r.s.user(u), nil
+}
+
+func (r users) FindByEmail(ctx context.Context, email string) (models.User, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	for _, u := range r.s.users {
+		if u.Email == email {
+			return r.s.user(u), nil
+		}
+	}
+	return models.User{}, repository.ErrNotFound
+}
+
+func (r users) PasswordHash(ctx context.Context, id int64) (string, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	u, ok := r.s.users[id]
+	if !ok {
+		return "", repository.ErrNotFound
+	}
+	return u.passwordHash, nil
+}
+
+func (r users) Exists(ctx context.Context, email, username string) (bool, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	for _, u := range r.s.users {
+		if strings.EqualFold(u.Email, email) || u.Username == username {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (r users) ListActive(ctx context.Context, orgID int64) ([]models.User, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	var list []models.User
+	for _, u := range r.s.users {
+		if u.Status == models.UserStatusActive && r.s.members[membership{u.ID, orgID}] {
+			list = append(list, r.s.user(u))
+		}
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Username < list[j].Username })
+	return list, nil
+}
+
+func (r users) IsMember(ctx context.Context, userID, orgID int64) (bool, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	return r.s.members[membership{userID, orgID}], nil
+}
+
+func (r users) Create(ctx context.Context, u *models.User, passwordHash string) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	if r.s.taken(u.Email, u.Username, 0) {
+		return repository.ErrDuplicate
+	}
+	// Суперадмина и адрес входа назначают не через профиль
+	u.IsSuperAdmin = false
+	u.LastIPAddr = ""
+	u.ID = r.s.id()
+	u.CreatedAt = time.Now()
+	u.UpdatedAt = u.CreatedAt
+	r.s.users[u.ID] = &user{User: *u, passwordHash: passwordHash}
+	r.s.members[membership{u.ID, u.OrgID}] = true
+	return nil
+}
+
+func (r users) Update(ctx context.Context, scope repository.Scope, u models.User, passwordHash string) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	current, ok := r.s.users[u.ID]
+	if !ok || !scope.Includes(current.OrgID) || !canManage(scope, current) {
+		return repository.ErrNotFound
+	}
+	if r.s.taken(u.Email, u.Username, u.ID) {
+		return repository.ErrDuplicate
+	}
+
+	// Служебные поля профилем не меняются
+	u.LastIPAddr = current.LastIPAddr
+	u.IsSuperAdmin = current.IsSuperAdmin
+	u.CreatedAt = current.CreatedAt
+	u.UpdatedAt = time.Now()
+	if u.OrgID == 0 {
+		u.OrgID = current.OrgID
+	} else {
+		r.s.members[membership{u.ID, u.OrgID}] = true
+	}
+	current.User = u
+	if passwordHash != "" {
+		current.passwordHash = passwordHash
+	}
+	return nil
+}
+
+func (r users) Review(ctx context.Context, scope repository.Scope, id int64, status int, reviewerID int64) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	u, ok := r.s.users[id]
+	if !ok || !scope.Includes(u.OrgID) || !canManage(scope, u) || u.Status != models.UserStatusPending {
+		return repository.ErrNotFound
+	}
+	u.Status = status
+	u.approvedBy = reviewerID
+	u.UpdatedAt = time.Now()
+	return nil
+}
+
+func (r users) Delete(ctx context.Context, scope repository.Scope, id int64) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	u, ok := r.s.users[id]
+	if !ok || !scope.Includes(u.OrgID) || !canManage(scope, u) {
+		return nil
+	}
+	delete(r.s.users, id)
+
+	// ON DELETE CASCADE: членство, сессии и операции пользователя
+	for m := range r.s.members {
+		if m.userID == id {
+			delete(r.s.members, m)
+		}
+	}
+	for sessionID, session := range r.s.sessions {
+		if session.UserID == id {
+			delete(r.s.sessions, sessionID)
+		}
+	}
+	for opID, op := range r.s.operations {
+		if op.PerformedBy == id {
+			delete(r.s.operations, opID)
+		}
+	}
+	// ON DELETE SET NULL: утвержденные пользователем заказы остаются
+	for supplyID, supply := range r.s.supplies {
+		if supply.ApprovedBy == id {
+			supply.ApprovedBy = 0
+			r.s.supplies[supplyID] = supply
+		}
+	}
+	// и инвентаризации, которые он открыл, провел или посчитал
+	for stID, st := range r.s.stocktakes {
+		if st.CreatedBy == id {
+			st.CreatedBy = 0
+		}
+		if st.ApprovedBy == id {
+			st.ApprovedBy = 0
+		}
+		for i := range st.Lines {
+			if st.Lines[i].CountedBy == id {
+				st.Lines[i].CountedBy = 0
+			}
+		}
+		r.s.stocktakes[stID] = st
+	}
+	// и резервы, которые он завел
+	for resID, res := range r.s.reserves {
+		if res.CreatedBy == id {
+			res.CreatedBy = 0
+			r.s.reserves[resID] = res
+		}
+	}
+	// и приглашения, которые он выписал
+	for inviteID, i := range r.s.invites {
+		if i.InvitedBy == id {
+			i.InvitedBy = 0
+			r.s.invites[inviteID] = i
+		}
+	}
+	return nil
+}
+
+// canManage сообщает, может ли запрос области scope менять учетную запись u:
+// суперадмина меняет только суперадмин.
+func canManage(scope repository.Scope, u *user) bool {
+	return scope.SuperAdmin || !u.IsSuperAdmin
+}
+
+func (r users) Teams(ctx context.Context, scope repository.Scope) ([]string, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	seen := make(map[string]bool)
+	var teams []string
+	for _, u := range r.s.users {
+		if u.Team != "" && scope.Includes(u.OrgID) && !seen[u.Team] {
+			seen[u.Team] = true
+			teams = append(teams, u.Team)
+		}
+	}
+	sort.Strings(teams)
+	return teams, nil
+}
+
+func (r users) FindBySubject(ctx context.Context, issuer, subject string) (models.User, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	for _, u := range r.s.users {
+		if u.oidcSubject != "" && u.oidcIssuer == issuer && u.oidcSubject == subject {
+			return r.s.user(u), nil
+		}
+	}
+	return models.User{}, repository.ErrNotFound
+}
+
+func (r users) LinkSubject(ctx context.Context, email, issuer, subject string) (models.User, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	for _, u := range r.s.users {
+		if u.oidcSubject == "" && strings.EqualFold(u.Email, email) {
+			u.oidcIssuer, u.oidcSubject = issuer, subject
+			u.UpdatedAt = time.Now()
+			return r.s.user(u), nil
+		}
+	}
+	return models.User{}, repository.ErrNotFound
+}
+
+func (r users) Provision(ctx context.Context, u *models.User, issuer, subject string) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	if r.s.taken(u.Email, u.Username, 0) {
+		return repository.ErrDuplicate
+	}
+	for _, existing := range r.s.users {
+		if existing.oidcIssuer == issuer && existing.oidcSubject == subject {
+			return repository.ErrDuplicate
+		}
+	}
+	u.IsSuperAdmin = false
+	u.LastIPAddr = ""
+	u.ID = r.s.id()
+	u.CreatedAt = time.Now()
+	u.UpdatedAt = u.CreatedAt
+	r.s.users[u.ID] = &user{User: *u, oidcIssuer: issuer, oidcSubject: subject}
+	r.s.members[membership{u.ID, u.OrgID}] = true
+	return nil
+}
+
+func (r users) SetRole(ctx context.Context, id int64, role string) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	if u, ok := r.s.users[id]; ok && u.UserRole != role {
+		u.UserRole = role
+		u.UpdatedAt = time.Now()
+	}
+	return nil
+}
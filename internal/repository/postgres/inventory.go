@@ -0,0 +1,1079 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"time"
+
+	"vend_erp/internal/models"
+	"vend_erp/internal/money"
+	"vend_erp/internal/repository"
+)
+
+// Inventory хранит склады (warehouse) и остатки товаров (warehouse_inventory).
+type Inventory struct {
+	db *sql.DB
+}
+
+const warehouseColumns = `
+        SELECT id, name, address, COALESCE(contact_person, ''), COALESCE(contact_phone, ''),
+               total_capacity, COALESCE(current_usage, 0), capacity_policy, COALESCE(is_active, false), org_id,
+               created_at, updated_at, version
+        FROM warehouse
+`
+
+func scanWarehouse(row rowScanner) (models.Warehouse, error) {
+	var warehouse models.Warehouse
+	var createdAt, updatedAt sql.NullTime
+	err := row.Scan(
+		&warehouse.ID, &warehouse.Name, &warehouse.Address,
+		&warehouse.ContactPerson, &warehouse.ContactPhone,
+		&warehouse.TotalCapacity, &warehouse.CurrentUsage, &warehouse.CapacityPolicy, &warehouse.IsActive,
+		&warehouse.OrgID, &createdAt, &updatedAt, &warehouse.Version,
+	)
+	warehouse.CreatedAt = createdAt.Time
+	warehouse.UpdatedAt = updatedAt.Time
+	return warehouse, err
+}
+
+func (r *Inventory) Warehouses(ctx context.Context, scope repository.Scope) ([]models.Warehouse, error) {
+	rows, err := r.db.QueryContext(ctx, warehouseColumns+`
+        WHERE is_active = true AND ($1::bigint IS NULL OR org_id = $1)
+        ORDER BY name
+    `, scope.Param())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var warehouses []models.Warehouse
+	for rows.Next() {
+		warehouse, err := scanWarehouse(rows)
+		if err != nil {
+			return nil, err
+		}
+		warehouses = append(warehouses, warehouse)
+	}
+	return warehouses, rows.Err()
+}
+
+func (r *Inventory) Warehouse(ctx context.Context, scope repository.Scope, id int64) (models.Warehouse, error) {
+	warehouse, err := scanWarehouse(r.db.QueryRowContext(ctx, warehouseColumns+`
+        WHERE id = $1 AND ($2::bigint IS NULL OR org_id = $2)
+    `, id, scope.Param()))
+	return warehouse, translate(err)
+}
+
+func (r *Inventory) CreateWarehouse(ctx context.Context, warehouse *models.Warehouse) error {
+	if warehouse.CapacityPolicy == "" {
+		warehouse.CapacityPolicy = repository.CapacityWarn
+	}
+	err := r.db.QueryRowContext(ctx, `
+        INSERT INTO warehouse (name, address, contact_person, contact_phone,
+                             total_capacity, capacity_policy, is_active, org_id)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+        RETURNING id, version
+    `, warehouse.Name, warehouse.Address, warehouse.ContactPerson,
+		warehouse.ContactPhone, warehouse.TotalCapacity, warehouse.CapacityPolicy,
+		warehouse.IsActive, warehouse.OrgID).Scan(&warehouse.ID, &warehouse.Version)
+	return translate(err)
+}
+
+func (r *Inventory) UpdateWarehouse(ctx context.Context, scope repository.Scope, warehouse models.Warehouse) error {
+	if warehouse.CapacityPolicy == "" {
+		warehouse.CapacityPolicy = repository.CapacityWarn
+	}
+	result, err := r.db.ExecContext(ctx, `
+        UPDATE warehouse
+        SET name=$1, address=$2, contact_person=$3, contact_phone=$4,
+            total_capacity=$5, capacity_policy=$6, is_active=$7, updated_at=CURRENT_TIMESTAMP,
+            version = version + 1
+        WHERE id=$8 AND ($9::bigint IS NULL OR org_id = $9) AND version = $10
+    `, warehouse.Name, warehouse.Address, warehouse.ContactPerson,
+		warehouse.ContactPhone, warehouse.TotalCapacity, warehouse.CapacityPolicy,
+		warehouse.IsActive, warehouse.ID, scope.Param(), warehouse.Version)
+	return versioned(ctx, r.db, result, err,
+		"SELECT 1 FROM warehouse WHERE id = $1 AND ($2::bigint IS NULL OR org_id = $2)",
+		warehouse.ID, scope.Param())
+}
+
+func (r *Inventory) Zones(ctx context.Context, scope repository.Scope, warehouseID int64) ([]models.WarehouseZone, error) {
+	if _, err := r.Warehouse(ctx, scope, warehouseID); err != nil {
+		return nil, err
+	}
+	rows, err := r.db.QueryContext(ctx, `
+        SELECT z.id, z.warehouse_id, z.name, z.capacity, z.created_at,
+               COALESCE(SUM(wi.quantity * p.unit_volume), 0), COUNT(wi.id)
+        FROM warehouse_zones z
+        LEFT JOIN warehouse_inventory wi ON wi.zone_id = z.id
+        LEFT JOIN products p ON p.id = wi.product_id
+        WHERE z.warehouse_id = $1
+        GROUP BY z.id
+        ORDER BY z.name, z.id
+    `, warehouseID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var zones []models.WarehouseZone
+	for rows.Next() {
+		var zone models.WarehouseZone
+		err := rows.Scan(&zone.ID, &zone.WarehouseID, &zone.Name, &zone.Capacity, &zone.CreatedAt,
+			&zone.Usage, &zone.ItemCount)
+		if err != nil {
+			return nil, err
+		}
+		zones = append(zones, zone)
+	}
+	return zones, rows.Err()
+}
+
+func (r *Inventory) CreateZone(ctx context.Context, scope repository.Scope, zone *models.WarehouseZone) error {
+	err := r.db.QueryRowContext(ctx, `
+        INSERT INTO warehouse_zones (warehouse_id, name, capacity)
+        SELECT id, $3, $4 FROM warehouse
+        WHERE id = $1 AND ($2::bigint IS NULL OR org_id = $2)
+        RETURNING id, created_at
+    `, zone.WarehouseID, scope.Param(), zone.Name, zone.Capacity).Scan(&zone.ID, &zone.CreatedAt)
+	return translate(err)
+}
+
+func (r *Inventory) UpdateZone(ctx context.Context, scope repository.Scope, zone models.WarehouseZone) error {
+	return affected(r.db.ExecContext(ctx, `
+        UPDATE warehouse_zones z
+        SET name = $3, capacity = $4
+        FROM warehouse w
+        WHERE z.id = $1 AND w.id = z.warehouse_id AND ($2::bigint IS NULL OR w.org_id = $2)
+    `, zone.ID, scope.Param(), zone.Name, zone.Capacity))
+}
+
+func (r *Inventory) DeleteZone(ctx context.Context, scope repository.Scope, id int64) error {
+	_, err := r.db.ExecContext(ctx, `
+        DELETE FROM warehouse_zones z
+        USING warehouse w
+        WHERE z.id = $1 AND w.id = z.warehouse_id AND ($2::bigint IS NULL OR w.org_id = $2)
+    `, id, scope.Param())
+	return err
+}
+
+func (r *Inventory) Place(ctx context.Context, scope repository.Scope, itemID, zoneID int64) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	item, err := lockItem(ctx, tx, scope, itemID)
+	if err != nil {
+		return err
+	}
+	if err := zoneOf(ctx, tx, item.warehouseID, zoneID); err != nil {
+		return err
+	}
+	// Размещение — правка позиции: открытая форма с прежней зоной устаревает
+	_, err = tx.ExecContext(ctx, `
+        UPDATE warehouse_inventory
+        SET zone_id = $1, updated_at = CURRENT_TIMESTAMP, version = version + 1
+        WHERE id = $2
+    `, nullIfZero(zoneID), itemID)
+	if err != nil {
+		return err
+	}
+	if zoneID != item.zoneID {
+		if err := checkZone(ctx, tx, zoneID); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (r *Inventory) Categories(ctx context.Context) ([]models.WarehouseCategory, error) {
+	rows, err := r.db.QueryContext(ctx,
+		"SELECT id, name, COALESCE(description, ''), created_at FROM warehouse_categories ORDER BY name")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var categories []models.WarehouseCategory
+	for rows.Next() {
+		var category models.WarehouseCategory
+		var createdAt sql.NullTime
+		if err := rows.Scan(&category.ID, &category.Name, &category.Description, &createdAt); err != nil {
+			return nil, err
+		}
+		category.CreatedAt = createdAt.Time
+		categories = append(categories, category)
+	}
+	return categories, rows.Err()
+}
+
+const itemColumns = `
+        SELECT
+            wi.id, wi.warehouse_id, wi.product_id, p.category_id, p.item_type,
+            p.name, COALESCE(p.description, ''), wi.quantity,
+            COALESCE(wi.min_stock_level, 0), COALESCE(wi.max_stock_level, 0),
+            p.default_cost, p.sku, wi.stock_value, wi.created_at, wi.updated_at, wi.version,
+            COALESCE(wi.zone_id, 0), p.unit_volume,
+            COALESCE((
+                SELECT SUM(sr.quantity) FROM stock_reservations sr
+                WHERE sr.item_id = wi.id AND ` + activeReservation + `
+            ), 0) as reserved,
+            w.name as warehouse_name, w.address as warehouse_address,
+            COALESCE(c.name, '') as category_name, COALESCE(z.name, '') as zone_name,
+            w.org_id, o.name as org_name
+        FROM warehouse_inventory wi
+        JOIN products p ON p.id = wi.product_id
+        JOIN warehouse w ON wi.warehouse_id = w.id
+        JOIN organizations o ON o.id = w.org_id
+        LEFT JOIN warehouse_categories c ON p.category_id = c.id
+        LEFT JOIN warehouse_zones z ON z.id = wi.zone_id
+`
+
+func scanItem(row rowScanner) (models.WarehouseInventory, error) {
+	var item models.WarehouseInventory
+	var createdAt, updatedAt sql.NullTime
+	err := row.Scan(
+		&item.ID, &item.WarehouseID, &item.ProductID, &item.CategoryID, &item.ItemType,
+		&item.ItemName, &item.Description, &item.Quantity, &item.MinStockLevel,
+		&item.MaxStockLevel, &item.UnitPrice, &item.SKU, &item.StockValue, &createdAt, &updatedAt, &item.Version,
+		&item.ZoneID, &item.UnitVolume, &item.Reserved,
+		&item.WarehouseName, &item.WarehouseAddress, &item.CategoryName, &item.ZoneName,
+		&item.OrgID, &item.OrgName,
+	)
+	item.CreatedAt = createdAt.Time
+	item.UpdatedAt = updatedAt.Time
+	return item, err
+}
+
+func (r *Inventory) Items(ctx context.Context, scope repository.Scope, filter repository.InventoryFilter) ([]models.WarehouseInventory, error) {
+	query := itemColumns + `
+        WHERE w.is_active = true AND ($1::bigint IS NULL OR w.org_id = $1)
+    `
+	args := []interface{}{scope.Param()}
+
+	if filter.WarehouseID != 0 {
+		args = append(args, filter.WarehouseID)
+		query += fmt.Sprintf(" AND wi.warehouse_id = $%d", len(args))
+	}
+	if filter.ItemType != "" {
+		args = append(args, filter.ItemType)
+		query += fmt.Sprintf(" AND p.item_type = $%d", len(args))
+	}
+	switch filter.Stock {
+	case repository.StockLow:
+		query += " AND wi.quantity < COALESCE(wi.min_stock_level, 0)"
+	case repository.StockOut:
+		query += " AND wi.quantity <= 0"
+	case repository.StockNormal:
+		query += " AND wi.quantity >= COALESCE(wi.min_stock_level, 0) AND wi.quantity <> 0"
+	}
+	query += " ORDER BY w.name, p.item_type, p.name, wi.id"
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []models.WarehouseInventory
+	for rows.Next() {
+		item, err := scanItem(rows)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
+
+func (r *Inventory) Item(ctx context.Context, scope repository.Scope, id int64) (models.WarehouseInventory, error) {
+	item, err := scanItem(r.db.QueryRowContext(ctx, itemColumns+`
+        WHERE wi.id = $1 AND ($2::bigint IS NULL OR w.org_id = $2)
+    `, id, scope.Param()))
+	return item, translate(err)
+}
+
+func (r *Inventory) CreateItem(ctx context.Context, item *models.WarehouseInventory) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Остаток заводится только для товара из справочника организации склада
+	// и оценивается по его закупочной цене
+	if err := zoneOf(ctx, tx, item.WarehouseID, item.ZoneID); err != nil {
+		return err
+	}
+	var unitCost money.Amount
+	err = tx.QueryRowContext(ctx, `
+        WITH product AS (
+            SELECT p.id, p.default_cost
+            FROM warehouse w
+            JOIN products p ON p.org_id = w.org_id
+            WHERE w.id = $1 AND p.id = $2
+        ), created AS (
+            INSERT INTO warehouse_inventory
+            (warehouse_id, product_id, quantity, min_stock_level, max_stock_level, stock_value, zone_id)
+            SELECT $1, id, $3::int, $4, $5, $3::int * default_cost, $6
+            FROM product
+            RETURNING id, version
+        )
+        SELECT created.id, created.version, product.default_cost FROM created, product
+    `, item.WarehouseID, item.ProductID, item.Quantity,
+		item.MinStockLevel, item.MaxStockLevel, nullIfZero(item.ZoneID)).Scan(&item.ID, &item.Version, &unitCost)
+	if err != nil {
+		return translate(err)
+	}
+
+	if item.Quantity > 0 {
+		item.StockValue = unitCost.Mul(item.Quantity)
+		movementID, err := record(ctx, tx, item.ID, item.WarehouseID, item.Quantity, models.StockMovement{
+			Type: repository.MovementOpening, Quantity: item.Quantity, Cost: item.StockValue,
+			Reason: "Начальный остаток",
+		})
+		if err != nil {
+			return err
+		}
+		err = addLot(ctx, tx, item.ID, movementID, models.StockLot{UnitCost: unitCost, Quantity: item.Quantity})
+		if err != nil {
+			return err
+		}
+	}
+	if err := updateUsage(ctx, tx, item.WarehouseID); err != nil {
+		return err
+	}
+	if item.Quantity > 0 {
+		if err := checkCapacity(ctx, tx, item.WarehouseID, item.ZoneID); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (r *Inventory) UpdateItem(ctx context.Context, scope repository.Scope, item models.WarehouseInventory) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Под блокировкой версия не может измениться до конца транзакции
+	current, err := lockItem(ctx, tx, scope, item.ID)
+	if err != nil {
+		return err
+	}
+	if current.version != item.Version {
+		return repository.ErrConflict
+	}
+	if err := zoneOf(ctx, tx, item.WarehouseID, item.ZoneID); err != nil {
+		return err
+	}
+	sourceWarehouseID, sourceZoneID := current.warehouseID, current.zoneID
+
+	// Отложенное остается на складе позиции: его нельзя ни списать
+	// правкой количества, ни увезти вместе с позицией
+	reserved, err := reservedQuantity(ctx, tx, current.id, 0)
+	if err != nil {
+		return err
+	}
+	if reserved > 0 && current.warehouseID != item.WarehouseID {
+		return repository.ErrInUse
+	}
+	if item.Quantity < current.quantity && item.Quantity < reserved {
+		return repository.ErrInsufficientStock
+	}
+
+	// Перенос позиции на другой склад — перемещение всего остатка
+	// вместе с партиями
+	if current.warehouseID != item.WarehouseID && current.quantity > 0 {
+		moved := current.quantity
+		out, lots, err := move(ctx, tx, &current, models.StockMovement{
+			Type: repository.MovementTransferOut, Quantity: -moved, Reason: "Позиция перенесена на другой склад",
+		})
+		if err != nil {
+			return err
+		}
+		current.warehouseID = item.WarehouseID
+		_, _, err = move(ctx, tx, &current, models.StockMovement{
+			Type: repository.MovementTransferIn, Quantity: moved, Cost: -out.Cost,
+			Reason: "Позиция перенесена на другой склад",
+		}, lots...)
+		if err != nil {
+			return err
+		}
+	}
+	current.warehouseID = item.WarehouseID
+	if delta := item.Quantity - current.quantity; delta != 0 {
+		_, _, err := move(ctx, tx, &current, models.StockMovement{
+			Type: repository.MovementAdjustment, Quantity: delta, Reason: "Изменено в карточке позиции",
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	// Движения уже увеличили версию; правка целиком — одно изменение
+	_, err = tx.ExecContext(ctx, `
+        UPDATE warehouse_inventory
+        SET warehouse_id=$1, min_stock_level=$2, max_stock_level=$3, zone_id=$4,
+            updated_at=CURRENT_TIMESTAMP, version=$5
+        WHERE id=$6
+    `, item.WarehouseID, item.MinStockLevel, item.MaxStockLevel, nullIfZero(item.ZoneID),
+		item.Version+1, item.ID)
+	if err != nil {
+		return translate(err)
+	}
+
+	if sourceWarehouseID != item.WarehouseID {
+		if err := updateUsage(ctx, tx, sourceWarehouseID); err != nil {
+			return err
+		}
+	}
+	if err := updateUsage(ctx, tx, item.WarehouseID); err != nil {
+		return err
+	}
+	// Перенос на другой склад проверяется как приход, новая зона — как
+	// размещение; правка количества — корректировка и не проверяется
+	switch {
+	case sourceWarehouseID != item.WarehouseID && item.Quantity > 0:
+		err = checkCapacity(ctx, tx, item.WarehouseID, item.ZoneID)
+	case sourceZoneID != item.ZoneID:
+		err = checkZone(ctx, tx, item.ZoneID)
+	}
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (r *Inventory) DeleteItem(ctx context.Context, scope repository.Scope, id int64) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Блокировка не дает провести движение, пока позиция удаляется
+	item, err := lockItem(ctx, tx, scope, id)
+	if err == repository.ErrNotFound {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	// Журнал позиции не удаляется: позицию с историей удалить нельзя
+	var used bool
+	err = tx.QueryRowContext(ctx, `
+        SELECT EXISTS (SELECT 1 FROM stock_movements WHERE item_id = $1)
+    `, id).Scan(&used)
+	if err != nil {
+		return err
+	}
+	if used || item.quantity != 0 {
+		return repository.ErrInUse
+	}
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM warehouse_inventory WHERE id = $1", id); err != nil {
+		return translate(err)
+	}
+	if err := updateUsage(ctx, tx, item.warehouseID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (r *Inventory) Adjust(ctx context.Context, scope repository.Scope, itemID int64, kind string, quantity int, reason string) (int, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	item, err := lockItem(ctx, tx, scope, itemID)
+	if err != nil {
+		return 0, err
+	}
+
+	var delta int
+	switch kind {
+	case repository.AdjustAdd:
+		delta = quantity
+	case repository.AdjustSubtract:
+		delta = -quantity
+	case repository.AdjustSet:
+		delta = quantity - item.quantity
+	default:
+		return 0, fmt.Errorf("unknown adjustment type %q", kind)
+	}
+	// Списание не трогает отложенное: резервы сначала нужно снять
+	if delta < 0 {
+		reserved, err := reservedQuantity(ctx, tx, item.id, 0)
+		if err != nil {
+			return 0, err
+		}
+		if item.quantity+delta < reserved {
+			return 0, repository.ErrInsufficientStock
+		}
+	}
+
+	_, _, err = move(ctx, tx, &item, models.StockMovement{
+		Type: repository.MovementAdjustment, Quantity: delta, Reason: reason,
+	})
+	if err != nil {
+		return 0, err
+	}
+	if err := updateUsage(ctx, tx, item.warehouseID); err != nil {
+		return 0, err
+	}
+	return item.quantity, tx.Commit()
+}
+
+func (r *Inventory) Transfer(ctx context.Context, scope repository.Scope, itemID, targetWarehouseID int64, quantity int, notes string) error {
+	if quantity <= 0 {
+		return fmt.Errorf("transfer quantity must be positive, got %d", quantity)
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var warehouseID, orgID, productID int64
+	err = tx.QueryRowContext(ctx, `
+        SELECT wi.warehouse_id, w.org_id, wi.product_id
+        FROM warehouse_inventory wi
+        JOIN warehouse w ON w.id = wi.warehouse_id
+        WHERE wi.id = $1 AND ($2::bigint IS NULL OR w.org_id = $2)
+    `, itemID, scope.Param()).Scan(&warehouseID, &orgID, &productID)
+	if err != nil {
+		return translate(err)
+	}
+	if warehouseID == targetWarehouseID {
+		return fmt.Errorf("transfer of item %d to its own warehouse", itemID)
+	}
+
+	// Перемещение возможно только между складами одной организации
+	var found bool
+	err = tx.QueryRowContext(ctx, `
+        SELECT EXISTS (SELECT 1 FROM warehouse WHERE id = $1 AND org_id = $2)
+    `, targetWarehouseID, orgID).Scan(&found)
+	if err != nil {
+		return err
+	}
+	if !found {
+		return repository.ErrNotFound
+	}
+
+	// Остаток того же товара на целевом складе; если его нет, он заводится
+	// с пороговыми уровнями исходной позиции
+	var targetID int64
+	err = tx.QueryRowContext(ctx, `
+        SELECT id FROM warehouse_inventory
+        WHERE warehouse_id = $1 AND product_id = $2
+    `, targetWarehouseID, productID).Scan(&targetID)
+	if err == sql.ErrNoRows {
+		err = tx.QueryRowContext(ctx, `
+            INSERT INTO warehouse_inventory
+            (warehouse_id, product_id, quantity, min_stock_level, max_stock_level)
+            SELECT $1, product_id, 0, min_stock_level, max_stock_level
+            FROM warehouse_inventory
+            WHERE id = $2
+            RETURNING id
+        `, targetWarehouseID, itemID).Scan(&targetID)
+	}
+	if err != nil {
+		return translate(err)
+	}
+
+	// Позиции блокируются по возрастанию id, чтобы встречные перемещения
+	// не ждали друг друга
+	ids := []int64{itemID, targetID}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	locked := make(map[int64]*lockedItem, len(ids))
+	for _, id := range ids {
+		item, err := lockItem(ctx, tx, repository.Scope{OrgID: orgID}, id)
+		if err != nil {
+			return err
+		}
+		locked[id] = &item
+	}
+	source, target := locked[itemID], locked[targetID]
+	reserved, err := reservedQuantity(ctx, tx, itemID, 0)
+	if err != nil {
+		return err
+	}
+	if source.quantity-reserved < quantity {
+		return repository.ErrInsufficientStock
+	}
+
+	// Партии переходят на целевой склад со своими ценами и датами прихода,
+	// стоимость — с той, по которой товар списан с исходного
+	out, lots, err := move(ctx, tx, source, models.StockMovement{
+		Type: repository.MovementTransferOut, Quantity: -quantity, TransferItemID: targetID, Reason: notes,
+	})
+	if err != nil {
+		return err
+	}
+	_, _, err = move(ctx, tx, target, models.StockMovement{
+		Type: repository.MovementTransferIn, Quantity: quantity, Cost: -out.Cost,
+		TransferItemID: itemID, Reason: notes,
+	}, lots...)
+	if err != nil {
+		return err
+	}
+
+	if err := updateUsage(ctx, tx, source.warehouseID); err != nil {
+		return err
+	}
+	if err := updateUsage(ctx, tx, targetWarehouseID); err != nil {
+		return err
+	}
+	if err := checkCapacity(ctx, tx, targetWarehouseID, target.zoneID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (r *Inventory) Move(ctx context.Context, scope repository.Scope, itemID int64, change repository.StockChange) (int, error) {
+	sign, err := changeSign(change)
+	if err != nil {
+		return 0, err
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	item, err := lockItem(ctx, tx, scope, itemID)
+	if err != nil {
+		return 0, err
+	}
+
+	// Расход берет доступный товар и отложенный под его резерв
+	if change.ReservationID != 0 {
+		if err := lockReservation(ctx, tx, item.id, change); err != nil {
+			return 0, err
+		}
+	}
+	if sign < 0 {
+		reserved, err := reservedQuantity(ctx, tx, item.id, change.ReservationID)
+		if err != nil {
+			return 0, err
+		}
+		if item.quantity-reserved < change.Quantity {
+			return 0, repository.ErrInsufficientStock
+		}
+	}
+
+	var lots []models.StockLot
+	if change.Type == repository.MovementReceipt {
+		unitCost := change.UnitCost
+		if unitCost == 0 {
+			unitCost = item.defaultCost
+		}
+		lots = append(lots, models.StockLot{UnitCost: unitCost, Quantity: change.Quantity})
+	}
+	m, _, err := move(ctx, tx, &item, models.StockMovement{
+		Type: change.Type, Quantity: sign * change.Quantity, Reason: change.Reason,
+	}, lots...)
+	if err != nil {
+		return 0, err
+	}
+	if change.ReservationID != 0 {
+		if err := consumeReservation(ctx, tx, change.ReservationID, m.ID); err != nil {
+			return 0, err
+		}
+	}
+	if err := updateUsage(ctx, tx, item.warehouseID); err != nil {
+		return 0, err
+	}
+	if change.Type == repository.MovementReceipt {
+		if err := checkCapacity(ctx, tx, item.warehouseID, item.zoneID); err != nil {
+			return 0, err
+		}
+	}
+	return item.quantity, tx.Commit()
+}
+
+// changeSign проверяет изменение для Move и возвращает знак его количества.
+func changeSign(change repository.StockChange) (int, error) {
+	if change.Quantity <= 0 {
+		return 0, fmt.Errorf("stock change quantity must be positive, got %d", change.Quantity)
+	}
+	switch change.Type {
+	case repository.MovementReceipt:
+		return 1, nil
+	case repository.MovementShipment:
+		return -1, nil
+	}
+	return 0, fmt.Errorf("unknown stock change type %q", change.Type)
+}
+
+func (r *Inventory) Movements(ctx context.Context, scope repository.Scope, itemID int64) ([]models.StockMovement, error) {
+	rows, err := r.db.QueryContext(ctx, `
+        SELECT sm.id, sm.item_id, sm.warehouse_id, sm.movement_type, sm.quantity,
+               sm.balance_after, sm.cost, COALESCE(sm.transfer_item_id, 0),
+               COALESCE(sm.vending_machine_id, 0), COALESCE(sm.operation_id, 0),
+               COALESCE(sm.reason, ''), sm.created_at,
+               w.name, COALESCE(vm.serial_number, '')
+        FROM stock_movements sm
+        JOIN warehouse_inventory wi ON wi.id = sm.item_id
+        JOIN warehouse iw ON iw.id = wi.warehouse_id
+        JOIN warehouse w ON w.id = sm.warehouse_id
+        LEFT JOIN vending_machines vm ON vm.id = sm.vending_machine_id
+        WHERE sm.item_id = $1 AND ($2::bigint IS NULL OR iw.org_id = $2)
+        ORDER BY sm.created_at DESC, sm.id DESC
+    `, itemID, scope.Param())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var movements []models.StockMovement
+	for rows.Next() {
+		var m models.StockMovement
+		err := rows.Scan(&m.ID, &m.ItemID, &m.WarehouseID, &m.Type, &m.Quantity,
+			&m.BalanceAfter, &m.Cost, &m.TransferItemID, &m.VendingMachineID, &m.OperationID,
+			&m.Reason, &m.CreatedAt, &m.WarehouseName, &m.MachineSerial)
+		if err != nil {
+			return nil, err
+		}
+		movements = append(movements, m)
+	}
+	return movements, rows.Err()
+}
+
+func (r *Inventory) BalanceAt(ctx context.Context, scope repository.Scope, itemID int64, at time.Time) (int, error) {
+	var balance int
+	err := r.db.QueryRowContext(ctx, `
+        SELECT COALESCE((
+            SELECT sm.balance_after
+            FROM stock_movements sm
+            WHERE sm.item_id = wi.id AND sm.created_at <= $3
+            ORDER BY sm.created_at DESC, sm.id DESC
+            LIMIT 1
+        ), 0)
+        FROM warehouse_inventory wi
+        JOIN warehouse w ON w.id = wi.warehouse_id
+        WHERE wi.id = $1 AND ($2::bigint IS NULL OR w.org_id = $2)
+    `, itemID, scope.Param(), at).Scan(&balance)
+	return balance, translate(err)
+}
+
+func (r *Inventory) Lots(ctx context.Context, scope repository.Scope, itemID int64) ([]models.StockLot, error) {
+	if _, err := r.Item(ctx, scope, itemID); err != nil {
+		return nil, err
+	}
+	return openLots(ctx, r.db, itemID)
+}
+
+// Valuation складывает количество и стоимость движений до момента at:
+// стоимость остатка позиции равна сумме стоимостей ее движений.
+func (r *Inventory) Valuation(ctx context.Context, scope repository.Scope, at time.Time) ([]repository.WarehouseValue, error) {
+	rows, err := r.db.QueryContext(ctx, `
+        SELECT w.id, w.name, SUM(sm.quantity), SUM(sm.cost)
+        FROM stock_movements sm
+        JOIN warehouse w ON w.id = sm.warehouse_id
+        WHERE sm.created_at <= $2 AND ($1::bigint IS NULL OR w.org_id = $1)
+        GROUP BY w.id, w.name
+        HAVING SUM(sm.quantity) <> 0 OR SUM(sm.cost) <> 0
+        ORDER BY w.name, w.id
+    `, scope.Param(), at)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var list []repository.WarehouseValue
+	for rows.Next() {
+		var v repository.WarehouseValue
+		if err := rows.Scan(&v.WarehouseID, &v.WarehouseName, &v.Quantity, &v.Value); err != nil {
+			return nil, err
+		}
+		list = append(list, v)
+	}
+	return list, rows.Err()
+}
+
+func (r *Inventory) CostOfSales(ctx context.Context, scope repository.Scope, from, to time.Time) ([]repository.OperationCost, error) {
+	// Движения без операции группируются каждое само по себе
+	rows, err := r.db.QueryContext(ctx, `
+        SELECT COALESCE(sm.operation_id, 0),
+               MIN(COALESCE(o.operation_date, sm.created_at)),
+               COALESCE(MAX(vm.serial_number), ''),
+               CASE WHEN sm.operation_id IS NULL THEN COALESCE(MAX(sm.reason), '') ELSE '' END,
+               -SUM(sm.quantity), -SUM(sm.cost)
+        FROM stock_movements sm
+        JOIN warehouse w ON w.id = sm.warehouse_id
+        LEFT JOIN vending_operations o ON o.id = sm.operation_id
+        LEFT JOIN vending_machines vm ON vm.id = sm.vending_machine_id
+        WHERE sm.movement_type = $2 AND sm.created_at >= $3 AND sm.created_at < $4
+          AND ($1::bigint IS NULL OR w.org_id = $1)
+        GROUP BY sm.operation_id, CASE WHEN sm.operation_id IS NULL THEN sm.id END
+        ORDER BY 2 DESC, 1 DESC, MIN(sm.id) DESC
+    `, scope.Param(), repository.MovementRestock, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var list []repository.OperationCost
+	for rows.Next() {
+		var c repository.OperationCost
+		if err := rows.Scan(&c.OperationID, &c.Date, &c.MachineSerial, &c.Reason, &c.Quantity, &c.Cost); err != nil {
+			return nil, err
+		}
+		list = append(list, c)
+	}
+	return list, rows.Err()
+}
+
+// lockedItem — позиция, заблокированная до конца транзакции.
+type lockedItem struct {
+	id          int64
+	warehouseID int64
+	zoneID      int64
+	orgID       int64
+	quantity    int
+	version     int
+	// value — стоимость остатка, method — метод оценки организации,
+	// defaultCost — закупочная цена товара
+	value       money.Amount
+	method      string
+	defaultCost money.Amount
+}
+
+// lockItem блокирует позицию области (SELECT ... FOR UPDATE): движения
+// по одной позиции выполняются по очереди, и каждое видит остаток,
+// оставленный предыдущим.
+func lockItem(ctx context.Context, tx *sql.Tx, scope repository.Scope, id int64) (lockedItem, error) {
+	item := lockedItem{id: id}
+	err := tx.QueryRowContext(ctx, `
+        SELECT wi.warehouse_id, COALESCE(wi.zone_id, 0), w.org_id, wi.quantity, wi.version,
+               wi.stock_value, o.costing_method, p.default_cost
+        FROM warehouse_inventory wi
+        JOIN warehouse w ON w.id = wi.warehouse_id
+        JOIN organizations o ON o.id = w.org_id
+        JOIN products p ON p.id = wi.product_id
+        WHERE wi.id = $1 AND ($2::bigint IS NULL OR w.org_id = $2)
+        FOR UPDATE OF wi
+    `, id, scope.Param()).Scan(&item.warehouseID, &item.zoneID, &item.orgID, &item.quantity, &item.version,
+		&item.value, &item.method, &item.defaultCost)
+	return item, translate(err)
+}
+
+// move меняет остаток заблокированной позиции на m.Quantity и записывает
+// движение в журнал. Отрицательный остаток не допускается.
+//
+// Приход заводит партии lots; без них — одну партию по средней
+// себестоимости остатка. Стоимость прихода — m.Cost, если она задана,
+// иначе стоимость партий. Расход списывает партии от старых к новым
+// (repository.Issue). move возвращает записанное движение со стоимостью
+// и списанные части партий.
+func move(ctx context.Context, tx *sql.Tx, item *lockedItem, m models.StockMovement, lots ...models.StockLot) (models.StockMovement, []models.StockLot, error) {
+	balance := item.quantity + m.Quantity
+	if balance < 0 {
+		return m, nil, repository.ErrInsufficientStock
+	}
+
+	var taken []models.StockLot
+	switch {
+	case m.Quantity > 0:
+		if len(lots) == 0 {
+			unitCost := item.defaultCost
+			if item.quantity > 0 {
+				unitCost = item.value.Share(1, item.quantity)
+			}
+			lots = []models.StockLot{{UnitCost: unitCost, Quantity: m.Quantity}}
+		}
+		if m.Cost == 0 {
+			for _, lot := range lots {
+				m.Cost += lot.UnitCost.Mul(lot.Quantity)
+			}
+		}
+	case m.Quantity < 0:
+		open, err := openLots(ctx, tx, item.id)
+		if err != nil {
+			return m, nil, err
+		}
+		var cost money.Amount
+		cost, taken = repository.Issue(item.method, open, item.value, item.quantity, -m.Quantity)
+		m.Cost = -cost
+		for _, lot := range taken {
+			_, err := tx.ExecContext(ctx, "UPDATE stock_lots SET remaining = $1 WHERE id = $2", lot.Remaining, lot.ID)
+			if err != nil {
+				return m, nil, err
+			}
+		}
+	}
+
+	_, err := tx.ExecContext(ctx, `
+        UPDATE warehouse_inventory
+        SET quantity = $1, stock_value = stock_value + $2,
+            updated_at = CURRENT_TIMESTAMP, version = version + 1
+        WHERE id = $3
+    `, balance, m.Cost, item.id)
+	if err != nil {
+		return m, nil, err
+	}
+	m.ID, err = record(ctx, tx, item.id, item.warehouseID, balance, m)
+	if err != nil {
+		return m, nil, err
+	}
+	if m.Quantity > 0 {
+		for _, lot := range lots {
+			if err := addLot(ctx, tx, item.id, m.ID, lot); err != nil {
+				return m, nil, err
+			}
+		}
+	}
+	m.ItemID, m.WarehouseID, m.BalanceAfter = item.id, item.warehouseID, balance
+	item.quantity = balance
+	item.value += m.Cost
+	item.version++
+	return m, taken, nil
+}
+
+// openLots возвращает непустые партии позиции, старые первыми.
+func openLots(ctx context.Context, q querier, itemID int64) ([]models.StockLot, error) {
+	rows, err := q.QueryContext(ctx, `
+        SELECT id, item_id, COALESCE(movement_id, 0), unit_cost, quantity, remaining, received_at
+        FROM stock_lots
+        WHERE item_id = $1 AND remaining > 0
+        ORDER BY received_at, id
+    `, itemID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var lots []models.StockLot
+	for rows.Next() {
+		var lot models.StockLot
+		err := rows.Scan(&lot.ID, &lot.ItemID, &lot.MovementID, &lot.UnitCost,
+			&lot.Quantity, &lot.Remaining, &lot.ReceivedAt)
+		if err != nil {
+			return nil, err
+		}
+		lots = append(lots, lot)
+	}
+	return lots, rows.Err()
+}
+
+// addLot заводит партию позиции, пришедшую движением movementID. Партия,
+// перемещенная с другого склада, сохраняет дату прихода.
+func addLot(ctx context.Context, tx *sql.Tx, itemID, movementID int64, lot models.StockLot) error {
+	_, err := tx.ExecContext(ctx, `
+        INSERT INTO stock_lots (item_id, movement_id, unit_cost, quantity, remaining, received_at)
+        VALUES ($1, $2, $3, $4, $4, COALESCE($5, CURRENT_TIMESTAMP))
+    `, itemID, movementID, lot.UnitCost, lot.Quantity, nullIfZeroTime(lot.ReceivedAt))
+	return err
+}
+
+// record добавляет движение m в журнал позиции и возвращает его ID.
+func record(ctx context.Context, tx *sql.Tx, itemID, warehouseID int64, balance int, m models.StockMovement) (int64, error) {
+	var id int64
+	err := tx.QueryRowContext(ctx, `
+        INSERT INTO stock_movements
+        (item_id, warehouse_id, movement_type, quantity, balance_after, cost,
+         transfer_item_id, vending_machine_id, operation_id, reason)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+        RETURNING id
+    `, itemID, warehouseID, m.Type, m.Quantity, balance, m.Cost,
+		nullIfZero(m.TransferItemID), nullIfZero(m.VendingMachineID), nullIfZero(m.OperationID),
+		nullIfEmpty(m.Reason)).Scan(&id)
+	return id, err
+}
+
+// execer и querier — *sql.DB или *sql.Tx.
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+type querier interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// warehouseUsage — загрузка склада warehouse.id: сумма объемов остатков.
+const warehouseUsage = `(
+            SELECT COALESCE(SUM(wi.quantity * p.unit_volume), 0)
+            FROM warehouse_inventory wi
+            JOIN products p ON p.id = wi.product_id
+            WHERE wi.warehouse_id = warehouse.id
+        )`
+
+// updateUsage пересчитывает загрузку склада по объемам остатков.
+func updateUsage(ctx context.Context, db execer, warehouseID int64) error {
+	_, err := db.ExecContext(ctx, `
+        UPDATE warehouse SET current_usage = `+warehouseUsage+`
+        WHERE id = $1
+    `, warehouseID)
+	return err
+}
+
+// zoneOf возвращает ErrNotFound, если zoneID (не 0) — не зона склада.
+func zoneOf(ctx context.Context, tx *sql.Tx, warehouseID, zoneID int64) error {
+	if zoneID == 0 {
+		return nil
+	}
+	var found bool
+	err := tx.QueryRowContext(ctx, `
+        SELECT EXISTS (SELECT 1 FROM warehouse_zones WHERE id = $1 AND warehouse_id = $2)
+    `, zoneID, warehouseID).Scan(&found)
+	if err != nil {
+		return err
+	}
+	if !found {
+		return repository.ErrNotFound
+	}
+	return nil
+}
+
+// checkCapacity возвращает ErrOverCapacity, если склад или зона zoneID
+// (0 — без зоны) переполнены, а политика склада — CapacityBlock.
+// Вызывается после updateUsage, перед фиксацией транзакции.
+func checkCapacity(ctx context.Context, tx *sql.Tx, warehouseID, zoneID int64) error {
+	var over bool
+	err := tx.QueryRowContext(ctx, `
+        SELECT current_usage > total_capacity AND capacity_policy = $2
+        FROM warehouse WHERE id = $1
+    `, warehouseID, repository.CapacityBlock).Scan(&over)
+	if err != nil {
+		return err
+	}
+	if over {
+		return repository.ErrOverCapacity
+	}
+	return checkZone(ctx, tx, zoneID)
+}
+
+// checkZone — то же только для зоны.
+func checkZone(ctx context.Context, tx *sql.Tx, zoneID int64) error {
+	if zoneID == 0 {
+		return nil
+	}
+	var over bool
+	err := tx.QueryRowContext(ctx, `
+        SELECT COALESCE(SUM(wi.quantity * p.unit_volume), 0) > z.capacity AND w.capacity_policy = $2
+        FROM warehouse_zones z
+        JOIN warehouse w ON w.id = z.warehouse_id
+        LEFT JOIN warehouse_inventory wi ON wi.zone_id = z.id
+        LEFT JOIN products p ON p.id = wi.product_id
+        WHERE z.id = $1
+        GROUP BY z.capacity, w.capacity_policy
+    `, zoneID, repository.CapacityBlock).Scan(&over)
+	if err != nil {
+		return err
+	}
+	if over {
+		return repository.ErrOverCapacity
+	}
+	return nil
+}
@@ -0,0 +1,82 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+
+	"vend_erp/internal/models"
+	"vend_erp/internal/repository"
+)
+
+// Invites хранит приглашения в таблице invites.
+type Invites struct {
+	db *sql.DB
+}
+
+const inviteColumns = `
+        SELECT i.id, COALESCE(i.email, ''), i.userrole, COALESCE(i.team, ''), i.org_id, o.name,
+               COALESCE(i.invited_by, 0), COALESCE(u.username, ''),
+               i.expires_at, i.used_at, i.revoked_at, i.created_at
+        FROM invites i
+        JOIN organizations o ON o.id = i.org_id
+        LEFT JOIN users u ON u.id = i.invited_by
+`
+
+func scanInvite(row rowScanner) (models.Invite, error) {
+	var invite models.Invite
+	err := row.Scan(
+		&invite.ID, &invite.Email, &invite.UserRole, &invite.Team, &invite.OrgID, &invite.OrgName,
+		&invite.InvitedBy, &invite.InvitedByName,
+		&invite.ExpiresAt, &invite.UsedAt, &invite.RevokedAt, &invite.CreatedAt,
+	)
+	return invite, err
+}
+
+func (r *Invites) Find(ctx context.Context, tokenHash string) (models.Invite, error) {
+	invite, err := scanInvite(r.db.QueryRowContext(ctx, inviteColumns+`
+        WHERE i.token_hash = $1 AND o.is_active = true
+    `, tokenHash))
+	return invite, translate(err)
+}
+
+func (r *Invites) List(ctx context.Context, scope repository.Scope) ([]models.Invite, error) {
+	rows, err := r.db.QueryContext(ctx, inviteColumns+`
+        WHERE ($1::bigint IS NULL OR i.org_id = $1)
+        ORDER BY (i.used_at IS NULL AND i.revoked_at IS NULL AND i.expires_at > CURRENT_TIMESTAMP) DESC,
+                 i.created_at DESC, i.id DESC
+        LIMIT 50
+    `, scope.Param())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var invites []models.Invite
+	for rows.Next() {
+		invite, err := scanInvite(rows)
+		if err != nil {
+			return nil, err
+		}
+		invites = append(invites, invite)
+	}
+	return invites, rows.Err()
+}
+
+func (r *Invites) Create(ctx context.Context, invite *models.Invite, tokenHash string) error {
+	err := r.db.QueryRowContext(ctx, `
+        INSERT INTO invites (token_hash, email, userrole, team, org_id, invited_by, expires_at)
+        VALUES ($1, $2, $3, $4, $5, $6, $7)
+        RETURNING id, created_at
+    `, tokenHash, nullIfEmpty(invite.Email), invite.UserRole, nullIfEmpty(invite.Team),
+		invite.OrgID, nullIfZero(invite.InvitedBy), invite.ExpiresAt).Scan(&invite.ID, &invite.CreatedAt)
+	return translate(err)
+}
+
+func (r *Invites) Revoke(ctx context.Context, scope repository.Scope, id int64) error {
+	_, err := r.db.ExecContext(ctx, `
+        UPDATE invites SET revoked_at = CURRENT_TIMESTAMP
+        WHERE id = $1 AND used_at IS NULL AND revoked_at IS NULL
+          AND ($2::bigint IS NULL OR org_id = $2)
+    `, id, scope.Param())
+	return err
+}
@@ -0,0 +1,113 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+
+	"vend_erp/internal/models"
+	"vend_erp/internal/repository"
+)
+
+// Locations хранит точки размещения в таблице locations.
+type Locations struct {
+	db *sql.DB
+}
+
+const locationColumns = `
+        SELECT l.id, l.name, COALESCE(l.address, ''),
+               COALESCE(l.contact_person, ''), COALESCE(l.contact_phone, ''),
+               COALESCE(l.monthly_rent, 0), COALESCE(l.rent_due_day, 1),
+               COALESCE(l.is_active, false), l.org_id, o.name,
+               l.created_at, l.updated_at, l.version
+        FROM locations l
+        JOIN organizations o ON o.id = l.org_id
+`
+
+func scanLocation(row rowScanner) (models.Location, error) {
+	var location models.Location
+	var createdAt, updatedAt sql.NullTime
+
+	err := row.Scan(
+		&location.ID, &location.Name, &location.Address,
+		&location.ContactPerson, &location.ContactPhone,
+		&location.MonthlyRent, &location.RentDueDay, &location.IsActive,
+		&location.OrgID, &location.OrgName,
+		&createdAt, &updatedAt, &location.Version,
+	)
+	location.CreatedAt = createdAt.Time
+	location.UpdatedAt = updatedAt.Time
+	return location, err
+}
+
+func (r *Locations) query(ctx context.Context, query string, args ...interface{}) ([]models.Location, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var locations []models.Location
+	for rows.Next() {
+		location, err := scanLocation(rows)
+		if err != nil {
+			return nil, err
+		}
+		locations = append(locations, location)
+	}
+	return locations, rows.Err()
+}
+
+func (r *Locations) List(ctx context.Context, scope repository.Scope) ([]models.Location, error) {
+	return r.query(ctx, locationColumns+`
+        WHERE ($1::bigint IS NULL OR l.org_id = $1)
+        ORDER BY l.created_at DESC, l.id DESC
+    `, scope.Param())
+}
+
+func (r *Locations) Get(ctx context.Context, scope repository.Scope, id int64) (models.Location, error) {
+	location, err := scanLocation(r.db.QueryRowContext(ctx, locationColumns+`
+        WHERE l.id = $1 AND ($2::bigint IS NULL OR l.org_id = $2)
+    `, id, scope.Param()))
+	return location, translate(err)
+}
+
+func (r *Locations) ListActive(ctx context.Context, orgID int64) ([]models.Location, error) {
+	return r.query(ctx, locationColumns+`
+        WHERE l.is_active = true AND l.org_id = $1
+        ORDER BY l.name
+    `, orgID)
+}
+
+func (r *Locations) Create(ctx context.Context, location *models.Location) error {
+	err := r.db.QueryRowContext(ctx, `
+        INSERT INTO locations (name, address, contact_person, contact_phone,
+                             monthly_rent, rent_due_day, is_active, org_id)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+        RETURNING id, version
+    `, location.Name, location.Address, location.ContactPerson,
+		location.ContactPhone, location.MonthlyRent, location.RentDueDay, location.IsActive,
+		location.OrgID).Scan(&location.ID, &location.Version)
+	return translate(err)
+}
+
+func (r *Locations) Update(ctx context.Context, scope repository.Scope, location models.Location) error {
+	result, err := r.db.ExecContext(ctx, `
+        UPDATE locations
+        SET name=$1, address=$2, contact_person=$3, contact_phone=$4,
+            monthly_rent=$5, rent_due_day=$6, is_active=$7, updated_at=CURRENT_TIMESTAMP,
+            version = version + 1
+        WHERE id=$8 AND ($9::bigint IS NULL OR org_id = $9) AND version = $10
+    `, location.Name, location.Address, location.ContactPerson,
+		location.ContactPhone, location.MonthlyRent, location.RentDueDay,
+		location.IsActive, location.ID, scope.Param(), location.Version)
+	return versioned(ctx, r.db, result, err,
+		"SELECT 1 FROM locations WHERE id = $1 AND ($2::bigint IS NULL OR org_id = $2)",
+		location.ID, scope.Param())
+}
+
+func (r *Locations) Delete(ctx context.Context, scope repository.Scope, id int64) error {
+	_, err := r.db.ExecContext(ctx,
+		"DELETE FROM locations WHERE id = $1 AND ($2::bigint IS NULL OR org_id = $2)",
+		id, scope.Param())
+	return err
+}
@@ -0,0 +1,203 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+
+	"vend_erp/internal/models"
+	"vend_erp/internal/repository"
+)
+
+// Machines хранит автоматы в таблице vending_machines.
+type Machines struct {
+	db *sql.DB
+}
+
+const machineColumns = `
+        SELECT
+            m.id, m.serial_number, m.model, m.status,
+            m.current_toys_count, m.capacity_toys, m.cash_amount,
+            m.last_maintenance_date, m.next_maintenance_date, m.installation_date,
+            m.created_at, m.updated_at, m.version,
+            m.location_id,
+            COALESCE(l.name, 'Не назначена') as location_name,
+            m.org_id, o.name as org_name
+        FROM vending_machines m
+        LEFT JOIN locations l ON m.location_id = l.id
+        JOIN organizations o ON o.id = m.org_id
+`
+
+func scanMachine(row rowScanner) (models.VendingMachine, error) {
+	var machine models.VendingMachine
+	var lastMaintenanceDate, nextMaintenanceDate, installationDate, createdAt, updatedAt sql.NullTime
+	var locationID sql.NullInt64
+
+	err := row.Scan(
+		&machine.ID, &machine.SerialNumber, &machine.Model,
+		&machine.Status, &machine.CurrentToysCount, &machine.CapacityToys,
+		&machine.CashAmount, &lastMaintenanceDate, &nextMaintenanceDate,
+		&installationDate, &createdAt, &updatedAt, &machine.Version,
+		&locationID, &machine.LocationName,
+		&machine.OrgID, &machine.OrgName,
+	)
+	if err != nil {
+		return machine, err
+	}
+
+	// Локация может быть снята при ее удалении (ON DELETE SET NULL)
+	machine.LocationID = locationID.Int64
+	machine.LastMaintenanceDate = lastMaintenanceDate.Time
+	machine.NextMaintenanceDate = nextMaintenanceDate.Time
+	machine.InstallationDate = installationDate.Time
+	machine.CreatedAt = createdAt.Time
+	machine.UpdatedAt = updatedAt.Time
+	return machine, nil
+}
+
+func (r *Machines) query(ctx context.Context, query string, args ...interface{}) ([]models.VendingMachine, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var machines []models.VendingMachine
+	for rows.Next() {
+		machine, err := scanMachine(rows)
+		if err != nil {
+			return nil, err
+		}
+		machines = append(machines, machine)
+	}
+	return machines, rows.Err()
+}
+
+func (r *Machines) List(ctx context.Context, scope repository.Scope) ([]models.VendingMachine, error) {
+	return r.query(ctx, machineColumns+`
+        WHERE ($1::bigint IS NULL OR m.org_id = $1)
+        ORDER BY m.created_at DESC, m.id DESC
+    `, scope.Param())
+}
+
+func (r *Machines) Get(ctx context.Context, scope repository.Scope, id int64) (models.VendingMachine, error) {
+	machine, err := scanMachine(r.db.QueryRowContext(ctx, machineColumns+`
+        WHERE m.id = $1 AND ($2::bigint IS NULL OR m.org_id = $2)
+    `, id, scope.Param()))
+	return machine, translate(err)
+}
+
+func (r *Machines) FindBySerial(ctx context.Context, scope repository.Scope, serial string) (models.VendingMachine, error) {
+	machine, err := scanMachine(r.db.QueryRowContext(ctx, machineColumns+`
+        WHERE m.serial_number = $1 AND ($2::bigint IS NULL OR m.org_id = $2)
+    `, serial, scope.Param()))
+	return machine, translate(err)
+}
+
+func (r *Machines) ListActive(ctx context.Context, orgID int64) ([]models.VendingMachine, error) {
+	return r.query(ctx, machineColumns+`
+        WHERE m.status = 'active' AND m.org_id = $1
+        ORDER BY m.serial_number
+    `, orgID)
+}
+
+func (r *Machines) Create(ctx context.Context, machine *models.VendingMachine) error {
+	err := r.db.QueryRowContext(ctx, `
+        INSERT INTO vending_machines
+        (serial_number, model, location_id, status, capacity_toys,
+         current_toys_count, cash_amount, last_maintenance_date,
+         next_maintenance_date, installation_date, org_id)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+        RETURNING id, version
+    `, machine.SerialNumber, machine.Model, nullIfZero(machine.LocationID), machine.Status,
+		machine.CapacityToys, machine.CurrentToysCount, machine.CashAmount,
+		nullIfZeroTime(machine.LastMaintenanceDate),
+		nullIfZeroTime(machine.NextMaintenanceDate),
+		nullIfZeroTime(machine.InstallationDate), machine.OrgID).Scan(&machine.ID, &machine.Version)
+	return translate(err)
+}
+
+func (r *Machines) Update(ctx context.Context, scope repository.Scope, machine models.VendingMachine) error {
+	result, err := r.db.ExecContext(ctx, `
+        UPDATE vending_machines
+        SET serial_number=$1, model=$2, location_id=$3, status=$4,
+            capacity_toys=$5, current_toys_count=$6, cash_amount=$7,
+            last_maintenance_date=$8, next_maintenance_date=$9,
+            installation_date=$10, updated_at=CURRENT_TIMESTAMP,
+            version = version + 1
+        WHERE id=$11 AND ($12::bigint IS NULL OR org_id = $12) AND version = $13
+    `, machine.SerialNumber, machine.Model, nullIfZero(machine.LocationID), machine.Status,
+		machine.CapacityToys, machine.CurrentToysCount, machine.CashAmount,
+		nullIfZeroTime(machine.LastMaintenanceDate),
+		nullIfZeroTime(machine.NextMaintenanceDate),
+		nullIfZeroTime(machine.InstallationDate), machine.ID, scope.Param(), machine.Version)
+	return versioned(ctx, r.db, result, err,
+		"SELECT 1 FROM vending_machines WHERE id = $1 AND ($2::bigint IS NULL OR org_id = $2)",
+		machine.ID, scope.Param())
+}
+
+func (r *Machines) Delete(ctx context.Context, scope repository.Scope, id int64) error {
+	_, err := r.db.ExecContext(ctx,
+		"DELETE FROM vending_machines WHERE id = $1 AND ($2::bigint IS NULL OR org_id = $2)",
+		id, scope.Param())
+	return err
+}
+
+func (r *Machines) Planogram(ctx context.Context, scope repository.Scope, machineID int64) ([]models.PlanogramItem, error) {
+	rows, err := r.db.QueryContext(ctx, `
+        SELECT pl.id, pl.machine_id, pl.product_id, pl.target_count, pl.price_per_play,
+               p.name, p.sku
+        FROM machine_planogram pl
+        JOIN vending_machines m ON m.id = pl.machine_id
+        JOIN products p ON p.id = pl.product_id
+        WHERE pl.machine_id = $1 AND ($2::bigint IS NULL OR m.org_id = $2)
+        ORDER BY p.name, pl.id
+    `, machineID, scope.Param())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []models.PlanogramItem
+	for rows.Next() {
+		var item models.PlanogramItem
+		err := rows.Scan(&item.ID, &item.MachineID, &item.ProductID, &item.TargetCount, &item.PricePerPlay,
+			&item.ProductName, &item.SKU)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
+
+func (r *Machines) SetPlanogram(ctx context.Context, scope repository.Scope, machineID int64, items []models.PlanogramItem) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var orgID int64
+	err = tx.QueryRowContext(ctx, `
+        SELECT org_id FROM vending_machines
+        WHERE id = $1 AND ($2::bigint IS NULL OR org_id = $2)
+        FOR UPDATE
+    `, machineID, scope.Param()).Scan(&orgID)
+	if err != nil {
+		return translate(err)
+	}
+	if _, err := tx.ExecContext(ctx, "DELETE FROM machine_planogram WHERE machine_id = $1", machineID); err != nil {
+		return err
+	}
+	for _, item := range items {
+		err := affected(tx.ExecContext(ctx, `
+            INSERT INTO machine_planogram (machine_id, product_id, target_count, price_per_play)
+            SELECT $1, id, $3, $4 FROM products
+            WHERE id = $2 AND org_id = $5
+        `, machineID, item.ProductID, item.TargetCount, item.PricePerPlay, orgID))
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,435 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"slices"
+	"time"
+
+	"vend_erp/internal/money"
+
+	"vend_erp/internal/models"
+	"vend_erp/internal/repository"
+)
+
+// Operations хранит операции обслуживания в таблице vending_operations,
+// а товары пополнений — в operation_items.
+type Operations struct {
+	db *sql.DB
+}
+
+const operationColumns = `
+        SELECT
+            o.id, o.vending_machine_id, o.operation_type, o.performed_by,
+            o.operation_date, COALESCE(o.toys_before, 0), COALESCE(o.toys_after, 0),
+            COALESCE(o.toys_added, 0), COALESCE(o.cash_before, 0),
+            COALESCE(o.cash_after, 0), COALESCE(o.cash_collected, 0),
+            o.created_at, o.updated_at, o.version,
+            COALESCE(vm.serial_number, '') as machine_serial,
+            COALESCE(u.username, '') as performer_name,
+            COALESCE(o.warehouse_id, 0), COALESCE(w.name, '') as warehouse_name,
+            o.org_id, org.name as org_name
+        FROM vending_operations o
+        LEFT JOIN vending_machines vm ON o.vending_machine_id = vm.id
+        LEFT JOIN users u ON o.performed_by = u.id
+        LEFT JOIN warehouse w ON w.id = o.warehouse_id
+        JOIN organizations org ON org.id = o.org_id
+`
+
+func scanOperation(row rowScanner) (models.VendingOperation, error) {
+	var operation models.VendingOperation
+	var operationDate, createdAt, updatedAt sql.NullTime
+
+	err := row.Scan(
+		&operation.ID, &operation.VendingMachineID, &operation.OperationType,
+		&operation.PerformedBy, &operationDate, &operation.ToysBefore,
+		&operation.ToysAfter, &operation.ToysAdded, &operation.CashBefore,
+		&operation.CashAfter, &operation.CashCollected, &createdAt, &updatedAt,
+		&operation.Version,
+		&operation.MachineSerial, &operation.PerformerName,
+		&operation.WarehouseID, &operation.WarehouseName,
+		&operation.OrgID, &operation.OrgName,
+	)
+	operation.OperationDate = operationDate.Time
+	operation.CreatedAt = createdAt.Time
+	operation.UpdatedAt = updatedAt.Time
+	return operation, err
+}
+
+func (r *Operations) List(ctx context.Context, scope repository.Scope) ([]models.VendingOperation, error) {
+	rows, err := r.db.QueryContext(ctx, operationColumns+`
+        WHERE ($1::bigint IS NULL OR o.org_id = $1)
+        ORDER BY o.operation_date DESC, o.id DESC
+    `, scope.Param())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var operations []models.VendingOperation
+	for rows.Next() {
+		operation, err := scanOperation(rows)
+		if err != nil {
+			return nil, err
+		}
+		operations = append(operations, operation)
+	}
+	return operations, rows.Err()
+}
+
+func (r *Operations) Get(ctx context.Context, scope repository.Scope, id int64) (models.VendingOperation, error) {
+	operation, err := scanOperation(r.db.QueryRowContext(ctx, operationColumns+`
+        WHERE o.id = $1 AND ($2::bigint IS NULL OR o.org_id = $2)
+    `, id, scope.Param()))
+	if err != nil {
+		return operation, translate(err)
+	}
+
+	rows, err := r.db.QueryContext(ctx, `
+        SELECT i.id, i.operation_id, i.product_id, i.count_before, i.added, i.price_per_play,
+               p.name, p.sku
+        FROM operation_items i
+        JOIN products p ON p.id = i.product_id
+        WHERE i.operation_id = $1
+        ORDER BY p.name, i.id
+    `, id)
+	if err != nil {
+		return operation, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var item models.OperationItem
+		err := rows.Scan(&item.ID, &item.OperationID, &item.ProductID, &item.CountBefore, &item.Added,
+			&item.PricePerPlay, &item.ProductName, &item.SKU)
+		if err != nil {
+			return operation, err
+		}
+		operation.Items = append(operation.Items, item)
+	}
+	return operation, rows.Err()
+}
+
+func (r *Operations) Create(ctx context.Context, operation *models.VendingOperation) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRowContext(ctx, `
+        INSERT INTO vending_operations
+        (vending_machine_id, operation_type, performed_by, operation_date,
+         toys_before, toys_after, toys_added, cash_before, cash_after, cash_collected,
+         warehouse_id, org_id)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+        RETURNING id, version
+    `, operation.VendingMachineID, operation.OperationType, operation.PerformedBy,
+		operation.OperationDate, operation.ToysBefore, operation.ToysAfter,
+		operation.ToysAdded, operation.CashBefore, operation.CashAfter,
+		operation.CashCollected, nullIfZero(operation.WarehouseID), operation.OrgID,
+	).Scan(&operation.ID, &operation.Version)
+	if err != nil {
+		return translate(err)
+	}
+	if err := saveItems(ctx, tx, operation.ID, operation.Items); err != nil {
+		return err
+	}
+	if err := issueRestock(ctx, tx, operation.ID, false); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (r *Operations) Update(ctx context.Context, scope repository.Scope, operation models.VendingOperation) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, `
+        UPDATE vending_operations
+        SET vending_machine_id=$1, operation_type=$2, performed_by=$3,
+            operation_date=$4, toys_before=$5, toys_after=$6, toys_added=$7,
+            cash_before=$8, cash_after=$9, cash_collected=$10, warehouse_id=$14,
+            updated_at=CURRENT_TIMESTAMP, version = version + 1
+        WHERE id=$11 AND ($12::bigint IS NULL OR org_id = $12) AND version = $13
+    `, operation.VendingMachineID, operation.OperationType, operation.PerformedBy,
+		operation.OperationDate, operation.ToysBefore, operation.ToysAfter,
+		operation.ToysAdded, operation.CashBefore, operation.CashAfter,
+		operation.CashCollected, operation.ID, scope.Param(), operation.Version,
+		nullIfZero(operation.WarehouseID))
+	err = versioned(ctx, tx, result, err,
+		"SELECT 1 FROM vending_operations WHERE id = $1 AND ($2::bigint IS NULL OR org_id = $2)",
+		operation.ID, scope.Param())
+	if err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, "DELETE FROM operation_items WHERE operation_id = $1", operation.ID); err != nil {
+		return err
+	}
+	if err := saveItems(ctx, tx, operation.ID, operation.Items); err != nil {
+		return err
+	}
+	if err := issueRestock(ctx, tx, operation.ID, false); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// saveItems записывает товары пополнения; товар должен быть из
+// организации операции.
+func saveItems(ctx context.Context, tx *sql.Tx, operationID int64, items []models.OperationItem) error {
+	for _, item := range items {
+		err := affected(tx.ExecContext(ctx, `
+            INSERT INTO operation_items (operation_id, product_id, count_before, added, price_per_play)
+            SELECT o.id, p.id, $3, $4, $5
+            FROM vending_operations o
+            JOIN products p ON p.org_id = o.org_id
+            WHERE o.id = $1 AND p.id = $2
+        `, operationID, item.ProductID, item.CountBefore, item.Added, item.PricePerPlay))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *Operations) Delete(ctx context.Context, scope repository.Scope, id int64) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var found bool
+	err = tx.QueryRowContext(ctx, `
+        SELECT EXISTS (SELECT 1 FROM vending_operations WHERE id = $1 AND ($2::bigint IS NULL OR org_id = $2))
+    `, id, scope.Param()).Scan(&found)
+	if err != nil || !found {
+		return err
+	}
+	if err := issueRestock(ctx, tx, id, true); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, "DELETE FROM vending_operations WHERE id = $1", id); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// issuedStock — выданное операцией с позиции склада.
+type issuedStock struct {
+	quantity int
+	cost     money.Amount
+}
+
+// issueRestock доводит выданное со склада по операции id до ее
+// пополнения: недостающее выдается движением restock, лишнее
+// возвращается на склад по себестоимости выдачи. У удаляемой операции
+// (remove) пополнения нет, и на склад возвращается все выданное.
+func issueRestock(ctx context.Context, tx *sql.Tx, id int64, remove bool) error {
+	var orgID, machineID, warehouseID int64
+	var kind string
+	err := tx.QueryRowContext(ctx, `
+        SELECT org_id, vending_machine_id, COALESCE(warehouse_id, 0), operation_type
+        FROM vending_operations
+        WHERE id = $1
+        FOR UPDATE
+    `, id).Scan(&orgID, &machineID, &warehouseID, &kind)
+	if err != nil {
+		return translate(err)
+	}
+
+	issued := make(map[int64]issuedStock)
+	rows, err := tx.QueryContext(ctx, `
+        SELECT item_id, -SUM(quantity), -SUM(cost)
+        FROM stock_movements
+        WHERE operation_id = $1
+        GROUP BY item_id
+    `, id)
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		var itemID int64
+		var stock issuedStock
+		if err := rows.Scan(&itemID, &stock.quantity, &stock.cost); err != nil {
+			rows.Close()
+			return err
+		}
+		issued[itemID] = stock
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	target := make(map[int64]int)
+	if !remove && warehouseID != 0 {
+		var found bool
+		err := tx.QueryRowContext(ctx, `
+            SELECT EXISTS (SELECT 1 FROM warehouse WHERE id = $1 AND org_id = $2)
+        `, warehouseID, orgID).Scan(&found)
+		if err != nil {
+			return err
+		}
+		if !found {
+			return repository.ErrNotFound
+		}
+		if kind == "restock" {
+			if target, err = restockTarget(ctx, tx, id, warehouseID); err != nil {
+				return err
+			}
+		}
+	}
+
+	// Позиции блокируются по возрастанию ID, как в Transfer
+	var ids []int64
+	for itemID := range issued {
+		ids = append(ids, itemID)
+	}
+	for itemID := range target {
+		if _, ok := issued[itemID]; !ok {
+			ids = append(ids, itemID)
+		}
+	}
+	slices.Sort(ids)
+
+	scope := repository.Scope{OrgID: orgID}
+	reason := fmt.Sprintf("Операция №%d", id)
+	touched := make(map[int64]bool)
+	for _, itemID := range ids {
+		back := issued[itemID]
+		diff := target[itemID] - back.quantity
+		if diff == 0 {
+			continue
+		}
+		item, err := lockItem(ctx, tx, scope, itemID)
+		if err != nil {
+			return err
+		}
+		touched[item.warehouseID] = true
+		if diff < 0 {
+			_, _, err := move(ctx, tx, &item, models.StockMovement{
+				Type: repository.MovementRestock, Quantity: -diff, Cost: back.cost.Share(-diff, back.quantity),
+				VendingMachineID: machineID, OperationID: id, Reason: reason + ": возврат на склад",
+			}, models.StockLot{UnitCost: back.cost.Share(1, back.quantity), Quantity: -diff})
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		reserves, err := restockReserves(ctx, tx, item.id, machineID)
+		if err != nil {
+			return err
+		}
+		reserved, err := reservedQuantity(ctx, tx, item.id, 0)
+		if err != nil {
+			return err
+		}
+		available := max(item.quantity-reserved, 0)
+		held := 0
+		for _, res := range reserves {
+			held += res.quantity
+		}
+		if diff > available+held {
+			return repository.ErrInsufficientStock
+		}
+		m, _, err := move(ctx, tx, &item, models.StockMovement{
+			Type: repository.MovementRestock, Quantity: -diff,
+			VendingMachineID: machineID, OperationID: id, Reason: reason,
+		})
+		if err != nil {
+			return err
+		}
+		if err := takeReserves(ctx, tx, reserves, diff, available, m.ID); err != nil {
+			return err
+		}
+	}
+	for warehouseID := range touched {
+		if err := updateUsage(ctx, tx, warehouseID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// restockTarget возвращает, сколько каждой позиции склада warehouseID
+// добавлено пополнением operationID; ErrInsufficientStock — товара
+// нет на складе.
+func restockTarget(ctx context.Context, tx *sql.Tx, operationID, warehouseID int64) (map[int64]int, error) {
+	rows, err := tx.QueryContext(ctx, `
+        SELECT COALESCE(wi.id, 0), oi.added
+        FROM operation_items oi
+        LEFT JOIN warehouse_inventory wi ON wi.product_id = oi.product_id AND wi.warehouse_id = $2
+        WHERE oi.operation_id = $1 AND oi.added > 0
+    `, operationID, warehouseID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	target := make(map[int64]int)
+	for rows.Next() {
+		var itemID int64
+		var added int
+		if err := rows.Scan(&itemID, &added); err != nil {
+			return nil, err
+		}
+		if itemID == 0 {
+			return nil, repository.ErrInsufficientStock
+		}
+		target[itemID] += added
+	}
+	return target, rows.Err()
+}
+
+func (r *Operations) Sales(ctx context.Context, scope repository.Scope, from, to time.Time) ([]models.ProductSales, error) {
+	// Предыдущее пополнение ищется по всем датам, а не только в периоде
+	rows, err := r.db.QueryContext(ctx, `
+        WITH restocks AS (
+            SELECT o.id, o.vending_machine_id, o.operation_date,
+                   LAG(o.id) OVER (PARTITION BY o.vending_machine_id
+                                   ORDER BY o.operation_date, o.id) AS prev_id
+            FROM vending_operations o
+            WHERE o.operation_type = 'restock' AND ($1::bigint IS NULL OR o.org_id = $1)
+        ), sold AS (
+            SELECT r.vending_machine_id, cur.product_id, prev.price_per_play,
+                   GREATEST(prev.count_before + prev.added - cur.count_before, 0) AS quantity
+            FROM restocks r
+            JOIN operation_items cur ON cur.operation_id = r.id
+            JOIN operation_items prev ON prev.operation_id = r.prev_id AND prev.product_id = cur.product_id
+            WHERE r.operation_date >= $2 AND r.operation_date < $3
+        )
+        SELECT COALESCE(l.id, 0), COALESCE(l.name, 'Не назначена'), org.name,
+               p.id, p.name, p.sku,
+               SUM(s.quantity), SUM(s.quantity * s.price_per_play)
+        FROM sold s
+        JOIN vending_machines m ON m.id = s.vending_machine_id
+        LEFT JOIN locations l ON l.id = m.location_id
+        JOIN organizations org ON org.id = m.org_id
+        JOIN products p ON p.id = s.product_id
+        GROUP BY l.id, l.name, org.name, p.id, p.name, p.sku
+        HAVING SUM(s.quantity) > 0
+        ORDER BY COALESCE(l.name, 'Не назначена'), l.id, SUM(s.quantity) DESC, p.name
+    `, scope.Param(), from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var list []models.ProductSales
+	for rows.Next() {
+		var sales models.ProductSales
+		err := rows.Scan(&sales.LocationID, &sales.LocationName, &sales.OrgName,
+			&sales.ProductID, &sales.ProductName, &sales.SKU, &sales.Sold, &sales.PlayValue)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, sales)
+	}
+	return list, rows.Err()
+}
@@ -0,0 +1,101 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+
+	"vend_erp/internal/models"
+	"vend_erp/internal/repository"
+)
+
+// Organizations хранит организации в таблице organizations.
+type Organizations struct {
+	db *sql.DB
+}
+
+const organizationColumns = `
+        SELECT o.id, o.name, o.slug, o.is_active, o.costing_method, o.created_at, o.updated_at
+        FROM organizations o
+`
+
+func scanOrganization(row rowScanner) (models.Organization, error) {
+	var org models.Organization
+	err := row.Scan(&org.ID, &org.Name, &org.Slug, &org.IsActive, &org.CostingMethod, &org.CreatedAt, &org.UpdatedAt)
+	return org, err
+}
+
+func (r *Organizations) query(ctx context.Context, query string, args ...interface{}) ([]models.Organization, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var orgs []models.Organization
+	for rows.Next() {
+		org, err := scanOrganization(rows)
+		if err != nil {
+			return nil, err
+		}
+		orgs = append(orgs, org)
+	}
+	return orgs, rows.Err()
+}
+
+func (r *Organizations) List(ctx context.Context) ([]models.Organization, error) {
+	return r.query(ctx, organizationColumns+"ORDER BY o.name")
+}
+
+func (r *Organizations) Member(ctx context.Context, userID int64) ([]models.Organization, error) {
+	return r.query(ctx, organizationColumns+`
+        JOIN user_organizations uo ON uo.org_id = o.id
+        WHERE uo.user_id = $1 AND o.is_active = true
+        ORDER BY o.name
+    `, userID)
+}
+
+func (r *Organizations) Get(ctx context.Context, id int64) (models.Organization, error) {
+	org, err := scanOrganization(r.db.QueryRowContext(ctx, organizationColumns+"WHERE o.id = $1", id))
+	return org, translate(err)
+}
+
+func (r *Organizations) FindBySlug(ctx context.Context, slug string) (models.Organization, error) {
+	org, err := scanOrganization(r.db.QueryRowContext(ctx, organizationColumns+"WHERE o.slug = $1", slug))
+	return org, translate(err)
+}
+
+func (r *Organizations) Create(ctx context.Context, org *models.Organization) error {
+	err := r.db.QueryRowContext(ctx, `
+        INSERT INTO organizations (name, slug, is_active, costing_method)
+        VALUES ($1, $2, $3, $4)
+        RETURNING id, created_at, updated_at
+    `, org.Name, org.Slug, org.IsActive, org.CostingMethod).Scan(&org.ID, &org.CreatedAt, &org.UpdatedAt)
+	return translate(err)
+}
+
+func (r *Organizations) Update(ctx context.Context, org models.Organization) error {
+	// Организацию по умолчанию нельзя отключить или переименовать в коде:
+	// в нее попадают новые пользователи
+	return affected(r.db.ExecContext(ctx, `
+        UPDATE organizations
+        SET name = $1,
+            slug = CASE WHEN slug = $5 THEN slug ELSE $2 END,
+            is_active = CASE WHEN slug = $5 THEN true ELSE $3 END,
+            costing_method = $6,
+            updated_at = CURRENT_TIMESTAMP
+        WHERE id = $4
+    `, org.Name, org.Slug, org.IsActive, org.ID, repository.DefaultOrganization, org.CostingMethod))
+}
+
+func (r *Organizations) CanSwitch(ctx context.Context, userID, orgID int64, superAdmin bool) (bool, error) {
+	var allowed bool
+	err := r.db.QueryRowContext(ctx, `
+        SELECT EXISTS(
+            SELECT 1 FROM organizations o
+            WHERE o.id = $1 AND o.is_active = true
+              AND ($3 OR EXISTS(
+                  SELECT 1 FROM user_organizations uo
+                  WHERE uo.org_id = o.id AND uo.user_id = $2)))
+    `, orgID, userID, superAdmin).Scan(&allowed)
+	return allowed, err
+}
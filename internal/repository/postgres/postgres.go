@@ -0,0 +1,117 @@
+// Package postgres реализует хранилища repository поверх PostgreSQL.
+// Все запросы получают контекст вызывающего, чтобы спаны otelsql
+// попадали в трассу HTTP-запроса, а отмена запроса прерывала SQL.
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"vend_erp/internal/repository"
+)
+
+// New возвращает хранилища всех агрегатов поверх одного пула соединений.
+func New(db *sql.DB) repository.Repos {
+	return repository.Repos{
+		Machines:      &Machines{db: db},
+		Locations:     &Locations{db: db},
+		Operations:    &Operations{db: db},
+		Products:      &Products{db: db},
+		Inventory:     &Inventory{db: db},
+		Supplies:      &Supplies{db: db},
+		Stocktakes:    &Stocktakes{db: db},
+		Users:         &Users{db: db},
+		Sessions:      &Sessions{db: db},
+		Organizations: &Organizations{db: db},
+		Invites:       &Invites{db: db},
+		Reports:       &Reports{db: db},
+	}
+}
+
+// SQLSTATE нарушений уникального ограничения и внешнего ключа.
+const (
+	uniqueViolation     = "23505"
+	foreignKeyViolation = "23503"
+)
+
+// translate переводит ошибки драйвера в ошибки пакета repository.
+func translate(err error) error {
+	if errors.Is(err, sql.ErrNoRows) {
+		return repository.ErrNotFound
+	}
+	var state interface{ SQLState() string }
+	if errors.As(err, &state) {
+		switch state.SQLState() {
+		case uniqueViolation:
+			return repository.ErrDuplicate
+		case foreignKeyViolation:
+			return repository.ErrInUse
+		}
+	}
+	return err
+}
+
+// affected возвращает ErrNotFound, если запрос не изменил ни одной строки.
+func affected(result sql.Result, err error) error {
+	if err != nil {
+		return translate(err)
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return repository.ErrNotFound
+	}
+	return nil
+}
+
+// rowQuerier — *sql.DB или *sql.Tx для запросов одной строки.
+type rowQuerier interface {
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// versioned проверяет результат UPDATE с условием на версию. Если строка
+// не изменилась, запрос exists (с параметрами args) отличает запись,
+// которую успели сохранить раньше (ErrConflict), от отсутствующей.
+// Внутри транзакции db — ее *sql.Tx.
+func versioned(ctx context.Context, db rowQuerier, result sql.Result, err error, exists string, args ...interface{}) error {
+	err = affected(result, err)
+	if !errors.Is(err, repository.ErrNotFound) {
+		return err
+	}
+	var found bool
+	if err := db.QueryRowContext(ctx, "SELECT EXISTS ("+exists+")", args...).Scan(&found); err != nil {
+		return err
+	}
+	if found {
+		return repository.ErrConflict
+	}
+	return repository.ErrNotFound
+}
+
+func nullIfZeroTime(t time.Time) interface{} {
+	if t.IsZero() {
+		return nil
+	}
+	return t
+}
+
+func nullIfEmpty(s string) interface{} {
+	if s == "" {
+		return nil
+	}
+	return s
+}
+
+func nullIfZero(id int64) interface{} {
+	if id == 0 {
+		return nil
+	}
+	return id
+}
+
+// rowScanner — общее у *sql.Row и *sql.Rows.
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
@@ -0,0 +1,30 @@
+package postgres_test
+
+import (
+	"database/sql"
+	"os"
+	"testing"
+
+	_ "github.com/jackc/pgx/v4/stdlib"
+
+	"vend_erp/internal/repository/repotest"
+	"vend_erp/migrations"
+)
+
+// TestPostgresRepos запускает контрактные тесты на базе TEST_DATABASE_URL;
+// миграции применяются перед запуском. Без переменной тест пропускается.
+func TestPostgresRepos(t *testing.T) {
+	url := os.Getenv("TEST_DATABASE_URL")
+	if url == "" {
+		t.Skip("TEST_DATABASE_URL не задан")
+	}
+	db, err := sql.Open("pgx", url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if err := migrations.RunMigrations(db, migrations.FS); err != nil {
+		t.Fatalf("applying migrations: %v", err)
+	}
+	repotest.Run(t, repotest.Postgres(db))
+}
@@ -0,0 +1,149 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+
+	"vend_erp/internal/models"
+	"vend_erp/internal/repository"
+)
+
+// Products хранит справочник товаров в таблице products.
+type Products struct {
+	db *sql.DB
+}
+
+const productColumns = `
+        SELECT p.id, p.org_id, p.sku, p.name, p.item_type, p.category_id,
+               COALESCE(p.description, ''), COALESCE(p.barcode, ''), COALESCE(p.photo_url, ''),
+               p.default_cost, p.default_price, COALESCE(p.supplier_name, ''), p.unit_volume,
+               p.min_order_quantity, p.pack_size, p.is_active,
+               p.created_at, p.updated_at, p.version,
+               COALESCE(c.name, ''), o.name,
+               COALESCE((SELECT SUM(wi.quantity) FROM warehouse_inventory wi WHERE wi.product_id = p.id), 0)
+        FROM products p
+        JOIN organizations o ON o.id = p.org_id
+        LEFT JOIN warehouse_categories c ON c.id = p.category_id
+`
+
+func scanProduct(row rowScanner) (models.Product, error) {
+	var product models.Product
+	err := row.Scan(
+		&product.ID, &product.OrgID, &product.SKU, &product.Name, &product.ItemType,
+		&product.CategoryID, &product.Description, &product.Barcode, &product.PhotoURL,
+		&product.DefaultCost, &product.DefaultPrice, &product.SupplierName, &product.UnitVolume,
+		&product.MinOrderQuantity, &product.PackSize, &product.IsActive,
+		&product.CreatedAt, &product.UpdatedAt, &product.Version,
+		&product.CategoryName, &product.OrgName, &product.Stock,
+	)
+	return product, err
+}
+
+func (r *Products) query(ctx context.Context, query string, args ...interface{}) ([]models.Product, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var products []models.Product
+	for rows.Next() {
+		product, err := scanProduct(rows)
+		if err != nil {
+			return nil, err
+		}
+		products = append(products, product)
+	}
+	return products, rows.Err()
+}
+
+func (r *Products) List(ctx context.Context, scope repository.Scope) ([]models.Product, error) {
+	return r.query(ctx, productColumns+`
+        WHERE ($1::bigint IS NULL OR p.org_id = $1)
+        ORDER BY p.name, p.id
+    `, scope.Param())
+}
+
+func (r *Products) Get(ctx context.Context, scope repository.Scope, id int64) (models.Product, error) {
+	product, err := scanProduct(r.db.QueryRowContext(ctx, productColumns+`
+        WHERE p.id = $1 AND ($2::bigint IS NULL OR p.org_id = $2)
+    `, id, scope.Param()))
+	return product, translate(err)
+}
+
+func (r *Products) ListActive(ctx context.Context, orgID int64) ([]models.Product, error) {
+	return r.query(ctx, productColumns+`
+        WHERE p.is_active = true AND p.org_id = $1
+        ORDER BY p.name, p.id
+    `, orgID)
+}
+
+func (r *Products) Create(ctx context.Context, product *models.Product) error {
+	if product.UnitVolume <= 0 {
+		product.UnitVolume = 1
+	}
+	if product.PackSize <= 0 {
+		product.PackSize = 1
+	}
+	err := r.db.QueryRowContext(ctx, `
+        INSERT INTO products (org_id, sku, name, item_type, category_id, description,
+                              barcode, photo_url, default_cost, default_price,
+                              supplier_name, unit_volume, min_order_quantity, pack_size, is_active)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15)
+        RETURNING id, version
+    `, product.OrgID, product.SKU, product.Name, product.ItemType, product.CategoryID,
+		product.Description, nullIfEmpty(product.Barcode), nullIfEmpty(product.PhotoURL),
+		product.DefaultCost, product.DefaultPrice, nullIfEmpty(product.SupplierName),
+		product.UnitVolume, product.MinOrderQuantity, product.PackSize, product.IsActive).Scan(&product.ID, &product.Version)
+	return translate(err)
+}
+
+func (r *Products) Update(ctx context.Context, scope repository.Scope, product models.Product) error {
+	if product.UnitVolume <= 0 {
+		product.UnitVolume = 1
+	}
+	if product.PackSize <= 0 {
+		product.PackSize = 1
+	}
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, `
+        UPDATE products
+        SET sku=$1, name=$2, item_type=$3, category_id=$4, description=$5,
+            barcode=$6, photo_url=$7, default_cost=$8, default_price=$9,
+            supplier_name=$10, unit_volume=$11, min_order_quantity=$12, pack_size=$13,
+            is_active=$14, updated_at=CURRENT_TIMESTAMP, version = version + 1
+        WHERE id=$15 AND ($16::bigint IS NULL OR org_id = $16) AND version = $17
+    `, product.SKU, product.Name, product.ItemType, product.CategoryID, product.Description,
+		nullIfEmpty(product.Barcode), nullIfEmpty(product.PhotoURL),
+		product.DefaultCost, product.DefaultPrice, nullIfEmpty(product.SupplierName),
+		product.UnitVolume, product.MinOrderQuantity, product.PackSize, product.IsActive,
+		product.ID, scope.Param(), product.Version)
+	err = versioned(ctx, r.db, result, err,
+		"SELECT 1 FROM products WHERE id = $1 AND ($2::bigint IS NULL OR org_id = $2)",
+		product.ID, scope.Param())
+	if err != nil {
+		return err
+	}
+
+	// Объем единицы меняет загрузку складов, где лежит товар
+	_, err = tx.ExecContext(ctx, `
+        UPDATE warehouse SET current_usage = `+warehouseUsage+`
+        WHERE id IN (SELECT warehouse_id FROM warehouse_inventory WHERE product_id = $1)
+    `, product.ID)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (r *Products) Delete(ctx context.Context, scope repository.Scope, id int64) error {
+	_, err := r.db.ExecContext(ctx,
+		"DELETE FROM products WHERE id = $1 AND ($2::bigint IS NULL OR org_id = $2)",
+		id, scope.Param())
+	return translate(err)
+}
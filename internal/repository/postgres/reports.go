@@ -0,0 +1,232 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"vend_erp/internal/models"
+	"vend_erp/internal/repository"
+)
+
+// Reports считает показатели дашборда и графиков по таблицам автоматов,
+// операций и складского учета.
+type Reports struct {
+	db *sql.DB
+}
+
+func (r *Reports) Machines(ctx context.Context, scope repository.Scope) (repository.MachineTotals, error) {
+	var totals repository.MachineTotals
+	err := r.db.QueryRowContext(ctx, `
+        SELECT COUNT(*),
+               COUNT(*) FILTER (WHERE status = 'active'),
+               COALESCE(SUM(cash_amount), 0),
+               COALESCE(SUM(current_toys_count), 0)
+        FROM vending_machines
+        WHERE ($1::bigint IS NULL OR org_id = $1)
+    `, scope.Param()).Scan(&totals.Count, &totals.Active, &totals.Cash, &totals.Toys)
+	return totals, err
+}
+
+func (r *Reports) OperationCounts(ctx context.Context, scope repository.Scope) (map[string]int, error) {
+	rows, err := r.db.QueryContext(ctx, `
+        SELECT operation_type, COUNT(*)
+        FROM vending_operations
+        WHERE ($1::bigint IS NULL OR org_id = $1)
+        GROUP BY operation_type
+    `, scope.Param())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := make(map[string]int)
+	for rows.Next() {
+		var opType string
+		var count int
+		if err := rows.Scan(&opType, &count); err != nil {
+			return nil, err
+		}
+		counts[opType] = count
+	}
+	return counts, rows.Err()
+}
+
+func (r *Reports) Warehouses(ctx context.Context, scope repository.Scope) (repository.WarehouseTotals, error) {
+	var totals repository.WarehouseTotals
+	// Стоимость — себестоимость остатков по партиям
+	err := r.db.QueryRowContext(ctx, `
+        SELECT COALESCE(SUM(wi.stock_value), 0),
+               COUNT(*) FILTER (WHERE wi.quantity < wi.min_stock_level AND wi.quantity > 0),
+               COUNT(*) FILTER (WHERE wi.quantity = 0)
+        FROM warehouse_inventory wi
+        JOIN warehouse w ON wi.warehouse_id = w.id
+        WHERE w.is_active = true AND ($1::bigint IS NULL OR w.org_id = $1)
+    `, scope.Param()).Scan(&totals.Value, &totals.LowStock, &totals.OutOfStock)
+	if err != nil {
+		return totals, err
+	}
+
+	err = r.db.QueryRowContext(ctx, `
+        SELECT COUNT(*) FROM warehouse
+        WHERE is_active = true AND ($1::bigint IS NULL OR org_id = $1)
+    `, scope.Param()).Scan(&totals.Warehouses)
+	return totals, err
+}
+
+func (r *Reports) StockByType(ctx context.Context, scope repository.Scope) ([]repository.TypeCount, error) {
+	rows, err := r.db.QueryContext(ctx, `
+        SELECT p.item_type, COUNT(*) AS count
+        FROM warehouse_inventory wi
+        JOIN products p ON p.id = wi.product_id
+        JOIN warehouse w ON wi.warehouse_id = w.id
+        WHERE w.is_active = true AND ($1::bigint IS NULL OR w.org_id = $1)
+        GROUP BY p.item_type
+        ORDER BY count DESC, p.item_type
+    `, scope.Param())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var types []repository.TypeCount
+	for rows.Next() {
+		var tc repository.TypeCount
+		if err := rows.Scan(&tc.ItemType, &tc.Count); err != nil {
+			return nil, err
+		}
+		types = append(types, tc)
+	}
+	return types, rows.Err()
+}
+
+func (r *Reports) LowStock(ctx context.Context, scope repository.Scope, limit int) ([]models.WarehouseInventory, error) {
+	rows, err := r.db.QueryContext(ctx, `
+        SELECT wi.id, wi.warehouse_id, wi.product_id, p.name, p.item_type,
+               wi.quantity, wi.min_stock_level, w.name
+        FROM warehouse_inventory wi
+        JOIN products p ON p.id = wi.product_id
+        JOIN warehouse w ON wi.warehouse_id = w.id
+        WHERE w.is_active = true
+          AND wi.quantity < wi.min_stock_level
+          AND wi.quantity > 0
+          AND ($1::bigint IS NULL OR w.org_id = $1)
+        ORDER BY (wi.min_stock_level - wi.quantity) DESC, wi.id
+        LIMIT $2
+    `, scope.Param(), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []models.WarehouseInventory
+	for rows.Next() {
+		var item models.WarehouseInventory
+		err := rows.Scan(&item.ID, &item.WarehouseID, &item.ProductID, &item.ItemName, &item.ItemType,
+			&item.Quantity, &item.MinStockLevel, &item.WarehouseName)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
+
+func (r *Reports) MachineHistory(ctx context.Context, scope repository.Scope, from, to time.Time) ([]repository.MachineDay, error) {
+	rows, err := r.db.QueryContext(ctx, `
+        SELECT ds.chart_date,
+               COUNT(vm.id),
+               COUNT(vm.id) FILTER (WHERE vm.status = 'active' OR vm.status IS NULL),
+               COALESCE(SUM(vm.cash_amount), 0),
+               COALESCE(SUM(vm.current_toys_count), 0)
+        FROM (SELECT generate_series($1::date, $2::date, '1 day')::date AS chart_date) ds
+        LEFT JOIN vending_machines vm ON date(vm.created_at) <= ds.chart_date
+            AND ($3::bigint IS NULL OR vm.org_id = $3)
+        GROUP BY ds.chart_date
+        ORDER BY ds.chart_date
+    `, from, to, scope.Param())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var days []repository.MachineDay
+	for rows.Next() {
+		var day repository.MachineDay
+		if err := rows.Scan(&day.Date, &day.Machines, &day.Active, &day.Cash, &day.Toys); err != nil {
+			return nil, err
+		}
+		days = append(days, day)
+	}
+	return days, rows.Err()
+}
+
+func (r *Reports) OperationHistory(ctx context.Context, scope repository.Scope, from, to time.Time) ([]repository.OperationDay, error) {
+	rows, err := r.db.QueryContext(ctx, `
+        SELECT date(created_at) AS op_date, operation_type, COUNT(*)
+        FROM vending_operations
+        WHERE created_at >= $1::date AND created_at < $2::date + 1
+          AND ($3::bigint IS NULL OR org_id = $3)
+        GROUP BY op_date, operation_type
+        ORDER BY op_date, operation_type
+    `, from, to, scope.Param())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var days []repository.OperationDay
+	for rows.Next() {
+		var day repository.OperationDay
+		if err := rows.Scan(&day.Date, &day.OperationType, &day.Count); err != nil {
+			return nil, err
+		}
+		days = append(days, day)
+	}
+	return days, rows.Err()
+}
+
+func (r *Reports) Revenue(ctx context.Context, scope repository.Scope, from, to time.Time) ([]repository.AmountDay, error) {
+	return r.amounts(ctx, `
+        SELECT date(operation_date) AS revenue_date, SUM(cash_collected)
+        FROM vending_operations
+        WHERE operation_type = 'collection'
+          AND operation_date >= $1::date AND operation_date < $2::date + 1
+          AND ($3::bigint IS NULL OR org_id = $3)
+        GROUP BY revenue_date
+        ORDER BY revenue_date
+    `, from, to, scope.Param())
+}
+
+func (r *Reports) StockValue(ctx context.Context, scope repository.Scope, from, to time.Time) ([]repository.AmountDay, error) {
+	return r.amounts(ctx, `
+        SELECT d.chart_date,
+               COALESCE((
+                   SELECT SUM(sm.cost)
+                   FROM stock_movements sm
+                   JOIN warehouse w ON w.id = sm.warehouse_id
+                   WHERE sm.created_at < d.chart_date + 1
+                     AND ($3::bigint IS NULL OR w.org_id = $3)
+               ), 0)
+        FROM (SELECT generate_series($1::date, $2::date, '1 day')::date AS chart_date) d
+        ORDER BY d.chart_date
+    `, from, to, scope.Param())
+}
+
+func (r *Reports) amounts(ctx context.Context, query string, args ...interface{}) ([]repository.AmountDay, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var days []repository.AmountDay
+	for rows.Next() {
+		var day repository.AmountDay
+		if err := rows.Scan(&day.Date, &day.Amount); err != nil {
+			return nil, err
+		}
+		days = append(days, day)
+	}
+	return days, rows.Err()
+}
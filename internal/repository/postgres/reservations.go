@@ -0,0 +1,224 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"vend_erp/internal/models"
+	"vend_erp/internal/repository"
+)
+
+// activeReservation отбирает действующие резервы stock_reservations sr.
+const activeReservation = `sr.status = 'active' AND sr.expires_at > CURRENT_TIMESTAMP`
+
+func (r *Inventory) Reservations(ctx context.Context, scope repository.Scope, itemID int64) ([]models.StockReservation, error) {
+	rows, err := r.db.QueryContext(ctx, `
+        SELECT sr.id, sr.item_id, sr.reservation_type, sr.quantity, COALESCE(sr.vending_machine_id, 0),
+               COALESCE(sr.reason, ''), sr.status, COALESCE(sr.movement_id, 0), sr.expires_at,
+               COALESCE(sr.created_by, 0), sr.created_at,
+               COALESCE(vm.serial_number, ''), COALESCE(u.username, '')
+        FROM stock_reservations sr
+        JOIN warehouse_inventory wi ON wi.id = sr.item_id
+        JOIN warehouse w ON w.id = wi.warehouse_id
+        LEFT JOIN vending_machines vm ON vm.id = sr.vending_machine_id
+        LEFT JOIN users u ON u.id = sr.created_by
+        WHERE sr.item_id = $1 AND ($2::bigint IS NULL OR w.org_id = $2) AND `+activeReservation+`
+        ORDER BY sr.expires_at, sr.id
+    `, itemID, scope.Param())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var list []models.StockReservation
+	for rows.Next() {
+		var res models.StockReservation
+		err := rows.Scan(&res.ID, &res.ItemID, &res.Type, &res.Quantity, &res.VendingMachineID,
+			&res.Reason, &res.Status, &res.MovementID, &res.ExpiresAt,
+			&res.CreatedBy, &res.CreatedAt, &res.MachineSerial, &res.CreatedByName)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, res)
+	}
+	return list, rows.Err()
+}
+
+func (r *Inventory) Reserve(ctx context.Context, scope repository.Scope, reservation *models.StockReservation) error {
+	if reservation.Type != repository.MovementShipment && reservation.Type != repository.MovementRestock {
+		return fmt.Errorf("unknown reservation type %q", reservation.Type)
+	}
+	if reservation.Quantity <= 0 {
+		return fmt.Errorf("reservation quantity must be positive, got %d", reservation.Quantity)
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Блокировка позиции упорядочивает резервы и расходы: два оператора
+	// не отложат один и тот же товар
+	item, err := lockItem(ctx, tx, scope, reservation.ItemID)
+	if err != nil {
+		return err
+	}
+	if reservation.VendingMachineID != 0 {
+		var found bool
+		err := tx.QueryRowContext(ctx, `
+            SELECT EXISTS (SELECT 1 FROM vending_machines WHERE id = $1 AND org_id = $2)
+        `, reservation.VendingMachineID, item.orgID).Scan(&found)
+		if err != nil {
+			return err
+		}
+		if !found {
+			return repository.ErrNotFound
+		}
+	}
+	reserved, err := reservedQuantity(ctx, tx, item.id, 0)
+	if err != nil {
+		return err
+	}
+	if item.quantity-reserved < reservation.Quantity {
+		return repository.ErrInsufficientStock
+	}
+
+	err = tx.QueryRowContext(ctx, `
+        INSERT INTO stock_reservations
+        (item_id, reservation_type, quantity, vending_machine_id, reason, expires_at, created_by)
+        VALUES ($1, $2, $3, $4, $5, $6, $7)
+        RETURNING id, status, created_at
+    `, item.id, reservation.Type, reservation.Quantity, nullIfZero(reservation.VendingMachineID),
+		nullIfEmpty(reservation.Reason), reservation.ExpiresAt, nullIfZero(reservation.CreatedBy),
+	).Scan(&reservation.ID, &reservation.Status, &reservation.CreatedAt)
+	if err != nil {
+		return translate(err)
+	}
+	return tx.Commit()
+}
+
+func (r *Inventory) Release(ctx context.Context, scope repository.Scope, id int64) error {
+	_, err := r.db.ExecContext(ctx, `
+        UPDATE stock_reservations sr
+        SET status = $3
+        FROM warehouse_inventory wi
+        JOIN warehouse w ON w.id = wi.warehouse_id
+        WHERE sr.id = $1 AND wi.id = sr.item_id AND ($2::bigint IS NULL OR w.org_id = $2)
+          AND `+activeReservation+`
+    `, id, scope.Param(), repository.ReservationReleased)
+	return err
+}
+
+// reservedQuantity возвращает, сколько товара позиции отложено
+// действующими резервами, кроме резерва exceptID.
+func reservedQuantity(ctx context.Context, tx *sql.Tx, itemID, exceptID int64) (int, error) {
+	var reserved int
+	err := tx.QueryRowContext(ctx, `
+        SELECT COALESCE(SUM(sr.quantity), 0)
+        FROM stock_reservations sr
+        WHERE sr.item_id = $1 AND sr.id <> $2 AND `+activeReservation+`
+    `, itemID, exceptID).Scan(&reserved)
+	return reserved, err
+}
+
+// lockReservation блокирует резерв, который расходует change: он должен
+// быть действующим резервом позиции того же типа.
+func lockReservation(ctx context.Context, tx *sql.Tx, itemID int64, change repository.StockChange) error {
+	var kind string
+	err := tx.QueryRowContext(ctx, `
+        SELECT sr.reservation_type
+        FROM stock_reservations sr
+        WHERE sr.id = $1 AND sr.item_id = $2 AND `+activeReservation+`
+        FOR UPDATE
+    `, change.ReservationID, itemID).Scan(&kind)
+	if err != nil {
+		return translate(err)
+	}
+	if kind != change.Type {
+		return repository.ErrNotFound
+	}
+	return nil
+}
+
+// restockReserve — действующий резерв позиции, из которого может взять
+// пополнение автомата.
+type restockReserve struct {
+	id       int64
+	quantity int
+	// machine — резерв под автомат пополнения, а не под рейс
+	machine bool
+}
+
+// restockReserves блокирует резервы позиции под пополнение автомата
+// machineID и под рейсы без автомата: сначала резервы автомата, затем
+// рейсов, ближайшие к сроку первыми.
+func restockReserves(ctx context.Context, tx *sql.Tx, itemID, machineID int64) ([]restockReserve, error) {
+	rows, err := tx.QueryContext(ctx, `
+        SELECT sr.id, sr.quantity, sr.vending_machine_id IS NOT NULL
+        FROM stock_reservations sr
+        WHERE sr.item_id = $1 AND sr.reservation_type = $3
+          AND (sr.vending_machine_id = $2 OR sr.vending_machine_id IS NULL) AND `+activeReservation+`
+        ORDER BY sr.vending_machine_id IS NULL, sr.expires_at, sr.id
+        FOR UPDATE
+    `, itemID, machineID, repository.MovementRestock)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var list []restockReserve
+	for rows.Next() {
+		var res restockReserve
+		if err := rows.Scan(&res.id, &res.quantity, &res.machine); err != nil {
+			return nil, err
+		}
+		list = append(list, res)
+	}
+	return list, rows.Err()
+}
+
+// takeReserves расходует резервы пополнения, из которых выдано quantity
+// единиц движением movementID сверх available доступных: резервы
+// автомата закрываются целиком, резервы рейсов уменьшаются на взятое.
+func takeReserves(ctx context.Context, tx *sql.Tx, reserves []restockReserve, quantity, available int, movementID int64) error {
+	// Сначала берется отложенное под автомат, затем доступное,
+	// затем отложенное под рейсы
+	rest := quantity
+	for _, res := range reserves {
+		if res.machine {
+			rest -= res.quantity
+			if err := consumeReservation(ctx, tx, res.id, movementID); err != nil {
+				return err
+			}
+		}
+	}
+	rest -= available
+	for _, res := range reserves {
+		if res.machine || rest <= 0 {
+			continue
+		}
+		taken := min(res.quantity, rest)
+		rest -= taken
+		if taken == res.quantity {
+			if err := consumeReservation(ctx, tx, res.id, movementID); err != nil {
+				return err
+			}
+			continue
+		}
+		_, err := tx.ExecContext(ctx, "UPDATE stock_reservations SET quantity = quantity - $2 WHERE id = $1", res.id, taken)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// consumeReservation отмечает резерв израсходованным движением movementID.
+func consumeReservation(ctx context.Context, tx *sql.Tx, id, movementID int64) error {
+	_, err := tx.ExecContext(ctx, `
+        UPDATE stock_reservations SET status = $2, movement_id = $3 WHERE id = $1
+    `, id, repository.ReservationConsumed, movementID)
+	return err
+}
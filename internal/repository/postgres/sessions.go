@@ -0,0 +1,56 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+
+	"vend_erp/internal/models"
+)
+
+// Sessions хранит сессии входа в таблице sessions.
+type Sessions struct {
+	db *sql.DB
+}
+
+func (r *Sessions) Create(ctx context.Context, session models.Session) error {
+	_, err := r.db.ExecContext(ctx, `
+        INSERT INTO sessions (id, user_id, expires_at, active_org_id, all_orgs)
+        VALUES ($1, $2, $3, $4, $5)
+    `, session.ID, session.UserID, session.ExpiresAt, nullIfZero(session.ActiveOrgID), session.AllOrgs)
+	return translate(err)
+}
+
+func (r *Sessions) Get(ctx context.Context, id string) (models.Session, error) {
+	session := models.Session{ID: id}
+	var activeOrgID sql.NullInt64
+	err := r.db.QueryRowContext(ctx, `
+        SELECT user_id, expires_at, active_org_id, all_orgs
+        FROM sessions
+        WHERE id = $1 AND expires_at > CURRENT_TIMESTAMP
+    `, id).Scan(&session.UserID, &session.ExpiresAt, &activeOrgID, &session.AllOrgs)
+	session.ActiveOrgID = activeOrgID.Int64
+	return session, translate(err)
+}
+
+func (r *Sessions) SetActiveOrg(ctx context.Context, id string, userID, orgID int64) error {
+	return affected(r.db.ExecContext(ctx, `
+        UPDATE sessions SET active_org_id = $1, all_orgs = false
+        WHERE id = $2 AND user_id = $3
+    `, orgID, id, userID))
+}
+
+func (r *Sessions) SetAllOrgs(ctx context.Context, id string, userID int64) error {
+	return affected(r.db.ExecContext(ctx, `
+        UPDATE sessions SET all_orgs = true WHERE id = $1 AND user_id = $2
+    `, id, userID))
+}
+
+func (r *Sessions) Delete(ctx context.Context, id string) error {
+	_, err := r.db.ExecContext(ctx, "DELETE FROM sessions WHERE id = $1", id)
+	return err
+}
+
+func (r *Sessions) DeleteOthers(ctx context.Context, userID int64, keepID string) error {
+	_, err := r.db.ExecContext(ctx, "DELETE FROM sessions WHERE user_id = $1 AND id <> $2", userID, keepID)
+	return err
+}
@@ -0,0 +1,292 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"vend_erp/internal/models"
+	"vend_erp/internal/repository"
+)
+
+// Stocktakes хранит инвентаризации (stocktakes, stocktake_lines).
+type Stocktakes struct {
+	db *sql.DB
+}
+
+const stocktakeColumns = `
+        SELECT s.id, s.warehouse_id, s.status, COALESCE(s.notes, ''),
+               COALESCE(s.created_by, 0), COALESCE(s.approved_by, 0), s.created_at, s.closed_at,
+               w.name, w.org_id, o.name, COALESCE(c.username, ''), COALESCE(a.username, ''),
+               (SELECT COUNT(*) FROM stocktake_lines l WHERE l.stocktake_id = s.id),
+               (SELECT COUNT(l.counted_quantity) FROM stocktake_lines l WHERE l.stocktake_id = s.id)
+        FROM stocktakes s
+        JOIN warehouse w ON w.id = s.warehouse_id
+        JOIN organizations o ON o.id = w.org_id
+        LEFT JOIN users c ON c.id = s.created_by
+        LEFT JOIN users a ON a.id = s.approved_by
+`
+
+func scanStocktake(row rowScanner) (models.Stocktake, error) {
+	var st models.Stocktake
+	var createdAt, closedAt sql.NullTime
+	err := row.Scan(
+		&st.ID, &st.WarehouseID, &st.Status, &st.Notes,
+		&st.CreatedBy, &st.ApprovedBy, &createdAt, &closedAt,
+		&st.WarehouseName, &st.OrgID, &st.OrgName, &st.CreatedByName, &st.ApprovedByName,
+		&st.LineCount, &st.CountedCount,
+	)
+	st.CreatedAt = createdAt.Time
+	st.ClosedAt = closedAt.Time
+	return st, err
+}
+
+func (r *Stocktakes) List(ctx context.Context, scope repository.Scope) ([]models.Stocktake, error) {
+	rows, err := r.db.QueryContext(ctx, stocktakeColumns+`
+        WHERE ($1::bigint IS NULL OR w.org_id = $1)
+        ORDER BY s.status = 'counting' DESC, s.created_at DESC, s.id DESC
+    `, scope.Param())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var list []models.Stocktake
+	for rows.Next() {
+		st, err := scanStocktake(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, st)
+	}
+	return list, rows.Err()
+}
+
+func (r *Stocktakes) Get(ctx context.Context, scope repository.Scope, id int64) (models.Stocktake, error) {
+	st, err := scanStocktake(r.db.QueryRowContext(ctx, stocktakeColumns+`
+        WHERE s.id = $1 AND ($2::bigint IS NULL OR w.org_id = $2)
+    `, id, scope.Param()))
+	if err != nil {
+		return st, translate(err)
+	}
+	st.Lines, err = r.lines(ctx, "WHERE l.stocktake_id = $1", id)
+	return st, err
+}
+
+const stocktakeLineColumns = `
+        SELECT l.id, l.stocktake_id, l.item_id, l.expected_quantity, l.unit_cost,
+               l.counted_quantity, COALESCE(l.counted_by, 0), l.counted_at,
+               p.name, p.sku, COALESCE(p.barcode, '')
+        FROM stocktake_lines l
+        JOIN warehouse_inventory wi ON wi.id = l.item_id
+        JOIN products p ON p.id = wi.product_id
+`
+
+func scanStocktakeLine(row rowScanner) (models.StocktakeLine, error) {
+	var line models.StocktakeLine
+	var counted sql.NullInt64
+	var countedAt sql.NullTime
+	err := row.Scan(&line.ID, &line.StocktakeID, &line.ItemID, &line.ExpectedQuantity, &line.UnitCost,
+		&counted, &line.CountedBy, &countedAt,
+		&line.ItemName, &line.SKU, &line.Barcode)
+	line.Counted = counted.Valid
+	line.CountedQuantity = int(counted.Int64)
+	line.CountedAt = countedAt.Time
+	return line, err
+}
+
+// lines читает позиции инвентаризации; where продолжает stocktakeLineColumns.
+func (r *Stocktakes) lines(ctx context.Context, where string, args ...interface{}) ([]models.StocktakeLine, error) {
+	rows, err := r.db.QueryContext(ctx, stocktakeLineColumns+where+`
+        ORDER BY p.name, l.id
+    `, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var lines []models.StocktakeLine
+	for rows.Next() {
+		line, err := scanStocktakeLine(rows)
+		if err != nil {
+			return nil, err
+		}
+		lines = append(lines, line)
+	}
+	return lines, rows.Err()
+}
+
+func (r *Stocktakes) Start(ctx context.Context, scope repository.Scope, stocktake *models.Stocktake) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var createdAt sql.NullTime
+	err = tx.QueryRowContext(ctx, `
+        INSERT INTO stocktakes (warehouse_id, status, notes, created_by)
+        SELECT id, $3, $4, $5 FROM warehouse
+        WHERE id = $1 AND ($2::bigint IS NULL OR org_id = $2)
+        RETURNING id, created_at
+    `, stocktake.WarehouseID, scope.Param(), repository.StocktakeCounting,
+		nullIfEmpty(stocktake.Notes), nullIfZero(stocktake.CreatedBy)).Scan(&stocktake.ID, &createdAt)
+	if err != nil {
+		return translate(err)
+	}
+
+	// Снимок делается одним запросом и поэтому согласован. Цена —
+	// средняя себестоимость остатка по учету, как в AverageCost
+	_, err = tx.ExecContext(ctx, `
+        INSERT INTO stocktake_lines (stocktake_id, item_id, expected_quantity, unit_cost)
+        SELECT $1, wi.id, wi.quantity,
+               CASE WHEN wi.quantity > 0 THEN ROUND(wi.stock_value / wi.quantity, 2)
+                    ELSE p.default_cost END
+        FROM warehouse_inventory wi
+        JOIN products p ON p.id = wi.product_id
+        WHERE wi.warehouse_id = $2
+    `, stocktake.ID, stocktake.WarehouseID)
+	if err != nil {
+		return err
+	}
+	stocktake.Status = repository.StocktakeCounting
+	stocktake.CreatedAt = createdAt.Time
+	return tx.Commit()
+}
+
+// openLine — условие на позицию открытой инвентаризации области;
+// продолжает UPDATE stocktake_lines l ... FROM stocktakes s, warehouse w.
+const openLine = `
+          AND s.id = l.stocktake_id AND s.status = 'counting'
+          AND w.id = s.warehouse_id AND ($2::bigint IS NULL OR w.org_id = $2)
+`
+
+func (r *Stocktakes) Count(ctx context.Context, scope repository.Scope, id, itemID int64, quantity int, userID int64) error {
+	if quantity < 0 {
+		return fmt.Errorf("counted quantity must not be negative, got %d", quantity)
+	}
+	return affected(r.db.ExecContext(ctx, `
+        UPDATE stocktake_lines l
+        SET counted_quantity = $4, counted_by = $5, counted_at = CURRENT_TIMESTAMP
+        FROM stocktakes s, warehouse w
+        WHERE l.stocktake_id = $1 AND l.item_id = $3
+    `+openLine, id, scope.Param(), itemID, quantity, nullIfZero(userID)))
+}
+
+func (r *Stocktakes) Scan(ctx context.Context, scope repository.Scope, id int64, code string, quantity int, userID int64) (models.StocktakeLine, error) {
+	if quantity <= 0 {
+		return models.StocktakeLine{}, fmt.Errorf("scanned quantity must be positive, got %d", quantity)
+	}
+	// Штрихкод одного товара может совпасть с артикулом другого;
+	// совпадение по штрихкоду важнее
+	var lineID int64
+	err := r.db.QueryRowContext(ctx, `
+        UPDATE stocktake_lines l
+        SET counted_quantity = COALESCE(l.counted_quantity, 0) + $4,
+            counted_by = $5, counted_at = CURRENT_TIMESTAMP
+        FROM stocktakes s, warehouse w
+        WHERE l.id = (
+            SELECT sl.id
+            FROM stocktake_lines sl
+            JOIN warehouse_inventory wi ON wi.id = sl.item_id
+            JOIN products p ON p.id = wi.product_id
+            WHERE sl.stocktake_id = $1 AND (p.barcode = $3 OR p.sku = $3)
+            ORDER BY p.barcode IS NOT DISTINCT FROM $3 DESC, sl.id
+            LIMIT 1
+        )
+    `+openLine+`
+        RETURNING l.id
+    `, id, scope.Param(), code, quantity, nullIfZero(userID)).Scan(&lineID)
+	if err != nil {
+		return models.StocktakeLine{}, translate(err)
+	}
+	line, err := scanStocktakeLine(r.db.QueryRowContext(ctx, stocktakeLineColumns+"WHERE l.id = $1", lineID))
+	return line, translate(err)
+}
+
+func (r *Stocktakes) Approve(ctx context.Context, scope repository.Scope, id, userID int64) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Блокировка инвентаризации не дает провести ее дважды
+	var warehouseID int64
+	err = tx.QueryRowContext(ctx, `
+        SELECT s.warehouse_id
+        FROM stocktakes s
+        JOIN warehouse w ON w.id = s.warehouse_id
+        WHERE s.id = $1 AND s.status = $2 AND ($3::bigint IS NULL OR w.org_id = $3)
+        FOR UPDATE OF s
+    `, id, repository.StocktakeCounting, scope.Param()).Scan(&warehouseID)
+	if err != nil {
+		return translate(err)
+	}
+
+	// Позиции блокируются в порядке ID, как и при других движениях
+	rows, err := tx.QueryContext(ctx, `
+        SELECT item_id, counted_quantity - expected_quantity
+        FROM stocktake_lines
+        WHERE stocktake_id = $1 AND counted_quantity IS NOT NULL
+          AND counted_quantity <> expected_quantity
+        ORDER BY item_id
+    `, id)
+	if err != nil {
+		return err
+	}
+	type variance struct {
+		itemID int64
+		delta  int
+	}
+	var variances []variance
+	for rows.Next() {
+		var v variance
+		if err := rows.Scan(&v.itemID, &v.delta); err != nil {
+			rows.Close()
+			return err
+		}
+		variances = append(variances, v)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	reason := fmt.Sprintf("Инвентаризация №%d", id)
+	for _, v := range variances {
+		item, err := lockItem(ctx, tx, scope, v.itemID)
+		if err != nil {
+			return err
+		}
+		_, _, err = move(ctx, tx, &item, models.StockMovement{
+			Type: repository.MovementAdjustment, Quantity: v.delta, Reason: reason,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	if err := updateUsage(ctx, tx, warehouseID); err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `
+        UPDATE stocktakes
+        SET status = $1, approved_by = $2, closed_at = CURRENT_TIMESTAMP
+        WHERE id = $3
+    `, repository.StocktakeApproved, nullIfZero(userID), id)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (r *Stocktakes) Cancel(ctx context.Context, scope repository.Scope, id int64) error {
+	return affected(r.db.ExecContext(ctx, `
+        UPDATE stocktakes s
+        SET status = $1, closed_at = CURRENT_TIMESTAMP
+        FROM warehouse w
+        WHERE s.id = $2 AND s.status = $3 AND w.id = s.warehouse_id
+          AND ($4::bigint IS NULL OR w.org_id = $4)
+    `, repository.StocktakeCancelled, id, repository.StocktakeCounting, scope.Param()))
+}
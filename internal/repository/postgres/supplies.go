@@ -0,0 +1,367 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"vend_erp/internal/models"
+	"vend_erp/internal/money"
+	"vend_erp/internal/repository"
+)
+
+// Supplies хранит заказы поставщикам (warehouse_supplies, supply_items).
+type Supplies struct {
+	db *sql.DB
+}
+
+const supplyColumns = `
+        SELECT s.id, s.warehouse_id, s.supplier_name, s.supply_date, s.expected_date,
+               COALESCE(s.status, 'ordered'), COALESCE(s.total_amount, 0), COALESCE(s.notes, ''),
+               COALESCE(s.approved_by, 0), s.approved_at, s.created_at, s.updated_at,
+               w.name, w.org_id, o.name, COALESCE(u.username, '')
+        FROM warehouse_supplies s
+        JOIN warehouse w ON w.id = s.warehouse_id
+        JOIN organizations o ON o.id = w.org_id
+        LEFT JOIN users u ON u.id = s.approved_by
+`
+
+func scanSupply(row rowScanner) (models.WarehouseSupply, error) {
+	var supply models.WarehouseSupply
+	var expectedDate, approvedAt, createdAt, updatedAt sql.NullTime
+	err := row.Scan(
+		&supply.ID, &supply.WarehouseID, &supply.SupplierName, &supply.SupplyDate, &expectedDate,
+		&supply.Status, &supply.TotalAmount, &supply.Notes,
+		&supply.ApprovedBy, &approvedAt, &createdAt, &updatedAt,
+		&supply.WarehouseName, &supply.OrgID, &supply.OrgName, &supply.ApprovedByName,
+	)
+	supply.ExpectedDate = expectedDate.Time
+	supply.ApprovedAt = approvedAt.Time
+	supply.CreatedAt = createdAt.Time
+	supply.UpdatedAt = updatedAt.Time
+	return supply, err
+}
+
+func (r *Supplies) List(ctx context.Context, scope repository.Scope) ([]models.WarehouseSupply, error) {
+	rows, err := r.db.QueryContext(ctx, supplyColumns+`
+        WHERE ($1::bigint IS NULL OR w.org_id = $1)
+        ORDER BY s.status = 'draft' DESC, s.created_at DESC, s.id DESC
+    `, scope.Param())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var supplies []models.WarehouseSupply
+	index := make(map[int64]int)
+	for rows.Next() {
+		supply, err := scanSupply(rows)
+		if err != nil {
+			return nil, err
+		}
+		index[supply.ID] = len(supplies)
+		supplies = append(supplies, supply)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	items, err := r.items(ctx, `
+        JOIN warehouse w ON w.id = s.warehouse_id
+        WHERE ($1::bigint IS NULL OR w.org_id = $1)
+    `, scope.Param())
+	if err != nil {
+		return nil, err
+	}
+	for _, item := range items {
+		if i, ok := index[item.SupplyID]; ok {
+			supplies[i].Items = append(supplies[i].Items, item)
+		}
+	}
+	return supplies, nil
+}
+
+func (r *Supplies) Get(ctx context.Context, scope repository.Scope, id int64) (models.WarehouseSupply, error) {
+	supply, err := scanSupply(r.db.QueryRowContext(ctx, supplyColumns+`
+        WHERE s.id = $1 AND ($2::bigint IS NULL OR w.org_id = $2)
+    `, id, scope.Param()))
+	if err != nil {
+		return supply, translate(err)
+	}
+	supply.Items, err = r.items(ctx, "WHERE s.id = $1", id)
+	return supply, err
+}
+
+// items читает позиции заказов; where продолжает запрос после
+// FROM supply_items si JOIN warehouse_supplies s.
+func (r *Supplies) items(ctx context.Context, where string, args ...interface{}) ([]models.SupplyItem, error) {
+	rows, err := r.db.QueryContext(ctx, `
+        SELECT si.id, si.supply_id, si.inventory_item_id, si.quantity_ordered,
+               COALESCE(si.quantity_received, 0), si.unit_price, si.total_price,
+               si.created_at, p.name, p.sku
+        FROM supply_items si
+        JOIN warehouse_supplies s ON s.id = si.supply_id
+        JOIN warehouse_inventory wi ON wi.id = si.inventory_item_id
+        JOIN products p ON p.id = wi.product_id
+        `+where+`
+        ORDER BY p.name, si.id
+    `, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []models.SupplyItem
+	for rows.Next() {
+		var item models.SupplyItem
+		var createdAt sql.NullTime
+		err := rows.Scan(&item.ID, &item.SupplyID, &item.InventoryItemID, &item.QuantityOrdered,
+			&item.QuantityReceived, &item.UnitPrice, &item.TotalPrice,
+			&createdAt, &item.ItemName, &item.SKU)
+		if err != nil {
+			return nil, err
+		}
+		item.CreatedAt = createdAt.Time
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
+
+func (r *Supplies) Create(ctx context.Context, supply *models.WarehouseSupply) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRowContext(ctx, `
+        INSERT INTO warehouse_supplies (warehouse_id, supplier_name, supply_date,
+                                        expected_date, status, notes)
+        SELECT id, $2, $3, $4, $5, $6 FROM warehouse WHERE id = $1
+        RETURNING id
+    `, supply.WarehouseID, supply.SupplierName, supply.SupplyDate,
+		nullIfZeroTime(supply.ExpectedDate), supply.Status, nullIfEmpty(supply.Notes)).Scan(&supply.ID)
+	if err != nil {
+		return translate(err)
+	}
+
+	for i := range supply.Items {
+		item := &supply.Items[i]
+		item.SupplyID = supply.ID
+		// Позиция должна лежать на складе заказа
+		err := tx.QueryRowContext(ctx, `
+            INSERT INTO supply_items (supply_id, inventory_item_id, quantity_ordered, unit_price)
+            SELECT $1, wi.id, $3, $4
+            FROM warehouse_inventory wi
+            WHERE wi.id = $2 AND wi.warehouse_id = $5
+            RETURNING id, total_price
+        `, supply.ID, item.InventoryItemID, item.QuantityOrdered, item.UnitPrice,
+			supply.WarehouseID).Scan(&item.ID, &item.TotalPrice)
+		if err != nil {
+			return translate(err)
+		}
+	}
+
+	err = tx.QueryRowContext(ctx, `
+        UPDATE warehouse_supplies
+        SET total_amount = (SELECT COALESCE(SUM(total_price), 0) FROM supply_items WHERE supply_id = $1)
+        WHERE id = $1
+        RETURNING total_amount
+    `, supply.ID).Scan(&supply.TotalAmount)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (r *Supplies) Approve(ctx context.Context, scope repository.Scope, id, userID int64) error {
+	return affected(r.db.ExecContext(ctx, `
+        UPDATE warehouse_supplies s
+        SET status = $1, approved_by = $2, approved_at = CURRENT_TIMESTAMP,
+            updated_at = CURRENT_TIMESTAMP
+        FROM warehouse w
+        WHERE s.id = $3 AND s.status = $4 AND w.id = s.warehouse_id
+          AND ($5::bigint IS NULL OR w.org_id = $5)
+    `, repository.SupplyOrdered, userID, id, repository.SupplyDraft, scope.Param()))
+}
+
+func (r *Supplies) Reject(ctx context.Context, scope repository.Scope, id int64) error {
+	return affected(r.db.ExecContext(ctx, `
+        UPDATE warehouse_supplies s
+        SET status = $1, updated_at = CURRENT_TIMESTAMP
+        FROM warehouse w
+        WHERE s.id = $2 AND s.status = $3 AND w.id = s.warehouse_id
+          AND ($4::bigint IS NULL OR w.org_id = $4)
+    `, repository.SupplyCancelled, id, repository.SupplyDraft, scope.Param()))
+}
+
+func (r *Supplies) Receive(ctx context.Context, scope repository.Scope, id int64, received map[int64]int) error {
+	for lineID, quantity := range received {
+		if quantity < 0 {
+			return fmt.Errorf("received quantity of supply item %d must not be negative, got %d", lineID, quantity)
+		}
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Блокировка заказа не дает принять его дважды
+	var warehouseID int64
+	err = tx.QueryRowContext(ctx, `
+        SELECT s.warehouse_id
+        FROM warehouse_supplies s
+        JOIN warehouse w ON w.id = s.warehouse_id
+        WHERE s.id = $1 AND s.status IN ($3, $4) AND ($2::bigint IS NULL OR w.org_id = $2)
+        FOR UPDATE OF s
+    `, id, scope.Param(), repository.SupplyOrdered, repository.SupplyInTransit).Scan(&warehouseID)
+	if err != nil {
+		return translate(err)
+	}
+
+	type line struct {
+		id, itemID int64
+		unitPrice  money.Amount
+	}
+	// Позиции склада блокируются по порядку id, как при перемещении
+	rows, err := tx.QueryContext(ctx, `
+        SELECT id, inventory_item_id, unit_price
+        FROM supply_items
+        WHERE supply_id = $1
+        ORDER BY inventory_item_id, id
+    `, id)
+	if err != nil {
+		return err
+	}
+	var lines []line
+	for rows.Next() {
+		var l line
+		if err := rows.Scan(&l.id, &l.itemID, &l.unitPrice); err != nil {
+			rows.Close()
+			return err
+		}
+		lines = append(lines, l)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	reason := fmt.Sprintf("Поставка №%d", id)
+	zones := make(map[int64]bool)
+	for _, l := range lines {
+		quantity := received[l.id]
+		if quantity == 0 {
+			continue
+		}
+		item, err := lockItem(ctx, tx, scope, l.itemID)
+		if err != nil {
+			return err
+		}
+		unitCost := l.unitPrice
+		if unitCost == 0 {
+			unitCost = item.defaultCost
+		}
+		_, _, err = move(ctx, tx, &item, models.StockMovement{
+			Type: repository.MovementReceipt, Quantity: quantity, Reason: reason,
+		}, models.StockLot{UnitCost: unitCost, Quantity: quantity})
+		if err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(ctx, `
+            UPDATE supply_items SET quantity_received = COALESCE(quantity_received, 0) + $2 WHERE id = $1
+        `, l.id, quantity)
+		if err != nil {
+			return err
+		}
+		zones[item.zoneID] = true
+	}
+
+	_, err = tx.ExecContext(ctx, `
+        UPDATE warehouse_supplies SET status = $2, updated_at = CURRENT_TIMESTAMP WHERE id = $1
+    `, id, repository.SupplyDelivered)
+	if err != nil {
+		return err
+	}
+	if err := updateUsage(ctx, tx, warehouseID); err != nil {
+		return err
+	}
+	for zoneID := range zones {
+		if err := checkCapacity(ctx, tx, warehouseID, zoneID); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// replenishmentLockKey — ключ pg_advisory_lock расчета пополнения
+// (у миграций — 7305001).
+const replenishmentLockKey int64 = 7305002
+
+// LockReplenishment держит pg_try_advisory_lock на отдельном соединении
+// до вызова unlock.
+func (r *Supplies) LockReplenishment(ctx context.Context) (func(), error) {
+	conn, err := r.db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var locked bool
+	if err := conn.QueryRowContext(ctx, "SELECT pg_try_advisory_lock($1)", replenishmentLockKey).Scan(&locked); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if !locked {
+		conn.Close()
+		return nil, repository.ErrLocked
+	}
+	return func() {
+		// Соединение вернется в пул, поэтому блокировку снимаем явно
+		conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1)", replenishmentLockKey)
+		conn.Close()
+	}, nil
+}
+
+func (r *Supplies) ReorderLines(ctx context.Context, scope repository.Scope, since time.Time) ([]repository.ReorderLine, error) {
+	rows, err := r.db.QueryContext(ctx, `
+        SELECT wi.id, wi.warehouse_id, w.org_id, COALESCE(p.supplier_name, ''), p.default_cost,
+               p.min_order_quantity, p.pack_size, wi.quantity, COALESCE(wi.min_stock_level, 0), COALESCE(wi.max_stock_level, 0),
+               COALESCE((
+                   SELECT SUM(GREATEST(si.quantity_ordered - COALESCE(si.quantity_received, 0), 0))
+                   FROM supply_items si
+                   JOIN warehouse_supplies s ON s.id = si.supply_id
+                   WHERE si.inventory_item_id = wi.id AND s.status IN ($3, $4, $5)
+               ), 0),
+               COALESCE((
+                   SELECT -SUM(sm.quantity)
+                   FROM stock_movements sm
+                   WHERE sm.item_id = wi.id AND sm.movement_type = $6 AND sm.created_at >= $2
+               ), 0)
+        FROM warehouse_inventory wi
+        JOIN warehouse w ON w.id = wi.warehouse_id
+        JOIN products p ON p.id = wi.product_id
+        WHERE w.is_active = true AND p.is_active = true
+          AND ($1::bigint IS NULL OR w.org_id = $1)
+        ORDER BY wi.warehouse_id, wi.id
+    `, scope.Param(), since, repository.SupplyDraft, repository.SupplyOrdered,
+		repository.SupplyInTransit, repository.MovementRestock)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var lines []repository.ReorderLine
+	for rows.Next() {
+		var line repository.ReorderLine
+		err := rows.Scan(&line.ItemID, &line.WarehouseID, &line.OrgID, &line.SupplierName,
+			&line.UnitCost, &line.MinOrder, &line.PackSize, &line.Quantity, &line.MinStock, &line.MaxStock,
+			&line.OnOrder, &line.Restocked)
+		if err != nil {
+			return nil, err
+		}
+		lines = append(lines, line)
+	}
+	return lines, rows.Err()
+}
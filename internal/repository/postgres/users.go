@@ -0,0 +1,276 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+
+	"vend_erp/internal/models"
+	"vend_erp/internal/repository"
+)
+
+// Users хранит учетные записи в таблице users и членство
+// в организациях в user_organizations.
+type Users struct {
+	db *sql.DB
+}
+
+const userColumns = `
+        SELECT
+            u.id, u.username, u.email, u.userrole, u.status,
+            COALESCE(u.lastipaddr, ''), COALESCE(u.fullusername, ''),
+            COALESCE(u.companyname, ''), COALESCE(u.companyrole, ''),
+            COALESCE(u.phone, ''), COALESCE(u.team, ''),
+            u.must_change_password, u.is_superadmin,
+            u.org_id, o.name, u.created_at, u.updated_at
+        FROM users u
+        JOIN organizations o ON o.id = u.org_id
+`
+
+func scanUser(row rowScanner) (models.User, error) {
+	var user models.User
+	var createdAt, updatedAt sql.NullTime
+	err := row.Scan(
+		&user.ID, &user.Username, &user.Email, &user.UserRole, &user.Status,
+		&user.LastIPAddr, &user.FullUserName,
+		&user.CompanyName, &user.CompanyRole,
+		&user.Phone, &user.Team,
+		&user.MustChangePassword, &user.IsSuperAdmin,
+		&user.OrgID, &user.OrgName, &createdAt, &updatedAt,
+	)
+	user.CreatedAt = createdAt.Time
+	user.UpdatedAt = updatedAt.Time
+	return user, err
+}
+
+func (r *Users) query(ctx context.Context, query string, args ...interface{}) ([]models.User, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var users []models.User
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, user)
+	}
+	return users, rows.Err()
+}
+
+func (r *Users) List(ctx context.Context, scope repository.Scope) ([]models.User, error) {
+	return r.query(ctx, userColumns+`
+        WHERE ($1::bigint IS NULL OR u.org_id = $1)
+        ORDER BY (u.status = $2) DESC, u.created_at DESC, u.id DESC
+    `, scope.Param(), models.UserStatusPending)
+}
+
+func (r *Users) Get(ctx context.Context, scope repository.Scope, id int64) (models.User, error) {
+	user, err := scanUser(r.db.QueryRowContext(ctx, userColumns+`
+        WHERE u.id = $1 AND ($2::bigint IS NULL OR u.org_id = $2)
+    `, id, scope.Param()))
+	return user, translate(err)
+}
+
+func (r *Users) FindByEmail(ctx context.Context, email string) (models.User, error) {
+	user, err := scanUser(r.db.QueryRowContext(ctx, userColumns+`
+        WHERE u.email = $1
+    `, email))
+	return user, translate(err)
+}
+
+func (r *Users) PasswordHash(ctx context.Context, id int64) (string, error) {
+	var hash string
+	err := r.db.QueryRowContext(ctx, "SELECT password FROM users WHERE id = $1", id).Scan(&hash)
+	return hash, translate(err)
+}
+
+func (r *Users) Exists(ctx context.Context, email, username string) (bool, error) {
+	var exists bool
+	err := r.db.QueryRowContext(ctx,
+		"SELECT EXISTS(SELECT 1 FROM users WHERE lower(email) = lower($1) OR username = $2)",
+		email, username).Scan(&exists)
+	return exists, err
+}
+
+func (r *Users) ListActive(ctx context.Context, orgID int64) ([]models.User, error) {
+	return r.query(ctx, userColumns+`
+        JOIN user_organizations uo ON uo.user_id = u.id AND uo.org_id = $1
+        WHERE u.status = $2
+        ORDER BY u.username
+    `, orgID, models.UserStatusActive)
+}
+
+func (r *Users) IsMember(ctx context.Context, userID, orgID int64) (bool, error) {
+	var member bool
+	err := r.db.QueryRowContext(ctx, `
+        SELECT EXISTS(SELECT 1 FROM user_organizations WHERE user_id = $1 AND org_id = $2)
+    `, userID, orgID).Scan(&member)
+	return member, err
+}
+
+func (r *Users) Create(ctx context.Context, user *models.User, passwordHash string) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRowContext(ctx, `
+        INSERT INTO users (username, email, userrole, status,
+                         fullusername, companyname, companyrole, phone, password, org_id, team,
+                         must_change_password, password_changed_at)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, CURRENT_TIMESTAMP)
+        RETURNING id
+    `, user.Username, user.Email, user.UserRole, user.Status,
+		nullIfEmpty(user.FullUserName), nullIfEmpty(user.CompanyName),
+		nullIfEmpty(user.CompanyRole), nullIfEmpty(user.Phone), passwordHash, user.OrgID,
+		nullIfEmpty(user.Team), user.MustChangePassword).Scan(&user.ID)
+	if err != nil {
+		return translate(err)
+	}
+
+	if err := addMembership(ctx, tx, user.ID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (r *Users) Update(ctx context.Context, scope repository.Scope, user models.User, passwordHash string) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Пароль и организация меняются, только если переданы
+	err = affected(tx.ExecContext(ctx, `
+        UPDATE users
+        SET username=$1, email=$2, userrole=$3, status=$4,
+            fullusername=$5, companyname=$6, companyrole=$7, phone=$8,
+            password=COALESCE($9::varchar, password),
+            password_changed_at=CASE WHEN $9::varchar IS NULL THEN password_changed_at ELSE CURRENT_TIMESTAMP END,
+            org_id=COALESCE($12, org_id), team=$13, must_change_password=$14,
+            updated_at=CURRENT_TIMESTAMP
+        WHERE id=$10 AND ($11::bigint IS NULL OR org_id = $11) AND ($15 OR NOT is_superadmin)
+    `, user.Username, user.Email, user.UserRole, user.Status,
+		nullIfEmpty(user.FullUserName), nullIfEmpty(user.CompanyName),
+		nullIfEmpty(user.CompanyRole), nullIfEmpty(user.Phone),
+		nullIfEmpty(passwordHash), user.ID, scope.Param(), nullIfZero(user.OrgID),
+		nullIfEmpty(user.Team), user.MustChangePassword, scope.SuperAdmin))
+	if err != nil {
+		return err
+	}
+
+	if user.OrgID != 0 {
+		if err := addMembership(ctx, tx, user.ID); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// addMembership добавляет пользователя в его домашнюю организацию.
+func addMembership(ctx context.Context, tx *sql.Tx, userID int64) error {
+	_, err := tx.ExecContext(ctx, `
+        INSERT INTO user_organizations (user_id, org_id)
+        SELECT id, org_id FROM users WHERE id = $1
+        ON CONFLICT DO NOTHING
+    `, userID)
+	return err
+}
+
+func (r *Users) Review(ctx context.Context, scope repository.Scope, id int64, status int, reviewerID int64) error {
+	return affected(r.db.ExecContext(ctx, `
+        UPDATE users
+        SET status = $1, approved_by = $2, approved_at = CURRENT_TIMESTAMP,
+            updated_at = CURRENT_TIMESTAMP
+        WHERE id = $3 AND status = $4 AND ($5::bigint IS NULL OR org_id = $5)
+          AND ($6 OR NOT is_superadmin)
+    `, status, reviewerID, id, models.UserStatusPending, scope.Param(), scope.SuperAdmin))
+}
+
+func (r *Users) Delete(ctx context.Context, scope repository.Scope, id int64) error {
+	_, err := r.db.ExecContext(ctx,
+		"DELETE FROM users WHERE id = $1 AND ($2::bigint IS NULL OR org_id = $2) AND ($3 OR NOT is_superadmin)",
+		id, scope.Param(), scope.SuperAdmin)
+	return err
+}
+
+func (r *Users) Teams(ctx context.Context, scope repository.Scope) ([]string, error) {
+	rows, err := r.db.QueryContext(ctx, `
+        SELECT DISTINCT team FROM users
+        WHERE team IS NOT NULL AND team <> '' AND ($1::bigint IS NULL OR org_id = $1)
+        ORDER BY team
+    `, scope.Param())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var teams []string
+	for rows.Next() {
+		var team string
+		if err := rows.Scan(&team); err != nil {
+			return nil, err
+		}
+		teams = append(teams, team)
+	}
+	return teams, rows.Err()
+}
+
+func (r *Users) FindBySubject(ctx context.Context, issuer, subject string) (models.User, error) {
+	user, err := scanUser(r.db.QueryRowContext(ctx, userColumns+`
+        WHERE u.oidc_issuer = $1 AND u.oidc_subject = $2
+    `, issuer, subject))
+	return user, translate(err)
+}
+
+func (r *Users) LinkSubject(ctx context.Context, email, issuer, subject string) (models.User, error) {
+	var id int64
+	err := r.db.QueryRowContext(ctx, `
+        UPDATE users
+        SET oidc_issuer = $1, oidc_subject = $2, updated_at = CURRENT_TIMESTAMP
+        WHERE lower(email) = lower($3) AND oidc_subject IS NULL
+        RETURNING id
+    `, issuer, subject, email).Scan(&id)
+	if err != nil {
+		return models.User{}, translate(err)
+	}
+	user, err := scanUser(r.db.QueryRowContext(ctx, userColumns+"WHERE u.id = $1", id))
+	return user, translate(err)
+}
+
+func (r *Users) Provision(ctx context.Context, user *models.User, issuer, subject string) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRowContext(ctx, `
+        INSERT INTO users (username, email, password, userrole, status, fullusername,
+                           oidc_issuer, oidc_subject, org_id, email_verified_at)
+        VALUES ($1, $2, '', $3, $4, $5, $6, $7, $8, CURRENT_TIMESTAMP)
+        RETURNING id
+    `, user.Username, user.Email, user.UserRole, user.Status, nullIfEmpty(user.FullUserName),
+		issuer, subject, user.OrgID).Scan(&user.ID)
+	if err != nil {
+		return translate(err)
+	}
+
+	if err := addMembership(ctx, tx, user.ID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (r *Users) SetRole(ctx context.Context, id int64, role string) error {
+	_, err := r.db.ExecContext(ctx, `
+        UPDATE users SET userrole = $1, updated_at = CURRENT_TIMESTAMP
+        WHERE id = $2 AND userrole <> $1
+    `, role, id)
+	return err
+}
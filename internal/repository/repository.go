@@ -0,0 +1,626 @@
+// Package repository описывает хранилища агрегатов VendERP: автоматы,
+// локации, операции, справочник товаров, складской учет, заказы
+// поставщикам, инвентаризации, организации, пользователи, приглашения
+// и сессии, а также сводные отчеты дашборда. Обработчики зависят только
+// от этих интерфейсов.
+//
+// Реализации:
+//   - repository/postgres — рабочая, поверх *sql.DB;
+//   - repository/memory — в памяти процесса, для тестов обработчиков.
+//
+// Обе проверяются общим набором контрактных тестов repository/repotest.
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"vend_erp/internal/models"
+	"vend_erp/internal/money"
+)
+
+var (
+	// ErrNotFound — записи нет или она не видна в переданной области.
+	ErrNotFound = errors.New("repository: not found")
+	// ErrDuplicate — нарушено ограничение уникальности (email, артикул).
+	ErrDuplicate = errors.New("repository: duplicate")
+	// ErrInUse — запись нельзя удалить, пока на нее ссылаются другие
+	// (товар с остатками на складах или в выкладке автомата).
+	ErrInUse = errors.New("repository: in use")
+	// ErrInsufficientStock — на складе меньше товара, чем требуется списать.
+	ErrInsufficientStock = errors.New("repository: insufficient stock")
+	// ErrConflict — запись сохранили после того, как ее прочитал вызывающий:
+	// переданная версия устарела.
+	ErrConflict = errors.New("repository: version conflict")
+	// ErrOverCapacity — приход или размещение переполнит склад или зону
+	// с политикой CapacityBlock.
+	ErrOverCapacity = errors.New("repository: over capacity")
+	// ErrLocked — блокировку уже держит другой процесс или экземпляр
+	// приложения.
+	ErrLocked = errors.New("repository: locked")
+)
+
+// Scope определяет, данные какой организации видит запрос.
+// AllOrgs включается только суперадмином в сводном режиме.
+type Scope struct {
+	OrgID   int64
+	AllOrgs bool
+	// SuperAdmin — запрос суперадмина: только он может менять и удалять
+	// учетные записи других суперадминов, даже в своей организации.
+	SuperAdmin bool
+}
+
+// Param возвращает значение для условия вида
+// ($N::bigint IS NULL OR t.org_id = $N): nil снимает фильтр.
+func (s Scope) Param() interface{} {
+	if s.AllOrgs {
+		return nil
+	}
+	return s.OrgID
+}
+
+// Includes сообщает, видна ли в области запись организации orgID.
+func (s Scope) Includes(orgID int64) bool {
+	return s.AllOrgs || s.OrgID == orgID
+}
+
+// Repos собирает хранилища всех агрегатов одной реализации.
+type Repos struct {
+	Machines      Machines
+	Locations     Locations
+	Operations    Operations
+	Products      Products
+	Inventory     Inventory
+	Supplies      Supplies
+	Stocktakes    Stocktakes
+	Users         Users
+	Sessions      Sessions
+	Organizations Organizations
+	Invites       Invites
+	Reports       Reports
+}
+
+// Общие соглашения:
+//   - Get и Update возвращают ErrNotFound, если запись не видна в области;
+//   - Delete идемпотентен: удаление невидимой или уже удаленной записи
+//     ничего не меняет и не считается ошибкой;
+//   - Create заполняет ID и org_id берет из самой записи;
+//   - автоматы, локации, операции, товары, склады и позиции склада
+//     версионируются: Create ставит Version = 1, Update сохраняет запись,
+//     только если ее Version совпадает с хранимой, и увеличивает версию,
+//     иначе возвращает ErrConflict. Корректировки и перемещения остатков
+//     тоже увеличивают версию позиции.
+
+// Machines хранит торговые автоматы.
+type Machines interface {
+	// List возвращает автоматы области, новые первыми.
+	List(ctx context.Context, scope Scope) ([]models.VendingMachine, error)
+	Get(ctx context.Context, scope Scope, id int64) (models.VendingMachine, error)
+	// FindBySerial ищет автомат области по серийному номеру, например
+	// из QR-кода на этикетке.
+	FindBySerial(ctx context.Context, scope Scope, serial string) (models.VendingMachine, error)
+	// ListActive возвращает работающие автоматы организации для выпадающих списков.
+	ListActive(ctx context.Context, orgID int64) ([]models.VendingMachine, error)
+	Create(ctx context.Context, machine *models.VendingMachine) error
+	Update(ctx context.Context, scope Scope, machine models.VendingMachine) error
+	Delete(ctx context.Context, scope Scope, id int64) error
+	// Planogram возвращает выкладку автомата области по названию товара.
+	Planogram(ctx context.Context, scope Scope, machineID int64) ([]models.PlanogramItem, error)
+	// SetPlanogram заменяет выкладку автомата области. Возвращает
+	// ErrNotFound, если автомат не виден или товар не из его организации.
+	SetPlanogram(ctx context.Context, scope Scope, machineID int64, items []models.PlanogramItem) error
+}
+
+// Locations хранит точки размещения автоматов.
+type Locations interface {
+	List(ctx context.Context, scope Scope) ([]models.Location, error)
+	Get(ctx context.Context, scope Scope, id int64) (models.Location, error)
+	// ListActive возвращает активные локации организации по алфавиту.
+	ListActive(ctx context.Context, orgID int64) ([]models.Location, error)
+	Create(ctx context.Context, location *models.Location) error
+	Update(ctx context.Context, scope Scope, location models.Location) error
+	Delete(ctx context.Context, scope Scope, id int64) error
+}
+
+// Operations хранит операции обслуживания автоматов.
+type Operations interface {
+	// List возвращает операции области, последние по дате первыми.
+	List(ctx context.Context, scope Scope) ([]models.VendingOperation, error)
+	// Get возвращает операцию вместе с товарами пополнения по названию.
+	Get(ctx context.Context, scope Scope, id int64) (models.VendingOperation, error)
+	// Create и Update сохраняют товары пополнения operation.Items, заменяя
+	// прежние; товар не из организации операции дает ErrNotFound.
+	//
+	// Пополнение со склада operation.WarehouseID выдается той же
+	// транзакцией: по каждому товару выданное операцией доводится до
+	// Added движениями MovementRestock позиции склада с OperationID
+	// операции, лишнее возвращается на склад по себестоимости выдачи.
+	// Выдача берет отложенное под автомат операции (такие резервы
+	// расходуются целиком), доступный товар и отложенное под рейс без
+	// автомата (такие резервы уменьшаются на взятое). ErrNotFound — склада
+	// нет в организации операции; ErrInsufficientStock — товара на складе
+	// не хватает, тогда ничего не сохраняется.
+	Create(ctx context.Context, operation *models.VendingOperation) error
+	Update(ctx context.Context, scope Scope, operation models.VendingOperation) error
+	// Delete возвращает выданное операцией на склад и удаляет ее.
+	Delete(ctx context.Context, scope Scope, id int64) error
+	// Sales возвращает продажи товаров по локациям области по пополнениям
+	// за период [from, to): проданное между соседними пополнениями автомата
+	// относится к дате второго и оценивается по цене игры первого
+	// (PlayValue). Внутри локации лучшие товары идут первыми.
+	Sales(ctx context.Context, scope Scope, from, to time.Time) ([]models.ProductSales, error)
+}
+
+// Фильтр остатков InventoryFilter.Stock
+const (
+	StockLow    = "low"
+	StockOut    = "out"
+	StockNormal = "normal"
+)
+
+// Виды корректировки остатка для Inventory.Adjust
+const (
+	AdjustAdd      = "add"
+	AdjustSubtract = "subtract"
+	AdjustSet      = "set"
+)
+
+// Типы движений товара (models.StockMovement.Type)
+const (
+	MovementOpening     = "opening"
+	MovementReceipt     = "receipt"
+	MovementAdjustment  = "adjustment"
+	MovementTransferOut = "transfer_out"
+	MovementTransferIn  = "transfer_in"
+	MovementShipment    = "shipment"
+	MovementRestock     = "restock"
+)
+
+// StockChange — приход или расход товара для Inventory.Move.
+type StockChange struct {
+	// Type — MovementReceipt или MovementShipment; пополнение автомата
+	// (MovementRestock) проводит только операция пополнения
+	Type string
+	// Quantity — сколько единиц пришло или ушло, больше нуля
+	Quantity int
+	// UnitCost — цена единицы прихода; ноль — закупочная цена товара
+	UnitCost money.Amount
+	// ReservationID — действующий резерв позиции того же типа, который
+	// расходует изменение; 0 — без резерва
+	ReservationID int64
+	Reason        string
+}
+
+// Состояния резерва (models.StockReservation.Status)
+const (
+	ReservationActive   = "active"
+	ReservationConsumed = "consumed"
+	ReservationReleased = "released"
+)
+
+// Products хранит справочник товаров. Артикул и штрихкод уникальны
+// в пределах организации.
+type Products interface {
+	// List возвращает товары области по названию с остатком на всех складах.
+	List(ctx context.Context, scope Scope) ([]models.Product, error)
+	Get(ctx context.Context, scope Scope, id int64) (models.Product, error)
+	// ListActive возвращает активные товары организации для выпадающих списков.
+	ListActive(ctx context.Context, orgID int64) ([]models.Product, error)
+	// Create и Update возвращают ErrDuplicate, если артикул или штрихкод заняты.
+	// Нулевой объем единицы сохраняется как 1; Update пересчитывает
+	// загрузку складов, где лежит товар.
+	Create(ctx context.Context, product *models.Product) error
+	Update(ctx context.Context, scope Scope, product models.Product) error
+	// Delete возвращает ErrInUse, если у товара есть позиции на складах,
+	// он есть в выкладке автомата или в записанных пополнениях.
+	Delete(ctx context.Context, scope Scope, id int64) error
+}
+
+// InventoryFilter сужает список складских позиций; нулевые поля не фильтруют.
+type InventoryFilter struct {
+	WarehouseID int64
+	ItemType    string
+	// Stock — StockLow, StockOut или StockNormal
+	Stock string
+}
+
+// Inventory хранит склады, их зоны и складские остатки. Загрузка склада
+// (current_usage) — сумма объемов остатков (количество на объем единицы
+// товара); она пересчитывается при каждом изменении остатков.
+//
+// Приход (Move с MovementReceipt, начальный остаток CreateItem), перемещение
+// на склад (Transfer, смена склада в UpdateItem) и размещение в зоне
+// проверяют вместимость: если склад или зона с политикой CapacityBlock
+// переполнятся, изменение отклоняется с ErrOverCapacity. Корректировки
+// и инвентаризации вместимость не проверяют: они фиксируют то, что уже
+// лежит на складе.
+//
+// Любое изменение остатка позиции — создание с ненулевым количеством,
+// правка количества или склада, корректировка, перемещение, приход и расход —
+// записывается в журнал движений той же транзакцией, что и новый остаток.
+// Позиция на время изменения блокируется, а отрицательный остаток
+// отклоняется с ErrInsufficientStock.
+type Inventory interface {
+	// Warehouses возвращает активные склады области по алфавиту.
+	Warehouses(ctx context.Context, scope Scope) ([]models.Warehouse, error)
+	Warehouse(ctx context.Context, scope Scope, id int64) (models.Warehouse, error)
+	CreateWarehouse(ctx context.Context, warehouse *models.Warehouse) error
+	UpdateWarehouse(ctx context.Context, scope Scope, warehouse models.Warehouse) error
+
+	// Zones возвращает зоны склада области по названию с загрузкой
+	// и числом позиций. ErrNotFound — склада нет в области.
+	Zones(ctx context.Context, scope Scope, warehouseID int64) ([]models.WarehouseZone, error)
+	// CreateZone и UpdateZone возвращают ErrDuplicate, если на складе уже
+	// есть зона с таким названием; склад зоны UpdateZone не меняет.
+	CreateZone(ctx context.Context, scope Scope, zone *models.WarehouseZone) error
+	UpdateZone(ctx context.Context, scope Scope, zone models.WarehouseZone) error
+	// DeleteZone удаляет зону; ее позиции остаются неразмещенными.
+	DeleteZone(ctx context.Context, scope Scope, id int64) error
+	// Place размещает позицию в зоне ее склада; zoneID 0 снимает
+	// размещение. ErrNotFound — позиции или зоны на ее складе нет.
+	Place(ctx context.Context, scope Scope, itemID, zoneID int64) error
+
+	Categories(ctx context.Context) ([]models.WarehouseCategory, error)
+
+	// Items возвращает позиции активных складов области, упорядоченные
+	// по складу, типу и названию. Item и Items заполняют Reserved.
+	Items(ctx context.Context, scope Scope, filter InventoryFilter) ([]models.WarehouseInventory, error)
+	Item(ctx context.Context, scope Scope, id int64) (models.WarehouseInventory, error)
+	// CreateItem заводит остаток товара item.ProductID на складе. Товар
+	// должен принадлежать организации склада, иначе — ErrNotFound.
+	// CreateItem и UpdateItem возвращают ErrDuplicate, если на складе уже
+	// есть позиция этого товара; товар позиции UpdateItem не меняет.
+	// Зона item.ZoneID должна быть зоной склада позиции, иначе — ErrNotFound.
+	CreateItem(ctx context.Context, item *models.WarehouseInventory) error
+	// UpdateItem, как и Adjust, не уменьшает остаток ниже зарезервированного
+	// (ErrInsufficientStock), а позицию с действующими резервами не переносит
+	// на другой склад (ErrInUse): резервы сначала нужно снять.
+	UpdateItem(ctx context.Context, scope Scope, item models.WarehouseInventory) error
+	// DeleteItem удаляет позицию без остатка и движений; позиция, у
+	// которой есть остаток или журнал движений, — ErrInUse: ее история
+	// нужна для остатков и оценки запасов на прошлые даты.
+	DeleteItem(ctx context.Context, scope Scope, id int64) error
+
+	// Adjust меняет остаток позиции (AdjustAdd, AdjustSubtract, AdjustSet),
+	// записывает движение MovementAdjustment и возвращает новый остаток.
+	// Списание, после которого остаток станет меньше зарезервированного,
+	// возвращает ErrInsufficientStock: отложенный товар сначала снимают с резерва.
+	Adjust(ctx context.Context, scope Scope, itemID int64, kind string, quantity int, reason string) (int, error)
+	// Transfer перемещает товар на другой склад той же организации
+	// парой движений MovementTransferOut и MovementTransferIn.
+	// Возвращает ErrInsufficientStock, если доступного товара не хватает, ErrNotFound,
+	// если позиция или целевой склад не найдены, и ErrOverCapacity.
+	Transfer(ctx context.Context, scope Scope, itemID, targetWarehouseID int64, quantity int, notes string) error
+	// Move проводит приход или отгрузку позиции и возвращает новый остаток.
+	// ErrNotFound — позиция не найдена в области либо change.ReservationID
+	// не действующий резерв позиции того же типа. Расход без резерва
+	// берет только доступный товар; расход по резерву может взять
+	// и зарезервированное им, а сам резерв считается израсходованным,
+	// даже если отгрузили меньше.
+	Move(ctx context.Context, scope Scope, itemID int64, change StockChange) (int, error)
+
+	// Reservations возвращает действующие резервы позиции: не
+	// израсходованные, не снятые и не истекшие, ближайшие к сроку первыми.
+	Reservations(ctx context.Context, scope Scope, itemID int64) ([]models.StockReservation, error)
+	// Reserve откладывает товар позиции reservation.ItemID под отгрузку
+	// (MovementShipment) или пополнение (MovementRestock) до
+	// reservation.ExpiresAt и заполняет ID. Резерв под пополнение
+	// расходует операция пополнения (см. Operations.Create). ErrInsufficientStock —
+	// доступного товара меньше reservation.Quantity; ErrNotFound — позиции
+	// или автомата нет в области.
+	Reserve(ctx context.Context, scope Scope, reservation *models.StockReservation) error
+	// Release снимает действующий резерв; остальные не меняются.
+	Release(ctx context.Context, scope Scope, id int64) error
+
+	// Movements возвращает журнал движений позиции, последние первыми.
+	Movements(ctx context.Context, scope Scope, itemID int64) ([]models.StockMovement, error)
+	// BalanceAt возвращает остаток позиции на момент at по журналу движений.
+	BalanceAt(ctx context.Context, scope Scope, itemID int64, at time.Time) (int, error)
+
+	// Lots возвращает непустые партии позиции, старые первыми.
+	Lots(ctx context.Context, scope Scope, itemID int64) ([]models.StockLot, error)
+	// Valuation возвращает количество и себестоимость запасов по складам
+	// области на момент at; склады без запасов не попадают в список.
+	Valuation(ctx context.Context, scope Scope, at time.Time) ([]WarehouseValue, error)
+	// CostOfSales возвращает себестоимость выданного в автоматы за период
+	// [from, to) по операциям пополнения, последние первыми. Движения
+	// операции за период складываются: возвраты на склад при правке
+	// операции уменьшают выдачу. Движение без операции (операция удалена
+	// или пополнение записано до привязки к операциям) — отдельная строка.
+	CostOfSales(ctx context.Context, scope Scope, from, to time.Time) ([]OperationCost, error)
+}
+
+// OperationCost — себестоимость выданного одной операцией пополнения
+// в Inventory.CostOfSales.
+type OperationCost struct {
+	// OperationID — 0 для движения без операции; тогда Date — дата
+	// движения, Reason — его основание
+	OperationID   int64
+	Date          time.Time
+	MachineSerial string
+	Reason        string
+	Quantity      int
+	Cost          money.Amount
+}
+
+// WarehouseValue — запасы склада в Inventory.Valuation.
+type WarehouseValue struct {
+	WarehouseID   int64
+	WarehouseName string
+	Quantity      int
+	Value         money.Amount
+}
+
+// Статусы заказа поставщику (models.WarehouseSupply.Status)
+const (
+	SupplyDraft     = "draft"
+	SupplyOrdered   = "ordered"
+	SupplyInTransit = "in_transit"
+	SupplyDelivered = "delivered"
+	SupplyCancelled = "cancelled"
+)
+
+// ReorderLine — позиция склада с данными для расчета заказа поставщику.
+type ReorderLine struct {
+	ItemID      int64
+	WarehouseID int64
+	OrgID       int64
+	// SupplierName, UnitCost и условия заказа берутся из справочника
+	// товаров: MinOrder — минимальная партия, PackSize — штук в упаковке
+	SupplierName string
+	UnitCost     money.Amount
+	MinOrder     int
+	PackSize     int
+	Quantity     int
+	MinStock     int
+	MaxStock     int
+	// OnOrder — заказано, но еще не получено по черновикам
+	// и незакрытым заказам (draft, ordered, in_transit)
+	OnOrder int
+	// Restocked — сколько единиц ушло со склада в автоматы начиная с since
+	Restocked int
+}
+
+// Supplies хранит заказы поставщикам вместе с позициями (supply_items).
+// Заказ относится к организации своего склада.
+type Supplies interface {
+	// List возвращает заказы области с позициями: сначала черновики,
+	// затем остальные, новые первыми.
+	List(ctx context.Context, scope Scope) ([]models.WarehouseSupply, error)
+	Get(ctx context.Context, scope Scope, id int64) (models.WarehouseSupply, error)
+	// Create сохраняет заказ и его позиции одной транзакцией; сумма заказа
+	// считается по позициям. Склад и позиции должны быть одного склада,
+	// иначе — ErrNotFound.
+	Create(ctx context.Context, supply *models.WarehouseSupply) error
+	// Approve переводит черновик в SupplyOrdered от имени userID,
+	// Reject — в SupplyCancelled. ErrNotFound означает, что черновика
+	// в области нет или его уже рассмотрели.
+	Approve(ctx context.Context, scope Scope, id, userID int64) error
+	Reject(ctx context.Context, scope Scope, id int64) error
+	// Receive принимает утвержденный заказ (SupplyOrdered или
+	// SupplyInTransit) одной транзакцией: received — сколько пришло по
+	// каждой позиции заказа (ключ — ID позиции). Пришедшее приходуется
+	// движением MovementReceipt и партией по цене позиции, прибавляется
+	// к QuantityReceived, а заказ получает статус SupplyDelivered и больше
+	// не считается заказанным, даже если пришло меньше. ErrNotFound —
+	// утвержденного заказа в области нет; ErrOverCapacity — приход не
+	// помещается на склад, тогда не принимается ничего.
+	Receive(ctx context.Context, scope Scope, id int64, received map[int64]int) error
+
+	// ReorderLines возвращает позиции активных товаров на активных складах
+	// области с остатком, заказанным количеством и расходом с момента since.
+	ReorderLines(ctx context.Context, scope Scope, since time.Time) ([]ReorderLine, error)
+	// LockReplenishment берет блокировку расчета пополнения, общую для
+	// всех экземпляров приложения, и возвращает функцию ее снятия.
+	// ErrLocked — расчет уже выполняется.
+	LockReplenishment(ctx context.Context) (unlock func(), err error)
+}
+
+// Статусы инвентаризации (models.Stocktake.Status)
+const (
+	StocktakeCounting  = "counting"
+	StocktakeApproved  = "approved"
+	StocktakeCancelled = "cancelled"
+)
+
+// Stocktakes хранит инвентаризации складов. Пока инвентаризация открыта
+// (StocktakeCounting), в нее вводят посчитанные количества; проведение
+// и отмена ее закрывают. Count, Scan, Approve и Cancel возвращают
+// ErrNotFound, если открытой инвентаризации в области нет.
+type Stocktakes interface {
+	// List возвращает инвентаризации области без позиций, новые первыми.
+	List(ctx context.Context, scope Scope) ([]models.Stocktake, error)
+	// Get возвращает инвентаризацию с позициями по названию товара.
+	Get(ctx context.Context, scope Scope, id int64) (models.Stocktake, error)
+	// Start открывает инвентаризацию склада stocktake.WarehouseID области
+	// и замораживает остатки всех его позиций и их среднюю себестоимость
+	// по учету (для пустого остатка — закупочную цену товара).
+	// ErrNotFound — склада нет в области, ErrDuplicate — на складе уже
+	// идет инвентаризация.
+	Start(ctx context.Context, scope Scope, stocktake *models.Stocktake) error
+	// Count записывает посчитанное количество позиции склада itemID.
+	Count(ctx context.Context, scope Scope, id, itemID int64, quantity int, userID int64) error
+	// Scan прибавляет quantity к посчитанному количеству позиции, товар
+	// которой имеет штрихкод или артикул code, и возвращает позицию.
+	// ErrNotFound — такого товара нет в снимке.
+	Scan(ctx context.Context, scope Scope, id int64, code string, quantity int, userID int64) (models.StocktakeLine, error)
+	// Approve проводит инвентаризацию: расхождение каждой посчитанной
+	// позиции записывается движением MovementAdjustment одной транзакцией.
+	// Если остаток какой-либо позиции стал бы отрицательным, ничего
+	// не проводится и возвращается ErrInsufficientStock.
+	Approve(ctx context.Context, scope Scope, id, userID int64) error
+	Cancel(ctx context.Context, scope Scope, id int64) error
+}
+
+// Users хранит учетные записи.
+type Users interface {
+	// List возвращает пользователей области: сначала ожидающие
+	// подтверждения, затем новые.
+	List(ctx context.Context, scope Scope) ([]models.User, error)
+	Get(ctx context.Context, scope Scope, id int64) (models.User, error)
+	FindByEmail(ctx context.Context, email string) (models.User, error)
+	// PasswordHash возвращает сохраненный хеш пароля.
+	PasswordHash(ctx context.Context, id int64) (string, error)
+	// Exists проверяет, занят ли email (без учета регистра) или имя
+	// пользователя.
+	Exists(ctx context.Context, email, username string) (bool, error)
+	// ListActive возвращает активных участников организации.
+	ListActive(ctx context.Context, orgID int64) ([]models.User, error)
+	IsMember(ctx context.Context, userID, orgID int64) (bool, error)
+
+	// Create добавляет пользователя и делает его участником домашней организации.
+	Create(ctx context.Context, user *models.User, passwordHash string) error
+	// Update сохраняет профиль. Пустой passwordHash оставляет пароль прежним;
+	// ненулевой user.OrgID переносит пользователя в эту организацию.
+	// Суперадмин виден Update, Review и Delete только в области
+	// с SuperAdmin: для остальных его запись не изменяется.
+	Update(ctx context.Context, scope Scope, user models.User, passwordHash string) error
+	// Review подтверждает или отклоняет регистрацию, ожидающую проверки.
+	// ErrNotFound означает, что заявки нет или она уже рассмотрена.
+	Review(ctx context.Context, scope Scope, id int64, status int, reviewerID int64) error
+	Delete(ctx context.Context, scope Scope, id int64) error
+	// Teams возвращает команды, уже назначенные пользователям области,
+	// по алфавиту.
+	Teams(ctx context.Context, scope Scope) ([]string, error)
+
+	// FindBySubject ищет пользователя, связанного с учетной записью
+	// OpenID Connect провайдера issuer.
+	FindBySubject(ctx context.Context, issuer, subject string) (models.User, error)
+	// LinkSubject связывает с учетной записью провайдера пользователя
+	// с email без учета регистра. ErrNotFound — такого пользователя нет
+	// или он уже связан с другой учетной записью.
+	LinkSubject(ctx context.Context, email, issuer, subject string) (models.User, error)
+	// Provision создает пользователя, который входит только через
+	// провайдера: пароль пустой, email считается подтвержденным.
+	Provision(ctx context.Context, user *models.User, issuer, subject string) error
+	// SetRole меняет роль пользователя без проверки области.
+	SetRole(ctx context.Context, id int64, role string) error
+}
+
+// Sessions хранит сессии входа.
+type Sessions interface {
+	Create(ctx context.Context, session models.Session) error
+	// Get возвращает действующую сессию; истекшая считается отсутствующей.
+	Get(ctx context.Context, id string) (models.Session, error)
+	// SetActiveOrg переключает сессию на организацию и выключает сводный режим.
+	SetActiveOrg(ctx context.Context, id string, userID, orgID int64) error
+	// SetAllOrgs включает сводный режим по всем организациям.
+	SetAllOrgs(ctx context.Context, id string, userID int64) error
+	Delete(ctx context.Context, id string) error
+	// DeleteOthers завершает все сессии пользователя, кроме keepID.
+	DeleteOthers(ctx context.Context, userID int64, keepID string) error
+}
+
+// DefaultOrganization — код организации, в которую попадают самостоятельно
+// зарегистрированные пользователи. Ее нельзя отключить или переименовать.
+const DefaultOrganization = "default"
+
+// Organizations хранит организации. Области видимости к ним не применяются:
+// список всех организаций доступен только суперадмину, и проверяет это
+// вызывающий.
+type Organizations interface {
+	// List возвращает все организации по названию.
+	List(ctx context.Context) ([]models.Organization, error)
+	// Member возвращает активные организации, в которых состоит пользователь.
+	Member(ctx context.Context, userID int64) ([]models.Organization, error)
+	Get(ctx context.Context, id int64) (models.Organization, error)
+	// FindBySlug ищет организацию по коду.
+	FindBySlug(ctx context.Context, slug string) (models.Organization, error)
+	// Create возвращает ErrDuplicate, если код уже занят.
+	Create(ctx context.Context, org *models.Organization) error
+	// Update сохраняет организацию; у DefaultOrganization код и признак
+	// активности не меняются.
+	Update(ctx context.Context, org models.Organization) error
+	// CanSwitch сообщает, может ли пользователь работать в организации:
+	// она активна, и он в ней состоит или является суперадмином.
+	CanSwitch(ctx context.Context, userID, orgID int64, superAdmin bool) (bool, error)
+}
+
+// Invites хранит приглашения. Токен из ссылки не хранится: поиск идет
+// по его хешу.
+type Invites interface {
+	// Find ищет приглашение активной организации по хешу токена.
+	// Использованные, отозванные и просроченные приглашения тоже
+	// возвращаются — проверяет вызывающий.
+	Find(ctx context.Context, tokenHash string) (models.Invite, error)
+	// List возвращает последние 50 приглашений области: сначала
+	// действующие, затем новые.
+	List(ctx context.Context, scope Scope) ([]models.Invite, error)
+	Create(ctx context.Context, invite *models.Invite, tokenHash string) error
+	// Revoke отзывает неиспользованное приглашение; уже использованное
+	// или отозванное не меняется.
+	Revoke(ctx context.Context, scope Scope, id int64) error
+}
+
+// MachineTotals — текущие показатели автоматов области.
+type MachineTotals struct {
+	Count  int
+	Active int
+	Cash   money.Amount
+	Toys   int
+}
+
+// WarehouseTotals — сводка по активным складам области.
+type WarehouseTotals struct {
+	Value      money.Amount
+	LowStock   int // позиции ниже минимального уровня, но не пустые
+	OutOfStock int
+	Warehouses int
+}
+
+// TypeCount — число складских позиций товаров одного типа.
+type TypeCount struct {
+	ItemType string
+	Count    int
+}
+
+// MachineDay — автоматы, созданные не позже дня Date, в Reports.MachineHistory.
+type MachineDay struct {
+	Date     time.Time
+	Machines int
+	Active   int
+	Cash     money.Amount
+	Toys     int
+}
+
+// OperationDay — число операций одного типа за день.
+type OperationDay struct {
+	Date          time.Time
+	OperationType string
+	Count         int
+}
+
+// AmountDay — денежный показатель за день.
+type AmountDay struct {
+	Date   time.Time
+	Amount money.Amount
+}
+
+// Reports считает показатели дашборда и графиков. Ряды по дням берут
+// даты from и to включительно, без времени суток.
+type Reports interface {
+	Machines(ctx context.Context, scope Scope) (MachineTotals, error)
+	// OperationCounts возвращает число операций области по типам.
+	OperationCounts(ctx context.Context, scope Scope) (map[string]int, error)
+	Warehouses(ctx context.Context, scope Scope) (WarehouseTotals, error)
+	// StockByType возвращает число позиций активных складов по типам
+	// товара, начиная с самого частого.
+	StockByType(ctx context.Context, scope Scope) ([]TypeCount, error)
+	// LowStock возвращает до limit позиций активных складов ниже
+	// минимального уровня, кроме пустых, начиная с наибольшей нехватки.
+	LowStock(ctx context.Context, scope Scope, limit int) ([]models.WarehouseInventory, error)
+
+	// MachineHistory возвращает по каждому дню число автоматов, созданных
+	// не позже него, активных среди них и их текущие деньги и игрушки.
+	MachineHistory(ctx context.Context, scope Scope, from, to time.Time) ([]MachineDay, error)
+	// OperationHistory считает операции по дню создания и типу; дни без
+	// операций пропускаются.
+	OperationHistory(ctx context.Context, scope Scope, from, to time.Time) ([]OperationDay, error)
+	// Revenue возвращает инкассированные деньги по дням операций; дни
+	// без инкассаций пропускаются.
+	Revenue(ctx context.Context, scope Scope, from, to time.Time) ([]AmountDay, error)
+	// StockValue возвращает себестоимость запасов на конец каждого дня —
+	// сумму стоимостей движений до его окончания.
+	StockValue(ctx context.Context, scope Scope, from, to time.Time) ([]AmountDay, error)
+}
@@ -0,0 +1,142 @@
+package repotest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"vend_erp/internal/models"
+	"vend_erp/internal/money"
+	"vend_erp/internal/repository"
+)
+
+// stockValue возвращает стоимость остатка позиции.
+func stockValue(t *testing.T, env Env, id int64) money.Amount {
+	t.Helper()
+	item, err := env.Repos.Inventory.Item(context.Background(), allOrgs, id)
+	must(t, err)
+	return item.StockValue
+}
+
+func testCosting(t *testing.T, env Env) {
+	ctx := context.Background()
+	repo := env.Repos.Inventory
+	start := time.Now().Add(-time.Minute)
+	rub := func(rubles int64) money.Amount { return money.FromRubles(rubles) }
+
+	t.Run("FIFO", func(t *testing.T) {
+		main := newWarehouse(t, env, env.OrgA, "Основной", true)
+		spare := newWarehouse(t, env, env.OrgA, "Резервный", true)
+		location := newLocation(t, env, env.OrgA, "ТЦ Партии", true)
+		machine := newMachine(t, env, env.OrgA, location.ID, "SN-FIFO")
+		operator := newUser(t, env, env.OrgA, "fifo", models.UserStatusActive)
+		product := newProduct(t, env, env.OrgA, "Мишка", "SKU-FIFO")
+		// Начальный остаток — партия по закупочной цене товара
+		item := newItem(t, env, main.ID, product, 10)
+		equal(t, "opening value", stockValue(t, env, item.ID), product.DefaultCost.Mul(10))
+
+		_, err := repo.Move(ctx, env.scopeA(), item.ID, repository.StockChange{
+			Type: repository.MovementReceipt, Quantity: 5, UnitCost: rub(100),
+		})
+		must(t, err)
+		lots, err := repo.Lots(ctx, env.scopeA(), item.ID)
+		must(t, err)
+		if len(lots) != 2 {
+			t.Fatalf("got %d lots, want 2", len(lots))
+		}
+		equal(t, "oldest lot cost", lots[0].UnitCost, product.DefaultCost)
+		equal(t, "newest lot cost", lots[1].UnitCost, rub(100))
+
+		// Пополнение списывает сначала старую партию
+		restock, err := warehouseRestock(env, machine, operator.ID, main.ID, product.ID, 12)
+		must(t, err)
+		cogs := product.DefaultCost.Mul(10) + rub(100).Mul(2)
+		movements, err := repo.Movements(ctx, env.scopeA(), item.ID)
+		must(t, err)
+		equal(t, "restock cost", movements[0].Cost, -cogs)
+		equal(t, "value after restock", stockValue(t, env, item.ID), rub(300))
+		lots, err = repo.Lots(ctx, env.scopeA(), item.ID)
+		must(t, err)
+		equal(t, "open lots", len(lots), 1)
+		equal(t, "remaining", lots[0].Remaining, 3)
+
+		// Перемещение переносит партию с ее ценой и датой прихода
+		must(t, repo.Transfer(ctx, env.scopeA(), item.ID, spare.ID, 2, ""))
+		moved, err := repo.Items(ctx, env.scopeA(), repository.InventoryFilter{WarehouseID: spare.ID})
+		must(t, err)
+		if len(moved) != 1 {
+			t.Fatalf("got %d items on target, want 1", len(moved))
+		}
+		equal(t, "target value", moved[0].StockValue, rub(200))
+		equal(t, "source value", stockValue(t, env, item.ID), rub(100))
+		targetLots, err := repo.Lots(ctx, env.scopeA(), moved[0].ID)
+		must(t, err)
+		equal(t, "target lots", len(targetLots), 1)
+		equal(t, "target lot cost", targetLots[0].UnitCost, rub(100))
+		equal(t, "target lot received", targetLots[0].ReceivedAt.Equal(lots[0].ReceivedAt), true)
+
+		sales, err := repo.CostOfSales(ctx, env.scopeA(), start, time.Now().Add(time.Minute))
+		must(t, err)
+		equal(t, "restocks", len(sales), 1)
+		equal(t, "operation", sales[0].OperationID, restock.ID)
+		equal(t, "cost of sales", sales[0].Cost, cogs)
+		equal(t, "issued", sales[0].Quantity, 12)
+		equal(t, "machine", sales[0].MachineSerial, machine.SerialNumber)
+		sales, err = repo.CostOfSales(ctx, env.scopeB(), start, time.Now().Add(time.Minute))
+		must(t, err)
+		equal(t, "org B restocks", len(sales), 0)
+
+		valuation, err := repo.Valuation(ctx, env.scopeA(), time.Now().Add(time.Minute))
+		must(t, err)
+		if len(valuation) != 2 {
+			t.Fatalf("got %d warehouses, want 2: %+v", len(valuation), valuation)
+		}
+		equal(t, "first warehouse", valuation[0].WarehouseID, main.ID)
+		equal(t, "main quantity", valuation[0].Quantity, 1)
+		equal(t, "main value", valuation[0].Value, rub(100))
+		equal(t, "spare value", valuation[1].Value, rub(200))
+		valuation, err = repo.Valuation(ctx, env.scopeA(), start)
+		must(t, err)
+		equal(t, "warehouses before start", len(valuation), 0)
+	})
+
+	t.Run("Average", func(t *testing.T) {
+		env.SetCostingMethod(t, env.OrgB, repository.CostAverage)
+		warehouse := newWarehouse(t, env, env.OrgB, "Средний", true)
+		product := newProduct(t, env, env.OrgB, "Капсула", "SKU-AVG")
+		item := newItem(t, env, warehouse.ID, product, 10)
+		_, err := repo.Move(ctx, env.scopeB(), item.ID, repository.StockChange{
+			Type: repository.MovementReceipt, Quantity: 10, UnitCost: rub(100),
+		})
+		must(t, err)
+		value := product.DefaultCost.Mul(10) + rub(100).Mul(10)
+
+		// Расход — по средней себестоимости, партии списываются по порядку
+		_, err = repo.Move(ctx, env.scopeB(), item.ID, repository.StockChange{
+			Type: repository.MovementShipment, Quantity: 3,
+		})
+		must(t, err)
+		value -= value.Share(3, 20)
+		equal(t, "value after shipment", stockValue(t, env, item.ID), value)
+		lots, err := repo.Lots(ctx, env.scopeB(), item.ID)
+		must(t, err)
+		equal(t, "lots", len(lots), 2)
+		equal(t, "oldest remaining", lots[0].Remaining, 7)
+
+		// Списание всего остатка обнуляет стоимость без остатка от округлений
+		_, err = repo.Adjust(ctx, env.scopeB(), item.ID, repository.AdjustSet, 0, "")
+		must(t, err)
+		equal(t, "value when empty", stockValue(t, env, item.ID), money.Amount(0))
+		lots, err = repo.Lots(ctx, env.scopeB(), item.ID)
+		must(t, err)
+		equal(t, "lots when empty", len(lots), 0)
+
+		// Излишек пустой позиции оценивается по закупочной цене
+		_, err = repo.Adjust(ctx, env.scopeB(), item.ID, repository.AdjustAdd, 4, "")
+		must(t, err)
+		equal(t, "surplus value", stockValue(t, env, item.ID), product.DefaultCost.Mul(4))
+
+		_, err = repo.Lots(ctx, env.scopeA(), item.ID)
+		wantErr(t, err, repository.ErrNotFound)
+	})
+}
@@ -0,0 +1,405 @@
+package repotest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"vend_erp/internal/models"
+	"vend_erp/internal/money"
+	"vend_erp/internal/repository"
+)
+
+func newWarehouse(t *testing.T, env Env, orgID int64, name string, active bool) models.Warehouse {
+	t.Helper()
+	warehouse := models.Warehouse{
+		Name:          name,
+		Address:       "Промзона, 7",
+		ContactPerson: "Петров",
+		ContactPhone:  "+7 900 111-11-11",
+		TotalCapacity: 1000,
+		IsActive:      active,
+		OrgID:         orgID,
+	}
+	must(t, env.Repos.Inventory.CreateWarehouse(context.Background(), &warehouse))
+	if warehouse.ID == 0 {
+		t.Fatal("Inventory.CreateWarehouse did not set ID")
+	}
+	return warehouse
+}
+
+// newItem заводит остаток товара product на складе. Поля товара
+// копируются в позицию, как их вернет Inventory.Item.
+func newItem(t *testing.T, env Env, warehouseID int64, product models.Product, quantity int) models.WarehouseInventory {
+	t.Helper()
+	item := models.WarehouseInventory{
+		WarehouseID:   warehouseID,
+		ProductID:     product.ID,
+		Quantity:      quantity,
+		MinStockLevel: 10,
+		MaxStockLevel: 100,
+	}
+	must(t, env.Repos.Inventory.CreateItem(context.Background(), &item))
+	if item.ID == 0 {
+		t.Fatal("Inventory.CreateItem did not set ID")
+	}
+	item.CategoryID = product.CategoryID
+	item.ItemType = product.ItemType
+	item.ItemName = product.Name
+	item.Description = product.Description
+	item.UnitPrice = product.DefaultCost
+	item.SKU = product.SKU
+	item.UnitVolume = product.UnitVolume
+	return item
+}
+
+func warehouseID(w models.Warehouse) int64        { return w.ID }
+func itemID(item models.WarehouseInventory) int64 { return item.ID }
+
+func usage(t *testing.T, env Env, id int64) int {
+	t.Helper()
+	warehouse, err := env.Repos.Inventory.Warehouse(context.Background(), allOrgs, id)
+	must(t, err)
+	return warehouse.CurrentUsage
+}
+
+func quantity(t *testing.T, env Env, id int64) int {
+	t.Helper()
+	item, err := env.Repos.Inventory.Item(context.Background(), allOrgs, id)
+	must(t, err)
+	return item.Quantity
+}
+
+func testInventory(t *testing.T, env Env) {
+	ctx := context.Background()
+	repo := env.Repos.Inventory
+	main := newWarehouse(t, env, env.OrgA, "Основной", true)
+	spare := newWarehouse(t, env, env.OrgA, "Запасной", true)
+	closed := newWarehouse(t, env, env.OrgA, "Архив", false)
+	foreign := newWarehouse(t, env, env.OrgB, "Чужой", true)
+
+	low := newItem(t, env, main.ID, newProduct(t, env, env.OrgA, "Зайчик", "SKU-LOW"), 5)
+	out := newItem(t, env, main.ID, newProduct(t, env, env.OrgA, "Мишка", "SKU-OUT"), 0)
+	normal := newItem(t, env, main.ID, newProduct(t, env, env.OrgA, "Котик", "SKU-NORMAL"), 50)
+
+	t.Run("Warehouses", func(t *testing.T) {
+		list, err := repo.Warehouses(ctx, env.scopeA())
+		must(t, err)
+		got := ids(list, warehouseID)
+		equal(t, "inactive excluded", contains(got, closed.ID), false)
+		equal(t, "other org excluded", contains(got, foreign.ID), false)
+		// Сортировка по названию: «Запасной» раньше «Основного»
+		equal(t, "ordered by name", inOrder(got, spare.ID, main.ID), true)
+
+		_, err = repo.Warehouse(ctx, env.scopeB(), main.ID)
+		wantErr(t, err, repository.ErrNotFound)
+		got1, err := repo.Warehouse(ctx, env.scopeA(), closed.ID)
+		must(t, err)
+		equal(t, "inactive warehouse by ID", got1.Name, closed.Name)
+	})
+
+	t.Run("UpdateWarehouse", func(t *testing.T) {
+		changed := spare
+		changed.TotalCapacity = 500
+		wantErr(t, repo.UpdateWarehouse(ctx, env.scopeB(), changed), repository.ErrNotFound)
+		must(t, repo.UpdateWarehouse(ctx, env.scopeA(), changed))
+		got, err := repo.Warehouse(ctx, env.scopeA(), spare.ID)
+		must(t, err)
+		equal(t, "TotalCapacity", got.TotalCapacity, 500)
+		equal(t, "Version", got.Version, spare.Version+1)
+
+		wantErr(t, repo.UpdateWarehouse(ctx, env.scopeA(), changed), repository.ErrConflict)
+	})
+
+	t.Run("Categories", func(t *testing.T) {
+		list, err := repo.Categories(ctx)
+		must(t, err)
+		found := false
+		for _, c := range list {
+			found = found || c.ID == env.CategoryID
+		}
+		equal(t, "category listed", found, true)
+	})
+
+	t.Run("Item", func(t *testing.T) {
+		got, err := repo.Item(ctx, env.scopeA(), low.ID)
+		must(t, err)
+		equal(t, "ItemName", got.ItemName, "Зайчик")
+		equal(t, "ProductID", got.ProductID, low.ProductID)
+		equal(t, "SKU", got.SKU, low.SKU)
+		equal(t, "UnitPrice", got.UnitPrice, money.FromKopecks(8550))
+		equal(t, "MinStockLevel", got.MinStockLevel, 10)
+		equal(t, "WarehouseName", got.WarehouseName, main.Name)
+		equal(t, "WarehouseAddress", got.WarehouseAddress, main.Address)
+		equal(t, "CategoryName", got.CategoryName != "", true)
+		equal(t, "OrgID", got.OrgID, env.OrgA)
+
+		_, err = repo.Item(ctx, env.scopeB(), low.ID)
+		wantErr(t, err, repository.ErrNotFound)
+	})
+
+	t.Run("Usage", func(t *testing.T) {
+		equal(t, "CurrentUsage", usage(t, env, main.ID), 55)
+	})
+
+	t.Run("OneItemPerProduct", func(t *testing.T) {
+		duplicate := low
+		duplicate.ID = 0
+		wantErr(t, repo.CreateItem(ctx, &duplicate), repository.ErrDuplicate)
+
+		// Тот же товар на другом складе — отдельная позиция
+		other := newItem(t, env, spare.ID, env.mustProduct(t, low.ProductID), 0)
+		moved := other
+		moved.WarehouseID = main.ID
+		wantErr(t, repo.UpdateItem(ctx, env.scopeA(), moved), repository.ErrDuplicate)
+		must(t, repo.DeleteItem(ctx, env.scopeA(), other.ID))
+
+		// Остаток заводится только для товара организации склада
+		alien := newProduct(t, env, env.OrgB, "Чужой товар", "SKU-ALIEN")
+		wrong := models.WarehouseInventory{WarehouseID: main.ID, ProductID: alien.ID, Quantity: 1}
+		wantErr(t, repo.CreateItem(ctx, &wrong), repository.ErrNotFound)
+	})
+
+	t.Run("Filters", func(t *testing.T) {
+		cases := []struct {
+			name   string
+			filter repository.InventoryFilter
+			want   []int64
+			absent []int64
+		}{
+			{"warehouse", repository.InventoryFilter{WarehouseID: main.ID}, []int64{low.ID, out.ID, normal.ID}, nil},
+			{"low", repository.InventoryFilter{Stock: repository.StockLow}, []int64{low.ID, out.ID}, []int64{normal.ID}},
+			{"out", repository.InventoryFilter{Stock: repository.StockOut}, []int64{out.ID}, []int64{low.ID, normal.ID}},
+			{"normal", repository.InventoryFilter{Stock: repository.StockNormal}, []int64{normal.ID}, []int64{low.ID, out.ID}},
+			{"type", repository.InventoryFilter{ItemType: "capsule"}, nil, []int64{low.ID, out.ID, normal.ID}},
+		}
+		for _, tc := range cases {
+			list, err := repo.Items(ctx, env.scopeA(), tc.filter)
+			must(t, err)
+			got := ids(list, itemID)
+			for _, id := range tc.want {
+				if !contains(got, id) {
+					t.Errorf("%s: item %d missing from %v", tc.name, id, got)
+				}
+			}
+			for _, id := range tc.absent {
+				if contains(got, id) {
+					t.Errorf("%s: item %d unexpected in %v", tc.name, id, got)
+				}
+			}
+		}
+
+		listB, err := repo.Items(ctx, env.scopeB(), repository.InventoryFilter{})
+		must(t, err)
+		equal(t, "org B sees org A items", contains(ids(listB, itemID), low.ID), false)
+
+		// Порядок: склад, тип, название — «Зайчик», «Котик», «Мишка»
+		list, err := repo.Items(ctx, env.scopeA(), repository.InventoryFilter{WarehouseID: main.ID})
+		must(t, err)
+		equal(t, "ordered by name", inOrder(ids(list, itemID), low.ID, normal.ID, out.ID), true)
+	})
+
+	t.Run("UpdateItemMovesUsage", func(t *testing.T) {
+		moved := newItem(t, env, main.ID, newProduct(t, env, env.OrgA, "Переезд", "SKU-MOVED"), 7)
+		before := usage(t, env, spare.ID)
+
+		moved.WarehouseID = spare.ID
+		must(t, repo.UpdateItem(ctx, env.scopeA(), moved))
+		equal(t, "source usage", usage(t, env, main.ID), 55)
+		equal(t, "target usage", usage(t, env, spare.ID), before+7)
+
+		wantErr(t, repo.UpdateItem(ctx, env.scopeB(), moved), repository.ErrNotFound)
+		wantErr(t, repo.UpdateItem(ctx, env.scopeA(), moved), repository.ErrConflict)
+	})
+
+	t.Run("Adjust", func(t *testing.T) {
+		steps := []struct {
+			kind     string
+			quantity int
+			want     int
+			err      error
+		}{
+			{repository.AdjustAdd, 5, 10, nil},
+			{repository.AdjustSubtract, 3, 7, nil},
+			{repository.AdjustSubtract, 100, 7, repository.ErrInsufficientStock},
+			{repository.AdjustSet, 12, 12, nil},
+		}
+		for _, step := range steps {
+			got, err := repo.Adjust(ctx, env.scopeA(), low.ID, step.kind, step.quantity, "проверка")
+			wantErr(t, err, step.err)
+			if step.err == nil {
+				equal(t, step.kind+" result", got, step.want)
+			}
+			equal(t, step.kind+" stored", quantity(t, env, low.ID), step.want)
+		}
+		equal(t, "CurrentUsage", usage(t, env, main.ID), 62)
+
+		// Корректировка меняет позицию, поэтому форма, открытая до нее, устарела
+		stale := low
+		stale.ItemName = "Зайчик большой"
+		wantErr(t, repo.UpdateItem(ctx, env.scopeA(), stale), repository.ErrConflict)
+
+		if _, err := repo.Adjust(ctx, env.scopeA(), low.ID, "double", 2, ""); err == nil {
+			t.Error("unknown adjustment type accepted")
+		}
+		_, err := repo.Adjust(ctx, env.scopeB(), low.ID, repository.AdjustAdd, 1, "")
+		wantErr(t, err, repository.ErrNotFound)
+	})
+
+	t.Run("Transfer", func(t *testing.T) {
+		spareBefore := usage(t, env, spare.ID)
+
+		must(t, repo.Transfer(ctx, env.scopeA(), normal.ID, spare.ID, 20, "на точку"))
+		equal(t, "source quantity", quantity(t, env, normal.ID), 30)
+		equal(t, "source usage", usage(t, env, main.ID), 42)
+		equal(t, "target usage", usage(t, env, spare.ID), spareBefore+20)
+
+		moved, err := repo.Items(ctx, env.scopeA(), repository.InventoryFilter{WarehouseID: spare.ID})
+		must(t, err)
+		found := false
+		for _, item := range moved {
+			if item.ProductID == normal.ProductID && item.Quantity == 20 {
+				found = true
+				equal(t, "target ItemName", item.ItemName, normal.ItemName)
+			}
+		}
+		equal(t, "target item created", found, true)
+
+		wantErr(t, repo.Transfer(ctx, env.scopeA(), normal.ID, spare.ID, 31, ""), repository.ErrInsufficientStock)
+		wantErr(t, repo.Transfer(ctx, env.scopeA(), normal.ID, foreign.ID, 1, ""), repository.ErrNotFound)
+		wantErr(t, repo.Transfer(ctx, env.scopeB(), normal.ID, spare.ID, 1, ""), repository.ErrNotFound)
+		equal(t, "source unchanged", quantity(t, env, normal.ID), 30)
+	})
+
+	t.Run("Move", func(t *testing.T) {
+		item := newItem(t, env, spare.ID, newProduct(t, env, env.OrgA, "Журнал", "SKU-LOG"), 10)
+
+		steps := []struct {
+			change repository.StockChange
+			want   int
+		}{
+			{repository.StockChange{Type: repository.MovementReceipt, Quantity: 5, Reason: "поставка"}, 15},
+			{repository.StockChange{Type: repository.MovementShipment, Quantity: 3}, 12},
+			{repository.StockChange{Type: repository.MovementShipment, Quantity: 2}, 10},
+		}
+		for _, step := range steps {
+			got, err := repo.Move(ctx, env.scopeA(), item.ID, step.change)
+			must(t, err)
+			equal(t, step.change.Type+" result", got, step.want)
+		}
+		equal(t, "stored", quantity(t, env, item.ID), 10)
+
+		_, err := repo.Move(ctx, env.scopeA(), item.ID, repository.StockChange{Type: repository.MovementShipment, Quantity: 11})
+		wantErr(t, err, repository.ErrInsufficientStock)
+		_, err = repo.Move(ctx, env.scopeB(), item.ID, repository.StockChange{Type: repository.MovementReceipt, Quantity: 1})
+		wantErr(t, err, repository.ErrNotFound)
+		if _, err := repo.Move(ctx, env.scopeA(), item.ID, repository.StockChange{Type: repository.MovementReceipt}); err == nil {
+			t.Error("zero quantity accepted")
+		}
+		if _, err := repo.Move(ctx, env.scopeA(), item.ID, repository.StockChange{Type: repository.MovementOpening, Quantity: 1}); err == nil {
+			t.Error("opening movement accepted")
+		}
+		// Пополнение автомата проводит только операция пополнения
+		if _, err := repo.Move(ctx, env.scopeA(), item.ID, repository.StockChange{Type: repository.MovementRestock, Quantity: 1}); err == nil {
+			t.Error("restock movement accepted")
+		}
+		equal(t, "unchanged", quantity(t, env, item.ID), 10)
+
+		// Журнал: каждое изменение — одна строка, новые сверху
+		movements, err := repo.Movements(ctx, env.scopeA(), item.ID)
+		must(t, err)
+		equal(t, "movements", len(movements), 4)
+		want := []struct {
+			kind              string
+			quantity, balance int
+		}{
+			{repository.MovementShipment, -2, 10},
+			{repository.MovementShipment, -3, 12},
+			{repository.MovementReceipt, 5, 15},
+			{repository.MovementOpening, 10, 10},
+		}
+		for i := 0; i < len(want) && i < len(movements); i++ {
+			equal(t, "Type", movements[i].Type, want[i].kind)
+			equal(t, "Quantity", movements[i].Quantity, want[i].quantity)
+			equal(t, "BalanceAfter", movements[i].BalanceAfter, want[i].balance)
+		}
+		if len(movements) > 0 {
+			equal(t, "WarehouseName", movements[0].WarehouseName, spare.Name)
+		}
+
+		foreign, err := repo.Movements(ctx, env.scopeB(), item.ID)
+		must(t, err)
+		equal(t, "foreign movements", len(foreign), 0)
+
+		balance, err := repo.BalanceAt(ctx, env.scopeA(), item.ID, time.Now().Add(-time.Hour))
+		must(t, err)
+		equal(t, "balance before creation", balance, 0)
+		balance, err = repo.BalanceAt(ctx, env.scopeA(), item.ID, time.Now().Add(time.Hour))
+		must(t, err)
+		equal(t, "current balance", balance, 10)
+		_, err = repo.BalanceAt(ctx, env.scopeB(), item.ID, time.Now())
+		wantErr(t, err, repository.ErrNotFound)
+	})
+
+	t.Run("TransferMovements", func(t *testing.T) {
+		movements, err := repo.Movements(ctx, env.scopeA(), normal.ID)
+		must(t, err)
+		if len(movements) == 0 {
+			t.Fatal("no movements after transfer")
+		}
+		out := movements[0]
+		equal(t, "Type", out.Type, repository.MovementTransferOut)
+		equal(t, "Quantity", out.Quantity, -20)
+		equal(t, "BalanceAfter", out.BalanceAfter, 30)
+
+		in, err := repo.Movements(ctx, env.scopeA(), out.TransferItemID)
+		must(t, err)
+		if len(in) == 0 {
+			t.Fatal("no movements on the target item")
+		}
+		equal(t, "target Type", in[0].Type, repository.MovementTransferIn)
+		equal(t, "target Quantity", in[0].Quantity, 20)
+		equal(t, "target TransferItemID", in[0].TransferItemID, normal.ID)
+
+		if err := repo.Transfer(ctx, env.scopeA(), normal.ID, main.ID, 1, ""); err == nil {
+			t.Error("transfer to the same warehouse accepted")
+		}
+
+		// Повторное перемещение того же товара пополняет ту же позицию
+		item := newItem(t, env, spare.ID, newProduct(t, env, env.OrgA, "Дважды", "SKU-TWICE"), 10)
+		must(t, repo.Transfer(ctx, env.scopeA(), item.ID, closed.ID, 3, ""))
+		must(t, repo.Transfer(ctx, env.scopeA(), item.ID, closed.ID, 3, ""))
+		twice, err := repo.Movements(ctx, env.scopeA(), item.ID)
+		must(t, err)
+		if len(twice) < 2 {
+			t.Fatal("transfers not recorded")
+		}
+		equal(t, "same target item", twice[0].TransferItemID, twice[1].TransferItemID)
+		equal(t, "target quantity", quantity(t, env, twice[0].TransferItemID), 6)
+	})
+
+	t.Run("DeleteItem", func(t *testing.T) {
+		empty := newItem(t, env, main.ID, newProduct(t, env, env.OrgA, "Пустая", "SKU-EMPTY"), 0)
+		must(t, repo.DeleteItem(ctx, env.scopeB(), empty.ID))
+		_, err := repo.Item(ctx, env.scopeA(), empty.ID)
+		must(t, err)
+
+		must(t, repo.DeleteItem(ctx, env.scopeA(), empty.ID))
+		_, err = repo.Item(ctx, env.scopeA(), empty.ID)
+		wantErr(t, err, repository.ErrNotFound)
+		must(t, repo.DeleteItem(ctx, env.scopeA(), empty.ID))
+
+		// Позиция с остатком или историей движений не удаляется, ее
+		// журнал и остаток на прошлые даты сохраняются
+		wantErr(t, repo.DeleteItem(ctx, env.scopeA(), low.ID), repository.ErrInUse)
+		_, err = repo.Adjust(ctx, env.scopeA(), low.ID, repository.AdjustSet, 0, "")
+		must(t, err)
+		wantErr(t, repo.DeleteItem(ctx, env.scopeA(), low.ID), repository.ErrInUse)
+		movements, err := repo.Movements(ctx, env.scopeA(), low.ID)
+		must(t, err)
+		if len(movements) == 0 {
+			t.Error("movements of a kept item are gone")
+		}
+		equal(t, "quantity", quantity(t, env, low.ID), 0)
+	})
+}
@@ -0,0 +1,100 @@
+package repotest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"vend_erp/internal/models"
+	"vend_erp/internal/repository"
+)
+
+func inviteID(i models.Invite) int64 { return i.ID }
+
+func testInvites(t *testing.T, env Env) {
+	ctx := context.Background()
+	repo := env.Repos.Invites
+	admin := newUser(t, env, env.OrgA, "inviter", models.UserStatusActive)
+
+	newInvite := func(name string, orgID int64, ttl time.Duration) models.Invite {
+		t.Helper()
+		invite := models.Invite{
+			Email:     env.unique(name) + "@example.com",
+			UserRole:  "moderator",
+			Team:      "Юг",
+			OrgID:     orgID,
+			InvitedBy: admin.ID,
+			ExpiresAt: time.Now().Add(ttl),
+		}
+		must(t, repo.Create(ctx, &invite, env.unique("hash-"+name)))
+		if invite.ID == 0 {
+			t.Fatal("Invites.Create did not set ID")
+		}
+		return invite
+	}
+	first := newInvite("first", env.OrgA, time.Hour)
+	expired := newInvite("expired", env.OrgA, -time.Hour)
+	revoked := newInvite("revoked", env.OrgA, time.Hour)
+	last := newInvite("last", env.OrgA, time.Hour)
+	other := newInvite("other", env.OrgB, time.Hour)
+
+	t.Run("Find", func(t *testing.T) {
+		got, err := repo.Find(ctx, env.unique("hash-first"))
+		must(t, err)
+		equal(t, "ID", got.ID, first.ID)
+		equal(t, "Email", got.Email, first.Email)
+		equal(t, "UserRole", got.UserRole, "moderator")
+		equal(t, "Team", got.Team, "Юг")
+		equal(t, "OrgName", got.OrgName, "Организация A")
+		equal(t, "InvitedBy", got.InvitedBy, admin.ID)
+		equal(t, "Usable", got.Usable(), true)
+
+		// Просроченное приглашение находится: проверяет вызывающий
+		got, err = repo.Find(ctx, env.unique("hash-expired"))
+		must(t, err)
+		equal(t, "expired Usable", got.Usable(), false)
+
+		_, err = repo.Find(ctx, env.unique("hash-missing"))
+		wantErr(t, err, repository.ErrNotFound)
+		duplicate := first
+		wantErr(t, repo.Create(ctx, &duplicate, env.unique("hash-first")), repository.ErrDuplicate)
+	})
+
+	t.Run("Revoke", func(t *testing.T) {
+		must(t, repo.Revoke(ctx, env.scopeB(), revoked.ID))
+		got, err := repo.Find(ctx, env.unique("hash-revoked"))
+		must(t, err)
+		equal(t, "revoked from other org", got.RevokedAt == nil, true)
+
+		must(t, repo.Revoke(ctx, env.scopeA(), revoked.ID))
+		got, err = repo.Find(ctx, env.unique("hash-revoked"))
+		must(t, err)
+		equal(t, "revoked", got.RevokedAt != nil, true)
+		equal(t, "Usable", got.Usable(), false)
+		must(t, repo.Revoke(ctx, env.scopeA(), revoked.ID))
+	})
+
+	t.Run("List", func(t *testing.T) {
+		list, err := repo.List(ctx, env.scopeA())
+		must(t, err)
+		got := ids(list, inviteID)
+		if contains(got, other.ID) {
+			t.Errorf("List(A) = %v, contains invite of B", got)
+		}
+		// Сначала действующие, новые первыми
+		if !inOrder(got, last.ID, first.ID, revoked.ID) || !inOrder(got, first.ID, expired.ID) {
+			t.Errorf("List(A) = %v, want usable %v first", got, []int64{last.ID, first.ID})
+		}
+		for _, invite := range list {
+			if invite.ID == first.ID {
+				equal(t, "InvitedByName", invite.InvitedByName, admin.Username)
+			}
+		}
+
+		list, err = repo.List(ctx, allOrgs)
+		must(t, err)
+		if !contains(ids(list, inviteID), other.ID) {
+			t.Errorf("List(all) = %v, want invite of B", ids(list, inviteID))
+		}
+	})
+}
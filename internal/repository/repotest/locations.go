@@ -0,0 +1,93 @@
+package repotest
+
+import (
+	"context"
+	"testing"
+
+	"vend_erp/internal/models"
+	"vend_erp/internal/money"
+	"vend_erp/internal/repository"
+)
+
+func locationID(l models.Location) int64 { return l.ID }
+
+func testLocations(t *testing.T, env Env) {
+	ctx := context.Background()
+	repo := env.Repos.Locations
+	location := newLocation(t, env, env.OrgA, "Бизнес-центр", true)
+
+	t.Run("Get", func(t *testing.T) {
+		got, err := repo.Get(ctx, env.scopeA(), location.ID)
+		must(t, err)
+		equal(t, "Name", got.Name, location.Name)
+		equal(t, "Address", got.Address, location.Address)
+		equal(t, "ContactPerson", got.ContactPerson, location.ContactPerson)
+		equal(t, "ContactPhone", got.ContactPhone, location.ContactPhone)
+		equal(t, "MonthlyRent", got.MonthlyRent, location.MonthlyRent)
+		equal(t, "RentDueDay", got.RentDueDay, location.RentDueDay)
+		equal(t, "IsActive", got.IsActive, true)
+		equal(t, "OrgID", got.OrgID, env.OrgA)
+		equal(t, "OrgName", got.OrgName != "", true)
+	})
+
+	t.Run("Scope", func(t *testing.T) {
+		_, err := repo.Get(ctx, env.scopeB(), location.ID)
+		wantErr(t, err, repository.ErrNotFound)
+
+		listB, err := repo.List(ctx, env.scopeB())
+		must(t, err)
+		equal(t, "in org B list", contains(ids(listB, locationID), location.ID), false)
+		all, err := repo.List(ctx, allOrgs)
+		must(t, err)
+		equal(t, "in all orgs list", contains(ids(all, locationID), location.ID), true)
+	})
+
+	t.Run("ListActive", func(t *testing.T) {
+		closed := newLocation(t, env, env.OrgA, "Аэропорт", false)
+		first := newLocation(t, env, env.OrgA, "Автовокзал", true)
+		other := newLocation(t, env, env.OrgB, "Вокзал", true)
+
+		active, err := repo.ListActive(ctx, env.OrgA)
+		must(t, err)
+		got := ids(active, locationID)
+		equal(t, "inactive excluded", contains(got, closed.ID), false)
+		equal(t, "other org excluded", contains(got, other.ID), false)
+		// Сортировка по названию
+		equal(t, "ordered by name", inOrder(got, first.ID, location.ID), true)
+	})
+
+	t.Run("Update", func(t *testing.T) {
+		changed := location
+		changed.Name = "Бизнес-центр Север"
+		changed.MonthlyRent = money.FromRubles(20000)
+		changed.IsActive = false
+
+		wantErr(t, repo.Update(ctx, env.scopeB(), changed), repository.ErrNotFound)
+		must(t, repo.Update(ctx, env.scopeA(), changed))
+
+		got, err := repo.Get(ctx, env.scopeA(), location.ID)
+		must(t, err)
+		equal(t, "Name", got.Name, changed.Name)
+		equal(t, "MonthlyRent", got.MonthlyRent, changed.MonthlyRent)
+		equal(t, "IsActive", got.IsActive, false)
+		equal(t, "Version", got.Version, location.Version+1)
+
+		wantErr(t, repo.Update(ctx, env.scopeA(), changed), repository.ErrConflict)
+	})
+
+	t.Run("DeleteDetachesMachines", func(t *testing.T) {
+		machine := newMachine(t, env, env.OrgA, location.ID, "SN-LOC")
+
+		must(t, repo.Delete(ctx, env.scopeB(), location.ID))
+		_, err := repo.Get(ctx, env.scopeA(), location.ID)
+		must(t, err)
+
+		must(t, repo.Delete(ctx, env.scopeA(), location.ID))
+		_, err = repo.Get(ctx, env.scopeA(), location.ID)
+		wantErr(t, err, repository.ErrNotFound)
+
+		got, err := env.Repos.Machines.Get(ctx, env.scopeA(), machine.ID)
+		must(t, err)
+		equal(t, "machine LocationID", got.LocationID, int64(0))
+	})
+}
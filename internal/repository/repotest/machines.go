@@ -0,0 +1,176 @@
+package repotest
+
+import (
+	"context"
+	"testing"
+
+	"vend_erp/internal/models"
+	"vend_erp/internal/money"
+	"vend_erp/internal/repository"
+)
+
+func newLocation(t *testing.T, env Env, orgID int64, name string, active bool) models.Location {
+	t.Helper()
+	location := models.Location{
+		Name:          name,
+		Address:       "ул. Ленина, 1",
+		ContactPerson: "Иванов",
+		ContactPhone:  "+7 900 000-00-00",
+		MonthlyRent:   money.FromRubles(15000),
+		RentDueDay:    5,
+		IsActive:      active,
+		OrgID:         orgID,
+	}
+	must(t, env.Repos.Locations.Create(context.Background(), &location))
+	if location.ID == 0 {
+		t.Fatal("Locations.Create did not set ID")
+	}
+	return location
+}
+
+func newMachine(t *testing.T, env Env, orgID, locationID int64, serial string) models.VendingMachine {
+	t.Helper()
+	machine := models.VendingMachine{
+		SerialNumber:        env.unique(serial),
+		Model:               "Claw-200",
+		LocationID:          locationID,
+		CapacityToys:        200,
+		CurrentToysCount:    120,
+		CashAmount:          money.FromKopecks(123450),
+		Status:              "active",
+		LastMaintenanceDate: date(2024, 3, 1),
+		NextMaintenanceDate: date(2024, 6, 1),
+		InstallationDate:    date(2023, 1, 15),
+		OrgID:               orgID,
+	}
+	must(t, env.Repos.Machines.Create(context.Background(), &machine))
+	if machine.ID == 0 {
+		t.Fatal("Machines.Create did not set ID")
+	}
+	return machine
+}
+
+func machineID(m models.VendingMachine) int64 { return m.ID }
+
+func testMachines(t *testing.T, env Env) {
+	ctx := context.Background()
+	repo := env.Repos.Machines
+	location := newLocation(t, env, env.OrgA, "ТЦ Галерея", true)
+	machine := newMachine(t, env, env.OrgA, location.ID, "SN-1")
+
+	t.Run("Get", func(t *testing.T) {
+		got, err := repo.Get(ctx, env.scopeA(), machine.ID)
+		must(t, err)
+		equal(t, "SerialNumber", got.SerialNumber, machine.SerialNumber)
+		equal(t, "Model", got.Model, machine.Model)
+		equal(t, "Status", got.Status, "active")
+		equal(t, "CapacityToys", got.CapacityToys, 200)
+		equal(t, "CurrentToysCount", got.CurrentToysCount, 120)
+		equal(t, "CashAmount", got.CashAmount, machine.CashAmount)
+		equal(t, "LocationID", got.LocationID, location.ID)
+		equal(t, "LocationName", got.LocationName, location.Name)
+		equal(t, "OrgID", got.OrgID, env.OrgA)
+		equal(t, "OrgName", got.OrgName != "", true)
+		sameDay(t, "LastMaintenanceDate", got.LastMaintenanceDate, machine.LastMaintenanceDate)
+		sameDay(t, "NextMaintenanceDate", got.NextMaintenanceDate, machine.NextMaintenanceDate)
+		sameDay(t, "InstallationDate", got.InstallationDate, machine.InstallationDate)
+		equal(t, "CreatedAt set", got.CreatedAt.IsZero(), false)
+	})
+
+	t.Run("Scope", func(t *testing.T) {
+		_, err := repo.Get(ctx, env.scopeB(), machine.ID)
+		wantErr(t, err, repository.ErrNotFound)
+		_, err = repo.Get(ctx, allOrgs, machine.ID)
+		must(t, err)
+
+		listA, err := repo.List(ctx, env.scopeA())
+		must(t, err)
+		equal(t, "in org A list", contains(ids(listA, machineID), machine.ID), true)
+		listB, err := repo.List(ctx, env.scopeB())
+		must(t, err)
+		equal(t, "in org B list", contains(ids(listB, machineID), machine.ID), false)
+	})
+
+	t.Run("FindBySerial", func(t *testing.T) {
+		got, err := repo.FindBySerial(ctx, env.scopeA(), machine.SerialNumber)
+		must(t, err)
+		equal(t, "ID", got.ID, machine.ID)
+		equal(t, "LocationName", got.LocationName, location.Name)
+		_, err = repo.FindBySerial(ctx, env.scopeB(), machine.SerialNumber)
+		wantErr(t, err, repository.ErrNotFound)
+		_, err = repo.FindBySerial(ctx, env.scopeA(), "NO-SUCH-SERIAL")
+		wantErr(t, err, repository.ErrNotFound)
+	})
+
+	t.Run("ListNewestFirst", func(t *testing.T) {
+		newer := newMachine(t, env, env.OrgA, location.ID, "SN-2")
+		list, err := repo.List(ctx, env.scopeA())
+		must(t, err)
+		if len(list) < 2 || list[0].ID != newer.ID {
+			t.Fatalf("List order = %v, want %d first", ids(list, machineID), newer.ID)
+		}
+	})
+
+	t.Run("DuplicateSerial", func(t *testing.T) {
+		duplicate := machine
+		duplicate.ID = 0
+		wantErr(t, repo.Create(ctx, &duplicate), repository.ErrDuplicate)
+	})
+
+	t.Run("Update", func(t *testing.T) {
+		changed := machine
+		changed.Status = "maintenance"
+		changed.CashAmount = 0
+		changed.NextMaintenanceDate = date(2024, 9, 1)
+
+		wantErr(t, repo.Update(ctx, env.scopeB(), changed), repository.ErrNotFound)
+		must(t, repo.Update(ctx, env.scopeA(), changed))
+
+		got, err := repo.Get(ctx, env.scopeA(), machine.ID)
+		must(t, err)
+		equal(t, "Status", got.Status, "maintenance")
+		equal(t, "CashAmount", got.CashAmount, money.Amount(0))
+		sameDay(t, "NextMaintenanceDate", got.NextMaintenanceDate, changed.NextMaintenanceDate)
+		equal(t, "Version", got.Version, machine.Version+1)
+
+		// Повторное сохранение по прочитанной ранее версии
+		wantErr(t, repo.Update(ctx, env.scopeA(), changed), repository.ErrConflict)
+
+		active, err := repo.ListActive(ctx, env.OrgA)
+		must(t, err)
+		equal(t, "in active list", contains(ids(active, machineID), machine.ID), false)
+	})
+
+	t.Run("ListActive", func(t *testing.T) {
+		b := newMachine(t, env, env.OrgA, location.ID, "SN-B")
+		a := newMachine(t, env, env.OrgA, location.ID, "SN-A")
+		other := newMachine(t, env, env.OrgB, 0, "SN-OTHER")
+
+		active, err := repo.ListActive(ctx, env.OrgA)
+		must(t, err)
+		got := ids(active, machineID)
+		equal(t, "other org excluded", contains(got, other.ID), false)
+		// Сортировка по серийному номеру
+		equal(t, "ordered by serial", inOrder(got, a.ID, b.ID), true)
+	})
+
+	t.Run("WithoutLocation", func(t *testing.T) {
+		m := newMachine(t, env, env.OrgA, 0, "SN-NOLOC")
+		got, err := repo.Get(ctx, env.scopeA(), m.ID)
+		must(t, err)
+		equal(t, "LocationID", got.LocationID, int64(0))
+		equal(t, "LocationName", got.LocationName, "Не назначена")
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		must(t, repo.Delete(ctx, env.scopeB(), machine.ID))
+		_, err := repo.Get(ctx, env.scopeA(), machine.ID)
+		must(t, err)
+
+		must(t, repo.Delete(ctx, env.scopeA(), machine.ID))
+		_, err = repo.Get(ctx, env.scopeA(), machine.ID)
+		wantErr(t, err, repository.ErrNotFound)
+		// Повторное удаление не ошибка
+		must(t, repo.Delete(ctx, env.scopeA(), machine.ID))
+	})
+}
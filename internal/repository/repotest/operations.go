@@ -0,0 +1,205 @@
+package repotest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"vend_erp/internal/models"
+	"vend_erp/internal/money"
+	"vend_erp/internal/repository"
+)
+
+func newOperation(t *testing.T, env Env, machine models.VendingMachine, performer int64, at time.Time) models.VendingOperation {
+	t.Helper()
+	operation := models.VendingOperation{
+		VendingMachineID: machine.ID,
+		OperationType:    "restock",
+		PerformedBy:      performer,
+		OperationDate:    at,
+		ToysBefore:       20,
+		ToysAfter:        180,
+		ToysAdded:        160,
+		CashBefore:       money.FromRubles(500),
+		CashAfter:        0,
+		CashCollected:    money.FromRubles(500),
+		OrgID:            machine.OrgID,
+	}
+	must(t, env.Repos.Operations.Create(context.Background(), &operation))
+	if operation.ID == 0 {
+		t.Fatal("Operations.Create did not set ID")
+	}
+	return operation
+}
+
+// warehouseRestock записывает пополнение автомата machine со склада
+// warehouseID: added единиц товара productID.
+func warehouseRestock(env Env, machine models.VendingMachine, performer, warehouseID, productID int64, added int) (models.VendingOperation, error) {
+	operation := models.VendingOperation{
+		VendingMachineID: machine.ID,
+		OperationType:    "restock",
+		PerformedBy:      performer,
+		OperationDate:    time.Now(),
+		ToysAdded:        added,
+		ToysAfter:        added,
+		WarehouseID:      warehouseID,
+		OrgID:            machine.OrgID,
+		Items:            []models.OperationItem{{ProductID: productID, Added: added}},
+	}
+	err := env.Repos.Operations.Create(context.Background(), &operation)
+	return operation, err
+}
+
+func operationID(op models.VendingOperation) int64 { return op.ID }
+
+func testOperations(t *testing.T, env Env) {
+	ctx := context.Background()
+	repo := env.Repos.Operations
+	machine := newMachine(t, env, env.OrgA, 0, "SN-OP")
+	performer := newUser(t, env, env.OrgA, "operator", models.UserStatusActive)
+	at := time.Date(2024, 5, 10, 14, 30, 0, 0, time.UTC)
+	operation := newOperation(t, env, machine, performer.ID, at)
+
+	t.Run("Get", func(t *testing.T) {
+		got, err := repo.Get(ctx, env.scopeA(), operation.ID)
+		must(t, err)
+		equal(t, "VendingMachineID", got.VendingMachineID, machine.ID)
+		equal(t, "MachineSerial", got.MachineSerial, machine.SerialNumber)
+		equal(t, "PerformedBy", got.PerformedBy, performer.ID)
+		equal(t, "PerformerName", got.PerformerName, performer.Username)
+		equal(t, "OperationType", got.OperationType, "restock")
+		equal(t, "OperationDate", got.OperationDate.Format(time.DateTime), at.Format(time.DateTime))
+		equal(t, "ToysAdded", got.ToysAdded, 160)
+		equal(t, "CashCollected", got.CashCollected, operation.CashCollected)
+		equal(t, "OrgID", got.OrgID, env.OrgA)
+	})
+
+	t.Run("Scope", func(t *testing.T) {
+		_, err := repo.Get(ctx, env.scopeB(), operation.ID)
+		wantErr(t, err, repository.ErrNotFound)
+		listB, err := repo.List(ctx, env.scopeB())
+		must(t, err)
+		equal(t, "in org B list", contains(ids(listB, operationID), operation.ID), false)
+	})
+
+	t.Run("ListLatestFirst", func(t *testing.T) {
+		older := newOperation(t, env, machine, performer.ID, at.AddDate(0, 0, -1))
+		later := newOperation(t, env, machine, performer.ID, at.AddDate(0, 0, 1))
+
+		list, err := repo.List(ctx, env.scopeA())
+		must(t, err)
+		equal(t, "ordered by date", inOrder(ids(list, operationID), later.ID, operation.ID, older.ID), true)
+	})
+
+	t.Run("Update", func(t *testing.T) {
+		changed := operation
+		changed.OperationType = "collection"
+		changed.CashCollected = money.FromKopecks(75025)
+
+		wantErr(t, repo.Update(ctx, env.scopeB(), changed), repository.ErrNotFound)
+		must(t, repo.Update(ctx, env.scopeA(), changed))
+
+		got, err := repo.Get(ctx, env.scopeA(), operation.ID)
+		must(t, err)
+		equal(t, "OperationType", got.OperationType, "collection")
+		equal(t, "CashCollected", got.CashCollected, changed.CashCollected)
+		equal(t, "Version", got.Version, operation.Version+1)
+
+		wantErr(t, repo.Update(ctx, env.scopeA(), changed), repository.ErrConflict)
+	})
+
+	t.Run("Warehouse", func(t *testing.T) {
+		warehouse := newWarehouse(t, env, env.OrgA, "Склад пополнений", true)
+		foreign := newWarehouse(t, env, env.OrgB, "Чужой склад", true)
+		bear := newProduct(t, env, env.OrgA, "Мишка", "SKU-OP-BEAR")
+		absent := newProduct(t, env, env.OrgA, "Зайчик", "SKU-OP-ABSENT")
+		item := newItem(t, env, warehouse.ID, bear, 10)
+		loader := newMachine(t, env, env.OrgA, 0, "SN-OP-STOCK")
+
+		// Пополнение со склада выдает добавленное движением операции
+		op, err := warehouseRestock(env, loader, performer.ID, warehouse.ID, bear.ID, 4)
+		must(t, err)
+		equal(t, "issued", quantity(t, env, item.ID), 6)
+		movements, err := env.Repos.Inventory.Movements(ctx, env.scopeA(), item.ID)
+		must(t, err)
+		equal(t, "Type", movements[0].Type, repository.MovementRestock)
+		equal(t, "Quantity", movements[0].Quantity, -4)
+		equal(t, "OperationID", movements[0].OperationID, op.ID)
+		equal(t, "MachineSerial", movements[0].MachineSerial, loader.SerialNumber)
+
+		// Правка доводит выданное до нового пополнения
+		got, err := repo.Get(ctx, env.scopeA(), op.ID)
+		must(t, err)
+		equal(t, "WarehouseID", got.WarehouseID, warehouse.ID)
+		equal(t, "WarehouseName", got.WarehouseName, warehouse.Name)
+		got.Items[0].Added = 7
+		must(t, repo.Update(ctx, env.scopeA(), got))
+		equal(t, "issued after update", quantity(t, env, item.ID), 3)
+
+		// Товара не хватает — операция не меняется
+		got.Version++
+		got.Items[0].Added = 11
+		wantErr(t, repo.Update(ctx, env.scopeA(), got), repository.ErrInsufficientStock)
+		equal(t, "issued after rejected update", quantity(t, env, item.ID), 3)
+		saved, err := repo.Get(ctx, env.scopeA(), op.ID)
+		must(t, err)
+		equal(t, "Added after rejected update", saved.Items[0].Added, 7)
+
+		// Лишнее возвращается по себестоимости выдачи
+		got.Items[0].Added = 2
+		must(t, repo.Update(ctx, env.scopeA(), got))
+		equal(t, "issued after return", quantity(t, env, item.ID), 8)
+		equal(t, "value after return", stockValue(t, env, item.ID), bear.DefaultCost.Mul(8))
+
+		// Себестоимость операции — за вычетом возвратов
+		sales := func() map[int64]repository.OperationCost {
+			list, err := env.Repos.Inventory.CostOfSales(ctx, env.scopeA(), at, time.Now().Add(time.Minute))
+			must(t, err)
+			lines := make(map[int64]repository.OperationCost)
+			for _, c := range list {
+				if c.MachineSerial == loader.SerialNumber {
+					c.Quantity += lines[c.OperationID].Quantity
+					c.Cost += lines[c.OperationID].Cost
+					lines[c.OperationID] = c
+				}
+			}
+			return lines
+		}
+		lines := sales()
+		equal(t, "cost lines", len(lines), 1)
+		equal(t, "cost Quantity", lines[op.ID].Quantity, 2)
+		equal(t, "cost Cost", lines[op.ID].Cost, bear.DefaultCost.Mul(2))
+
+		_, err = warehouseRestock(env, loader, performer.ID, foreign.ID, bear.ID, 1)
+		wantErr(t, err, repository.ErrNotFound)
+		_, err = warehouseRestock(env, loader, performer.ID, warehouse.ID, absent.ID, 1)
+		wantErr(t, err, repository.ErrInsufficientStock)
+
+		// Удаление возвращает на склад все выданное
+		must(t, repo.Delete(ctx, env.scopeA(), op.ID))
+		equal(t, "after delete", quantity(t, env, item.ID), 10)
+		equal(t, "value after delete", stockValue(t, env, item.ID), bear.DefaultCost.Mul(10))
+		// Движения удаленной операции остаются без нее и в сумме дают ноль
+		lines = sales()
+		equal(t, "cost lines after delete", len(lines), 1)
+		equal(t, "unlinked Quantity", lines[0].Quantity, 0)
+		equal(t, "unlinked Cost", lines[0].Cost, money.Amount(0))
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		must(t, repo.Delete(ctx, env.scopeB(), operation.ID))
+		_, err := repo.Get(ctx, env.scopeA(), operation.ID)
+		must(t, err)
+
+		must(t, repo.Delete(ctx, env.scopeA(), operation.ID))
+		_, err = repo.Get(ctx, env.scopeA(), operation.ID)
+		wantErr(t, err, repository.ErrNotFound)
+	})
+
+	t.Run("DeletedWithMachine", func(t *testing.T) {
+		op := newOperation(t, env, machine, performer.ID, at)
+		must(t, env.Repos.Machines.Delete(ctx, env.scopeA(), machine.ID))
+		_, err := repo.Get(ctx, env.scopeA(), op.ID)
+		wantErr(t, err, repository.ErrNotFound)
+	})
+}
@@ -0,0 +1,123 @@
+package repotest
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"vend_erp/internal/models"
+	"vend_erp/internal/repository"
+)
+
+func orgID(o models.Organization) int64 { return o.ID }
+
+func testOrganizations(t *testing.T, env Env) {
+	ctx := context.Background()
+	repo := env.Repos.Organizations
+	user := newUser(t, env, env.OrgA, "org-member", models.UserStatusActive)
+
+	created := models.Organization{
+		Name:          "Организация C",
+		Slug:          "repotest-" + env.unique("c"),
+		IsActive:      true,
+		CostingMethod: repository.CostAverage,
+	}
+	must(t, repo.Create(ctx, &created))
+	if created.ID == 0 {
+		t.Fatal("Organizations.Create did not set ID")
+	}
+
+	t.Run("Get", func(t *testing.T) {
+		got, err := repo.Get(ctx, created.ID)
+		must(t, err)
+		equal(t, "Name", got.Name, created.Name)
+		equal(t, "Slug", got.Slug, created.Slug)
+		equal(t, "IsActive", got.IsActive, true)
+		equal(t, "CostingMethod", got.CostingMethod, repository.CostAverage)
+
+		got, err = repo.FindBySlug(ctx, created.Slug)
+		must(t, err)
+		equal(t, "FindBySlug", got.ID, created.ID)
+
+		_, err = repo.FindBySlug(ctx, "repotest-"+env.unique("missing"))
+		wantErr(t, err, repository.ErrNotFound)
+
+		duplicate := created
+		duplicate.Name = "Дубликат"
+		wantErr(t, repo.Create(ctx, &duplicate), repository.ErrDuplicate)
+	})
+
+	t.Run("List", func(t *testing.T) {
+		all, err := repo.List(ctx)
+		must(t, err)
+		if !inOrder(ids(all, orgID), env.OrgA, env.OrgB, created.ID) {
+			t.Errorf("List = %v, want organizations A, B, C by name", ids(all, orgID))
+		}
+
+		member, err := repo.Member(ctx, user.ID)
+		must(t, err)
+		equal(t, "member of", len(member), 1)
+		if len(member) == 1 {
+			equal(t, "member of", member[0].ID, env.OrgA)
+		}
+	})
+
+	t.Run("Update", func(t *testing.T) {
+		changed := created
+		changed.Name = "Организация C2"
+		changed.IsActive = false
+		changed.CostingMethod = repository.CostFIFO
+		must(t, repo.Update(ctx, changed))
+		got, err := repo.Get(ctx, created.ID)
+		must(t, err)
+		equal(t, "Name", got.Name, "Организация C2")
+		equal(t, "IsActive", got.IsActive, false)
+		equal(t, "CostingMethod", got.CostingMethod, repository.CostFIFO)
+
+		changed.ID = -1
+		wantErr(t, repo.Update(ctx, changed), repository.ErrNotFound)
+	})
+
+	t.Run("Default", func(t *testing.T) {
+		def, err := repo.FindBySlug(ctx, repository.DefaultOrganization)
+		if errors.Is(err, repository.ErrNotFound) {
+			def = models.Organization{
+				Name: "По умолчанию", Slug: repository.DefaultOrganization,
+				IsActive: true, CostingMethod: repository.CostFIFO,
+			}
+			must(t, repo.Create(ctx, &def))
+		} else {
+			must(t, err)
+		}
+
+		// Код и активность организации по умолчанию не меняются
+		changed := def
+		changed.Slug = "repotest-" + env.unique("renamed")
+		changed.IsActive = false
+		must(t, repo.Update(ctx, changed))
+		got, err := repo.Get(ctx, def.ID)
+		must(t, err)
+		equal(t, "Slug", got.Slug, repository.DefaultOrganization)
+		equal(t, "IsActive", got.IsActive, true)
+	})
+
+	t.Run("CanSwitch", func(t *testing.T) {
+		for _, tc := range []struct {
+			name       string
+			orgID      int64
+			superAdmin bool
+			want       bool
+		}{
+			{"member", env.OrgA, false, true},
+			{"not a member", env.OrgB, false, false},
+			{"superadmin", env.OrgB, true, true},
+			// Организация C отключена в Update
+			{"inactive", created.ID, true, false},
+			{"missing", -1, true, false},
+		} {
+			got, err := repo.CanSwitch(ctx, user.ID, tc.orgID, tc.superAdmin)
+			must(t, err)
+			equal(t, tc.name, got, tc.want)
+		}
+	})
+}
@@ -0,0 +1,209 @@
+package repotest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"vend_erp/internal/models"
+	"vend_erp/internal/money"
+	"vend_erp/internal/repository"
+)
+
+func testPlanogram(t *testing.T, env Env) {
+	ctx := context.Background()
+	repo := env.Repos.Machines
+	machine := newMachine(t, env, env.OrgA, 0, "SN-PLAN")
+	bear := newProduct(t, env, env.OrgA, "Мишка", "SKU-PLAN-BEAR")
+	bunny := newProduct(t, env, env.OrgA, "Зайчик", "SKU-PLAN-BUNNY")
+	foreign := newProduct(t, env, env.OrgB, "Чужой", "SKU-PLAN-FOREIGN")
+
+	must(t, repo.SetPlanogram(ctx, env.scopeA(), machine.ID, []models.PlanogramItem{
+		{ProductID: bear.ID, TargetCount: 40, PricePerPlay: money.FromRubles(100)},
+		{ProductID: bunny.ID, TargetCount: 60, PricePerPlay: money.FromRubles(50)},
+	}))
+
+	t.Run("Get", func(t *testing.T) {
+		items, err := repo.Planogram(ctx, env.scopeA(), machine.ID)
+		must(t, err)
+		if len(items) != 2 {
+			t.Fatalf("got %d planogram items, want 2", len(items))
+		}
+		// Товары упорядочены по названию
+		equal(t, "items[0].ProductName", items[0].ProductName, bunny.Name)
+		equal(t, "items[0].SKU", items[0].SKU, bunny.SKU)
+		equal(t, "items[0].TargetCount", items[0].TargetCount, 60)
+		equal(t, "items[1].PricePerPlay", items[1].PricePerPlay, money.FromRubles(100))
+		equal(t, "items[1].MachineID", items[1].MachineID, machine.ID)
+	})
+
+	t.Run("Replace", func(t *testing.T) {
+		must(t, repo.SetPlanogram(ctx, env.scopeA(), machine.ID, []models.PlanogramItem{
+			{ProductID: bear.ID, TargetCount: 80, PricePerPlay: money.FromRubles(120)},
+		}))
+		items, err := repo.Planogram(ctx, env.scopeA(), machine.ID)
+		must(t, err)
+		if len(items) != 1 {
+			t.Fatalf("got %d planogram items, want 1", len(items))
+		}
+		equal(t, "TargetCount", items[0].TargetCount, 80)
+		equal(t, "PricePerPlay", items[0].PricePerPlay, money.FromRubles(120))
+	})
+
+	t.Run("Scope", func(t *testing.T) {
+		wantErr(t, repo.SetPlanogram(ctx, env.scopeB(), machine.ID, nil), repository.ErrNotFound)
+		items, err := repo.Planogram(ctx, env.scopeB(), machine.ID)
+		must(t, err)
+		equal(t, "items in org B", len(items), 0)
+
+		// Товар другой организации не попадает в выкладку, прежняя сохраняется
+		wantErr(t, repo.SetPlanogram(ctx, env.scopeA(), machine.ID, []models.PlanogramItem{
+			{ProductID: foreign.ID, TargetCount: 10},
+		}), repository.ErrNotFound)
+		items, err = repo.Planogram(ctx, env.scopeA(), machine.ID)
+		must(t, err)
+		equal(t, "items after failed replace", len(items), 1)
+	})
+
+	t.Run("ProductInUse", func(t *testing.T) {
+		wantErr(t, env.Repos.Products.Delete(ctx, env.scopeA(), bear.ID), repository.ErrInUse)
+	})
+
+	t.Run("DeletedWithMachine", func(t *testing.T) {
+		must(t, repo.Delete(ctx, env.scopeA(), machine.ID))
+		items, err := repo.Planogram(ctx, env.scopeA(), machine.ID)
+		must(t, err)
+		equal(t, "items after delete", len(items), 0)
+		must(t, env.Repos.Products.Delete(ctx, env.scopeA(), bear.ID))
+	})
+}
+
+// newRestock записывает пополнение автомата по товарам.
+func newRestock(t *testing.T, env Env, machine models.VendingMachine, performer int64, at time.Time, items ...models.OperationItem) models.VendingOperation {
+	t.Helper()
+	operation := models.VendingOperation{
+		VendingMachineID: machine.ID,
+		OperationType:    "restock",
+		PerformedBy:      performer,
+		OperationDate:    at,
+		OrgID:            machine.OrgID,
+		Items:            items,
+	}
+	for _, item := range items {
+		operation.ToysBefore += item.CountBefore
+		operation.ToysAdded += item.Added
+	}
+	operation.ToysAfter = operation.ToysBefore + operation.ToysAdded
+	must(t, env.Repos.Operations.Create(context.Background(), &operation))
+	return operation
+}
+
+func testSales(t *testing.T, env Env) {
+	ctx := context.Background()
+	repo := env.Repos.Operations
+	mall := newLocation(t, env, env.OrgA, "ТЦ Галерея", true)
+	station := newLocation(t, env, env.OrgA, "Вокзал", true)
+	first := newMachine(t, env, env.OrgA, mall.ID, "SN-SALES-1")
+	second := newMachine(t, env, env.OrgA, mall.ID, "SN-SALES-2")
+	third := newMachine(t, env, env.OrgA, station.ID, "SN-SALES-3")
+	performer := newUser(t, env, env.OrgA, "loader", models.UserStatusActive)
+	bear := newProduct(t, env, env.OrgA, "Мишка", "SKU-SALES-BEAR")
+	bunny := newProduct(t, env, env.OrgA, "Зайчик", "SKU-SALES-BUNNY")
+	fox := newProduct(t, env, env.OrgA, "Лиса", "SKU-SALES-FOX")
+	rub := money.FromRubles
+
+	day := func(d int) time.Time { return time.Date(2024, 6, d, 10, 0, 0, 0, time.UTC) }
+	restock := newRestock(t, env, first, performer.ID, day(1),
+		models.OperationItem{ProductID: bear.ID, CountBefore: 0, Added: 20, PricePerPlay: rub(100)},
+		models.OperationItem{ProductID: bunny.ID, CountBefore: 5, Added: 15, PricePerPlay: rub(50)})
+	// 20-8=12 мишек и 20-14=6 зайчиков; лиса появилась только сейчас
+	newRestock(t, env, first, performer.ID, day(10),
+		models.OperationItem{ProductID: bear.ID, CountBefore: 8, Added: 12, PricePerPlay: rub(120)},
+		models.OperationItem{ProductID: bunny.ID, CountBefore: 14, Added: 6, PricePerPlay: rub(50)},
+		models.OperationItem{ProductID: fox.ID, CountBefore: 0, Added: 10, PricePerPlay: rub(80)})
+	// Мишки по цене прошлого пополнения: 20-15=5 по 120 ₽
+	newRestock(t, env, first, performer.ID, day(20),
+		models.OperationItem{ProductID: bear.ID, CountBefore: 15, Added: 5, PricePerPlay: rub(120)})
+	// Инкассация между пополнениями не мешает
+	newOperation(t, env, second, performer.ID, day(2))
+	newRestock(t, env, second, performer.ID, day(3),
+		models.OperationItem{ProductID: bunny.ID, CountBefore: 0, Added: 30, PricePerPlay: rub(60)})
+	newRestock(t, env, second, performer.ID, day(12),
+		models.OperationItem{ProductID: bunny.ID, CountBefore: 10, Added: 20, PricePerPlay: rub(60)})
+	newRestock(t, env, third, performer.ID, day(4),
+		models.OperationItem{ProductID: fox.ID, CountBefore: 0, Added: 10, PricePerPlay: rub(90)})
+	newRestock(t, env, third, performer.ID, day(14),
+		models.OperationItem{ProductID: fox.ID, CountBefore: 7, Added: 3, PricePerPlay: rub(90)})
+
+	t.Run("Items", func(t *testing.T) {
+		got, err := repo.Get(ctx, env.scopeA(), restock.ID)
+		must(t, err)
+		if len(got.Items) != 2 {
+			t.Fatalf("got %d items, want 2", len(got.Items))
+		}
+		equal(t, "Items[0].ProductName", got.Items[0].ProductName, bunny.Name)
+		equal(t, "Items[0].CountAfter", got.Items[0].CountAfter(), 20)
+		equal(t, "Items[1].PricePerPlay", got.Items[1].PricePerPlay, rub(100))
+		equal(t, "Items[1].OperationID", got.Items[1].OperationID, restock.ID)
+
+		list, err := repo.List(ctx, env.scopeA())
+		must(t, err)
+		for _, op := range list {
+			equal(t, "List items", len(op.Items), 0)
+		}
+	})
+
+	t.Run("Sales", func(t *testing.T) {
+		sales, err := repo.Sales(ctx, env.scopeA(), day(1), day(30))
+		must(t, err)
+		type row struct {
+			location, product string
+			sold              int
+			playValue         money.Amount
+		}
+		want := []row{
+			{station.Name, fox.Name, 3, rub(270)},
+			{mall.Name, bunny.Name, 26, rub(50*6 + 60*20)},
+			{mall.Name, bear.Name, 17, rub(100*12 + 120*5)},
+		}
+		if len(sales) != len(want) {
+			t.Fatalf("got %d sales rows, want %d: %+v", len(sales), len(want), sales)
+		}
+		for i, w := range want {
+			got := sales[i]
+			equal(t, "LocationName", got.LocationName, w.location)
+			equal(t, "ProductName", got.ProductName, w.product)
+			equal(t, "Sold", got.Sold, w.sold)
+			equal(t, "PlayValue", got.PlayValue, w.playValue)
+		}
+		equal(t, "LocationID", sales[0].LocationID, station.ID)
+		equal(t, "SKU", sales[0].SKU, fox.SKU)
+	})
+
+	t.Run("Period", func(t *testing.T) {
+		// Продажи относятся к дате пополнения, которое их обнаружило
+		sales, err := repo.Sales(ctx, env.scopeA(), day(11), day(15))
+		must(t, err)
+		if len(sales) != 2 {
+			t.Fatalf("got %d sales rows, want 2: %+v", len(sales), sales)
+		}
+		equal(t, "station fox", sales[0].Sold, 3)
+		equal(t, "mall bunny", sales[1].Sold, 20)
+	})
+
+	t.Run("Scope", func(t *testing.T) {
+		sales, err := repo.Sales(ctx, env.scopeB(), day(1), day(30))
+		must(t, err)
+		equal(t, "rows in org B", len(sales), 0)
+	})
+
+	t.Run("ForeignProduct", func(t *testing.T) {
+		foreign := newProduct(t, env, env.OrgB, "Чужой", "SKU-SALES-FOREIGN")
+		op := models.VendingOperation{
+			VendingMachineID: third.ID, OperationType: "restock", PerformedBy: performer.ID,
+			OperationDate: day(25), OrgID: env.OrgA,
+			Items: []models.OperationItem{{ProductID: foreign.ID, Added: 1}},
+		}
+		wantErr(t, repo.Create(ctx, &op), repository.ErrNotFound)
+	})
+}
@@ -0,0 +1,172 @@
+package repotest
+
+import (
+	"context"
+	"testing"
+
+	"vend_erp/internal/models"
+	"vend_erp/internal/money"
+	"vend_erp/internal/repository"
+)
+
+// newProduct добавляет товар в справочник организации; артикул
+// дополняется суффиксом окружения.
+func newProduct(t *testing.T, env Env, orgID int64, name, sku string) models.Product {
+	t.Helper()
+	product := models.Product{
+		OrgID:        orgID,
+		SKU:          env.unique(sku),
+		Name:         name,
+		ItemType:     "toy",
+		CategoryID:   env.CategoryID,
+		Description:  "Мягкая игрушка",
+		DefaultCost:  money.FromKopecks(8550),
+		DefaultPrice: money.FromRubles(150),
+		SupplierName: "ООО Игрушки",
+		IsActive:     true,
+	}
+	must(t, env.Repos.Products.Create(context.Background(), &product))
+	if product.ID == 0 {
+		t.Fatal("Products.Create did not set ID")
+	}
+	return product
+}
+
+// mustProduct читает товар по ID в сводном режиме.
+func (env Env) mustProduct(t *testing.T, id int64) models.Product {
+	t.Helper()
+	product, err := env.Repos.Products.Get(context.Background(), allOrgs, id)
+	must(t, err)
+	return product
+}
+
+func productID(p models.Product) int64 { return p.ID }
+
+func testProducts(t *testing.T, env Env) {
+	ctx := context.Background()
+	repo := env.Repos.Products
+	product := newProduct(t, env, env.OrgA, "Зайчик", "SKU-BUNNY")
+
+	t.Run("Get", func(t *testing.T) {
+		got, err := repo.Get(ctx, env.scopeA(), product.ID)
+		must(t, err)
+		equal(t, "SKU", got.SKU, product.SKU)
+		equal(t, "Name", got.Name, product.Name)
+		equal(t, "ItemType", got.ItemType, "toy")
+		equal(t, "DefaultCost", got.DefaultCost, product.DefaultCost)
+		equal(t, "DefaultPrice", got.DefaultPrice, product.DefaultPrice)
+		equal(t, "SupplierName", got.SupplierName, product.SupplierName)
+		equal(t, "Barcode", got.Barcode, "")
+		equal(t, "CategoryName", got.CategoryName != "", true)
+		equal(t, "OrgName", got.OrgName != "", true)
+		equal(t, "Version", got.Version, 1)
+	})
+
+	t.Run("Scope", func(t *testing.T) {
+		_, err := repo.Get(ctx, env.scopeB(), product.ID)
+		wantErr(t, err, repository.ErrNotFound)
+
+		listB, err := repo.List(ctx, env.scopeB())
+		must(t, err)
+		equal(t, "in org B list", contains(ids(listB, productID), product.ID), false)
+		all, err := repo.List(ctx, allOrgs)
+		must(t, err)
+		equal(t, "in all orgs list", contains(ids(all, productID), product.ID), true)
+	})
+
+	t.Run("ListActive", func(t *testing.T) {
+		first := newProduct(t, env, env.OrgA, "Ёжик", "SKU-FIRST")
+		closed := newProduct(t, env, env.OrgA, "Архивный", "SKU-CLOSED")
+		closed.IsActive = false
+		must(t, repo.Update(ctx, env.scopeA(), closed))
+		other := newProduct(t, env, env.OrgB, "Чужой", "SKU-OTHER")
+
+		active, err := repo.ListActive(ctx, env.OrgA)
+		must(t, err)
+		got := ids(active, productID)
+		equal(t, "inactive excluded", contains(got, closed.ID), false)
+		equal(t, "other org excluded", contains(got, other.ID), false)
+		// Сортировка по названию: «Ёжик» (Ё) раньше «Зайчика»
+		equal(t, "ordered by name", inOrder(got, first.ID, product.ID), true)
+	})
+
+	t.Run("Duplicate", func(t *testing.T) {
+		duplicate := product
+		duplicate.ID = 0
+		wantErr(t, repo.Create(ctx, &duplicate), repository.ErrDuplicate)
+
+		// Артикул уникален только в пределах организации
+		duplicate.OrgID = env.OrgB
+		must(t, repo.Create(ctx, &duplicate))
+
+		coded := newProduct(t, env, env.OrgA, "Со штрихкодом", "SKU-CODED")
+		coded.Barcode = env.unique("4600000000001")
+		must(t, repo.Update(ctx, env.scopeA(), coded))
+		coded.Version++
+
+		same := newProduct(t, env, env.OrgA, "Тот же штрихкод", "SKU-SAME")
+		same.Barcode = coded.Barcode
+		wantErr(t, repo.Update(ctx, env.scopeA(), same), repository.ErrDuplicate)
+		same.Barcode = ""
+		same.SKU = coded.SKU
+		wantErr(t, repo.Update(ctx, env.scopeA(), same), repository.ErrDuplicate)
+
+		// Пустой штрихкод не занимает значение
+		newProduct(t, env, env.OrgA, "Без штрихкода", "SKU-NOCODE")
+	})
+
+	t.Run("Update", func(t *testing.T) {
+		current, err := repo.Get(ctx, env.scopeA(), product.ID)
+		must(t, err)
+		changed := current
+		changed.Name = "Зайчик большой"
+		changed.DefaultPrice = money.FromRubles(200)
+		changed.PhotoURL = "https://example.com/bunny.jpg"
+
+		wantErr(t, repo.Update(ctx, env.scopeB(), changed), repository.ErrNotFound)
+		must(t, repo.Update(ctx, env.scopeA(), changed))
+
+		got, err := repo.Get(ctx, env.scopeA(), product.ID)
+		must(t, err)
+		equal(t, "Name", got.Name, changed.Name)
+		equal(t, "DefaultPrice", got.DefaultPrice, changed.DefaultPrice)
+		equal(t, "PhotoURL", got.PhotoURL, changed.PhotoURL)
+		equal(t, "OrgID", got.OrgID, env.OrgA)
+		equal(t, "Version", got.Version, current.Version+1)
+
+		wantErr(t, repo.Update(ctx, env.scopeA(), changed), repository.ErrConflict)
+	})
+
+	t.Run("Stock", func(t *testing.T) {
+		main := newWarehouse(t, env, env.OrgA, "Основной", true)
+		spare := newWarehouse(t, env, env.OrgA, "Запасной", true)
+		current := env.mustProduct(t, product.ID)
+		item := newItem(t, env, main.ID, current, 7)
+		newItem(t, env, spare.ID, current, 5)
+
+		equal(t, "Stock", env.mustProduct(t, product.ID).Stock, 12)
+
+		// Позиция показывает поля товара из справочника
+		got, err := env.Repos.Inventory.Item(ctx, env.scopeA(), item.ID)
+		must(t, err)
+		equal(t, "ItemName", got.ItemName, current.Name)
+		equal(t, "UnitPrice", got.UnitPrice, current.DefaultCost)
+		equal(t, "SKU", got.SKU, current.SKU)
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		wantErr(t, repo.Delete(ctx, env.scopeA(), product.ID), repository.ErrInUse)
+		_, err := repo.Get(ctx, env.scopeA(), product.ID)
+		must(t, err)
+
+		unused := newProduct(t, env, env.OrgA, "Без остатков", "SKU-UNUSED")
+		must(t, repo.Delete(ctx, env.scopeB(), unused.ID))
+		_, err = repo.Get(ctx, env.scopeA(), unused.ID)
+		must(t, err)
+
+		must(t, repo.Delete(ctx, env.scopeA(), unused.ID))
+		_, err = repo.Get(ctx, env.scopeA(), unused.ID)
+		wantErr(t, err, repository.ErrNotFound)
+		must(t, repo.Delete(ctx, env.scopeA(), unused.ID))
+	})
+}
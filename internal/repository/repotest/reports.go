@@ -0,0 +1,161 @@
+package repotest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"vend_erp/internal/models"
+	"vend_erp/internal/money"
+	"vend_erp/internal/repository"
+)
+
+func testReports(t *testing.T, env Env) {
+	ctx := context.Background()
+	repo := env.Repos.Reports
+	today := time.Now()
+	yesterday := today.AddDate(0, 0, -1)
+	performer := newUser(t, env, env.OrgA, "reporter", models.UserStatusActive)
+
+	location := newLocation(t, env, env.OrgA, "Отчетная", true)
+	active := newMachine(t, env, env.OrgA, location.ID, "report-active")
+	idle := newMachine(t, env, env.OrgA, location.ID, "report-idle")
+	idle.Status = "inactive"
+	must(t, env.Repos.Machines.Update(ctx, env.scopeA(), idle))
+	otherLocation := newLocation(t, env, env.OrgB, "Чужая", true)
+	newMachine(t, env, env.OrgB, otherLocation.ID, "report-other")
+
+	newOperation(t, env, active, performer.ID, today)
+	newOperation(t, env, idle, performer.ID, today)
+	collection := models.VendingOperation{
+		VendingMachineID: active.ID,
+		OperationType:    "collection",
+		PerformedBy:      performer.ID,
+		OperationDate:    today,
+		CashBefore:       money.FromRubles(700),
+		CashCollected:    money.FromRubles(700),
+		OrgID:            env.OrgA,
+	}
+	must(t, env.Repos.Operations.Create(ctx, &collection))
+
+	warehouse := newWarehouse(t, env, env.OrgA, "Отчетный склад", true)
+	closed := newWarehouse(t, env, env.OrgA, "Закрытый склад", false)
+	bear := newProduct(t, env, env.OrgA, "Мишка", "REP-BEAR")
+	bunny := newProduct(t, env, env.OrgA, "Зайка", "REP-BUNNY")
+	capsule := newProduct(t, env, env.OrgA, "Капсула", "REP-CAPSULE")
+	capsule.ItemType = "capsule"
+	must(t, env.Repos.Products.Update(ctx, env.scopeA(), capsule))
+	// Минимальный уровень 10: нехватка 8, 5 и пустая позиция
+	short := newItem(t, env, warehouse.ID, bear, 2)
+	low := newItem(t, env, warehouse.ID, bunny, 5)
+	empty := newItem(t, env, warehouse.ID, capsule, 0)
+	hidden := newItem(t, env, closed.ID, bear, 1)
+
+	t.Run("Machines", func(t *testing.T) {
+		machines, err := env.Repos.Machines.List(ctx, env.scopeA())
+		must(t, err)
+		var cash money.Amount
+		var toys int
+		for _, m := range machines {
+			cash += m.CashAmount
+			toys += m.CurrentToysCount
+		}
+
+		totals, err := repo.Machines(ctx, env.scopeA())
+		must(t, err)
+		equal(t, "Count", totals.Count, 2)
+		equal(t, "Active", totals.Active, 1)
+		equal(t, "Cash", totals.Cash, cash)
+		equal(t, "Toys", totals.Toys, toys)
+
+		counts, err := repo.OperationCounts(ctx, env.scopeA())
+		must(t, err)
+		equal(t, "restock", counts["restock"], 2)
+		equal(t, "collection", counts["collection"], 1)
+
+		counts, err = repo.OperationCounts(ctx, env.scopeB())
+		must(t, err)
+		equal(t, "operations of B", len(counts), 0)
+	})
+
+	t.Run("Warehouses", func(t *testing.T) {
+		totals, err := repo.Warehouses(ctx, env.scopeA())
+		must(t, err)
+		equal(t, "Value", totals.Value, stockValue(t, env, short.ID)+stockValue(t, env, low.ID))
+		equal(t, "LowStock", totals.LowStock, 2)
+		equal(t, "OutOfStock", totals.OutOfStock, 1)
+		equal(t, "Warehouses", totals.Warehouses, 1)
+
+		types, err := repo.StockByType(ctx, env.scopeA())
+		must(t, err)
+		want := []repository.TypeCount{{ItemType: "toy", Count: 2}, {ItemType: "capsule", Count: 1}}
+		equal(t, "types", len(types), len(want))
+		for i := range want {
+			if i < len(types) {
+				equal(t, "type", types[i], want[i])
+			}
+		}
+
+		items, err := repo.LowStock(ctx, env.scopeA(), 10)
+		must(t, err)
+		got := ids(items, itemID)
+		if len(got) != 2 || got[0] != short.ID || got[1] != low.ID {
+			t.Errorf("LowStock = %v, want %v", got, []int64{short.ID, low.ID})
+		}
+		if contains(got, empty.ID) || contains(got, hidden.ID) {
+			t.Errorf("LowStock = %v, contains empty item or inactive warehouse", got)
+		}
+		if len(items) > 0 {
+			equal(t, "ItemName", items[0].ItemName, "Мишка")
+			equal(t, "WarehouseName", items[0].WarehouseName, "Отчетный склад")
+			equal(t, "MinStockLevel", items[0].MinStockLevel, 10)
+		}
+
+		items, err = repo.LowStock(ctx, env.scopeA(), 1)
+		must(t, err)
+		equal(t, "limited", len(items), 1)
+	})
+
+	t.Run("History", func(t *testing.T) {
+		totals, err := repo.Machines(ctx, env.scopeA())
+		must(t, err)
+		machines, err := repo.MachineHistory(ctx, env.scopeA(), yesterday, today)
+		must(t, err)
+		equal(t, "machine days", len(machines), 2)
+		if len(machines) == 2 {
+			sameDay(t, "first day", machines[0].Date, yesterday)
+			equal(t, "machines yesterday", machines[0].Machines, 0)
+			sameDay(t, "last day", machines[1].Date, today)
+			equal(t, "machines today", machines[1].Machines, 2)
+			equal(t, "active today", machines[1].Active, 1)
+			equal(t, "cash today", machines[1].Cash, totals.Cash)
+			equal(t, "toys today", machines[1].Toys, totals.Toys)
+		}
+
+		operations, err := repo.OperationHistory(ctx, env.scopeA(), yesterday, today)
+		must(t, err)
+		equal(t, "operation rows", len(operations), 2)
+		if len(operations) == 2 {
+			sameDay(t, "operation day", operations[0].Date, today)
+			equal(t, "first type", operations[0].OperationType, "collection")
+			equal(t, "restocks", operations[1].Count, 2)
+		}
+
+		revenue, err := repo.Revenue(ctx, env.scopeA(), yesterday, today)
+		must(t, err)
+		equal(t, "revenue days", len(revenue), 1)
+		if len(revenue) == 1 {
+			equal(t, "revenue", revenue[0].Amount, money.FromRubles(700))
+		}
+
+		values, err := repo.StockValue(ctx, env.scopeA(), yesterday, today)
+		must(t, err)
+		equal(t, "value days", len(values), 2)
+		if len(values) == 2 {
+			equal(t, "value yesterday", values[0].Amount, money.Amount(0))
+			// Запасы неактивного склада тоже входят в стоимость
+			equal(t, "value today", values[1].Amount,
+				stockValue(t, env, short.ID)+stockValue(t, env, low.ID)+stockValue(t, env, hidden.ID))
+		}
+	})
+}
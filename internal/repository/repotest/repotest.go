@@ -0,0 +1,221 @@
+// Package repotest — контрактные тесты хранилищ repository. Один и тот же
+// набор проверок запускается для каждой реализации, поэтому обработчики,
+// протестированные на memory, ведут себя так же на PostgreSQL. Набор
+// запускают TestMemoryRepos в пакете memory и TestPostgresRepos в пакете
+// postgres; последний — на базе из TEST_DATABASE_URL:
+//
+//	func TestMemoryRepos(t *testing.T) {
+//		repotest.Run(t, repotest.Memory)
+//	}
+//
+// Проверки для PostgreSQL создают собственные организации с уникальными
+// именами и удаляют свои данные по завершении.
+package repotest
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"vend_erp/internal/repository"
+	"vend_erp/internal/repository/memory"
+	"vend_erp/internal/repository/postgres"
+)
+
+// Env — хранилища под тестом и подготовленные для них данные.
+type Env struct {
+	Repos repository.Repos
+	// Две организации для проверки изоляции данных
+	OrgA, OrgB int64
+	// CategoryID — существующая категория склада
+	CategoryID int64
+	// Suffix делает уникальными серийные номера, email и артикулы,
+	// если база уже содержит данные
+	Suffix string
+	// SetCostingMethod меняет метод оценки запасов организации
+	SetCostingMethod func(t *testing.T, orgID int64, method string)
+	// SetSuperAdmin выдает пользователю права суперадмина
+	SetSuperAdmin func(t *testing.T, userID int64)
+}
+
+// Run выполняет все контрактные тесты; newEnv вызывается для каждого из них.
+func Run(t *testing.T, newEnv func(t *testing.T) Env) {
+	t.Run("Machines", func(t *testing.T) { testMachines(t, newEnv(t)) })
+	t.Run("Locations", func(t *testing.T) { testLocations(t, newEnv(t)) })
+	t.Run("Operations", func(t *testing.T) { testOperations(t, newEnv(t)) })
+	t.Run("Planogram", func(t *testing.T) { testPlanogram(t, newEnv(t)) })
+	t.Run("Sales", func(t *testing.T) { testSales(t, newEnv(t)) })
+	t.Run("Products", func(t *testing.T) { testProducts(t, newEnv(t)) })
+	t.Run("Inventory", func(t *testing.T) { testInventory(t, newEnv(t)) })
+	t.Run("Zones", func(t *testing.T) { testZones(t, newEnv(t)) })
+	t.Run("Supplies", func(t *testing.T) { testSupplies(t, newEnv(t)) })
+	t.Run("Stocktakes", func(t *testing.T) { testStocktakes(t, newEnv(t)) })
+	t.Run("Costing", func(t *testing.T) { testCosting(t, newEnv(t)) })
+	t.Run("Reservations", func(t *testing.T) { testReservations(t, newEnv(t)) })
+	t.Run("Users", func(t *testing.T) { testUsers(t, newEnv(t)) })
+	t.Run("Sessions", func(t *testing.T) { testSessions(t, newEnv(t)) })
+	t.Run("Organizations", func(t *testing.T) { testOrganizations(t, newEnv(t)) })
+	t.Run("Invites", func(t *testing.T) { testInvites(t, newEnv(t)) })
+	t.Run("Reports", func(t *testing.T) { testReports(t, newEnv(t)) })
+}
+
+// Memory готовит окружение на пустом memory.Store.
+func Memory(t *testing.T) Env {
+	store := memory.New()
+	return Env{
+		Repos:      store.Repos(),
+		OrgA:       store.AddOrganization("Организация A"),
+		OrgB:       store.AddOrganization("Организация B"),
+		CategoryID: store.AddCategory("Игрушки"),
+		Suffix:     "mem",
+		SetCostingMethod: func(t *testing.T, orgID int64, method string) {
+			store.SetCostingMethod(orgID, method)
+		},
+		SetSuperAdmin: func(t *testing.T, userID int64) {
+			store.SetSuperAdmin(userID, true)
+		},
+	}
+}
+
+// Postgres готовит окружение в базе db: создает две организации
+// и категорию, а после теста удаляет все, что к ним относится.
+func Postgres(db *sql.DB) func(t *testing.T) Env {
+	return func(t *testing.T) Env {
+		ctx := context.Background()
+		suffix := fmt.Sprintf("%x", time.Now().UnixNano())
+		env := Env{Repos: postgres.New(db), Suffix: suffix}
+		env.SetCostingMethod = func(t *testing.T, orgID int64, method string) {
+			t.Helper()
+			_, err := db.ExecContext(ctx, "UPDATE organizations SET costing_method = $1 WHERE id = $2", method, orgID)
+			must(t, err)
+		}
+		env.SetSuperAdmin = func(t *testing.T, userID int64) {
+			t.Helper()
+			_, err := db.ExecContext(ctx, "UPDATE users SET is_superadmin = true WHERE id = $1", userID)
+			must(t, err)
+		}
+
+		for _, org := range []struct {
+			id   *int64
+			name string
+		}{{&env.OrgA, "a"}, {&env.OrgB, "b"}} {
+			err := db.QueryRowContext(ctx, `
+                INSERT INTO organizations (name, slug) VALUES ($1, $2) RETURNING id
+            `, "Организация "+org.name, "repotest-"+org.name+"-"+suffix).Scan(org.id)
+			if err != nil {
+				t.Fatalf("creating organization: %v", err)
+			}
+		}
+		err := db.QueryRowContext(ctx, `
+            INSERT INTO warehouse_categories (name) VALUES ($1) RETURNING id
+        `, "repotest-"+suffix).Scan(&env.CategoryID)
+		if err != nil {
+			t.Fatalf("creating category: %v", err)
+		}
+
+		t.Cleanup(func() {
+			orgs := []interface{}{env.OrgA, env.OrgB}
+			for _, query := range []string{
+				"DELETE FROM vending_operations WHERE org_id IN ($1, $2)",
+				"DELETE FROM vending_machines WHERE org_id IN ($1, $2)",
+				"DELETE FROM locations WHERE org_id IN ($1, $2)",
+				"DELETE FROM warehouse WHERE org_id IN ($1, $2)",
+				"DELETE FROM products WHERE org_id IN ($1, $2)",
+				"DELETE FROM users WHERE org_id IN ($1, $2)",
+				"DELETE FROM organizations WHERE id IN ($1, $2)",
+			} {
+				if _, err := db.ExecContext(ctx, query, orgs...); err != nil {
+					t.Errorf("cleanup: %v", err)
+				}
+			}
+			db.ExecContext(ctx, "DELETE FROM warehouse_categories WHERE id = $1", env.CategoryID)
+			// Организации, созданные проверками Organizations
+			db.ExecContext(ctx, "DELETE FROM organizations WHERE slug LIKE $1", "repotest-%-"+suffix)
+		})
+		return env
+	}
+}
+
+func (env Env) scopeA() repository.Scope { return repository.Scope{OrgID: env.OrgA} }
+func (env Env) scopeB() repository.Scope { return repository.Scope{OrgID: env.OrgB} }
+
+// allOrgs — сводный режим суперадмина.
+var allOrgs = repository.Scope{AllOrgs: true, SuperAdmin: true}
+
+func (env Env) unique(prefix string) string {
+	return prefix + "-" + env.Suffix
+}
+
+func must(t *testing.T, err error) {
+	t.Helper()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func wantErr(t *testing.T, err, want error) {
+	t.Helper()
+	if !errors.Is(err, want) {
+		t.Fatalf("got error %v, want %v", err, want)
+	}
+}
+
+func equal[T comparable](t *testing.T, field string, got, want T) {
+	t.Helper()
+	if got != want {
+		t.Errorf("%s = %v, want %v", field, got, want)
+	}
+}
+
+// date — дата без времени: столбцы DATE хранят только ее.
+func date(year int, month time.Month, day int) time.Time {
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
+
+func sameDay(t *testing.T, field string, got, want time.Time) {
+	t.Helper()
+	equal(t, field, got.Format(time.DateOnly), want.Format(time.DateOnly))
+}
+
+// ids возвращает ID элементов списка в порядке следования.
+func ids[T any](list []T, id func(T) int64) []int64 {
+	result := make([]int64, len(list))
+	for i, item := range list {
+		result[i] = id(item)
+	}
+	return result
+}
+
+func contains(list []int64, id int64) bool {
+	for _, v := range list {
+		if v == id {
+			return true
+		}
+	}
+	return false
+}
+
+// inOrder проверяет, что все want есть в list и идут в этом порядке.
+func inOrder(list []int64, want ...int64) bool {
+	prev := -1
+	for _, id := range want {
+		i := indexOf(list, id)
+		if i <= prev {
+			return false
+		}
+		prev = i
+	}
+	return true
+}
+
+func indexOf(list []int64, id int64) int {
+	for i, v := range list {
+		if v == id {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,247 @@
+package repotest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"vend_erp/internal/models"
+	"vend_erp/internal/repository"
+)
+
+// reserved возвращает остаток и зарезервированное количество позиции.
+func reserved(t *testing.T, env Env, id int64) (quantity, reserved int) {
+	t.Helper()
+	item, err := env.Repos.Inventory.Item(context.Background(), allOrgs, id)
+	must(t, err)
+	return item.Quantity, item.Reserved
+}
+
+func testReservations(t *testing.T, env Env) {
+	ctx := context.Background()
+	repo := env.Repos.Inventory
+	main := newWarehouse(t, env, env.OrgA, "Основной", true)
+	spare := newWarehouse(t, env, env.OrgA, "Резервный", true)
+	location := newLocation(t, env, env.OrgA, "ТЦ Резерв", true)
+	machine := newMachine(t, env, env.OrgA, location.ID, "SN-RES-1")
+	other := newMachine(t, env, env.OrgA, location.ID, "SN-RES-2")
+	foreign := newMachine(t, env, env.OrgB, 0, "SN-RES-B")
+	operator := newUser(t, env, env.OrgA, "planner", models.UserStatusActive)
+	product := newProduct(t, env, env.OrgA, "Мишка", "SKU-RES")
+	item := newItem(t, env, main.ID, product, 50)
+	soon := time.Now().Add(24 * time.Hour)
+	later := time.Now().Add(72 * time.Hour)
+
+	shipment := models.StockReservation{
+		ItemID: item.ID, Type: repository.MovementShipment, Quantity: 20,
+		Reason: "Заказ партнера", ExpiresAt: later, CreatedBy: operator.ID,
+	}
+	must(t, repo.Reserve(ctx, env.scopeA(), &shipment))
+	if shipment.ID == 0 {
+		t.Fatal("Inventory.Reserve did not set ID")
+	}
+	equal(t, "Status", shipment.Status, repository.ReservationActive)
+	restock := models.StockReservation{
+		ItemID: item.ID, Type: repository.MovementRestock, Quantity: 10,
+		VendingMachineID: machine.ID, ExpiresAt: soon,
+	}
+	must(t, repo.Reserve(ctx, env.scopeA(), &restock))
+
+	t.Run("Available", func(t *testing.T) {
+		quantity, res := reserved(t, env, item.ID)
+		equal(t, "Quantity", quantity, 50)
+		equal(t, "Reserved", res, 30)
+		items, err := repo.Items(ctx, env.scopeA(), repository.InventoryFilter{WarehouseID: main.ID})
+		must(t, err)
+		equal(t, "Items Available", items[0].Available(), 20)
+
+		list, err := repo.Reservations(ctx, env.scopeA(), item.ID)
+		must(t, err)
+		if len(list) != 2 {
+			t.Fatalf("got %d reservations, want 2", len(list))
+		}
+		// Ближайшие к сроку первыми
+		equal(t, "list[0].ID", list[0].ID, restock.ID)
+		equal(t, "list[0].MachineSerial", list[0].MachineSerial, machine.SerialNumber)
+		equal(t, "list[1].Reason", list[1].Reason, "Заказ партнера")
+		equal(t, "list[1].CreatedByName", list[1].CreatedByName, operator.Username)
+		equal(t, "list[1].Quantity", list[1].Quantity, 20)
+	})
+
+	t.Run("Insufficient", func(t *testing.T) {
+		// Второй оператор не отложит и не отгрузит уже зарезервированное
+		wantErr(t, repo.Reserve(ctx, env.scopeA(), &models.StockReservation{
+			ItemID: item.ID, Type: repository.MovementShipment, Quantity: 21, ExpiresAt: later,
+		}), repository.ErrInsufficientStock)
+		_, err := repo.Move(ctx, env.scopeA(), item.ID, repository.StockChange{
+			Type: repository.MovementShipment, Quantity: 21,
+		})
+		wantErr(t, err, repository.ErrInsufficientStock)
+		wantErr(t, repo.Transfer(ctx, env.scopeA(), item.ID, spare.ID, 21, ""), repository.ErrInsufficientStock)
+		quantity, _ := reserved(t, env, item.ID)
+		equal(t, "Quantity", quantity, 50)
+	})
+
+	t.Run("Scope", func(t *testing.T) {
+		wantErr(t, repo.Reserve(ctx, env.scopeB(), &models.StockReservation{
+			ItemID: item.ID, Type: repository.MovementShipment, Quantity: 1, ExpiresAt: later,
+		}), repository.ErrNotFound)
+		wantErr(t, repo.Reserve(ctx, env.scopeA(), &models.StockReservation{
+			ItemID: item.ID, Type: repository.MovementRestock, Quantity: 1,
+			VendingMachineID: foreign.ID, ExpiresAt: later,
+		}), repository.ErrNotFound)
+		list, err := repo.Reservations(ctx, env.scopeB(), item.ID)
+		must(t, err)
+		equal(t, "reservations in org B", len(list), 0)
+		must(t, repo.Release(ctx, env.scopeB(), shipment.ID))
+		_, res := reserved(t, env, item.ID)
+		equal(t, "Reserved after foreign release", res, 30)
+	})
+
+	t.Run("Consume", func(t *testing.T) {
+		// Резерв другого типа или под другой автомат не подходит
+		_, err := repo.Move(ctx, env.scopeA(), item.ID, repository.StockChange{
+			Type: repository.MovementShipment, Quantity: 5, ReservationID: restock.ID,
+		})
+		wantErr(t, err, repository.ErrNotFound)
+		_, err = warehouseRestock(env, other, operator.ID, main.ID, product.ID, 21)
+		wantErr(t, err, repository.ErrInsufficientStock)
+
+		// Отгрузка по резерву берет отложенное под него, резерв
+		// расходуется целиком
+		balance, err := repo.Move(ctx, env.scopeA(), item.ID, repository.StockChange{
+			Type: repository.MovementShipment, Quantity: 15, ReservationID: shipment.ID,
+		})
+		must(t, err)
+		equal(t, "balance", balance, 35)
+		_, res := reserved(t, env, item.ID)
+		equal(t, "Reserved", res, 10)
+		_, err = repo.Move(ctx, env.scopeA(), item.ID, repository.StockChange{
+			Type: repository.MovementShipment, Quantity: 1, ReservationID: shipment.ID,
+		})
+		wantErr(t, err, repository.ErrNotFound)
+
+		// Пополнение автомата больше отложенного под него берет
+		// и доступное, резерв автомата расходуется
+		_, err = warehouseRestock(env, machine, operator.ID, main.ID, product.ID, 12)
+		must(t, err)
+		quantity, res := reserved(t, env, item.ID)
+		equal(t, "balance after restock", quantity, 23)
+		equal(t, "Reserved after restock", res, 0)
+	})
+
+	t.Run("Run", func(t *testing.T) {
+		// Резерв рейса без автомата пополнение берет после доступного
+		// и уменьшает на взятое
+		run := models.StockReservation{
+			ItemID: item.ID, Type: repository.MovementRestock, Quantity: 15, ExpiresAt: later,
+		}
+		must(t, repo.Reserve(ctx, env.scopeA(), &run))
+		_, err := warehouseRestock(env, other, operator.ID, main.ID, product.ID, 24)
+		wantErr(t, err, repository.ErrInsufficientStock)
+		op, err := warehouseRestock(env, other, operator.ID, main.ID, product.ID, 10)
+		must(t, err)
+		quantity, res := reserved(t, env, item.ID)
+		equal(t, "balance after run restock", quantity, 13)
+		equal(t, "Reserved after run restock", res, 13)
+
+		// Отгрузка рейсовый резерв не трогает
+		_, err = repo.Move(ctx, env.scopeA(), item.ID, repository.StockChange{
+			Type: repository.MovementShipment, Quantity: 1,
+		})
+		wantErr(t, err, repository.ErrInsufficientStock)
+		must(t, repo.Release(ctx, env.scopeA(), run.ID))
+
+		// Удаленная операция возвращает выданное на склад
+		must(t, env.Repos.Operations.Delete(ctx, env.scopeA(), op.ID))
+		quantity, _ = reserved(t, env, item.ID)
+		equal(t, "balance after delete", quantity, 23)
+	})
+
+	t.Run("Release", func(t *testing.T) {
+		res := models.StockReservation{
+			ItemID: item.ID, Type: repository.MovementShipment, Quantity: 5, ExpiresAt: later,
+		}
+		must(t, repo.Reserve(ctx, env.scopeA(), &res))
+		_, held := reserved(t, env, item.ID)
+		equal(t, "Reserved", held, 5)
+		must(t, repo.Release(ctx, env.scopeA(), res.ID))
+		must(t, repo.Release(ctx, env.scopeA(), res.ID))
+		_, held = reserved(t, env, item.ID)
+		equal(t, "Reserved after release", held, 0)
+		_, err := repo.Move(ctx, env.scopeA(), item.ID, repository.StockChange{
+			Type: repository.MovementShipment, Quantity: 1, ReservationID: res.ID,
+		})
+		wantErr(t, err, repository.ErrNotFound)
+	})
+
+	t.Run("Adjust", func(t *testing.T) {
+		res := models.StockReservation{
+			ItemID: item.ID, Type: repository.MovementShipment, Quantity: 20, ExpiresAt: later,
+		}
+		must(t, repo.Reserve(ctx, env.scopeA(), &res))
+		// Корректировка не списывает отложенное
+		_, err := repo.Adjust(ctx, env.scopeA(), item.ID, repository.AdjustSubtract, 4, "")
+		wantErr(t, err, repository.ErrInsufficientStock)
+		_, err = repo.Adjust(ctx, env.scopeA(), item.ID, repository.AdjustSet, 19, "")
+		wantErr(t, err, repository.ErrInsufficientStock)
+		balance, err := repo.Adjust(ctx, env.scopeA(), item.ID, repository.AdjustSubtract, 3, "")
+		must(t, err)
+		equal(t, "balance", balance, 20)
+		balance, err = repo.Adjust(ctx, env.scopeA(), item.ID, repository.AdjustSet, 25, "")
+		must(t, err)
+		equal(t, "balance after set", balance, 25)
+		must(t, repo.Release(ctx, env.scopeA(), res.ID))
+	})
+
+	t.Run("UpdateItem", func(t *testing.T) {
+		res := models.StockReservation{
+			ItemID: item.ID, Type: repository.MovementShipment, Quantity: 20, ExpiresAt: later,
+		}
+		must(t, repo.Reserve(ctx, env.scopeA(), &res))
+		card, err := repo.Item(ctx, env.scopeA(), item.ID)
+		must(t, err)
+		equal(t, "Quantity", card.Quantity, 25)
+
+		// Правка карточки не списывает отложенное и не увозит его
+		lowered := card
+		lowered.Quantity = 19
+		wantErr(t, repo.UpdateItem(ctx, env.scopeA(), lowered), repository.ErrInsufficientStock)
+		moved := card
+		moved.WarehouseID = spare.ID
+		wantErr(t, repo.UpdateItem(ctx, env.scopeA(), moved), repository.ErrInUse)
+		quantity, held := reserved(t, env, item.ID)
+		equal(t, "Quantity after rejected edits", quantity, 25)
+		equal(t, "Reserved after rejected edits", held, 20)
+
+		lowered.Quantity = 20
+		must(t, repo.UpdateItem(ctx, env.scopeA(), lowered))
+		quantity, _ = reserved(t, env, item.ID)
+		equal(t, "Quantity after edit", quantity, 20)
+		must(t, repo.Release(ctx, env.scopeA(), res.ID))
+	})
+
+	t.Run("Expired", func(t *testing.T) {
+		expired := models.StockReservation{
+			ItemID: item.ID, Type: repository.MovementShipment, Quantity: 20,
+			ExpiresAt: time.Now().Add(-time.Hour),
+		}
+		must(t, repo.Reserve(ctx, env.scopeA(), &expired))
+		_, held := reserved(t, env, item.ID)
+		equal(t, "Reserved with expired", held, 0)
+		list, err := repo.Reservations(ctx, env.scopeA(), item.ID)
+		must(t, err)
+		equal(t, "expired listed", len(list), 0)
+	})
+
+	t.Run("ItemInUse", func(t *testing.T) {
+		res := models.StockReservation{
+			ItemID: item.ID, Type: repository.MovementShipment, Quantity: 3, ExpiresAt: later,
+		}
+		must(t, repo.Reserve(ctx, env.scopeA(), &res))
+		wantErr(t, repo.DeleteItem(ctx, env.scopeA(), item.ID), repository.ErrInUse)
+		list, err := repo.Reservations(ctx, env.scopeA(), item.ID)
+		must(t, err)
+		equal(t, "reservations after delete", len(list), 1)
+	})
+}
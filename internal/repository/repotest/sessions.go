@@ -0,0 +1,91 @@
+package repotest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"vend_erp/internal/models"
+	"vend_erp/internal/repository"
+)
+
+func testSessions(t *testing.T, env Env) {
+	ctx := context.Background()
+	repo := env.Repos.Sessions
+	user := newUser(t, env, env.OrgA, "session", models.UserStatusActive)
+	other := newUser(t, env, env.OrgA, "session-other", models.UserStatusActive)
+
+	newSession := func(name string, userID int64, ttl time.Duration) models.Session {
+		t.Helper()
+		session := models.Session{
+			ID:        env.unique(name),
+			UserID:    userID,
+			ExpiresAt: time.Now().Add(ttl),
+		}
+		must(t, repo.Create(ctx, session))
+		return session
+	}
+	current := newSession("current", user.ID, time.Hour)
+
+	t.Run("Get", func(t *testing.T) {
+		got, err := repo.Get(ctx, current.ID)
+		must(t, err)
+		equal(t, "UserID", got.UserID, user.ID)
+		equal(t, "ActiveOrgID", got.ActiveOrgID, int64(0))
+		equal(t, "AllOrgs", got.AllOrgs, false)
+
+		_, err = repo.Get(ctx, env.unique("missing"))
+		wantErr(t, err, repository.ErrNotFound)
+		wantErr(t, repo.Create(ctx, current), repository.ErrDuplicate)
+	})
+
+	t.Run("Expired", func(t *testing.T) {
+		expired := newSession("expired", user.ID, -time.Hour)
+		_, err := repo.Get(ctx, expired.ID)
+		wantErr(t, err, repository.ErrNotFound)
+	})
+
+	t.Run("Organization", func(t *testing.T) {
+		must(t, repo.SetAllOrgs(ctx, current.ID, user.ID))
+		got, err := repo.Get(ctx, current.ID)
+		must(t, err)
+		equal(t, "AllOrgs", got.AllOrgs, true)
+
+		must(t, repo.SetActiveOrg(ctx, current.ID, user.ID, env.OrgB))
+		got, err = repo.Get(ctx, current.ID)
+		must(t, err)
+		equal(t, "ActiveOrgID", got.ActiveOrgID, env.OrgB)
+		equal(t, "AllOrgs", got.AllOrgs, false)
+
+		// Чужую сессию переключить нельзя
+		wantErr(t, repo.SetActiveOrg(ctx, current.ID, other.ID, env.OrgA), repository.ErrNotFound)
+		wantErr(t, repo.SetAllOrgs(ctx, current.ID, other.ID), repository.ErrNotFound)
+	})
+
+	t.Run("DeleteOthers", func(t *testing.T) {
+		second := newSession("second", user.ID, time.Hour)
+		foreign := newSession("foreign", other.ID, time.Hour)
+
+		must(t, repo.DeleteOthers(ctx, user.ID, current.ID))
+		_, err := repo.Get(ctx, second.ID)
+		wantErr(t, err, repository.ErrNotFound)
+		_, err = repo.Get(ctx, current.ID)
+		must(t, err)
+		_, err = repo.Get(ctx, foreign.ID)
+		must(t, err)
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		must(t, repo.Delete(ctx, current.ID))
+		_, err := repo.Get(ctx, current.ID)
+		wantErr(t, err, repository.ErrNotFound)
+		must(t, repo.Delete(ctx, current.ID))
+	})
+
+	t.Run("DeletedWithUser", func(t *testing.T) {
+		session := newSession("orphan", other.ID, time.Hour)
+		must(t, env.Repos.Users.Delete(ctx, env.scopeA(), other.ID))
+		_, err := repo.Get(ctx, session.ID)
+		wantErr(t, err, repository.ErrNotFound)
+	})
+}
@@ -0,0 +1,214 @@
+package repotest
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"vend_erp/internal/models"
+	"vend_erp/internal/money"
+	"vend_erp/internal/repository"
+)
+
+// stocktakeLine находит позицию инвентаризации по позиции склада.
+func stocktakeLine(t *testing.T, st models.Stocktake, itemID int64) models.StocktakeLine {
+	t.Helper()
+	for _, line := range st.Lines {
+		if line.ItemID == itemID {
+			return line
+		}
+	}
+	t.Fatalf("item %d is not in stocktake %d", itemID, st.ID)
+	return models.StocktakeLine{}
+}
+
+func stocktakeID(st models.Stocktake) int64 { return st.ID }
+
+func testStocktakes(t *testing.T, env Env) {
+	ctx := context.Background()
+	repo := env.Repos.Stocktakes
+	warehouse := newWarehouse(t, env, env.OrgA, "Основной", true)
+	counter := newUser(t, env, env.OrgA, "counter", models.UserStatusActive)
+
+	bear := newProduct(t, env, env.OrgA, "Мишка", "SKU-COUNT-BEAR")
+	bunny := newProduct(t, env, env.OrgA, "Зайчик", "SKU-COUNT-BUNNY")
+	bunny.Barcode = env.unique("4600000000017")
+	must(t, env.Repos.Products.Update(ctx, env.scopeA(), bunny))
+	fox := newProduct(t, env, env.OrgA, "Лиса", "SKU-COUNT-FOX")
+	bears := newItem(t, env, warehouse.ID, bear, 20)
+	bunnies := newItem(t, env, warehouse.ID, bunny, 5)
+	foxes := newItem(t, env, warehouse.ID, fox, 7)
+
+	stocktake := models.Stocktake{WarehouseID: warehouse.ID, Notes: "Квартальная", CreatedBy: counter.ID}
+	must(t, repo.Start(ctx, env.scopeA(), &stocktake))
+	if stocktake.ID == 0 {
+		t.Fatal("Stocktakes.Start did not set ID")
+	}
+
+	t.Run("Get", func(t *testing.T) {
+		got, err := repo.Get(ctx, env.scopeA(), stocktake.ID)
+		must(t, err)
+		equal(t, "Status", got.Status, repository.StocktakeCounting)
+		equal(t, "Notes", got.Notes, "Квартальная")
+		equal(t, "WarehouseName", got.WarehouseName, warehouse.Name)
+		equal(t, "OrgID", got.OrgID, env.OrgA)
+		equal(t, "CreatedByName", got.CreatedByName, counter.Username)
+		equal(t, "LineCount", got.LineCount, 3)
+		equal(t, "CountedCount", got.CountedCount, 0)
+		if len(got.Lines) != 3 {
+			t.Fatalf("got %d lines, want 3", len(got.Lines))
+		}
+		// Позиции упорядочены по названию товара
+		equal(t, "Lines[0].ItemName", got.Lines[0].ItemName, bunny.Name)
+		line := stocktakeLine(t, got, bears.ID)
+		equal(t, "ExpectedQuantity", line.ExpectedQuantity, 20)
+		equal(t, "UnitCost", line.UnitCost, bear.DefaultCost)
+		equal(t, "Counted", line.Counted, false)
+		equal(t, "SKU", line.SKU, bear.SKU)
+	})
+
+	t.Run("Duplicate", func(t *testing.T) {
+		again := models.Stocktake{WarehouseID: warehouse.ID}
+		wantErr(t, repo.Start(ctx, env.scopeA(), &again), repository.ErrDuplicate)
+	})
+
+	t.Run("Scope", func(t *testing.T) {
+		_, err := repo.Get(ctx, env.scopeB(), stocktake.ID)
+		wantErr(t, err, repository.ErrNotFound)
+		listB, err := repo.List(ctx, env.scopeB())
+		must(t, err)
+		equal(t, "in org B list", contains(ids(listB, stocktakeID), stocktake.ID), false)
+
+		other := models.Stocktake{WarehouseID: warehouse.ID}
+		wantErr(t, repo.Start(ctx, env.scopeB(), &other), repository.ErrNotFound)
+		wantErr(t, repo.Count(ctx, env.scopeB(), stocktake.ID, bears.ID, 1, 0), repository.ErrNotFound)
+		_, err = repo.Scan(ctx, env.scopeB(), stocktake.ID, bear.SKU, 1, 0)
+		wantErr(t, err, repository.ErrNotFound)
+		wantErr(t, repo.Approve(ctx, env.scopeB(), stocktake.ID, 0), repository.ErrNotFound)
+		wantErr(t, repo.Cancel(ctx, env.scopeB(), stocktake.ID), repository.ErrNotFound)
+	})
+
+	t.Run("Count", func(t *testing.T) {
+		must(t, repo.Count(ctx, env.scopeA(), stocktake.ID, bears.ID, 17, counter.ID))
+		// Повторный ввод заменяет количество
+		must(t, repo.Count(ctx, env.scopeA(), stocktake.ID, bears.ID, 18, counter.ID))
+		wantErr(t, repo.Count(ctx, env.scopeA(), stocktake.ID, warehouse.ID, 1, counter.ID), repository.ErrNotFound)
+
+		got, err := repo.Get(ctx, env.scopeA(), stocktake.ID)
+		must(t, err)
+		line := stocktakeLine(t, got, bears.ID)
+		equal(t, "Counted", line.Counted, true)
+		equal(t, "CountedQuantity", line.CountedQuantity, 18)
+		equal(t, "CountedBy", line.CountedBy, counter.ID)
+		equal(t, "Variance", line.Variance(), -2)
+		equal(t, "VarianceValue", line.VarianceValue(), bear.DefaultCost.Mul(-2))
+		equal(t, "CountedCount", got.CountedCount, 1)
+	})
+
+	t.Run("Scan", func(t *testing.T) {
+		line, err := repo.Scan(ctx, env.scopeA(), stocktake.ID, bunny.Barcode, 3, counter.ID)
+		must(t, err)
+		equal(t, "ItemID", line.ItemID, bunnies.ID)
+		equal(t, "CountedQuantity", line.CountedQuantity, 3)
+		// Артикул тоже подходит; сканы складываются
+		line, err = repo.Scan(ctx, env.scopeA(), stocktake.ID, bunny.SKU, 4, counter.ID)
+		must(t, err)
+		equal(t, "CountedQuantity after SKU", line.CountedQuantity, 7)
+		equal(t, "ItemName", line.ItemName, bunny.Name)
+
+		_, err = repo.Scan(ctx, env.scopeA(), stocktake.ID, "NO-SUCH-CODE", 1, counter.ID)
+		wantErr(t, err, repository.ErrNotFound)
+	})
+
+	t.Run("Approve", func(t *testing.T) {
+		// Движение после снимка не теряется: проводится только расхождение
+		_, err := env.Repos.Inventory.Move(ctx, env.scopeA(), bears.ID, repository.StockChange{
+			Type: repository.MovementReceipt, Quantity: 10,
+		})
+		must(t, err)
+
+		must(t, repo.Approve(ctx, env.scopeA(), stocktake.ID, counter.ID))
+		equal(t, "bears", quantity(t, env, bears.ID), 28)
+		equal(t, "bunnies", quantity(t, env, bunnies.ID), 7)
+		// Непосчитанная позиция не меняется
+		equal(t, "foxes", quantity(t, env, foxes.ID), 7)
+
+		movements, err := env.Repos.Inventory.Movements(ctx, env.scopeA(), bears.ID)
+		must(t, err)
+		equal(t, "last movement type", movements[0].Type, repository.MovementAdjustment)
+		equal(t, "last movement quantity", movements[0].Quantity, -2)
+		equal(t, "last movement reason", movements[0].Reason, fmt.Sprintf("Инвентаризация №%d", stocktake.ID))
+		equal(t, "warehouse usage", usage(t, env, warehouse.ID), 28+7+7)
+
+		got, err := repo.Get(ctx, env.scopeA(), stocktake.ID)
+		must(t, err)
+		equal(t, "Status", got.Status, repository.StocktakeApproved)
+		equal(t, "ApprovedByName", got.ApprovedByName, counter.Username)
+		equal(t, "ClosedAt set", got.ClosedAt.IsZero(), false)
+
+		wantErr(t, repo.Approve(ctx, env.scopeA(), stocktake.ID, counter.ID), repository.ErrNotFound)
+		wantErr(t, repo.Count(ctx, env.scopeA(), stocktake.ID, bears.ID, 1, counter.ID), repository.ErrNotFound)
+		wantErr(t, repo.Cancel(ctx, env.scopeA(), stocktake.ID), repository.ErrNotFound)
+	})
+
+	t.Run("ApproveAllOrNothing", func(t *testing.T) {
+		next := models.Stocktake{WarehouseID: warehouse.ID}
+		must(t, repo.Start(ctx, env.scopeA(), &next))
+		must(t, repo.Count(ctx, env.scopeA(), next.ID, bunnies.ID, 10, counter.ID))
+		must(t, repo.Count(ctx, env.scopeA(), next.ID, bears.ID, 0, counter.ID))
+		// Пока шел подсчет, мишек отгрузили: недостачу по снимку не списать
+		_, err := env.Repos.Inventory.Move(ctx, env.scopeA(), bears.ID, repository.StockChange{
+			Type: repository.MovementShipment, Quantity: 20,
+		})
+		must(t, err)
+
+		wantErr(t, repo.Approve(ctx, env.scopeA(), next.ID, counter.ID), repository.ErrInsufficientStock)
+		equal(t, "bunnies", quantity(t, env, bunnies.ID), 7)
+		equal(t, "bears", quantity(t, env, bears.ID), 8)
+
+		must(t, repo.Cancel(ctx, env.scopeA(), next.ID))
+		list, err := repo.List(ctx, env.scopeA())
+		must(t, err)
+		if !inOrder(ids(list, stocktakeID), next.ID, stocktake.ID) {
+			t.Errorf("stocktakes are not newest first: %v", ids(list, stocktakeID))
+		}
+		equal(t, "Status", list[0].Status, repository.StocktakeCancelled)
+		equal(t, "CountedCount", list[0].CountedCount, 2)
+	})
+
+	t.Run("BookedCost", func(t *testing.T) {
+		// Расхождение оценивается по себестоимости остатка, а не по
+		// текущей закупочной цене товара
+		_, err := env.Repos.Inventory.Move(ctx, env.scopeA(), bunnies.ID, repository.StockChange{
+			Type: repository.MovementReceipt, Quantity: 3, UnitCost: money.FromRubles(100),
+		})
+		must(t, err)
+		emptied := newItem(t, env, warehouse.ID, newProduct(t, env, env.OrgA, "Ежик", "SKU-COUNT-HEDGEHOG"), 0)
+
+		next := models.Stocktake{WarehouseID: warehouse.ID}
+		must(t, repo.Start(ctx, env.scopeA(), &next))
+		got, err := repo.Get(ctx, env.scopeA(), next.ID)
+		must(t, err)
+		// (7 × 85,50 + 3 × 100) / 10
+		equal(t, "bunnies UnitCost", stocktakeLine(t, got, bunnies.ID).UnitCost, money.FromKopecks(8985))
+		// Для пустого остатка — закупочная цена товара
+		equal(t, "emptied UnitCost", stocktakeLine(t, got, emptied.ID).UnitCost, money.FromKopecks(8550))
+		must(t, repo.Cancel(ctx, env.scopeA(), next.ID))
+	})
+
+	t.Run("DeleteItem", func(t *testing.T) {
+		wantErr(t, env.Repos.Inventory.DeleteItem(ctx, env.scopeA(), foxes.ID), repository.ErrInUse)
+		empty := newItem(t, env, warehouse.ID, newProduct(t, env, env.OrgA, "Енот", "SKU-COUNT-RACCOON"), 0)
+		next := models.Stocktake{WarehouseID: warehouse.ID}
+		must(t, repo.Start(ctx, env.scopeA(), &next))
+		must(t, env.Repos.Inventory.DeleteItem(ctx, env.scopeA(), empty.ID))
+		got, err := repo.Get(ctx, env.scopeA(), next.ID)
+		must(t, err)
+		for _, line := range got.Lines {
+			if line.ItemID == empty.ID {
+				t.Error("deleted item is still in the stocktake")
+			}
+		}
+		must(t, repo.Cancel(ctx, env.scopeA(), next.ID))
+	})
+}
@@ -0,0 +1,281 @@
+package repotest
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"vend_erp/config"
+	"vend_erp/internal/models"
+	"vend_erp/internal/replenishment"
+	"vend_erp/internal/repository"
+)
+
+// newDraft создает черновик заказа на склад с одной позицией.
+func newDraft(t *testing.T, env Env, item models.WarehouseInventory, quantity int) models.WarehouseSupply {
+	t.Helper()
+	supply := models.WarehouseSupply{
+		WarehouseID:  item.WarehouseID,
+		SupplierName: "ООО Игрушки",
+		SupplyDate:   date(2024, 5, 1),
+		ExpectedDate: date(2024, 5, 8),
+		Status:       repository.SupplyDraft,
+		Notes:        "Сформирован автоматически",
+		Items: []models.SupplyItem{
+			{InventoryItemID: item.ID, QuantityOrdered: quantity, UnitPrice: item.UnitPrice},
+		},
+	}
+	must(t, env.Repos.Supplies.Create(context.Background(), &supply))
+	if supply.ID == 0 || supply.Items[0].ID == 0 {
+		t.Fatal("Supplies.Create did not set IDs")
+	}
+	return supply
+}
+
+func supplyID(s models.WarehouseSupply) int64 { return s.ID }
+
+// reorderLine находит позицию в результате ReorderLines.
+func reorderLine(t *testing.T, env Env, scope repository.Scope, since time.Time, itemID int64) (repository.ReorderLine, bool) {
+	t.Helper()
+	lines, err := env.Repos.Supplies.ReorderLines(context.Background(), scope, since)
+	must(t, err)
+	for _, line := range lines {
+		if line.ItemID == itemID {
+			return line, true
+		}
+	}
+	return repository.ReorderLine{}, false
+}
+
+func testSupplies(t *testing.T, env Env) {
+	ctx := context.Background()
+	repo := env.Repos.Supplies
+	warehouse := newWarehouse(t, env, env.OrgA, "Основной", true)
+	other := newWarehouse(t, env, env.OrgA, "Запасной", true)
+	product := newProduct(t, env, env.OrgA, "Зайчик", "SKU-SUPPLY")
+	item := newItem(t, env, warehouse.ID, product, 40)
+	draft := newDraft(t, env, item, 30)
+
+	t.Run("Get", func(t *testing.T) {
+		got, err := repo.Get(ctx, env.scopeA(), draft.ID)
+		must(t, err)
+		equal(t, "Status", got.Status, repository.SupplyDraft)
+		equal(t, "SupplierName", got.SupplierName, "ООО Игрушки")
+		sameDay(t, "SupplyDate", got.SupplyDate, draft.SupplyDate)
+		sameDay(t, "ExpectedDate", got.ExpectedDate, draft.ExpectedDate)
+		equal(t, "TotalAmount", got.TotalAmount, product.DefaultCost.Mul(30))
+		equal(t, "WarehouseName", got.WarehouseName, warehouse.Name)
+		equal(t, "OrgID", got.OrgID, env.OrgA)
+		equal(t, "ApprovedBy", got.ApprovedBy, int64(0))
+		if len(got.Items) != 1 {
+			t.Fatalf("got %d items, want 1", len(got.Items))
+		}
+		equal(t, "Items[0].InventoryItemID", got.Items[0].InventoryItemID, item.ID)
+		equal(t, "Items[0].QuantityOrdered", got.Items[0].QuantityOrdered, 30)
+		equal(t, "Items[0].TotalPrice", got.Items[0].TotalPrice, product.DefaultCost.Mul(30))
+		equal(t, "Items[0].SKU", got.Items[0].SKU, product.SKU)
+		equal(t, "Items[0].ItemName", got.Items[0].ItemName, product.Name)
+	})
+
+	t.Run("Scope", func(t *testing.T) {
+		_, err := repo.Get(ctx, env.scopeB(), draft.ID)
+		wantErr(t, err, repository.ErrNotFound)
+		listB, err := repo.List(ctx, env.scopeB())
+		must(t, err)
+		equal(t, "in org B list", contains(ids(listB, supplyID), draft.ID), false)
+		wantErr(t, repo.Approve(ctx, env.scopeB(), draft.ID, 0), repository.ErrNotFound)
+		wantErr(t, repo.Reject(ctx, env.scopeB(), draft.ID), repository.ErrNotFound)
+
+		if _, ok := reorderLine(t, env, env.scopeB(), time.Time{}, item.ID); ok {
+			t.Error("org B sees the item in ReorderLines")
+		}
+	})
+
+	t.Run("ItemFromOtherWarehouse", func(t *testing.T) {
+		supply := models.WarehouseSupply{
+			WarehouseID:  other.ID,
+			SupplierName: "ООО Игрушки",
+			SupplyDate:   date(2024, 5, 1),
+			Status:       repository.SupplyDraft,
+			Items:        []models.SupplyItem{{InventoryItemID: item.ID, QuantityOrdered: 1}},
+		}
+		wantErr(t, repo.Create(ctx, &supply), repository.ErrNotFound)
+	})
+
+	t.Run("ReorderLines", func(t *testing.T) {
+		location := newLocation(t, env, env.OrgA, "ТЦ Поставки", true)
+		machine := newMachine(t, env, env.OrgA, location.ID, "SN-SUPPLY")
+		operator := newUser(t, env, env.OrgA, "supplier", models.UserStatusActive)
+		for _, n := range []int{4, 6} {
+			_, err := warehouseRestock(env, machine, operator.ID, warehouse.ID, product.ID, n)
+			must(t, err)
+		}
+		_, err := env.Repos.Inventory.Move(ctx, env.scopeA(), item.ID, repository.StockChange{
+			Type: repository.MovementShipment, Quantity: 5,
+		})
+		must(t, err)
+		equal(t, "default PackSize", product.PackSize, 1)
+		product.MinOrderQuantity = 12
+		product.PackSize = 6
+		must(t, env.Repos.Products.Update(ctx, env.scopeA(), product))
+		product.Version++
+
+		line, ok := reorderLine(t, env, env.scopeA(), time.Now().Add(-time.Hour), item.ID)
+		if !ok {
+			t.Fatal("item missing from ReorderLines")
+		}
+		equal(t, "WarehouseID", line.WarehouseID, warehouse.ID)
+		equal(t, "OrgID", line.OrgID, env.OrgA)
+		equal(t, "SupplierName", line.SupplierName, product.SupplierName)
+		equal(t, "UnitCost", line.UnitCost, product.DefaultCost)
+		equal(t, "MinOrder", line.MinOrder, 12)
+		equal(t, "PackSize", line.PackSize, 6)
+		// Остальные проверки считают заказ без условий поставщика
+		product.MinOrderQuantity = 0
+		product.PackSize = 1
+		must(t, env.Repos.Products.Update(ctx, env.scopeA(), product))
+		product.Version++
+		equal(t, "Quantity", line.Quantity, 25)
+		equal(t, "MinStock", line.MinStock, 10)
+		equal(t, "MaxStock", line.MaxStock, 100)
+		equal(t, "OnOrder", line.OnOrder, 30)
+		// Отгрузки не считаются расходом в автоматы
+		equal(t, "Restocked", line.Restocked, 10)
+
+		line, _ = reorderLine(t, env, env.scopeA(), time.Now().Add(time.Hour), item.ID)
+		equal(t, "Restocked after since", line.Restocked, 0)
+	})
+
+	t.Run("InactiveProduct", func(t *testing.T) {
+		closed := newProduct(t, env, env.OrgA, "Архивный", "SKU-SUPPLY-CLOSED")
+		stocked := newItem(t, env, warehouse.ID, closed, 0)
+		closed.IsActive = false
+		must(t, env.Repos.Products.Update(ctx, env.scopeA(), closed))
+		if _, ok := reorderLine(t, env, env.scopeA(), time.Time{}, stocked.ID); ok {
+			t.Error("inactive product is in ReorderLines")
+		}
+	})
+
+	t.Run("LockReplenishment", func(t *testing.T) {
+		unlock, err := repo.LockReplenishment(ctx)
+		must(t, err)
+		_, err = repo.LockReplenishment(ctx)
+		wantErr(t, err, repository.ErrLocked)
+		unlock()
+		unlock, err = repo.LockReplenishment(ctx)
+		must(t, err)
+		unlock()
+	})
+
+	t.Run("Approve", func(t *testing.T) {
+		approver := newUser(t, env, env.OrgA, "approver", models.UserStatusActive)
+		must(t, repo.Approve(ctx, env.scopeA(), draft.ID, approver.ID))
+
+		got, err := repo.Get(ctx, env.scopeA(), draft.ID)
+		must(t, err)
+		equal(t, "Status", got.Status, repository.SupplyOrdered)
+		equal(t, "ApprovedBy", got.ApprovedBy, approver.ID)
+		equal(t, "ApprovedByName", got.ApprovedByName, approver.Username)
+		equal(t, "ApprovedAt set", got.ApprovedAt.IsZero(), false)
+
+		// Рассмотренный заказ больше не черновик
+		wantErr(t, repo.Approve(ctx, env.scopeA(), draft.ID, approver.ID), repository.ErrNotFound)
+		wantErr(t, repo.Reject(ctx, env.scopeA(), draft.ID), repository.ErrNotFound)
+
+		// Утвержденный заказ по-прежнему ожидается на складе
+		line, _ := reorderLine(t, env, env.scopeA(), time.Time{}, item.ID)
+		equal(t, "OnOrder", line.OnOrder, 30)
+	})
+
+	t.Run("Reject", func(t *testing.T) {
+		rejected := newDraft(t, env, item, 5)
+		must(t, repo.Reject(ctx, env.scopeA(), rejected.ID))
+		got, err := repo.Get(ctx, env.scopeA(), rejected.ID)
+		must(t, err)
+		equal(t, "Status", got.Status, repository.SupplyCancelled)
+		equal(t, "ApprovedBy", got.ApprovedBy, int64(0))
+
+		// Отмененный заказ не считается заказанным
+		line, _ := reorderLine(t, env, env.scopeA(), time.Time{}, item.ID)
+		equal(t, "OnOrder", line.OnOrder, 30)
+	})
+
+	t.Run("DraftsFirst", func(t *testing.T) {
+		newer := newDraft(t, env, item, 1)
+		list, err := repo.List(ctx, env.scopeA())
+		must(t, err)
+		if !inOrder(ids(list, supplyID), newer.ID, draft.ID) {
+			t.Errorf("drafts are not listed first: %v", ids(list, supplyID))
+		}
+		for _, supply := range list {
+			if supply.ID == newer.ID && len(supply.Items) != 1 {
+				t.Errorf("List returned %d items for the draft, want 1", len(supply.Items))
+			}
+		}
+		must(t, repo.Reject(ctx, env.scopeA(), newer.ID))
+	})
+
+	t.Run("Receive", func(t *testing.T) {
+		// Расход 10 за неделю при поставке за неделю
+		cfg := config.ReplenishmentConfig{Window: 7 * 24 * time.Hour, LeadTime: 7 * 24 * time.Hour}
+		reorder := func() int {
+			line, _ := reorderLine(t, env, env.scopeA(), time.Time{}, item.ID)
+			return replenishment.Reorder(line, cfg)
+		}
+		// 25 на складе + 30 заказано − 10 расхода выше минимума
+		equal(t, "Reorder before receipt", reorder(), 0)
+
+		pending := newDraft(t, env, item, 5)
+		wantErr(t, repo.Receive(ctx, env.scopeA(), pending.ID, map[int64]int{pending.Items[0].ID: 5}), repository.ErrNotFound)
+		must(t, repo.Reject(ctx, env.scopeA(), pending.ID))
+
+		received := map[int64]int{draft.Items[0].ID: 20}
+		wantErr(t, repo.Receive(ctx, env.scopeB(), draft.ID, received), repository.ErrNotFound)
+		if err := repo.Receive(ctx, env.scopeA(), draft.ID, map[int64]int{draft.Items[0].ID: -1}); err == nil {
+			t.Error("negative received quantity accepted")
+		}
+
+		// Пришло меньше заказанного: заказ все равно закрывается
+		must(t, repo.Receive(ctx, env.scopeA(), draft.ID, received))
+		got, err := repo.Get(ctx, env.scopeA(), draft.ID)
+		must(t, err)
+		equal(t, "Status", got.Status, repository.SupplyDelivered)
+		equal(t, "QuantityReceived", got.Items[0].QuantityReceived, 20)
+		equal(t, "quantity", quantity(t, env, item.ID), 45)
+		movements, err := env.Repos.Inventory.Movements(ctx, env.scopeA(), item.ID)
+		must(t, err)
+		equal(t, "movement Type", movements[0].Type, repository.MovementReceipt)
+		equal(t, "movement Quantity", movements[0].Quantity, 20)
+		equal(t, "movement Cost", movements[0].Cost, product.DefaultCost.Mul(20))
+		equal(t, "movement Reason", movements[0].Reason, fmt.Sprintf("Поставка №%d", draft.ID))
+		wantErr(t, repo.Receive(ctx, env.scopeA(), draft.ID, received), repository.ErrNotFound)
+
+		// Принятый заказ больше не считается заказанным
+		line, _ := reorderLine(t, env, env.scopeA(), time.Time{}, item.ID)
+		equal(t, "OnOrder", line.OnOrder, 0)
+		_, err = env.Repos.Inventory.Move(ctx, env.scopeA(), item.ID, repository.StockChange{
+			Type: repository.MovementShipment, Quantity: 40,
+		})
+		must(t, err)
+		// 5 на складе − 10 расхода: заказ до максимума 100
+		equal(t, "Reorder after receipt", reorder(), 105)
+		lines, err := repo.ReorderLines(ctx, env.scopeA(), time.Time{})
+		must(t, err)
+		drafts := replenishment.Plan(lines, cfg, time.Now())
+		if len(drafts) != 1 || len(drafts[0].Items) != 1 {
+			t.Fatalf("Plan returned %+v, want one draft with one item", drafts)
+		}
+		equal(t, "draft item", drafts[0].Items[0].InventoryItemID, item.ID)
+		equal(t, "draft quantity", drafts[0].Items[0].QuantityOrdered, 105)
+	})
+
+	t.Run("DeleteItem", func(t *testing.T) {
+		spare := newItem(t, env, other.ID, product, 0)
+		supply := newDraft(t, env, spare, 7)
+		must(t, env.Repos.Inventory.DeleteItem(ctx, env.scopeA(), spare.ID))
+		got, err := repo.Get(ctx, env.scopeA(), supply.ID)
+		must(t, err)
+		equal(t, "items after delete", len(got.Items), 0)
+	})
+}
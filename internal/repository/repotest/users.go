@@ -0,0 +1,262 @@
+package repotest
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"vend_erp/internal/models"
+	"vend_erp/internal/repository"
+)
+
+func newUser(t *testing.T, env Env, orgID int64, name string, status int) models.User {
+	t.Helper()
+	user := models.User{
+		Username:     env.unique(name),
+		Email:        env.unique(name) + "@example.com",
+		UserRole:     "user",
+		Status:       status,
+		FullUserName: "Иван Иванов",
+		Phone:        "+7 900 222-22-22",
+		Team:         "Север",
+		OrgID:        orgID,
+	}
+	must(t, env.Repos.Users.Create(context.Background(), &user, "hash-"+name))
+	if user.ID == 0 {
+		t.Fatal("Users.Create did not set ID")
+	}
+	return user
+}
+
+func userID(u models.User) int64 { return u.ID }
+
+func testUsers(t *testing.T, env Env) {
+	ctx := context.Background()
+	repo := env.Repos.Users
+	active := newUser(t, env, env.OrgA, "active", models.UserStatusActive)
+	pending := newUser(t, env, env.OrgA, "pending", models.UserStatusPending)
+
+	t.Run("Get", func(t *testing.T) {
+		got, err := repo.Get(ctx, env.scopeA(), active.ID)
+		must(t, err)
+		equal(t, "Username", got.Username, active.Username)
+		equal(t, "Email", got.Email, active.Email)
+		equal(t, "UserRole", got.UserRole, "user")
+		equal(t, "Status", got.Status, models.UserStatusActive)
+		equal(t, "FullUserName", got.FullUserName, active.FullUserName)
+		equal(t, "CompanyName", got.CompanyName, "")
+		equal(t, "Team", got.Team, "Север")
+		equal(t, "IsSuperAdmin", got.IsSuperAdmin, false)
+		equal(t, "OrgID", got.OrgID, env.OrgA)
+		equal(t, "OrgName", got.OrgName != "", true)
+
+		_, err = repo.Get(ctx, env.scopeB(), active.ID)
+		wantErr(t, err, repository.ErrNotFound)
+	})
+
+	t.Run("FindByEmail", func(t *testing.T) {
+		got, err := repo.FindByEmail(ctx, active.Email)
+		must(t, err)
+		equal(t, "ID", got.ID, active.ID)
+		_, err = repo.FindByEmail(ctx, env.unique("nobody")+"@example.com")
+		wantErr(t, err, repository.ErrNotFound)
+
+		hash, err := repo.PasswordHash(ctx, active.ID)
+		must(t, err)
+		equal(t, "PasswordHash", hash, "hash-active")
+	})
+
+	t.Run("Exists", func(t *testing.T) {
+		for _, tc := range []struct {
+			email, username string
+			want            bool
+		}{
+			{active.Email, env.unique("other"), true},
+			{env.unique("other") + "@example.com", active.Username, true},
+			{env.unique("other") + "@example.com", env.unique("other"), false},
+		} {
+			got, err := repo.Exists(ctx, tc.email, tc.username)
+			must(t, err)
+			equal(t, "Exists("+tc.email+", "+tc.username+")", got, tc.want)
+		}
+
+		duplicate := active
+		duplicate.ID = 0
+		wantErr(t, repo.Create(ctx, &duplicate, "hash"), repository.ErrDuplicate)
+	})
+
+	t.Run("List", func(t *testing.T) {
+		newer := newUser(t, env, env.OrgA, "newer", models.UserStatusActive)
+		list, err := repo.List(ctx, env.scopeA())
+		must(t, err)
+		// Ожидающие подтверждения первыми, затем новые
+		equal(t, "order", inOrder(ids(list, userID), pending.ID, newer.ID, active.ID), true)
+
+		listB, err := repo.List(ctx, env.scopeB())
+		must(t, err)
+		equal(t, "in org B list", contains(ids(listB, userID), active.ID), false)
+	})
+
+	t.Run("Membership", func(t *testing.T) {
+		member, err := repo.IsMember(ctx, active.ID, env.OrgA)
+		must(t, err)
+		equal(t, "member of home org", member, true)
+		member, err = repo.IsMember(ctx, active.ID, env.OrgB)
+		must(t, err)
+		equal(t, "member of other org", member, false)
+
+		list, err := repo.ListActive(ctx, env.OrgA)
+		must(t, err)
+		got := ids(list, userID)
+		equal(t, "active listed", contains(got, active.ID), true)
+		equal(t, "pending listed", contains(got, pending.ID), false)
+	})
+
+	t.Run("Update", func(t *testing.T) {
+		changed := active
+		changed.UserRole = "admin"
+		changed.Team = ""
+		changed.MustChangePassword = true
+		changed.OrgID = 0
+
+		wantErr(t, repo.Update(ctx, env.scopeB(), changed, ""), repository.ErrNotFound)
+		must(t, repo.Update(ctx, env.scopeA(), changed, ""))
+		got, err := repo.Get(ctx, env.scopeA(), active.ID)
+		must(t, err)
+		equal(t, "UserRole", got.UserRole, "admin")
+		equal(t, "Team", got.Team, "")
+		equal(t, "MustChangePassword", got.MustChangePassword, true)
+		equal(t, "OrgID kept", got.OrgID, env.OrgA)
+		hash, err := repo.PasswordHash(ctx, active.ID)
+		must(t, err)
+		equal(t, "password kept", hash, "hash-active")
+
+		must(t, repo.Update(ctx, env.scopeA(), changed, "hash-new"))
+		hash, err = repo.PasswordHash(ctx, active.ID)
+		must(t, err)
+		equal(t, "password changed", hash, "hash-new")
+
+		taken := changed
+		taken.Email = pending.Email
+		wantErr(t, repo.Update(ctx, env.scopeA(), taken, ""), repository.ErrDuplicate)
+	})
+
+	t.Run("MoveToOrganization", func(t *testing.T) {
+		moving := newUser(t, env, env.OrgA, "moving", models.UserStatusActive)
+		moving.OrgID = env.OrgB
+		must(t, repo.Update(ctx, allOrgs, moving, ""))
+
+		got, err := repo.Get(ctx, env.scopeB(), moving.ID)
+		must(t, err)
+		equal(t, "OrgID", got.OrgID, env.OrgB)
+		member, err := repo.IsMember(ctx, moving.ID, env.OrgB)
+		must(t, err)
+		equal(t, "member of new org", member, true)
+	})
+
+	t.Run("Review", func(t *testing.T) {
+		wantErr(t, repo.Review(ctx, env.scopeB(), pending.ID, models.UserStatusActive, active.ID), repository.ErrNotFound)
+		wantErr(t, repo.Review(ctx, env.scopeA(), active.ID, models.UserStatusRejected, active.ID), repository.ErrNotFound)
+
+		must(t, repo.Review(ctx, env.scopeA(), pending.ID, models.UserStatusActive, active.ID))
+		got, err := repo.Get(ctx, env.scopeA(), pending.ID)
+		must(t, err)
+		equal(t, "Status", got.Status, models.UserStatusActive)
+
+		// Рассмотренная заявка повторно не меняется
+		wantErr(t, repo.Review(ctx, env.scopeA(), pending.ID, models.UserStatusRejected, active.ID), repository.ErrNotFound)
+	})
+
+	t.Run("SuperAdmin", func(t *testing.T) {
+		super := newUser(t, env, env.OrgA, "super", models.UserStatusActive)
+		env.SetSuperAdmin(t, super.ID)
+		superScope := repository.Scope{OrgID: env.OrgA, SuperAdmin: true}
+
+		// Администратор организации не меняет пароль суперадмина и не удаляет его
+		changed := super
+		changed.OrgID = 0
+		wantErr(t, repo.Update(ctx, env.scopeA(), changed, "hash-stolen"), repository.ErrNotFound)
+		must(t, repo.Delete(ctx, env.scopeA(), super.ID))
+		hash, err := repo.PasswordHash(ctx, super.ID)
+		must(t, err)
+		equal(t, "password kept", hash, "hash-super")
+
+		must(t, repo.Update(ctx, superScope, changed, "hash-new"))
+		got, err := repo.Get(ctx, env.scopeA(), super.ID)
+		must(t, err)
+		equal(t, "IsSuperAdmin kept", got.IsSuperAdmin, true)
+		must(t, repo.Delete(ctx, superScope, super.ID))
+		_, err = repo.Get(ctx, env.scopeA(), super.ID)
+		wantErr(t, err, repository.ErrNotFound)
+	})
+
+	t.Run("Teams", func(t *testing.T) {
+		newUser(t, env, env.OrgB, "team-b", models.UserStatusActive)
+		teams, err := repo.Teams(ctx, env.scopeA())
+		must(t, err)
+		equal(t, "teams", len(teams), 1)
+		if len(teams) == 1 {
+			equal(t, "team", teams[0], "Север")
+		}
+	})
+
+	t.Run("OIDC", func(t *testing.T) {
+		issuer, subject := "https://idp.example.com", env.unique("subject")
+		_, err := repo.FindBySubject(ctx, issuer, subject)
+		wantErr(t, err, repository.ErrNotFound)
+
+		// Email сравнивается без учета регистра
+		exists, err := repo.Exists(ctx, strings.ToUpper(active.Email), "")
+		must(t, err)
+		equal(t, "Exists", exists, true)
+
+		linked, err := repo.LinkSubject(ctx, strings.ToUpper(active.Email), issuer, subject)
+		must(t, err)
+		equal(t, "linked", linked.ID, active.ID)
+		found, err := repo.FindBySubject(ctx, issuer, subject)
+		must(t, err)
+		equal(t, "FindBySubject", found.ID, active.ID)
+		equal(t, "Status", found.Status, models.UserStatusActive)
+
+		// Уже связанную учетную запись повторно не связывают
+		_, err = repo.LinkSubject(ctx, active.Email, issuer, env.unique("other-subject"))
+		wantErr(t, err, repository.ErrNotFound)
+
+		provisioned := models.User{
+			Username: env.unique("sso"),
+			Email:    env.unique("sso") + "@example.com",
+			UserRole: "user",
+			Status:   models.UserStatusPending,
+			OrgID:    env.OrgA,
+		}
+		must(t, repo.Provision(ctx, &provisioned, issuer, env.unique("sso-subject")))
+		found, err = repo.FindBySubject(ctx, issuer, env.unique("sso-subject"))
+		must(t, err)
+		equal(t, "provisioned", found.ID, provisioned.ID)
+		equal(t, "Status", found.Status, models.UserStatusPending)
+		hash, err := repo.PasswordHash(ctx, provisioned.ID)
+		must(t, err)
+		equal(t, "password", hash, "")
+		member, err := repo.IsMember(ctx, provisioned.ID, env.OrgA)
+		must(t, err)
+		equal(t, "member", member, true)
+
+		must(t, repo.SetRole(ctx, provisioned.ID, "moderator"))
+		got, err := repo.Get(ctx, env.scopeA(), provisioned.ID)
+		must(t, err)
+		equal(t, "UserRole", got.UserRole, "moderator")
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		must(t, repo.Delete(ctx, env.scopeB(), pending.ID))
+		_, err := repo.Get(ctx, env.scopeA(), pending.ID)
+		must(t, err)
+
+		must(t, repo.Delete(ctx, env.scopeA(), pending.ID))
+		_, err = repo.Get(ctx, env.scopeA(), pending.ID)
+		wantErr(t, err, repository.ErrNotFound)
+		member, err := repo.IsMember(ctx, pending.ID, env.OrgA)
+		must(t, err)
+		equal(t, "membership removed", member, false)
+	})
+}
@@ -0,0 +1,236 @@
+package repotest
+
+import (
+	"context"
+	"testing"
+
+	"vend_erp/internal/models"
+	"vend_erp/internal/repository"
+)
+
+// newZone заводит зону склада вместимостью capacity.
+func newZone(t *testing.T, env Env, warehouseID int64, name string, capacity int) models.WarehouseZone {
+	t.Helper()
+	zone := models.WarehouseZone{WarehouseID: warehouseID, Name: name, Capacity: capacity}
+	must(t, env.Repos.Inventory.CreateZone(context.Background(), allOrgs, &zone))
+	if zone.ID == 0 {
+		t.Fatal("Inventory.CreateZone did not set ID")
+	}
+	return zone
+}
+
+// findZone находит зону в списке Inventory.Zones.
+func findZone(t *testing.T, env Env, warehouseID, id int64) models.WarehouseZone {
+	t.Helper()
+	zones, err := env.Repos.Inventory.Zones(context.Background(), allOrgs, warehouseID)
+	must(t, err)
+	for _, zone := range zones {
+		if zone.ID == id {
+			return zone
+		}
+	}
+	t.Fatalf("zone %d is not listed for warehouse %d", id, warehouseID)
+	return models.WarehouseZone{}
+}
+
+func zoneID(z models.WarehouseZone) int64 { return z.ID }
+
+// bulky заводит товар, единица которого занимает volume единиц вместимости.
+func bulky(t *testing.T, env Env, name, sku string, volume int) models.Product {
+	t.Helper()
+	product := newProduct(t, env, env.OrgA, name, sku)
+	product.UnitVolume = volume
+	must(t, env.Repos.Products.Update(context.Background(), env.scopeA(), product))
+	return env.mustProduct(t, product.ID)
+}
+
+func testZones(t *testing.T, env Env) {
+	ctx := context.Background()
+	repo := env.Repos.Inventory
+
+	t.Run("UnitVolume", func(t *testing.T) {
+		warehouse := newWarehouse(t, env, env.OrgA, "Объемный", true)
+		equal(t, "CapacityPolicy", warehouse.CapacityPolicy, repository.CapacityWarn)
+		plain := newProduct(t, env, env.OrgA, "Брелок", "SKU-VOL-PLAIN")
+		equal(t, "default UnitVolume", plain.UnitVolume, 1)
+		newItem(t, env, warehouse.ID, plain, 10)
+		big := bulky(t, env, "Большой медведь", "SKU-VOL-BIG", 4)
+		item := newItem(t, env, warehouse.ID, big, 5)
+		equal(t, "usage by volume", usage(t, env, warehouse.ID), 10+5*4)
+
+		got, err := repo.Item(ctx, env.scopeA(), item.ID)
+		must(t, err)
+		equal(t, "UnitVolume", got.UnitVolume, 4)
+		equal(t, "Volume", got.Volume(), 20)
+
+		// Новый объем товара пересчитывает загрузку склада
+		big.UnitVolume = 2
+		must(t, env.Repos.Products.Update(ctx, env.scopeA(), big))
+		equal(t, "usage after volume change", usage(t, env, warehouse.ID), 10+5*2)
+	})
+
+	t.Run("Zones", func(t *testing.T) {
+		warehouse := newWarehouse(t, env, env.OrgA, "Зонированный", true)
+		shelf := newZone(t, env, warehouse.ID, "Б-1", 100)
+		rack := newZone(t, env, warehouse.ID, "А-1", 50)
+
+		wantErr(t, repo.CreateZone(ctx, env.scopeA(), &models.WarehouseZone{
+			WarehouseID: warehouse.ID, Name: "А-1", Capacity: 10,
+		}), repository.ErrDuplicate)
+		wantErr(t, repo.CreateZone(ctx, env.scopeB(), &models.WarehouseZone{
+			WarehouseID: warehouse.ID, Name: "В-1", Capacity: 10,
+		}), repository.ErrNotFound)
+		_, err := repo.Zones(ctx, env.scopeB(), warehouse.ID)
+		wantErr(t, err, repository.ErrNotFound)
+
+		product := bulky(t, env, "Пирамидка", "SKU-ZONE", 3)
+		item := models.WarehouseInventory{
+			WarehouseID: warehouse.ID, ProductID: product.ID, Quantity: 4, ZoneID: rack.ID,
+			MinStockLevel: 1, MaxStockLevel: 10,
+		}
+		must(t, repo.CreateItem(ctx, &item))
+		got, err := repo.Item(ctx, env.scopeA(), item.ID)
+		must(t, err)
+		equal(t, "ZoneID", got.ZoneID, rack.ID)
+		equal(t, "ZoneName", got.ZoneName, "А-1")
+
+		zones, err := repo.Zones(ctx, env.scopeA(), warehouse.ID)
+		must(t, err)
+		// Зоны по названию: «А-1» раньше «Б-1»
+		equal(t, "ordered by name", inOrder(ids(zones, zoneID), rack.ID, shelf.ID), true)
+		equal(t, "Usage", findZone(t, env, warehouse.ID, rack.ID).Usage, 12)
+		equal(t, "ItemCount", findZone(t, env, warehouse.ID, rack.ID).ItemCount, 1)
+		equal(t, "empty zone Usage", findZone(t, env, warehouse.ID, shelf.ID).Usage, 0)
+
+		// Зона другого склада позиции не подходит
+		other := newWarehouse(t, env, env.OrgA, "Соседний", true)
+		foreignZone := newZone(t, env, other.ID, "А-1", 10)
+		wantErr(t, repo.Place(ctx, env.scopeA(), item.ID, foreignZone.ID), repository.ErrNotFound)
+		wrong := got
+		wrong.ZoneID = foreignZone.ID
+		wantErr(t, repo.UpdateItem(ctx, env.scopeA(), wrong), repository.ErrNotFound)
+		wantErr(t, repo.Place(ctx, env.scopeB(), item.ID, shelf.ID), repository.ErrNotFound)
+
+		must(t, repo.Place(ctx, env.scopeA(), item.ID, shelf.ID))
+		equal(t, "moved Usage", findZone(t, env, warehouse.ID, shelf.ID).Usage, 12)
+		equal(t, "left Usage", findZone(t, env, warehouse.ID, rack.ID).Usage, 0)
+		// Размещение меняет позицию: форма, открытая до него, устарела
+		wantErr(t, repo.UpdateItem(ctx, env.scopeA(), got), repository.ErrConflict)
+
+		renamed := shelf
+		renamed.Name = "Б-2"
+		renamed.Capacity = 120
+		must(t, repo.UpdateZone(ctx, env.scopeA(), renamed))
+		equal(t, "renamed", findZone(t, env, warehouse.ID, shelf.ID).Name, "Б-2")
+		renamed.Name = "А-1"
+		wantErr(t, repo.UpdateZone(ctx, env.scopeA(), renamed), repository.ErrDuplicate)
+		wantErr(t, repo.UpdateZone(ctx, env.scopeB(), renamed), repository.ErrNotFound)
+
+		// Удаление зоны оставляет позицию неразмещенной
+		must(t, repo.DeleteZone(ctx, env.scopeB(), shelf.ID))
+		equal(t, "zone kept for other org", findZone(t, env, warehouse.ID, shelf.ID).ID, shelf.ID)
+		must(t, repo.DeleteZone(ctx, env.scopeA(), shelf.ID))
+		got, err = repo.Item(ctx, env.scopeA(), item.ID)
+		must(t, err)
+		equal(t, "ZoneID after delete", got.ZoneID, int64(0))
+		zones, err = repo.Zones(ctx, env.scopeA(), warehouse.ID)
+		must(t, err)
+		equal(t, "zones after delete", len(zones), 1)
+	})
+
+	t.Run("Block", func(t *testing.T) {
+		warehouse := newWarehouse(t, env, env.OrgA, "Строгий", true)
+		warehouse.TotalCapacity = 100
+		warehouse.CapacityPolicy = repository.CapacityBlock
+		must(t, repo.UpdateWarehouse(ctx, env.scopeA(), warehouse))
+		zone := newZone(t, env, warehouse.ID, "Полка", 30)
+		product := bulky(t, env, "Коробка", "SKU-BLOCK", 2)
+		item := models.WarehouseInventory{
+			WarehouseID: warehouse.ID, ProductID: product.ID, Quantity: 10, ZoneID: zone.ID,
+			MinStockLevel: 1, MaxStockLevel: 50,
+		}
+		must(t, repo.CreateItem(ctx, &item))
+
+		// Зона: 20 из 30, приход 6 коробок занял бы 32
+		_, err := repo.Move(ctx, env.scopeA(), item.ID, repository.StockChange{Type: repository.MovementReceipt, Quantity: 6})
+		wantErr(t, err, repository.ErrOverCapacity)
+		equal(t, "rejected receipt", quantity(t, env, item.ID), 10)
+		equal(t, "usage unchanged", usage(t, env, warehouse.ID), 20)
+		_, err = repo.Move(ctx, env.scopeA(), item.ID, repository.StockChange{Type: repository.MovementReceipt, Quantity: 5})
+		must(t, err)
+
+		// Склад: без зоны проверяется только общая вместимость
+		loose := newItem(t, env, warehouse.ID, newProduct(t, env, env.OrgA, "Мячик", "SKU-BLOCK-LOOSE"), 60)
+		_, err = repo.Move(ctx, env.scopeA(), loose.ID, repository.StockChange{Type: repository.MovementReceipt, Quantity: 11})
+		wantErr(t, err, repository.ErrOverCapacity)
+		overfill := models.WarehouseInventory{
+			WarehouseID: warehouse.ID, ProductID: newProduct(t, env, env.OrgA, "Кубик", "SKU-BLOCK-NEW").ID,
+			Quantity: 11, MaxStockLevel: 50,
+		}
+		wantErr(t, repo.CreateItem(ctx, &overfill), repository.ErrOverCapacity)
+
+		// В переполненную зону позицию не переложить
+		wantErr(t, repo.Place(ctx, env.scopeA(), loose.ID, zone.ID), repository.ErrOverCapacity)
+
+		// Перемещение на полный склад отклоняется целиком
+		source := newWarehouse(t, env, env.OrgA, "Исходный", true)
+		extra := newItem(t, env, source.ID, env.mustProduct(t, loose.ProductID), 20)
+		wantErr(t, repo.Transfer(ctx, env.scopeA(), extra.ID, warehouse.ID, 11, ""), repository.ErrOverCapacity)
+		equal(t, "source kept", quantity(t, env, extra.ID), 20)
+		must(t, repo.Transfer(ctx, env.scopeA(), extra.ID, warehouse.ID, 10, ""))
+		equal(t, "full", usage(t, env, warehouse.ID), 100)
+
+		// Корректировка фиксирует факт и проходит сверх вместимости
+		_, err = repo.Adjust(ctx, env.scopeA(), loose.ID, repository.AdjustAdd, 5, "нашлось")
+		must(t, err)
+		equal(t, "over after adjustment", usage(t, env, warehouse.ID), 105)
+	})
+
+	t.Run("Warn", func(t *testing.T) {
+		warehouse := newWarehouse(t, env, env.OrgA, "Мягкий", true)
+		warehouse.TotalCapacity = 10
+		must(t, repo.UpdateWarehouse(ctx, env.scopeA(), warehouse))
+		zone := newZone(t, env, warehouse.ID, "Полка", 5)
+		item := newItem(t, env, warehouse.ID, newProduct(t, env, env.OrgA, "Юла", "SKU-WARN"), 4)
+		must(t, repo.Place(ctx, env.scopeA(), item.ID, zone.ID))
+
+		// Предупреждает обработчик; хранилище проводит приход
+		_, err := repo.Move(ctx, env.scopeA(), item.ID, repository.StockChange{Type: repository.MovementReceipt, Quantity: 10})
+		must(t, err)
+		equal(t, "over capacity", usage(t, env, warehouse.ID), 14)
+		equal(t, "zone over capacity", findZone(t, env, warehouse.ID, zone.ID).Free(), -9)
+	})
+
+	t.Run("Putaway", func(t *testing.T) { testPutaway(t) })
+}
+
+// testPutaway проверяет выбор зоны без хранилища.
+func testPutaway(t *testing.T) {
+	zones := []models.WarehouseZone{
+		{ID: 1, Name: "А-1", Capacity: 100, Usage: 90},
+		{ID: 2, Name: "А-2", Capacity: 100, Usage: 40},
+		{ID: 3, Name: "Б-1", Capacity: 100, Usage: 70},
+		{ID: 4, Name: "Б-2", Capacity: 100, Usage: 70},
+	}
+	cases := []struct {
+		name   string
+		zoneID int64
+		volume int
+		want   int64
+	}{
+		{"current zone fits", 2, 10, 2},
+		{"fullest that fits", 0, 20, 3},
+		{"current zone too small", 1, 20, 3},
+		{"only roomy zone", 0, 50, 2},
+		{"nothing fits", 0, 70, 0},
+	}
+	for _, tc := range cases {
+		got, ok := repository.Putaway(zones, tc.zoneID, tc.volume)
+		if tc.want == 0 {
+			equal(t, tc.name+" ok", ok, false)
+			continue
+		}
+		equal(t, tc.name+" ok", ok, true)
+		equal(t, tc.name, got.ID, tc.want)
+	}
+}
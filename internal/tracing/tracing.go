@@ -0,0 +1,122 @@
+// Package tracing настраивает OpenTelemetry: провайдер спанов с выбранным
+// экспортером, распространение контекста W3C Trace Context и HTTP-middleware.
+// SQL-запросы трассирует config.ConnectDB через otelsql; чтобы спан запроса
+// к базе попал в трассу HTTP-запроса, обработчик передает r.Context()
+// в QueryContext/ExecContext.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+
+	"vend_erp/config"
+)
+
+// Setup регистрирует глобальный провайдер спанов. Возвращаемая функция
+// отправляет накопленные спаны и закрывает экспортер; ее нужно вызвать
+// при остановке сервера. С экспортером none остается провайдер-заглушка.
+func Setup(ctx context.Context, cfg config.TracingConfig) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{}, propagation.Baggage{}))
+
+	if cfg.Exporter == config.TraceNone {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	exporter, closeOutput, err := newExporter(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewSchemaless(
+		attribute.String("service.name", cfg.ServiceName),
+	))
+	if err != nil {
+		return nil, err
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(cfg.SampleRatio))),
+	)
+	otel.SetTracerProvider(provider)
+
+	return func(ctx context.Context) error {
+		err := provider.Shutdown(ctx)
+		if closeErr := closeOutput(); err == nil {
+			err = closeErr
+		}
+		return err
+	}, nil
+}
+
+func newExporter(ctx context.Context, cfg config.TracingConfig) (sdktrace.SpanExporter, func() error, error) {
+	noClose := func() error { return nil }
+
+	switch cfg.Exporter {
+	case config.TraceStdout:
+		exporter, err := stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+		return exporter, noClose, err
+
+	case config.TraceFile:
+		file, err := os.OpenFile(cfg.File, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+		if err != nil {
+			return nil, nil, fmt.Errorf("opening trace file: %w", err)
+		}
+		exporter, err := stdouttrace.New(stdouttrace.WithWriter(file))
+		if err != nil {
+			file.Close()
+			return nil, nil, err
+		}
+		return exporter, file.Close, nil
+
+	case config.TraceOTLP:
+		var options []otlptracehttp.Option
+		if strings.Contains(cfg.Endpoint, "://") {
+			options = append(options, otlptracehttp.WithEndpointURL(cfg.Endpoint))
+		} else {
+			options = append(options, otlptracehttp.WithEndpoint(cfg.Endpoint))
+		}
+		if cfg.Insecure {
+			options = append(options, otlptracehttp.WithInsecure())
+		}
+		exporter, err := otlptracehttp.New(ctx, options...)
+		return exporter, noClose, err
+	}
+	return nil, nil, fmt.Errorf("unknown trace exporter %q", cfg.Exporter)
+}
+
+// Middleware открывает серверный спан на каждый запрос и принимает
+// родительский контекст из заголовка traceparent.
+func Middleware(next http.Handler) http.Handler {
+	return otelhttp.NewHandler(next, "http.request")
+}
+
+// Route переименовывает спан запроса по шаблону маршрута ServeMux
+// ("GET /machines/save"). Как и metrics.Middleware, должен оборачивать
+// непосредственно mux: шаблон известен только после выбора обработчика.
+func Route(mux http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mux.ServeHTTP(w, r)
+		if r.Pattern == "" {
+			return
+		}
+		span := trace.SpanFromContext(r.Context())
+		span.SetName(r.Method + " " + r.Pattern)
+		span.SetAttributes(attribute.String("http.route", r.Pattern))
+	})
+}
@@ -0,0 +1,234 @@
+// Package validate разбирает и проверяет поля HTML-форм. Form собирает
+// ошибки по именам полей, чтобы показать их все разом рядом с полями,
+// а не обрывать сохранение на первой:
+//
+//	form := validate.New(r.PostForm)
+//	form.Required("serial_number", "model")
+//	capacity := form.Int("capacity_toys")
+//	current := form.Int("current_toys_count")
+//	form.Min("capacity_toys", capacity, 0)
+//	form.Check(current <= capacity, "current_toys_count", "Не больше вместимости")
+//	if !form.Valid() {
+//		// перерисовать форму с form.Errors
+//	}
+//
+// Разбор (Int, Money, Date...) при ошибке возвращает ноль и запоминает
+// сообщение; у каждого поля остается только первая ошибка.
+package validate
+
+import (
+	"fmt"
+	"net/mail"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"vend_erp/internal/money"
+)
+
+// Форматы полей <input type="date"> и <input type="datetime-local">.
+const (
+	DateLayout     = "2006-01-02"
+	DateTimeLayout = "2006-01-02T15:04"
+)
+
+// Errors — сообщения об ошибках по именам полей.
+type Errors map[string]string
+
+// Form — отправленные значения формы и найденные в них ошибки.
+type Form struct {
+	values url.Values
+	Errors Errors
+}
+
+// New оборачивает значения формы, обычно r.PostForm.
+func New(values url.Values) *Form {
+	return &Form{values: values, Errors: Errors{}}
+}
+
+// Get возвращает значение поля без пробелов по краям.
+func (f *Form) Get(field string) string {
+	return strings.TrimSpace(f.values.Get(field))
+}
+
+// Value возвращает значение поля в том виде, в каком его ввел пользователь:
+// форма с ошибками показывает именно его, а не разобранный ноль.
+func (f *Form) Value(field string) string {
+	return f.values.Get(field)
+}
+
+// Error возвращает ошибку поля или пустую строку.
+func (f *Form) Error(field string) string {
+	return f.Errors[field]
+}
+
+// Valid сообщает, что ошибок нет.
+func (f *Form) Valid() bool {
+	return len(f.Errors) == 0
+}
+
+// Fail запоминает ошибку поля, если у него еще нет другой.
+func (f *Form) Fail(field, message string) {
+	if _, ok := f.Errors[field]; !ok {
+		f.Errors[field] = message
+	}
+}
+
+// Check запоминает ошибку поля, если условие не выполнено.
+func (f *Form) Check(ok bool, field, message string) {
+	if !ok {
+		f.Fail(field, message)
+	}
+}
+
+// Required проверяет, что поля заполнены.
+func (f *Form) Required(fields ...string) {
+	for _, field := range fields {
+		f.Check(f.Get(field) != "", field, "Обязательное поле")
+	}
+}
+
+// MaxLength ограничивает длину поля в символах.
+func (f *Form) MaxLength(field string, max int) {
+	f.Check(utf8.RuneCountInString(f.Get(field)) <= max, field,
+		fmt.Sprintf("Не длиннее %d символов", max))
+}
+
+// OneOf проверяет, что значение поля — одно из допустимых, и возвращает его.
+func (f *Form) OneOf(field string, allowed ...string) string {
+	value := f.Get(field)
+	for _, a := range allowed {
+		if value == a {
+			return value
+		}
+	}
+	f.Fail(field, "Выберите значение из списка")
+	return value
+}
+
+// Email проверяет адрес электронной почты, если он указан.
+func (f *Form) Email(field string) {
+	value := f.Get(field)
+	if value == "" {
+		return
+	}
+	addr, err := mail.ParseAddress(value)
+	f.Check(err == nil && addr.Address == value, field, "Некорректный email")
+}
+
+// Phone проверяет номер телефона, если он указан: от 10 до 15 цифр,
+// между ними допустимы пробелы, скобки, дефисы и "+" в начале.
+func (f *Form) Phone(field string) {
+	value := f.Get(field)
+	if value == "" {
+		return
+	}
+	digits := 0
+	for i, r := range value {
+		switch {
+		case r >= '0' && r <= '9':
+			digits++
+		case r == '+' && i == 0, r == ' ', r == '-', r == '(', r == ')':
+		default:
+			f.Fail(field, "Некорректный номер телефона")
+			return
+		}
+	}
+	f.Check(digits >= 10 && digits <= 15, field, "Некорректный номер телефона")
+}
+
+// URL проверяет абсолютную ссылку http или https, если она указана.
+func (f *Form) URL(field string) {
+	value := f.Get(field)
+	if value == "" {
+		return
+	}
+	u, err := url.Parse(value)
+	f.Check(err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != "",
+		field, "Введите ссылку, например https://example.com/photo.jpg")
+}
+
+// Int разбирает целое число; пустое поле — ноль.
+func (f *Form) Int(field string) int {
+	value := f.Get(field)
+	if value == "" {
+		return 0
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		f.Fail(field, "Введите целое число")
+		return 0
+	}
+	return n
+}
+
+// ID разбирает идентификатор из скрытого поля или списка; пустое поле — ноль.
+func (f *Form) ID(field string) int64 {
+	value := f.Get(field)
+	if value == "" {
+		return 0
+	}
+	id, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || id < 0 {
+		f.Fail(field, "Выберите значение из списка")
+		return 0
+	}
+	return id
+}
+
+// maxAmount — наибольшая сумма, которая помещается в DECIMAL(10,2).
+var maxAmount = money.FromKopecks(9_999_999_999)
+
+// Money разбирает денежную сумму; пустое поле — ноль.
+func (f *Form) Money(field string) money.Amount {
+	amount, err := money.Parse(f.Get(field))
+	if err != nil {
+		f.Fail(field, "Введите сумму, например 1234,50")
+		return 0
+	}
+	if amount > maxAmount || amount < -maxAmount {
+		f.Fail(field, "Слишком большая сумма")
+		return 0
+	}
+	return amount
+}
+
+// Date разбирает дату из <input type="date">; пустое поле — нулевое время.
+func (f *Form) Date(field string) time.Time {
+	return f.time(field, DateLayout, "Введите дату")
+}
+
+// DateTime разбирает дату и время из <input type="datetime-local">.
+func (f *Form) DateTime(field string) time.Time {
+	return f.time(field, DateTimeLayout, "Введите дату и время")
+}
+
+func (f *Form) time(field, layout, message string) time.Time {
+	value := f.Get(field)
+	if value == "" {
+		return time.Time{}
+	}
+	t, err := time.Parse(layout, value)
+	if err != nil {
+		f.Fail(field, message)
+		return time.Time{}
+	}
+	return t
+}
+
+// Min проверяет нижнюю границу числа.
+func (f *Form) Min(field string, value, min int) {
+	f.Check(value >= min, field, fmt.Sprintf("Не меньше %d", min))
+}
+
+// Range проверяет, что число лежит в границах включительно.
+func (f *Form) Range(field string, value, min, max int) {
+	f.Check(value >= min && value <= max, field, fmt.Sprintf("От %d до %d", min, max))
+}
+
+// NotNegative проверяет, что сумма не отрицательна.
+func (f *Form) NotNegative(field string, amount money.Amount) {
+	f.Check(amount >= 0, field, "Сумма не может быть отрицательной")
+}
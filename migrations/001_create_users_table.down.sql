@@ -0,0 +1,2 @@
+-- Migration: 001_create_users_table.down.sql
+DROP TABLE IF EXISTS users;
@@ -0,0 +1,2 @@
+-- Migration: 002_create_sessions_table.down.sql
+DROP TABLE IF EXISTS sessions;
@@ -0,0 +1,2 @@
+-- Migration: 003_create_locations_table.down.sql
+DROP TABLE IF EXISTS locations;
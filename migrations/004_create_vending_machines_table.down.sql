@@ -0,0 +1,2 @@
+-- Migration: 004_create_vending_machines_table.down.sql
+DROP TABLE IF EXISTS vending_machines;
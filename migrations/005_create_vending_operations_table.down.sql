@@ -0,0 +1,2 @@
+-- Migration: 005_create_vending_operations_table.down.sql
+DROP TABLE IF EXISTS vending_operations;
@@ -0,0 +1,3 @@
+-- Migration: 006_seed.down.sql
+-- Демо-данные загружаются сидами и удаляются вместе с базой; откатывать нечего.
+SELECT 1;
@@ -0,0 +1,9 @@
+-- Migration: 007_create_warehouse_tables.down.sql
+DROP TABLE IF EXISTS shipment_items;
+DROP TABLE IF EXISTS warehouse_shipments;
+DROP TABLE IF EXISTS supply_items;
+DROP TABLE IF EXISTS warehouse_supplies;
+DROP TABLE IF EXISTS warehouse_inventory;
+DROP TABLE IF EXISTS warehouse_categories;
+DROP TABLE IF EXISTS warehouse;
+DROP FUNCTION IF EXISTS update_updated_at_column();
@@ -0,0 +1,3 @@
+-- Migration: 008_seed_warehouse_data.down.sql
+-- Демо-данные загружаются сидами и удаляются вместе с базой; откатывать нечего.
+SELECT 1;
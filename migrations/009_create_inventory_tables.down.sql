@@ -0,0 +1,3 @@
+-- Migration: 009_create_inventory_tables.down.sql
+DROP TABLE IF EXISTS inventory_transfers;
+DROP TABLE IF EXISTS inventory_adjustments;
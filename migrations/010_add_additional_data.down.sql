@@ -0,0 +1,3 @@
+-- Migration: 010_add_additional_data.down.sql
+-- Демо-данные загружаются сидами и удаляются вместе с базой; откатывать нечего.
+SELECT 1;
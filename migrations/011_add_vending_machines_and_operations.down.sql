@@ -0,0 +1,3 @@
+-- Migration: 011_add_vending_machines_and_operations.down.sql
+DROP INDEX IF EXISTS idx_vending_machines_location_status;
+DROP INDEX IF EXISTS idx_vending_operations_machine_date;
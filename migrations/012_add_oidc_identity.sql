@@ -0,0 +1,8 @@
+-- Migration: 012_add_oidc_identity.sql
+-- Привязка пользователей к учетной записи внешнего OIDC провайдера
+ALTER TABLE users ADD COLUMN IF NOT EXISTS oidc_issuer VARCHAR(255);
+ALTER TABLE users ADD COLUMN IF NOT EXISTS oidc_subject VARCHAR(255);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_oidc_identity ON users(oidc_issuer, oidc_subject)
+    WHERE oidc_subject IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_users_email_lower ON users(lower(email));
@@ -0,0 +1,13 @@
+-- Migration: 013_create_organizations.down.sql
+ALTER TABLE sessions DROP COLUMN IF EXISTS all_orgs;
+ALTER TABLE sessions DROP COLUMN IF EXISTS active_org_id;
+
+ALTER TABLE users DROP COLUMN IF EXISTS is_superadmin;
+ALTER TABLE users DROP COLUMN IF EXISTS org_id;
+ALTER TABLE locations DROP COLUMN IF EXISTS org_id;
+ALTER TABLE vending_machines DROP COLUMN IF EXISTS org_id;
+ALTER TABLE vending_operations DROP COLUMN IF EXISTS org_id;
+ALTER TABLE warehouse DROP COLUMN IF EXISTS org_id;
+
+DROP TABLE IF EXISTS user_organizations;
+DROP TABLE IF EXISTS organizations;
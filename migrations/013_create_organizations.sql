@@ -0,0 +1,68 @@
+-- migrate:replaces-checksum dda2b2cc31ce483fa8c9bde6b6d19875642aa6f08577424531abc521e9230189
+-- migrate:replaces-checksum 64e883a17fa0c79c334d3f1199ab58573a9cc444573333084313247c72b2f7be
+-- Migration: 013_create_organizations.sql
+-- Организации (арендаторы): все локации, автоматы, склады, операции и
+-- пользователи принадлежат организации. Существующие данные переносятся
+-- в организацию по умолчанию. Организации разделяет приложение: каждый
+-- запрос фильтрует по org_id.
+
+CREATE TABLE IF NOT EXISTS organizations (
+    id BIGSERIAL PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    slug VARCHAR(100) NOT NULL UNIQUE,
+    is_active BOOLEAN DEFAULT true,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+INSERT INTO organizations (name, slug)
+VALUES ('Организация по умолчанию', 'default')
+ON CONFLICT (slug) DO NOTHING;
+
+-- Членство пользователей в организациях (для переключения между ними)
+CREATE TABLE IF NOT EXISTS user_organizations (
+    user_id BIGINT NOT NULL,
+    org_id BIGINT NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (user_id, org_id),
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY (org_id) REFERENCES organizations(id) ON DELETE CASCADE
+);
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS org_id BIGINT REFERENCES organizations(id);
+ALTER TABLE users ADD COLUMN IF NOT EXISTS is_superadmin BOOLEAN NOT NULL DEFAULT false;
+ALTER TABLE locations ADD COLUMN IF NOT EXISTS org_id BIGINT REFERENCES organizations(id);
+ALTER TABLE vending_machines ADD COLUMN IF NOT EXISTS org_id BIGINT REFERENCES organizations(id);
+ALTER TABLE vending_operations ADD COLUMN IF NOT EXISTS org_id BIGINT REFERENCES organizations(id);
+ALTER TABLE warehouse ADD COLUMN IF NOT EXISTS org_id BIGINT REFERENCES organizations(id);
+
+UPDATE users SET org_id = (SELECT id FROM organizations WHERE slug = 'default') WHERE org_id IS NULL;
+UPDATE locations SET org_id = (SELECT id FROM organizations WHERE slug = 'default') WHERE org_id IS NULL;
+UPDATE vending_machines SET org_id = (SELECT id FROM organizations WHERE slug = 'default') WHERE org_id IS NULL;
+UPDATE vending_operations SET org_id = (SELECT id FROM organizations WHERE slug = 'default') WHERE org_id IS NULL;
+UPDATE warehouse SET org_id = (SELECT id FROM organizations WHERE slug = 'default') WHERE org_id IS NULL;
+
+ALTER TABLE users ALTER COLUMN org_id SET NOT NULL;
+ALTER TABLE locations ALTER COLUMN org_id SET NOT NULL;
+ALTER TABLE vending_machines ALTER COLUMN org_id SET NOT NULL;
+ALTER TABLE vending_operations ALTER COLUMN org_id SET NOT NULL;
+ALTER TABLE warehouse ALTER COLUMN org_id SET NOT NULL;
+
+INSERT INTO user_organizations (user_id, org_id)
+SELECT id, org_id FROM users
+ON CONFLICT DO NOTHING;
+
+-- Суперадмин назначается явно (go run ./cmd/seed superadmin grant <username>),
+-- роль admin сама по себе кросс-организационного доступа не дает.
+
+-- Выбранная в сессии организация; all_orgs — сводный режим суперадмина
+ALTER TABLE sessions ADD COLUMN IF NOT EXISTS active_org_id BIGINT REFERENCES organizations(id) ON DELETE SET NULL;
+ALTER TABLE sessions ADD COLUMN IF NOT EXISTS all_orgs BOOLEAN NOT NULL DEFAULT false;
+
+CREATE INDEX IF NOT EXISTS idx_users_org ON users(org_id);
+CREATE INDEX IF NOT EXISTS idx_locations_org ON locations(org_id);
+CREATE INDEX IF NOT EXISTS idx_machines_org ON vending_machines(org_id);
+CREATE INDEX IF NOT EXISTS idx_vending_operations_org ON vending_operations(org_id);
+CREATE INDEX IF NOT EXISTS idx_warehouse_org ON warehouse(org_id);
+
+CREATE TRIGGER update_organizations_updated_at BEFORE UPDATE ON organizations FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
@@ -0,0 +1,6 @@
+-- Migration: 014_create_invites.down.sql
+DROP TABLE IF EXISTS invites;
+DROP INDEX IF EXISTS idx_users_pending;
+ALTER TABLE users DROP COLUMN IF EXISTS approved_at;
+ALTER TABLE users DROP COLUMN IF EXISTS approved_by;
+ALTER TABLE users DROP COLUMN IF EXISTS team;
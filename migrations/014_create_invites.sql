@@ -0,0 +1,27 @@
+-- migrate:replaces-checksum 9d7b712ddb7e581090dffcf57469209a3283255fc2a567e406c12c7b56721ada
+-- Migration: 014_create_invites.sql
+-- Приглашения и подтверждение регистрации администратором.
+-- users.status: 0 — неактивен, 1 — активен, 2 — ожидает подтверждения, 3 — отклонен.
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS team VARCHAR(100);
+ALTER TABLE users ADD COLUMN IF NOT EXISTS approved_by BIGINT REFERENCES users(id) ON DELETE SET NULL;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS approved_at TIMESTAMP;
+
+-- В таблице хранится только SHA-256 токена: сама ссылка показывается один раз
+CREATE TABLE IF NOT EXISTS invites (
+    id BIGSERIAL PRIMARY KEY,
+    token_hash VARCHAR(64) NOT NULL UNIQUE,
+    email VARCHAR(255),
+    userrole VARCHAR(50) NOT NULL DEFAULT 'user',
+    team VARCHAR(100),
+    org_id BIGINT NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+    invited_by BIGINT REFERENCES users(id) ON DELETE SET NULL,
+    expires_at TIMESTAMP NOT NULL,
+    used_at TIMESTAMP,
+    used_by BIGINT REFERENCES users(id) ON DELETE SET NULL,
+    revoked_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_invites_org ON invites(org_id);
+CREATE INDEX IF NOT EXISTS idx_users_pending ON users(org_id) WHERE status = 2;
@@ -0,0 +1,3 @@
+-- Migration: 015_add_password_policy_fields.down.sql
+ALTER TABLE users DROP COLUMN IF EXISTS password_changed_at;
+ALTER TABLE users DROP COLUMN IF EXISTS must_change_password;
@@ -0,0 +1,6 @@
+-- Migration: 015_add_password_policy_fields.sql
+-- Принудительная смена пароля и дата последней смены.
+-- Пароли в открытом виде хешируются сервером при запуске (credentials.MigratePlaintext).
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS must_change_password BOOLEAN NOT NULL DEFAULT false;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS password_changed_at TIMESTAMP;
@@ -0,0 +1,2 @@
+-- Migration: 016_create_seed_runs.down.sql
+DROP TABLE IF EXISTS seed_runs;
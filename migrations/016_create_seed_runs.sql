@@ -0,0 +1,24 @@
+-- Migration: 016_create_seed_runs.sql
+-- Журнал загруженных шагов сидов (см. seeds/). Шаг, записанный здесь,
+-- повторно не выполняется.
+
+CREATE TABLE IF NOT EXISTS seed_runs (
+    name VARCHAR(100) PRIMARY KEY,
+    profile VARCHAR(50) NOT NULL,
+    org_id BIGINT REFERENCES organizations(id) ON DELETE SET NULL,
+    applied_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- В базах, созданных до выделения сидов, демо-данные уже загружены
+-- миграциями 006-011: отмечаем соответствующие шаги выполненными.
+INSERT INTO seed_runs (name, profile, org_id)
+SELECT step.name, 'legacy', (SELECT id FROM organizations WHERE slug = 'default')
+FROM (VALUES
+    ('users', EXISTS (SELECT 1 FROM users WHERE username = 'monitor')),
+    ('fleet', EXISTS (SELECT 1 FROM locations WHERE name = 'ТЦ "Москва"')),
+    ('warehouse', EXISTS (SELECT 1 FROM warehouse WHERE name = 'Основной склад')),
+    ('network', EXISTS (SELECT 1 FROM warehouse WHERE name = 'Склад "Северный"')),
+    ('machines', EXISTS (SELECT 1 FROM vending_machines WHERE serial_number = 'VM-101'))
+) AS step(name, seeded)
+WHERE step.seeded
+ON CONFLICT (name) DO NOTHING;
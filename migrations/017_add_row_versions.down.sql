@@ -0,0 +1,6 @@
+-- Migration: 017_add_row_versions.down.sql
+ALTER TABLE warehouse_inventory DROP COLUMN IF EXISTS version;
+ALTER TABLE warehouse DROP COLUMN IF EXISTS version;
+ALTER TABLE vending_operations DROP COLUMN IF EXISTS version;
+ALTER TABLE locations DROP COLUMN IF EXISTS version;
+ALTER TABLE vending_machines DROP COLUMN IF EXISTS version;
@@ -0,0 +1,10 @@
+-- Migration: 017_add_row_versions.sql
+-- Версии записей для оптимистической блокировки: форма отправляет версию,
+-- с которой начиналась правка, и UPDATE проходит, только если запись с тех
+-- пор никто не сохранил. Каждое изменение увеличивает версию.
+
+ALTER TABLE vending_machines ADD COLUMN IF NOT EXISTS version INTEGER NOT NULL DEFAULT 1;
+ALTER TABLE locations ADD COLUMN IF NOT EXISTS version INTEGER NOT NULL DEFAULT 1;
+ALTER TABLE vending_operations ADD COLUMN IF NOT EXISTS version INTEGER NOT NULL DEFAULT 1;
+ALTER TABLE warehouse ADD COLUMN IF NOT EXISTS version INTEGER NOT NULL DEFAULT 1;
+ALTER TABLE warehouse_inventory ADD COLUMN IF NOT EXISTS version INTEGER NOT NULL DEFAULT 1;
@@ -0,0 +1,3 @@
+-- Migration: 018_create_stock_movements.down.sql
+ALTER TABLE warehouse_inventory DROP CONSTRAINT IF EXISTS warehouse_inventory_quantity_not_negative;
+DROP TABLE IF EXISTS stock_movements;
@@ -0,0 +1,47 @@
+-- migrate:replaces-checksum d04d6de89bd8a9e97e2152466ac44e040030198baa08b9ca7bd3ab61ee014b76
+-- Migration: 018_create_stock_movements.sql
+-- Журнал движений товара: каждое изменение остатка позиции (приход,
+-- корректировка, перемещение, отгрузка, пополнение автомата) — одна строка
+-- с изменением и остатком после него. Строка пишется в той же транзакции,
+-- что и новое warehouse_inventory.quantity, поэтому остаток позиции всегда
+-- равен balance_after ее последнего движения.
+--
+-- Журналы inventory_adjustments и inventory_transfers больше не пополняются
+-- и остаются как архив. Для уже существующих позиций записывается
+-- начальный остаток.
+--
+-- Журнал не удаляется вместе с позицией склада: иначе остатки и оценка
+-- запасов на прошлые даты менялись бы задним числом, поэтому позицию,
+-- у которой есть движения, удалить нельзя. Ограничение item_id — NO ACTION,
+-- а не RESTRICT: оно проверяется в конце запроса, поэтому удаление склада
+-- удаляет его позиции вместе с движениями (warehouse_id ON DELETE CASCADE).
+
+CREATE TABLE IF NOT EXISTS stock_movements (
+    id BIGSERIAL PRIMARY KEY,
+    item_id BIGINT NOT NULL REFERENCES warehouse_inventory(id),
+    warehouse_id BIGINT NOT NULL REFERENCES warehouse(id) ON DELETE CASCADE,
+    movement_type VARCHAR(20) NOT NULL CHECK (movement_type IN
+        ('opening', 'receipt', 'adjustment', 'transfer_out', 'transfer_in', 'shipment', 'restock')),
+    -- Изменение остатка со знаком: приход положительный, расход отрицательный
+    quantity INTEGER NOT NULL,
+    balance_after INTEGER NOT NULL CHECK (balance_after >= 0),
+    -- Позиция на другом складе для перемещений
+    transfer_item_id BIGINT REFERENCES warehouse_inventory(id) ON DELETE SET NULL,
+    -- Автомат, который пополнили со склада
+    vending_machine_id BIGINT REFERENCES vending_machines(id) ON DELETE SET NULL,
+    reason TEXT,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_stock_movements_item ON stock_movements(item_id, created_at, id);
+CREATE INDEX IF NOT EXISTS idx_stock_movements_warehouse ON stock_movements(warehouse_id, created_at);
+
+INSERT INTO stock_movements (item_id, warehouse_id, movement_type, quantity, balance_after, reason)
+SELECT id, warehouse_id, 'opening', quantity, quantity, 'Остаток при переходе на журнал движений'
+FROM warehouse_inventory
+WHERE quantity > 0;
+
+-- Отрицательный остаток больше недопустим. NOT VALID не проверяет старые
+-- строки, чтобы миграция не падала на уже испорченных данных.
+ALTER TABLE warehouse_inventory
+    ADD CONSTRAINT warehouse_inventory_quantity_not_negative CHECK (quantity >= 0) NOT VALID;
@@ -0,0 +1,35 @@
+-- Migration: 019_create_products.down.sql
+ALTER TABLE warehouse_inventory
+    ADD COLUMN category_id BIGINT REFERENCES warehouse_categories(id) ON DELETE CASCADE,
+    ADD COLUMN item_type VARCHAR(50) CHECK (item_type IN ('vending_machine', 'toy', 'capsule')),
+    ADD COLUMN item_name VARCHAR(255),
+    ADD COLUMN description TEXT,
+    ADD COLUMN unit_price DECIMAL(10,2) DEFAULT 0,
+    ADD COLUMN sku VARCHAR(100);
+
+-- Артикул снова уникален во всей базе: повторы получают суффикс с id позиции
+UPDATE warehouse_inventory wi
+SET category_id = p.category_id,
+    item_type = p.item_type,
+    item_name = p.name,
+    description = p.description,
+    unit_price = p.default_cost,
+    sku = p.sku || CASE WHEN EXISTS (
+        SELECT 1 FROM warehouse_inventory x
+        JOIN products q ON q.id = x.product_id
+        WHERE q.sku = p.sku AND x.id < wi.id
+    ) THEN '-' || wi.id ELSE '' END
+FROM products p
+WHERE p.id = wi.product_id;
+
+ALTER TABLE warehouse_inventory
+    ALTER COLUMN category_id SET NOT NULL,
+    ALTER COLUMN item_type SET NOT NULL,
+    ALTER COLUMN item_name SET NOT NULL,
+    ADD CONSTRAINT warehouse_inventory_sku_key UNIQUE (sku),
+    DROP CONSTRAINT warehouse_inventory_warehouse_product_key,
+    DROP COLUMN product_id;
+
+CREATE INDEX IF NOT EXISTS idx_warehouse_inventory_type ON warehouse_inventory(item_type);
+
+DROP TABLE IF EXISTS products;
@@ -0,0 +1,66 @@
+-- Migration: 019_create_products.sql
+-- Справочник товаров. Раньше название, тип, категория, цена и артикул
+-- повторялись в каждой строке warehouse_inventory, а артикул был уникален
+-- во всей базе, поэтому перемещение на склад, где товара еще не было,
+-- упиралось в UNIQUE(sku). Теперь товар описывается один раз в products,
+-- а warehouse_inventory хранит только остаток товара на складе: одна
+-- строка на пару (склад, товар).
+--
+-- Каждая существующая позиция становится товаром организации своего склада
+-- с тем же артикулом; позиции без артикула получают ITEM-<id>. Цена позиции
+-- становится закупочной ценой товара.
+
+CREATE TABLE IF NOT EXISTS products (
+    id BIGSERIAL PRIMARY KEY,
+    org_id BIGINT NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+    sku VARCHAR(100) NOT NULL,
+    name VARCHAR(255) NOT NULL,
+    item_type VARCHAR(50) NOT NULL CHECK (item_type IN ('vending_machine', 'toy', 'capsule')),
+    category_id BIGINT NOT NULL REFERENCES warehouse_categories(id),
+    description TEXT,
+    barcode VARCHAR(64),
+    photo_url TEXT,
+    default_cost DECIMAL(10,2) NOT NULL DEFAULT 0,  -- закупочная цена
+    default_price DECIMAL(10,2) NOT NULL DEFAULT 0, -- цена продажи
+    supplier_name VARCHAR(255),
+    is_active BOOLEAN NOT NULL DEFAULT true,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    version INTEGER NOT NULL DEFAULT 1,
+    UNIQUE (org_id, sku)
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_products_barcode ON products(org_id, barcode) WHERE barcode IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_products_type ON products(item_type);
+
+CREATE TRIGGER update_products_updated_at BEFORE UPDATE ON products FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+
+INSERT INTO products (org_id, sku, name, item_type, category_id, description, default_cost)
+SELECT w.org_id, COALESCE(wi.sku, 'ITEM-' || wi.id), wi.item_name, wi.item_type,
+       wi.category_id, wi.description, COALESCE(wi.unit_price, 0)
+FROM warehouse_inventory wi
+JOIN warehouse w ON w.id = wi.warehouse_id
+ORDER BY wi.id
+ON CONFLICT (org_id, sku) DO NOTHING;
+
+-- Товар с позициями на складах удалить нельзя: сначала нужно убрать остатки
+ALTER TABLE warehouse_inventory ADD COLUMN IF NOT EXISTS product_id BIGINT REFERENCES products(id);
+
+UPDATE warehouse_inventory wi
+SET product_id = p.id
+FROM warehouse w, products p
+WHERE w.id = wi.warehouse_id
+  AND p.org_id = w.org_id
+  AND p.sku = COALESCE(wi.sku, 'ITEM-' || wi.id);
+
+ALTER TABLE warehouse_inventory
+    ALTER COLUMN product_id SET NOT NULL,
+    ADD CONSTRAINT warehouse_inventory_warehouse_product_key UNIQUE (warehouse_id, product_id),
+    DROP COLUMN category_id,
+    DROP COLUMN item_type,
+    DROP COLUMN item_name,
+    DROP COLUMN description,
+    DROP COLUMN unit_price,
+    DROP COLUMN sku;
+
+CREATE INDEX IF NOT EXISTS idx_warehouse_inventory_product ON warehouse_inventory(product_id);
@@ -0,0 +1,17 @@
+-- Migration: 020_add_supply_drafts.down.sql
+DROP INDEX IF EXISTS idx_stock_movements_type;
+DROP INDEX IF EXISTS idx_supply_items_item;
+
+ALTER TABLE products
+    DROP COLUMN IF EXISTS pack_size,
+    DROP COLUMN IF EXISTS min_order_quantity;
+
+-- Неутвержденные черновики удаляются вместе с позициями (ON DELETE CASCADE)
+DELETE FROM warehouse_supplies WHERE status = 'draft';
+
+ALTER TABLE warehouse_supplies
+    DROP COLUMN IF EXISTS approved_at,
+    DROP COLUMN IF EXISTS approved_by,
+    DROP CONSTRAINT IF EXISTS warehouse_supplies_status_check,
+    ADD CONSTRAINT warehouse_supplies_status_check
+        CHECK (status IN ('ordered', 'in_transit', 'delivered', 'cancelled'));
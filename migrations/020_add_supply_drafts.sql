@@ -0,0 +1,23 @@
+-- Migration: 020_add_supply_drafts.sql
+-- Черновики заказов поставщикам. Расчет пополнения по расписанию создает
+-- заказы в статусе draft; менеджер утверждает черновик (он становится
+-- ordered) или отклоняет его (cancelled). Утвердивший и время утверждения
+-- сохраняются в самом заказе. Условия поставщика товара — минимальная
+-- партия и штук в упаковке — ограничивают рассчитанный заказ снизу
+-- и округляют его вверх до целых упаковок.
+
+ALTER TABLE warehouse_supplies
+    DROP CONSTRAINT IF EXISTS warehouse_supplies_status_check,
+    ADD CONSTRAINT warehouse_supplies_status_check
+        CHECK (status IN ('draft', 'ordered', 'in_transit', 'delivered', 'cancelled')),
+    ADD COLUMN IF NOT EXISTS approved_by BIGINT REFERENCES users(id) ON DELETE SET NULL,
+    ADD COLUMN IF NOT EXISTS approved_at TIMESTAMP;
+
+ALTER TABLE products
+    ADD COLUMN IF NOT EXISTS min_order_quantity INTEGER NOT NULL DEFAULT 0 CHECK (min_order_quantity >= 0),
+    ADD COLUMN IF NOT EXISTS pack_size INTEGER NOT NULL DEFAULT 1 CHECK (pack_size >= 1);
+
+-- Расчет суммирует незакрытые заказы по позициям склада
+CREATE INDEX IF NOT EXISTS idx_supply_items_item ON supply_items(inventory_item_id);
+-- и расход по пополнениям автоматов за период
+CREATE INDEX IF NOT EXISTS idx_stock_movements_type ON stock_movements(movement_type, created_at);
@@ -0,0 +1,3 @@
+-- Migration: 021_create_stocktakes.down.sql
+DROP TABLE IF EXISTS stocktake_lines;
+DROP TABLE IF EXISTS stocktakes;
@@ -0,0 +1,34 @@
+-- Migration: 021_create_stocktakes.sql
+-- Инвентаризация склада. При открытии сессии остатки всех позиций склада
+-- и их закупочные цены замораживаются в stocktake_lines (expected_quantity,
+-- unit_cost). Счетчики вводят фактическое количество, не видя ожидаемого.
+-- При проведении расхождение counted_quantity - expected_quantity каждой
+-- посчитанной позиции записывается корректировкой в stock_movements
+-- одной транзакцией. Движения, прошедшие после снимка, сохраняются.
+
+CREATE TABLE IF NOT EXISTS stocktakes (
+    id BIGSERIAL PRIMARY KEY,
+    warehouse_id BIGINT NOT NULL REFERENCES warehouse(id) ON DELETE CASCADE,
+    status VARCHAR(20) NOT NULL DEFAULT 'counting' CHECK (status IN ('counting', 'approved', 'cancelled')),
+    notes TEXT,
+    created_by BIGINT REFERENCES users(id) ON DELETE SET NULL,
+    approved_by BIGINT REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    closed_at TIMESTAMP
+);
+
+-- На складе одновременно идет не больше одной инвентаризации
+CREATE UNIQUE INDEX IF NOT EXISTS idx_stocktakes_open ON stocktakes(warehouse_id) WHERE status = 'counting';
+
+CREATE TABLE IF NOT EXISTS stocktake_lines (
+    id BIGSERIAL PRIMARY KEY,
+    stocktake_id BIGINT NOT NULL REFERENCES stocktakes(id) ON DELETE CASCADE,
+    item_id BIGINT NOT NULL REFERENCES warehouse_inventory(id) ON DELETE CASCADE,
+    expected_quantity INTEGER NOT NULL,
+    unit_cost DECIMAL(10,2) NOT NULL DEFAULT 0,
+    -- NULL — позицию еще не посчитали
+    counted_quantity INTEGER CHECK (counted_quantity >= 0),
+    counted_by BIGINT REFERENCES users(id) ON DELETE SET NULL,
+    counted_at TIMESTAMP,
+    UNIQUE (stocktake_id, item_id)
+);
@@ -0,0 +1,6 @@
+-- Migration: 022_create_stock_lots.down.sql
+DROP TABLE IF EXISTS stock_lots;
+
+ALTER TABLE stock_movements DROP COLUMN IF EXISTS cost;
+ALTER TABLE warehouse_inventory DROP COLUMN IF EXISTS stock_value;
+ALTER TABLE organizations DROP COLUMN IF EXISTS costing_method;
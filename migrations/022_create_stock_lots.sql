@@ -0,0 +1,51 @@
+-- Migration: 022_create_stock_lots.sql
+-- Партии и себестоимость. Каждый приход заводит партию (stock_lots) со своей
+-- ценой и датой; расход списывает партии от старых к новым. Стоимость
+-- движения (stock_movements.cost, со знаком) считается по методу оценки
+-- организации: fifo — по ценам списанных партий, average — по средней
+-- себестоимости остатка. Для пополнения автомата -cost — себестоимость
+-- выданного товара. warehouse_inventory.stock_value — стоимость остатка,
+-- равная сумме cost движений позиции, поэтому стоимость запасов на любую
+-- дату — сумма cost движений до нее.
+--
+-- Истории цен до этой миграции нет: уже записанные движения и остатки
+-- оцениваются по закупочной цене товара, а остаток каждой позиции
+-- становится одной партией.
+
+ALTER TABLE organizations
+    ADD COLUMN IF NOT EXISTS costing_method VARCHAR(10) NOT NULL DEFAULT 'fifo'
+        CHECK (costing_method IN ('fifo', 'average'));
+
+ALTER TABLE warehouse_inventory ADD COLUMN IF NOT EXISTS stock_value DECIMAL(12,2) NOT NULL DEFAULT 0;
+ALTER TABLE stock_movements ADD COLUMN IF NOT EXISTS cost DECIMAL(12,2) NOT NULL DEFAULT 0;
+
+CREATE TABLE IF NOT EXISTS stock_lots (
+    id BIGSERIAL PRIMARY KEY,
+    item_id BIGINT NOT NULL REFERENCES warehouse_inventory(id) ON DELETE CASCADE,
+    -- Движение, которым партия пришла
+    movement_id BIGINT REFERENCES stock_movements(id) ON DELETE SET NULL,
+    unit_cost DECIMAL(10,2) NOT NULL CHECK (unit_cost >= 0),
+    quantity INTEGER NOT NULL CHECK (quantity > 0),
+    remaining INTEGER NOT NULL CHECK (remaining >= 0 AND remaining <= quantity),
+    received_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Расход выбирает непустые партии позиции от старых к новым
+CREATE INDEX IF NOT EXISTS idx_stock_lots_open ON stock_lots(item_id, received_at, id) WHERE remaining > 0;
+
+UPDATE stock_movements sm
+SET cost = sm.quantity * p.default_cost
+FROM warehouse_inventory wi
+JOIN products p ON p.id = wi.product_id
+WHERE wi.id = sm.item_id;
+
+UPDATE warehouse_inventory wi
+SET stock_value = wi.quantity * p.default_cost
+FROM products p
+WHERE p.id = wi.product_id;
+
+INSERT INTO stock_lots (item_id, unit_cost, quantity, remaining, received_at)
+SELECT wi.id, p.default_cost, wi.quantity, wi.quantity, COALESCE(wi.created_at, CURRENT_TIMESTAMP)
+FROM warehouse_inventory wi
+JOIN products p ON p.id = wi.product_id
+WHERE wi.quantity > 0;
@@ -0,0 +1,6 @@
+-- Migration: 023_create_warehouse_zones.down.sql
+ALTER TABLE warehouse_inventory DROP COLUMN IF EXISTS zone_id;
+DROP TABLE IF EXISTS warehouse_zones;
+
+ALTER TABLE warehouse DROP COLUMN IF EXISTS capacity_policy;
+ALTER TABLE products DROP COLUMN IF EXISTS unit_volume;
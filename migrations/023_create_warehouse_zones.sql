@@ -0,0 +1,33 @@
+-- Migration: 023_create_warehouse_zones.sql
+-- Зоны хранения склада и объем товара. Склад делится на зоны (стеллаж,
+-- ячейка, паллетное место) со своей вместимостью; позиция склада лежит
+-- в одной зоне или еще не размещена (zone_id IS NULL). Вместимость склада
+-- и зон считается в единицах вместимости: единица товара занимает
+-- products.unit_volume таких единиц, поэтому загрузка склада — сумма
+-- quantity * unit_volume его позиций, а не сумма количеств.
+--
+-- capacity_policy определяет, что делать с приходом или перемещением,
+-- после которого склад или зона переполнятся: block — отклонить,
+-- warn — предупредить и провести после подтверждения.
+
+ALTER TABLE products
+    ADD COLUMN IF NOT EXISTS unit_volume INTEGER NOT NULL DEFAULT 1 CHECK (unit_volume > 0);
+
+ALTER TABLE warehouse
+    ADD COLUMN IF NOT EXISTS capacity_policy VARCHAR(10) NOT NULL DEFAULT 'warn'
+        CHECK (capacity_policy IN ('block', 'warn'));
+
+CREATE TABLE IF NOT EXISTS warehouse_zones (
+    id BIGSERIAL PRIMARY KEY,
+    warehouse_id BIGINT NOT NULL REFERENCES warehouse(id) ON DELETE CASCADE,
+    name VARCHAR(100) NOT NULL,
+    capacity INTEGER NOT NULL CHECK (capacity > 0),
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (warehouse_id, name)
+);
+
+-- Удаленная зона оставляет свои позиции неразмещенными
+ALTER TABLE warehouse_inventory
+    ADD COLUMN IF NOT EXISTS zone_id BIGINT REFERENCES warehouse_zones(id) ON DELETE SET NULL;
+
+CREATE INDEX IF NOT EXISTS idx_warehouse_inventory_zone ON warehouse_inventory(zone_id);
@@ -0,0 +1,5 @@
+-- Migration: 024_create_planograms.down.sql
+ALTER TABLE stock_movements DROP COLUMN IF EXISTS operation_id;
+ALTER TABLE vending_operations DROP COLUMN IF EXISTS warehouse_id;
+DROP TABLE IF EXISTS operation_items;
+DROP TABLE IF EXISTS machine_planogram;
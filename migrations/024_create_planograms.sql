@@ -0,0 +1,44 @@
+-- Migration: 024_create_planograms.sql
+-- Выкладка автоматов и пополнение по товарам. Выкладка (machine_planogram)
+-- перечисляет товары, которые загружаются в автомат, с целевым
+-- количеством и ценой игры. Пополнение записывает по каждому товару,
+-- сколько его было в автомате до загрузки и сколько добавлено
+-- (operation_items), с ценой игры на момент пополнения.
+--
+-- Продажи товара считаются между соседними пополнениями автомата:
+-- было после прошлого пополнения минус было до текущего. Товары, которых
+-- нет в одном из двух пополнений, в продажи не попадают.
+--
+-- Пополнение со склада (vending_operations.warehouse_id) выдает добавленное
+-- движениями restock, связанными с операцией (stock_movements.operation_id):
+-- остатки склада и автомата меняет одна запись. Правка операции доводит
+-- выданное до нового пополнения, удаление возвращает его на склад.
+
+CREATE TABLE IF NOT EXISTS machine_planogram (
+    id BIGSERIAL PRIMARY KEY,
+    machine_id BIGINT NOT NULL REFERENCES vending_machines(id) ON DELETE CASCADE,
+    product_id BIGINT NOT NULL REFERENCES products(id),
+    target_count INTEGER NOT NULL CHECK (target_count > 0),
+    price_per_play DECIMAL(10,2) NOT NULL DEFAULT 0 CHECK (price_per_play >= 0),
+    UNIQUE (machine_id, product_id)
+);
+
+CREATE TABLE IF NOT EXISTS operation_items (
+    id BIGSERIAL PRIMARY KEY,
+    operation_id BIGINT NOT NULL REFERENCES vending_operations(id) ON DELETE CASCADE,
+    product_id BIGINT NOT NULL REFERENCES products(id),
+    count_before INTEGER NOT NULL DEFAULT 0 CHECK (count_before >= 0),
+    added INTEGER NOT NULL DEFAULT 0 CHECK (added >= 0),
+    price_per_play DECIMAL(10,2) NOT NULL DEFAULT 0 CHECK (price_per_play >= 0),
+    UNIQUE (operation_id, product_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_machine_planogram_product ON machine_planogram(product_id);
+CREATE INDEX IF NOT EXISTS idx_operation_items_product ON operation_items(product_id);
+
+ALTER TABLE vending_operations
+    ADD COLUMN IF NOT EXISTS warehouse_id BIGINT REFERENCES warehouse(id) ON DELETE SET NULL;
+ALTER TABLE stock_movements
+    ADD COLUMN IF NOT EXISTS operation_id BIGINT REFERENCES vending_operations(id) ON DELETE SET NULL;
+
+CREATE INDEX IF NOT EXISTS idx_stock_movements_operation ON stock_movements(operation_id);
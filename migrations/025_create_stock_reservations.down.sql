@@ -0,0 +1,2 @@
+-- Migration: 025_create_stock_reservations.down.sql
+DROP TABLE IF EXISTS stock_reservations;
@@ -0,0 +1,27 @@
+-- Migration: 025_create_stock_reservations.sql
+-- Резервы товара под запланированные отгрузки и рейсы пополнения
+-- автоматов. Резерв откладывает часть остатка позиции: доступно для
+-- отгрузки, перемещения и новых резервов quantity позиции минус сумма
+-- действующих резервов. Действующий резерв — active с expires_at в
+-- будущем; истекший перестает учитываться без отдельной записи.
+--
+-- Отгрузка или пополнение, проведенные по резерву, расходуют его:
+-- status = consumed, movement_id — движение в stock_movements. Снятый
+-- вручную резерв получает status = released.
+
+CREATE TABLE IF NOT EXISTS stock_reservations (
+    id BIGSERIAL PRIMARY KEY,
+    item_id BIGINT NOT NULL REFERENCES warehouse_inventory(id) ON DELETE CASCADE,
+    reservation_type VARCHAR(20) NOT NULL CHECK (reservation_type IN ('shipment', 'restock')),
+    quantity INTEGER NOT NULL CHECK (quantity > 0),
+    -- Автомат пополнения; NULL — любой автомат рейса
+    vending_machine_id BIGINT REFERENCES vending_machines(id) ON DELETE CASCADE,
+    reason TEXT,
+    status VARCHAR(10) NOT NULL DEFAULT 'active' CHECK (status IN ('active', 'consumed', 'released')),
+    movement_id BIGINT REFERENCES stock_movements(id) ON DELETE SET NULL,
+    expires_at TIMESTAMP NOT NULL,
+    created_by BIGINT REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_stock_reservations_active ON stock_reservations(item_id, expires_at) WHERE status = 'active';
@@ -1,101 +1,769 @@
 package migrations
 
 import (
+    "context"
+    "crypto/sha256"
     "database/sql"
+    "encoding/hex"
     "fmt"
+    "io"
+    "io/fs"
     "log"
     "os"
     "path/filepath"
+    "regexp"
     "sort"
+    "strconv"
     "strings"
+    "time"
 )
 
+// Файлы миграций:
+//   NNN_name.sql или NNN_name.up.sql — применение
+//   NNN_name.down.sql                — откат (необязателен)
+// В schema_migrations.name записывается имя up-файла, в checksum — SHA-256
+// его содержимого.
+//
+// Миграция, которую нельзя выполнить в транзакции (например,
+// CREATE INDEX CONCURRENTLY), помечается строкой в начале файла:
+//   -- migrate:no-transaction
+// Такие файлы выполняются по одной команде; при ошибке часть команд
+// может остаться примененной.
+//
+// Если уже примененный файл переписан намеренно (например, из него убраны
+// демо-данные), в начале файла перечисляются прежние контрольные суммы:
+//   -- migrate:replaces-checksum <sha256>
+// Базы с такой суммой не считаются измененными, и сумма обновляется.
+const (
+    upSuffix   = ".up.sql"
+    downSuffix = ".down.sql"
+
+    noTransactionDirective    = "-- migrate:no-transaction"
+    replacesChecksumDirective = "-- migrate:replaces-checksum "
+)
+
+// lockKey — ключ pg_advisory_lock, общий для всех экземпляров приложения
+const lockKey int64 = 7305001
+
+// DriftError сообщает об измененных после применения файлах миграций.
+type DriftError struct {
+    Versions []string
+}
+
+func (e *DriftError) Error() string {
+    return fmt.Sprintf("applied migrations changed on disk: %s (restore the files, run `migrate accept <version>` or set MIGRATIONS_ALLOW_DRIFT=true)",
+        strings.Join(e.Versions, ", "))
+}
+
 type Migration struct {
     Version string
     Name    string
+    // DownName — имя файла отката, пустое, если его нет
+    DownName string
+    // Checksum — SHA-256 текущего содержимого up-файла
+    Checksum string
+    // Replaces — прежние суммы файла из -- migrate:replaces-checksum
+    Replaces []string
 }
 
-func RunMigrations(db *sql.DB, migrationsPath string) error {
-    // Create migrations table if it doesn't exist
-    if err := createMigrationsTable(db); err != nil {
-        return fmt.Errorf("error creating migrations table: %v", err)
+// replaced сообщает, что сумма относится к прежней версии файла.
+func (m Migration) replaced(sum string) bool {
+    for _, old := range m.Replaces {
+        if old == sum {
+            return true
+        }
     }
+    return false
+}
+
+// MigrationStatus — состояние миграции для команды status.
+type MigrationStatus struct {
+    Migration
+    Applied   bool
+    AppliedAt time.Time
+    // Missing — миграция записана в schema_migrations, но файла уже нет
+    Missing bool
+    // AppliedChecksum — контрольная сумма на момент применения; пустая
+    // у миграций, примененных до появления контрольных сумм
+    AppliedChecksum string
+    // Drift — файл изменился после применения
+    Drift bool
+}
+
+// Migrator применяет и откатывает миграции из files — встроенного FS
+// или os.DirFS каталога на диске. В режиме DryRun SQL только печатается
+// в Out, база и schema_migrations не меняются. AllowDrift разрешает
+// работать, если примененные файлы были изменены.
+type Migrator struct {
+    db         *sql.DB
+    files      fs.FS
+    DryRun     bool
+    AllowDrift bool
+    Out        io.Writer
+}
+
+func NewMigrator(db *sql.DB, files fs.FS) *Migrator {
+    return &Migrator{db: db, files: files, Out: os.Stdout}
+}
+
+func RunMigrations(db *sql.DB, files fs.FS) error {
+    return NewMigrator(db, files).Run()
+}
 
-    // Get applied migrations
-    applied, err := getAppliedMigrations(db)
+// Run применяет все ожидающие миграции при запуске сервера.
+func (m *Migrator) Run() error {
+    applied, err := m.Up(0)
     if err != nil {
-        return fmt.Errorf("error getting applied migrations: %v", err)
+        return err
     }
 
-    // Get available migrations
-    available, err := getAvailableMigrations(migrationsPath)
+    log.Printf("Migrations completed. %d migrations applied.", applied)
+    return nil
+}
+
+// withLock выполняет fn под pg_advisory_lock, чтобы несколько экземпляров,
+// запущенных одновременно, применяли миграции по очереди. Блокировка
+// держится на отдельном соединении и снимается при его закрытии.
+func (m *Migrator) withLock(fn func() error) error {
+    if m.DryRun {
+        return fn()
+    }
+
+    ctx := context.Background()
+    conn, err := m.db.Conn(ctx)
     if err != nil {
-        return fmt.Errorf("error getting available migrations: %v", err)
+        return err
     }
+    defer conn.Close()
 
-    // Run pending migrations
-    for _, migration := range available {
-        if _, exists := applied[migration.Version]; !exists {
-            if err := runMigration(db, migration, migrationsPath); err != nil {
-                return fmt.Errorf("error running migration %s: %v", migration.Version, err)
+    if _, err := conn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", lockKey); err != nil {
+        return fmt.Errorf("error acquiring migration lock: %v", err)
+    }
+    defer conn.ExecContext(ctx, "SELECT pg_advisory_unlock($1)", lockKey)
+
+    return fn()
+}
+
+// checkDrift останавливает работу, если примененные файлы изменились.
+// Миграциям без сохраненной суммы или с замененной прежней суммой
+// записывается текущая.
+func (m *Migrator) checkDrift(statuses []MigrationStatus) error {
+    var drifted []string
+    for _, status := range statuses {
+        if status.Drift {
+            drifted = append(drifted, status.Version)
+        }
+        outdated := status.AppliedChecksum == "" || status.replaced(status.AppliedChecksum)
+        if status.Applied && !status.Missing && outdated && !m.DryRun {
+            if _, err := m.db.Exec(
+                "UPDATE schema_migrations SET checksum = $1 WHERE version = $2",
+                status.Checksum, status.Version,
+            ); err != nil {
+                return err
             }
         }
     }
 
-    log.Printf("Migrations completed. %d migrations applied.", len(available))
+    if len(drifted) == 0 {
+        return nil
+    }
+    if m.AllowDrift {
+        log.Printf("WARNING: applied migrations changed on disk: %s", strings.Join(drifted, ", "))
+        return nil
+    }
+    return &DriftError{Versions: drifted}
+}
+
+// Accept записывает текущую контрольную сумму файла примененной миграции,
+// подтверждая, что изменение файла намеренное.
+func (m *Migrator) Accept(version string) error {
+    statuses, err := m.Status()
+    if err != nil {
+        return err
+    }
+
+    for _, status := range statuses {
+        if status.Version != version {
+            continue
+        }
+        if !status.Applied || status.Missing {
+            return fmt.Errorf("migration %s is not applied or its file is missing", version)
+        }
+        if m.DryRun {
+            fmt.Fprintf(m.Out, "UPDATE schema_migrations SET checksum = '%s' WHERE version = '%s';\n", status.Checksum, version)
+            return nil
+        }
+        _, err := m.db.Exec("UPDATE schema_migrations SET checksum = $1 WHERE version = $2", status.Checksum, version)
+        return err
+    }
+    return fmt.Errorf("unknown migration version %s", version)
+}
+
+// Status возвращает все известные миграции в порядке версий.
+func (m *Migrator) Status() ([]MigrationStatus, error) {
+    if err := createMigrationsTable(m.db); err != nil {
+        return nil, fmt.Errorf("error creating migrations table: %v", err)
+    }
+
+    applied, err := getAppliedMigrations(m.db)
+    if err != nil {
+        return nil, fmt.Errorf("error getting applied migrations: %v", err)
+    }
+
+    available, err := getAvailableMigrations(m.files)
+    if err != nil {
+        return nil, fmt.Errorf("error getting available migrations: %v", err)
+    }
+
+    var statuses []MigrationStatus
+    for _, migration := range available {
+        status := MigrationStatus{Migration: migration}
+        if record, ok := applied[migration.Version]; ok {
+            status.Applied = true
+            status.AppliedAt = record.AppliedAt
+            status.AppliedChecksum = record.Checksum
+            status.Drift = record.Checksum != "" && record.Checksum != migration.Checksum &&
+                !migration.replaced(record.Checksum)
+            delete(applied, migration.Version)
+        }
+        statuses = append(statuses, status)
+    }
+    for version, record := range applied {
+        statuses = append(statuses, MigrationStatus{
+            Migration: Migration{Version: version, Name: record.Name},
+            Applied:         true,
+            AppliedAt:       record.AppliedAt,
+            Missing:         true,
+            AppliedChecksum: record.Checksum,
+        })
+    }
+
+    sort.Slice(statuses, func(i, j int) bool {
+        return statuses[i].Version < statuses[j].Version
+    })
+    return statuses, nil
+}
+
+// Pending возвращает число еще не примененных миграций. В отличие от Status
+// ничего не создает в базе, поэтому подходит для проверки готовности.
+func (m *Migrator) Pending() (int, error) {
+    applied, err := getAppliedMigrations(m.db)
+    if err != nil {
+        return 0, fmt.Errorf("error getting applied migrations: %v", err)
+    }
+    available, err := getAvailableMigrations(m.files)
+    if err != nil {
+        return 0, fmt.Errorf("error getting available migrations: %v", err)
+    }
+
+    pending := 0
+    for _, migration := range available {
+        if _, ok := applied[migration.Version]; !ok {
+            pending++
+        }
+    }
+    return pending, nil
+}
+
+// Up применяет до n ожидающих миграций (все при n <= 0) и возвращает их число.
+func (m *Migrator) Up(n int) (count int, err error) {
+    err = m.withLock(func() error {
+        count, err = m.up(n)
+        return err
+    })
+    return count, err
+}
+
+func (m *Migrator) up(n int) (int, error) {
+    statuses, err := m.Status()
+    if err != nil {
+        return 0, err
+    }
+    if err := m.checkDrift(statuses); err != nil {
+        return 0, err
+    }
+
+    count := 0
+    for _, status := range statuses {
+        if status.Applied {
+            continue
+        }
+        if n > 0 && count >= n {
+            break
+        }
+        if err := m.apply(status.Migration); err != nil {
+            return count, fmt.Errorf("error running migration %s: %v", status.Version, err)
+        }
+        count++
+    }
+    return count, nil
+}
+
+// Down откатывает n последних примененных миграций.
+func (m *Migrator) Down(n int) (count int, err error) {
+    err = m.withLock(func() error {
+        count, err = m.down(n)
+        return err
+    })
+    return count, err
+}
+
+func (m *Migrator) down(n int) (int, error) {
+    statuses, err := m.Status()
+    if err != nil {
+        return 0, err
+    }
+    if err := m.checkDrift(statuses); err != nil {
+        return 0, err
+    }
+
+    count := 0
+    for i := len(statuses) - 1; i >= 0 && count < n; i-- {
+        status := statuses[i]
+        if !status.Applied {
+            continue
+        }
+        if status.Missing {
+            return count, fmt.Errorf("migration %s is applied but its file is missing", status.Version)
+        }
+        if err := m.revert(status.Migration); err != nil {
+            return count, fmt.Errorf("error reverting migration %s: %v", status.Version, err)
+        }
+        count++
+    }
+    return count, nil
+}
+
+// Redo откатывает и заново применяет последнюю миграцию.
+func (m *Migrator) Redo() error {
+    return m.withLock(m.redo)
+}
+
+func (m *Migrator) redo() error {
+    statuses, err := m.Status()
+    if err != nil {
+        return err
+    }
+    if err := m.checkDrift(statuses); err != nil {
+        return err
+    }
+
+    for i := len(statuses) - 1; i >= 0; i-- {
+        if !statuses[i].Applied {
+            continue
+        }
+        last := statuses[i]
+        if last.Missing {
+            return fmt.Errorf("migration %s is applied but its file is missing", last.Version)
+        }
+        if err := m.revert(last.Migration); err != nil {
+            return fmt.Errorf("error reverting migration %s: %v", last.Version, err)
+        }
+        if err := m.apply(last.Migration); err != nil {
+            return fmt.Errorf("error running migration %s: %v", last.Version, err)
+        }
+        return nil
+    }
+    return fmt.Errorf("no applied migrations")
+}
+
+// Force помечает миграции до version включительно примененными, а более
+// поздние — непримененными, не выполняя SQL. Нужна после ручного исправления
+// базы. Версия "0" очищает schema_migrations.
+func (m *Migrator) Force(version string) error {
+    return m.withLock(func() error {
+        return m.force(version)
+    })
+}
+
+func (m *Migrator) force(version string) error {
+    statuses, err := m.Status()
+    if err != nil {
+        return err
+    }
+
+    known := version == "0"
+    for _, status := range statuses {
+        if status.Version == version {
+            known = true
+        }
+    }
+    if !known {
+        return fmt.Errorf("unknown migration version %s", version)
+    }
+
+    var statements []string
+    var args [][]interface{}
+    for _, status := range statuses {
+        switch {
+        case status.Version <= version && version != "0" && !status.Applied:
+            statements = append(statements, "INSERT INTO schema_migrations (version, name, checksum) VALUES ($1, $2, $3)")
+            args = append(args, []interface{}{status.Version, status.Name, status.Checksum})
+        case (status.Version > version || version == "0") && status.Applied:
+            statements = append(statements, "DELETE FROM schema_migrations WHERE version = $1")
+            args = append(args, []interface{}{status.Version})
+        }
+    }
+
+    if m.DryRun {
+        for i, statement := range statements {
+            fmt.Fprintf(m.Out, "%s; -- %v\n", statement, args[i])
+        }
+        return nil
+    }
+
+    tx, err := m.db.Begin()
+    if err != nil {
+        return err
+    }
+    defer tx.Rollback()
+
+    for i, statement := range statements {
+        if _, err := tx.Exec(statement, args[i]...); err != nil {
+            return err
+        }
+    }
+    return tx.Commit()
+}
+
+func (m *Migrator) apply(migration Migration) error {
+    content, err := fs.ReadFile(m.files, migration.Name)
+    if err != nil {
+        return err
+    }
+
+    if m.DryRun {
+        fmt.Fprintf(m.Out, "-- up: %s\n%s\n\n", migration.Name, strings.TrimSpace(string(content)))
+        return nil
+    }
+
+    // Record migration
+    record := func(exec execer) error {
+        _, err := exec.Exec(
+            "INSERT INTO schema_migrations (version, name, checksum) VALUES ($1, $2, $3)",
+            migration.Version, migration.Name, migration.Checksum,
+        )
+        return err
+    }
+    if err := m.execute(migration.Name, string(content), record); err != nil {
+        return err
+    }
+
+    log.Printf("Applied migration: %s", migration.Name)
     return nil
 }
 
+func (m *Migrator) revert(migration Migration) error {
+    if migration.DownName == "" {
+        return fmt.Errorf("migration %s has no %s file", migration.Name, downSuffix)
+    }
+
+    content, err := fs.ReadFile(m.files, migration.DownName)
+    if err != nil {
+        return err
+    }
+
+    if m.DryRun {
+        fmt.Fprintf(m.Out, "-- down: %s\n%s\n\n", migration.DownName, strings.TrimSpace(string(content)))
+        return nil
+    }
+
+    record := func(exec execer) error {
+        _, err := exec.Exec("DELETE FROM schema_migrations WHERE version = $1", migration.Version)
+        return err
+    }
+    if err := m.execute(migration.DownName, string(content), record); err != nil {
+        return err
+    }
+
+    log.Printf("Reverted migration: %s", migration.Name)
+    return nil
+}
+
+type execer interface {
+    Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
+// execute выполняет SQL файла и record в одной транзакции, либо, для файлов
+// с -- migrate:no-transaction, по одной команде без транзакции.
+func (m *Migrator) execute(name, content string, record func(execer) error) error {
+    if !hasNoTransactionDirective(content) {
+        // Start transaction
+        tx, err := m.db.Begin()
+        if err != nil {
+            return err
+        }
+        defer tx.Rollback()
+
+        // Execute migration
+        if _, err := tx.Exec(content); err != nil {
+            return fmt.Errorf("error executing migration %s: %v", name, err)
+        }
+        if err := record(tx); err != nil {
+            return err
+        }
+        return tx.Commit()
+    }
+
+    // Несколько команд в одном запросе PostgreSQL выполняет в неявной
+    // транзакции, поэтому команды отправляются по одной
+    for i, statement := range splitStatements(content) {
+        if _, err := m.db.Exec(statement); err != nil {
+            return fmt.Errorf("error executing migration %s (statement %d): %v", name, i+1, err)
+        }
+    }
+    return record(m.db)
+}
+
+// headerComments возвращает строки комментариев до первой команды файла.
+func headerComments(content string) []string {
+    var lines []string
+    for _, line := range strings.Split(content, "\n") {
+        line = strings.TrimSpace(line)
+        if line == "" {
+            continue
+        }
+        if !strings.HasPrefix(line, "--") {
+            break
+        }
+        lines = append(lines, line)
+    }
+    return lines
+}
+
+func hasNoTransactionDirective(content string) bool {
+    for _, line := range headerComments(content) {
+        if line == noTransactionDirective {
+            return true
+        }
+    }
+    return false
+}
+
+func replacedChecksums(content string) []string {
+    var sums []string
+    for _, line := range headerComments(content) {
+        if strings.HasPrefix(line, replacesChecksumDirective) {
+            sums = append(sums, strings.TrimSpace(strings.TrimPrefix(line, replacesChecksumDirective)))
+        }
+    }
+    return sums
+}
+
+// splitStatements делит SQL на команды по «;» вне строк, идентификаторов
+// в кавычках, комментариев и $$-блоков.
+func splitStatements(content string) []string {
+    var statements []string
+    var current strings.Builder
+    flush := func() {
+        if statement := strings.TrimSpace(current.String()); statement != "" && !onlyComments(statement) {
+            statements = append(statements, statement)
+        }
+        current.Reset()
+    }
+
+    for i := 0; i < len(content); i++ {
+        c := content[i]
+        switch {
+        case c == '-' && i+1 < len(content) && content[i+1] == '-':
+            end := strings.IndexByte(content[i:], '\n')
+            if end < 0 {
+                end = len(content) - i
+            }
+            current.WriteString(content[i : i+end])
+            i += end - 1
+        case c == '/' && i+1 < len(content) && content[i+1] == '*':
+            end := strings.Index(content[i+2:], "*/")
+            if end < 0 {
+                end = len(content) - i - 4
+            }
+            current.WriteString(content[i : i+end+4])
+            i += end + 3
+        case c == '\'' || c == '"':
+            end := i + 1
+            for end < len(content) {
+                if content[end] == c {
+                    if end+1 < len(content) && content[end+1] == c {
+                        end += 2
+                        continue
+                    }
+                    break
+                }
+                end++
+            }
+            current.WriteString(content[i:min(end+1, len(content))])
+            i = end
+        case c == '$':
+            tag := dollarTag(content[i:])
+            if tag == "" {
+                current.WriteByte(c)
+                continue
+            }
+            end := strings.Index(content[i+len(tag):], tag)
+            if end < 0 {
+                current.WriteString(content[i:])
+                i = len(content)
+                continue
+            }
+            stop := i + len(tag) + end + len(tag)
+            current.WriteString(content[i:stop])
+            i = stop - 1
+        case c == ';':
+            flush()
+        default:
+            current.WriteByte(c)
+        }
+    }
+    flush()
+    return statements
+}
+
+// dollarTag возвращает открывающий тег вида $$ или $body$ в начале s.
+func dollarTag(s string) string {
+    for i := 1; i < len(s); i++ {
+        c := s[i]
+        if c == '$' {
+            return s[:i+1]
+        }
+        if !(c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || i > 1 && c >= '0' && c <= '9') {
+            return ""
+        }
+    }
+    return ""
+}
+
+func onlyComments(statement string) bool {
+    for _, line := range strings.Split(statement, "\n") {
+        if line = strings.TrimSpace(line); line != "" && !strings.HasPrefix(line, "--") {
+            return false
+        }
+    }
+    return true
+}
+
+// checksum считает SHA-256 содержимого без учета окончаний строк.
+func checksum(content []byte) string {
+    normalized := strings.ReplaceAll(string(content), "\r\n", "\n")
+    sum := sha256.Sum256([]byte(normalized))
+    return hex.EncodeToString(sum[:])
+}
+
+var migrationNamePattern = regexp.MustCompile(`^[a-z0-9_]+$`)
+
+// Create создает пару пустых файлов up/down со следующим номером версии
+// и возвращает их пути.
+func Create(migrationsPath, name string) (string, string, error) {
+    name = strings.ToLower(strings.ReplaceAll(strings.TrimSpace(name), "-", "_"))
+    if !migrationNamePattern.MatchString(name) {
+        return "", "", fmt.Errorf("invalid migration name %q: use letters, digits and underscores", name)
+    }
+
+    available, err := getAvailableMigrations(os.DirFS(migrationsPath))
+    if err != nil {
+        return "", "", err
+    }
+
+    next := 1
+    for _, migration := range available {
+        if number, err := strconv.Atoi(migration.Version); err == nil && number >= next {
+            next = number + 1
+        }
+    }
+
+    base := fmt.Sprintf("%03d_%s", next, name)
+    upPath := filepath.Join(migrationsPath, base+upSuffix)
+    downPath := filepath.Join(migrationsPath, base+downSuffix)
+
+    header := "-- Migration: %s\n"
+    if err := os.WriteFile(upPath, []byte(fmt.Sprintf(header, base+upSuffix)), 0644); err != nil {
+        return "", "", err
+    }
+    if err := os.WriteFile(downPath, []byte(fmt.Sprintf(header, base+downSuffix)), 0644); err != nil {
+        return "", "", err
+    }
+    return upPath, downPath, nil
+}
+
 func createMigrationsTable(db *sql.DB) error {
     query := `
         CREATE TABLE IF NOT EXISTS schema_migrations (
             version VARCHAR(255) PRIMARY KEY,
             name VARCHAR(255) NOT NULL,
             applied_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
-        )
+        );
+        ALTER TABLE schema_migrations ADD COLUMN IF NOT EXISTS checksum VARCHAR(64)
     `
     _, err := db.Exec(query)
     return err
 }
 
-func getAppliedMigrations(db *sql.DB) (map[string]bool, error) {
-    rows, err := db.Query("SELECT version FROM schema_migrations ORDER BY version")
+type appliedMigration struct {
+    Name      string
+    AppliedAt time.Time
+    Checksum  string
+}
+
+func getAppliedMigrations(db *sql.DB) (map[string]appliedMigration, error) {
+    rows, err := db.Query("SELECT version, name, applied_at, COALESCE(checksum, '') FROM schema_migrations ORDER BY version")
     if err != nil {
         return nil, err
     }
     defer rows.Close()
 
-    applied := make(map[string]bool)
+    applied := make(map[string]appliedMigration)
     for rows.Next() {
         var version string
-        if err := rows.Scan(&version); err != nil {
+        var record appliedMigration
+        var appliedAt sql.NullTime
+        if err := rows.Scan(&version, &record.Name, &appliedAt, &record.Checksum); err != nil {
             return nil, err
         }
-        applied[version] = true
+        record.AppliedAt = appliedAt.Time
+        applied[version] = record
     }
     return applied, nil
 }
 
-func getAvailableMigrations(migrationsPath string) ([]Migration, error) {
-    files, err := os.ReadDir(migrationsPath)
+func getAvailableMigrations(migrationsFS fs.FS) ([]Migration, error) {
+    files, err := fs.ReadDir(migrationsFS, ".")
     if err != nil {
         return nil, err
     }
 
+    downs := make(map[string]string)
     var migrations []Migration
     for _, file := range files {
-        if !file.IsDir() && strings.HasSuffix(file.Name(), ".sql") {
-            parts := strings.Split(file.Name(), "_")
-            if len(parts) > 0 {
-                migration := Migration{
-                    Version: parts[0],
-                    Name:    file.Name(),
-                }
-                migrations = append(migrations, migration)
+        name := file.Name()
+        if file.IsDir() || !strings.HasSuffix(name, ".sql") {
+            continue
+        }
+        if strings.HasSuffix(name, downSuffix) {
+            downs[strings.TrimSuffix(name, downSuffix)] = name
+            continue
+        }
+        parts := strings.Split(name, "_")
+        if len(parts) > 0 {
+            migration := Migration{
+                Version: parts[0],
+                Name:    name,
             }
+            migrations = append(migrations, migration)
         }
     }
 
+    for i := range migrations {
+        base := strings.TrimSuffix(migrations[i].Name, upSuffix)
+        base = strings.TrimSuffix(base, ".sql")
+        migrations[i].DownName = downs[base]
+
+        content, err := fs.ReadFile(migrationsFS, migrations[i].Name)
+        if err != nil {
+            return nil, err
+        }
+        migrations[i].Checksum = checksum(content)
+        migrations[i].Replaces = replacedChecksums(string(content))
+    }
+
     // Sort by version
     sort.Slice(migrations, func(i, j int) bool {
         return migrations[i].Version < migrations[j].Version
@@ -103,38 +771,3 @@ func getAvailableMigrations(migrationsPath string) ([]Migration, error) {
 
     return migrations, nil
 }
-
-func runMigration(db *sql.DB, migration Migration, migrationsPath string) error {
-    filePath := filepath.Join(migrationsPath, migration.Name)
-    content, err := os.ReadFile(filePath)
-    if err != nil {
-        return err
-    }
-
-    // Start transaction
-    tx, err := db.Begin()
-    if err != nil {
-        return err
-    }
-    defer tx.Rollback()
-
-    // Execute migration
-    if _, err := tx.Exec(string(content)); err != nil {
-        return fmt.Errorf("error executing migration %s: %v", migration.Name, err)
-    }
-
-    // Record migration
-    if _, err := tx.Exec(
-        "INSERT INTO schema_migrations (version, name) VALUES ($1, $2)",
-        migration.Version, migration.Name,
-    ); err != nil {
-        return err
-    }
-
-    if err := tx.Commit(); err != nil {
-        return err
-    }
-
-    log.Printf("Applied migration: %s", migration.Name)
-    return nil
-}
\ No newline at end of file
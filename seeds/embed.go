@@ -0,0 +1,8 @@
+package seeds
+
+import "embed"
+
+// FS — SQL-файлы сидов, встроенные в бинарник.
+//
+//go:embed sql/*.sql
+var FS embed.FS
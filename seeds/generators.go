@@ -0,0 +1,124 @@
+package seeds
+
+import (
+    "database/sql"
+    "fmt"
+    "math/rand"
+    "time"
+
+    "golang.org/x/crypto/bcrypt"
+)
+
+const (
+    devPassword  = "dev-password-1"
+    testPassword = "test-password-1"
+)
+
+// randomizeDates разносит created_at демо-записей по последним 30 дням,
+// как cmd/randomize. Случайность зависит только от id записи, поэтому
+// повторный запуск дает те же даты.
+func randomizeDates(tx *sql.Tx, orgID int64) error {
+    tables := []string{"locations", "users", "vending_machines", "vending_operations", "warehouse"}
+
+    now := time.Now()
+    start := now.AddDate(0, 0, -30)
+    window := int64(now.Sub(start).Seconds())
+
+    for _, table := range tables {
+        rows, err := tx.Query(fmt.Sprintf("SELECT id FROM %s WHERE org_id = $1 ORDER BY id", table), orgID)
+        if err != nil {
+            return fmt.Errorf("error querying %s: %w", table, err)
+        }
+        var ids []int64
+        for rows.Next() {
+            var id int64
+            if err := rows.Scan(&id); err != nil {
+                rows.Close()
+                return err
+            }
+            ids = append(ids, id)
+        }
+        rows.Close()
+        if err := rows.Err(); err != nil {
+            return err
+        }
+
+        for _, id := range ids {
+            rnd := rand.New(rand.NewSource(id))
+            createdAt := start.Add(time.Duration(rnd.Int63n(window)) * time.Second)
+            updatedAt := createdAt
+            // С вероятностью 30% запись менялась позже, до суток после создания
+            if rnd.Intn(100) < 30 {
+                updatedAt = createdAt.Add(time.Duration(rnd.Intn(86400)) * time.Second)
+            }
+
+            _, err := tx.Exec(fmt.Sprintf("UPDATE %s SET created_at = $1, updated_at = $2 WHERE id = $3", table),
+                createdAt, updatedAt, id)
+            if err != nil {
+                return fmt.Errorf("error updating %s id=%d: %w", table, id, err)
+            }
+        }
+    }
+    return nil
+}
+
+// devPasswords задает демо-пользователям организации общий известный пароль,
+// чтобы под ними можно было войти при разработке.
+func devPasswords(tx *sql.Tx, orgID int64) error {
+    hash, err := bcrypt.GenerateFromPassword([]byte(devPassword), bcrypt.DefaultCost)
+    if err != nil {
+        return err
+    }
+    _, err = tx.Exec(`
+        UPDATE users SET password = $1, must_change_password = false, password_changed_at = CURRENT_TIMESTAMP
+        WHERE org_id = $2 AND email LIKE '%@testsystem.ru'
+    `, string(hash), orgID)
+    return err
+}
+
+// testUsers создает по пользователю на каждую роль с паролем testPassword.
+// Имена совпадают с демо-данными, на которые ссылаются fleet.sql и warehouse.sql.
+func testUsers(tx *sql.Tx, orgID int64) error {
+    hash, err := bcrypt.GenerateFromPassword([]byte(testPassword), bcrypt.DefaultCost)
+    if err != nil {
+        return err
+    }
+
+    users := []struct {
+        username, role, fullName string
+    }{
+        {"admin", "admin", "Тестовый администратор"},
+        {"moderator", "moderator", "Тестовый модератор"},
+        {"agent", "agent", "Тестовый агент"},
+        {"support", "support", "Тестовая поддержка"},
+        {"partner", "partner", "Тестовый партнер"},
+        {"monitor", "monitor", "Тестовый монитор"},
+        {"user", "user", "Тестовый пользователь"},
+        {"operator1", "operator", "Тестовый оператор"},
+        {"tech1", "technician", "Тестовый техник"},
+        {"courier1", "courier", "Тестовый курьер"},
+    }
+
+    for _, u := range users {
+        _, err := tx.Exec(`
+            INSERT INTO users (org_id, username, email, userrole, status, password, fullusername,
+                               companyname, is_superadmin, password_changed_at)
+            SELECT $1, $2, $3, $4, 1, $5, $6, 'Test System', $4 = 'admin', CURRENT_TIMESTAMP
+            WHERE NOT EXISTS (SELECT 1 FROM users WHERE username = $2)
+        `, orgID, u.username, u.username+"@test.local", u.role, string(hash), u.fullName)
+        if err != nil {
+            return fmt.Errorf("user %s: %w", u.username, err)
+        }
+    }
+    return nil
+}
+
+// memberships добавляет созданных в организации пользователей в ее участники.
+func memberships(tx *sql.Tx, orgID int64) error {
+    _, err := tx.Exec(`
+        INSERT INTO user_organizations (user_id, org_id)
+        SELECT id, org_id FROM users WHERE org_id = $1
+        ON CONFLICT DO NOTHING
+    `, orgID)
+    return err
+}
@@ -0,0 +1,46 @@
+package seeds
+
+// Шаги, из которых собираются профили. Демо-данные перенесены сюда
+// из миграций 006, 008, 010 и 011.
+var (
+    stepUsers     = Step{Name: "users", File: "users.sql"}
+    stepFleet     = Step{Name: "fleet", File: "fleet.sql"}
+    stepWarehouse = Step{Name: "warehouse", File: "warehouse.sql"}
+    stepNetwork   = Step{Name: "network", File: "network.sql"}
+    stepMachines  = Step{Name: "machines", File: "machines.sql"}
+    stepPlanogram = Step{Name: "planogram", File: "planogram.sql"}
+
+    stepRandomizeDates = Step{Name: "randomize_dates", Run: randomizeDates}
+    stepDevPasswords   = Step{Name: "dev_passwords", Run: devPasswords}
+    stepTestUsers      = Step{Name: "test_users", Run: testUsers}
+    stepMemberships    = Step{Name: "memberships", Run: memberships, Always: true}
+)
+
+var profiles = map[string]Profile{
+    "empty": {
+        Name:        "empty",
+        Description: "без данных — для production",
+    },
+    "demo": {
+        Name:        "demo",
+        Description: "полный набор демо-данных с датами за последний месяц",
+        Steps: []Step{
+            stepUsers, stepFleet, stepWarehouse, stepNetwork, stepMachines, stepPlanogram,
+            stepRandomizeDates, stepMemberships,
+        },
+    },
+    "dev": {
+        Name:        "dev",
+        Description: "небольшой набор данных; пароль демо-пользователей — " + devPassword,
+        Steps: []Step{
+            stepUsers, stepFleet, stepWarehouse, stepPlanogram, stepDevPasswords, stepMemberships,
+        },
+    },
+    "test": {
+        Name:        "test",
+        Description: "по пользователю на каждую роль с паролем " + testPassword + " и минимум данных",
+        Steps: []Step{
+            stepTestUsers, stepFleet, stepWarehouse, stepPlanogram, stepMemberships,
+        },
+    },
+}
@@ -0,0 +1,160 @@
+// Package seeds загружает тестовые и демонстрационные данные. В отличие
+// от миграций, сиды не применяются автоматически: профиль выбирается
+// явно командой `go run ./cmd/seed <profile>` или переменной SEED_PROFILE.
+//
+// Профиль — упорядоченный список шагов. Шаг — SQL-файл из seeds/sql
+// или функция-генератор на Go. Выполненные шаги записываются в seed_runs
+// и при повторном запуске пропускаются, поэтому профили можно запускать
+// сколько угодно раз. Данные создаются в выбранной организации: ее id
+// доступен SQL-файлам как current_setting('seed.org_id').
+package seeds
+
+import (
+    "database/sql"
+    "fmt"
+    "io/fs"
+    "log"
+    "sort"
+    "strconv"
+)
+
+// Generator создает данные кодом, например со случайными значениями.
+type Generator func(tx *sql.Tx, orgID int64) error
+
+type Step struct {
+    // Name — ключ шага в seed_runs, общий для всех профилей
+    Name string
+    // File — SQL-файл в каталоге сидов; пустой, если задан Run
+    File string
+    Run  Generator
+    // Always — шаг выполняется при каждом запуске (должен быть идемпотентным)
+    Always bool
+}
+
+type Profile struct {
+    Name        string
+    Description string
+    Steps       []Step
+}
+
+// Lookup возвращает профиль по имени.
+func Lookup(name string) (Profile, bool) {
+    profile, ok := profiles[name]
+    return profile, ok
+}
+
+// Profiles возвращает все профили в алфавитном порядке.
+func Profiles() []Profile {
+    var list []Profile
+    for _, profile := range profiles {
+        list = append(list, profile)
+    }
+    sort.Slice(list, func(i, j int) bool {
+        return list[i].Name < list[j].Name
+    })
+    return list
+}
+
+// Seeder читает SQL-файлы из files — встроенного FS или os.DirFS
+// каталога seeds на диске.
+type Seeder struct {
+    db    *sql.DB
+    files fs.FS
+}
+
+func NewSeeder(db *sql.DB, files fs.FS) *Seeder {
+    return &Seeder{db: db, files: files}
+}
+
+// Run загружает профиль в организацию с указанным slug и возвращает число
+// выполненных шагов.
+func (s *Seeder) Run(profileName, orgSlug string) (int, error) {
+    profile, ok := Lookup(profileName)
+    if !ok {
+        return 0, fmt.Errorf("unknown seed profile %q", profileName)
+    }
+
+    var orgID int64
+    err := s.db.QueryRow("SELECT id FROM organizations WHERE slug = $1", orgSlug).Scan(&orgID)
+    if err == sql.ErrNoRows {
+        return 0, fmt.Errorf("organization %q not found", orgSlug)
+    }
+    if err != nil {
+        return 0, err
+    }
+
+    done, err := s.completedSteps()
+    if err != nil {
+        return 0, fmt.Errorf("error reading seed_runs: %v", err)
+    }
+
+    count := 0
+    for _, step := range profile.Steps {
+        if done[step.Name] && !step.Always {
+            continue
+        }
+        if err := s.runStep(profile.Name, step, orgID); err != nil {
+            return count, fmt.Errorf("seed step %s: %v", step.Name, err)
+        }
+        log.Printf("Seeded: %s", step.Name)
+        count++
+    }
+    return count, nil
+}
+
+func (s *Seeder) runStep(profile string, step Step, orgID int64) error {
+    tx, err := s.db.Begin()
+    if err != nil {
+        return err
+    }
+    defer tx.Rollback()
+
+    // SQL-файлы берут организацию из настройки транзакции
+    if _, err := tx.Exec("SELECT set_config('seed.org_id', $1, true)", strconv.FormatInt(orgID, 10)); err != nil {
+        return err
+    }
+
+    if step.File != "" {
+        content, err := fs.ReadFile(s.files, "sql/"+step.File)
+        if err != nil {
+            return err
+        }
+        if _, err := tx.Exec(string(content)); err != nil {
+            return err
+        }
+    }
+    if step.Run != nil {
+        if err := step.Run(tx, orgID); err != nil {
+            return err
+        }
+    }
+
+    if !step.Always {
+        _, err = tx.Exec(`
+            INSERT INTO seed_runs (name, profile, org_id) VALUES ($1, $2, $3)
+            ON CONFLICT (name) DO NOTHING
+        `, step.Name, profile, orgID)
+        if err != nil {
+            return err
+        }
+    }
+    return tx.Commit()
+}
+
+func (s *Seeder) completedSteps() (map[string]bool, error) {
+    rows, err := s.db.Query("SELECT name FROM seed_runs")
+    if err != nil {
+        return nil, err
+    }
+    defer rows.Close()
+
+    done := make(map[string]bool)
+    for rows.Next() {
+        var name string
+        if err := rows.Scan(&name); err != nil {
+            return nil, err
+        }
+        done[name] = true
+    }
+    return done, rows.Err()
+}
@@ -0,0 +1,94 @@
+-- Первые локации, автоматы и операции (бывшая миграция 006)
+
+-- 1. Вставляем локации (только если не существуют)
+INSERT INTO locations (org_id, name, address, contact_person, contact_phone, monthly_rent, rent_due_day) 
+SELECT current_setting('seed.org_id')::bigint, 'ТЦ "Москва"', 'ул. Ленина, 1', 'Иванов Иван', '+7-999-123-45-67', 15000.00, 15
+WHERE NOT EXISTS (SELECT 1 FROM locations WHERE name = 'ТЦ "Москва"');
+
+INSERT INTO locations (org_id, name, address, contact_person, contact_phone, monthly_rent, rent_due_day) 
+SELECT current_setting('seed.org_id')::bigint, 'ТРК "Европа"', 'пр. Мира, 25', 'Петрова Мария', '+7-999-765-43-21', 20000.00, 10
+WHERE NOT EXISTS (SELECT 1 FROM locations WHERE name = 'ТРК "Европа"');
+
+INSERT INTO locations (org_id, name, address, contact_person, contact_phone, monthly_rent, rent_due_day) 
+SELECT current_setting('seed.org_id')::bigint, 'Аэропорт', 'ш. Аэропортовское, 10', 'Сидоров Алексей', '+7-999-555-44-33', 30000.00, 5
+WHERE NOT EXISTS (SELECT 1 FROM locations WHERE name = 'Аэропорт');
+
+-- 2. Вставляем вендинговые автоматы (только если не существуют)
+INSERT INTO vending_machines (org_id, serial_number, model, status, location_id, current_toys_count, capacity_toys, cash_amount, installation_date, last_maintenance_date, next_maintenance_date) 
+SELECT current_setting('seed.org_id')::bigint, 'VM001', 'ToyMaster 3000', 'active', id, 45, 100, 1500.00, '2024-01-01', '2024-01-10', '2024-02-10'
+FROM locations WHERE name = 'ТЦ "Москва"' 
+AND NOT EXISTS (SELECT 1 FROM vending_machines WHERE serial_number = 'VM001')
+LIMIT 1;
+
+INSERT INTO vending_machines (org_id, serial_number, model, status, location_id, current_toys_count, capacity_toys, cash_amount, installation_date, last_maintenance_date, next_maintenance_date) 
+SELECT current_setting('seed.org_id')::bigint, 'VM002', 'ToyMaster 3000', 'active', id, 38, 100, 2300.00, '2024-01-02', '2024-01-11', '2024-02-11'
+FROM locations WHERE name = 'Аэропорт' 
+AND NOT EXISTS (SELECT 1 FROM vending_machines WHERE serial_number = 'VM002')
+LIMIT 1;
+
+INSERT INTO vending_machines (org_id, serial_number, model, status, location_id, current_toys_count, capacity_toys, cash_amount, installation_date, last_maintenance_date, next_maintenance_date) 
+SELECT current_setting('seed.org_id')::bigint, 'VM003', 'ToyMaster 2000', 'active', id, 22, 80, 1800.00, '2024-01-03', '2024-01-12', '2024-02-12'
+FROM locations WHERE name = 'ТРК "Европа"' 
+AND NOT EXISTS (SELECT 1 FROM vending_machines WHERE serial_number = 'VM003')
+LIMIT 1;
+
+INSERT INTO vending_machines (org_id, serial_number, model, status, location_id, current_toys_count, capacity_toys, cash_amount, installation_date) 
+SELECT current_setting('seed.org_id')::bigint, 'VM004', 'ToyMaster 3000', 'maintenance', id, 0, 100, 0, '2024-01-04'
+FROM locations WHERE name = 'ТРК "Европа"' 
+AND NOT EXISTS (SELECT 1 FROM vending_machines WHERE serial_number = 'VM004')
+LIMIT 1;
+
+INSERT INTO vending_machines (org_id, serial_number, model, status, location_id, current_toys_count, capacity_toys, cash_amount, installation_date, last_maintenance_date, next_maintenance_date) 
+SELECT current_setting('seed.org_id')::bigint, 'VM005', 'ToyMaster 2000', 'active', id, 65, 80, 3150.00, '2024-01-05', '2024-01-13', '2024-02-13'
+FROM locations WHERE name = 'ТЦ "Москва"' 
+AND NOT EXISTS (SELECT 1 FROM vending_machines WHERE serial_number = 'VM005')
+LIMIT 1;
+
+-- 3. Вставляем операции (только если не существуют для избежания дубликатов)
+INSERT INTO vending_operations (org_id, vending_machine_id, operation_type, performed_by, operation_date, toys_before, toys_after, toys_added, cash_before, cash_after, cash_collected, notes)
+SELECT current_setting('seed.org_id')::bigint, 1, 'restock', id, '2024-01-15 09:00:00', 10, 60, 50, 1500.00, 1500.00, 0, 'Пополнение игрушек'
+FROM users WHERE username = 'admin' 
+AND NOT EXISTS (SELECT 1 FROM vending_operations WHERE vending_machine_id = 1 AND operation_type = 'restock' AND operation_date = '2024-01-15 09:00:00')
+LIMIT 1;
+
+INSERT INTO vending_operations (org_id, vending_machine_id, operation_type, performed_by, operation_date, toys_before, toys_after, toys_added, cash_before, cash_after, cash_collected, notes)
+SELECT current_setting('seed.org_id')::bigint, 2, 'restock', id, '2024-01-15 10:30:00', 15, 65, 50, 2300.00, 2300.00, 0, 'Пополнение игрушек'
+FROM users WHERE username = 'admin' 
+AND NOT EXISTS (SELECT 1 FROM vending_operations WHERE vending_machine_id = 2 AND operation_type = 'restock' AND operation_date = '2024-01-15 10:30:00')
+LIMIT 1;
+
+INSERT INTO vending_operations (org_id, vending_machine_id, operation_type, performed_by, operation_date, toys_before, toys_after, toys_added, cash_before, cash_after, cash_collected, notes)
+SELECT current_setting('seed.org_id')::bigint, 3, 'restock', id, '2024-01-15 11:15:00', 8, 58, 50, 1800.00, 1800.00, 0, 'Пополнение игрушек'
+FROM users WHERE username = 'operator1' 
+AND NOT EXISTS (SELECT 1 FROM vending_operations WHERE vending_machine_id = 3 AND operation_type = 'restock' AND operation_date = '2024-01-15 11:15:00')
+LIMIT 1;
+
+INSERT INTO vending_operations (org_id, vending_machine_id, operation_type, performed_by, operation_date, toys_before, toys_after, toys_added, cash_before, cash_after, cash_collected, notes)
+SELECT current_setting('seed.org_id')::bigint, 1, 'collection', id, '2024-01-14 16:00:00', 25, 25, 0, 3200.00, 200.00, 3000.00, 'Инкассация денежных средств'
+FROM users WHERE username = 'admin' 
+AND NOT EXISTS (SELECT 1 FROM vending_operations WHERE vending_machine_id = 1 AND operation_type = 'collection' AND operation_date = '2024-01-14 16:00:00')
+LIMIT 1;
+
+INSERT INTO vending_operations (org_id, vending_machine_id, operation_type, performed_by, operation_date, toys_before, toys_after, toys_added, cash_before, cash_after, cash_collected, notes)
+SELECT current_setting('seed.org_id')::bigint, 2, 'collection', id, '2024-01-14 16:30:00', 30, 30, 0, 2850.00, 350.00, 2500.00, 'Инкассация денежных средств'
+FROM users WHERE username = 'admin' 
+AND NOT EXISTS (SELECT 1 FROM vending_operations WHERE vending_machine_id = 2 AND operation_type = 'collection' AND operation_date = '2024-01-14 16:30:00')
+LIMIT 1;
+
+INSERT INTO vending_operations (org_id, vending_machine_id, operation_type, performed_by, operation_date, toys_before, toys_after, toys_added, cash_before, cash_after, cash_collected, notes)
+SELECT current_setting('seed.org_id')::bigint, 3, 'collection', id, '2024-01-14 17:00:00', 22, 22, 0, 1950.00, 150.00, 1800.00, 'Инкассация денежных средств'
+FROM users WHERE username = 'operator1' 
+AND NOT EXISTS (SELECT 1 FROM vending_operations WHERE vending_machine_id = 3 AND operation_type = 'collection' AND operation_date = '2024-01-14 17:00:00')
+LIMIT 1;
+
+INSERT INTO vending_operations (org_id, vending_machine_id, operation_type, performed_by, operation_date, toys_before, toys_after, toys_added, cash_before, cash_after, cash_collected, notes)
+SELECT current_setting('seed.org_id')::bigint, 1, 'maintenance', id, '2024-01-10 14:00:00', 45, 45, 0, 1500.00, 1500.00, 0, 'Плановое техническое обслуживание'
+FROM users WHERE username = 'tech1' 
+AND NOT EXISTS (SELECT 1 FROM vending_operations WHERE vending_machine_id = 1 AND operation_type = 'maintenance' AND operation_date = '2024-01-10 14:00:00')
+LIMIT 1;
+
+INSERT INTO vending_operations (org_id, vending_machine_id, operation_type, performed_by, operation_date, toys_before, toys_after, toys_added, cash_before, cash_after, cash_collected, notes)
+SELECT current_setting('seed.org_id')::bigint, 2, 'maintenance', id, '2024-01-11 15:30:00', 38, 38, 0, 2300.00, 2300.00, 0, 'Плановое техническое обслуживание'
+FROM users WHERE username = 'tech1' 
+AND NOT EXISTS (SELECT 1 FROM vending_operations WHERE vending_machine_id = 2 AND operation_type = 'maintenance' AND operation_date = '2024-01-11 15:30:00')
+LIMIT 1;
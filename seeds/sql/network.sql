@@ -0,0 +1,170 @@
+-- Дополнительные склады, 40 локаций и распределение товаров (бывшая миграция 010)
+
+-- 1. Добавляем 3 дополнительных склада
+DO $$
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM warehouse WHERE name = 'Склад "Северный"') THEN
+        INSERT INTO warehouse (org_id, name, address, contact_person, contact_phone, total_capacity, current_usage) VALUES
+        (current_setting('seed.org_id')::bigint, 'Склад "Северный"', 'ул. Северная, 25, Москва', 'Петров Сергей', '+7-999-222-33-44', 3000, 0);
+    END IF;
+
+    IF NOT EXISTS (SELECT 1 FROM warehouse WHERE name = 'Склад "Западный"') THEN
+        INSERT INTO warehouse (org_id, name, address, contact_person, contact_phone, total_capacity, current_usage) VALUES
+        (current_setting('seed.org_id')::bigint, 'Склад "Западный"', 'ул. Западная, 10, Москва', 'Козлова Ольга', '+7-999-333-44-55', 4000, 0);
+    END IF;
+
+    IF NOT EXISTS (SELECT 1 FROM warehouse WHERE name = 'Склад "Центральный"') THEN
+        INSERT INTO warehouse (org_id, name, address, contact_person, contact_phone, total_capacity, current_usage) VALUES
+        (current_setting('seed.org_id')::bigint, 'Склад "Центральный"', 'ул. Центральная, 5, Москва', 'Николаев Дмитрий', '+7-999-444-55-66', 6000, 0);
+    END IF;
+END $$;
+
+-- 3. Добавляем 40 локаций
+DO $$
+DECLARE
+    location_record RECORD;
+BEGIN
+    FOR location_record IN (
+        SELECT * FROM (VALUES
+            ('ТЦ "Авиапарк"', 'Ходынский бульвар, 4, Москва', 'Семенов А.В.', '+7-999-100-01-01', 25000.00, 5),
+            ('ТРЦ "РИО"', 'Дмитровское ш., 163А, Москва', 'Калинина М.С.', '+7-999-100-01-02', 18000.00, 10),
+            ('ТЦ "МЕГА"', 'Коровинское ш., 10, Москва', 'Орлов Д.Н.', '+7-999-100-01-03', 22000.00, 15),
+            ('ТЦ "Весна"', 'ул. Пушкина, 35, Москва', 'Волкова Т.П.', '+7-999-100-01-04', 12000.00, 20),
+            ('ТРК "Континент"', 'ул. Ленина, 87, Москва', 'Жуков Р.А.', '+7-999-100-01-05', 19000.00, 25),
+            ('ТЦ "Октябрь"', 'пр. Мира, 124, Москва', 'Лебедева О.И.', '+7-999-100-01-06', 15000.00, 12),
+            ('ТРЦ "Галерея"', 'ул. Советская, 56, Москва', 'Новиков С.М.', '+7-999-100-01-07', 28000.00, 8),
+            ('ТЦ "Парус"', 'ул. Гагарина, 23, Москва', 'Федорова Е.В.', '+7-999-100-01-08', 16000.00, 18),
+            ('ТРК "Москва"', 'ул. Тверская, 15, Москва', 'Дмитриев П.К.', '+7-999-100-01-09', 32000.00, 7),
+            ('ТЦ "Заря"', 'ул. Кирова, 42, Москва', 'Соколова А.М.', '+7-999-100-01-10', 14000.00, 22),
+            ('ТРЦ "Небо"', 'пр. Победы, 67, Москва', 'Комаров В.С.', '+7-999-100-01-11', 26000.00, 9),
+            ('ТЦ "Восток"', 'ул. Садовая, 18, Москва', 'Егорова Л.Д.', '+7-999-100-01-12', 13000.00, 17),
+            ('ТРК "Юг"', 'ул. Центральная, 91, Москва', 'Григорьев И.А.', '+7-999-100-01-13', 17000.00, 14),
+            ('ТЦ "Север"', 'ул. Лесная, 29, Москва', 'Тихонова М.В.', '+7-999-100-01-14', 14500.00, 19),
+            ('ТРЦ "Запад"', 'ул. Школьная, 54, Москва', 'Фролов А.Н.', '+7-999-100-01-15', 21000.00, 11),
+            ('ТЦ "Лукоморье"', 'ул. Парковая, 33, Москва', 'Мартынова С.П.', '+7-999-100-01-16', 12500.00, 23),
+            ('ТРК "Атриум"', 'ул. Новая, 76, Москва', 'Белов К.Д.', '+7-999-100-01-17', 24000.00, 6),
+            ('ТЦ "Радуга"', 'ул. Строителей, 48, Москва', 'Крылова Т.С.', '+7-999-100-01-18', 15500.00, 16),
+            ('ТРЦ "Планета"', 'ул. Мира, 112, Москва', 'Сорокин М.А.', '+7-999-100-01-19', 19500.00, 13),
+            ('ТЦ "Орион"', 'ул. Звездная, 25, Москва', 'Воронова Е.Н.', '+7-999-100-01-20', 13500.00, 21),
+            ('ТРК "Глобус"', 'ул. Интернациональная, 39, Москва', 'Лазарев Д.В.', '+7-999-100-02-01', 22500.00, 8),
+            ('ТЦ "Феникс"', 'ул. Возрождения, 17, Москва', 'Медведева О.С.', '+7-999-100-02-02', 16500.00, 15),
+            ('ТРЦ "Высота"', 'ул. Горная, 63, Москва', 'Савельев Р.П.', '+7-999-100-02-03', 27500.00, 5),
+            ('ТЦ "Волна"', 'ул. Речная, 28, Москва', 'Гусева А.К.', '+7-999-100-02-04', 14200.00, 20),
+            ('ТРК "Энергия"', 'ул. Энергетиков, 45, Москва', 'Тарасов В.М.', '+7-999-100-02-05', 18800.00, 12),
+            ('ТЦ "Спутник"', 'ул. Космонавтов, 31, Москва', 'Комарова Л.В.', '+7-999-100-02-06', 15200.00, 18),
+            ('ТРЦ "Меридиан"', 'ул. Параллельная, 52, Москва', 'Ефимов С.Н.', '+7-999-100-02-07', 23200.00, 9),
+            ('ТЦ "Альфа"', 'ул. Бета, 27, Москва', 'Одинцова М.Д.', '+7-999-100-02-08', 13800.00, 22),
+            ('ТРК "Омега"', 'ул. Гамма, 34, Москва', 'Власов П.С.', '+7-999-100-02-09', 20200.00, 11),
+            ('ТЦ "Кварц"', 'ул. Гранитная, 41, Москва', 'Маслова Т.А.', '+7-999-100-02-10', 14800.00, 19),
+            ('ТРЦ "Кристалл"', 'ул. Алмазная, 58, Москва', 'Исаев А.В.', '+7-999-100-02-11', 24200.00, 7),
+            ('ТЦ "Рубин"', 'ул. Сапфировая, 22, Москва', 'Суханова Е.П.', '+7-999-100-02-12', 12800.00, 24),
+            ('ТРК "Изумруд"', 'ул. Изумрудная, 47, Москва', 'Горбунов Д.М.', '+7-999-100-02-13', 19200.00, 14),
+            ('ТЦ "Бриллиант"', 'ул. Бриллиантовая, 36, Москва', 'Зайцева С.В.', '+7-999-100-02-14', 26200.00, 6),
+            ('ТРЦ "Платина"', 'ул. Платиновая, 29, Москва', 'Семенов К.А.', '+7-999-100-02-15', 17200.00, 16),
+            ('ТЦ "Золото"', 'ул. Золотая, 44, Москва', 'Кузнецова Н.С.', '+7-999-100-02-16', 18200.00, 13),
+            ('ТРК "Серебро"', 'ул. Серебряная, 51, Москва', 'Виноградов М.П.', '+7-999-100-02-17', 21200.00, 10),
+            ('ТЦ "Бронза"', 'ул. Бронзовая, 38, Москва', 'Давыдова О.Н.', '+7-999-100-02-18', 13200.00, 21),
+            ('ТРК "Металл"', 'ул. Металлистов, 55, Москва', 'Журавлев С.Д.', '+7-999-100-02-19', 19800.00, 17),
+            ('ТЦ "Сталь"', 'ул. Сталеваров, 42, Москва', 'Носова Т.К.', '+7-999-100-02-20', 14200.00, 23)
+        ) AS loc(name, address, contact_person, contact_phone, monthly_rent, rent_due_day)
+    ) LOOP
+        IF NOT EXISTS (SELECT 1 FROM locations WHERE name = location_record.name) THEN
+            INSERT INTO locations (org_id, name, address, contact_person, contact_phone, monthly_rent, rent_due_day) 
+            VALUES (current_setting('seed.org_id')::bigint, location_record.name, location_record.address, location_record.contact_person, location_record.contact_phone, location_record.monthly_rent, location_record.rent_due_day);
+        END IF;
+    END LOOP;
+END $$;
+
+-- 4. Распределяем товары по складам в реальной пропорции (по 1 единице каждого товара).
+-- На основном складе все товары справочника уже лежат (warehouse.sql)
+INSERT INTO warehouse_inventory (warehouse_id, product_id, quantity, min_stock_level, max_stock_level)
+SELECT w.id, p.id, 1,
+       CASE WHEN p.item_type = 'vending_machine' THEN 1 ELSE 5 END,
+       CASE WHEN p.item_type = 'vending_machine' THEN 5 ELSE 50 END
+FROM warehouse w
+JOIN products p ON p.org_id = w.org_id
+WHERE w.org_id = current_setting('seed.org_id')::bigint
+  AND w.name IN ('Склад "Северный"', 'Склад "Западный"', 'Склад "Центральный"')
+ON CONFLICT ON CONSTRAINT warehouse_inventory_warehouse_product_key DO NOTHING;
+
+-- 5. Убираем секцию с денежными средствами, так как тип 'cash' недопустим
+-- Вместо этого добавим дополнительную категорию для аксессуаров
+
+-- 6. Обновляем текущее использование всех складов по объему товаров
+UPDATE warehouse 
+SET current_usage = (
+    SELECT COALESCE(SUM(wi.quantity * p.unit_volume), 0) 
+    FROM warehouse_inventory wi
+    JOIN products p ON p.id = wi.product_id
+    WHERE wi.warehouse_id = warehouse.id
+);
+
+-- 7. Добавляем несколько тестовых отгрузок с курьерами
+DO $$
+DECLARE
+    main_warehouse_id BIGINT;
+    location_ids BIGINT[];
+    courier_ids BIGINT[];
+    toy_item_id BIGINT;
+    capsule_item_id BIGINT;
+    i INT;
+    shipment_id BIGINT;
+BEGIN
+    SELECT id INTO main_warehouse_id FROM warehouse WHERE name = 'Основной склад' LIMIT 1;
+    
+    -- Получаем ID локаций
+    SELECT array_agg(id) INTO location_ids FROM locations WHERE org_id = current_setting('seed.org_id')::bigint LIMIT 10;
+    
+    -- Получаем ID курьеров
+    SELECT array_agg(id) INTO courier_ids FROM users WHERE userrole = 'courier' AND org_id = current_setting('seed.org_id')::bigint;
+    
+    -- Получаем ID товаров
+    SELECT wi.id INTO toy_item_id FROM warehouse_inventory wi JOIN products p ON p.id = wi.product_id
+    WHERE wi.warehouse_id = main_warehouse_id AND p.sku = 'TOY-SOFT-10' LIMIT 1;
+    SELECT wi.id INTO capsule_item_id FROM warehouse_inventory wi JOIN products p ON p.id = wi.product_id
+    WHERE wi.warehouse_id = main_warehouse_id AND p.sku = 'CAP-STD-100' LIMIT 1;
+
+    -- Создаем 5 тестовых отгрузок с разными курьерами
+    FOR i IN 1..5 LOOP
+        IF i <= array_length(courier_ids, 1) THEN
+            INSERT INTO warehouse_shipments (warehouse_id, shipment_type, courier_info, shipment_date, status, notes) 
+            VALUES (
+                main_warehouse_id, 
+                'to_courier', 
+                'Курьер ID: ' || courier_ids[i] || ' - Регулярная доставка', 
+                CURRENT_DATE - (i * 2), 
+                CASE 
+                    WHEN i = 1 THEN 'delivered'
+                    WHEN i = 2 THEN 'shipped' 
+                    ELSE 'preparing' 
+                END,
+                'Отгрузка №' || i || ' для пополнения автоматов'
+            )
+            RETURNING id INTO shipment_id;
+
+            -- Добавляем товары в отгрузку
+            INSERT INTO shipment_items (shipment_id, inventory_item_id, quantity) VALUES
+            (shipment_id, toy_item_id, 1),
+            (shipment_id, capsule_item_id, 1);
+        END IF;
+    END LOOP;
+END $$;
+
+-- Начальные остатки загруженных позиций в журнале движений; каждый
+-- становится партией по закупочной цене товара
+WITH opening AS (
+    INSERT INTO stock_movements (item_id, warehouse_id, movement_type, quantity, balance_after, cost, reason)
+    SELECT wi.id, wi.warehouse_id, 'opening', wi.quantity, wi.quantity, wi.quantity * p.default_cost, 'Начальный остаток'
+    FROM warehouse_inventory wi
+    JOIN products p ON p.id = wi.product_id
+    WHERE wi.quantity > 0
+      AND NOT EXISTS (SELECT 1 FROM stock_movements sm WHERE sm.item_id = wi.id)
+    RETURNING id, item_id, quantity, cost
+), lots AS (
+    INSERT INTO stock_lots (item_id, movement_id, unit_cost, quantity, remaining)
+    SELECT item_id, id, cost / quantity, quantity, quantity FROM opening
+)
+UPDATE warehouse_inventory wi
+SET stock_value = opening.cost
+FROM opening
+WHERE wi.id = opening.item_id;
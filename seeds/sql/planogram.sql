@@ -0,0 +1,64 @@
+-- Выкладка автоматов и пополнение по товарам для отчета о продажах
+
+-- Игрушки делят вместимость автомата поровну; цена игры зависит от игрушки
+INSERT INTO machine_planogram (machine_id, product_id, target_count, price_per_play)
+SELECT m.id, p.id, m.capacity_toys / 4, toy.price
+FROM vending_machines m
+JOIN products p ON p.org_id = m.org_id
+JOIN (VALUES
+    ('TOY-HEROES-1', 150.00),
+    ('TOY-SOFT-10', 100.00),
+    ('TOY-CONSTRUCT-1', 120.00),
+    ('TOY-CARS-5', 80.00)
+) AS toy(sku, price) ON toy.sku = p.sku
+WHERE m.org_id = current_setting('seed.org_id')::bigint AND m.capacity_toys >= 4
+ON CONFLICT (machine_id, product_id) DO NOTHING;
+
+-- За неделю до первого пополнения каждого автомата выкладка загружена
+-- до цели, чтобы по следующему пополнению были видны продажи
+INSERT INTO vending_operations (org_id, vending_machine_id, operation_type, performed_by, operation_date,
+                                toys_before, toys_after, toys_added, cash_before, cash_after, cash_collected, notes)
+SELECT o.org_id, o.vending_machine_id, 'restock', o.performed_by, o.operation_date - INTERVAL '7 days',
+       0, 0, 0, 0, 0, 0, 'Загрузка по выкладке'
+FROM (
+    SELECT DISTINCT ON (vending_machine_id) *
+    FROM vending_operations
+    WHERE org_id = current_setting('seed.org_id')::bigint AND operation_type = 'restock'
+    ORDER BY vending_machine_id, operation_date
+) AS o
+WHERE EXISTS (SELECT 1 FROM machine_planogram pl WHERE pl.machine_id = o.vending_machine_id)
+  AND NOT EXISTS (
+    SELECT 1 FROM vending_operations x
+    WHERE x.vending_machine_id = o.vending_machine_id AND x.notes = 'Загрузка по выкладке'
+  );
+
+-- Записанные итоги пополнений делятся между товарами выкладки: добавлено
+-- поровну, а остаток перед пополнением тем меньше, чем лучше игрушка
+-- продается (доли 1:2:3:4 из 10). Загрузка по выкладке — до цели
+-- каждого товара
+INSERT INTO operation_items (operation_id, product_id, count_before, added, price_per_play)
+SELECT o.id, pl.product_id,
+       CASE WHEN o.notes = 'Загрузка по выкладке' THEN 0 ELSE COALESCE(o.toys_before, 0) * toy.leftover / 10 END,
+       CASE WHEN o.notes = 'Загрузка по выкладке' THEN pl.target_count ELSE COALESCE(o.toys_added, 0) / 4 END,
+       pl.price_per_play
+FROM vending_operations o
+JOIN machine_planogram pl ON pl.machine_id = o.vending_machine_id
+JOIN products p ON p.id = pl.product_id
+JOIN (VALUES
+    ('TOY-HEROES-1', 1),
+    ('TOY-SOFT-10', 2),
+    ('TOY-CONSTRUCT-1', 3),
+    ('TOY-CARS-5', 4)
+) AS toy(sku, leftover) ON toy.sku = p.sku
+WHERE o.org_id = current_setting('seed.org_id')::bigint AND o.operation_type = 'restock'
+  AND NOT EXISTS (SELECT 1 FROM operation_items i WHERE i.operation_id = o.id);
+
+-- Итоги пополнений — суммы по товарам
+UPDATE vending_operations o
+SET toys_before = items.before, toys_added = items.added, toys_after = items.before + items.added
+FROM (
+    SELECT operation_id, SUM(count_before) AS before, SUM(added) AS added
+    FROM operation_items
+    GROUP BY operation_id
+) AS items
+WHERE items.operation_id = o.id AND o.org_id = current_setting('seed.org_id')::bigint;
@@ -0,0 +1,79 @@
+-- Демо-пользователи всех ролей (бывшие миграции 006 и 010)
+
+-- 1. Пользователи (только если не существуют)
+DO $$ 
+BEGIN
+    -- Проверяем и вставляем пользователей только если их нет
+    IF NOT EXISTS (SELECT 1 FROM users WHERE username = 'monitor') THEN
+        INSERT INTO users (org_id, username, email, userrole, status, password, fullusername, companyname, companyrole, phone) VALUES
+        (current_setting('seed.org_id')::bigint, 'monitor', 'monitor@testsystem.ru', 'monitor', 1, '$2y$12$Dc7wN3TQlym69XcfYtsnkOXmH6wY0RWfLSDnpsZfMlEEkrT1OFSHW', 'Монитор User', 'Test System', 'Монитор', '+7-999-000-00-01');
+    END IF;
+
+    IF NOT EXISTS (SELECT 1 FROM users WHERE username = 'moderator') THEN
+        INSERT INTO users (org_id, username, email, userrole, status, password, fullusername, companyname, companyrole, phone) VALUES
+        (current_setting('seed.org_id')::bigint, 'moderator', 'moderator@testsystem.ru', 'moderator', 1, '$2y$12$Vqmodk5UMpRqjG0HMbOi4e54R5UffACnh7gMU6obZHBO31uwOv59S', 'Модератор User', 'Test System', 'Модератор', '+7-999-000-00-02');
+    END IF;
+
+    IF NOT EXISTS (SELECT 1 FROM users WHERE username = 'admin') THEN
+        INSERT INTO users (org_id, username, email, userrole, status, password, fullusername, companyname, companyrole, phone) VALUES
+        (current_setting('seed.org_id')::bigint, 'admin', 'admin@testsystem.ru', 'admin', 1, '$2y$12$1b6PV2G0iUgrrjw9S642QOJxoHamlLr3hN4ww90co/OSUlwmiUcuu', 'Администратор', 'Test System', 'Administrator', '+7-999-000-00-03');
+    END IF;
+
+    IF NOT EXISTS (SELECT 1 FROM users WHERE username = 'agent') THEN
+        INSERT INTO users (org_id, username, email, userrole, status, password, fullusername, companyname, companyrole, phone) VALUES
+        (current_setting('seed.org_id')::bigint, 'agent', 'agent@testsystem.ru', 'agent', 1, '$2y$12$Zg.mLS/GaVrrPS84kGHU2uTHlYEul18Iip53w/HHU0.DnFAVGk.TC', 'Агент', 'Test System', 'Агент', '+7-999-000-00-04');
+    END IF;
+
+    IF NOT EXISTS (SELECT 1 FROM users WHERE username = 'support') THEN
+        INSERT INTO users (org_id, username, email, userrole, status, password, fullusername, companyname, companyrole, phone) VALUES
+        (current_setting('seed.org_id')::bigint, 'support', 'support@testsystem.ru', 'support', 1, '$2y$12$JJ3Ygj72LIJ3iEEBxLnF7ubFMHK/U1iWYK1RvGH7g7EY2cMRDqB9K', 'Техподдержка', 'Test System', 'Техническая поддержка', '+7-999-000-00-05');
+    END IF;
+
+    IF NOT EXISTS (SELECT 1 FROM users WHERE username = 'partner') THEN
+        INSERT INTO users (org_id, username, email, userrole, status, password, fullusername, companyname, companyrole, phone) VALUES
+        (current_setting('seed.org_id')::bigint, 'partner', 'partner@testsystem.ru', 'partner', 1, '$2y$12$NRo08V/jZNqHjf0w6JwvcOZ0SUrVAdvuA9Lyq.lCC6zchcXxahH2a', 'Партнер', 'Taxi Company', 'Управляющий', '+7-999-000-00-06');
+    END IF;
+
+    IF NOT EXISTS (SELECT 1 FROM users WHERE username = 'operator1') THEN
+        INSERT INTO users (org_id, username, email, userrole, status, password, fullusername, companyname, companyrole, phone) VALUES
+        (current_setting('seed.org_id')::bigint, 'operator1', 'operator1@testsystem.ru', 'operator', 1, '$2y$12$testpasswordhashforoperator1', 'Оператор 1', 'Test System', 'Оператор', '+7-999-000-00-07');
+    END IF;
+
+    IF NOT EXISTS (SELECT 1 FROM users WHERE username = 'tech1') THEN
+        INSERT INTO users (org_id, username, email, userrole, status, password, fullusername, companyname, companyrole, phone) VALUES
+        (current_setting('seed.org_id')::bigint, 'tech1', 'tech1@testsystem.ru', 'technician', 1, '$2y$12$testpasswordhashfortechnician1', 'Техник 1', 'Test System', 'Техник', '+7-999-000-00-08');
+    END IF;
+END $$;
+
+
+-- 2. Добавляем 5 курьеров как пользователей с ролью courier
+DO $$ 
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM users WHERE username = 'courier1') THEN
+        INSERT INTO users (org_id, username, email, userrole, status, password, fullusername, companyname, companyrole, phone) VALUES
+        (current_setting('seed.org_id')::bigint, 'courier1', 'courier1@testsystem.ru', 'courier', 1, '$2y$12$courier1passwordhash', 'Иванов Алексей', 'Курьерская служба', 'Курьер', '+7-999-111-11-11');
+    END IF;
+
+    IF NOT EXISTS (SELECT 1 FROM users WHERE username = 'courier2') THEN
+        INSERT INTO users (org_id, username, email, userrole, status, password, fullusername, companyname, companyrole, phone) VALUES
+        (current_setting('seed.org_id')::bigint, 'courier2', 'courier2@testsystem.ru', 'courier', 1, '$2y$12$courier2passwordhash', 'Петров Михаил', 'Курьерская служба', 'Курьер', '+7-999-222-22-22');
+    END IF;
+
+    IF NOT EXISTS (SELECT 1 FROM users WHERE username = 'courier3') THEN
+        INSERT INTO users (org_id, username, email, userrole, status, password, fullusername, companyname, companyrole, phone) VALUES
+        (current_setting('seed.org_id')::bigint, 'courier3', 'courier3@testsystem.ru', 'courier', 1, '$2y$12$courier3passwordhash', 'Сидорова Анна', 'Курьерская служба', 'Курьер', '+7-999-333-33-33');
+    END IF;
+
+    IF NOT EXISTS (SELECT 1 FROM users WHERE username = 'courier4') THEN
+        INSERT INTO users (org_id, username, email, userrole, status, password, fullusername, companyname, companyrole, phone) VALUES
+        (current_setting('seed.org_id')::bigint, 'courier4', 'courier4@testsystem.ru', 'courier', 1, '$2y$12$courier4passwordhash', 'Кузнецов Денис', 'Курьерская служба', 'Курьер', '+7-999-444-44-44');
+    END IF;
+
+    IF NOT EXISTS (SELECT 1 FROM users WHERE username = 'courier5') THEN
+        INSERT INTO users (org_id, username, email, userrole, status, password, fullusername, companyname, companyrole, phone) VALUES
+        (current_setting('seed.org_id')::bigint, 'courier5', 'courier5@testsystem.ru', 'courier', 1, '$2y$12$courier5passwordhash', 'Морозова Екатерина', 'Курьерская служба', 'Курьер', '+7-999-555-55-55');
+    END IF;
+END $$;
+
+-- 3. Администратор демо-организации получает кросс-организационный доступ
+UPDATE users SET is_superadmin = true WHERE username = 'admin' AND org_id = current_setting('seed.org_id')::bigint;
@@ -0,0 +1,211 @@
+-- Основной склад, категории, остатки, поставки и отгрузки (бывшая миграция 008)
+
+-- 1. Создаем основной склад
+INSERT INTO warehouse (org_id, name, address, contact_person, contact_phone, total_capacity, current_usage) 
+SELECT current_setting('seed.org_id')::bigint, 'Основной склад', 'ул. Складская, 15, Москва', 'Смирнов Александр', '+7-999-111-22-33', 5000, 0
+WHERE NOT EXISTS (SELECT 1 FROM warehouse WHERE name = 'Основной склад');
+
+-- 2. Добавляем категории товаров
+INSERT INTO warehouse_categories (name, description) VALUES
+('Вендинговые автоматы', 'Различные модели вендинговых автоматов для игрушек'),
+('Игрушки', 'Игрушки для наполнения автоматов'),
+('Капсулы', 'Капсулы для упаковки игрушек')
+ON CONFLICT (name) DO NOTHING;
+
+-- 3. Добавляем товары в справочник и остатки на склад
+DO $$
+DECLARE
+    seed_org_id BIGINT := current_setting('seed.org_id')::bigint;
+    main_warehouse_id BIGINT;
+    cat_machines BIGINT;
+    cat_toys BIGINT;
+    cat_capsules BIGINT;
+BEGIN
+    -- Получаем ID склада и категорий
+    SELECT id INTO main_warehouse_id FROM warehouse WHERE name = 'Основной склад' LIMIT 1;
+    SELECT id INTO cat_machines FROM warehouse_categories WHERE name = 'Вендинговые автоматы' LIMIT 1;
+    SELECT id INTO cat_toys FROM warehouse_categories WHERE name = 'Игрушки' LIMIT 1;
+    SELECT id INTO cat_capsules FROM warehouse_categories WHERE name = 'Капсулы' LIMIT 1;
+
+    INSERT INTO products (org_id, sku, name, item_type, category_id, description, default_cost, supplier_name, unit_volume) VALUES
+    -- Вендинговые автоматы
+    (seed_org_id, 'VM-TM3000', 'ToyMaster 3000', 'vending_machine', cat_machines, 'Вендинговый автомат премиум-класса, вместимость 100 игрушек', 50000.00, 'Завод "ВендингМаш"', 100),
+    (seed_org_id, 'VM-TM2000', 'ToyMaster 2000', 'vending_machine', cat_machines, 'Вендинговый автомат стандарт-класса, вместимость 80 игрушек', 35000.00, 'Завод "ВендингМаш"', 100),
+    (seed_org_id, 'VM-TM1000', 'ToyMaster 1000', 'vending_machine', cat_machines, 'Компактный вендинговый автомат, вместимость 50 игрушек', 25000.00, 'Завод "ВендингМаш"', 100),
+    -- Игрушки
+    (seed_org_id, 'TOY-SOFT-10', 'Мягкие игрушки (набор)', 'toy', cat_toys, 'Набор из 10 мягких игрушек разных животных', 150.00, 'ООО "ИгрушкиОпт"', 1),
+    (seed_org_id, 'TOY-HEROES-1', 'Фигурки супергероев', 'toy', cat_toys, 'Коллекционные фигурки популярных супергероев', 200.00, 'ООО "ИгрушкиОпт"', 1),
+    (seed_org_id, 'TOY-CARS-5', 'Машинки миниатюрные', 'toy', cat_toys, 'Набор миниатюрных машинок разных моделей', 120.00, 'ООО "ИгрушкиОпт"', 1),
+    (seed_org_id, 'TOY-CONSTRUCT-1', 'Конструктор мини', 'toy', cat_toys, 'Мини-конструктор для сборки различных моделей', 180.00, 'ООО "ИгрушкиОпт"', 1),
+    -- Капсулы
+    (seed_org_id, 'CAP-STD-100', 'Капсулы стандартные (прозрачные)', 'capsule', cat_capsules, 'Стандартные прозрачные капсулы для игрушек, 100 шт.', 300.00, 'ООО "ИгрушкиОпт"', 2),
+    (seed_org_id, 'CAP-COLOR-100', 'Капсулы цветные (набор)', 'capsule', cat_capsules, 'Набор цветных капсул, 5 цветов по 20 шт.', 350.00, 'ООО "ИгрушкиОпт"', 2),
+    (seed_org_id, 'CAP-GOLD-50', 'Капсулы премиум (золотые)', 'capsule', cat_capsules, 'Премиум капсулы золотого цвета, 50 шт.', 500.00, 'ООО "ИгрушкиОпт"', 2)
+    ON CONFLICT ON CONSTRAINT products_org_id_sku_key DO NOTHING;
+
+    INSERT INTO warehouse_inventory (warehouse_id, product_id, quantity, min_stock_level, max_stock_level)
+    SELECT main_warehouse_id, p.id, stock.quantity, stock.min_level, stock.max_level
+    FROM (VALUES
+        ('VM-TM3000', 5, 2, 10),
+        ('VM-TM2000', 3, 1, 5),
+        ('VM-TM1000', 2, 1, 3),
+        ('TOY-SOFT-10', 150, 50, 500),
+        ('TOY-HEROES-1', 200, 100, 1000),
+        ('TOY-CARS-5', 180, 80, 800),
+        ('TOY-CONSTRUCT-1', 120, 60, 600),
+        ('CAP-STD-100', 80, 20, 200),
+        ('CAP-COLOR-100', 60, 15, 150),
+        ('CAP-GOLD-50', 30, 10, 100)
+    ) AS stock(sku, quantity, min_level, max_level)
+    JOIN products p ON p.org_id = seed_org_id AND p.sku = stock.sku
+    ON CONFLICT ON CONSTRAINT warehouse_inventory_warehouse_product_key DO NOTHING;
+END $$;
+
+-- 4. Добавляем ожидаемые поставки
+DO $$
+DECLARE
+    warehouse_id BIGINT;
+    toy_item_id BIGINT;
+    capsule_item_id BIGINT;
+    machine_item_id BIGINT;
+    supply_id BIGINT;
+BEGIN
+    SELECT id INTO warehouse_id FROM warehouse WHERE name = 'Основной склад' LIMIT 1;
+    
+    -- Получаем ID товаров для поставок
+    SELECT wi.id INTO toy_item_id FROM warehouse_inventory wi JOIN products p ON p.id = wi.product_id WHERE p.sku = 'TOY-SOFT-10' ORDER BY wi.id LIMIT 1;
+    SELECT wi.id INTO capsule_item_id FROM warehouse_inventory wi JOIN products p ON p.id = wi.product_id WHERE p.sku = 'CAP-STD-100' ORDER BY wi.id LIMIT 1;
+    SELECT wi.id INTO machine_item_id FROM warehouse_inventory wi JOIN products p ON p.id = wi.product_id WHERE p.sku = 'VM-TM3000' ORDER BY wi.id LIMIT 1;
+
+    -- Поставка игрушек и капсул
+    INSERT INTO warehouse_supplies (warehouse_id, supplier_name, supply_date, expected_date, status, total_amount, notes) 
+    SELECT warehouse_id, 'ООО "ИгрушкиОпт"', '2024-02-01', '2024-02-10', 'ordered', 75000.00, 'Регулярная поставка игрушек и капсул'
+    WHERE NOT EXISTS (SELECT 1 FROM warehouse_supplies WHERE supplier_name = 'ООО "ИгрушкиОпт"' AND supply_date = '2024-02-01')
+    RETURNING id INTO supply_id;
+
+    IF FOUND THEN
+        INSERT INTO supply_items (supply_id, inventory_item_id, quantity_ordered, unit_price) VALUES
+        (supply_id, toy_item_id, 300, 150.00),
+        (supply_id, capsule_item_id, 100, 300.00);
+    END IF;
+
+    -- Поставка новых автоматов
+    INSERT INTO warehouse_supplies (warehouse_id, supplier_name, supply_date, expected_date, status, total_amount, notes) 
+    SELECT warehouse_id, 'Завод "ВендингМаш"', '2024-02-05', '2024-02-15', 'ordered', 200000.00, 'Поставка новых автоматов ToyMaster 3000'
+    WHERE NOT EXISTS (SELECT 1 FROM warehouse_supplies WHERE supplier_name = 'Завод "ВендингМаш"' AND supply_date = '2024-02-05')
+    RETURNING id INTO supply_id;
+
+    IF FOUND THEN
+        INSERT INTO supply_items (supply_id, inventory_item_id, quantity_ordered, unit_price) VALUES
+        (supply_id, machine_item_id, 4, 50000.00);
+    END IF;
+END $$;
+
+-- 5. Добавляем отгрузки
+DO $$
+DECLARE
+    warehouse_id BIGINT;
+    location1_id BIGINT;
+    location2_id BIGINT;
+    toy_item_id BIGINT;
+    capsule_item_id BIGINT;
+    machine_item_id BIGINT;
+    machine_item2_id BIGINT;
+    vending_machine_id BIGINT;
+    shipment_id BIGINT;
+BEGIN
+    SELECT id INTO warehouse_id FROM warehouse WHERE name = 'Основной склад' LIMIT 1;
+    SELECT id INTO location1_id FROM locations WHERE name = 'ТЦ "Москва"' LIMIT 1;
+    SELECT id INTO location2_id FROM locations WHERE name = 'ТРК "Европа"' LIMIT 1;
+    
+    -- Получаем ID товаров
+    SELECT wi.id INTO toy_item_id FROM warehouse_inventory wi JOIN products p ON p.id = wi.product_id WHERE p.sku = 'TOY-SOFT-10' ORDER BY wi.id LIMIT 1;
+    SELECT wi.id INTO capsule_item_id FROM warehouse_inventory wi JOIN products p ON p.id = wi.product_id WHERE p.sku = 'CAP-STD-100' ORDER BY wi.id LIMIT 1;
+    SELECT wi.id INTO machine_item_id FROM warehouse_inventory wi JOIN products p ON p.id = wi.product_id WHERE p.sku = 'VM-TM3000' ORDER BY wi.id LIMIT 1;
+    SELECT wi.id INTO machine_item2_id FROM warehouse_inventory wi JOIN products p ON p.id = wi.product_id WHERE p.sku = 'VM-TM2000' ORDER BY wi.id LIMIT 1;
+    SELECT id INTO vending_machine_id FROM vending_machines WHERE serial_number = 'VM004' LIMIT 1;
+
+    -- Отгрузка автомата в локацию
+    INSERT INTO warehouse_shipments (warehouse_id, shipment_type, target_location_id, shipment_date, status, notes) 
+    SELECT warehouse_id, 'to_location', location1_id, '2024-01-20', 'delivered', 'Установка нового автомата в ТЦ "Москва"'
+    WHERE NOT EXISTS (SELECT 1 FROM warehouse_shipments WHERE target_location_id = location1_id AND shipment_date = '2024-01-20')
+    RETURNING id INTO shipment_id;
+
+    IF FOUND THEN
+        INSERT INTO shipment_items (shipment_id, inventory_item_id, vending_machine_id, quantity) VALUES
+        (shipment_id, machine_item_id, vending_machine_id, 1);
+    END IF;
+
+    -- Отгрузка игрушек и капсул курьеру
+    INSERT INTO warehouse_shipments (warehouse_id, shipment_type, courier_info, shipment_date, status, notes) 
+    SELECT warehouse_id, 'to_courier', 'Курьер: Иванов П.С., тел. +7-999-444-55-66', '2024-01-25', 'shipped', 'Отгрузка для пополнения автоматов в ТРК "Европа"'
+    WHERE NOT EXISTS (SELECT 1 FROM warehouse_shipments WHERE courier_info LIKE '%Иванов П.С.%' AND shipment_date = '2024-01-25')
+    RETURNING id INTO shipment_id;
+
+    IF FOUND THEN
+        INSERT INTO shipment_items (shipment_id, inventory_item_id, quantity) VALUES
+        (shipment_id, toy_item_id, 50),
+        (shipment_id, capsule_item_id, 20);
+    END IF;
+
+    -- Отгрузка для технического обслуживания
+    INSERT INTO warehouse_shipments (warehouse_id, shipment_type, target_location_id, shipment_date, status, notes) 
+    SELECT warehouse_id, 'to_location', location2_id, '2024-01-28', 'preparing', 'Замена неисправного автомата'
+    WHERE NOT EXISTS (SELECT 1 FROM warehouse_shipments WHERE target_location_id = location2_id AND shipment_date = '2024-01-28')
+    RETURNING id INTO shipment_id;
+
+    IF FOUND THEN
+        INSERT INTO shipment_items (shipment_id, inventory_item_id, quantity) VALUES
+        (shipment_id, machine_item2_id, 1);
+    END IF;
+END $$;
+
+-- Зоны основного склада: автоматы стоят отдельно от стеллажей с
+-- игрушками; капсулы оставлены неразмещенными, чтобы загрузка склада
+-- подсказала, куда их положить
+INSERT INTO warehouse_zones (warehouse_id, name, capacity)
+SELECT w.id, zone.name, zone.capacity
+FROM warehouse w
+CROSS JOIN (VALUES
+    ('Зона автоматов', 1500),
+    ('Стеллаж А', 1500),
+    ('Стеллаж Б', 1000)
+) AS zone(name, capacity)
+WHERE w.name = 'Основной склад'
+ON CONFLICT (warehouse_id, name) DO NOTHING;
+
+UPDATE warehouse_inventory wi
+SET zone_id = z.id
+FROM products p, warehouse_zones z, warehouse w
+WHERE p.id = wi.product_id AND w.id = wi.warehouse_id AND w.name = 'Основной склад'
+  AND z.warehouse_id = w.id AND wi.zone_id IS NULL
+  AND z.name = CASE p.item_type WHEN 'vending_machine' THEN 'Зона автоматов' WHEN 'toy' THEN 'Стеллаж А' END;
+
+-- Обновляем текущее использование склада: штука занимает объем товара
+UPDATE warehouse 
+SET current_usage = (
+    SELECT COALESCE(SUM(wi.quantity * p.unit_volume), 0) 
+    FROM warehouse_inventory wi
+    JOIN products p ON p.id = wi.product_id
+    WHERE wi.warehouse_id = warehouse.id
+)
+WHERE name = 'Основной склад';
+
+-- Начальные остатки загруженных позиций в журнале движений; каждый
+-- становится партией по закупочной цене товара
+WITH opening AS (
+    INSERT INTO stock_movements (item_id, warehouse_id, movement_type, quantity, balance_after, cost, reason)
+    SELECT wi.id, wi.warehouse_id, 'opening', wi.quantity, wi.quantity, wi.quantity * p.default_cost, 'Начальный остаток'
+    FROM warehouse_inventory wi
+    JOIN products p ON p.id = wi.product_id
+    WHERE wi.quantity > 0
+      AND NOT EXISTS (SELECT 1 FROM stock_movements sm WHERE sm.item_id = wi.id)
+    RETURNING id, item_id, quantity, cost
+), lots AS (
+    INSERT INTO stock_lots (item_id, movement_id, unit_cost, quantity, remaining)
+    SELECT item_id, id, cost / quantity, quantity, quantity FROM opening
+)
+UPDATE warehouse_inventory wi
+SET stock_value = opening.cost
+FROM opening
+WHERE wi.id = opening.item_id;
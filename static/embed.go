@@ -0,0 +1,7 @@
+// Package static встраивает CSS и JavaScript в бинарник.
+package static
+
+import "embed"
+
+//go:embed css js
+var FS embed.FS
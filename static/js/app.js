@@ -45,7 +45,7 @@ const VendERP = {
                 // Мини-график
                 const totalElement = document.getElementById('cash-mini-total');
                 if (totalElement) {
-                    totalElement.textContent = this.formatCurrency(this.data.total || 0);
+                    totalElement.textContent = this.formatCurrency(this.data.amount ?? this.data.total ?? 0);
                 }
 
                 this.updateMiniChart(labels, amounts, series.color);
@@ -53,7 +53,7 @@ const VendERP = {
                 // Полноэкранный график
                 const fullTotal = document.getElementById('cash-full-total');
                 if (fullTotal) {
-                    fullTotal.textContent = this.formatCurrency(this.data.total || 0);
+                    fullTotal.textContent = this.formatCurrency(this.data.amount ?? this.data.total ?? 0);
                 }
 
                 const periodElement = document.getElementById('cash-full-period');
@@ -1732,20 +1732,55 @@ operations: {
             }
         });
 
+        // A form with field errors comes back as 422 and a stale edit as 409
+        // with the conflict view; both replace the form in the modal (the
+        // server sets HX-Retarget)
+        document.addEventListener('htmx:beforeSwap', function (evt) {
+            if (evt.detail.xhr.status === 422 || evt.detail.xhr.status === 409) {
+                evt.detail.shouldSwap = true;
+                evt.detail.isError = false;
+            }
+        });
+
         // Close modal after successful save for various tables
         document.addEventListener('htmx:beforeSwap', function (evt) {
-            const targets = ['accounts-table', 'machines-table', 'locations-table', 'operations-table'];
+            const targets = ['accounts-table', 'machines-table', 'locations-table', 'operations-table', 'products-table', 'supplies-table'];
             if (targets.includes(evt.detail.target.id) && evt.detail.shouldSwap) {
                 VendERP.hideModal();
             }
         });
 
-        // Handle HTMX errors
+        // Handle HTMX errors: the server answers 500 with a ready-made
+        // notification fragment that carries the request ID
         document.addEventListener('htmx:responseError', function (evt) {
             console.error('HTMX Error:', evt.detail);
+            const xhr = evt.detail.xhr;
+            if ((xhr.getResponseHeader('Content-Type') || '').startsWith('text/html')) {
+                VendERP.showNotification(xhr.responseText);
+            }
         });
     },
 
+    // Show a server-rendered notification fragment for a few seconds
+    showNotification: function (html) {
+        let container = document.querySelector('.notifications-container');
+        if (!container) {
+            container = document.createElement('div');
+            container.className = 'notifications-container';
+            document.body.appendChild(container);
+        }
+        const wrapper = document.createElement('div');
+        wrapper.innerHTML = html;
+        const notification = wrapper.firstElementChild;
+        if (!notification) {
+            return;
+        }
+        container.appendChild(notification);
+        setTimeout(function () {
+            notification.remove();
+        }, 10000);
+    },
+
     // Utility function to format dates
     formatDate: function (dateString) {
         const date = new Date(dateString);
@@ -0,0 +1,7 @@
+// Package templates встраивает HTML-шаблоны в бинарник.
+package templates
+
+import "embed"
+
+//go:embed *.html layouts/*.html partials/*.html components/*.html
+var FS embed.FS